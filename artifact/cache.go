@@ -2,6 +2,8 @@ package artifact
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"hash/fnv"
 	"io"
@@ -82,6 +84,13 @@ type Cache interface {
 	// be added.
 	Status() (*Status, error)
 
+	// Usage reports how much of the cache is in use.
+	Usage() (*CacheUsage, error)
+
+	// Verify re-hashes the cached content of the artifacts in the tree and
+	// reports any that do not match it.
+	Verify(ctx context.Context) (*VerifyReport, error)
+
 	// Close must be called in order to clean up any in use resources.
 	Close() error
 }
@@ -123,6 +132,10 @@ func NewCache(config *Config) (Cache, error) {
 		cache:   fsStore,
 		config:  config,
 		rootDir: artifactsRoot,
+		lock:    &fileLock{path: filepath.Join(cacheDir, lockFileName)},
+	}
+	if err := cStore.recoverIncomplete(); err != nil {
+		return nil, errors.Wrap(err, "error recovering cache")
 	}
 	if config.SourceStore == nil {
 		cStore.source = fsStore
@@ -136,12 +149,19 @@ func NewCache(config *Config) (Cache, error) {
 	return &cStore, nil
 }
 
+// A cachedStore may be shared by many processes. Within a process, mu guards
+// it. Across processes, anything writing to the cache or root holds lock, and
+// artifacts are written to temporary files renamed into place so that they are
+// never seen partially written.
 type cachedStore struct {
 	mu      sync.Mutex
+	lock    *fileLock
 	cache   *fileSystemStore
 	source  Store
 	config  *Config
 	rootDir string
+
+	hits, misses, evictions uint64
 }
 
 func (s *cachedStore) Contains(hash string) error {
@@ -159,10 +179,12 @@ func (s *cachedStore) Load(hash string) (io.ReadCloser, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if rc, err := s.cache.Load(hash); err == nil {
+		s.hits++
 		return rc, nil
 	} else if s.source == nil || !IsNotFoundError(err) {
 		return nil, err
 	}
+	s.misses++
 	return s.source.Load(hash)
 }
 
@@ -173,10 +195,12 @@ func (s *cachedStore) Store(hash string, r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	if err := s.source.Store(hash, bytes.NewReader(data)); err != nil {
+	unlock, err := s.lock.lock()
+	if err != nil {
 		return err
 	}
-	return s.cache.Store(hash, bytes.NewReader(data))
+	defer unlock()
+	return s.store(hash, data)
 }
 
 func (s *cachedStore) NewPath(to string) string {
@@ -186,6 +210,11 @@ func (s *cachedStore) NewPath(to string) string {
 func (s *cachedStore) Ensure(path string, ignoreLimit bool) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 	node, err := s.config.Lookup(path)
 	if err != nil {
 		return "", err
@@ -196,6 +225,11 @@ func (s *cachedStore) Ensure(path string, ignoreLimit bool) (string, error) {
 func (s *cachedStore) Remove(path string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if strings.HasPrefix(path, s.rootDir) {
 		path = strings.TrimPrefix(path, s.rootDir+"/")
 	}
@@ -209,6 +243,11 @@ func (s *cachedStore) Remove(path string) error {
 func (s *cachedStore) Clean() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	return s.cleanTree(s.config.tree, s.rootDir)
 }
 
@@ -217,6 +256,11 @@ func (s *cachedStore) Clean() error {
 func (s *cachedStore) WriteThroughUser() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	if err := s.writeThroughUserTree(s.config.tree, nil, s.rootDir); err != nil {
 		return err
 	}
@@ -229,6 +273,18 @@ func (s *cachedStore) Status() (*Status, error) {
 	return s.status()
 }
 
+func (s *cachedStore) Usage() (*CacheUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage()
+}
+
+func (s *cachedStore) Verify(ctx context.Context) (*VerifyReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verify(ctx)
+}
+
 func (s *cachedStore) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -242,7 +298,11 @@ func (s *cachedStore) store(hash string, data []byte) error {
 	if err := s.source.Store(hash, bytes.NewReader(data)); err != nil {
 		return err
 	}
-	return s.cache.Store(hash, bytes.NewReader(data))
+	if err := s.cache.Store(hash, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	s.evict(hash)
+	return nil
 }
 
 // ensureNode verifies that all nodes living under a tree with respect to a given
@@ -259,6 +319,7 @@ func (s *cachedStore) ensureNode(node *TreeNode, dstPath string, ignoreLimit boo
 	nodeHash := node.external.Hash
 
 	if err := s.cache.Contains(nodeHash); err == nil {
+		s.hits++
 		if err := emplaceFile(s.cache, nodeHash, dstPath); err != nil {
 			return "", errors.Wrap(err, "error emplacing into file system cache")
 		}
@@ -272,18 +333,34 @@ func (s *cachedStore) ensureNode(node *TreeNode, dstPath string, ignoreLimit boo
 		return "", nil
 	}
 
+	s.misses++
 	Logger.Debugw("loading from source", "path", dstPath, "hash", nodeHash)
 	rc, err := s.source.Load(nodeHash)
 	if err != nil {
 		return "", errors.Wrap(err, "error loading from source cache")
 	}
 	defer utils.UncheckedErrorFunc(rc.Close)
-	if err := s.cache.Store(nodeHash, rc); err != nil {
+	hasher := fnv.New128a()
+	digester := sha256.New()
+	if err := s.cache.Store(nodeHash, io.TeeReader(rc, io.MultiWriter(hasher, digester))); err != nil {
 		return "", errors.Wrap(err, "error storing into file system cache")
 	}
+	// the signature of a tree covers the content of its artifacts through their digests.
+	if s.config.PublicKey != nil {
+		if actualHash := hex.EncodeToString(hasher.Sum(nil)); actualHash != nodeHash {
+			utils.UncheckedError(s.cache.remove(nodeHash))
+			return "", errors.Errorf("content loaded from source for %q has hash %q but expected %q", dstPath, actualHash, nodeHash)
+		}
+		if actualDigest := hex.EncodeToString(digester.Sum(nil)); actualDigest != node.external.SHA256 {
+			utils.UncheckedError(s.cache.remove(nodeHash))
+			return "", errors.Errorf(
+				"content loaded from source for %q has sha256 %q but expected %q", dstPath, actualDigest, node.external.SHA256)
+		}
+	}
 	if err := emplaceFile(s.cache, nodeHash, dstPath); err != nil {
 		return "", errors.Wrap(err, "error emplacing into file system cache")
 	}
+	s.evict(nodeHash)
 	return dstPath, nil
 }
 
@@ -321,10 +398,12 @@ type nodeChangeType int
 const (
 	nodeChangeTypeUnstored nodeChangeType = iota
 	nodeChangeTypeModified
+	// nodeChangeTypeUndigested is an unchanged artifact whose node has no SHA-256 digest yet.
+	nodeChangeTypeUndigested
 )
 
 // walkUserTreeUncached examines the tree with respect to the given local path and visits all artifacts
-// not in the tree.
+// not in the tree, as well as those in it without a SHA-256 digest.
 func (s *cachedStore) walkUserTreeUncached(
 	tree map[string]*TreeNode,
 	treePath []string,
@@ -341,6 +420,10 @@ func (s *cachedStore) walkUserTreeUncached(
 			if _, ok := s.config.ignoreSet[name]; ok {
 				continue
 			}
+			// artifacts being emplaced by another process are not the user's.
+			if isTempFile(name) {
+				continue
+			}
 		}
 		newTreePath := append([]string{}, treePath...)
 		newTreePath = append(newTreePath, name)
@@ -376,13 +459,16 @@ func (s *cachedStore) walkUserTreeUncached(
 			if err != nil {
 				return err
 			}
-			if hasExistingNode && !existingNode.IsInternal() && existingNode.external.Hash == nodeHash {
-				return nil
-			}
 			var changeType nodeChangeType
-			if hasExistingNode {
+			switch {
+			case hasExistingNode && !existingNode.IsInternal() && existingNode.external.Hash == nodeHash:
+				if existingNode.external.SHA256 != "" {
+					return nil
+				}
+				changeType = nodeChangeTypeUndigested
+			case hasExistingNode:
 				changeType = nodeChangeTypeModified
-			} else {
+			default:
 				changeType = nodeChangeTypeUnstored
 			}
 			return visit(changeType, nodeHash, newLocalPath, newTreePath, data)
@@ -401,11 +487,13 @@ func (s *cachedStore) writeThroughUserTree(tree map[string]*TreeNode, treePath [
 		treePath,
 		localPath,
 		func(changeType nodeChangeType, nodeHash, localPath string, treePath []string, data []byte) error {
-			Logger.Debugw("writing through", "path", localPath, "hash", nodeHash)
-			if err := s.store(nodeHash, data); err != nil {
-				return err
+			if changeType != nodeChangeTypeUndigested {
+				Logger.Debugw("writing through", "path", localPath, "hash", nodeHash)
+				if err := s.store(nodeHash, data); err != nil {
+					return err
+				}
 			}
-			s.config.StoreHash(nodeHash, len(data), treePath)
+			s.config.storeArtifact(nodeHash, computeSHA256(data), len(data), treePath)
 			return nil
 		})
 }
@@ -440,6 +528,23 @@ func computeHash(data []byte) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// computeSHA256 returns the hex encoded SHA-256 digest of the data.
+func computeSHA256(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// computeHashReader works like computeHash but for everything read from r, whose
+// size it also returns.
+func computeHashReader(r io.Reader) (string, int64, error) {
+	hasher := fnv.New128a()
+	size, err := io.Copy(hasher, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
 type noopCache struct{}
 
 func (cache *noopCache) Contains(hash string) error {
@@ -478,6 +583,14 @@ func (cache *noopCache) Status() (*Status, error) {
 	return &Status{}, nil
 }
 
+func (cache *noopCache) Usage() (*CacheUsage, error) {
+	return &CacheUsage{}, nil
+}
+
+func (cache *noopCache) Verify(ctx context.Context) (*VerifyReport, error) {
+	return &VerifyReport{}, nil
+}
+
 func (cache *noopCache) Close() error {
 	return nil
 }
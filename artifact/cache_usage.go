@@ -0,0 +1,118 @@
+package artifact
+
+import (
+	"sort"
+	"strings"
+
+	"go.viam.com/utils"
+)
+
+// CacheUsage describes how much of the local cache is in use.
+type CacheUsage struct {
+	// Size is how many bytes the cached artifacts take up.
+	Size int64
+	// Artifacts is how many artifacts are cached.
+	Artifacts int
+	// PinnedSize is how many of those bytes are taken up by pinned artifacts.
+	PinnedSize int64
+	// PinnedArtifacts is how many of the cached artifacts are pinned.
+	PinnedArtifacts int
+	// SizeLimit is the configured size limit of the cache, or 0 if it is unbounded.
+	SizeLimit int64
+	// Hits and Misses count how many artifacts were and were not already cached
+	// when loaded or ensured since the cache was created.
+	Hits, Misses uint64
+	// Evictions counts how many artifacts were evicted since the cache was created.
+	Evictions uint64
+}
+
+// usage inspects the cache and reports how much of it is in use.
+func (s *cachedStore) usage() (*CacheUsage, error) {
+	artifacts, err := s.cache.list()
+	if err != nil {
+		return nil, err
+	}
+	pinned := s.pinnedHashes()
+	usage := CacheUsage{
+		Artifacts: len(artifacts),
+		SizeLimit: s.config.CacheSizeLimit,
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+	}
+	for _, artifact := range artifacts {
+		usage.Size += artifact.size
+		if _, ok := pinned[artifact.hash]; ok {
+			usage.PinnedSize += artifact.size
+			usage.PinnedArtifacts++
+		}
+	}
+	return &usage, nil
+}
+
+// pinnedHashes returns the hashes of all artifacts in the tree under the pinned paths.
+func (s *cachedStore) pinnedHashes() utils.StringSet {
+	pinned := utils.StringSet{}
+	for _, path := range s.config.Pin {
+		if path = strings.Trim(path, "/"); path == "" {
+			path = "/"
+		}
+		node, err := s.config.Lookup(path)
+		if err != nil {
+			continue
+		}
+		node.collectHashes(pinned)
+	}
+	return pinned
+}
+
+// evict removes the least recently used artifacts from the cache until it fits
+// within its size limit. Pinned artifacts and the one given, which was just
+// cached, are never evicted. Without a source store, the cache is where the
+// artifacts of the tree are stored, so none of those are evicted either.
+func (s *cachedStore) evict(justCached string) {
+	if s.config.CacheSizeLimit <= 0 {
+		return
+	}
+	artifacts, err := s.cache.list()
+	if err != nil {
+		Logger.Warnw("error listing cache for eviction", "error", err)
+		return
+	}
+	var size int64
+	for _, artifact := range artifacts {
+		size += artifact.size
+	}
+	if size <= s.config.CacheSizeLimit {
+		return
+	}
+
+	protected := s.pinnedHashes()
+	protected.Add(justCached)
+	if s.config.SourceStore == nil {
+		if root, err := s.config.Lookup("/"); err == nil {
+			root.collectHashes(protected)
+		}
+	}
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].lastUsed.Before(artifacts[j].lastUsed)
+	})
+	for _, artifact := range artifacts {
+		if size <= s.config.CacheSizeLimit {
+			return
+		}
+		if _, ok := protected[artifact.hash]; ok {
+			continue
+		}
+		Logger.Debugw("evicting from cache", "hash", artifact.hash, "size", artifact.size)
+		if err := s.cache.remove(artifact.hash); err != nil {
+			Logger.Warnw("error evicting from cache", "hash", artifact.hash, "error", err)
+			continue
+		}
+		size -= artifact.size
+		s.evictions++
+	}
+	if size > s.config.CacheSizeLimit {
+		Logger.Warnw("cache is over its size limit with only pinned artifacts left", "size", size, "limit", s.config.CacheSizeLimit)
+	}
+}
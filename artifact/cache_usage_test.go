@@ -0,0 +1,120 @@
+package artifact
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestCacheEviction(t *testing.T) {
+	artDir := t.TempDir()
+	sourceDir := filepath.Join(artDir, "source")
+	source, err := newFileSystemStore(&FileSystemStoreConfig{Path: sourceDir})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, source.Store("foo", strings.NewReader("foocontent")), test.ShouldBeNil)
+	test.That(t, source.Store("bar", strings.NewReader("barcontent")), test.ShouldBeNil)
+	test.That(t, source.Store("baz", strings.NewReader("bazcontent")), test.ShouldBeNil)
+
+	conf := &Config{
+		Root:           filepath.Join(artDir, "root"),
+		Cache:          filepath.Join(artDir, "cache"),
+		SourceStore:    &FileSystemStoreConfig{Path: sourceDir},
+		CacheSizeLimit: 20,
+		Pin:            []string{"/two/"},
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	conf.StoreHash("foo", 10, []string{"one", "two"})
+	conf.StoreHash("bar", 10, []string{"one", "three"})
+	conf.StoreHash("baz", 10, []string{"two"})
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	usage, err := cache.Usage()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, usage, test.ShouldResemble, &CacheUsage{SizeLimit: 20})
+
+	for _, path := range []string{"two", "one/two"} {
+		_, err = cache.Ensure(path, true)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	usage, err = cache.Usage()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, usage, test.ShouldResemble, &CacheUsage{
+		Size:            20,
+		Artifacts:       2,
+		PinnedSize:      10,
+		PinnedArtifacts: 1,
+		SizeLimit:       20,
+		Misses:          2,
+	})
+
+	// foo is the least recently used artifact that is not pinned.
+	_, err = cache.Ensure("one/three", true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, IsNotFoundError(cache.(*cachedStore).cache.Contains("foo")), test.ShouldBeTrue)
+	test.That(t, cache.(*cachedStore).cache.Contains("bar"), test.ShouldBeNil)
+	test.That(t, cache.(*cachedStore).cache.Contains("baz"), test.ShouldBeNil)
+
+	// using bar still leaves it as the least recently used once foo comes back.
+	reader, err := cache.Load("bar")
+	test.That(t, err, test.ShouldBeNil)
+	rd, err := io.ReadAll(reader)
+	test.That(t, reader.Close(), test.ShouldBeNil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "barcontent")
+
+	_, err = cache.Ensure("one/two", true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cache.(*cachedStore).cache.Contains("foo"), test.ShouldBeNil)
+	test.That(t, IsNotFoundError(cache.(*cachedStore).cache.Contains("bar")), test.ShouldBeTrue)
+	test.That(t, cache.(*cachedStore).cache.Contains("baz"), test.ShouldBeNil)
+
+	usage, err = cache.Usage()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, usage, test.ShouldResemble, &CacheUsage{
+		Size:            20,
+		Artifacts:       2,
+		PinnedSize:      10,
+		PinnedArtifacts: 1,
+		SizeLimit:       20,
+		Hits:            1,
+		Misses:          4,
+		Evictions:       2,
+	})
+}
+
+func TestCacheEvictionWithoutSource(t *testing.T) {
+	artDir := t.TempDir()
+	conf := &Config{
+		Root:           filepath.Join(artDir, "root"),
+		Cache:          filepath.Join(artDir, "cache"),
+		CacheSizeLimit: 5,
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	conf.StoreHash("foo", 10, []string{"one"})
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	// the cache is the only place artifacts in the tree are stored without a source.
+	test.That(t, cache.Store("foo", strings.NewReader("foocontent")), test.ShouldBeNil)
+	test.That(t, cache.Store("bar", strings.NewReader("barcontent")), test.ShouldBeNil)
+	test.That(t, cache.Store("baz", strings.NewReader("bazcontent")), test.ShouldBeNil)
+	test.That(t, cache.Contains("foo"), test.ShouldBeNil)
+	test.That(t, IsNotFoundError(cache.Contains("bar")), test.ShouldBeTrue)
+	test.That(t, cache.Contains("baz"), test.ShouldBeNil)
+}
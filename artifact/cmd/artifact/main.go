@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"go.viam.com/utils"
+	"go.viam.com/utils/artifact"
 	"go.viam.com/utils/artifact/tools"
 )
 
@@ -20,7 +21,7 @@ func main() {
 var logger = golog.NewDevelopmentLogger("artifact")
 
 type topArguments struct {
-	Command string   `flag:"0,required,usage=<clean|pull|push|rm|status>"`
+	Command string   `flag:"0,required,usage=<clean|keygen|pull|push|rm|status|usage|verify>"`
 	Extra   []string `flag:",extra"` // for sub-commands
 }
 
@@ -29,16 +30,23 @@ type pullArguments struct {
 	TreePath string `flag:"0,usage=pull a specific path from the tree in"`
 }
 
+type keygenArguments struct {
+	Path string `flag:"0,required,usage=keygen <path to write the signing key to>"`
+}
+
 type removeArguments struct {
 	Path string `flag:"0,required,usage=rm <path>"`
 }
 
 const (
 	commandNameClean  = "clean"
+	commandNameKeygen = "keygen"
 	commandNamePull   = "pull"
 	commandNamePush   = "push"
 	commandNameRemove = "rm"
 	commandNameStatus = "status"
+	commandNameUsage  = "usage"
+	commandNameVerify = "verify"
 )
 
 func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) (err error) {
@@ -52,6 +60,16 @@ func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) (err
 		if err := tools.Clean(); err != nil {
 			logger.Fatal(err)
 		}
+	case commandNameKeygen:
+		var keygenArgsParsed keygenArguments
+		if err := utils.ParseFlags(utils.StringSliceRemove(args, 1), &keygenArgsParsed); err != nil {
+			return err
+		}
+		publicKey, err := artifact.GenerateSigningKey(keygenArgsParsed.Path)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infow("wrote signing key; set public_key in the config to verify the tree with it", "public_key", publicKey)
 	case commandNamePull:
 		var pullArgsParsed pullArguments
 		if err := utils.ParseFlags(utils.StringSliceRemove(args, 1), &pullArgsParsed); err != nil {
@@ -108,8 +126,44 @@ func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) (err
 		if buf.Len() != 0 {
 			logger.Info("\n" + buf.String())
 		}
+	case commandNameUsage:
+		//nolint:contextcheck
+		usage, err := tools.Usage()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infow(
+			"cache usage",
+			"size", usage.Size,
+			"artifacts", usage.Artifacts,
+			"pinned_size", usage.PinnedSize,
+			"pinned_artifacts", usage.PinnedArtifacts,
+			"size_limit", usage.SizeLimit,
+		)
+	case commandNameVerify:
+		report, err := tools.Verify(ctx)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, path := range report.Uncached {
+			logger.Debugw("not cached", "path", path)
+		}
+		for _, corrupted := range report.Corrupted {
+			logger.Errorw(
+				"corrupted",
+				"path", corrupted.Path,
+				"hash", corrupted.Hash,
+				"actual_hash", corrupted.ActualHash,
+				"sha256", corrupted.SHA256,
+				"actual_sha256", corrupted.ActualSHA256,
+			)
+		}
+		logger.Infow("verified cache", "verified", report.Verified, "uncached", len(report.Uncached), "corrupted", len(report.Corrupted))
+		if len(report.Corrupted) != 0 {
+			return errors.Errorf("%d cached artifacts are corrupted", len(report.Corrupted))
+		}
 	default:
-		return errors.New("usage: artifact <clean|pull|push|rm|status>")
+		return errors.New("usage: artifact <clean|keygen|pull|push|rm|status|usage|verify>")
 	}
 	return nil
 }
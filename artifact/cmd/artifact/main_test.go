@@ -137,8 +137,8 @@ func TestMainMain(t *testing.T) {
 	}
 
 	testutils.TestMain(t, mainWithArgs, []testutils.MainTestCase{
-		{"no args", nil, "clean|pull|push|rm|status", nil, nil, nil},
-		{"unknown", []string{"unknown"}, "clean|pull|push|rm|status", nil, nil, nil},
+		{"no args", nil, "clean|keygen|pull|push|rm|status|usage|verify", nil, nil, nil},
+		{"unknown", []string{"unknown"}, "clean|keygen|pull|push|rm|status|usage|verify", nil, nil, nil},
 		{"clean nothing", []string{"clean"}, "", before, nil, teardown},
 		{
 			"clean something",
@@ -393,5 +393,11 @@ func TestMainMain(t *testing.T) {
 				test.That(t, messages[0].Message, test.ShouldContainSubstring, newFilePath)
 			},
 		},
+		{"usage", []string{"usage"}, "", before, nil, func(t *testing.T, logs *observer.ObservedLogs) {
+			defer unsetup()
+			messages := logs.FilterMessageSnippet("cache usage").All()
+			test.That(t, messages, test.ShouldHaveLength, 1)
+			test.That(t, messages[0].ContextMap()["artifacts"], test.ShouldEqual, int64(0))
+		}},
 	})
 }
@@ -1,6 +1,7 @@
 package artifact
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"strings"
 
@@ -48,6 +49,20 @@ type Config struct {
 	// the root.
 	Ignore []string
 
+	// CacheSizeLimit is how many bytes of artifacts the cache may hold before
+	// the least recently used ones are evicted. If unset, the cache is unbounded.
+	CacheSizeLimit int64
+
+	// Pin is a list of tree paths whose artifacts, including all of those below
+	// them, are never evicted from the cache.
+	Pin []string
+
+	// PublicKey, if set, is the Ed25519 public key the tree must be signed with.
+	// The tree is verified against its signature when loaded and artifacts pulled
+	// from source are verified against their SHA-256 digests, which the signature
+	// covers.
+	PublicKey ed25519.PublicKey
+
 	ignoreSet utils.StringSet
 	tree      TreeNodeTree
 	configDir string
@@ -80,6 +95,15 @@ func (c *Config) StoreHash(nodeHash string, nodeSize int, path []string) {
 	c.tree.storeHash(nodeHash, nodeSize, path)
 }
 
+// storeArtifact works like StoreHash but also records the SHA-256 digest of the
+// artifact, which every artifact of a signed tree must have.
+func (c *Config) storeArtifact(nodeHash, digest string, nodeSize int, path []string) {
+	c.tree.storeHash(nodeHash, nodeSize, path)
+	if node, ok := c.tree.lookup(path); ok && !node.IsInternal() {
+		node.external.SHA256 = digest
+	}
+}
+
 // UnmarshalJSON unmarshals the config from JSON data.
 func (c *Config) UnmarshalJSON(data []byte) error {
 	rawConfig := &struct {
@@ -88,6 +112,9 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		SourceStore         *json.RawMessage `json:"source_store"`
 		SourcePullSizeLimit *int             `json:"source_pull_size_limit,omitempty"`
 		Ignore              []string         `json:"ignore"`
+		CacheSizeLimit      int64            `json:"cache_size_limit,omitempty"`
+		Pin                 []string         `json:"pin,omitempty"`
+		PublicKey           string           `json:"public_key,omitempty"`
 	}{}
 	if err := json.Unmarshal(data, rawConfig); err != nil {
 		return err
@@ -99,6 +126,15 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 	} else {
 		c.SourcePullSizeLimit = *rawConfig.SourcePullSizeLimit
 	}
+	c.CacheSizeLimit = rawConfig.CacheSizeLimit
+	c.Pin = rawConfig.Pin
+	if rawConfig.PublicKey != "" {
+		publicKey, err := parsePublicKey(rawConfig.PublicKey)
+		if err != nil {
+			return err
+		}
+		c.PublicKey = publicKey
+	}
 	c.Ignore = rawConfig.Ignore
 	if c.Ignore != nil {
 		c.ignoreSet = utils.NewStringSet(c.Ignore...)
@@ -112,6 +148,10 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 		c.SourceStore = storeConfig
 	}
 
+	if c.CacheSizeLimit < 0 {
+		return errors.New("cache_size_limit must not be negative")
+	}
+
 	return nil
 }
 
@@ -135,6 +175,12 @@ func unmarshalJSONToStoreConfig(data []byte) (StoreConfig, error) {
 			return nil, err
 		}
 		return &config, nil
+	case StoreTypeS3:
+		var config S3StoreConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		return &config, nil
 	default:
 		return nil, errors.Errorf("unknown store type %q", partialConfig.Type)
 	}
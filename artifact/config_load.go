@@ -1,6 +1,7 @@
 package artifact
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -12,8 +13,9 @@ import (
 
 // The artifact file names.
 const (
-	ConfigName = "config.json"
-	TreeName   = "tree.json"
+	ConfigName        = "config.json"
+	TreeName          = "tree.json"
+	TreeSignatureName = "tree.json.sig"
 )
 
 // LoadConfig attempts to automatically load an artifact config
@@ -88,31 +90,43 @@ func LoadConfigFromFile(path string) (*Config, error) {
 	treePath := filepath.Join(pathDir, TreeName)
 	config.configDir = pathDir
 	config.commitFn = func() error {
-		//nolint:gosec
-		newTreeFile, err := os.OpenFile(treePath, os.O_RDWR|os.O_CREATE, 0o600)
+		var treeData bytes.Buffer
+		enc := json.NewEncoder(&treeData)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(config.tree); err != nil {
+			return err
+		}
+		// signing first means a tree that must be signed is left alone if it cannot be.
+		signature, err := config.signTree(treeData.Bytes())
 		if err != nil {
 			return err
 		}
-		defer utils.UncheckedErrorFunc(newTreeFile.Close)
-		if err := newTreeFile.Truncate(0); err != nil {
+
+		// writing atomically means processes sharing the tree never see it partially written.
+		if err := AtomicStore(treePath, &treeData, TreeName); err != nil {
 			return err
 		}
-		enc := json.NewEncoder(newTreeFile)
-		enc.SetIndent("", "  ")
-		return enc.Encode(config.tree)
+		return writeTreeSignature(pathDir, signature)
 	}
 
 	//nolint:gosec
-	treeFile, err := os.Open(treePath)
+	treeData, err := os.ReadFile(treePath)
 	if err == nil {
-		defer utils.UncheckedErrorFunc(treeFile.Close)
-
-		treeDec := json.NewDecoder(treeFile)
+		if config.PublicKey != nil {
+			if err := verifyTreeSignature(pathDir, treeData, config.PublicKey); err != nil {
+				return nil, err
+			}
+		}
 
 		var tree TreeNodeTree
-		if err := treeDec.Decode(&tree); err != nil {
+		if err := json.Unmarshal(treeData, &tree); err != nil {
 			return nil, err
 		}
+		if config.PublicKey != nil {
+			if err := tree.checkSHA256(); err != nil {
+				return nil, errors.Wrap(ErrTreeSignatureInvalid, err.Error())
+			}
+		}
 		config.tree = tree
 	} else {
 		config.tree = TreeNodeTree{}
@@ -88,7 +88,9 @@ func TestConfigUnmarshalJSON(t *testing.T) {
 				"bucket": "mybucket"
 			},
 			"source_pull_size_limit": 5,
-			"ignore": ["one", "two"]
+			"ignore": ["one", "two"],
+			"cache_size_limit": 1024,
+			"pin": ["one/two"]
 		}`), &config)
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, config, test.ShouldResemble, Config{
@@ -99,7 +101,16 @@ func TestConfigUnmarshalJSON(t *testing.T) {
 			},
 			SourcePullSizeLimit: 5,
 			Ignore:              []string{"one", "two"},
+			CacheSizeLimit:      1024,
+			Pin:                 []string{"one/two"},
 			ignoreSet:           utils.NewStringSet("one", "two"),
 		})
 	})
+
+	t.Run("negative cache size limit", func(t *testing.T) {
+		var config Config
+		err := json.Unmarshal([]byte(`{"cache_size_limit": -1}`), &config)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "cache_size_limit")
+	})
 }
@@ -16,8 +16,6 @@ import (
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
-
-	"go.viam.com/utils"
 )
 
 // Path returns the local file system path to the given artifact path. It
@@ -43,6 +41,17 @@ func MustPath(to string) string {
 	return resolved
 }
 
+// Load opens the artifact at the given path for reading, ensuring it exists on the
+// local file system first. The caller must close it.
+func Load(to string) (io.ReadCloser, error) {
+	actualPath, err := Path(to)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:gosec
+	return os.Open(actualPath)
+}
+
 // NewPath returns the would be path to an artifact on the local file system.
 func NewPath(to string) (string, error) {
 	cache, err := GlobalCache()
@@ -63,7 +72,8 @@ func MustNewPath(to string) string {
 
 // emplaceFile ensures that a given artifact identified by a given hash
 // is placed in the given path (creating parent directories along the way).
-func emplaceFile(store Store, hash, path string) error {
+// Any old artifact there is atomically replaced.
+func emplaceFile(store Store, hash, path string) (err error) {
 	if err := store.Contains(hash); err != nil {
 		return err
 	}
@@ -84,9 +94,6 @@ func emplaceFile(store Store, hash, path string) error {
 		if existingHash == hash {
 			return nil
 		}
-		if err := os.Remove(path); err != nil {
-			return errors.Errorf("error removing old artifact at %q", path)
-		}
 	}
 
 	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
@@ -101,28 +108,5 @@ func emplaceFile(store Store, hash, path string) error {
 		err = multierr.Combine(err, hashFile.Close())
 	}()
 
-	tempFile, err := os.CreateTemp(filepath.Dir(path), hash)
-	if err != nil {
-		return err
-	}
-	var successful bool
-	defer func() {
-		if !successful {
-			utils.UncheckedError(os.Remove(tempFile.Name()))
-		}
-	}()
-	if err := os.Chmod(tempFile.Name(), 0o600); err != nil {
-		return err
-	}
-	if _, err = io.Copy(tempFile, hashFile); err != nil {
-		return err
-	}
-	if err := tempFile.Close(); err != nil {
-		return err
-	}
-	if err := os.Rename(tempFile.Name(), path); err != nil {
-		return err
-	}
-	successful = true
-	return nil
+	return AtomicStore(path, hashFile, hash)
 }
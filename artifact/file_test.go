@@ -1,6 +1,7 @@
 package artifact
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -61,6 +62,39 @@ func TestPath(t *testing.T) {
 	test.That(t, resolved, test.ShouldEqual, filepath.Join(filepath.Dir(found), "someotherdir/to/somewhere"))
 }
 
+func TestLoad(t *testing.T) {
+	dir, undo := TestSetupGlobalCache(t)
+	defer undo()
+
+	test.That(t, os.MkdirAll(filepath.Join(dir, DotDir), 0o755), test.ShouldBeNil)
+	confPath := filepath.Join(dir, DotDir, ConfigName)
+	test.That(t, os.WriteFile(confPath, []byte(`{
+	"cache": "somedir",
+	"root": "someotherdir"
+}`), 0o644), test.ShouldBeNil)
+	found, err := searchConfig()
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = Load("to/somewhere")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not found")
+
+	cache, err := GlobalCache()
+	test.That(t, err, test.ShouldBeNil)
+	toSomePath := filepath.Join(filepath.Dir(found), "someotherdir", "to/somewhere")
+	test.That(t, os.MkdirAll(filepath.Dir(toSomePath), 0o755), test.ShouldBeNil)
+	test.That(t, os.WriteFile(toSomePath, []byte("hello world"), 0o644), test.ShouldBeNil)
+	test.That(t, cache.WriteThroughUser(), test.ShouldBeNil)
+	test.That(t, os.Remove(toSomePath), test.ShouldBeNil)
+
+	rc, err := Load("to/somewhere")
+	test.That(t, err, test.ShouldBeNil)
+	rd, err := io.ReadAll(rc)
+	test.That(t, rc.Close(), test.ShouldBeNil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "hello world")
+}
+
 func TestNewPath(t *testing.T) {
 	dir, undo := TestSetupGlobalCache(t)
 	defer undo()
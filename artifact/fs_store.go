@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -51,39 +53,84 @@ func (s *fileSystemStore) Load(hash string) (io.ReadCloser, error) {
 	if err := s.Contains(hash); err != nil {
 		return nil, err
 	}
-	return os.Open(s.pathToHashFile(hash))
+	path := s.pathToHashFile(hash)
+	// the modification time records when an artifact was last used so that the
+	// least recently used ones can be evicted. It does not matter if it cannot be
+	// recorded, such as for a read only store.
+	now := time.Now()
+	utils.UncheckedError(os.Chtimes(path, now, now))
+	return os.Open(path)
 }
 
-// AtomicStore writes reader contents to a temp file and then renames to
-// path, ensuring safer, atomic file writes.
-func AtomicStore(path string, r io.Reader, hash string) (err error) {
-	tempFile, err := os.CreateTemp(filepath.Dir(path), hash)
+// A storedArtifact is an artifact stored in a fileSystemStore.
+type storedArtifact struct {
+	hash     string
+	size     int64
+	lastUsed time.Time
+}
+
+// list returns all artifacts in the store.
+func (s *fileSystemStore) list() ([]storedArtifact, error) {
+	entries, err := os.ReadDir(s.dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	var successful bool
-	defer func() {
-		if !successful {
-			utils.UncheckedError(os.Remove(tempFile.Name()))
+	artifacts := make([]storedArtifact, 0, len(entries))
+	for _, entry := range entries {
+		// the lock file and temporary files start with a dot and are not artifacts.
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
 		}
-	}()
-	if err := os.Chmod(tempFile.Name(), 0o600); err != nil {
-		return err
-	}
-	if _, err = io.Copy(tempFile, r); err != nil {
-		return err
-	}
-	if err := tempFile.Close(); err != nil {
-		return err
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		artifacts = append(artifacts, storedArtifact{
+			hash:     entry.Name(),
+			size:     info.Size(),
+			lastUsed: info.ModTime(),
+		})
 	}
-	if err := os.Rename(tempFile.Name(), path); err != nil {
+	return artifacts, nil
+}
+
+// remove removes the artifact from the store, if it is there.
+func (s *fileSystemStore) remove(hash string) error {
+	if err := os.Remove(s.pathToHashFile(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	successful = true
 	return nil
 }
 
+// tempFilePrefix and tempFileSuffix surround the names of the temporary files artifacts
+// are written to before being renamed into place, which sets them apart from complete
+// ones as well as from any files of the user's that happen to look temporary.
+const (
+	tempFilePrefix = ".tmp-"
+	tempFileSuffix = ".artifact-incomplete"
+)
+
+// AtomicStore writes reader contents to a temp file and then renames to
+// path, ensuring safer, atomic file writes. The file is synced before the
+// rename so that path never refers to partially written contents, even
+// after a crash.
+func AtomicStore(path string, r io.Reader, hash string) error {
+	return utils.WriteFileAtomicFrom(path, r, utils.WriteFileAtomicOptions{
+		Perm:        0o600,
+		Sync:        true,
+		TempPattern: tempFilePrefix + hash + "-*" + tempFileSuffix,
+	})
+}
+
+// isTempFile returns if the file name is that of a temporary file written by
+// AtomicStore.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix) && strings.HasSuffix(name, tempFileSuffix)
+}
+
 func (s *fileSystemStore) Store(hash string, r io.Reader) (err error) {
 	path := s.pathToHashFile(hash)
 
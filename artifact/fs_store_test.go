@@ -3,8 +3,11 @@ package artifact
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/pkg/errors"
 	"go.viam.com/test"
 )
 
@@ -38,3 +41,24 @@ func TestFileSystemStore(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 	testStore(t, store, true)
 }
+
+func TestAtomicStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact")
+	test.That(t, AtomicStore(path, strings.NewReader("hello"), "hash1"), test.ShouldBeNil)
+	test.That(t, AtomicStore(path, strings.NewReader("world"), "hash2"), test.ShouldBeNil)
+	rd, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "world")
+
+	test.That(t, AtomicStore(path, iotest.ErrReader(errors.New("whoops")), "hash3"), test.ShouldBeError, errors.New("whoops"))
+	rd, err = os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "world")
+
+	// nothing is left behind but the artifact.
+	entries, err := os.ReadDir(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entries, test.ShouldHaveLength, 1)
+	test.That(t, entries[0].Name(), test.ShouldEqual, "artifact")
+}
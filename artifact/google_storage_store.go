@@ -2,9 +2,12 @@ package artifact
 
 import (
 	"context"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 
 	"cloud.google.com/go/storage"
@@ -13,8 +16,13 @@ import (
 	"golang.org/x/oauth2"
 	"google.golang.org/api/option"
 	gcphttp "google.golang.org/api/transport/http"
+
+	"go.viam.com/utils"
 )
 
+// googleStorageMaxComposeParts is how many objects Google Storage can compose into one.
+const googleStorageMaxComposeParts = 32
+
 func init() {
 	if path, ok := os.LookupEnv("ARTIFACT_GOOGLE_APPLICATION_CREDENTIALS"); ok && path != "" {
 		setGoogleCredsPath(path)
@@ -76,47 +84,152 @@ func newGoogleStorageStore(config *GoogleStorageStoreConfig) (*googleStorageStor
 		client:        client,
 		bucket:        client.Bucket(config.Bucket),
 		httpTransport: &httpTransport,
+		opts:          config.TransferOptions.withDefaults(),
 	}, nil
 }
 
 // A googleStorageStore is able to load and store artifacts by their hashes and content.
+// Artifacts larger than the part size are downloaded in parallel ranges and uploaded
+// as parallel parts that are then composed into one object. Content is verified
+// against the CRC32C checksum Google Storage keeps for each object.
 type googleStorageStore struct {
 	client        *storage.Client
 	bucket        *storage.BucketHandle
 	httpTransport *http.Transport
+	opts          TransferOptions
 }
 
 func (s *googleStorageStore) Contains(hash string) error {
-	_, err := s.bucket.Object(hash).Attrs(context.Background())
-	if err != nil {
+	_, err := s.attrs(context.Background(), hash)
+	return err
+}
+
+func (s *googleStorageStore) attrs(ctx context.Context, hash string) (*storage.ObjectAttrs, error) {
+	var attrs *storage.ObjectAttrs
+	if err := retry(ctx, s.opts.MaxAttempts, func() error {
+		var err error
+		attrs, err = s.bucket.Object(hash).Attrs(ctx)
 		if errors.Is(err, storage.ErrObjectNotExist) {
 			return NewArtifactNotFoundHashError(hash)
 		}
 		return err
+	}); err != nil {
+		return nil, err
 	}
-	return nil
+	return attrs, nil
 }
 
 func (s *googleStorageStore) Load(hash string) (io.ReadCloser, error) {
-	rc, err := s.bucket.Object(hash).NewReader(context.Background())
+	ctx := context.Background()
+	attrs, err := s.attrs(ctx, hash)
 	if err != nil {
-		if errors.Is(err, storage.ErrObjectNotExist) {
-			return nil, NewArtifactNotFoundHashError(hash)
+		return nil, err
+	}
+	progress := newTransferProgress(s.opts.Progress, hash, TransferDirectionDownload, attrs.Size)
+	f, err := downloadParts(ctx, attrs.Size, s.opts, progress, func(ctx context.Context, w io.Writer, offset, length int64) error {
+		// pinning the generation keeps the parts consistent should the object be replaced.
+		rc, err := s.bucket.Object(hash).Generation(attrs.Generation).NewRangeReader(ctx, offset, length)
+		if err != nil {
+			if errors.Is(err, storage.ErrObjectNotExist) {
+				return NewArtifactNotFoundHashError(hash)
+			}
+			return err
 		}
+		defer utils.UncheckedErrorFunc(rc.Close)
+		_, err = io.Copy(w, rc)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	return rc, nil
+	if err := f.verifyChecksum(hash, attrs.CRC32C); err != nil {
+		return nil, multierr.Combine(err, f.Close())
+	}
+	return f, nil
 }
 
 func (s *googleStorageStore) Store(hash string, r io.Reader) (err error) {
-	if rc, err := s.Load(hash); err == nil {
-		return rc.Close()
+	ctx := context.Background()
+	if err := s.Contains(hash); err == nil {
+		return nil
+	}
+	f, size, crc, err := spoolToTempFile(r)
+	if err != nil {
+		return err
 	}
-	wc := s.bucket.Object(hash).NewWriter(context.Background())
 	defer func() {
-		err = multierr.Combine(err, wc.Close())
+		err = multierr.Combine(err, f.Close())
 	}()
-	if _, err := io.Copy(wc, r); err != nil {
+	progress := newTransferProgress(s.opts.Progress, hash, TransferDirectionUpload, size)
+
+	partSize := s.opts.PartSize
+	if maxParts := int64(googleStorageMaxComposeParts); (size+partSize-1)/partSize > maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	if size <= partSize {
+		return retry(ctx, s.opts.MaxAttempts, func() error {
+			return s.upload(ctx, s.bucket.Object(hash), io.NewSectionReader(f, 0, size), crc, progress)
+		})
+	}
+
+	// the parts are uploaded as their own uniquely named objects, to not collide with
+	// anyone else uploading the same artifact, and then composed into the artifact.
+	partPrefix := fmt.Sprintf("%s.upload-%s.part", hash, strings.ToLower(utils.RandomAlphaString(8)))
+	var parts []*storage.ObjectHandle
+	for offset := int64(0); offset < size; offset += partSize {
+		parts = append(parts, s.bucket.Object(fmt.Sprintf("%s%d", partPrefix, len(parts))))
+	}
+	defer func() {
+		for _, part := range parts {
+			if err := part.Delete(context.Background()); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+				Logger.Debugw("error deleting uploaded part", "object", part.ObjectName(), "error", err)
+			}
+		}
+	}()
+	partOpts := s.opts
+	partOpts.PartSize = partSize
+	if err := transferParts(ctx, size, partOpts, func(ctx context.Context, part int, offset, length int64) error {
+		section := io.NewSectionReader(f, offset, length)
+		hasher := crc32.New(crc32cTable)
+		if _, err := io.Copy(hasher, section); err != nil {
+			return err
+		}
+		return s.upload(ctx, parts[part], io.NewSectionReader(f, offset, length), hasher.Sum32(), progress)
+	}); err != nil {
+		return err
+	}
+	return retry(ctx, s.opts.MaxAttempts, func() error {
+		composer := s.bucket.Object(hash).ComposerFrom(parts...)
+		composer.CRC32C = crc
+		composer.SendCRC32C = true
+		_, err := composer.Run(ctx)
+		return err
+	})
+}
+
+// upload writes the content of r to the object, having Google Storage verify it
+// against the given CRC32C checksum.
+func (s *googleStorageStore) upload(
+	ctx context.Context,
+	obj *storage.ObjectHandle,
+	r io.Reader,
+	crc uint32,
+	progress *transferProgress,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wc := obj.NewWriter(ctx)
+	wc.CRC32C = crc
+	wc.SendCRC32C = true
+	w := &countingWriter{w: wc, progress: progress}
+	if _, err := io.Copy(w, r); err != nil {
+		// canceling the context before closing aborts the upload.
+		cancel()
+		w.undo()
+		return multierr.Combine(err, wc.Close())
+	}
+	if err := wc.Close(); err != nil {
+		w.undo()
 		return err
 	}
 	return nil
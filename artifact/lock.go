@@ -0,0 +1,35 @@
+package artifact
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+// lockFileName is the name of the file in the cache directory that all processes
+// using the cache lock before writing to it.
+const lockFileName = ".lock"
+
+// A fileLock is an advisory lock on a file that is shared across processes.
+type fileLock struct {
+	path string
+}
+
+// lock blocks until the lock is held and returns a function that releases it.
+func (l *fileLock) lock() (func(), error) {
+	//nolint:gosec
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening lock file")
+	}
+	if err := lockFile(f); err != nil {
+		utils.UncheckedError(f.Close())
+		return nil, errors.Wrapf(err, "error locking %q", l.path)
+	}
+	return func() {
+		utils.UncheckedError(unlockFile(f))
+		utils.UncheckedError(f.Close())
+	}, nil
+}
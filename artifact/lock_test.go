@@ -0,0 +1,38 @@
+package artifact
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+	// separate locks on the same file exclude each other as they would across processes.
+	lock1 := &fileLock{path: path}
+	lock2 := &fileLock{path: path}
+
+	unlock1, err := lock1.lock()
+	test.That(t, err, test.ShouldBeNil)
+
+	locked := make(chan func())
+	go func() {
+		unlock2, err := lock2.lock()
+		test.That(t, err, test.ShouldBeNil)
+		locked <- unlock2
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("expected lock to be held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock1()
+	unlock2 := <-locked
+	unlock2()
+
+	_, err = (&fileLock{path: filepath.Join(t.TempDir(), "missing", lockFileName)}).lock()
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,130 @@
+package artifact
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// quarantineDirName is the name of the directory in the cache directory that
+// incomplete writes are moved to by the recovery pass.
+const quarantineDirName = ".quarantine"
+
+// recoverIncomplete quarantines what is left of writes to the cache and root that
+// never completed, such as from a process being killed part way through a
+// download. These are temporary files, as well as cached artifacts whose size
+// does not match the tree, which could have been written before writes were
+// atomic. Every writer holds the lock, so holding it means no temporary file
+// found is still being written. Only the directories of the root that the tree
+// emplaces artifacts into are looked at since the rest belongs to the user.
+func (s *cachedStore) recoverIncomplete() error {
+	unlock, err := s.lock.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	treeSizes := map[string]int{}
+	if err := (&TreeNode{internal: s.config.tree}).walk(nil, func(path []string, external *TreeNodeExternal) error {
+		treeSizes[external.Hash] = external.Size
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.cache.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		if isTempFile(name) {
+			if err := s.quarantineCached(name, "incomplete write"); err != nil {
+				return err
+			}
+			continue
+		}
+		size, ok := treeSizes[name]
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if info.Size() != int64(size) {
+			if err := s.quarantineCached(name, "size does not match tree"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.recoverIncompleteRoot(s.config.tree, s.rootDir)
+}
+
+// recoverIncompleteRoot quarantines the temporary files in the given directory of the
+// root and in those below it that the tree has artifacts in.
+func (s *cachedStore) recoverIncompleteRoot(tree TreeNodeTree, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		if entry.IsDir() {
+			if node, ok := tree[name]; ok && node.IsInternal() {
+				if err := s.recoverIncompleteRoot(node.internal, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() || !isTempFile(name) {
+			continue
+		}
+		if err := s.quarantine(path, "incomplete write"); err != nil {
+			// the root may be on another file system than the cache. The file is left
+			// alone rather than removed in case it is the user's after all.
+			Logger.Warnw("error moving into quarantine; skipping", "path", path, "error", err)
+		}
+	}
+	return nil
+}
+
+// quarantineCached quarantines the named file of the cache, removing it instead if it
+// cannot be moved so that it is never mistaken for a complete artifact.
+func (s *cachedStore) quarantineCached(name, reason string) error {
+	path := filepath.Join(s.cache.dir, name)
+	if err := s.quarantine(path, reason); err != nil {
+		Logger.Debugw("error moving into quarantine; removing instead", "path", path, "error", err)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// quarantine moves the file into the quarantine directory, where it is kept for
+// inspection but no longer mistaken for a complete artifact.
+func (s *cachedStore) quarantine(path, reason string) error {
+	quarantineDir := filepath.Join(s.cache.dir, quarantineDirName)
+	if err := os.MkdirAll(quarantineDir, 0o750); err != nil {
+		return err
+	}
+	dst := filepath.Join(quarantineDir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(path)))
+	Logger.Warnw("quarantining", "path", path, "reason", reason, "destination", dst)
+	return os.Rename(path, dst)
+}
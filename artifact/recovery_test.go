@@ -0,0 +1,80 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestCacheRecovery(t *testing.T) {
+	artDir := t.TempDir()
+	cacheDir := filepath.Join(artDir, "cache")
+	rootDir := filepath.Join(artDir, "root")
+	test.That(t, os.MkdirAll(cacheDir, 0o750), test.ShouldBeNil)
+	test.That(t, os.MkdirAll(filepath.Join(rootDir, "one"), 0o750), test.ShouldBeNil)
+
+	// left behind by a process killed part way through writing.
+	cacheTemp := tempFilePrefix + "foo-123" + tempFileSuffix
+	rootTemp := tempFilePrefix + "foo-456" + tempFileSuffix
+	test.That(t, os.WriteFile(filepath.Join(cacheDir, cacheTemp), []byte("fooc"), 0o600), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(rootDir, "one", rootTemp), []byte("fooco"), 0o600), test.ShouldBeNil)
+	// the user's own, which only look temporary or are where the tree has no artifacts.
+	userFiles := []string{
+		filepath.Join(rootDir, "one", tempFilePrefix+"notes"),
+		filepath.Join(rootDir, "mine", rootTemp),
+	}
+	test.That(t, os.MkdirAll(filepath.Join(rootDir, "mine"), 0o750), test.ShouldBeNil)
+	for _, path := range userFiles {
+		test.That(t, os.WriteFile(path, []byte("mine"), 0o600), test.ShouldBeNil)
+	}
+	// truncated before writes were atomic.
+	test.That(t, os.WriteFile(filepath.Join(cacheDir, "bar"), []byte("barc"), 0o600), test.ShouldBeNil)
+	// complete.
+	bazHash, err := computeHash([]byte("bazcontent"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(cacheDir, bazHash), []byte("bazcontent"), 0o600), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(rootDir, "one", "two"), []byte("bazcontent"), 0o600), test.ShouldBeNil)
+
+	conf := &Config{
+		Root:  rootDir,
+		Cache: cacheDir,
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	conf.StoreHash("bar", 10, []string{"bar"})
+	conf.StoreHash(bazHash, 10, []string{"one", "two"})
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	quarantined, err := os.ReadDir(filepath.Join(cacheDir, quarantineDirName))
+	test.That(t, err, test.ShouldBeNil)
+	var names []string
+	for _, entry := range quarantined {
+		names = append(names, entry.Name()[strings.IndexByte(entry.Name(), '-')+1:])
+	}
+	test.That(t, names, test.ShouldHaveLength, 3)
+	test.That(t, names, test.ShouldContain, cacheTemp)
+	test.That(t, names, test.ShouldContain, rootTemp)
+	test.That(t, names, test.ShouldContain, "bar")
+	for _, path := range userFiles {
+		_, err = os.Stat(path)
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	test.That(t, IsNotFoundError(cache.Contains("bar")), test.ShouldBeTrue)
+	test.That(t, cache.Contains(bazHash), test.ShouldBeNil)
+	_, err = os.Stat(filepath.Join(rootDir, "one", "two"))
+	test.That(t, err, test.ShouldBeNil)
+
+	status, err := cache.Status()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status, test.ShouldResemble, &Status{Unstored: []string{userFiles[0]}})
+}
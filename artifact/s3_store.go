@@ -0,0 +1,189 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// s3ChecksumMetadataKey is the user metadata key the CRC32C checksum of an artifact
+// is stored under, as S3 does not keep a checksum of whole multipart objects.
+const s3ChecksumMetadataKey = "Crc32c"
+
+// newS3Store returns a new s3Store based on the given config.
+func newS3Store(config *S3StoreConfig) (*s3Store, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("bucket required")
+	}
+
+	awsConfig := aws.NewConfig().WithS3ForcePathStyle(config.ForcePathStyle)
+	if config.Region != "" {
+		awsConfig = awsConfig.WithRegion(config.Region)
+	}
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	opts := config.TransferOptions.withDefaults()
+	client := s3.New(sess)
+	return &s3Store{
+		client: client,
+		bucket: config.Bucket,
+		opts:   opts,
+		uploader: s3manager.NewUploaderWithClient(client, func(u *s3manager.Uploader) {
+			u.PartSize = opts.PartSize
+			if u.PartSize < s3manager.MinUploadPartSize {
+				u.PartSize = s3manager.MinUploadPartSize
+			}
+			u.Concurrency = opts.Concurrency
+		}),
+	}, nil
+}
+
+// An s3Store is able to load and store artifacts by their hashes and content in an
+// S3 bucket. Artifacts larger than the part size are downloaded in parallel ranges
+// and uploaded as parallel multipart uploads. Content is verified against a CRC32C
+// checksum stored alongside each artifact.
+type s3Store struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	opts     TransferOptions
+}
+
+// isS3NotFound returns if the error is S3 reporting an object does not exist.
+func isS3NotFound(err error) bool {
+	var reqErr awserr.RequestFailure
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound
+}
+
+func (s *s3Store) head(ctx context.Context, hash string) (*s3.HeadObjectOutput, error) {
+	var out *s3.HeadObjectOutput
+	if err := retry(ctx, s.opts.MaxAttempts, func() error {
+		var err error
+		out, err = s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(hash),
+		})
+		if isS3NotFound(err) {
+			return NewArtifactNotFoundHashError(hash)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *s3Store) Contains(hash string) error {
+	_, err := s.head(context.Background(), hash)
+	return err
+}
+
+func (s *s3Store) Load(hash string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	out, err := s.head(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	size := aws.Int64Value(out.ContentLength)
+	progress := newTransferProgress(s.opts.Progress, hash, TransferDirectionDownload, size)
+	f, err := downloadParts(ctx, size, s.opts, progress, func(ctx context.Context, w io.Writer, offset, length int64) error {
+		if length == 0 {
+			return nil
+		}
+		part, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(hash),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+			// matching the entity tag keeps the parts consistent should the object be replaced.
+			IfMatch: out.ETag,
+		})
+		if err != nil {
+			if isS3NotFound(err) {
+				return NewArtifactNotFoundHashError(hash)
+			}
+			return err
+		}
+		defer utils.UncheckedErrorFunc(part.Body.Close)
+		_, err = io.Copy(w, part.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if encoded := aws.StringValue(out.Metadata[s3ChecksumMetadataKey]); encoded != "" {
+		expected, err := strconv.ParseUint(encoded, 16, 32)
+		if err != nil {
+			return nil, multierr.Combine(errors.Wrapf(err, "invalid checksum for artifact %q", hash), f.Close())
+		}
+		if err := f.verifyChecksum(hash, uint32(expected)); err != nil {
+			return nil, multierr.Combine(err, f.Close())
+		}
+	}
+	return f, nil
+}
+
+func (s *s3Store) Store(hash string, r io.Reader) (err error) {
+	ctx := context.Background()
+	if err := s.Contains(hash); err == nil {
+		return nil
+	}
+	f, size, crc, err := spoolToTempFile(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = multierr.Combine(err, f.Close())
+	}()
+	progress := newTransferProgress(s.opts.Progress, hash, TransferDirectionUpload, size)
+	if err := retry(ctx, s.opts.MaxAttempts, func() error {
+		counter := &countingWriter{w: io.Discard, progress: progress}
+		if _, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(hash),
+			Body:   io.TeeReader(io.NewSectionReader(f, 0, size), counter),
+			Metadata: map[string]*string{
+				s3ChecksumMetadataKey: aws.String(fmt.Sprintf("%08x", crc)),
+			},
+		}); err != nil {
+			counter.undo()
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	out, err := s.head(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if stored := aws.Int64Value(out.ContentLength); stored != size {
+		return errors.Errorf("stored artifact %q has %d bytes but expected %d", hash, stored, size)
+	}
+	return nil
+}
+
+func (s *s3Store) Close() error {
+	return nil
+}
@@ -0,0 +1,423 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestNewS3Store(t *testing.T) {
+	_, err := NewStore(&S3StoreConfig{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "bucket required")
+
+	store, err := NewStore(&S3StoreConfig{Bucket: "somebucket", Region: "us-east-1", TransferOptions: TransferOptions{PartSize: 1}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, store.(*s3Store).opts.Concurrency, test.ShouldEqual, defaultTransferConcurrency)
+	test.That(t, store.Close(), test.ShouldBeNil)
+}
+
+func TestS3Store(t *testing.T) {
+	oldBackoff := transferInitialBackoff
+	transferInitialBackoff = time.Millisecond
+	defer func() {
+		transferInitialBackoff = oldBackoff
+	}()
+	t.Setenv("AWS_ACCESS_KEY_ID", "someid")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "somesecret")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	newStore := func(t *testing.T, opts TransferOptions) (*s3Store, *fakeS3) {
+		t.Helper()
+		fake := newFakeS3("somebucket")
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+		store, err := NewStore(&S3StoreConfig{
+			Bucket:          "somebucket",
+			Region:          "us-east-1",
+			Endpoint:        server.URL,
+			ForcePathStyle:  true,
+			TransferOptions: opts,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		t.Cleanup(func() {
+			test.That(t, store.Close(), test.ShouldBeNil)
+		})
+		return store.(*s3Store), fake
+	}
+	load := func(t *testing.T, store Store, hash string) []byte {
+		t.Helper()
+		reader, err := store.Load(hash)
+		test.That(t, err, test.ShouldBeNil)
+		rd, err := io.ReadAll(reader)
+		test.That(t, reader.Close(), test.ShouldBeNil)
+		test.That(t, err, test.ShouldBeNil)
+		return rd
+	}
+
+	t.Run("store", func(t *testing.T) {
+		store, _ := newStore(t, TransferOptions{})
+		testStore(t, store, false)
+	})
+
+	t.Run("ranged parallel download", func(t *testing.T) {
+		store, fake := newStore(t, TransferOptions{PartSize: 1024, Concurrency: 3})
+		content := randomS3Content(10*1024 + 10)
+		hash, err := computeHash(content)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+
+		test.That(t, load(t, store, hash), test.ShouldResemble, content)
+		ranges := fake.getRanges()
+		test.That(t, ranges, test.ShouldHaveLength, 11)
+		test.That(t, ranges[0], test.ShouldEqual, "bytes=0-1023")
+		test.That(t, ranges[10], test.ShouldEqual, "bytes=10240-10249")
+	})
+
+	t.Run("multipart upload", func(t *testing.T) {
+		store, fake := newStore(t, TransferOptions{PartSize: s3manager.MinUploadPartSize})
+		content := randomS3Content(2*int(s3manager.MinUploadPartSize) + 10)
+		hash, err := computeHash(content)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+
+		test.That(t, fake.operationCount("CreateMultipartUpload"), test.ShouldEqual, 1)
+		test.That(t, fake.operationCount("UploadPart"), test.ShouldEqual, 3)
+		test.That(t, fake.operationCount("CompleteMultipartUpload"), test.ShouldEqual, 1)
+		test.That(t, fake.operationCount("PutObject"), test.ShouldEqual, 0)
+		test.That(t, load(t, store, hash), test.ShouldResemble, content)
+
+		// storing what is already there uploads nothing.
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+		test.That(t, fake.operationCount("CreateMultipartUpload"), test.ShouldEqual, 1)
+	})
+
+	t.Run("crc32c verification", func(t *testing.T) {
+		store, fake := newStore(t, TransferOptions{})
+		content := []byte("mycoolcontent")
+		hash, err := computeHash(content)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+		test.That(t, fake.object(hash).metadata.Get("X-Amz-Meta-Crc32c"), test.ShouldEqual,
+			fmt.Sprintf("%08x", crc32.Checksum(content, crc32cTable)))
+
+		fake.corrupt(hash)
+		_, err = store.Load(hash)
+		test.That(t, errors.Is(err, errChecksumMismatch), test.ShouldBeTrue)
+
+		fake.setMetadata(hash, "X-Amz-Meta-Crc32c", "notachecksum")
+		_, err = store.Load(hash)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid checksum")
+
+		// artifacts stored without a checksum are not verified.
+		fake.setMetadata(hash, "X-Amz-Meta-Crc32c", "")
+		test.That(t, load(t, store, hash), test.ShouldNotResemble, content)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		store, fake := newStore(t, TransferOptions{})
+		content := []byte("mycoolcontent")
+		hash, err := computeHash(content)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, IsNotFoundError(store.Contains(hash)), test.ShouldBeTrue)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+
+		// an object deleted between finding it and downloading it is not found either.
+		fake.deleteOnGet(hash)
+		_, err = store.Load(hash)
+		test.That(t, IsNotFoundError(err), test.ShouldBeTrue)
+		test.That(t, err, test.ShouldResemble, &NotFoundError{hash: &hash})
+		test.That(t, IsNotFoundError(store.Contains(hash)), test.ShouldBeTrue)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		content := []byte("mycoolcontent")
+		hash, err := computeHash(content)
+		test.That(t, err, test.ShouldBeNil)
+
+		// the client retries failures itself before the store retries the transfer, so
+		// fail more requests than it retries.
+		store, fake := newStore(t, TransferOptions{MaxAttempts: 1})
+		fake.fail(http.MethodPut, 5)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldNotBeNil)
+
+		store, fake = newStore(t, TransferOptions{MaxAttempts: 2})
+		fake.fail(http.MethodPut, 5)
+		test.That(t, store.Store(hash, bytes.NewReader(content)), test.ShouldBeNil)
+		fake.fail(http.MethodGet, 5)
+		test.That(t, load(t, store, hash), test.ShouldResemble, content)
+	})
+}
+
+func randomS3Content(size int) []byte {
+	content := make([]byte, size)
+	//nolint:gosec
+	rand.New(rand.NewSource(1)).Read(content)
+	return content
+}
+
+// fakeS3 serves the objects of a single bucket in memory as S3 does with path-style
+// addressing, enough for an s3Store to load and store artifacts.
+type fakeS3 struct {
+	bucket string
+
+	mu         sync.Mutex
+	objects    map[string]*fakeS3Object
+	uploads    map[string]*fakeS3Upload
+	ranges     []string
+	operations map[string]int
+	// failures are how many of the next requests of each method fail.
+	failures     map[string]int
+	deleteOnGets map[string]bool
+}
+
+type fakeS3Object struct {
+	data     []byte
+	metadata http.Header
+}
+
+type fakeS3Upload struct {
+	key      string
+	metadata http.Header
+	parts    map[int][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:       bucket,
+		objects:      map[string]*fakeS3Object{},
+		uploads:      map[string]*fakeS3Upload{},
+		operations:   map[string]int{},
+		failures:     map[string]int{},
+		deleteOnGets: map[string]bool{},
+	}
+}
+
+func (fs *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		fs.writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/"+fs.bucket+"/")
+	query := r.URL.Query()
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.failures[r.Method] > 0 {
+		fs.failures[r.Method]--
+		fs.writeError(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		obj, ok := fs.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fs.writeObjectHeaders(w, obj)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet:
+		fs.operations["GetObject"]++
+		obj, ok := fs.objects[key]
+		if ok && fs.deleteOnGets[key] {
+			delete(fs.objects, key)
+			ok = false
+		}
+		if !ok {
+			fs.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != fakeS3ETag(obj.data) {
+			fs.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		rangeHeader := r.Header.Get("Range")
+		fs.ranges = append(fs.ranges, rangeHeader)
+		var start, end int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil || end >= len(obj.data) || start > end {
+			fs.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		fs.writeObjectHeaders(w, obj)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data)))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		//nolint:errcheck
+		w.Write(obj.data[start : end+1])
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		fs.operations["UploadPart"]++
+		upload, ok := fs.uploads[query.Get("uploadId")]
+		partNumber, err := strconv.Atoi(query.Get("partNumber"))
+		if !ok || err != nil {
+			fs.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		upload.parts[partNumber] = body
+		w.Header().Set("ETag", fakeS3ETag(body))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut:
+		fs.operations["PutObject"]++
+		fs.objects[key] = &fakeS3Object{data: body, metadata: fakeS3Metadata(r.Header)}
+		w.Header().Set("ETag", fakeS3ETag(body))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fs.operations["CreateMultipartUpload"]++
+		uploadID := strconv.Itoa(len(fs.uploads) + 1)
+		fs.uploads[uploadID] = &fakeS3Upload{key: key, metadata: fakeS3Metadata(r.Header), parts: map[int][]byte{}}
+		fs.writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: fs.bucket, Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		fs.operations["CompleteMultipartUpload"]++
+		upload, ok := fs.uploads[query.Get("uploadId")]
+		if !ok {
+			fs.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		delete(fs.uploads, query.Get("uploadId"))
+		partNumbers := make([]int, 0, len(upload.parts))
+		for partNumber := range upload.parts {
+			partNumbers = append(partNumbers, partNumber)
+		}
+		sort.Ints(partNumbers)
+		var data []byte
+		for _, partNumber := range partNumbers {
+			data = append(data, upload.parts[partNumber]...)
+		}
+		fs.objects[upload.key] = &fakeS3Object{data: data, metadata: upload.metadata}
+		fs.writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: fs.bucket, Key: upload.key, ETag: fakeS3ETag(data)})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		fs.operations["AbortMultipartUpload"]++
+		delete(fs.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fs.writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (fs *fakeS3) writeObjectHeaders(w http.ResponseWriter, obj *fakeS3Object) {
+	for name, values := range obj.metadata {
+		w.Header()[name] = values
+	}
+	w.Header().Set("ETag", fakeS3ETag(obj.data))
+}
+
+func (fs *fakeS3) writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	//nolint:errcheck
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (fs *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	//nolint:errcheck
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
+
+func (fs *fakeS3) getRanges() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ranges := append([]string(nil), fs.ranges...)
+	sort.Slice(ranges, func(i, j int) bool {
+		var start1, start2 int
+		//nolint:errcheck
+		fmt.Sscanf(ranges[i], "bytes=%d-", &start1)
+		//nolint:errcheck
+		fmt.Sscanf(ranges[j], "bytes=%d-", &start2)
+		return start1 < start2
+	})
+	return ranges
+}
+
+func (fs *fakeS3) operationCount(operation string) int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.operations[operation]
+}
+
+func (fs *fakeS3) object(key string) *fakeS3Object {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.objects[key]
+}
+
+// corrupt changes the content of the object without updating its checksum.
+func (fs *fakeS3) corrupt(key string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.objects[key].data[0]++
+}
+
+func (fs *fakeS3) setMetadata(key, name, value string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if value == "" {
+		fs.objects[key].metadata.Del(name)
+		return
+	}
+	fs.objects[key].metadata.Set(name, value)
+}
+
+// deleteOnGet deletes the object once it is next downloaded.
+func (fs *fakeS3) deleteOnGet(key string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.deleteOnGets[key] = true
+}
+
+// fail fails the next requests of the method.
+func (fs *fakeS3) fail(method string, times int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.failures[method] = times
+}
+
+func fakeS3ETag(data []byte) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sha256.Sum256(data)))
+}
+
+// fakeS3Metadata returns the user metadata among the headers.
+func fakeS3Metadata(header http.Header) http.Header {
+	metadata := http.Header{}
+	for name, values := range header {
+		if strings.HasPrefix(name, "X-Amz-Meta-") {
+			metadata[name] = values
+		}
+	}
+	return metadata
+}
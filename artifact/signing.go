@@ -0,0 +1,150 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+func init() {
+	if path, ok := os.LookupEnv("ARTIFACT_SIGNING_KEY_FILE"); ok && path != "" {
+		setSigningKeyPath(path)
+	}
+}
+
+var (
+	_signingKeyMu   sync.Mutex
+	_signingKeyPath string
+)
+
+func getSigningKeyPath() string {
+	_signingKeyMu.Lock()
+	defer _signingKeyMu.Unlock()
+	return _signingKeyPath
+}
+
+func setSigningKeyPath(path string) func() {
+	_signingKeyMu.Lock()
+	prevSigningKeyPath := _signingKeyPath
+	_signingKeyPath = path
+	_signingKeyMu.Unlock()
+	return func() {
+		setSigningKeyPath(prevSigningKeyPath)
+	}
+}
+
+// ErrTreeSignatureInvalid is used when the tree does not match its signature.
+var ErrTreeSignatureInvalid = errors.New("tree signature is invalid")
+
+// GenerateSigningKey writes a new Ed25519 private key for signing the tree to the
+// given path. It returns the matching public key, base64 encoded, to set as the
+// public key in the config of those loading the tree.
+func GenerateSigningKey(path string) (string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(privateKey.Seed()) + "\n"
+	if err := utils.WriteFileAtomic(path, []byte(encoded), utils.WriteFileAtomicOptions{Perm: 0o600, Sync: true}); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// LoadSigningKey reads a private key written by GenerateSigningKey.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	//nolint:gosec
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding signing key %q", path)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.Errorf("expected signing key %q to be %d bytes but got %d", path, ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// parsePublicKey parses a base64 encoded Ed25519 public key.
+func parsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding public_key")
+	}
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("expected public_key to be %d bytes but got %d", ed25519.PublicKeySize, len(decoded))
+	}
+	return ed25519.PublicKey(decoded), nil
+}
+
+// signTree returns the detached signature of the tree data to write alongside it,
+// or nil if there is no signing key. It errors if there is no signing key but the
+// config requires the tree to be signed.
+func (c *Config) signTree(treeData []byte) ([]byte, error) {
+	keyPath := getSigningKeyPath()
+	if keyPath == "" {
+		if c.PublicKey != nil {
+			return nil, errors.New("tree must be signed but no signing key is set in ARTIFACT_SIGNING_KEY_FILE")
+		}
+		return nil, nil
+	}
+	key, err := LoadSigningKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey, ok := key.Public().(ed25519.PublicKey); c.PublicKey != nil && (!ok || !publicKey.Equal(c.PublicKey)) {
+		return nil, errors.Errorf("signing key %q does not match the configured public_key", keyPath)
+	}
+	// the hashes artifacts are stored by are not collision resistant, so the signature
+	// only vouches for their content through their digests.
+	if err := c.tree.checkSHA256(); err != nil {
+		return nil, errors.Wrap(err, "cannot sign tree; push again to record missing digests")
+	}
+	signature := ed25519.Sign(key, treeData)
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n"), nil
+}
+
+// writeTreeSignature writes the signature of the tree alongside it. Without a
+// signature, any one there is removed since it would no longer match the tree.
+func writeTreeSignature(configDir string, signature []byte) error {
+	signaturePath := filepath.Join(configDir, TreeSignatureName)
+	if signature == nil {
+		if err := os.Remove(signaturePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return AtomicStore(signaturePath, bytes.NewReader(signature), TreeSignatureName)
+}
+
+// verifyTreeSignature verifies the tree data against the signature alongside it.
+func verifyTreeSignature(configDir string, treeData []byte, publicKey ed25519.PublicKey) error {
+	//nolint:gosec
+	encoded, err := os.ReadFile(filepath.Join(configDir, TreeSignatureName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.Wrapf(ErrTreeSignatureInvalid, "%q is missing", TreeSignatureName)
+		}
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return errors.Wrapf(ErrTreeSignatureInvalid, "error decoding %q: %s", TreeSignatureName, err)
+	}
+	if !ed25519.Verify(publicKey, treeData, signature) {
+		return ErrTreeSignatureInvalid
+	}
+	return nil
+}
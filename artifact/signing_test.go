@@ -0,0 +1,164 @@
+package artifact
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key")
+	publicKey, err := GenerateSigningKey(keyPath)
+	test.That(t, err, test.ShouldBeNil)
+
+	key, err := LoadSigningKey(keyPath)
+	test.That(t, err, test.ShouldBeNil)
+	parsed, err := parsePublicKey(publicKey)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, parsed.Equal(key.Public()), test.ShouldBeTrue)
+
+	_, err = LoadSigningKey(filepath.Join(dir, "unknown"))
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	test.That(t, os.WriteFile(keyPath, []byte("Zm9v\n"), 0o600), test.ShouldBeNil)
+	_, err = LoadSigningKey(keyPath)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "32 bytes")
+
+	_, err = parsePublicKey("not base64!")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "public_key")
+}
+
+func TestSignedTree(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key")
+	publicKey, err := GenerateSigningKey(keyPath)
+	test.That(t, err, test.ShouldBeNil)
+
+	confPath := filepath.Join(dir, ConfigName)
+	treePath := filepath.Join(dir, TreeName)
+	signaturePath := filepath.Join(dir, TreeSignatureName)
+	test.That(t, os.WriteFile(confPath, []byte(fmt.Sprintf(`{"public_key": %q}`, publicKey)), 0o644), test.ShouldBeNil)
+
+	// no tree at all is fine.
+	config, err := LoadConfigFromFile(confPath)
+	test.That(t, err, test.ShouldBeNil)
+	config.StoreHash("hash1", 5, []string{"one", "two"})
+
+	// the tree is left alone if it cannot be signed.
+	err = config.commitFn()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "ARTIFACT_SIGNING_KEY_FILE")
+	_, err = os.Stat(treePath)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	undo := setSigningKeyPath(keyPath)
+	defer undo()
+	// nor can it be signed while an artifact has no digest for the signature to cover.
+	err = config.commitFn()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no sha256 digest")
+	_, err = os.Stat(treePath)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	digest := computeSHA256([]byte("hello"))
+	config.storeArtifact("hash1", digest, 5, []string{"one", "two"})
+	test.That(t, config.commitFn(), test.ShouldBeNil)
+	_, err = os.Stat(signaturePath)
+	test.That(t, err, test.ShouldBeNil)
+
+	config, err = LoadConfigFromFile(confPath)
+	test.That(t, err, test.ShouldBeNil)
+	node, err := config.Lookup("one/two")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, node.external.Hash, test.ShouldEqual, "hash1")
+	test.That(t, node.external.SHA256, test.ShouldEqual, digest)
+
+	// a key that does not match cannot sign.
+	otherKeyPath := filepath.Join(dir, "other_key")
+	_, err = GenerateSigningKey(otherKeyPath)
+	test.That(t, err, test.ShouldBeNil)
+	setSigningKeyPath(otherKeyPath)
+	err = config.commitFn()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "does not match")
+	setSigningKeyPath(keyPath)
+
+	treeData, err := os.ReadFile(treePath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, os.WriteFile(treePath, []byte(`{"one": {"two": {"size": 5, "hash": "evil"}}}`), 0o600), test.ShouldBeNil)
+	_, err = LoadConfigFromFile(confPath)
+	test.That(t, errors.Is(err, ErrTreeSignatureInvalid), test.ShouldBeTrue)
+
+	test.That(t, os.WriteFile(treePath, treeData, 0o600), test.ShouldBeNil)
+	test.That(t, os.Remove(signaturePath), test.ShouldBeNil)
+	_, err = LoadConfigFromFile(confPath)
+	test.That(t, errors.Is(err, ErrTreeSignatureInvalid), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "missing")
+
+	// a tree signed without digests does not vouch for the content of its artifacts.
+	undigestedData := []byte(`{"one": {"two": {"size": 5, "hash": "hash1"}}}`)
+	key, err := LoadSigningKey(keyPath)
+	test.That(t, err, test.ShouldBeNil)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, undigestedData))
+	test.That(t, os.WriteFile(treePath, undigestedData, 0o600), test.ShouldBeNil)
+	test.That(t, os.WriteFile(signaturePath, []byte(signature+"\n"), 0o600), test.ShouldBeNil)
+	_, err = LoadConfigFromFile(confPath)
+	test.That(t, errors.Is(err, ErrTreeSignatureInvalid), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no sha256 digest")
+
+	// without a public key, a stale signature is removed rather than left not matching.
+	test.That(t, os.WriteFile(confPath, []byte(`{}`), 0o644), test.ShouldBeNil)
+	test.That(t, os.WriteFile(signaturePath, []byte("stale\n"), 0o600), test.ShouldBeNil)
+	setSigningKeyPath("")
+	config, err = LoadConfigFromFile(confPath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, config.commitFn(), test.ShouldBeNil)
+	_, err = os.Stat(signaturePath)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
+
+func TestWriteThroughRecordsSHA256(t *testing.T) {
+	artDir := t.TempDir()
+	conf := &Config{
+		Root:  filepath.Join(artDir, "root"),
+		Cache: filepath.Join(artDir, "cache"),
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	// artifacts already in the tree get their digests recorded without being stored again.
+	oldHash, err := computeHash([]byte("old"))
+	test.That(t, err, test.ShouldBeNil)
+	conf.StoreHash(oldHash, 3, []string{"old"})
+	test.That(t, os.WriteFile(cache.NewPath("old"), []byte("old"), 0o644), test.ShouldBeNil)
+	test.That(t, os.WriteFile(cache.NewPath("new"), []byte("new"), 0o644), test.ShouldBeNil)
+
+	status, err := cache.Status()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status, test.ShouldResemble, &Status{Unstored: []string{cache.NewPath("new")}})
+
+	test.That(t, cache.WriteThroughUser(), test.ShouldBeNil)
+	for _, content := range []string{"old", "new"} {
+		node, err := conf.Lookup(content)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, node.external.SHA256, test.ShouldEqual, computeSHA256([]byte(content)))
+	}
+	test.That(t, IsNotFoundError(cache.Contains(oldHash)), test.ShouldBeTrue)
+	test.That(t, conf.tree.checkSHA256(), test.ShouldBeNil)
+}
@@ -23,6 +23,7 @@ type StoreType string
 const (
 	StoreTypeFileSystem    = StoreType("fs")
 	StoreTypeGoogleStorage = StoreType("google_storage")
+	StoreTypeS3            = StoreType("s3")
 )
 
 // NewStore returns a new store based on the given config. It errors
@@ -33,6 +34,8 @@ func NewStore(config StoreConfig) (Store, error) {
 		return newFileSystemStore(v)
 	case *GoogleStorageStoreConfig:
 		return newGoogleStorageStore(v)
+	case *S3StoreConfig:
+		return newS3Store(v)
 	default:
 		return nil, errors.Errorf("unknown store type %q", config.Type())
 	}
@@ -21,9 +21,32 @@ func (c *FileSystemStoreConfig) Type() StoreType {
 // Store that has its credentials automatically looked up.
 type GoogleStorageStoreConfig struct {
 	Bucket string `json:"bucket"`
+	TransferOptions
 }
 
 // Type returns that this is a Google storage Store.
 func (c *GoogleStorageStoreConfig) Type() StoreType {
 	return StoreTypeGoogleStorage
 }
+
+// S3StoreConfig is for configuring an S3 (or S3 compatible) based
+// Store that has its credentials automatically looked up from the
+// environment or shared AWS configuration.
+type S3StoreConfig struct {
+	Bucket string `json:"bucket"`
+	// Region is the region of the bucket. If unset, it is looked up
+	// from the environment or shared AWS configuration.
+	Region string `json:"region,omitempty"`
+	// Endpoint, if set, is the URL of an S3 compatible service to use
+	// instead of AWS.
+	Endpoint string `json:"endpoint,omitempty"`
+	// ForcePathStyle addresses the bucket as part of the path rather
+	// than the host name, which many S3 compatible services require.
+	ForcePathStyle bool `json:"force_path_style,omitempty"`
+	TransferOptions
+}
+
+// Type returns that this is an S3 Store.
+func (c *S3StoreConfig) Type() StoreType {
+	return StoreTypeS3
+}
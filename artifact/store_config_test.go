@@ -34,3 +34,35 @@ func TestGoogleStorageStoreConfig(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, fromJSON.Bucket, test.ShouldEqual, "someBucket")
 }
+
+func TestS3StoreConfig(t *testing.T) {
+	var empty S3StoreConfig
+	test.That(t, empty.Type(), test.ShouldEqual, StoreTypeS3)
+
+	var fromJSON S3StoreConfig
+	err := json.Unmarshal([]byte(`{"bucket": 1}`), &fromJSON)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "cannot")
+
+	err = json.Unmarshal([]byte(`{
+		"bucket": "someBucket",
+		"region": "us-east-1",
+		"endpoint": "http://localhost:9000",
+		"force_path_style": true,
+		"part_size": 1024,
+		"concurrency": 2,
+		"max_attempts": 5
+	}`), &fromJSON)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromJSON, test.ShouldResemble, S3StoreConfig{
+		Bucket:         "someBucket",
+		Region:         "us-east-1",
+		Endpoint:       "http://localhost:9000",
+		ForcePathStyle: true,
+		TransferOptions: TransferOptions{
+			PartSize:    1024,
+			Concurrency: 2,
+			MaxAttempts: 5,
+		},
+	})
+}
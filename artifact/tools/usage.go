@@ -0,0 +1,15 @@
+package tools
+
+import (
+	"go.viam.com/utils/artifact"
+)
+
+// Usage reports how much of the cache is in use.
+func Usage() (*artifact.CacheUsage, error) {
+	cache, err := artifact.GlobalCache()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.Usage()
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/artifact"
+)
+
+func TestUsage(t *testing.T) {
+	dir, undo := artifact.TestSetupGlobalCache(t)
+	defer undo()
+	test.That(t, os.MkdirAll(filepath.Join(dir, artifact.DotDir), 0o755), test.ShouldBeNil)
+	confPath := filepath.Join(dir, artifact.DotDir, artifact.ConfigName)
+	test.That(t, os.WriteFile(confPath, []byte(`{"cache_size_limit": 100, "pin": ["some"]}`), 0o644), test.ShouldBeNil)
+
+	usage, err := Usage()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, usage, test.ShouldResemble, &artifact.CacheUsage{SizeLimit: 100})
+
+	filePath := artifact.MustNewPath("some/file")
+	test.That(t, os.MkdirAll(filepath.Dir(filePath), 0o755), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filePath, []byte("hello"), 0o644), test.ShouldBeNil)
+	test.That(t, Push(), test.ShouldBeNil)
+
+	usage, err = Usage()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, usage, test.ShouldResemble, &artifact.CacheUsage{
+		Size:            5,
+		Artifacts:       1,
+		PinnedSize:      5,
+		PinnedArtifacts: 1,
+		SizeLimit:       100,
+	})
+}
@@ -0,0 +1,18 @@
+package tools
+
+import (
+	"context"
+
+	"go.viam.com/utils/artifact"
+)
+
+// Verify re-hashes the cached content of the artifacts in the tree and reports
+// any that do not match it.
+func Verify(ctx context.Context) (*artifact.VerifyReport, error) {
+	cache, err := artifact.GlobalCache()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.Verify(ctx)
+}
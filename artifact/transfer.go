@@ -0,0 +1,330 @@
+package artifact
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// Defaults for moving artifacts to and from remote stores.
+const (
+	defaultTransferPartSize    = 16 << 20
+	defaultTransferConcurrency = 4
+	defaultTransferMaxAttempts = 3
+	transferMaxBackoff         = 5 * time.Second
+)
+
+// transferInitialBackoff is how long to wait before retrying a failed transfer for
+// the first time. The wait doubles with each attempt up to transferMaxBackoff.
+var transferInitialBackoff = 250 * time.Millisecond
+
+// crc32cTable is the table of the CRC32C (Castagnoli) checksums artifacts are
+// verified with.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errChecksumMismatch is used when the content of an artifact does not match its
+// expected checksum.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// TransferOptions tune how a remote Store moves artifacts.
+type TransferOptions struct {
+	// PartSize is the size of the ranges artifacts are split into to be transferred
+	// in parallel. Artifacts no larger than it are transferred in one piece. If unset,
+	// it defaults to 16 MiB.
+	PartSize int64 `json:"part_size,omitempty"`
+
+	// Concurrency is how many parts of an artifact are transferred at once. If unset,
+	// it defaults to 4.
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// MaxAttempts is how many times each part is tried before the transfer fails. If
+	// unset, it defaults to 3.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+
+	// Progress, if set, is called as artifacts are transferred.
+	Progress TransferProgressFunc `json:"-"`
+}
+
+func (opts TransferOptions) withDefaults() TransferOptions {
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaultTransferPartSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultTransferConcurrency
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultTransferMaxAttempts
+	}
+	return opts
+}
+
+// A TransferDirection is the direction an artifact is moving in.
+type TransferDirection string
+
+// The set of transfer directions.
+const (
+	TransferDirectionUpload   = TransferDirection("upload")
+	TransferDirectionDownload = TransferDirection("download")
+)
+
+// TransferProgress describes how far along the transfer of an artifact is.
+type TransferProgress struct {
+	Hash      string
+	Direction TransferDirection
+	// Transferred is how many bytes have been transferred so far. It can go down
+	// when a part is retried.
+	Transferred int64
+	// Total is the size of the artifact.
+	Total int64
+}
+
+// A TransferProgressFunc is called with the progress of a transfer each time more
+// of it is done. Calls for a single transfer never overlap.
+type TransferProgressFunc func(progress TransferProgress)
+
+// transferProgress tracks the progress of a single transfer.
+type transferProgress struct {
+	mu       sync.Mutex
+	fn       TransferProgressFunc
+	progress TransferProgress
+}
+
+func newTransferProgress(fn TransferProgressFunc, hash string, direction TransferDirection, total int64) *transferProgress {
+	return &transferProgress{
+		fn:       fn,
+		progress: TransferProgress{Hash: hash, Direction: direction, Total: total},
+	}
+}
+
+// add records that n more bytes were transferred, or that n bytes need to be
+// transferred again if it is negative.
+func (p *transferProgress) add(n int64) {
+	if p == nil || p.fn == nil || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Transferred += n
+	p.fn(p.progress)
+}
+
+// countingWriter counts what is written through it towards the progress of a transfer.
+type countingWriter struct {
+	w        io.Writer
+	progress *transferProgress
+	written  int64
+}
+
+func (cw *countingWriter) Write(data []byte) (int, error) {
+	n, err := cw.w.Write(data)
+	cw.written += int64(n)
+	cw.progress.add(int64(n))
+	return n, err
+}
+
+// undo takes back the progress counted so far so that the same data can be written
+// again.
+func (cw *countingWriter) undo() {
+	cw.progress.add(-cw.written)
+	cw.written = 0
+}
+
+// retry calls fn until it succeeds or fails maxAttempts times, backing off
+// exponentially in between. Artifacts not being found and the context being done
+// are not retried.
+func retry(ctx context.Context, maxAttempts int, fn func() error) error {
+	backoff := transferInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || IsNotFoundError(err) || ctx.Err() != nil || attempt >= maxAttempts {
+			return err
+		}
+		Logger.Debugw("retrying transfer", "attempt", attempt, "error", err)
+		if !utils.SelectContextOrWaitUntil(ctx, time.Now().Add(backoff)) {
+			return multierr.Combine(err, ctx.Err())
+		}
+		backoff *= 2
+		if backoff > transferMaxBackoff {
+			backoff = transferMaxBackoff
+		}
+	}
+}
+
+// transferParts splits size bytes into parts of the configured size and calls
+// transfer on each, with as many at once as the configured concurrency and retrying
+// each on failure. The first part to fail for good cancels the rest.
+func transferParts(
+	ctx context.Context,
+	size int64,
+	opts TransferOptions,
+	transfer func(ctx context.Context, part int, offset, length int64) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type partRange struct {
+		part           int
+		offset, length int64
+	}
+	parts := make(chan partRange)
+	var (
+		errMu          sync.Mutex
+		transferErr    error
+		activeTransfer sync.WaitGroup
+	)
+	for i := 0; i < opts.Concurrency; i++ {
+		activeTransfer.Add(1)
+		utils.ManagedGo(func() {
+			for next := range parts {
+				if err := retry(ctx, opts.MaxAttempts, func() error {
+					return transfer(ctx, next.part, next.offset, next.length)
+				}); err != nil {
+					errMu.Lock()
+					if transferErr == nil {
+						transferErr = err
+					}
+					errMu.Unlock()
+					cancel()
+				}
+			}
+		}, activeTransfer.Done)
+	}
+
+	part := 0
+	for offset := int64(0); offset < size || part == 0; offset += opts.PartSize {
+		length := opts.PartSize
+		if size-offset < length {
+			length = size - offset
+		}
+		select {
+		case parts <- partRange{part, offset, length}:
+		case <-ctx.Done():
+		}
+		part++
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(parts)
+	activeTransfer.Wait()
+	if transferErr != nil {
+		return transferErr
+	}
+	return ctx.Err()
+}
+
+// downloadParts downloads an artifact of the given size into a temporary file by
+// calling download for each of its parts in parallel. The file is removed once the
+// returned ReadCloser is closed.
+func downloadParts(
+	ctx context.Context,
+	size int64,
+	opts TransferOptions,
+	progress *transferProgress,
+	download func(ctx context.Context, w io.Writer, offset, length int64) error,
+) (_ *tempFile, err error) {
+	f, err := newTempFile("artifact-download")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Combine(err, f.Close())
+		}
+	}()
+	if err := f.Truncate(size); err != nil {
+		return nil, err
+	}
+	if err := transferParts(ctx, size, opts, func(ctx context.Context, part int, offset, length int64) error {
+		w := &countingWriter{w: io.NewOffsetWriter(f, offset), progress: progress}
+		if err := download(ctx, w, offset, length); err != nil {
+			w.undo()
+			return err
+		}
+		if w.written != length {
+			w.undo()
+			return errors.Wrapf(io.ErrUnexpectedEOF, "expected %d bytes at offset %d but got %d", length, offset, w.written)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// A tempFile is a temporary file that is removed once closed.
+type tempFile struct {
+	*os.File
+}
+
+func newTempFile(pattern string) (*tempFile, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &tempFile{f}, nil
+}
+
+// Close closes and removes the file.
+func (f *tempFile) Close() error {
+	return multierr.Combine(f.File.Close(), os.Remove(f.Name()))
+}
+
+// checksum returns the CRC32C checksum of the whole file and rewinds it.
+func (f *tempFile) checksum() (uint32, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	hasher := crc32.New(crc32cTable)
+	if _, err := io.Copy(hasher, f); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return hasher.Sum32(), nil
+}
+
+// verifyChecksum errors if the CRC32C checksum of the file is not the expected one.
+func (f *tempFile) verifyChecksum(hash string, expected uint32) error {
+	actual, err := f.checksum()
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return errors.Wrapf(errChecksumMismatch, "artifact %q has checksum %08x but expected %08x", hash, actual, expected)
+	}
+	return nil
+}
+
+// spoolToTempFile copies r to a temporary file so that it can be transferred in
+// parallel parts and retried. It returns the file, rewound, along with its size and
+// CRC32C checksum.
+func spoolToTempFile(r io.Reader) (_ *tempFile, size int64, crc uint32, err error) {
+	f, err := newTempFile("artifact-upload")
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Combine(err, f.Close())
+		}
+	}()
+	hasher := crc32.New(crc32cTable)
+	size, err = io.Copy(io.MultiWriter(f, hasher), r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, 0, err
+	}
+	return f, size, hasher.Sum32(), nil
+}
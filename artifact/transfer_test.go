@@ -0,0 +1,137 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestRetry(t *testing.T) {
+	oldBackoff := transferInitialBackoff
+	transferInitialBackoff = time.Millisecond
+	defer func() {
+		transferInitialBackoff = oldBackoff
+	}()
+
+	var attempts int
+	err := retry(context.Background(), 3, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, attempts, test.ShouldEqual, 3)
+
+	attempts = 0
+	err = retry(context.Background(), 3, func() error {
+		attempts++
+		return errors.New("never works")
+	})
+	test.That(t, err, test.ShouldBeError, errors.New("never works"))
+	test.That(t, attempts, test.ShouldEqual, 3)
+
+	attempts = 0
+	err = retry(context.Background(), 3, func() error {
+		attempts++
+		return NewArtifactNotFoundHashError("foo")
+	})
+	test.That(t, IsNotFoundError(err), test.ShouldBeTrue)
+	test.That(t, attempts, test.ShouldEqual, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err = retry(ctx, 3, func() error {
+		attempts++
+		return errors.New("canceled")
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, attempts, test.ShouldEqual, 1)
+}
+
+func TestDownloadParts(t *testing.T) {
+	oldBackoff := transferInitialBackoff
+	transferInitialBackoff = time.Millisecond
+	defer func() {
+		transferInitialBackoff = oldBackoff
+	}()
+
+	content := []byte(strings.Repeat("0123456789", 10))
+	opts := TransferOptions{PartSize: 8, Concurrency: 3}.withDefaults()
+
+	var (
+		mu       sync.Mutex
+		offsets  = map[int64]int{}
+		progress []TransferProgress
+	)
+	tracker := newTransferProgress(func(p TransferProgress) {
+		progress = append(progress, p)
+	}, "hash1", TransferDirectionDownload, int64(len(content)))
+
+	f, err := downloadParts(context.Background(), int64(len(content)), opts, tracker,
+		func(ctx context.Context, w io.Writer, offset, length int64) error {
+			mu.Lock()
+			offsets[offset]++
+			tries := offsets[offset]
+			mu.Unlock()
+			if offset == 16 && tries == 1 {
+				// fail part way through to make sure the part is retried and recounted.
+				if _, err := w.Write(content[offset : offset+2]); err != nil {
+					return err
+				}
+				return errors.New("connection reset")
+			}
+			_, err := w.Write(content[offset : offset+length])
+			return err
+		})
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, f.Close(), test.ShouldBeNil)
+	}()
+
+	test.That(t, offsets, test.ShouldHaveLength, 13)
+	test.That(t, offsets[16], test.ShouldEqual, 2)
+	rd, err := io.ReadAll(f)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rd, test.ShouldResemble, content)
+	test.That(t, f.verifyChecksum("hash1", crc32.Checksum(content, crc32cTable)), test.ShouldBeNil)
+	err = f.verifyChecksum("hash1", 1)
+	test.That(t, errors.Is(err, errChecksumMismatch), test.ShouldBeTrue)
+
+	last := progress[len(progress)-1]
+	test.That(t, last, test.ShouldResemble, TransferProgress{
+		Hash:        "hash1",
+		Direction:   TransferDirectionDownload,
+		Transferred: int64(len(content)),
+		Total:       int64(len(content)),
+	})
+
+	_, err = downloadParts(context.Background(), int64(len(content)), opts, nil,
+		func(ctx context.Context, w io.Writer, offset, length int64) error {
+			_, err := w.Write(content[offset : offset+length-1])
+			return err
+		})
+	test.That(t, errors.Is(err, io.ErrUnexpectedEOF), test.ShouldBeTrue)
+}
+
+func TestSpoolToTempFile(t *testing.T) {
+	content := []byte("mycoolcontent")
+	f, size, crc, err := spoolToTempFile(bytes.NewReader(content))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, size, test.ShouldEqual, int64(len(content)))
+	test.That(t, crc, test.ShouldEqual, crc32.Checksum(content, crc32cTable))
+	rd, err := io.ReadAll(f)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rd, test.ShouldResemble, content)
+	test.That(t, f.Close(), test.ShouldBeNil)
+}
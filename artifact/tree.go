@@ -1,6 +1,14 @@
 package artifact
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
 
 // A TreeNode represents a node in an artifact tree. The tree
 // is a hierarchy of artifacts that mimics a file system.
@@ -19,6 +27,10 @@ func (tn *TreeNode) IsInternal() bool {
 type TreeNodeExternal struct {
 	Hash string `json:"hash"`
 	Size int    `json:"size"`
+	// SHA256 is the hex encoded SHA-256 digest of the artifact. Unlike Hash, it is
+	// collision resistant, so it is what a signed tree vouches for the content of its
+	// artifacts with.
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 // A TreeNodeTree is an internal node with mappings to other
@@ -64,6 +76,17 @@ func (tnt TreeNodeTree) storeHash(nodeHash string, nodeSize int, path []string)
 	node.internal.storeHash(nodeHash, nodeSize, path[1:])
 }
 
+// checkSHA256 errors if any artifact in the tree has no SHA-256 digest.
+func (tnt TreeNodeTree) checkSHA256() error {
+	root := &TreeNode{internal: tnt}
+	return root.walk(nil, func(path []string, external *TreeNodeExternal) error {
+		if external.SHA256 == "" {
+			return errors.Errorf("artifact %q has no sha256 digest", strings.Join(path, "/"))
+		}
+		return nil
+	})
+}
+
 // removePath removes nodes that fall into the given path.
 func (tnt TreeNodeTree) removePath(path []string) {
 	if tnt == nil || len(path) == 0 {
@@ -80,6 +103,42 @@ func (tnt TreeNodeTree) removePath(path []string) {
 	node.internal.removePath(path[1:])
 }
 
+// collectHashes adds the hashes of all external nodes at or below this node to the set.
+func (tn *TreeNode) collectHashes(hashes utils.StringSet) {
+	if !tn.IsInternal() {
+		if tn.external != nil {
+			hashes.Add(tn.external.Hash)
+		}
+		return
+	}
+	for _, child := range tn.internal {
+		child.collectHashes(hashes)
+	}
+}
+
+// walk calls visit with the path and external node of every artifact at or below this
+// node, in path order.
+func (tn *TreeNode) walk(path []string, visit func(path []string, external *TreeNodeExternal) error) error {
+	if !tn.IsInternal() {
+		if tn.external == nil {
+			return nil
+		}
+		return visit(path, tn.external)
+	}
+	names := make([]string, 0, len(tn.internal))
+	for name := range tn.internal {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := append(append([]string{}, path...), name)
+		if err := tn.internal[name].walk(childPath, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UnmarshalJSON unmarshals JSON into a specific tree node
 // that may be internal or external.
 func (tn *TreeNode) UnmarshalJSON(data []byte) error {
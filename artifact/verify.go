@@ -0,0 +1,107 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+
+	"go.viam.com/utils"
+)
+
+// VerifyReport describes how the cached artifacts compare to the tree.
+type VerifyReport struct {
+	// Verified is how many artifacts in the tree are cached with the content they
+	// should have.
+	Verified int
+	// Uncached lists the paths of the artifacts in the tree that are not cached and
+	// so could not be verified.
+	Uncached []string
+	// Corrupted lists the cached artifacts whose content does not match the tree.
+	Corrupted []CorruptedArtifact
+}
+
+// A CorruptedArtifact is a cached artifact whose content does not match the tree.
+// SHA256 and ActualSHA256 are only set for artifacts the tree has a digest for.
+type CorruptedArtifact struct {
+	Path         string
+	Hash         string
+	ActualHash   string
+	SHA256       string
+	ActualSHA256 string
+	Size         int
+	ActualSize   int
+}
+
+// verify re-hashes the cached content of every artifact in the tree, checking its
+// SHA-256 digest too when the tree has one. Artifacts found under more than one path
+// are only hashed once.
+func (s *cachedStore) verify(ctx context.Context) (*VerifyReport, error) {
+	type hashResult struct {
+		actualHash   string
+		actualSHA256 string
+		actualSize   int
+		cached       bool
+	}
+	results := map[string]hashResult{}
+	var report VerifyReport
+	root := &TreeNode{internal: s.config.tree}
+	if err := root.walk(nil, func(path []string, external *TreeNodeExternal) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, ok := results[external.Hash]
+		if !ok {
+			//nolint:gosec
+			f, err := os.Open(s.cache.pathToHashFile(external.Hash))
+			switch {
+			case err == nil:
+				digester := sha256.New()
+				actualHash, actualSize, err := computeHashReader(io.TeeReader(f, digester))
+				utils.UncheckedError(f.Close())
+				if err != nil {
+					return err
+				}
+				result = hashResult{
+					actualHash:   actualHash,
+					actualSHA256: hex.EncodeToString(digester.Sum(nil)),
+					actualSize:   int(actualSize),
+					cached:       true,
+				}
+			case os.IsNotExist(err):
+			default:
+				return err
+			}
+			results[external.Hash] = result
+		}
+
+		treePath := strings.Join(path, "/")
+		switch {
+		case !result.cached:
+			report.Uncached = append(report.Uncached, treePath)
+		case result.actualHash != external.Hash || result.actualSize != external.Size ||
+			(external.SHA256 != "" && result.actualSHA256 != external.SHA256):
+			Logger.Warnw("cached artifact is corrupted", "path", treePath, "hash", external.Hash, "actual_hash", result.actualHash)
+			corrupted := CorruptedArtifact{
+				Path:       treePath,
+				Hash:       external.Hash,
+				ActualHash: result.actualHash,
+				Size:       external.Size,
+				ActualSize: result.actualSize,
+			}
+			if external.SHA256 != "" {
+				corrupted.SHA256 = external.SHA256
+				corrupted.ActualSHA256 = result.actualSHA256
+			}
+			report.Corrupted = append(report.Corrupted, corrupted)
+		default:
+			report.Verified++
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,138 @@
+package artifact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestCacheVerify(t *testing.T) {
+	artDir := t.TempDir()
+	conf := &Config{
+		Root:  filepath.Join(artDir, "root"),
+		Cache: filepath.Join(artDir, "cache"),
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	for path, content := range map[string]string{
+		"one/two":   "content1",
+		"one/three": "content2",
+		"four":      "content1",
+	} {
+		hash, err := computeHash([]byte(content))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cache.Store(hash, strings.NewReader(content)), test.ShouldBeNil)
+		conf.StoreHash(hash, len(content), strings.Split(path, "/"))
+	}
+	conf.StoreHash("unknown", 5, []string{"five"})
+
+	report, err := cache.Verify(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, report, test.ShouldResemble, &VerifyReport{
+		Verified: 3,
+		Uncached: []string{"five"},
+	})
+
+	hash1, err := computeHash([]byte("content1"))
+	test.That(t, err, test.ShouldBeNil)
+	corruptedHash, err := computeHash([]byte("bitrot"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(artDir, "cache", hash1), []byte("bitrot"), 0o600), test.ShouldBeNil)
+
+	report, err = cache.Verify(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, report, test.ShouldResemble, &VerifyReport{
+		Verified: 1,
+		Uncached: []string{"five"},
+		Corrupted: []CorruptedArtifact{
+			{Path: "four", Hash: hash1, ActualHash: corruptedHash, Size: 8, ActualSize: 6},
+			{Path: "one/two", Hash: hash1, ActualHash: corruptedHash, Size: 8, ActualSize: 6},
+		},
+	})
+
+	// a digest that does not match is corruption even if the hash does.
+	hash2, err := computeHash([]byte("content2"))
+	test.That(t, err, test.ShouldBeNil)
+	forgedDigest := computeSHA256([]byte("forged"))
+	conf.storeArtifact(hash2, forgedDigest, 8, []string{"one", "three"})
+	report, err = cache.Verify(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, report.Corrupted, test.ShouldContain, CorruptedArtifact{
+		Path:         "one/three",
+		Hash:         hash2,
+		ActualHash:   hash2,
+		SHA256:       forgedDigest,
+		ActualSHA256: computeSHA256([]byte("content2")),
+		Size:         8,
+		ActualSize:   8,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cache.Verify(ctx)
+	test.That(t, err, test.ShouldBeError, context.Canceled)
+}
+
+func TestEnsureVerifiesSignedTree(t *testing.T) {
+	artDir := t.TempDir()
+	sourceDir := filepath.Join(artDir, "source")
+	source, err := newFileSystemStore(&FileSystemStoreConfig{Path: sourceDir})
+	test.That(t, err, test.ShouldBeNil)
+	goodHash, err := computeHash([]byte("good"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, source.Store(goodHash, strings.NewReader("good")), test.ShouldBeNil)
+	test.That(t, source.Store("badhash", strings.NewReader("bad")), test.ShouldBeNil)
+
+	publicKey, err := GenerateSigningKey(filepath.Join(artDir, "key"))
+	test.That(t, err, test.ShouldBeNil)
+	parsed, err := parsePublicKey(publicKey)
+	test.That(t, err, test.ShouldBeNil)
+	conf := &Config{
+		Root:        filepath.Join(artDir, "root"),
+		Cache:       filepath.Join(artDir, "cache"),
+		SourceStore: &FileSystemStoreConfig{Path: sourceDir},
+		PublicKey:   parsed,
+		commitFn: func() error {
+			return nil
+		},
+		tree: TreeNodeTree{},
+	}
+	conf.storeArtifact(goodHash, computeSHA256([]byte("good")), 4, []string{"good"})
+	conf.storeArtifact("badhash", computeSHA256([]byte("bad")), 3, []string{"bad"})
+	// content whose hash collides with that of good but not its digest.
+	conf.storeArtifact(goodHash, computeSHA256([]byte("forged")), 4, []string{"forged"})
+	cache, err := NewCache(conf)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, cache.Close(), test.ShouldBeNil)
+	}()
+
+	_, err = cache.Ensure("forged", true)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "sha256")
+	test.That(t, IsNotFoundError(cache.(*cachedStore).cache.Contains(goodHash)), test.ShouldBeTrue)
+	_, err = os.Stat(cache.NewPath("forged"))
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	_, err = cache.Ensure("good", true)
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = cache.Ensure("bad", true)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "badhash")
+	test.That(t, IsNotFoundError(cache.(*cachedStore).cache.Contains("badhash")), test.ShouldBeTrue)
+	_, err = os.Stat(cache.NewPath("bad"))
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
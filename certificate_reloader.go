@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// certificateReloadDelay is how long to wait after a certificate file changes before
+// reloading so that a certificate and its key written one after the other are reloaded
+// together.
+const certificateReloadDelay = 100 * time.Millisecond
+
+// CertificateFiles are the paths of a PEM encoded X509 certificate, which may be a chain,
+// and its private key.
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// A CertificateReloader provides certificates loaded from files to TLS servers and reloads
+// them whenever the files change or, on platforms that have it, the process receives
+// SIGHUP, so that certificates can be rotated without restarting. If a reload fails, the
+// certificates already loaded continue to be used. When there is more than one certificate,
+// the one served is chosen based on the client hello, such as by its server name (SNI).
+type CertificateReloader struct {
+	logger golog.Logger
+	files  []CertificateFiles
+
+	mu    sync.RWMutex
+	certs []*tls.Certificate
+
+	watcher                 *fsnotify.Watcher
+	signals                 chan os.Signal
+	cancel                  func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+// NewCertificateReloader loads the given certificates and watches them for changes until
+// closed.
+func NewCertificateReloader(logger golog.Logger, files ...CertificateFiles) (*CertificateReloader, error) {
+	if len(files) == 0 {
+		return nil, errors.New("expected at least one certificate")
+	}
+	for _, pair := range files {
+		if pair.CertFile == "" || pair.KeyFile == "" {
+			return nil, ErrInsufficientX509KeyPair
+		}
+	}
+	reloader := &CertificateReloader{logger: logger, files: files}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// directories are watched since certificates are often rotated by replacing their files,
+	// such as by renaming them into place or swapping symlinks.
+	watchedDirs := map[string]bool{}
+	for _, pair := range files {
+		for _, file := range []string{pair.CertFile, pair.KeyFile} {
+			dir := filepath.Dir(file)
+			if watchedDirs[dir] {
+				continue
+			}
+			watchedDirs[dir] = true
+			if err := watcher.Add(dir); err != nil {
+				return nil, multierr.Combine(err, watcher.Close())
+			}
+		}
+	}
+	reloader.watcher = watcher
+
+	reloader.signals = make(chan os.Signal, 1)
+	notifyReloadSignals(reloader.signals)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloader.cancel = cancel
+	reloader.activeBackgroundWorkers.Add(1)
+	ManagedGo(func() {
+		reloader.watch(ctx)
+	}, reloader.activeBackgroundWorkers.Done)
+	return reloader, nil
+}
+
+func (r *CertificateReloader) watch(ctx context.Context) {
+	reloadTimer := time.NewTimer(0)
+	if !reloadTimer.Stop() {
+		<-reloadTimer.C
+	}
+	defer reloadTimer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.signals:
+			r.logger.Info("reloading certificates on signal")
+			r.reloadOrWarn()
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			reloadTimer.Reset(certificateReloadDelay)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Warnw("error watching certificates", "error", err)
+		case <-reloadTimer.C:
+			r.logger.Info("reloading certificates on change")
+			r.reloadOrWarn()
+		}
+	}
+}
+
+func (r *CertificateReloader) reloadOrWarn() {
+	if err := r.Reload(); err != nil {
+		r.logger.Warnw("failed to reload certificates; continuing to use the ones already loaded", "error", err)
+	}
+}
+
+// Reload loads the certificates from their files again, replacing the ones in use if all
+// of them load successfully.
+func (r *CertificateReloader) Reload() error {
+	certs := make([]*tls.Certificate, 0, len(r.files))
+	for _, pair := range r.files {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load certificate %q", pair.CertFile)
+		}
+		// the leaf is used to choose a certificate for each client hello.
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return errors.Wrapf(err, "failed to parse certificate %q", pair.CertFile)
+		}
+		certs = append(certs, &cert)
+	}
+	r.mu.Lock()
+	r.certs = certs
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the certificate to serve for the client hello. It is meant to be
+// used as a tls.Config's GetCertificate. If no certificate supports the client hello, the
+// first one is returned.
+func (r *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.certs) > 1 && hello != nil && hello.ServerName != "" {
+		for _, cert := range r.certs {
+			if hello.SupportsCertificate(cert) == nil {
+				return cert, nil
+			}
+		}
+	}
+	return r.certs[0], nil
+}
+
+// TLSConfig returns a TLS config for servers that serves the reloaded certificates.
+func (r *CertificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Close stops watching the certificates for changes. The certificates last loaded continue
+// to be served.
+func (r *CertificateReloader) Close() error {
+	signal.Stop(r.signals)
+	r.cancel()
+	err := r.watcher.Close()
+	r.activeBackgroundWorkers.Wait()
+	return err
+}
@@ -0,0 +1,94 @@
+package utils_test
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils"
+	"go.viam.com/utils/testutils"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	dir := t.TempDir()
+
+	// writeCert generates a certificate for the name and writes it to the given files.
+	writeCert := func(t *testing.T, name string, files utils.CertificateFiles) {
+		t.Helper()
+		_, certFile, keyFile, _, err := testutils.GenerateSelfSignedCertificate(name)
+		test.That(t, err, test.ShouldBeNil)
+		for src, dst := range map[string]string{certFile: files.CertFile, keyFile: files.KeyFile} {
+			data, err := os.ReadFile(src)
+			test.That(t, err, test.ShouldBeNil)
+			// write to a temporary file and rename it into place so the pair is never torn.
+			tmp := dst + ".tmp"
+			test.That(t, os.WriteFile(tmp, data, 0o600), test.ShouldBeNil)
+			test.That(t, os.Rename(tmp, dst), test.ShouldBeNil)
+		}
+	}
+	servedName := func(tb testing.TB, reloader *utils.CertificateReloader, serverName string) string {
+		tb.Helper()
+		cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{
+			ServerName:        serverName,
+			SignatureSchemes:  []tls.SignatureScheme{tls.PSSWithSHA256, tls.PKCS1WithSHA256},
+			SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+			CipherSuites:      []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			SupportedPoints:   []uint8{0},
+		})
+		test.That(tb, err, test.ShouldBeNil)
+		return cert.Leaf.Subject.CommonName
+	}
+
+	_, err := utils.NewCertificateReloader(logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = utils.NewCertificateReloader(logger, utils.CertificateFiles{CertFile: "cert.pem"})
+	test.That(t, err, test.ShouldEqual, utils.ErrInsufficientX509KeyPair)
+	_, err = utils.NewCertificateReloader(logger, utils.CertificateFiles{
+		CertFile: filepath.Join(dir, "missing.pem"),
+		KeyFile:  filepath.Join(dir, "missing.key"),
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	first := utils.CertificateFiles{CertFile: filepath.Join(dir, "first.pem"), KeyFile: filepath.Join(dir, "first.key")}
+	second := utils.CertificateFiles{CertFile: filepath.Join(dir, "second.pem"), KeyFile: filepath.Join(dir, "second.key")}
+	writeCert(t, "first.example", first)
+	writeCert(t, "second.example", second)
+
+	reloader, err := utils.NewCertificateReloader(logger, first, second)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, reloader.Close(), test.ShouldBeNil)
+	}()
+
+	t.Run("sni", func(t *testing.T) {
+		test.That(t, servedName(t, reloader, "first.example"), test.ShouldEqual, "first.example")
+		test.That(t, servedName(t, reloader, "second.example"), test.ShouldEqual, "second.example")
+		// unknown names get the first certificate
+		test.That(t, servedName(t, reloader, "third.example"), test.ShouldEqual, "first.example")
+		test.That(t, servedName(t, reloader, ""), test.ShouldEqual, "first.example")
+
+		tlsConfig := reloader.TLSConfig()
+		test.That(t, tlsConfig.MinVersion, test.ShouldEqual, tls.VersionTLS12)
+		test.That(t, tlsConfig.GetCertificate, test.ShouldNotBeNil)
+	})
+
+	t.Run("reload on change", func(t *testing.T) {
+		writeCert(t, "renamed.example", second)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, servedName(tb, reloader, "renamed.example"), test.ShouldEqual, "renamed.example")
+		})
+		test.That(t, servedName(t, reloader, "first.example"), test.ShouldEqual, "first.example")
+	})
+
+	t.Run("failed reload keeps certificates", func(t *testing.T) {
+		test.That(t, os.WriteFile(first.CertFile, []byte("not a certificate"), 0o600), test.ShouldBeNil)
+		test.That(t, reloader.Reload(), test.ShouldNotBeNil)
+		test.That(t, servedName(t, reloader, "first.example"), test.ShouldEqual, "first.example")
+	})
+}
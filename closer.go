@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// A CloserComponent is a part of a service to shut down with a Closer.
+type CloserComponent struct {
+	// Name identifies the component in errors.
+	Name string
+	// Timeout, if set, is the most the component is given to close. A component that
+	// does not return in time is left to finish on its own while the rest close.
+	Timeout time.Duration
+	// Close shuts the component down. Its context is done once the timeout passes or the
+	// context given to Closer.Close is done.
+	Close func(ctx context.Context) error
+}
+
+// A Closer shuts down the components of a service in order. Like deferred calls, the
+// stages components were added in are closed in reverse, so that components are closed
+// before those they were built on. The components of a stage close in parallel.
+type Closer struct {
+	mu     sync.Mutex
+	stages [][]CloserComponent
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewCloser returns a Closer without any components.
+func NewCloser() *Closer {
+	return &Closer{}
+}
+
+// Add adds a stage of a single component.
+func (c *Closer) Add(name string, timeout time.Duration, closeFn func(ctx context.Context) error) {
+	c.AddParallel(CloserComponent{Name: name, Timeout: timeout, Close: closeFn})
+}
+
+// AddParallel adds a stage of components that close at the same time.
+func (c *Closer) AddParallel(components ...CloserComponent) {
+	if len(components) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stages = append(c.stages, components)
+}
+
+// Close closes every stage, the last added first, and returns the errors of all
+// components combined. Each stage waits for the one before it to finish even if some of
+// its components fail. Only the first call closes anything; later ones wait for it and
+// return the same result.
+func (c *Closer) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		stages := c.stages
+		c.stages = nil
+		c.mu.Unlock()
+
+		for i := len(stages) - 1; i >= 0; i-- {
+			c.closeErr = multierr.Combine(c.closeErr, closeStage(ctx, stages[i]))
+		}
+	})
+	return c.closeErr
+}
+
+// closeStage closes the components of a stage in parallel.
+func closeStage(ctx context.Context, components []CloserComponent) error {
+	errs := make([]error, len(components))
+	var wg sync.WaitGroup
+	for i, component := range components {
+		i, component := i, component
+		wg.Add(1)
+		PanicCapturingGo(func() {
+			defer wg.Done()
+			errs[i] = closeComponent(ctx, component)
+		})
+	}
+	wg.Wait()
+	return multierr.Combine(errs...)
+}
+
+// closeComponent closes the component, giving up on it once its timeout passes.
+func closeComponent(ctx context.Context, component CloserComponent) error {
+	if component.Timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, component.Timeout)
+		defer cancel()
+	}
+	// buffered so that a component returning after being given up on does not block.
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				errCh <- errors.Errorf("panic while closing: %v", err)
+			}
+		}()
+		errCh <- component.Close(ctx)
+	}()
+	select {
+	case err := <-errCh:
+		return errors.Wrapf(err, "error closing %s", component.Name)
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "%s did not close in time", component.Name)
+	}
+}
+
+// CloseWithoutContext adapts a close function that does not take a context, such as the
+// Close method of an io.Closer, for use with a Closer.
+func CloseWithoutContext(closeFn func() error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return closeFn()
+	}
+}
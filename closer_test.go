@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestCloser(t *testing.T) {
+	t.Run("closes stages in reverse", func(t *testing.T) {
+		var mu sync.Mutex
+		var closed []string
+		closeFn := func(name string) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				closed = append(closed, name)
+				return nil
+			}
+		}
+		closer := NewCloser()
+		closer.Add("first", 0, closeFn("first"))
+		closer.AddParallel(
+			CloserComponent{Name: "second", Close: closeFn("second")},
+			CloserComponent{Name: "third", Close: closeFn("third")},
+		)
+		closer.Add("fourth", time.Second, closeFn("fourth"))
+		test.That(t, closer.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, closed, test.ShouldHaveLength, 4)
+		test.That(t, closed[0], test.ShouldEqual, "fourth")
+		test.That(t, closed[1:3], test.ShouldContain, "second")
+		test.That(t, closed[1:3], test.ShouldContain, "third")
+		test.That(t, closed[3], test.ShouldEqual, "first")
+
+		// closing again closes nothing
+		test.That(t, closer.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, closed, test.ShouldHaveLength, 4)
+	})
+
+	t.Run("combines errors", func(t *testing.T) {
+		errClose := errors.New("whoops")
+		release := make(chan struct{})
+		defer close(release)
+		var lastClosed bool
+		closer := NewCloser()
+		closer.Add("last", 0, func(ctx context.Context) error {
+			lastClosed = true
+			return nil
+		})
+		closer.Add("stuck", time.Millisecond, func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+		closer.Add("failing", 0, CloseWithoutContext(func() error {
+			return errClose
+		}))
+		closer.Add("panicking", 0, func(ctx context.Context) error {
+			panic("oops")
+		})
+
+		err := closer.Close(context.Background())
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, errors.Is(err, errClose), test.ShouldBeTrue)
+		test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "error closing failing")
+		test.That(t, err.Error(), test.ShouldContainSubstring, "stuck did not close in time")
+		test.That(t, err.Error(), test.ShouldContainSubstring, "panic while closing: oops")
+		test.That(t, lastClosed, test.ShouldBeTrue)
+
+		// the same result is returned again
+		test.That(t, closer.Close(context.Background()), test.ShouldEqual, err)
+	})
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A CoalescedFunc is a function whose calls come in bursts and are coalesced into fewer
+// runs of it, as returned by Debounce and Throttle. The function runs in the background
+// and never more than once at a time.
+type CoalescedFunc struct {
+	wait time.Duration
+	fn   func()
+	// leading is whether a call is run right away when no run happened within wait, as
+	// opposed to only once calls stop coming for wait.
+	leading bool
+
+	runMu sync.Mutex // runMu keeps runs of fn from overlapping.
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	gen     uint64 // gen tells the latest timer apart from stopped ones that already fired.
+	waiting bool
+	pending bool
+	closed  bool
+	running sync.WaitGroup
+}
+
+// Debounce returns fn coalesced so that it runs once calls to it stop coming for the
+// given wait, no matter how many calls there were before that.
+func Debounce(wait time.Duration, fn func()) *CoalescedFunc {
+	return &CoalescedFunc{wait: wait, fn: fn}
+}
+
+// Throttle returns fn coalesced so that it runs at most once every interval. A call when
+// fn has not run within the interval runs it right away; any calls after it within the
+// interval run it once more at the end of the interval.
+func Throttle(interval time.Duration, fn func()) *CoalescedFunc {
+	return &CoalescedFunc{wait: interval, fn: fn, leading: true}
+}
+
+// Call asks for the function to run. Calls after Stop or Close are ignored.
+func (cf *CoalescedFunc) Call() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if cf.closed {
+		return
+	}
+	if !cf.leading {
+		cf.pending = true
+		cf.setTimerLocked()
+		return
+	}
+	if cf.waiting {
+		cf.pending = true
+		return
+	}
+	cf.runLocked()
+	cf.setTimerLocked()
+}
+
+// Flush runs the function right away if a call to it is pending.
+func (cf *CoalescedFunc) Flush() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if cf.closed || !cf.pending {
+		return
+	}
+	cf.pending = false
+	cf.runLocked()
+	if cf.leading {
+		cf.setTimerLocked()
+	} else {
+		cf.stopTimerLocked()
+	}
+}
+
+// Stop drops any pending call and ignores all further ones. A run already in progress
+// is not waited for.
+func (cf *CoalescedFunc) Stop() {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.closed = true
+	cf.pending = false
+	cf.stopTimerLocked()
+}
+
+// Close ignores all further calls, runs the function one last time if a call to it is
+// pending, and waits for it to finish running or for the context to be done.
+func (cf *CoalescedFunc) Close(ctx context.Context) error {
+	cf.mu.Lock()
+	if !cf.closed {
+		cf.closed = true
+		cf.stopTimerLocked()
+		if cf.pending {
+			cf.pending = false
+			cf.runLocked()
+		}
+	}
+	cf.mu.Unlock()
+
+	done := make(chan struct{})
+	PanicCapturingGo(func() {
+		defer close(done)
+		cf.running.Wait()
+	})
+	if !SelectContextOrWaitChan(ctx, done) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (cf *CoalescedFunc) setTimerLocked() {
+	cf.stopTimerLocked()
+	cf.waiting = true
+	gen := cf.gen
+	cf.timer = time.AfterFunc(cf.wait, func() {
+		cf.fire(gen)
+	})
+}
+
+func (cf *CoalescedFunc) stopTimerLocked() {
+	if cf.timer != nil {
+		cf.timer.Stop()
+		cf.timer = nil
+	}
+	cf.gen++
+	cf.waiting = false
+}
+
+func (cf *CoalescedFunc) fire(gen uint64) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if cf.closed || gen != cf.gen {
+		return
+	}
+	cf.waiting = false
+	if !cf.pending {
+		return
+	}
+	cf.pending = false
+	cf.runLocked()
+	if cf.leading {
+		cf.setTimerLocked()
+	}
+}
+
+func (cf *CoalescedFunc) runLocked() {
+	cf.running.Add(1)
+	PanicCapturingGo(func() {
+		defer cf.running.Done()
+		cf.runMu.Lock()
+		defer cf.runMu.Unlock()
+		cf.fn()
+	})
+}
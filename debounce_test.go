@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestDebounce(t *testing.T) {
+	t.Run("coalesces a burst", func(t *testing.T) {
+		var runs atomic.Int32
+		debounced := Debounce(50*time.Millisecond, func() {
+			runs.Add(1)
+		})
+		for i := 0; i < 10; i++ {
+			debounced.Call()
+		}
+		test.That(t, runs.Load(), test.ShouldEqual, 0)
+		time.Sleep(200 * time.Millisecond)
+		test.That(t, runs.Load(), test.ShouldEqual, 1)
+		test.That(t, debounced.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, runs.Load(), test.ShouldEqual, 1)
+	})
+
+	t.Run("close flushes", func(t *testing.T) {
+		var runs atomic.Int32
+		debounced := Debounce(time.Hour, func() {
+			runs.Add(1)
+		})
+		debounced.Call()
+		test.That(t, debounced.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, runs.Load(), test.ShouldEqual, 1)
+
+		// calls once closed are ignored
+		debounced.Call()
+		debounced.Flush()
+		test.That(t, debounced.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, runs.Load(), test.ShouldEqual, 1)
+	})
+
+	t.Run("close waits for the context", func(t *testing.T) {
+		release := make(chan struct{})
+		debounced := Debounce(time.Hour, func() {
+			<-release
+		})
+		debounced.Call()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		test.That(t, debounced.Close(ctx), test.ShouldBeError, context.Canceled)
+		close(release)
+		test.That(t, debounced.Close(context.Background()), test.ShouldBeNil)
+	})
+
+	t.Run("stop drops pending calls", func(t *testing.T) {
+		var runs atomic.Int32
+		debounced := Debounce(time.Millisecond, func() {
+			runs.Add(1)
+		})
+		debounced.Call()
+		debounced.Stop()
+		time.Sleep(50 * time.Millisecond)
+		test.That(t, debounced.Close(context.Background()), test.ShouldBeNil)
+		test.That(t, runs.Load(), test.ShouldEqual, 0)
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	var runs atomic.Int32
+	ran := make(chan struct{}, 10)
+	throttled := Throttle(100*time.Millisecond, func() {
+		runs.Add(1)
+		ran <- struct{}{}
+	})
+	defer func() {
+		test.That(t, throttled.Close(context.Background()), test.ShouldBeNil)
+	}()
+
+	// the first call runs right away and the rest are coalesced into one more run
+	for i := 0; i < 10; i++ {
+		throttled.Call()
+	}
+	<-ran
+	test.That(t, runs.Load(), test.ShouldEqual, 1)
+	<-ran
+	time.Sleep(200 * time.Millisecond)
+	test.That(t, runs.Load(), test.ShouldEqual, 2)
+
+	// flushing runs the pending call without waiting for the interval
+	throttled.Call()
+	<-ran
+	throttled.Call()
+	throttled.Flush()
+	<-ran
+	test.That(t, runs.Load(), test.ShouldEqual, 4)
+}
@@ -0,0 +1,179 @@
+// Package errs contains errors categorized by what went wrong, such as something not
+// being found, so that the category survives being returned over gRPC, WebRTC, and HTTP.
+package errs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A Category is the kind of failure an error represents. Each corresponds to a gRPC
+// status code of the same name.
+type Category string
+
+// The Categories of errors.
+const (
+	Unknown            Category = "unknown"
+	Canceled           Category = "canceled"
+	InvalidArgument    Category = "invalid_argument"
+	DeadlineExceeded   Category = "deadline_exceeded"
+	NotFound           Category = "not_found"
+	AlreadyExists      Category = "already_exists"
+	PermissionDenied   Category = "permission_denied"
+	ResourceExhausted  Category = "resource_exhausted"
+	FailedPrecondition Category = "failed_precondition"
+	Aborted            Category = "aborted"
+	Unimplemented      Category = "unimplemented"
+	Internal           Category = "internal"
+	Unavailable        Category = "unavailable"
+	Unauthenticated    Category = "unauthenticated"
+)
+
+// statusClientClosedRequest is the non-standard HTTP status used for requests canceled by
+// the client, as grpc-gateway does.
+const statusClientClosedRequest = 499
+
+var categoryCodes = map[Category]struct {
+	code       codes.Code
+	httpStatus int
+}{
+	Unknown:            {codes.Unknown, http.StatusInternalServerError},
+	Canceled:           {codes.Canceled, statusClientClosedRequest},
+	InvalidArgument:    {codes.InvalidArgument, http.StatusBadRequest},
+	DeadlineExceeded:   {codes.DeadlineExceeded, http.StatusGatewayTimeout},
+	NotFound:           {codes.NotFound, http.StatusNotFound},
+	AlreadyExists:      {codes.AlreadyExists, http.StatusConflict},
+	PermissionDenied:   {codes.PermissionDenied, http.StatusForbidden},
+	ResourceExhausted:  {codes.ResourceExhausted, http.StatusTooManyRequests},
+	FailedPrecondition: {codes.FailedPrecondition, http.StatusBadRequest},
+	Aborted:            {codes.Aborted, http.StatusConflict},
+	Unimplemented:      {codes.Unimplemented, http.StatusNotImplemented},
+	Internal:           {codes.Internal, http.StatusInternalServerError},
+	Unavailable:        {codes.Unavailable, http.StatusServiceUnavailable},
+	Unauthenticated:    {codes.Unauthenticated, http.StatusUnauthorized},
+}
+
+// GRPCCode returns the gRPC status code of the category.
+func (c Category) GRPCCode() codes.Code {
+	if mapped, ok := categoryCodes[c]; ok {
+		return mapped.code
+	}
+	return codes.Unknown
+}
+
+// HTTPStatus returns the HTTP status code of the category.
+func (c Category) HTTPStatus() int {
+	if mapped, ok := categoryCodes[c]; ok {
+		return mapped.httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// categoryForCode returns the category of the gRPC status code.
+func categoryForCode(code codes.Code) Category {
+	for category, mapped := range categoryCodes {
+		if mapped.code == code {
+			return category
+		}
+	}
+	return Unknown
+}
+
+// Errorf returns a new error of the category.
+func (c Category) Errorf(format string, args ...interface{}) error {
+	return &Error{Category: c, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns err annotated with the category and message, or nil if err is nil.
+func (c Category) Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: c, Msg: msg, Err: err}
+}
+
+// Wrapf is Wrap with a formatted message.
+func (c Category) Wrapf(err error, format string, args ...interface{}) error {
+	return c.Wrap(err, fmt.Sprintf(format, args...))
+}
+
+// An Error is an error of a Category. It converts to a gRPC status of the category's code
+// and is used as a web.ErrorResponse with the category's HTTP status.
+type Error struct {
+	Category Category
+	// Msg describes what went wrong.
+	Msg string
+	// Err, if set, is the error that caused this one.
+	Err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Msg
+	case e.Msg == "":
+		return e.Err.Error()
+	default:
+		return e.Msg + ": " + e.Err.Error()
+	}
+}
+
+// Unwrap returns the error that caused this one, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the error as a gRPC status.
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.Category.GRPCCode(), e.Error())
+}
+
+// Status returns the HTTP status code of the error.
+func (e *Error) Status() int {
+	return e.Category.HTTPStatus()
+}
+
+// CategoryOf returns the category of the error. Errors without one of their own get one
+// from the gRPC status they carry, such as errors returned by remote calls, or from being
+// a context error. Otherwise the category is Unknown.
+func CategoryOf(err error) Category {
+	if err == nil {
+		return ""
+	}
+	var categorized *Error
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	var withStatus interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &withStatus) {
+		return categoryForCode(withStatus.GRPCStatus().Code())
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	default:
+		return Unknown
+	}
+}
+
+// Is returns whether the error is of the category.
+func Is(err error, category Category) bool {
+	return CategoryOf(err) == category
+}
+
+// ToStatus returns the gRPC status of an error that is or wraps an Error. The message of
+// the status is that of the whole error. It returns false for any other error.
+func ToStatus(err error) (*status.Status, bool) {
+	var categorized *Error
+	if !errors.As(err, &categorized) {
+		return nil, false
+	}
+	return status.New(categorized.Category.GRPCCode(), err.Error()), true
+}
@@ -0,0 +1,61 @@
+package errs
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCategories(t *testing.T) {
+	for category := range categoryCodes {
+		test.That(t, categoryForCode(category.GRPCCode()), test.ShouldEqual, category)
+	}
+	test.That(t, NotFound.HTTPStatus(), test.ShouldEqual, http.StatusNotFound)
+	test.That(t, Category("bogus").GRPCCode(), test.ShouldEqual, codes.Unknown)
+	test.That(t, Category("bogus").HTTPStatus(), test.ShouldEqual, http.StatusInternalServerError)
+}
+
+func TestError(t *testing.T) {
+	err := NotFound.Errorf("robot %q", "foo")
+	test.That(t, err.Error(), test.ShouldEqual, `robot "foo"`)
+	test.That(t, CategoryOf(err), test.ShouldEqual, NotFound)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.NotFound)
+
+	cause := errors.New("connection refused")
+	test.That(t, Unavailable.Wrap(nil, "dialing"), test.ShouldBeNil)
+	err = Unavailable.Wrapf(cause, "dialing %s", "host")
+	test.That(t, err.Error(), test.ShouldEqual, "dialing host: connection refused")
+	test.That(t, errors.Is(err, cause), test.ShouldBeTrue)
+	test.That(t, Is(err, Unavailable), test.ShouldBeTrue)
+
+	// the category survives more wrapping
+	wrapped := errors.Wrap(err, "starting")
+	test.That(t, CategoryOf(wrapped), test.ShouldEqual, Unavailable)
+	var categorized *Error
+	test.That(t, errors.As(wrapped, &categorized), test.ShouldBeTrue)
+	test.That(t, categorized.Status(), test.ShouldEqual, http.StatusServiceUnavailable)
+
+	s, ok := ToStatus(wrapped)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, s.Code(), test.ShouldEqual, codes.Unavailable)
+	test.That(t, s.Message(), test.ShouldEqual, "starting: dialing host: connection refused")
+
+	// and is recovered from the status on the other end
+	test.That(t, CategoryOf(s.Err()), test.ShouldEqual, Unavailable)
+
+	_, ok = ToStatus(cause)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestCategoryOf(t *testing.T) {
+	test.That(t, CategoryOf(nil), test.ShouldEqual, Category(""))
+	test.That(t, CategoryOf(errors.New("whoops")), test.ShouldEqual, Unknown)
+	test.That(t, CategoryOf(status.Error(codes.PermissionDenied, "no")), test.ShouldEqual, PermissionDenied)
+	test.That(t, CategoryOf(errors.Wrap(context.Canceled, "stopping")), test.ShouldEqual, Canceled)
+	test.That(t, CategoryOf(context.DeadlineExceeded), test.ShouldEqual, DeadlineExceeded)
+}
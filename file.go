@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// WriteFileAtomicOptions control how WriteFileAtomic writes a file.
+type WriteFileAtomicOptions struct {
+	// Perm is the permissions of the file. Defaults to 0o644.
+	Perm os.FileMode
+
+	// PreserveExisting is whether a file being replaced keeps its permissions and, where
+	// supported, its owner instead of getting Perm.
+	PreserveExisting bool
+
+	// Sync is whether the contents of the file are flushed to disk before it replaces
+	// the old one, so that a crash cannot leave the file empty or partially written.
+	Sync bool
+
+	// SyncDir is whether the directory of the file is flushed to disk once the file is in
+	// place, so that a crash cannot undo the replacement.
+	SyncDir bool
+
+	// TempPattern is the pattern (see os.CreateTemp) of the name of the temporary file the
+	// contents are written to before replacing the file. Defaults to the name of the file
+	// prefixed with a dot and suffixed with ".tmp-*" so that it is hidden.
+	TempPattern string
+}
+
+// WriteFileAtomic writes data to the file at the given path such that the file either
+// has its old contents or all of the new ones, even if the process crashes part way. The
+// data is written to a temporary file in the same directory which then replaces the file.
+func WriteFileAtomic(path string, data []byte, opts WriteFileAtomicOptions) error {
+	return WriteFileAtomicFrom(path, bytes.NewReader(data), opts)
+}
+
+// WriteFileAtomicFrom is WriteFileAtomic with the contents read from r. If reading fails,
+// the file is left as it was.
+func WriteFileAtomicFrom(path string, r io.Reader, opts WriteFileAtomicOptions) (err error) {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0o644
+	}
+	pattern := opts.TempPattern
+	if pattern == "" {
+		pattern = "." + filepath.Base(path) + ".tmp-*"
+	}
+	var existing os.FileInfo
+	if opts.PreserveExisting {
+		existing, err = os.Stat(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	dir := filepath.Dir(path)
+	tempFile, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return err
+	}
+	var successful bool
+	defer func() {
+		if !successful {
+			UncheckedError(tempFile.Close())
+			UncheckedError(os.Remove(tempFile.Name()))
+		}
+	}()
+
+	if existing != nil {
+		perm = existing.Mode().Perm()
+		if err := preserveOwner(tempFile, existing); err != nil {
+			return errors.Wrapf(err, "error preserving owner of %q", path)
+		}
+	}
+	if err := tempFile.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tempFile, r); err != nil {
+		return err
+	}
+	if opts.Sync {
+		if err := tempFile.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return err
+	}
+	successful = true
+	if opts.SyncDir {
+		return syncDir(dir)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+
+	"go.uber.org/multierr"
+)
+
+// preserveOwner gives the file the owner of the file described by info.
+func preserveOwner(f *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return f.Chown(int(stat.Uid), int(stat.Gid))
+}
+
+// syncDir flushes the entries of the directory to disk.
+func syncDir(dir string) error {
+	//nolint:gosec
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return multierr.Combine(f.Sync(), f.Close())
+}
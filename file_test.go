@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+
+	test.That(t, WriteFileAtomic(path, []byte("hello"), WriteFileAtomicOptions{Sync: true, SyncDir: true}), test.ShouldBeNil)
+	//nolint:gosec
+	data, err := os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(data), test.ShouldEqual, "hello")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, info.Mode().Perm(), test.ShouldEqual, os.FileMode(0o644))
+
+		test.That(t, os.Chmod(path, 0o600), test.ShouldBeNil)
+		test.That(t, WriteFileAtomic(path, []byte("there"), WriteFileAtomicOptions{PreserveExisting: true}), test.ShouldBeNil)
+		info, err = os.Stat(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, info.Mode().Perm(), test.ShouldEqual, os.FileMode(0o600))
+	}
+
+	// a failed write leaves the file as it was without anything left behind
+	errRead := errors.New("whoops")
+	err = WriteFileAtomicFrom(path, iotest.ErrReader(errRead), WriteFileAtomicOptions{})
+	test.That(t, err, test.ShouldEqual, errRead)
+	//nolint:gosec
+	data, err = os.ReadFile(path)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(data), test.ShouldNotEqual, "")
+	entries, err := os.ReadDir(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entries, test.ShouldHaveLength, 1)
+
+	// temporary files can be named
+	test.That(t, WriteFileAtomic(path, nil, WriteFileAtomicOptions{TempPattern: "custom-*"}), test.ShouldBeNil)
+	entries, err = os.ReadDir(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, entries, test.ShouldHaveLength, 1)
+	test.That(t, entries[0].Name(), test.ShouldEqual, "file")
+}
@@ -0,0 +1,13 @@
+package utils
+
+import "os"
+
+// preserveOwner does nothing on Windows where files are not owned by a user and group.
+func preserveOwner(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing on Windows where directories cannot be flushed.
+func syncDir(dir string) error {
+	return nil
+}
@@ -3,13 +3,20 @@ package jwks
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	httphelper "github.com/zitadel/oidc/pkg/http"
 	"github.com/zitadel/oidc/pkg/oidc"
@@ -45,8 +52,18 @@ type cachingKeyProvider struct {
 	cancel  context.CancelFunc
 	ar      *jwk.AutoRefresh
 	jwksURI string
+
+	// keys can be rotated before the next scheduled refresh, so unknown key IDs trigger
+	// a refresh, but no more than once every minForcedRefreshInterval.
+	refreshMu         sync.Mutex
+	lastForcedRefresh time.Time
 }
 
+// minForcedRefreshInterval bounds how often unknown key IDs can cause the keys to be refetched.
+var minForcedRefreshInterval = time.Minute
+
+var errKeyIDNotFound = errors.New("kid header does not exist in keyset")
+
 // Stop cancels the auto refresh.
 func (cp *cachingKeyProvider) Close() error {
 	cp.cancel()
@@ -60,6 +77,24 @@ func (cp *cachingKeyProvider) LookupKey(ctx context.Context, kid, alg string) (i
 		return nil, err
 	}
 
+	key, err := publicKeyFromKeySet(keyset, kid, alg)
+	if !errors.Is(err, errKeyIDNotFound) {
+		return key, err
+	}
+
+	// the key may have been rotated in since we last refreshed.
+	cp.refreshMu.Lock()
+	if time.Since(cp.lastForcedRefresh) < minForcedRefreshInterval {
+		cp.refreshMu.Unlock()
+		return nil, err
+	}
+	cp.lastForcedRefresh = time.Now()
+	cp.refreshMu.Unlock()
+
+	keyset, err = cp.ar.Refresh(ctx, cp.jwksURI)
+	if err != nil {
+		return nil, err
+	}
 	return publicKeyFromKeySet(keyset, kid, alg)
 }
 
@@ -100,6 +135,13 @@ func NewCachingOIDCJWKKeyProvider(ctx context.Context, issuer string) (KeyProvid
 		return nil, oidc.ErrIssuerInvalid
 	}
 
+	return NewCachingJWKKeyProvider(ctx, discoveryConfig.JwksURI)
+}
+
+// NewCachingJWKKeyProvider creates a CachingKeyProvider for the keys served at the given
+// JWKS URI and starts the auto refresh. Call CachingKeyProvider.Stop() to stop any
+// background goroutines.
+func NewCachingJWKKeyProvider(ctx context.Context, jwksURI string) (KeyProvider, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
 	ar := jwk.NewAutoRefresh(ctx)
@@ -108,10 +150,10 @@ func NewCachingOIDCJWKKeyProvider(ctx context.Context, issuer string) (KeyProvid
 	// when it needs to (based on Cache-Control or Expires header from
 	// the HTTP response). If the calculated minimum refresh interval is less
 	// than 15 minutes, don't go refreshing any earlier than 15 minutes.
-	ar.Configure(discoveryConfig.JwksURI, jwk.WithMinRefreshInterval(15*time.Minute))
+	ar.Configure(jwksURI, jwk.WithMinRefreshInterval(15*time.Minute))
 
 	// Refresh the JWKS once before we start our service.
-	if _, err := ar.Refresh(ctx, discoveryConfig.JwksURI); err != nil {
+	if _, err := ar.Refresh(ctx, jwksURI); err != nil {
 		cancel()
 		return nil, err
 	}
@@ -119,7 +161,7 @@ func NewCachingOIDCJWKKeyProvider(ctx context.Context, issuer string) (KeyProvid
 	return &cachingKeyProvider{
 		cancel:  cancel,
 		ar:      ar,
-		jwksURI: discoveryConfig.JwksURI,
+		jwksURI: jwksURI,
 	}, nil
 }
 
@@ -151,21 +193,80 @@ func NewStaticJWKKeyProvider(keyset KeySet) KeyProvider {
 	}
 }
 
-func publicKeyFromKeySet(keyset KeySet, kid, alg string) (*rsa.PublicKey, error) {
+func publicKeyFromKeySet(keyset KeySet, kid, alg string) (interface{}, error) {
 	key, ok := keyset.LookupKeyID(kid)
 	if !ok {
-		return nil, errors.New("kid header does not exist in keyset")
+		return nil, errKeyIDNotFound
 	}
 
 	if key.Algorithm() != alg {
 		return nil, errors.New("key from kid has different signing alg")
 	}
 
-	var pubKey rsa.PublicKey
-	err := key.Raw(&pubKey)
-	if err != nil {
+	switch key.KeyType() {
+	case jwa.RSA:
+		var pubKey rsa.PublicKey
+		if err := key.Raw(&pubKey); err != nil {
+			return nil, errors.New("invalid key type")
+		}
+		return &pubKey, nil
+	case jwa.EC:
+		var pubKey ecdsa.PublicKey
+		if err := key.Raw(&pubKey); err != nil {
+			return nil, errors.New("invalid key type")
+		}
+		return &pubKey, nil
+	case jwa.OKP:
+		var pubKey ed25519.PublicKey
+		if err := key.Raw(&pubKey); err != nil {
+			return nil, errors.New("invalid key type")
+		}
+		return pubKey, nil
+	default:
 		return nil, errors.New("invalid key type")
 	}
+}
 
-	return &pubKey, nil
+// SigningAlgorithm returns the JWT signing algorithm (alg) used with the given public key:
+// RS256 for RSA, ES256 for ECDSA P-256, and EdDSA for Ed25519 keys.
+func SigningAlgorithm(pubKey crypto.PublicKey) (string, error) {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		return jwa.RS256.String(), nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported ECDSA curve %q", key.Curve.Params().Name)
+		}
+		return jwa.ES256.String(), nil
+	case ed25519.PublicKey:
+		return jwa.EdDSA.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+}
+
+// NewPublicKeySet returns a KeySet of the given public keys by their key ID. Each key's
+// alg is set to its SigningAlgorithm.
+func NewPublicKeySet(keys map[string]crypto.PublicKey) (KeySet, error) {
+	keyset := jwk.NewSet()
+	for kid, pubKey := range keys {
+		alg, err := SigningAlgorithm(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		jwkKey, err := jwk.New(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := jwkKey.Set(jwk.AlgorithmKey, alg); err != nil {
+			return nil, err
+		}
+		if err := jwkKey.Set(jwk.KeyIDKey, kid); err != nil {
+			return nil, err
+		}
+		if !keyset.Add(jwkKey) {
+			return nil, fmt.Errorf("duplicate key %q", kid)
+		}
+	}
+	return keyset, nil
 }
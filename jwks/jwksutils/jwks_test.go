@@ -2,7 +2,13 @@ package jwksutils
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
 	"testing"
 
 	"go.viam.com/test"
@@ -87,3 +93,82 @@ func TestOIDCRefreshingKeySet(t *testing.T) {
 	_, err = keyProvider.LookupKey(ctx, "key-id-1", "foo")
 	test.That(t, err.Error(), test.ShouldContainSubstring, "key from kid has different signing alg")
 }
+
+func TestCachingKeyRotation(t *testing.T) {
+	ctx := context.Background()
+
+	set, _, err := NewTestKeySet(1)
+	test.That(t, err, test.ShouldBeNil)
+
+	address, closeFakeOIDC := ServeFakeOIDCEndpoint(t, set)
+	defer closeFakeOIDC()
+
+	keyProvider, err := jwks.NewCachingOIDCJWKKeyProvider(ctx, address)
+	test.That(t, err, test.ShouldBeNil)
+	defer keyProvider.Close()
+
+	_, err = keyProvider.LookupKey(ctx, "key-id-1", "RS256")
+	test.That(t, err, test.ShouldBeNil)
+
+	// rotate a new key in on the issuer side
+	otherSet, keys, err := NewTestKeySet(2)
+	test.That(t, err, test.ShouldBeNil)
+	rotatedKey, ok := otherSet.LookupKeyID("key-id-2")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, set.Add(rotatedKey), test.ShouldBeTrue)
+
+	publicKey2, err := keyProvider.LookupKey(ctx, "key-id-2", "RS256")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, publicKey2.(*rsa.PublicKey).N, test.ShouldResemble, keys[1].PublicKey.N)
+
+	_, err = keyProvider.LookupKey(ctx, "not-a-key", "RS256")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "kid header does not exist")
+}
+
+func TestPublicKeySet(t *testing.T) {
+	ctx := context.Background()
+
+	//nolint:gosec
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
+	test.That(t, err, test.ShouldBeNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	edPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+
+	keyset, err := jwks.NewPublicKeySet(map[string]crypto.PublicKey{
+		"rsa": &rsaKey.PublicKey,
+		"ec":  &ecKey.PublicKey,
+		"ed":  edPubKey,
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	// serialize and parse the keys back
+	keysetJSON, err := json.Marshal(keyset)
+	test.That(t, err, test.ShouldBeNil)
+	keyset, err = jwks.ParseKeySet(string(keysetJSON))
+	test.That(t, err, test.ShouldBeNil)
+	keyProvider := jwks.NewStaticJWKKeyProvider(keyset)
+
+	rsaPubKey, err := keyProvider.LookupKey(ctx, "rsa", "RS256")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rsaPubKey.(*rsa.PublicKey).Equal(&rsaKey.PublicKey), test.ShouldBeTrue)
+
+	ecPubKey, err := keyProvider.LookupKey(ctx, "ec", "ES256")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ecPubKey.(*ecdsa.PublicKey).Equal(&ecKey.PublicKey), test.ShouldBeTrue)
+
+	parsedEdPubKey, err := keyProvider.LookupKey(ctx, "ed", "EdDSA")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, parsedEdPubKey.(ed25519.PublicKey).Equal(edPubKey), test.ShouldBeTrue)
+
+	_, err = keyProvider.LookupKey(ctx, "ec", "RS256")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "key from kid has different signing alg")
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = jwks.SigningAlgorithm(&p384Key.PublicKey)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported ECDSA curve")
+}
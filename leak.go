@@ -2,15 +2,21 @@ package utils
 
 import "go.uber.org/goleak"
 
+// LongLivedGoroutines are the top functions of goroutines that, once started, run for the
+// life of the process and so are not leaks.
+var LongLivedGoroutines = []string{
+	"go.opencensus.io/stats/view.(*worker).start",
+	"github.com/desertbit/timer.timerRoutine",              // gRPC uses this
+	"github.com/letsencrypt/pebble/va.VAImpl.processTasks", // no way to stop it,
+}
+
 // FindGoroutineLeaks finds any goroutine leaks after a program is done running. This
 // should be used at the end of a main test run or a top-level process run.
 func FindGoroutineLeaks(options ...goleak.Option) error {
-	optsCopy := make([]goleak.Option, len(options))
+	optsCopy := make([]goleak.Option, len(options), len(options)+len(LongLivedGoroutines))
 	copy(optsCopy, options)
-	optsCopy = append(optsCopy,
-		goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"),
-		goleak.IgnoreTopFunction("github.com/desertbit/timer.timerRoutine"),              // gRPC uses this
-		goleak.IgnoreTopFunction("github.com/letsencrypt/pebble/va.VAImpl.processTasks"), // no way to stop it,
-	)
+	for _, topFunction := range LongLivedGoroutines {
+		optsCopy = append(optsCopy, goleak.IgnoreTopFunction(topFunction))
+	}
 	return goleak.Find(optsCopy...)
 }
@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// The environment variables of systemd's socket activation and readiness notification
+// protocols (see sd_listen_fds(3) and sd_notify(3)), which are also used to hand off
+// listeners to a replacement process.
+const (
+	envListenFDs    = "LISTEN_FDS"
+	envListenPID    = "LISTEN_PID"
+	envListenNames  = "LISTEN_FDNAMES"
+	envNotifySocket = "NOTIFY_SOCKET"
+)
+
+// listenFDsStart is the first file descriptor of inherited listeners.
+const listenFDsStart = 3
+
+// notifyReady is the message sent to indicate readiness.
+const notifyReady = "READY=1"
+
+// InheritedListeners returns the listeners passed to this process by systemd socket
+// activation or by the process that started it with StartReplacementProcess, in the order
+// they were passed. It returns none if no listeners were passed. The environment variables
+// describing them are unset so that they are not passed on to any other process.
+func InheritedListeners() ([]net.Listener, error) {
+	fds := os.Getenv(envListenFDs)
+	pid := os.Getenv(envListenPID)
+	for _, key := range []string{envListenFDs, envListenPID, envListenNames} {
+		if err := os.Unsetenv(key); err != nil {
+			return nil, err
+		}
+	}
+	if fds == "" {
+		return nil, nil
+	}
+	// replacement processes do not know their PID in advance so it is only checked if set.
+	if pid != "" {
+		listenPID, err := strconv.Atoi(pid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", envListenPID)
+		}
+		if listenPID != os.Getpid() {
+			return nil, nil
+		}
+	}
+	numFDs, err := strconv.Atoi(fds)
+	if err != nil || numFDs < 0 {
+		return nil, errors.Errorf("invalid %s %q", envListenFDs, fds)
+	}
+
+	listeners := make([]net.Listener, 0, numFDs)
+	for fd := listenFDsStart; fd < listenFDsStart+numFDs; fd++ {
+		file := os.NewFile(uintptr(fd), "listener"+strconv.Itoa(fd))
+		// the listener has its own duplicate of the file descriptor.
+		listener, err := net.FileListener(file)
+		err = multierr.Combine(err, file.Close())
+		if err != nil {
+			for _, listener := range listeners {
+				err = multierr.Combine(err, listener.Close())
+			}
+			return nil, errors.Wrapf(err, "inherited file descriptor %d is not a listener", fd)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// NotifyReady tells systemd (for services with Type=notify), or the process that started
+// this one with StartReplacementProcess, that this process is ready. It does nothing if
+// nothing is waiting on it. ContextualMain calls it once its main function indicates it
+// is ready with ContextMainReadyFunc.
+func NotifyReady() error {
+	socket := os.Getenv(envNotifySocket)
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to notify socket")
+	}
+	_, err = conn.Write([]byte(notifyReady))
+	return multierr.Combine(err, conn.Close())
+}
+
+// A ReplacementProcess is a new instance of this process started by StartReplacementProcess.
+type ReplacementProcess struct {
+	Process *os.Process
+	exited  chan struct{}
+	err     error
+}
+
+// Wait waits for the process to exit and returns why it did, if it was not successful.
+func (p *ReplacementProcess) Wait() error {
+	<-p.exited
+	return p.err
+}
+
+// replacementArgs are the arguments a replacement process is started with.
+var replacementArgs = func() []string {
+	return os.Args[1:]
+}
+
+// StartReplacementProcess starts a new instance of this process, with the same arguments
+// and environment, that inherits the given listeners (see InheritedListeners) so that
+// connections are not refused while it takes over. It returns once the new process is
+// ready (see NotifyReady), at which point this process should stop serving on its
+// listeners, finish the requests in flight, and exit. If the new process exits or the
+// context is done before then, the new process is killed and an error is returned.
+func StartReplacementProcess(ctx context.Context, listeners ...net.Listener) (*ReplacementProcess, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*os.File, 0, len(listeners))
+	defer func() {
+		for _, file := range files {
+			UncheckedError(file.Close())
+		}
+	}()
+	for _, listener := range listeners {
+		filer, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, errors.Errorf("cannot hand off listener of type %T", listener)
+		}
+		file, err := filer.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	notifyDir, err := os.MkdirTemp("", "notify")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		UncheckedError(os.RemoveAll(notifyDir))
+	}()
+	notifyAddr := &net.UnixAddr{Name: filepath.Join(notifyDir, "notify.sock"), Net: "unixgram"}
+	notifyConn, err := net.ListenUnixgram("unixgram", notifyAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		UncheckedError(notifyConn.Close())
+	}()
+
+	//nolint:gosec
+	cmd := exec.Command(executable, replacementArgs()...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		if key != envListenFDs && key != envListenPID && key != envListenNames && key != envNotifySocket {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env,
+		envListenFDs+"="+strconv.Itoa(len(files)),
+		envNotifySocket+"="+notifyAddr.Name,
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	replacement := &ReplacementProcess{Process: cmd.Process, exited: make(chan struct{})}
+	PanicCapturingGo(func() {
+		defer close(replacement.exited)
+		replacement.err = cmd.Wait()
+	})
+
+	ready := make(chan error, 1)
+	PanicCapturingGo(func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := notifyConn.Read(buf)
+			if err != nil {
+				ready <- err
+				return
+			}
+			for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+				if string(line) == notifyReady {
+					ready <- nil
+					return
+				}
+			}
+		}
+	})
+
+	select {
+	case err = <-ready:
+		if err == nil {
+			return replacement, nil
+		}
+		err = errors.Wrap(err, "failed to wait for replacement process to be ready")
+	case <-replacement.exited:
+		return nil, errors.Wrap(multierr.Combine(errors.New("exited before it was ready"), replacement.err), "replacement process")
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if killErr := replacement.Process.Kill(); killErr != nil {
+		err = multierr.Combine(err, killErr)
+	}
+	<-replacement.exited
+	return nil, err
+}
+
+// ListenReusePort listens on the address like net.Listen but with SO_REUSEPORT set so that
+// other processes, such as a replacement of this one, may listen on the same address at the
+// same time and have new connections spread between them. It is not supported on Windows.
+func ListenReusePort(ctx context.Context, network, address string) (net.Listener, error) {
+	listenConfig := net.ListenConfig{Control: reusePortControl}
+	return listenConfig.Listen(ctx, network, address)
+}
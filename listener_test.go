@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestInheritedListeners(t *testing.T) {
+	t.Setenv(envListenFDs, "")
+	listeners, err := InheritedListeners()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, listeners, test.ShouldBeEmpty)
+
+	// listeners meant for another process are ignored
+	t.Setenv(envListenFDs, "1")
+	t.Setenv(envListenPID, strconv.Itoa(os.Getpid()+1))
+	listeners, err = InheritedListeners()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, listeners, test.ShouldBeEmpty)
+	_, ok := os.LookupEnv(envListenFDs)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = os.LookupEnv(envListenPID)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	t.Setenv(envListenFDs, "nope")
+	_, err = InheritedListeners()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid LISTEN_FDS")
+
+	t.Setenv(envListenFDs, "1")
+	t.Setenv(envListenPID, "nope")
+	_, err = InheritedListeners()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid LISTEN_PID")
+}
+
+// envReplacementHelper makes TestStartReplacementProcess act as the replacement process.
+const envReplacementHelper = "UTILS_TEST_REPLACEMENT_HELPER"
+
+func TestStartReplacementProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("listeners cannot be handed off on windows")
+	}
+	if os.Getenv(envReplacementHelper) != "" {
+		serveAsReplacement()
+		return
+	}
+
+	prevArgs := replacementArgs
+	replacementArgs = func() []string {
+		return []string{"-test.run=^TestStartReplacementProcess$"}
+	}
+	defer func() {
+		replacementArgs = prevArgs
+	}()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	// the replacement fails if it cannot get ready
+	t.Setenv(envReplacementHelper, "fail")
+	_, err = StartReplacementProcess(context.Background(), listener)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "exited before it was ready")
+
+	t.Setenv(envReplacementHelper, "serve")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	replacement, err := StartReplacementProcess(ctx, listener)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, listener.Close(), test.ShouldBeNil)
+
+	// the replacement answers on the same address
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	rd, err := io.ReadAll(conn)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "replaced")
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, replacement.Wait(), test.ShouldBeNil)
+}
+
+// serveAsReplacement answers one connection on the inherited listener and exits.
+func serveAsReplacement() {
+	if os.Getenv(envReplacementHelper) == "fail" {
+		os.Exit(1)
+	}
+	listeners, err := InheritedListeners()
+	if err != nil || len(listeners) != 1 {
+		os.Exit(2)
+	}
+	if err := NotifyReady(); err != nil {
+		os.Exit(3)
+	}
+	conn, err := listeners[0].Accept()
+	if err != nil {
+		os.Exit(4)
+	}
+	if _, err := conn.Write([]byte("replaced")); err != nil {
+		os.Exit(5)
+	}
+	if err := conn.Close(); err != nil {
+		os.Exit(6)
+	}
+	os.Exit(0)
+}
+
+func TestNotifyReady(t *testing.T) {
+	t.Setenv(envNotifySocket, "")
+	test.That(t, NotifyReady(), test.ShouldBeNil)
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unix datagram sockets are not supported on windows")
+	}
+	addr := &net.UnixAddr{Name: t.TempDir() + "/notify.sock", Net: "unixgram"}
+	notifyConn, err := net.ListenUnixgram("unixgram", addr)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, notifyConn.Close(), test.ShouldBeNil)
+	}()
+
+	t.Setenv(envNotifySocket, addr.Name)
+	test.That(t, NotifyReady(), test.ShouldBeNil)
+	buf := make([]byte, 64)
+	n, err := notifyConn.Read(buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(buf[:n]), test.ShouldEqual, notifyReady)
+}
+
+func TestListenReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported on windows")
+	}
+	listener1, err := ListenReusePort(context.Background(), "tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, listener1.Close(), test.ShouldBeNil)
+	}()
+
+	listener2, err := ListenReusePort(context.Background(), "tcp", listener1.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, listener2.Close(), test.ShouldBeNil)
+
+	// listeners without it cannot share the address
+	_, err = net.Listen("tcp", listener1.Addr().String())
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,11 @@
+package utils
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on windows")
+}
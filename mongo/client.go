@@ -0,0 +1,218 @@
+package mongoutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// Defaults used by NewClient for unset fields of a ClientConfig.
+const (
+	DefaultClientMaxPoolSize            = 100
+	DefaultClientConnectTimeout         = 10 * time.Second
+	DefaultClientServerSelectionTimeout = 10 * time.Second
+	DefaultClientMaxConnIdleTime        = 5 * time.Minute
+	DefaultClientPingAttempts           = 5
+	DefaultClientHealthTimeout          = 2 * time.Second
+)
+
+// ClientConfig configures a client made by NewClient. Settings given in the URI are
+// overridden by those set here.
+type ClientConfig struct {
+	// URI is the connection string of the deployment to connect to. It is required.
+	URI string
+
+	// AppName, if set, identifies the client in server logs.
+	AppName string
+
+	// MinPoolSize is how many connections to each server are kept open even when idle.
+	MinPoolSize uint64
+
+	// MaxPoolSize is the most connections to each server. Defaults to
+	// DefaultClientMaxPoolSize.
+	MaxPoolSize uint64
+
+	// MaxConnIdleTime is how long a connection may be idle before it is closed. Defaults to
+	// DefaultClientMaxConnIdleTime.
+	MaxConnIdleTime time.Duration
+
+	// ConnectTimeout is how long opening a connection may take. Defaults to
+	// DefaultClientConnectTimeout.
+	ConnectTimeout time.Duration
+
+	// ServerSelectionTimeout is how long an operation waits for a suitable server.
+	// Defaults to DefaultClientServerSelectionTimeout.
+	ServerSelectionTimeout time.Duration
+
+	// PingAttempts is how many times the initial ping is tried before giving up. Defaults
+	// to DefaultClientPingAttempts.
+	PingAttempts int
+
+	// Monitor, if set, receives the events of every command instead of them being traced.
+	// Otherwise, commands are traced with OpenTelemetry much like otelmongo.NewMonitor
+	// (go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo)
+	// would, without depending on it.
+	Monitor *event.CommandMonitor
+
+	// TracerProvider provides the tracer commands are traced with when Monitor is not
+	// set. Defaults to the global OpenTelemetry tracer provider.
+	TracerProvider oteltrace.TracerProvider
+}
+
+// A Client is a connected MongoDB client as made by NewClient.
+type Client struct {
+	*mongo.Client
+}
+
+// NewClient connects a client to the deployment at the URI of the config. The client
+// gets bounded pool sizes and timeouts, traces every command with OpenTelemetry, and is
+// only returned once the primary answers a ping, which is retried with backoff for
+// deployments that are still starting up.
+func NewClient(ctx context.Context, cfg ClientConfig) (*Client, error) {
+	if cfg.URI == "" {
+		return nil, errors.New("expected MongoDB URI")
+	}
+	if _, err := connstring.ParseAndValidate(cfg.URI); err != nil {
+		return nil, errors.Wrap(err, "invalid MongoDB URI")
+	}
+	maxPoolSize := withDefault(cfg.MaxPoolSize, DefaultClientMaxPoolSize)
+	if cfg.MinPoolSize > maxPoolSize {
+		return nil, errors.New("expected min pool size to be at most max pool size")
+	}
+
+	opts := options.Client().ApplyURI(cfg.URI)
+	if cfg.AppName != "" {
+		opts.SetAppName(cfg.AppName)
+	}
+	opts.SetMinPoolSize(cfg.MinPoolSize)
+	opts.SetMaxPoolSize(maxPoolSize)
+	opts.SetMaxConnIdleTime(withDefault(cfg.MaxConnIdleTime, DefaultClientMaxConnIdleTime))
+	opts.SetConnectTimeout(withDefault(cfg.ConnectTimeout, DefaultClientConnectTimeout))
+	opts.SetServerSelectionTimeout(withDefault(cfg.ServerSelectionTimeout, DefaultClientServerSelectionTimeout))
+	if cfg.Monitor != nil {
+		opts.SetMonitor(cfg.Monitor)
+	} else {
+		tracerProvider := cfg.TracerProvider
+		if tracerProvider == nil {
+			tracerProvider = otel.GetTracerProvider()
+		}
+		opts.SetMonitor(newTracingCommandMonitor(tracerProvider))
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.Retry(ctx, utils.RetryPolicy{
+		MaxAttempts: withDefault(cfg.PingAttempts, DefaultClientPingAttempts),
+	}, func() error {
+		return client.Ping(ctx, readpref.Primary())
+	}); err != nil {
+		return nil, multierr.Combine(errors.Wrap(err, "failed to ping MongoDB"), client.Disconnect(ctx))
+	}
+	return &Client{client}, nil
+}
+
+// Healthy returns an error if the primary does not answer a ping in time, for use in
+// readiness probes.
+func (c *Client) Healthy(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultClientHealthTimeout)
+	defer cancel()
+	if err := c.Ping(ctx, readpref.Primary()); err != nil {
+		return errors.Wrap(err, "MongoDB is unhealthy")
+	}
+	return nil
+}
+
+func withDefault[T comparable](value, defaultValue T) T {
+	var zero T
+	if value == zero {
+		return defaultValue
+	}
+	return value
+}
+
+// tracerName names the tracer commands are traced with.
+const tracerName = "go.viam.com/utils/mongo"
+
+// newTracingCommandMonitor returns a monitor that traces every command with
+// OpenTelemetry as a span of the context it was run with.
+func newTracingCommandMonitor(tracerProvider oteltrace.TracerProvider) *event.CommandMonitor {
+	tracer := tracerProvider.Tracer(tracerName)
+	var mu sync.Mutex
+	spans := map[int64]oteltrace.Span{}
+	finish := func(requestID int64, failure string) {
+		mu.Lock()
+		span, ok := spans[requestID]
+		delete(spans, requestID)
+		mu.Unlock()
+		if !ok {
+			return
+		}
+		if failure != "" {
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := tracer.Start(
+				withOpenCensusParent(ctx),
+				"mongodb."+evt.CommandName,
+				oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+				oteltrace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", evt.DatabaseName),
+					attribute.String("db.operation", evt.CommandName),
+					attribute.String("net.peer.name", evt.ConnectionID),
+				),
+			)
+			mu.Lock()
+			spans[evt.RequestID] = span
+			mu.Unlock()
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, "")
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Failure)
+		},
+	}
+}
+
+// withOpenCensusParent bridges the OpenCensus span of the context, which the rest of
+// this module traces with, to be the parent of the OpenTelemetry spans started with it
+// unless the context already has an OpenTelemetry span.
+func withOpenCensusParent(ctx context.Context) context.Context {
+	if oteltrace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return ctx
+	}
+	spanContext := span.SpanContext()
+	var traceFlags oteltrace.TraceFlags
+	if spanContext.IsSampled() {
+		traceFlags = oteltrace.FlagsSampled
+	}
+	return oteltrace.ContextWithSpanContext(ctx, oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID(spanContext.TraceID),
+		SpanID:     oteltrace.SpanID(spanContext.SpanID),
+		TraceFlags: traceFlags,
+	}))
+}
@@ -0,0 +1,120 @@
+package mongoutils_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.viam.com/test"
+
+	mongoutils "go.viam.com/utils/mongo"
+	"go.viam.com/utils/testutils"
+)
+
+func TestNewClient(t *testing.T) {
+	_, err := mongoutils.NewClient(context.Background(), mongoutils.ClientConfig{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expected MongoDB URI")
+
+	_, err = mongoutils.NewClient(context.Background(), mongoutils.ClientConfig{URI: "http://localhost"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid MongoDB URI")
+
+	_, err = mongoutils.NewClient(context.Background(), mongoutils.ClientConfig{
+		URI:         "mongodb://localhost",
+		MinPoolSize: 10,
+		MaxPoolSize: 5,
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "min pool size")
+
+	client, err := mongoutils.NewClient(context.Background(), mongoutils.ClientConfig{
+		URI:     testutils.BackingMongoDBURI(t),
+		AppName: "test",
+	})
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, client.Disconnect(context.Background()), test.ShouldBeNil)
+	}()
+	test.That(t, client.Healthy(context.Background()), test.ShouldBeNil)
+}
+
+func TestNewClientTracing(t *testing.T) {
+	tracerProvider := &recordingTracerProvider{}
+	client, err := mongoutils.NewClient(context.Background(), mongoutils.ClientConfig{
+		URI:            testutils.BackingMongoDBURI(t),
+		TracerProvider: tracerProvider,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, client.Disconnect(context.Background()), test.ShouldBeNil)
+	}()
+
+	// commands run under an OpenCensus span are traced as its children.
+	ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	test.That(t, client.Ping(ctx, readpref.Primary()), test.ShouldBeNil)
+	span.End()
+
+	tracerProvider.mu.Lock()
+	defer tracerProvider.mu.Unlock()
+	test.That(t, tracerProvider.spans, test.ShouldNotBeEmpty)
+	last := tracerProvider.spans[len(tracerProvider.spans)-1]
+	test.That(t, last.name, test.ShouldEqual, "mongodb.ping")
+	test.That(t, last.ended, test.ShouldBeTrue)
+	test.That(t, last.statusCode, test.ShouldEqual, codes.Unset)
+	test.That(t, last.parent.TraceID(), test.ShouldEqual, oteltrace.TraceID(span.SpanContext().TraceID))
+	test.That(t, last.parent.SpanID(), test.ShouldEqual, oteltrace.SpanID(span.SpanContext().SpanID))
+	test.That(t, last.parent.IsSampled(), test.ShouldBeTrue)
+}
+
+// recordingTracerProvider records the spans started by its tracers.
+type recordingTracerProvider struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (tp *recordingTracerProvider) Tracer(name string, opts ...oteltrace.TracerOption) oteltrace.Tracer {
+	return tp
+}
+
+func (tp *recordingTracerProvider) Start(
+	ctx context.Context,
+	name string,
+	opts ...oteltrace.SpanStartOption,
+) (context.Context, oteltrace.Span) {
+	span := &recordingSpan{
+		Span:     oteltrace.SpanFromContext(context.Background()),
+		provider: tp,
+		name:     name,
+		parent:   oteltrace.SpanContextFromContext(ctx),
+	}
+	tp.mu.Lock()
+	tp.spans = append(tp.spans, span)
+	tp.mu.Unlock()
+	return oteltrace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	oteltrace.Span
+	provider   *recordingTracerProvider
+	name       string
+	parent     oteltrace.SpanContext
+	ended      bool
+	statusCode codes.Code
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.provider.mu.Lock()
+	defer s.provider.mu.Unlock()
+	s.statusCode = code
+}
+
+func (s *recordingSpan) End(opts ...oteltrace.SpanEndOption) {
+	s.provider.mu.Lock()
+	defer s.provider.mu.Unlock()
+	s.ended = true
+}
@@ -2,16 +2,301 @@ package mongoutils
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.opencensus.io/trace"
+	"go.uber.org/multierr"
 )
 
-// EnsureIndexes ensures that the given indexes are created on the given collection.
+// EnsureIndexes ensures that the given indexes are created on the given collection. It
+// fails without changing anything if an index with the same name or keys as one of them
+// exists with a different definition. See EnsureIndexesWithOptions.
 func EnsureIndexes(ctx context.Context, coll *mongo.Collection, indexes ...mongo.IndexModel) error {
+	_, err := EnsureIndexesWithOptions(ctx, coll, EnsureIndexesOptions{}, indexes...)
+	return err
+}
+
+// EnsureIndexesOptions control how EnsureIndexesWithOptions treats indexes that differ
+// from those expected.
+type EnsureIndexesOptions struct {
+	// DropExtra drops indexes that are not expected, other than the one on _id.
+	DropExtra bool
+
+	// IgnoreTTL does not compare when documents expire, so that TTLs tuned elsewhere
+	// (e.g. with collMod) are left alone.
+	IgnoreTTL bool
+}
+
+// An IndexDiff describes how the indexes of a collection differed from those expected.
+type IndexDiff struct {
+	// Created are the names of the expected indexes that were missing and got created.
+	Created []string
+	// Conflicting are the expected indexes with an index of the same name or keys but a
+	// different definition.
+	Conflicting []IndexConflict
+	// Extra are the names of the indexes that are not expected, other than the one on _id.
+	Extra []string
+	// Dropped are the names of the extra indexes that got dropped.
+	Dropped []string
+}
+
+// Empty returns whether the indexes were as expected.
+func (d IndexDiff) Empty() bool {
+	return len(d.Created) == 0 && len(d.Conflicting) == 0 && len(d.Extra) == 0
+}
+
+// An IndexConflict is an expected index of which a different definition exists.
+type IndexConflict struct {
+	Name     string
+	Expected string
+	Existing string
+}
+
+func (c IndexConflict) String() string {
+	return "index " + strconv.Quote(c.Name) + " exists as " + c.Existing + " instead of " + c.Expected
+}
+
+// EnsureIndexesWithOptions compares the indexes of the collection with those expected
+// and creates the missing ones. If any conflicts with an existing index, it fails without
+// changing anything. The returned diff describes what differed and what was done about it.
+func EnsureIndexesWithOptions(
+	ctx context.Context,
+	coll *mongo.Collection,
+	opts EnsureIndexesOptions,
+	indexes ...mongo.IndexModel,
+) (IndexDiff, error) {
 	ctx, span := trace.StartSpan(ctx, "EnsureIndexes")
 	defer span.End()
 
-	_, err := coll.Indexes().CreateMany(ctx, indexes)
-	return err
+	var diff IndexDiff
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return diff, err
+	}
+	var existingDocs []bson.D
+	if err := cursor.All(ctx, &existingDocs); err != nil {
+		return diff, err
+	}
+	existing := make([]indexDefinition, 0, len(existingDocs))
+	for _, doc := range existingDocs {
+		existing = append(existing, existingIndexDefinition(doc))
+	}
+
+	matched := map[string]bool{}
+	var missing []mongo.IndexModel
+	for _, index := range indexes {
+		expected, err := expectedIndexDefinition(index)
+		if err != nil {
+			return diff, err
+		}
+		found := false
+		for _, current := range existing {
+			if current.name != expected.name && current.keys != expected.keys {
+				continue
+			}
+			found = true
+			matched[current.name] = true
+			if !current.equal(expected, opts.IgnoreTTL) {
+				diff.Conflicting = append(diff.Conflicting, IndexConflict{
+					Name:     expected.name,
+					Expected: expected.String(),
+					Existing: current.String(),
+				})
+			}
+			break
+		}
+		if !found {
+			missing = append(missing, index)
+			diff.Created = append(diff.Created, expected.name)
+		}
+	}
+	for _, current := range existing {
+		if current.name != "_id_" && !matched[current.name] {
+			diff.Extra = append(diff.Extra, current.name)
+		}
+	}
+
+	if len(diff.Conflicting) != 0 {
+		diff.Created = nil
+		var errs error
+		for _, conflict := range diff.Conflicting {
+			errs = multierr.Combine(errs, errors.New(conflict.String()))
+		}
+		return diff, errors.Wrapf(errs, "an index of %q already exists with a different definition", coll.Name())
+	}
+
+	if len(missing) != 0 {
+		if _, err := coll.Indexes().CreateMany(ctx, missing); err != nil {
+			diff.Created = nil
+			return diff, err
+		}
+	}
+	if opts.DropExtra {
+		for _, name := range diff.Extra {
+			if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+				return diff, errors.Wrapf(err, "failed to drop index %q", name)
+			}
+			diff.Dropped = append(diff.Dropped, name)
+		}
+	}
+	return diff, nil
+}
+
+// indexDefinition is the part of an index's definition that EnsureIndexes compares,
+// with every part in a canonical form.
+type indexDefinition struct {
+	name                    string
+	keys                    string
+	unique                  bool
+	sparse                  bool
+	expireAfterSeconds      string
+	partialFilterExpression string
+}
+
+func (def indexDefinition) equal(other indexDefinition, ignoreTTL bool) bool {
+	if ignoreTTL {
+		def.expireAfterSeconds, other.expireAfterSeconds = "", ""
+	}
+	return def == other
+}
+
+func (def indexDefinition) String() string {
+	parts := []string{"name=" + def.name, "keys=" + def.keys}
+	if def.unique {
+		parts = append(parts, "unique")
+	}
+	if def.sparse {
+		parts = append(parts, "sparse")
+	}
+	if def.expireAfterSeconds != "" {
+		parts = append(parts, "expireAfterSeconds="+def.expireAfterSeconds)
+	}
+	if def.partialFilterExpression != "" {
+		parts = append(parts, "partialFilterExpression="+def.partialFilterExpression)
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// existingIndexDefinition returns the definition of an index as listed by MongoDB.
+func existingIndexDefinition(doc bson.D) indexDefinition {
+	var def indexDefinition
+	for _, elem := range doc {
+		switch elem.Key {
+		case "name":
+			def.name, _ = elem.Value.(string)
+		case "key":
+			def.keys = canonicalBSON(elem.Value)
+		case "unique":
+			def.unique, _ = elem.Value.(bool)
+		case "sparse":
+			def.sparse, _ = elem.Value.(bool)
+		case "expireAfterSeconds":
+			def.expireAfterSeconds = canonicalBSON(elem.Value)
+		case "partialFilterExpression":
+			def.partialFilterExpression = canonicalBSON(elem.Value)
+		}
+	}
+	return def
+}
+
+// expectedIndexDefinition returns the definition of an index to create.
+func expectedIndexDefinition(index mongo.IndexModel) (indexDefinition, error) {
+	var def indexDefinition
+	keys, err := toBSOND(index.Keys)
+	if err != nil {
+		return def, errors.Wrap(err, "invalid index keys")
+	}
+	def.keys = canonicalBSON(keys)
+	if index.Options != nil && index.Options.Name != nil {
+		def.name = *index.Options.Name
+	} else {
+		// named the same way the driver names indexes.
+		names := make([]string, 0, len(keys))
+		for _, elem := range keys {
+			names = append(names, elem.Key+"_"+canonicalBSON(elem.Value))
+		}
+		def.name = strings.Join(names, "_")
+	}
+	if index.Options == nil {
+		return def, nil
+	}
+	if index.Options.Unique != nil {
+		def.unique = *index.Options.Unique
+	}
+	if index.Options.Sparse != nil {
+		def.sparse = *index.Options.Sparse
+	}
+	if index.Options.ExpireAfterSeconds != nil {
+		def.expireAfterSeconds = canonicalBSON(*index.Options.ExpireAfterSeconds)
+	}
+	if index.Options.PartialFilterExpression != nil {
+		filter, err := toBSOND(index.Options.PartialFilterExpression)
+		if err != nil {
+			return def, errors.Wrap(err, "invalid partial filter expression")
+		}
+		def.partialFilterExpression = canonicalBSON(filter)
+	}
+	return def, nil
+}
+
+// toBSOND returns the document as a bson.D, keeping the order of its fields.
+func toBSOND(doc interface{}) (bson.D, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// canonicalBSON returns a BSON value as a string such that values MongoDB treats as the
+// same, such as numbers of different types, are the same string.
+func canonicalBSON(value interface{}) string {
+	switch v := value.(type) {
+	case int32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case int64:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case int:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case primitive.D:
+		parts := make([]string, 0, len(v))
+		for _, elem := range v {
+			parts = append(parts, elem.Key+": "+canonicalBSON(elem.Value))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case primitive.M:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, key+": "+canonicalBSON(v[key]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case primitive.A:
+		parts := make([]string, 0, len(v))
+		for _, elem := range v {
+			parts = append(parts, canonicalBSON(elem))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
 }
@@ -65,3 +65,57 @@ func TestEnsureIndexes(t *testing.T) {
 	delete(nameToIndex, "bar")
 	test.That(t, nameToIndex, test.ShouldHaveLength, 0)
 }
+
+func TestEnsureIndexesWithOptions(t *testing.T) {
+	client := testutils.BackingMongoDBClient(t)
+	dbName, collName := testutils.NewMongoDBNamespace()
+	coll := client.Database(dbName).Collection(collName)
+
+	expireAfter := int32(2)
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{"foo", 1}, {"bar", -1}}},
+		{Keys: bson.D{{"baz", 1}}, Options: options.Index().SetExpireAfterSeconds(expireAfter).SetUnique(true)},
+	}
+	diff, err := mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{}, indexes...)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Created, test.ShouldResemble, []string{"foo_1_bar_-1", "baz_1"})
+	test.That(t, diff.Empty(), test.ShouldBeFalse)
+
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{}, indexes...)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Empty(), test.ShouldBeTrue)
+
+	// indexes not expected are reported and optionally dropped
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{}, indexes[1])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Created, test.ShouldBeEmpty)
+	test.That(t, diff.Extra, test.ShouldResemble, []string{"foo_1_bar_-1"})
+	test.That(t, diff.Dropped, test.ShouldBeEmpty)
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{DropExtra: true}, indexes[1])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Dropped, test.ShouldResemble, []string{"foo_1_bar_-1"})
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{}, indexes[1])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Empty(), test.ShouldBeTrue)
+
+	// conflicting definitions fail without changing anything
+	otherExpireAfter := int32(3)
+	conflicting := []mongo.IndexModel{
+		indexes[0],
+		{Keys: bson.D{{"baz", 1}}, Options: options.Index().SetExpireAfterSeconds(otherExpireAfter).SetUnique(true)},
+	}
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{}, conflicting...)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "already exists with a different definition")
+	test.That(t, diff.Created, test.ShouldBeEmpty)
+	test.That(t, diff.Conflicting, test.ShouldHaveLength, 1)
+	test.That(t, diff.Conflicting[0].Name, test.ShouldEqual, "baz_1")
+	test.That(t, diff.Conflicting[0].Existing, test.ShouldContainSubstring, "expireAfterSeconds=2")
+	test.That(t, diff.Conflicting[0].Expected, test.ShouldContainSubstring, "expireAfterSeconds=3")
+
+	// unless only the TTL differs and it is ignored
+	diff, err = mongoutils.EnsureIndexesWithOptions(context.Background(), coll, mongoutils.EnsureIndexesOptions{IgnoreTTL: true}, conflicting...)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, diff.Created, test.ShouldResemble, []string{"foo_1_bar_-1"})
+	test.That(t, diff.Conflicting, test.ShouldBeEmpty)
+}
@@ -0,0 +1,104 @@
+package mongoutils
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrLockNotHeld is returned when renewing a lock that is not held, such as one that
+// expired and was taken by another owner.
+var ErrLockNotHeld = errors.New("lock not held")
+
+// A Lock is a lock shared by every process using the same collection and name, such as
+// the replicas of a service electing the one to run a background job. A lock expires
+// once its TTL passes without being renewed so that it is not held forever by a
+// process that went away. Expiry is judged by the clock of the MongoDB server so that
+// the clocks of owners do not matter.
+type Lock struct {
+	coll  *mongo.Collection
+	name  string
+	ttl   time.Duration
+	owner string
+}
+
+// NewLock returns a lock of the given name kept in the collection. Each returned lock is
+// its own owner, even when sharing a name with another in the same process.
+func NewLock(coll *mongo.Collection, name string, ttl time.Duration) *Lock {
+	return &Lock{
+		coll:  coll,
+		name:  name,
+		ttl:   ttl,
+		owner: primitive.NewObjectID().Hex(),
+	}
+}
+
+// Owner returns the ID the lock is held with.
+func (l *Lock) Owner() string {
+	return l.owner
+}
+
+// expireAt is an aggregation expression for when the lock expires if taken now.
+func (l *Lock) expireAt() bson.D {
+	return bson.D{{"$add", bson.A{"$$NOW", l.ttl.Milliseconds()}}}
+}
+
+// Acquire takes the lock if it is free, expired, or already held by this owner, in which
+// case it is renewed. It returns false if another owner holds the lock.
+func (l *Lock) Acquire(ctx context.Context) (bool, error) {
+	_, err := l.coll.UpdateOne(
+		ctx,
+		bson.D{
+			{"_id", l.name},
+			{"$or", bson.A{
+				bson.D{{"owner", l.owner}},
+				bson.D{{"$expr", bson.D{{"$lte", bson.A{"$expires_at", "$$NOW"}}}}},
+			}},
+		},
+		mongo.Pipeline{{{"$set", bson.D{
+			{"owner", l.owner},
+			{"expires_at", l.expireAt()},
+		}}}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		// a lock held by another owner is not matched, so an upsert of the same name is tried.
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Renew extends the lock for another TTL. It returns ErrLockNotHeld if this owner does
+// not hold the lock any more.
+func (l *Lock) Renew(ctx context.Context) error {
+	result, err := l.coll.UpdateOne(
+		ctx,
+		bson.D{
+			{"_id", l.name},
+			{"owner", l.owner},
+			{"$expr", bson.D{{"$gt", bson.A{"$expires_at", "$$NOW"}}}},
+		},
+		mongo.Pipeline{{{"$set", bson.D{{"expires_at", l.expireAt()}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Release frees the lock if this owner holds it. Releasing a lock not held does nothing.
+func (l *Lock) Release(ctx context.Context) error {
+	_, err := l.coll.DeleteOne(ctx, bson.D{{"_id", l.name}, {"owner", l.owner}})
+	return err
+}
@@ -0,0 +1,64 @@
+package mongoutils_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	mongoutils "go.viam.com/utils/mongo"
+	"go.viam.com/utils/testutils"
+)
+
+func TestLock(t *testing.T) {
+	coll := testutils.BackingMongoDBDatabase(t).Collection("locks")
+	ctx := context.Background()
+
+	lock1 := mongoutils.NewLock(coll, "foo", time.Hour)
+	lock2 := mongoutils.NewLock(coll, "foo", time.Hour)
+	test.That(t, lock1.Owner(), test.ShouldNotEqual, lock2.Owner())
+
+	acquired, err := lock1.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+
+	// acquiring again renews it
+	acquired, err = lock1.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+	test.That(t, lock1.Renew(ctx), test.ShouldBeNil)
+
+	acquired, err = lock2.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeFalse)
+	test.That(t, lock2.Renew(ctx), test.ShouldEqual, mongoutils.ErrLockNotHeld)
+
+	// locks of other names are separate
+	acquired, err = mongoutils.NewLock(coll, "bar", time.Hour).Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+
+	// only the owner may release it
+	test.That(t, lock2.Release(ctx), test.ShouldBeNil)
+	acquired, err = lock2.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeFalse)
+	test.That(t, lock1.Release(ctx), test.ShouldBeNil)
+	acquired, err = lock2.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+	test.That(t, lock1.Renew(ctx), test.ShouldEqual, mongoutils.ErrLockNotHeld)
+
+	// expired locks may be taken by others
+	shortLock1 := mongoutils.NewLock(coll, "baz", 100*time.Millisecond)
+	shortLock2 := mongoutils.NewLock(coll, "baz", 100*time.Millisecond)
+	acquired, err = shortLock1.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+	time.Sleep(200 * time.Millisecond)
+	test.That(t, shortLock1.Renew(ctx), test.ShouldEqual, mongoutils.ErrLockNotHeld)
+	acquired, err = shortLock2.Acquire(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, acquired, test.ShouldBeTrue)
+}
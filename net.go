@@ -4,6 +4,9 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
@@ -165,3 +168,57 @@ func newTLSListener(address string, config *tls.Config) (net.Listener, bool, err
 	}
 	return secureListener, true, nil
 }
+
+// UnixAddressPrefix prefixes addresses of UNIX domain sockets, as in unix:///path/to.sock.
+const UnixAddressPrefix = "unix://"
+
+// IsUnixAddress returns whether the address is of a UNIX domain socket.
+func IsUnixAddress(address string) bool {
+	return strings.HasPrefix(address, UnixAddressPrefix)
+}
+
+// UnixSocketPath returns the path of the UNIX domain socket at the given address.
+func UnixSocketPath(address string) string {
+	return strings.TrimPrefix(address, UnixAddressPrefix)
+}
+
+// ListenUnix listens on the UNIX domain socket at the given address, which is either a path
+// or prefixed with unix://. If a socket already exists at the path but nothing is listening
+// on it, as happens when a previous process did not exit cleanly, it is removed first. If
+// perm is set, the socket's permissions are changed to it so that only the intended users
+// may connect. The socket is removed once the listener is closed.
+func ListenUnix(address string, perm os.FileMode) (net.Listener, error) {
+	path := UnixSocketPath(address)
+	if err := removeStaleUnixSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if perm != 0 {
+		if err := os.Chmod(path, perm); err != nil {
+			return nil, multierr.Combine(err, listener.Close())
+		}
+	}
+	return listener, nil
+}
+
+// removeStaleUnixSocket removes the UNIX domain socket at the path if nothing is listening on it.
+func removeStaleUnixSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.Errorf("%q exists and is not a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		return multierr.Combine(errors.Errorf("socket %q is in use", path), conn.Close())
+	}
+	return os.Remove(path)
+}
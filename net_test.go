@@ -3,9 +3,13 @@ package utils_test
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -184,3 +188,39 @@ func TestNewPossiblySecureTCPListenerFromMemory(t *testing.T) {
 		<-serveDone
 	})
 }
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("UNIX domain socket permissions are not supported on windows")
+	}
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	test.That(t, utils.IsUnixAddress("unix://"+socketPath), test.ShouldBeTrue)
+	test.That(t, utils.IsUnixAddress("localhost:8080"), test.ShouldBeFalse)
+	test.That(t, utils.UnixSocketPath("unix://"+socketPath), test.ShouldEqual, socketPath)
+
+	listener, err := utils.ListenUnix("unix://"+socketPath, 0o660)
+	test.That(t, err, test.ShouldBeNil)
+	info, err := os.Stat(socketPath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, info.Mode().Perm(), test.ShouldEqual, os.FileMode(0o660))
+
+	// a socket in use is left alone
+	_, err = utils.ListenUnix(socketPath, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "in use")
+
+	// a stale socket is replaced
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	test.That(t, listener.Close(), test.ShouldBeNil)
+	listener, err = utils.ListenUnix(socketPath, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, listener.Close(), test.ShouldBeNil)
+	_, err = os.Stat(socketPath)
+	test.That(t, errors.Is(err, os.ErrNotExist), test.ShouldBeTrue)
+
+	// anything else is left alone
+	test.That(t, os.WriteFile(socketPath, nil, 0o600), test.ShouldBeNil)
+	_, err = utils.ListenUnix(socketPath, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not a socket")
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A PeriodicTaskConfig describes how often a PeriodicTask runs.
+type PeriodicTaskConfig struct {
+	// Interval is how long to wait between the starts of runs.
+	Interval time.Duration
+	// Jitter, if set, is the most each wait is randomly made shorter or longer by so that
+	// tasks started together do not run in lockstep.
+	Jitter time.Duration
+	// RunImmediately is whether the first run happens as soon as the task starts rather
+	// than after the first wait.
+	RunImmediately bool
+}
+
+// PeriodicTaskStatus describes the runs of a PeriodicTask so far.
+type PeriodicTaskStatus struct {
+	// Runs is how many runs were started.
+	Runs uint64
+	// Skipped is how many runs were skipped since the previous one was still going.
+	Skipped uint64
+	// Running is whether a run is going right now.
+	Running bool
+	// LastRun is when the last finished run started, or zero if none finished yet.
+	LastRun time.Time
+	// LastDuration is how long the last finished run took.
+	LastDuration time.Duration
+	// LastErr is what the last finished run failed with, if it did.
+	LastErr error
+}
+
+// A PeriodicTask runs a function in the background every interval until it is stopped or
+// its context is done. A run never overlaps with the one before it; if the previous run
+// is still going when the next is due, the next is skipped.
+type PeriodicTask struct {
+	config  PeriodicTaskConfig
+	fn      func(ctx context.Context) error
+	workers *StoppableWorkers
+
+	mu     sync.Mutex
+	status PeriodicTaskStatus
+}
+
+// StartPeriodicTask starts running fn according to the config until the task is stopped
+// or the context is done. The context given to fn is done once either happens.
+func StartPeriodicTask(
+	ctx context.Context,
+	config PeriodicTaskConfig,
+	fn func(ctx context.Context) error,
+) *PeriodicTask {
+	pt := &PeriodicTask{
+		config:  config,
+		fn:      fn,
+		workers: NewStoppableWorkersWithContext(ctx, Logger),
+	}
+	pt.workers.AddWorkers(pt.schedule)
+	return pt
+}
+
+// schedule starts a run every interval until the context is done.
+func (pt *PeriodicTask) schedule(ctx context.Context) {
+	if pt.config.RunImmediately {
+		pt.start()
+	}
+	for SelectContextOrWaitUntil(ctx, time.Now().Add(pt.nextWait())) {
+		pt.start()
+	}
+}
+
+// nextWait returns how long to wait until the next run, with jitter.
+func (pt *PeriodicTask) nextWait() time.Duration {
+	wait := pt.config.Interval
+	if pt.config.Jitter > 0 {
+		//nolint:gosec
+		wait += time.Duration(rand.Int63n(2*int64(pt.config.Jitter)+1)) - pt.config.Jitter
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// start starts a run unless the previous one is still going.
+func (pt *PeriodicTask) start() {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.status.Running {
+		pt.status.Skipped++
+		return
+	}
+	pt.status.Running = true
+	pt.status.Runs++
+	pt.workers.AddWorkers(pt.run)
+}
+
+func (pt *PeriodicTask) run(ctx context.Context) {
+	started := time.Now()
+	var err error
+	defer func() {
+		pt.mu.Lock()
+		defer pt.mu.Unlock()
+		pt.status.Running = false
+		pt.status.LastRun = started
+		pt.status.LastDuration = time.Since(started)
+		pt.status.LastErr = err
+	}()
+	err = pt.fn(ctx)
+}
+
+// Status returns how the runs of the task have gone so far.
+func (pt *PeriodicTask) Status() PeriodicTaskStatus {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.status
+}
+
+// Stop stops running the task and waits for a run in progress to return.
+func (pt *PeriodicTask) Stop() {
+	pt.workers.Stop()
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestPeriodicTask(t *testing.T) {
+	t.Run("runs and reports", func(t *testing.T) {
+		errRun := errors.New("whoops")
+		ran := make(chan struct{}, 10)
+		task := StartPeriodicTask(context.Background(), PeriodicTaskConfig{
+			Interval: 10 * time.Millisecond,
+			Jitter:   5 * time.Millisecond,
+		}, func(ctx context.Context) error {
+			select {
+			case ran <- struct{}{}:
+			default:
+			}
+			return errRun
+		})
+		<-ran
+		<-ran
+		task.Stop()
+		status := task.Status()
+		test.That(t, status.Runs, test.ShouldBeGreaterThanOrEqualTo, 2)
+		test.That(t, status.Running, test.ShouldBeFalse)
+		test.That(t, status.LastErr, test.ShouldEqual, errRun)
+		test.That(t, status.LastRun.IsZero(), test.ShouldBeFalse)
+	})
+
+	t.Run("skips overlapping runs", func(t *testing.T) {
+		started := make(chan struct{})
+		task := StartPeriodicTask(context.Background(), PeriodicTaskConfig{
+			Interval:       time.Millisecond,
+			RunImmediately: true,
+		}, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		<-started
+		time.Sleep(50 * time.Millisecond)
+		status := task.Status()
+		test.That(t, status.Runs, test.ShouldEqual, 1)
+		test.That(t, status.Running, test.ShouldBeTrue)
+		test.That(t, status.Skipped, test.ShouldBeGreaterThan, 0)
+
+		// stopping waits for the run in progress
+		task.Stop()
+		status = task.Status()
+		test.That(t, status.Running, test.ShouldBeFalse)
+		test.That(t, status.LastErr, test.ShouldBeError, context.Canceled)
+	})
+
+	t.Run("stops with its context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		task := StartPeriodicTask(ctx, PeriodicTaskConfig{Interval: time.Millisecond}, func(ctx context.Context) error {
+			return nil
+		})
+		task.Stop()
+		test.That(t, task.Status().Runs, test.ShouldEqual, 0)
+	})
+}
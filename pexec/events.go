@@ -0,0 +1,68 @@
+package pexec
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A ProcessEventType identifies what a ProcessEvent is about.
+type ProcessEventType string
+
+// The ProcessEventTypes reported.
+const (
+	// ProcessEventLimitExceeded is reported when a process runs into one of its resource
+	// limits, such as by being throttled for using more CPU than it may or having its
+	// memory reclaimed at its limit.
+	ProcessEventLimitExceeded ProcessEventType = "limit_exceeded"
+	// ProcessEventOOMKilled is reported when a process is killed for running out of the
+	// memory it may use.
+	ProcessEventOOMKilled ProcessEventType = "oom_killed"
+	// ProcessEventProbeFailed is reported when a process fails as many liveness probes in
+	// a row as allowed and is stopped so that its restart policy applies.
+	ProcessEventProbeFailed ProcessEventType = "probe_failed"
+)
+
+// A ProcessEvent is something that happened to a managed process.
+type ProcessEvent struct {
+	ProcessID string
+	Type      ProcessEventType
+	Time      time.Time
+	// Resource is the resource an event is about, such as "memory" or "cpu".
+	Resource string
+	// Count is how many times the event happened since it was last reported, or in a row.
+	Count uint64
+	// Err is the error that caused the event, if any.
+	Err error
+}
+
+// A ProcessEventHook is called with the events of the processes of a ProcessManager. It is
+// called synchronously from the goroutine watching the process, so it should return
+// quickly.
+type ProcessEventHook func(event ProcessEvent)
+
+// An eventReporter is a process that reports events to a hook set by its manager.
+type eventReporter interface {
+	setEventHook(hook ProcessEventHook)
+}
+
+// eventHookHolder holds the hook of an eventReporter which may be swapped at any time,
+// such as when the process moves to another manager.
+type eventHookHolder struct {
+	hook atomic.Pointer[ProcessEventHook]
+}
+
+func (h *eventHookHolder) setEventHook(hook ProcessEventHook) {
+	h.hook.Store(&hook)
+}
+
+// reportEvent passes the event to the hook, if any.
+func (h *eventHookHolder) reportEvent(event ProcessEvent) {
+	hook := h.hook.Load()
+	if hook == nil || *hook == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	(*hook)(event)
+}
@@ -0,0 +1,213 @@
+package pexec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"go.viam.com/utils"
+)
+
+// defaultLogBufferLines is how many lines of output are kept for a process by default.
+const defaultLogBufferLines = 1000
+
+// logSubscriptionBuffer is how many lines a log subscriber can fall behind by before
+// lines are dropped for it.
+const logSubscriptionBuffer = 256
+
+// The streams of output of a process.
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+)
+
+// A LogLine is a line of output of a process.
+type LogLine struct {
+	Time time.Time
+	// Stream is either LogStreamStdout or LogStreamStderr.
+	Stream string
+	Line   string
+}
+
+// A LoggingProcess is a managed process whose recent output can be retrieved and
+// followed. The output is kept even if it is neither logged nor written anywhere.
+type LoggingProcess interface {
+	ManagedProcess
+
+	// TailLogs returns up to the last n lines of output of the process, or all that are
+	// kept if n is not positive, oldest first.
+	TailLogs(ctx context.Context, n int) ([]LogLine, error)
+
+	// SubscribeLogs returns a channel receiving each line of output of the process from
+	// now on. The channel is closed when the context is done or the process is stopped.
+	// Lines are dropped for subscribers that fall too far behind rather than holding up
+	// the process.
+	SubscribeLogs(ctx context.Context) (<-chan LogLine, error)
+}
+
+// A logBuffer is a ring buffer of the most recent lines of output of a process which
+// also forwards new lines to subscribers.
+type logBuffer struct {
+	mu          sync.Mutex
+	lines       []LogLine
+	next        int
+	full        bool
+	closed      chan struct{}
+	subscribers map[chan LogLine]struct{}
+}
+
+// newLogBuffer returns a buffer keeping the given number of lines. It returns nil, which
+// keeps nothing, if the number is negative.
+func newLogBuffer(size int) *logBuffer {
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		size = defaultLogBufferLines
+	}
+	return &logBuffer{
+		lines:       make([]LogLine, size),
+		closed:      make(chan struct{}),
+		subscribers: map[chan LogLine]struct{}{},
+	}
+}
+
+// add keeps the line and sends it to all subscribers.
+func (buf *logBuffer) add(line LogLine) {
+	if buf == nil {
+		return
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.lines[buf.next] = line
+	buf.next = (buf.next + 1) % len(buf.lines)
+	if buf.next == 0 {
+		buf.full = true
+	}
+	for sub := range buf.subscribers {
+		select {
+		case sub <- line:
+		default:
+		}
+	}
+}
+
+// tail returns up to the last n lines kept, or all of them if n is not positive.
+func (buf *logBuffer) tail(n int) []LogLine {
+	if buf == nil {
+		return nil
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	count := buf.next
+	if buf.full {
+		count = len(buf.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	tail := make([]LogLine, 0, n)
+	for i := buf.next - n; i < buf.next; i++ {
+		tail = append(tail, buf.lines[(i+len(buf.lines))%len(buf.lines)])
+	}
+	return tail
+}
+
+// subscribe returns a channel receiving lines added from now on until the context is done
+// or the buffer is closed.
+func (buf *logBuffer) subscribe(ctx context.Context) <-chan LogLine {
+	sub := make(chan LogLine, logSubscriptionBuffer)
+	if buf == nil {
+		close(sub)
+		return sub
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	select {
+	case <-buf.closed:
+		close(sub)
+		return sub
+	default:
+	}
+	buf.subscribers[sub] = struct{}{}
+	utils.PanicCapturingGo(func() {
+		select {
+		case <-ctx.Done():
+			buf.unsubscribe(sub)
+		case <-buf.closed:
+		}
+	})
+	return sub
+}
+
+func (buf *logBuffer) unsubscribe(sub chan LogLine) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if _, ok := buf.subscribers[sub]; ok {
+		delete(buf.subscribers, sub)
+		close(sub)
+	}
+}
+
+// close closes the channels of all subscribers, and of any that subscribe later.
+func (buf *logBuffer) close() {
+	if buf == nil {
+		return
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	select {
+	case <-buf.closed:
+		return
+	default:
+	}
+	close(buf.closed)
+	for sub := range buf.subscribers {
+		delete(buf.subscribers, sub)
+		close(sub)
+	}
+}
+
+// streamWriter adds the lines written to it to a logBuffer, for processes whose output is
+// not read line by line.
+type streamWriter struct {
+	buf     *logBuffer
+	stream  string
+	partial []byte
+
+	// output, if set, also receives everything written. It is guarded by outputMu, which
+	// is shared by the writers of all streams of a process.
+	output   io.Writer
+	outputMu *sync.Mutex
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	if w.output != nil {
+		w.outputMu.Lock()
+		_, err := w.output.Write(data)
+		w.outputMu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	w.partial = append(w.partial, data...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx == -1 {
+			break
+		}
+		w.buf.add(LogLine{Time: time.Now(), Stream: w.stream, Line: string(bytes.TrimSuffix(w.partial[:idx], []byte("\r")))})
+		w.partial = w.partial[idx+1:]
+	}
+	return len(data), nil
+}
+
+// flush adds what is left of an unterminated last line.
+func (w *streamWriter) flush() {
+	if len(w.partial) != 0 {
+		w.buf.add(LogLine{Time: time.Now(), Stream: w.stream, Line: string(w.partial)})
+		w.partial = nil
+	}
+}
@@ -0,0 +1,79 @@
+package pexec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestLogBuffer(t *testing.T) {
+	lineTexts := func(lines []LogLine) []string {
+		texts := make([]string, 0, len(lines))
+		for _, line := range lines {
+			texts = append(texts, line.Line)
+		}
+		return texts
+	}
+
+	t.Run("keeps the most recent lines", func(t *testing.T) {
+		buf := newLogBuffer(3)
+		test.That(t, buf.tail(0), test.ShouldBeEmpty)
+		buf.add(LogLine{Line: "1"})
+		buf.add(LogLine{Line: "2"})
+		test.That(t, lineTexts(buf.tail(0)), test.ShouldResemble, []string{"1", "2"})
+		test.That(t, lineTexts(buf.tail(5)), test.ShouldResemble, []string{"1", "2"})
+		for i := 3; i <= 7; i++ {
+			buf.add(LogLine{Line: fmt.Sprint(i)})
+		}
+		test.That(t, lineTexts(buf.tail(0)), test.ShouldResemble, []string{"5", "6", "7"})
+		test.That(t, lineTexts(buf.tail(2)), test.ShouldResemble, []string{"6", "7"})
+
+		test.That(t, newLogBuffer(0).lines, test.ShouldHaveLength, defaultLogBufferLines)
+		test.That(t, newLogBuffer(-1), test.ShouldBeNil)
+	})
+
+	t.Run("subscriptions", func(t *testing.T) {
+		buf := newLogBuffer(3)
+		buf.add(LogLine{Line: "before"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := buf.subscribe(ctx)
+		open := buf.subscribe(context.Background())
+		buf.add(LogLine{Line: "1"})
+		test.That(t, (<-canceled).Line, test.ShouldEqual, "1")
+		test.That(t, (<-open).Line, test.ShouldEqual, "1")
+
+		cancel()
+		_, ok := <-canceled
+		test.That(t, ok, test.ShouldBeFalse)
+
+		// lines are dropped rather than blocking on subscribers that fall behind.
+		for i := 0; i < logSubscriptionBuffer+10; i++ {
+			buf.add(LogLine{Line: fmt.Sprint(i)})
+		}
+		test.That(t, open, test.ShouldHaveLength, logSubscriptionBuffer)
+
+		buf.close()
+		for range open {
+		}
+		_, ok = <-buf.subscribe(context.Background())
+		test.That(t, ok, test.ShouldBeFalse)
+	})
+
+	t.Run("stream writer", func(t *testing.T) {
+		buf := newLogBuffer(5)
+		w := &streamWriter{buf: buf, stream: LogStreamStderr}
+		_, err := w.Write([]byte("one\r\ntw"))
+		test.That(t, err, test.ShouldBeNil)
+		_, err = w.Write([]byte("o\nthree"))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, lineTexts(buf.tail(0)), test.ShouldResemble, []string{"one", "two"})
+		w.flush()
+		lines := buf.tail(0)
+		test.That(t, lineTexts(lines), test.ShouldResemble, []string{"one", "two", "three"})
+		test.That(t, lines[2].Stream, test.ShouldEqual, LogStreamStderr)
+		test.That(t, lines[2].Time.IsZero(), test.ShouldBeFalse)
+	})
+}
@@ -2,6 +2,7 @@ package pexec
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -35,7 +36,12 @@ type ManagedProcess interface {
 }
 
 // NewManagedProcess returns a new, unstarted, from the given configuration.
+// Processes with a Schedule are returned as a ScheduledProcess.
 func NewManagedProcess(config ProcessConfig, logger golog.Logger) ManagedProcess {
+	if config.Schedule != "" {
+		return newScheduledProcess(config, logger)
+	}
+	original := config
 	logger = logger.Named(fmt.Sprintf("process.%s_%s", config.ID, config.Name))
 
 	if config.StopSignal == 0 {
@@ -46,6 +52,19 @@ func NewManagedProcess(config ProcessConfig, logger golog.Logger) ManagedProcess
 		config.StopTimeout = defaultStopTimeout
 	}
 
+	if config.RestartPolicy == "" {
+		config.RestartPolicy = RestartPolicyAlways
+	}
+	if config.RestartDelay == 0 {
+		config.RestartDelay = defaultRestartDelay
+	}
+	if config.MaxRestartDelay == 0 {
+		config.MaxRestartDelay = defaultMaxRestartDelay
+	}
+	if config.RestartDelay > config.MaxRestartDelay {
+		config.MaxRestartDelay = config.RestartDelay
+	}
+
 	// From os/exec/exec.go:
 	//  If Env contains duplicate environment keys, only the last
 	//  value in the slice for each duplicate key is used.
@@ -55,6 +74,7 @@ func NewManagedProcess(config ProcessConfig, logger golog.Logger) ManagedProcess
 	}
 
 	return &managedProcess{
+		config:           original,
 		id:               config.ID,
 		name:             config.Name,
 		args:             config.Args,
@@ -68,14 +88,23 @@ func NewManagedProcess(config ProcessConfig, logger golog.Logger) ManagedProcess
 		killCh:           make(chan struct{}),
 		stopSig:          config.StopSignal,
 		stopWaitInterval: config.StopTimeout / time.Duration(3),
+		order:            config.StopOrder,
+		restartPolicy:    config.RestartPolicy,
+		restartDelay:     config.RestartDelay,
+		maxRestartDelay:  config.MaxRestartDelay,
 		logger:           logger,
 		logWriter:        config.LogWriter,
+		logPrefix:        config.LogPrefix,
+		resources:        config.Resources,
+		logs:             newLogBuffer(config.LogBufferLines),
+		livenessProbe:    config.LivenessProbe,
 	}
 }
 
 type managedProcess struct {
 	mu sync.Mutex
 
+	config    ProcessConfig
 	id        string
 	name      string
 	args      []string
@@ -92,10 +121,28 @@ type managedProcess struct {
 	killCh           chan struct{}
 	stopSig          syscall.Signal
 	stopWaitInterval time.Duration
+	order            int
 	lastWaitErr      error
 
+	restartPolicy   RestartPolicy
+	restartDelay    time.Duration
+	maxRestartDelay time.Duration
+	// nextRestartDelay and startedAt are only used by the manage goroutine, of which
+	// there is at most one at a time.
+	nextRestartDelay time.Duration
+	startedAt        time.Time
+
+	resources ResourceLimits
+	eventHookHolder
+
+	livenessProbe *ProbeConfig
+	proberMu      sync.Mutex
+	prober        *prober
+
 	logger    golog.Logger
 	logWriter io.Writer
+	logPrefix string
+	logs      *logBuffer
 }
 
 func (p *managedProcess) ID() string {
@@ -132,24 +179,45 @@ func (p *managedProcess) Start(ctx context.Context) error {
 		}
 		cmd.Env = p.env
 		cmd.Dir = p.cwd
-		var runErr error
-		if p.shouldLog || p.logWriter != nil {
-			out, err := cmd.CombinedOutput()
-			if len(out) > 0 {
+		var out bytes.Buffer
+		shouldCaptureOutput := p.shouldLog || p.logWriter != nil
+		var outMu sync.Mutex
+		stdOut := &streamWriter{buf: p.logs, stream: LogStreamStdout}
+		stdErr := &streamWriter{buf: p.logs, stream: LogStreamStderr}
+		if shouldCaptureOutput {
+			stdOut.output, stdOut.outputMu = &out, &outMu
+			stdErr.output, stdErr.outputMu = &out, &outMu
+		}
+		if shouldCaptureOutput || p.logs != nil {
+			cmd.Stdout = stdOut
+			cmd.Stderr = stdErr
+		}
+		runErr := cmd.Start()
+		if runErr == nil {
+			var releaseLimits func()
+			releaseLimits, runErr = p.applyResourceLimits(cmd.Process.Pid)
+			if runErr != nil {
+				utils.UncheckedError(cmd.Process.Kill())
+				utils.UncheckedError(cmd.Wait())
+			} else {
+				runErr = cmd.Wait()
+				releaseLimits()
+			}
+		}
+		stdOut.flush()
+		stdErr.flush()
+		if shouldCaptureOutput {
+			output := p.prefixLines(out.Bytes())
+			if len(output) > 0 {
 				if p.shouldLog {
-					p.logger.Debugw("process output", "name", p.name, "output", string(out))
+					p.logger.Debugw("process output", "name", p.name, "output", string(output))
 				}
 				if p.logWriter != nil {
-					if _, err := p.logWriter.Write(out); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+					if _, err := p.logWriter.Write(output); err != nil && !errors.Is(err, io.ErrClosedPipe) {
 						p.logger.Errorw("error writing process output to log writer", "name", p.name, "error", err)
 					}
 				}
 			}
-			if err != nil {
-				runErr = err
-			}
-		} else {
-			runErr = cmd.Run()
 		}
 		if runErr == nil {
 			return nil
@@ -169,7 +237,7 @@ func (p *managedProcess) Start(ctx context.Context) error {
 	cmd.Dir = p.cwd
 
 	var stdOut, stdErr io.ReadCloser
-	if p.shouldLog || p.logWriter != nil {
+	if p.shouldLog || p.logWriter != nil || p.logs != nil {
 		var err error
 		stdOut, err = cmd.StdoutPipe()
 		if err != nil {
@@ -183,14 +251,30 @@ func (p *managedProcess) Start(ctx context.Context) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
+	releaseLimits, err := p.applyResourceLimits(cmd.Process.Pid)
+	if err != nil {
+		utils.UncheckedError(cmd.Process.Kill())
+		utils.UncheckedError(cmd.Wait())
+		return err
+	}
 	// We have the lock here so it's okay to:
 	// 1. Unset the old command, if there was one and let it be GC'd.
 	// 2. Assign a new command to be referenced in other places.
 	p.cmd = cmd
+	p.startedAt = time.Now()
+
+	onExit := releaseLimits
+	if p.livenessProbe != nil {
+		stopProbing := p.startProbing(cmd)
+		onExit = func() {
+			stopProbing()
+			releaseLimits()
+		}
+	}
 
 	// It's okay to not wait for management to start.
 	utils.ManagedGo(func() {
-		p.manage(stdOut, stdErr)
+		p.manage(stdOut, stdErr, onExit)
 	}, nil)
 	return nil
 }
@@ -202,7 +286,7 @@ func (p *managedProcess) Start(ctx context.Context) error {
 // a restart to be in progress while a Stop is happening. As a means of
 // simplifying implementation, a restart spawns new goroutines by calling Start
 // again and lets the original goroutine die off.
-func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
+func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser, onExit func()) {
 	// If no restart is going to happen after this function exits,
 	// then we want to notify anyone listening that this process
 	// is done being managed. We assume that if we aren't managing,
@@ -218,7 +302,7 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 	// pipes are closed.
 	stopLogging := make(chan struct{})
 	var activeLoggers sync.WaitGroup
-	if p.shouldLog || p.logWriter != nil {
+	if stdOut != nil {
 		logPipe := func(name string, pipe io.ReadCloser, isErr bool) {
 			logger := p.logger.Named(name)
 			defer activeLoggers.Done()
@@ -237,6 +321,14 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 					}
 					return
 				}
+				stream := LogStreamStdout
+				if isErr {
+					stream = LogStreamStderr
+				}
+				p.logs.add(LogLine{Time: time.Now(), Stream: stream, Line: string(line)})
+				if p.logPrefix != "" {
+					line = append([]byte(p.logPrefix), line...)
+				}
 				if p.shouldLog {
 					if isErr {
 						logger.Error("\n\\_ " + string(line))
@@ -253,7 +345,7 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 						if !errors.Is(err, io.ErrClosedPipe) {
 							p.logger.Debugw("error writing process output to log writer", "name", name, "error", err)
 						}
-						if !p.shouldLog {
+						if !p.shouldLog && p.logs == nil {
 							return
 						}
 						logWriterError = true
@@ -271,6 +363,7 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 	}
 
 	err := p.cmd.Wait()
+	onExit()
 	// This is safe to write to because it is only read in Stop which
 	// is waiting for us to stop managing.
 	if err == nil {
@@ -288,6 +381,11 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 	default:
 	}
 
+	if !p.shouldRestart(err) {
+		p.logger.Infow("process exited; not restarting", "restart_policy", p.restartPolicy, "state", p.cmd.ProcessState)
+		return
+	}
+
 	// Run onUnexpectedExit if it exists. Do not attempt restart if
 	// onUnexpectedExit returns false.
 	if p.onUnexpectedExit != nil &&
@@ -309,26 +407,160 @@ func (p *managedProcess) manage(stdOut, stdErr io.ReadCloser) {
 	} else {
 		p.logger.Infow("process exited before expected", "state", p.cmd.ProcessState)
 	}
-	p.logger.Info("restarting process")
 
-	// Temper ourselves so we aren't constantly restarting if we immediately fail.
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-	select {
-	case <-ticker.C:
-	case <-p.killCh:
-		return
+	// Back off exponentially between restarts of a process that keeps failing, but restart
+	// a process that stayed up for a while promptly again.
+	if p.nextRestartDelay == 0 || time.Since(p.startedAt) >= p.maxRestartDelay {
+		p.nextRestartDelay = p.restartDelay
 	}
+	for {
+		// Temper ourselves so we aren't constantly restarting if we immediately fail.
+		p.logger.Infow("restarting process", "delay", p.nextRestartDelay)
+		if !p.waitToRestart(p.nextRestartDelay) {
+			return
+		}
+		p.nextRestartDelay *= 2
+		if p.nextRestartDelay > p.maxRestartDelay {
+			p.nextRestartDelay = p.maxRestartDelay
+		}
 
-	err = p.Start(context.Background())
-	if err != nil {
-		if !errors.Is(err, errAlreadyStopped) {
-			// MAYBE(erd): add retry
-			p.logger.Errorw("error restarting process", "error", err)
+		err = p.Start(context.Background())
+		if err == nil {
+			restarted = true
+			return
+		}
+		if errors.Is(err, errAlreadyStopped) {
+			return
 		}
+		p.logger.Errorw("error restarting process", "error", err)
+	}
+}
+
+// prefixLines prepends the log prefix, if any, to each line of the output.
+func (p *managedProcess) prefixLines(out []byte) []byte {
+	if p.logPrefix == "" || len(out) == 0 {
+		return out
+	}
+	lines := bytes.SplitAfter(out, []byte("\n"))
+	var prefixed bytes.Buffer
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		prefixed.WriteString(p.logPrefix)
+		prefixed.Write(line)
+	}
+	return prefixed.Bytes()
+}
+
+func (p *managedProcess) TailLogs(ctx context.Context, n int) ([]LogLine, error) {
+	if p.logs == nil {
+		return nil, errors.New("output of process is not kept")
+	}
+	return p.logs.tail(n), nil
+}
+
+func (p *managedProcess) SubscribeLogs(ctx context.Context) (<-chan LogLine, error) {
+	if p.logs == nil {
+		return nil, errors.New("output of process is not kept")
+	}
+	return p.logs.subscribe(ctx), nil
+}
+
+// startProbing probes the liveness of the given run of the process until the returned
+// function is called once it has exited. A run failing the probe is stopped, after which
+// the restart policy applies as if it had exited on its own.
+func (p *managedProcess) startProbing(cmd *exec.Cmd) func() {
+	pr := newProber(*p.livenessProbe, p.env, p.cwd)
+	p.proberMu.Lock()
+	p.prober = pr
+	p.proberMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	var activeProbers sync.WaitGroup
+	activeProbers.Add(1)
+	utils.ManagedGo(func() {
+		pr.run(ctx, func(failures int, err error) {
+			p.logger.Warnw("process failed liveness probe; stopping it", "failures", failures, "error", err)
+			p.reportEvent(ProcessEvent{ProcessID: p.id, Type: ProcessEventProbeFailed, Count: uint64(failures), Err: err})
+			p.stopUnhealthy(cmd, exited)
+		})
+	}, activeProbers.Done)
+
+	return func() {
+		close(exited)
+		cancel()
+		activeProbers.Wait()
+	}
+}
+
+// stopUnhealthy signals the given run of the process to stop and kills it if it does not
+// exit in time.
+func (p *managedProcess) stopUnhealthy(cmd *exec.Cmd, exited <-chan struct{}) {
+	if err := cmd.Process.Signal(p.stopSig); err != nil {
+		utils.UncheckedError(cmd.Process.Kill())
 		return
 	}
-	restarted = true
+	timer := time.NewTimer(p.stopWaitInterval * 3)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		p.logger.Infof("killing unhealthy process %d", cmd.Process.Pid)
+		utils.UncheckedError(cmd.Process.Kill())
+	}
+}
+
+// livenessStatus returns the status of the liveness probe of the current run of the
+// process, if it has a probe.
+func (p *managedProcess) livenessStatus() (ProbeStatus, bool) {
+	if p.livenessProbe == nil {
+		return ProbeStatus{}, false
+	}
+	p.proberMu.Lock()
+	pr := p.prober
+	p.proberMu.Unlock()
+	if pr == nil {
+		return ProbeStatus{Healthy: true}, true
+	}
+	return pr.currentStatus(), true
+}
+
+func (p *managedProcess) processConfig() ProcessConfig {
+	return p.config
+}
+
+func (p *managedProcess) stopOrder() int {
+	return p.order
+}
+
+// shouldRestart returns whether the restart policy calls for restarting the process
+// after it exited with the given wait error.
+func (p *managedProcess) shouldRestart(waitErr error) bool {
+	switch p.restartPolicy {
+	case RestartPolicyNever:
+		return false
+	case RestartPolicyOnFailure:
+		return waitErr != nil || !p.cmd.ProcessState.Success()
+	case RestartPolicyAlways:
+		fallthrough
+	default:
+		return true
+	}
+}
+
+// waitToRestart waits for the given delay and returns false if the process was stopped
+// in the meantime.
+func (p *managedProcess) waitToRestart(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-p.killCh:
+		return false
+	}
 }
 
 func (p *managedProcess) Stop() error {
@@ -343,6 +575,7 @@ func (p *managedProcess) Stop() error {
 	}
 	close(p.killCh)
 	p.stopped = true
+	p.logs.close()
 
 	if p.cmd == nil {
 		p.mu.Unlock()
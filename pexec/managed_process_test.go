@@ -282,6 +282,57 @@ func TestManagedProcessManage(t *testing.T) {
 			test.That(t, err.Error(), test.ShouldContainSubstring, "exit status 1")
 		}
 	})
+	t.Run("restart policies", func(t *testing.T) {
+		for _, tc := range []struct {
+			policy   RestartPolicy
+			exitCode int
+			restarts bool
+		}{
+			{RestartPolicyNever, 1, false},
+			{RestartPolicyOnFailure, 0, false},
+			{RestartPolicyOnFailure, 1, true},
+			{RestartPolicyAlways, 0, true},
+		} {
+			t.Run(fmt.Sprintf("%s exit=%d", tc.policy, tc.exitCode), func(t *testing.T) {
+				logger := golog.NewTestLogger(t)
+
+				tempFile := testutils.TempFile(t, "something.txt")
+				defer tempFile.Close()
+
+				proc := NewManagedProcess(ProcessConfig{
+					Name:          "bash",
+					Args:          []string{"-c", fmt.Sprintf("echo hello >> '%s'\nexit %d", tempFile.Name(), tc.exitCode)},
+					RestartPolicy: tc.policy,
+					RestartDelay:  10 * time.Millisecond,
+				}, logger)
+				test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+
+				if tc.restarts {
+					testutils.WaitForAssertion(t, func(tb testing.TB) {
+						tb.Helper()
+						rd, err := os.ReadFile(tempFile.Name())
+						test.That(tb, err, test.ShouldBeNil)
+						test.That(tb, strings.Count(string(rd), "hello"), test.ShouldBeGreaterThanOrEqualTo, 3)
+					})
+				} else {
+					select {
+					case <-proc.(*managedProcess).managingCh:
+					case <-time.After(5 * time.Second):
+						t.Fatal("process should have stopped being managed")
+					}
+					rd, err := os.ReadFile(tempFile.Name())
+					test.That(t, err, test.ShouldBeNil)
+					test.That(t, string(rd), test.ShouldEqual, "hello\n")
+				}
+
+				err := proc.Stop()
+				// sometimes we simply cannot get the status
+				if err != nil {
+					test.That(t, err.Error(), test.ShouldContainSubstring, "exit")
+				}
+			})
+		}
+	})
 }
 
 func TestManagedProcessStop(t *testing.T) {
@@ -672,6 +723,106 @@ func TestManagedProcessLogWriter(t *testing.T) {
 		}
 		test.That(t, proc.Stop(), test.ShouldBeNil)
 	})
+
+	t.Run("Prefix log lines", func(t *testing.T) {
+		for _, oneShot := range []bool{true, false} {
+			t.Run(fmt.Sprintf("one_shot=%t", oneShot), func(t *testing.T) {
+				logger := golog.NewTestLogger(t)
+				logReader, logWriter := io.Pipe()
+				proc := NewManagedProcess(ProcessConfig{
+					Name:      "bash",
+					Args:      []string{"-c", "echo hello; echo world"},
+					OneShot:   oneShot,
+					LogWriter: logWriter,
+					LogPrefix: "[greeter] ",
+					// only read the output of the first run.
+					RestartPolicy: RestartPolicyNever,
+				}, logger)
+				var activeReaders sync.WaitGroup
+				activeReaders.Add(1)
+				utils.PanicCapturingGo(func() {
+					defer activeReaders.Done()
+					bufferedLogReader := bufio.NewReader(logReader)
+					for _, expected := range []string{"[greeter] hello\n", "[greeter] world\n"} {
+						line, err := bufferedLogReader.ReadString('\n')
+						test.That(t, err, test.ShouldBeNil)
+						test.That(t, line, test.ShouldEqual, expected)
+					}
+				})
+				test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+				activeReaders.Wait()
+				test.That(t, proc.Stop(), test.ShouldBeNil)
+			})
+		}
+	})
+}
+
+func TestManagedProcessTailLogs(t *testing.T) {
+	for _, oneShot := range []bool{true, false} {
+		t.Run(fmt.Sprintf("one_shot=%t", oneShot), func(t *testing.T) {
+			logger := golog.NewTestLogger(t)
+			proc := NewManagedProcess(ProcessConfig{
+				Name:          "bash",
+				Args:          []string{"-c", "echo one; echo two >&2; echo three"},
+				OneShot:       oneShot,
+				RestartPolicy: RestartPolicyNever,
+			}, logger).(LoggingProcess)
+			test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+
+			testutils.WaitForAssertion(t, func(tb testing.TB) {
+				tb.Helper()
+				lines, err := proc.TailLogs(context.Background(), 0)
+				test.That(tb, err, test.ShouldBeNil)
+				test.That(tb, lines, test.ShouldHaveLength, 3)
+			})
+			lines, err := proc.TailLogs(context.Background(), 2)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, lines, test.ShouldHaveLength, 2)
+			byStream := map[string]string{}
+			for _, line := range lines {
+				byStream[line.Stream] += line.Line
+			}
+			test.That(t, byStream[LogStreamStderr], test.ShouldEqual, "two")
+
+			test.That(t, proc.Stop(), test.ShouldBeNil)
+		})
+	}
+
+	t.Run("subscribe", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		proc := NewManagedProcess(ProcessConfig{
+			Name: "bash",
+			Args: []string{"-c", "while true; do echo hello; sleep 0.1; done"},
+		}, logger).(LoggingProcess)
+		sub, err := proc.SubscribeLogs(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			line := <-sub
+			test.That(t, line.Line, test.ShouldEqual, "hello")
+			test.That(t, line.Stream, test.ShouldEqual, LogStreamStdout)
+		}
+
+		// stopping the process ends subscriptions.
+		test.That(t, proc.Stop(), test.ShouldBeNil)
+		for range sub {
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		proc := NewManagedProcess(ProcessConfig{
+			Name:           "bash",
+			Args:           []string{"-c", "echo hello"},
+			OneShot:        true,
+			LogBufferLines: -1,
+		}, logger).(LoggingProcess)
+		test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+		_, err := proc.TailLogs(context.Background(), 0)
+		test.That(t, err, test.ShouldNotBeNil)
+		_, err = proc.SubscribeLogs(context.Background())
+		test.That(t, err, test.ShouldNotBeNil)
+	})
 }
 
 type fakeProcess struct {
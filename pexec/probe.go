@@ -0,0 +1,252 @@
+package pexec
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+// Defaults for probing processes.
+const (
+	defaultProbeInterval         = 10 * time.Second
+	defaultProbeTimeout          = time.Second
+	defaultProbeFailureThreshold = 3
+)
+
+// A ProbeConfig describes how to check that a process is healthy. Exactly one of Exec,
+// TCPAddress, and HTTPURL must be set.
+type ProbeConfig struct {
+	// Exec is a command, followed by its arguments, that must exit successfully. It runs
+	// with the environment and working directory of the process.
+	Exec []string
+	// TCPAddress is an address that must accept TCP connections.
+	TCPAddress string
+	// HTTPURL is a URL that must respond to a GET request with a 2xx or 3xx status.
+	HTTPURL string
+
+	// InitialDelay is how long to wait after the process starts before first probing it.
+	InitialDelay time.Duration
+	// Interval is how often to probe the process. Defaults to 10 seconds.
+	Interval time.Duration
+	// Timeout is how long a probe may take before it fails. Defaults to 1 second.
+	Timeout time.Duration
+	// FailureThreshold is how many probes in a row must fail for the process to be
+	// considered unhealthy. Defaults to 3.
+	FailureThreshold int
+}
+
+func (config ProbeConfig) validate() error {
+	var kinds int
+	for _, set := range []bool{len(config.Exec) != 0, config.TCPAddress != "", config.HTTPURL != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("liveness_probe should have exactly one of exec, tcp_address, and http_url")
+	}
+	if config.InitialDelay < 0 || config.Interval < 0 || config.Timeout < 0 || config.FailureThreshold < 0 {
+		return errors.New("liveness_probe durations and failure_threshold should not be negative")
+	}
+	return nil
+}
+
+func (config ProbeConfig) withDefaults() ProbeConfig {
+	if config.Interval == 0 {
+		config.Interval = defaultProbeInterval
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultProbeTimeout
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = defaultProbeFailureThreshold
+	}
+	return config
+}
+
+// Note: keep this in sync with json-supported fields in ProbeConfig.
+type probeConfigData struct {
+	Exec             []string `json:"exec,omitempty"`
+	TCPAddress       string   `json:"tcp_address,omitempty"`
+	HTTPURL          string   `json:"http_url,omitempty"`
+	InitialDelay     string   `json:"initial_delay,omitempty"`
+	Interval         string   `json:"interval,omitempty"`
+	Timeout          string   `json:"timeout,omitempty"`
+	FailureThreshold int      `json:"failure_threshold,omitempty"`
+}
+
+// UnmarshalJSON parses incoming json.
+func (config *ProbeConfig) UnmarshalJSON(data []byte) error {
+	var temp probeConfigData
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	*config = ProbeConfig{
+		Exec:             temp.Exec,
+		TCPAddress:       temp.TCPAddress,
+		HTTPURL:          temp.HTTPURL,
+		FailureThreshold: temp.FailureThreshold,
+	}
+	for _, dur := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{temp.InitialDelay, &config.InitialDelay},
+		{temp.Interval, &config.Interval},
+		{temp.Timeout, &config.Timeout},
+	} {
+		if dur.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(dur.value)
+		if err != nil {
+			return err
+		}
+		*dur.dst = parsed
+	}
+	return nil
+}
+
+// MarshalJSON converts to json.
+func (config ProbeConfig) MarshalJSON() ([]byte, error) {
+	temp := probeConfigData{
+		Exec:             config.Exec,
+		TCPAddress:       config.TCPAddress,
+		HTTPURL:          config.HTTPURL,
+		FailureThreshold: config.FailureThreshold,
+	}
+	for _, dur := range []struct {
+		value time.Duration
+		dst   *string
+	}{
+		{config.InitialDelay, &temp.InitialDelay},
+		{config.Interval, &temp.Interval},
+		{config.Timeout, &temp.Timeout},
+	} {
+		if dur.value != 0 {
+			*dur.dst = dur.value.String()
+		}
+	}
+	return json.Marshal(temp)
+}
+
+// ProbeStatus describes the results of probing a process.
+type ProbeStatus struct {
+	// Healthy is whether the process is considered healthy, which it is until as many
+	// probes as the failure threshold fail in a row.
+	Healthy bool
+	// ConsecutiveFailures is how many of the latest probes failed in a row.
+	ConsecutiveFailures int
+	// LastProbe is when the process was last probed, or zero if it was not yet.
+	LastProbe time.Time
+	// LastErr is why the last probe failed, if it did.
+	LastErr error
+}
+
+// A prober probes a single run of a process until it exits.
+type prober struct {
+	config ProbeConfig
+	env    []string
+	cwd    string
+
+	mu     sync.Mutex
+	status ProbeStatus
+}
+
+func newProber(config ProbeConfig, env []string, cwd string) *prober {
+	return &prober{
+		config: config.withDefaults(),
+		env:    env,
+		cwd:    cwd,
+		status: ProbeStatus{Healthy: true},
+	}
+}
+
+func (pr *prober) currentStatus() ProbeStatus {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return pr.status
+}
+
+// run probes at the configured interval until the context is done or the failure
+// threshold is reached, in which case onUnhealthy is called with the last error.
+func (pr *prober) run(ctx context.Context, onUnhealthy func(failures int, err error)) {
+	delay := pr.config.InitialDelay
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = pr.config.Interval
+
+		err := pr.probe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		pr.mu.Lock()
+		pr.status.LastProbe = time.Now()
+		pr.status.LastErr = err
+		if err == nil {
+			pr.status.ConsecutiveFailures = 0
+			pr.status.Healthy = true
+		} else {
+			pr.status.ConsecutiveFailures++
+		}
+		failures := pr.status.ConsecutiveFailures
+		unhealthy := failures >= pr.config.FailureThreshold
+		if unhealthy {
+			pr.status.Healthy = false
+		}
+		pr.mu.Unlock()
+		if unhealthy {
+			onUnhealthy(failures, err)
+			return
+		}
+	}
+}
+
+// probe checks the process once.
+func (pr *prober) probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pr.config.Timeout)
+	defer cancel()
+	switch {
+	case len(pr.config.Exec) != 0:
+		//nolint:gosec
+		cmd := exec.CommandContext(ctx, pr.config.Exec[0], pr.config.Exec[1:]...)
+		cmd.Env = pr.env
+		cmd.Dir = pr.cwd
+		return cmd.Run()
+	case pr.config.TCPAddress != "":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", pr.config.TCPAddress)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pr.config.HTTPURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer utils.UncheckedErrorFunc(resp.Body.Close)
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return errors.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+}
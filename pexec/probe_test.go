@@ -0,0 +1,118 @@
+package pexec
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/testutils"
+)
+
+func TestProbe(t *testing.T) {
+	probe := func(config ProbeConfig) error {
+		return newProber(config, os.Environ(), "").probe(context.Background())
+	}
+
+	t.Run("exec", func(t *testing.T) {
+		test.That(t, probe(ProbeConfig{Exec: []string{"true"}}), test.ShouldBeNil)
+		test.That(t, probe(ProbeConfig{Exec: []string{"false"}}), test.ShouldNotBeNil)
+		err := probe(ProbeConfig{Exec: []string{"sleep", "1"}, Timeout: 10 * time.Millisecond})
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		addr := listener.Addr().String()
+		test.That(t, probe(ProbeConfig{TCPAddress: addr}), test.ShouldBeNil)
+		test.That(t, listener.Close(), test.ShouldBeNil)
+		test.That(t, probe(ProbeConfig{TCPAddress: addr}), test.ShouldNotBeNil)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/healthz" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer httpServer.Close()
+		test.That(t, probe(ProbeConfig{HTTPURL: httpServer.URL + "/healthz"}), test.ShouldBeNil)
+		err := probe(ProbeConfig{HTTPURL: httpServer.URL + "/other"})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "503")
+	})
+}
+
+func TestManagedProcessLivenessProbe(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	tempDir := t.TempDir()
+	healthFile := filepath.Join(tempDir, "healthy")
+	startsFile := filepath.Join(tempDir, "starts")
+	test.That(t, os.WriteFile(healthFile, nil, 0o600), test.ShouldBeNil)
+
+	var mu sync.Mutex
+	var events []ProcessEvent
+	pm := NewProcessManager(logger, WithProcessEventHook(func(event ProcessEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+	_, err := pm.AddProcessFromConfig(context.Background(), ProcessConfig{
+		ID:   "probed",
+		Name: "bash",
+		Args: []string{
+			"-c",
+			fmt.Sprintf("echo start >> '%s'; trap 'exit 0' SIGTERM; while true; do sleep 0.05; done", startsFile),
+		},
+		RestartDelay: 10 * time.Millisecond,
+		LivenessProbe: &ProbeConfig{
+			Exec:             []string{"test", "-f", healthFile},
+			Interval:         50 * time.Millisecond,
+			FailureThreshold: 2,
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pm.Start(context.Background()), test.ShouldBeNil)
+	defer func() {
+		test.That(t, pm.Stop(), test.ShouldBeNil)
+	}()
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		statuses := pm.Status()
+		test.That(tb, statuses, test.ShouldHaveLength, 1)
+		test.That(tb, statuses[0].ID, test.ShouldEqual, "probed")
+		test.That(tb, statuses[0].Schedule, test.ShouldBeNil)
+		test.That(tb, statuses[0].Liveness, test.ShouldNotBeNil)
+		test.That(tb, statuses[0].Liveness.Healthy, test.ShouldBeTrue)
+		test.That(tb, statuses[0].Liveness.LastProbe.IsZero(), test.ShouldBeFalse)
+	})
+
+	// an unhealthy process is restarted.
+	test.That(t, os.Remove(healthFile), test.ShouldBeNil)
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		test.That(tb, events, test.ShouldNotBeEmpty)
+		test.That(tb, events[0].ProcessID, test.ShouldEqual, "probed")
+		test.That(tb, events[0].Type, test.ShouldEqual, ProcessEventProbeFailed)
+		test.That(tb, events[0].Count, test.ShouldEqual, 2)
+		test.That(tb, events[0].Err, test.ShouldNotBeNil)
+
+		starts, err := os.ReadFile(startsFile)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, strings.Count(string(starts), "start"), test.ShouldBeGreaterThanOrEqualTo, 2)
+	})
+}
@@ -20,6 +20,27 @@ import (
 // defaultStopTimeout is how long to wait in seconds (all stages) between first signaling and finally killing.
 const defaultStopTimeout = time.Second * 10
 
+// Defaults for how long to wait before restarting a process. The delay doubles after each
+// restart, up to the maximum, and is reset once the process stays up for the maximum.
+const (
+	defaultRestartDelay    = time.Second
+	defaultMaxRestartDelay = time.Minute
+)
+
+// A RestartPolicy determines whether a managed process is restarted when it exits on its
+// own.
+type RestartPolicy string
+
+// The available RestartPolicies.
+const (
+	// RestartPolicyAlways restarts the process whenever it exits. It is the default.
+	RestartPolicyAlways RestartPolicy = "always"
+	// RestartPolicyOnFailure restarts the process only when it exits unsuccessfully.
+	RestartPolicyOnFailure RestartPolicy = "on-failure"
+	// RestartPolicyNever never restarts the process.
+	RestartPolicyNever RestartPolicy = "never"
+)
+
 // A ProcessConfig describes how to manage a system process.
 type ProcessConfig struct {
 	ID      string
@@ -35,13 +56,46 @@ type ProcessConfig struct {
 	Environment map[string]string
 	Log         bool
 	LogWriter   io.Writer
+	// LogPrefix, if set, is prepended to each line of output that is logged or written to
+	// LogWriter, which helps tell apart processes sharing a writer.
+	LogPrefix   string
 	StopSignal  syscall.Signal
 	StopTimeout time.Duration
+	// StopOrder determines when the process is stopped by its ProcessManager: processes
+	// with a lower StopOrder are stopped, one group at a time, before those with a higher
+	// one, such that e.g. sidecars can outlive the processes depending on them.
+	StopOrder int
+	// RestartPolicy determines whether the process is restarted when it exits on its own.
+	// It does not apply to one shot processes. Defaults to RestartPolicyAlways.
+	RestartPolicy RestartPolicy
+	// RestartDelay is how long to wait before first restarting the process after it exits.
+	// The delay doubles with each consecutive restart, up to MaxRestartDelay, and is reset
+	// once the process stays up for MaxRestartDelay. Defaults to 1 second.
+	RestartDelay time.Duration
+	// MaxRestartDelay is the longest to wait before restarting the process. Defaults to
+	// 1 minute.
+	MaxRestartDelay time.Duration
+	// Schedule, if set, makes the process run to completion, like a one shot process, each
+	// time the schedule fires rather than being kept running. See ParseSchedule for the
+	// supported cron expressions and intervals. Restart policies do not apply to it.
+	Schedule string
+	// ScheduleOverlap determines what happens when the process is due to run while its
+	// previous run has not finished yet. Defaults to OverlapPolicySkip.
+	ScheduleOverlap OverlapPolicy
+	// Resources limits the system resources available to the process.
+	Resources ResourceLimits
+	// LivenessProbe, if set, checks that the process is healthy while it runs. A process
+	// failing it is stopped, after which its restart policy applies. It is not supported
+	// for one shot and scheduled processes.
+	LivenessProbe *ProbeConfig
+	// LogBufferLines is how many of the most recent lines of output are kept for
+	// LoggingProcess.TailLogs. Defaults to 1000, and a negative number keeps none.
+	LogBufferLines int
 	// OnUnexpectedExit will be called when the manage goroutine detects an
-	// unexpected exit of the process. The exit code of the crashed process will
-	// be passed in. If the returned bool is true, the manage goroutine will
-	// attempt to restart the process. Otherwise, the manage goroutine will
-	// simply return.
+	// unexpected exit of the process that the restart policy would restart it
+	// for. The exit code of the crashed process will be passed in. If the
+	// returned bool is true, the manage goroutine will attempt to restart the
+	// process. Otherwise, the manage goroutine will simply return.
 	//
 	// NOTE(benjirewis): use `jsonschema:"-"` struct tag to avoid issues with
 	// jsonschema reflection (go functions cannot be encoded to JSON).
@@ -71,6 +125,38 @@ func (config *ProcessConfig) validate(path string) error {
 	if config.StopTimeout < 100*time.Millisecond && config.StopTimeout != 0 {
 		return utils.NewConfigValidationError(path, errors.New("stop_timeout should not be less than 100ms"))
 	}
+	switch config.RestartPolicy {
+	case "", RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyNever:
+	default:
+		return utils.NewConfigValidationError(path, errors.Errorf("unknown restart_policy %q", config.RestartPolicy))
+	}
+	if config.RestartDelay < 0 || config.MaxRestartDelay < 0 {
+		return utils.NewConfigValidationError(path, errors.New("restart delays should not be negative"))
+	}
+	if config.MaxRestartDelay != 0 && config.RestartDelay > config.MaxRestartDelay {
+		return utils.NewConfigValidationError(path, errors.New("restart_delay should not exceed max_restart_delay"))
+	}
+	if config.Schedule != "" {
+		if _, err := ParseSchedule(config.Schedule); err != nil {
+			return utils.NewConfigValidationError(path, err)
+		}
+	}
+	if err := config.Resources.validate(); err != nil {
+		return utils.NewConfigValidationError(path, err)
+	}
+	if config.LivenessProbe != nil {
+		if config.OneShot || config.Schedule != "" {
+			return utils.NewConfigValidationError(path, errors.New("liveness_probe is not supported for one shot and scheduled processes"))
+		}
+		if err := config.LivenessProbe.validate(); err != nil {
+			return utils.NewConfigValidationError(path, err)
+		}
+	}
+	switch config.ScheduleOverlap {
+	case "", OverlapPolicySkip, OverlapPolicyQueue:
+	default:
+		return utils.NewConfigValidationError(path, errors.Errorf("unknown schedule_overlap %q", config.ScheduleOverlap))
+	}
 	return nil
 }
 
@@ -84,8 +170,21 @@ type configData struct {
 	Username    string            `json:"username"`
 	Environment map[string]string `json:"env"`
 	Log         bool              `json:"log"`
+	LogPrefix   string            `json:"log_prefix,omitempty"`
 	StopSignal  string            `json:"stop_signal,omitempty"`
 	StopTimeout string            `json:"stop_timeout,omitempty"`
+	StopOrder   int               `json:"stop_order,omitempty"`
+
+	RestartPolicy   RestartPolicy `json:"restart_policy,omitempty"`
+	RestartDelay    string        `json:"restart_delay,omitempty"`
+	MaxRestartDelay string        `json:"max_restart_delay,omitempty"`
+
+	Schedule        string        `json:"schedule,omitempty"`
+	ScheduleOverlap OverlapPolicy `json:"schedule_overlap,omitempty"`
+
+	Resources      *ResourceLimits `json:"resources,omitempty"`
+	LogBufferLines int             `json:"log_buffer_lines,omitempty"`
+	LivenessProbe  *ProbeConfig    `json:"liveness_probe,omitempty"`
 }
 
 // UnmarshalJSON parses incoming json.
@@ -96,23 +195,43 @@ func (config *ProcessConfig) UnmarshalJSON(data []byte) error {
 	}
 
 	*config = ProcessConfig{
-		ID:          temp.ID,
-		Name:        temp.Name,
-		Args:        temp.Args,
-		CWD:         temp.CWD,
-		OneShot:     temp.OneShot,
-		Username:    temp.Username,
-		Environment: temp.Environment,
-		Log:         temp.Log,
+		ID:              temp.ID,
+		Name:            temp.Name,
+		Args:            temp.Args,
+		CWD:             temp.CWD,
+		OneShot:         temp.OneShot,
+		Username:        temp.Username,
+		Environment:     temp.Environment,
+		Log:             temp.Log,
+		LogPrefix:       temp.LogPrefix,
+		LogBufferLines:  temp.LogBufferLines,
+		LivenessProbe:   temp.LivenessProbe,
+		StopOrder:       temp.StopOrder,
+		RestartPolicy:   temp.RestartPolicy,
+		Schedule:        temp.Schedule,
+		ScheduleOverlap: temp.ScheduleOverlap,
 		// OnUnexpectedExit cannot be specified in JSON.
 	}
+	if temp.Resources != nil {
+		config.Resources = *temp.Resources
+	}
 
-	if temp.StopTimeout != "" {
-		dur, err := time.ParseDuration(temp.StopTimeout)
+	for _, dur := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{temp.StopTimeout, &config.StopTimeout},
+		{temp.RestartDelay, &config.RestartDelay},
+		{temp.MaxRestartDelay, &config.MaxRestartDelay},
+	} {
+		if dur.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(dur.value)
 		if err != nil {
 			return err
 		}
-		config.StopTimeout = dur
+		*dur.dst = parsed
 	}
 
 	stopSig, err := parseSignal(temp.StopSignal, "stop_signal")
@@ -131,18 +250,35 @@ func (config ProcessConfig) MarshalJSON() ([]byte, error) {
 		stopSig = config.StopSignal.String()
 	}
 	temp := configData{
-		ID:          config.ID,
-		Name:        config.Name,
-		Args:        config.Args,
-		CWD:         config.CWD,
-		OneShot:     config.OneShot,
-		Username:    config.Username,
-		Environment: config.Environment,
-		Log:         config.Log,
-		StopSignal:  stopSig,
-		StopTimeout: config.StopTimeout.String(),
+		ID:              config.ID,
+		Name:            config.Name,
+		Args:            config.Args,
+		CWD:             config.CWD,
+		OneShot:         config.OneShot,
+		Username:        config.Username,
+		Environment:     config.Environment,
+		Log:             config.Log,
+		LogPrefix:       config.LogPrefix,
+		LogBufferLines:  config.LogBufferLines,
+		LivenessProbe:   config.LivenessProbe,
+		StopSignal:      stopSig,
+		StopTimeout:     config.StopTimeout.String(),
+		StopOrder:       config.StopOrder,
+		RestartPolicy:   config.RestartPolicy,
+		Schedule:        config.Schedule,
+		ScheduleOverlap: config.ScheduleOverlap,
 		// OnUnexpectedExit cannot be converted to JSON.
 	}
+	if config.RestartDelay != 0 {
+		temp.RestartDelay = config.RestartDelay.String()
+	}
+	if config.MaxRestartDelay != 0 {
+		temp.MaxRestartDelay = config.MaxRestartDelay.String()
+	}
+	if !config.Resources.isZero() {
+		resources := config.Resources
+		temp.Resources = &resources
+	}
 	return json.Marshal(temp)
 }
 
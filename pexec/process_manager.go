@@ -2,6 +2,8 @@ package pexec
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/edaniels/golog"
@@ -38,6 +40,18 @@ type ProcessManager interface {
 	// replaced by its ID, the replaced process will be returned.
 	AddProcessFromConfig(ctx context.Context, config ProcessConfig) (ManagedProcess, error)
 
+	// Apply changes the managed processes to those of the given configurations.
+	// Processes no longer configured are removed and stopped, newly configured ones
+	// are added, and those whose configuration changed are replaced by stopping the
+	// old process before starting the new one. Processes whose configuration did not
+	// change keep running undisturbed. Processes added by AddProcess rather than from
+	// a configuration are always replaced or removed. New processes are only started
+	// if the manager is started, and the same context semantics in Start apply here.
+	Apply(ctx context.Context, configs []ProcessConfig) error
+
+	// Status reports on all managed processes, ordered by ID.
+	Status() []ProcessStatus
+
 	// Stop signals and waits for all managed processes to stop and returns
 	// any errors from stopping them.
 	Stop() error
@@ -51,15 +65,64 @@ type processManager struct {
 	mu            sync.Mutex
 	processesByID map[string]ManagedProcess
 	logger        golog.Logger
+	opts          processManagerOptions
 	started       bool
 	stopped       bool
 }
 
 // NewProcessManager returns a new ProcessManager.
-func NewProcessManager(logger golog.Logger) ProcessManager {
+func NewProcessManager(logger golog.Logger, opts ...ProcessManagerOption) ProcessManager {
+	var pmOpts processManagerOptions
+	for _, opt := range opts {
+		opt.apply(&pmOpts)
+	}
 	return &processManager{
 		logger:        logger,
 		processesByID: map[string]ManagedProcess{},
+		opts:          pmOpts,
+	}
+}
+
+// processManagerOptions configure a ProcessManager.
+type processManagerOptions struct {
+	// eventHooks are called with the events of managed processes.
+	eventHooks []ProcessEventHook
+}
+
+// ProcessManagerOption configures a ProcessManager.
+type ProcessManagerOption interface {
+	apply(*processManagerOptions)
+}
+
+// funcProcessManagerOption wraps a function that modifies processManagerOptions into an
+// implementation of the ProcessManagerOption interface.
+type funcProcessManagerOption struct {
+	f func(*processManagerOptions)
+}
+
+func (fpmo *funcProcessManagerOption) apply(do *processManagerOptions) {
+	fpmo.f(do)
+}
+
+func newFuncProcessManagerOption(f func(*processManagerOptions)) *funcProcessManagerOption {
+	return &funcProcessManagerOption{
+		f: f,
+	}
+}
+
+// WithProcessEventHook returns a ProcessManagerOption which calls the given hook with the
+// events of the processes added to the manager, such as them running into their resource
+// limits. Only processes created by this package report events.
+func WithProcessEventHook(hook ProcessEventHook) ProcessManagerOption {
+	return newFuncProcessManagerOption(func(o *processManagerOptions) {
+		o.eventHooks = append(o.eventHooks, hook)
+	})
+}
+
+// reportEvent calls the manager's event hooks with the event.
+func (pm *processManager) reportEvent(event ProcessEvent) {
+	for _, hook := range pm.opts.eventHooks {
+		hook(event)
 	}
 }
 
@@ -119,10 +182,17 @@ func (pm *processManager) Start(ctx context.Context) error {
 func (pm *processManager) AddProcess(ctx context.Context, proc ManagedProcess, start bool) (ManagedProcess, error) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
+	return pm.addProcess(ctx, proc, start)
+}
+
+func (pm *processManager) addProcess(ctx context.Context, proc ManagedProcess, start bool) (ManagedProcess, error) {
 	if pm.stopped {
 		return nil, errAlreadyStopped
 	}
 	replaced := pm.processesByID[proc.ID()]
+	if reporter, ok := proc.(eventReporter); ok {
+		reporter.setEventHook(pm.reportEvent)
+	}
 	if pm.started && start {
 		if err := proc.Start(ctx); err != nil {
 			return nil, err
@@ -140,6 +210,105 @@ func (pm *processManager) AddProcessFromConfig(ctx context.Context, config Proce
 	return pm.AddProcess(ctx, proc, true)
 }
 
+func (pm *processManager) Apply(ctx context.Context, configs []ProcessConfig) error {
+	desired := make(map[string]ProcessConfig, len(configs))
+	for i, config := range configs {
+		if err := config.Validate(fmt.Sprintf("processes.%d", i)); err != nil {
+			return err
+		}
+		if _, ok := desired[config.ID]; ok {
+			return errors.Errorf("duplicate process id %q", config.ID)
+		}
+		desired[config.ID] = config
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.stopped {
+		return errAlreadyStopped
+	}
+
+	var outdated []ManagedProcess
+	for id, proc := range pm.processesByID {
+		if config, ok := desired[id]; ok {
+			if configured, ok := proc.(configuredProcess); ok && !processConfigChanged(configured.processConfig(), config) {
+				delete(desired, id)
+				continue
+			}
+		}
+		outdated = append(outdated, proc)
+		delete(pm.processesByID, id)
+	}
+	err := stopProcesses(outdated)
+
+	// start processes in the order they are configured in.
+	for _, config := range configs {
+		if _, ok := desired[config.ID]; !ok {
+			continue
+		}
+		if _, addErr := pm.addProcess(ctx, NewManagedProcess(config, pm.logger), true); addErr != nil {
+			err = multierr.Combine(err, errors.Wrapf(addErr, "error starting process %q", config.ID))
+		}
+	}
+	return err
+}
+
+// ProcessStatus reports on a managed process.
+type ProcessStatus struct {
+	ID string
+	// Liveness is the status of the liveness probe of the process's current run, if it has
+	// a probe.
+	Liveness *ProbeStatus
+	// Schedule is the status of the runs of a ScheduledProcess.
+	Schedule *ScheduleStatus
+}
+
+func (pm *processManager) Status() []ProcessStatus {
+	pm.mu.Lock()
+	procs := make([]ManagedProcess, 0, len(pm.processesByID))
+	for _, proc := range pm.processesByID {
+		procs = append(procs, proc)
+	}
+	pm.mu.Unlock()
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].ID() < procs[j].ID()
+	})
+
+	statuses := make([]ProcessStatus, 0, len(procs))
+	for _, proc := range procs {
+		status := ProcessStatus{ID: proc.ID()}
+		if probed, ok := proc.(livenessProbed); ok {
+			if liveness, ok := probed.livenessStatus(); ok {
+				status.Liveness = &liveness
+			}
+		}
+		if scheduled, ok := proc.(ScheduledProcess); ok {
+			schedule := scheduled.ScheduleStatus()
+			status.Schedule = &schedule
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// A livenessProbed is a process that may have a liveness probe.
+type livenessProbed interface {
+	livenessStatus() (ProbeStatus, bool)
+}
+
+// A configuredProcess is a process created from a configuration.
+type configuredProcess interface {
+	processConfig() ProcessConfig
+}
+
+// processConfigChanged returns whether a process needs to be replaced to go from the old
+// configuration to the new one. Callbacks cannot be compared and are ignored.
+func processConfigChanged(oldConfig, newConfig ProcessConfig) bool {
+	oldConfig.OnUnexpectedExit = nil
+	newConfig.OnUnexpectedExit = nil
+	return !oldConfig.Equals(newConfig)
+}
+
 func (pm *processManager) Stop() error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
@@ -151,14 +320,57 @@ func (pm *processManager) Stop() error {
 
 func (pm *processManager) stop() error {
 	pm.stopped = true
-	var err error
+	procs := make([]ManagedProcess, 0, len(pm.processesByID))
 	for _, proc := range pm.processesByID {
-		err = multierr.Combine(err, proc.Stop())
+		procs = append(procs, proc)
 	}
 	pm.processesByID = nil
+	return stopProcesses(procs)
+}
+
+// stopProcesses stops the processes grouped by their stop order and returns any errors
+// from stopping them.
+func stopProcesses(procs []ManagedProcess) error {
+	groups := map[int][]ManagedProcess{}
+	for _, proc := range procs {
+		order := 0
+		if ordered, ok := proc.(stopOrderer); ok {
+			order = ordered.stopOrder()
+		}
+		groups[order] = append(groups[order], proc)
+	}
+	orders := make([]int, 0, len(groups))
+	for order := range groups {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+
+	var err error
+	for _, order := range orders {
+		// processes of the same group are stopped concurrently.
+		group := groups[order]
+		errs := make([]error, len(group))
+		var wg sync.WaitGroup
+		wg.Add(len(group))
+		for i, proc := range group {
+			i, proc := i, proc
+			go func() {
+				defer wg.Done()
+				errs[i] = proc.Stop()
+			}()
+		}
+		wg.Wait()
+		err = multierr.Combine(err, multierr.Combine(errs...))
+	}
 	return err
 }
 
+// A stopOrderer is a process that has to be stopped in a particular order relative to
+// others; see ProcessConfig.StopOrder.
+type stopOrderer interface {
+	stopOrder() int
+}
+
 func (pm *processManager) Clone() ProcessManager {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
@@ -169,6 +381,7 @@ func (pm *processManager) Clone() ProcessManager {
 	return &processManager{
 		processesByID: processesByIDCopy,
 		logger:        pm.logger,
+		opts:          pm.opts,
 		started:       pm.started,
 		stopped:       pm.stopped,
 	}
@@ -241,6 +454,14 @@ func (noop noopProcessManager) AddProcessFromConfig(ctx context.Context, config
 	return nil, errors.New("unsupported")
 }
 
+func (noop noopProcessManager) Apply(ctx context.Context, configs []ProcessConfig) error {
+	return errors.New("unsupported")
+}
+
+func (noop noopProcessManager) Status() []ProcessStatus {
+	return nil
+}
+
 func (noop noopProcessManager) Stop() error {
 	return nil
 }
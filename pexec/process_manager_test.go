@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/fsnotify/fsnotify"
@@ -440,6 +442,132 @@ func TestProcessManagerStop(t *testing.T) {
 			<-watcher.Events
 		}
 	})
+
+	t.Run("processes are stopped in stop order", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		pm := NewProcessManager(logger)
+		test.That(t, pm.Start(context.Background()), test.ShouldBeNil)
+
+		var mu sync.Mutex
+		var stopped []string
+		for _, fp := range []*orderedFakeProcess{
+			{fakeProcess: fakeProcess{id: "sidecar"}, order: 10},
+			{fakeProcess: fakeProcess{id: "app1"}},
+			{fakeProcess: fakeProcess{id: "early"}, order: -1},
+			{fakeProcess: fakeProcess{id: "app2", stopErr: true}},
+		} {
+			fp.onStop = func(id string) {
+				mu.Lock()
+				defer mu.Unlock()
+				stopped = append(stopped, id)
+			}
+			_, err := pm.AddProcess(context.Background(), fp, true)
+			test.That(t, err, test.ShouldBeNil)
+		}
+
+		err := pm.Stop()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "stop")
+		test.That(t, stopped, test.ShouldHaveLength, 4)
+		test.That(t, stopped[0], test.ShouldEqual, "early")
+		test.That(t, stopped[1:3], test.ShouldContain, "app1")
+		test.That(t, stopped[1:3], test.ShouldContain, "app2")
+		test.That(t, stopped[3], test.ShouldEqual, "sidecar")
+	})
+}
+
+type orderedFakeProcess struct {
+	fakeProcess
+	order  int
+	onStop func(id string)
+}
+
+func (fp *orderedFakeProcess) Stop() error {
+	fp.onStop(fp.id)
+	return fp.fakeProcess.Stop()
+}
+
+func (fp *orderedFakeProcess) stopOrder() int {
+	return fp.order
+}
+
+func TestProcessManagerApply(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	pm := NewProcessManager(logger)
+	test.That(t, pm.Start(context.Background()), test.ShouldBeNil)
+	defer func() {
+		test.That(t, pm.Stop(), test.ShouldBeNil)
+	}()
+
+	sleeper := func(id string, args ...string) ProcessConfig {
+		return ProcessConfig{
+			ID:   id,
+			Name: "bash",
+			Args: append([]string{"-c", "trap 'exit 0' SIGTERM; while true; do sleep 0.1; done"}, args...),
+		}
+	}
+	processes := func() map[string]ManagedProcess {
+		procs := map[string]ManagedProcess{}
+		for _, id := range pm.ProcessIDs() {
+			proc, ok := pm.ProcessByID(id)
+			test.That(t, ok, test.ShouldBeTrue)
+			procs[id] = proc
+		}
+		return procs
+	}
+
+	fp := &fakeProcess{id: "3"}
+	_, err := pm.AddProcess(context.Background(), fp, true)
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, pm.Apply(context.Background(), []ProcessConfig{sleeper("1"), sleeper("2"), sleeper("3")}), test.ShouldBeNil)
+	before := processes()
+	test.That(t, before, test.ShouldHaveLength, 3)
+	// processes not created from a configuration are always replaced.
+	test.That(t, before["3"], test.ShouldNotEqual, fp)
+	test.That(t, fp.stopCount, test.ShouldEqual, 1)
+
+	t.Run("only changed processes are replaced", func(t *testing.T) {
+		changed := sleeper("2")
+		changed.Environment = map[string]string{"FOO": "bar"}
+		test.That(t, pm.Apply(context.Background(), []ProcessConfig{sleeper("1"), changed, sleeper("4", "arg")}), test.ShouldBeNil)
+		after := processes()
+		test.That(t, after, test.ShouldHaveLength, 3)
+		test.That(t, after["1"], test.ShouldEqual, before["1"])
+		test.That(t, after["2"], test.ShouldNotEqual, before["2"])
+		test.That(t, after["4"], test.ShouldNotBeNil)
+
+		// replaced and removed processes are stopped.
+		for _, id := range []string{"2", "3"} {
+			select {
+			case <-before[id].(*managedProcess).managingCh:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("process %q should have been stopped", id)
+			}
+		}
+		select {
+		case <-after["1"].(*managedProcess).managingCh:
+			t.Fatal("unchanged process should not have been stopped")
+		default:
+		}
+	})
+
+	t.Run("invalid configurations change nothing", func(t *testing.T) {
+		before := processes()
+		err := pm.Apply(context.Background(), []ProcessConfig{sleeper("1"), sleeper("1")})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "duplicate")
+
+		err = pm.Apply(context.Background(), []ProcessConfig{{ID: "5"}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, `"name" is required`)
+		test.That(t, processes(), test.ShouldResemble, before)
+	})
+
+	t.Run("an empty configuration removes all processes", func(t *testing.T) {
+		test.That(t, pm.Apply(context.Background(), nil), test.ShouldBeNil)
+		test.That(t, pm.ProcessIDs(), test.ShouldBeEmpty)
+	})
 }
 
 func TestProcessManagerClone(t *testing.T) {
@@ -21,6 +21,27 @@ func TestProcessConfigRoundTripJSON(t *testing.T) {
 		Log:         true,
 		StopSignal:  syscall.SIGTERM,
 		StopTimeout: 250 * time.Millisecond,
+
+		LogPrefix:       "[hello] ",
+		StopOrder:       2,
+		RestartPolicy:   RestartPolicyOnFailure,
+		RestartDelay:    500 * time.Millisecond,
+		MaxRestartDelay: 10 * time.Second,
+		Schedule:        "*/5 * * * *",
+		ScheduleOverlap: OverlapPolicyQueue,
+		Resources: ResourceLimits{
+			CPUs:        1.5,
+			MemoryBytes: 1 << 30,
+			Nice:        10,
+			OOMScoreAdj: 500,
+		},
+		LivenessProbe: &ProbeConfig{
+			HTTPURL:          "http://localhost:8080/healthz",
+			InitialDelay:     5 * time.Second,
+			Interval:         time.Minute,
+			Timeout:          500 * time.Millisecond,
+			FailureThreshold: 5,
+		},
 	}
 	md, err := json.Marshal(config)
 	test.That(t, err, test.ShouldBeNil)
@@ -74,6 +95,35 @@ func TestProcessConfigValidate(t *testing.T) {
 	test.That(t, err, test.ShouldNotBeNil)
 	test.That(t, err.Error(), test.ShouldContainSubstring, `stop_timeout should not be less than 100ms`)
 
+	for _, tc := range []struct {
+		config ProcessConfig
+		err    string
+	}{
+		{ProcessConfig{RestartPolicy: "sometimes"}, `unknown restart_policy "sometimes"`},
+		{ProcessConfig{RestartDelay: -time.Second}, "restart delays should not be negative"},
+		{ProcessConfig{MaxRestartDelay: -time.Second}, "restart delays should not be negative"},
+		{ProcessConfig{RestartDelay: time.Minute, MaxRestartDelay: time.Second}, "restart_delay should not exceed max_restart_delay"},
+		{ProcessConfig{Schedule: "* * *"}, "expected 5 fields"},
+		{ProcessConfig{LivenessProbe: &ProbeConfig{}}, "exactly one of exec, tcp_address, and http_url"},
+		{
+			ProcessConfig{LivenessProbe: &ProbeConfig{Exec: []string{"true"}, TCPAddress: "localhost:80"}},
+			"exactly one of exec, tcp_address, and http_url",
+		},
+		{ProcessConfig{LivenessProbe: &ProbeConfig{Exec: []string{"true"}, Interval: -1}}, "should not be negative"},
+		{ProcessConfig{OneShot: true, LivenessProbe: &ProbeConfig{Exec: []string{"true"}}}, "not supported for one shot"},
+		{ProcessConfig{Resources: ResourceLimits{CPUs: -1}}, "resources.cpus should not be negative"},
+		{ProcessConfig{Resources: ResourceLimits{MemoryBytes: -1}}, "resources.memory_bytes should not be negative"},
+		{ProcessConfig{Resources: ResourceLimits{Nice: 20}}, "resources.nice should be between -20 and 19"},
+		{ProcessConfig{Resources: ResourceLimits{OOMScoreAdj: -1001}}, "resources.oom_score_adj should be between -1000 and 1000"},
+		{ProcessConfig{Schedule: "@every 1h", ScheduleOverlap: "cancel"}, `unknown schedule_overlap "cancel"`},
+	} {
+		tc.config.ID = "id1"
+		tc.config.Name = "foo"
+		err = tc.config.Validate("path")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+	}
+
 	validConfig := ProcessConfig{
 		ID:          "id1",
 		Name:        "foo",
@@ -0,0 +1,44 @@
+package pexec
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ResourceLimits limits the system resources available to a process. Zero values leave the
+// respective resource unlimited or at the system's default.
+//
+// Limits are only supported on Linux. CPU and memory are limited with a cgroup (v2) for
+// the process where the system allows creating one, in which case running into the
+// limits is reported as events. Otherwise, memory is limited with setrlimit and CPU is
+// not limited. Limits are applied right after the process starts.
+type ResourceLimits struct {
+	// CPUs is how many CPUs worth of time the process may use, such as 0.5 for half of one.
+	CPUs float64 `json:"cpus,omitempty"`
+	// MemoryBytes is the most memory the process may use.
+	MemoryBytes int64 `json:"memory_bytes,omitempty"`
+	// Nice is the scheduling priority of the process, from -20 (highest) to 19 (lowest).
+	Nice int `json:"nice,omitempty"`
+	// OOMScoreAdj adjusts how likely the process is to be killed when the system runs out
+	// of memory, from -1000 (never) to 1000 (first).
+	OOMScoreAdj int `json:"oom_score_adj,omitempty"`
+}
+
+func (limits ResourceLimits) isZero() bool {
+	return limits == ResourceLimits{}
+}
+
+func (limits ResourceLimits) validate() error {
+	if limits.CPUs < 0 {
+		return errors.New("resources.cpus should not be negative")
+	}
+	if limits.MemoryBytes < 0 {
+		return errors.New("resources.memory_bytes should not be negative")
+	}
+	if limits.Nice < -20 || limits.Nice > 19 {
+		return errors.New("resources.nice should be between -20 and 19")
+	}
+	if limits.OOMScoreAdj < -1000 || limits.OOMScoreAdj > 1000 {
+		return errors.New("resources.oom_score_adj should be between -1000 and 1000")
+	}
+	return nil
+}
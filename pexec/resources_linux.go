@@ -0,0 +1,247 @@
+//go:build linux
+
+package pexec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"golang.org/x/sys/unix"
+
+	"go.viam.com/utils"
+)
+
+const (
+	// cgroupRoot is where the cgroup v2 hierarchy is mounted.
+	cgroupRoot = "/sys/fs/cgroup"
+	// cgroupCPUPeriod is the period, in microseconds, over which CPU limits are enforced.
+	cgroupCPUPeriod = 100000
+	// cgroupMinCPUQuota is the smallest CPU quota, in microseconds, the kernel accepts.
+	cgroupMinCPUQuota = 1000
+)
+
+// resourceEventPollInterval is how often the cgroup of a process is checked for the
+// process running into its limits.
+var resourceEventPollInterval = time.Second
+
+// cgroupCounters are the counters of a cgroup that are reported as events when they
+// increase.
+var cgroupCounters = []struct {
+	file, key string
+	eventType ProcessEventType
+	resource  string
+}{
+	{"memory.events", "max", ProcessEventLimitExceeded, "memory"},
+	{"memory.events", "oom_kill", ProcessEventOOMKilled, "memory"},
+	{"cpu.stat", "nr_throttled", ProcessEventLimitExceeded, "cpu"},
+}
+
+// applyResourceLimits applies the process's resource limits to the started process with
+// the given pid. It returns a function to call once the process has exited, which stops
+// watching the limits and cleans up after them.
+func (p *managedProcess) applyResourceLimits(pid int) (func(), error) {
+	limits := p.resources
+	noop := func() {}
+	if limits.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, limits.Nice); err != nil {
+			return nil, errors.Wrapf(err, "error setting niceness of process %d", pid)
+		}
+	}
+	if limits.OOMScoreAdj != 0 {
+		oomScoreAdjPath := fmt.Sprintf("/proc/%d/oom_score_adj", pid)
+		if err := os.WriteFile(oomScoreAdjPath, []byte(strconv.Itoa(limits.OOMScoreAdj)), 0); err != nil {
+			return nil, errors.Wrapf(err, "error adjusting OOM score of process %d", pid)
+		}
+	}
+	if limits.CPUs == 0 && limits.MemoryBytes == 0 {
+		return noop, nil
+	}
+
+	cgroup, err := newProcessCgroup(pid, limits)
+	if err == nil {
+		return p.watchCgroup(cgroup), nil
+	}
+	p.logger.Warnw("cannot limit resources with a cgroup; falling back to setrlimit", "error", err)
+	if limits.CPUs != 0 {
+		p.logger.Warn("CPU cannot be limited without a cgroup")
+	}
+	if limits.MemoryBytes != 0 {
+		rlimit := unix.Rlimit{Cur: uint64(limits.MemoryBytes), Max: uint64(limits.MemoryBytes)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &rlimit, nil); err != nil {
+			return nil, errors.Wrapf(err, "error limiting memory of process %d", pid)
+		}
+	}
+	return noop, nil
+}
+
+// watchCgroup reports the process running into the limits of its cgroup until the
+// returned function is called, which then removes the cgroup.
+func (p *managedProcess) watchCgroup(cgroup *processCgroup) func() {
+	stop := make(chan struct{})
+	var activeWatchers sync.WaitGroup
+	activeWatchers.Add(1)
+	utils.ManagedGo(func() {
+		ticker := time.NewTicker(resourceEventPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			p.reportCgroupEvents(cgroup)
+		}
+	}, activeWatchers.Done)
+
+	return func() {
+		close(stop)
+		activeWatchers.Wait()
+		// catch anything since the last check, such as the process being OOM killed.
+		p.reportCgroupEvents(cgroup)
+		if err := cgroup.remove(); err != nil {
+			p.logger.Debugw("error removing cgroup", "dir", cgroup.dir, "error", err)
+		}
+	}
+}
+
+func (p *managedProcess) reportCgroupEvents(cgroup *processCgroup) {
+	for _, event := range cgroup.events() {
+		event.ProcessID = p.id
+		p.logger.Warnw("process ran into resource limit", "event", event.Type, "resource", event.Resource, "count", event.Count)
+		p.reportEvent(event)
+	}
+}
+
+// A processCgroup is a cgroup created to limit the resources of a single process.
+type processCgroup struct {
+	dir string
+	// lastCounters are the values of cgroupCounters when last checked.
+	lastCounters map[string]uint64
+}
+
+// newProcessCgroup creates a cgroup for the process with the given pid below the cgroup
+// of the current process and moves the process into it.
+func newProcessCgroup(pid int, limits ResourceLimits) (*processCgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, errors.New("cgroups v2 are not available")
+	}
+	parent, err := currentCgroupDir()
+	if err != nil {
+		return nil, err
+	}
+	// Children of a cgroup can only use the controllers it delegates to them. Delegating
+	// them fails if the cgroup has processes of its own, other than at the root, in which
+	// case they need to have been delegated already.
+	_ = os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+cpu +memory"), 0)
+
+	cgroup := &processCgroup{
+		dir:          filepath.Join(parent, fmt.Sprintf("pexec-%d", pid)),
+		lastCounters: map[string]uint64{},
+	}
+	if err := os.Mkdir(cgroup.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := cgroup.configure(pid, limits); err != nil {
+		return nil, multierr.Combine(err, cgroup.remove())
+	}
+	return cgroup, nil
+}
+
+// currentCgroupDir returns the directory of the cgroup (v2) of the current process.
+func currentCgroupDir() (string, error) {
+	rd, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(rd))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(cgroupRoot, path), nil
+		}
+	}
+	return "", errors.New("cannot find the cgroup of the current process")
+}
+
+func (cgroup *processCgroup) configure(pid int, limits ResourceLimits) error {
+	if limits.MemoryBytes != 0 {
+		if err := cgroup.write("memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			return err
+		}
+	}
+	if limits.CPUs != 0 {
+		quota := int64(limits.CPUs * cgroupCPUPeriod)
+		if quota < cgroupMinCPUQuota {
+			quota = cgroupMinCPUQuota
+		}
+		if err := cgroup.write("cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+	return cgroup.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+func (cgroup *processCgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(cgroup.dir, file), []byte(value), 0)
+}
+
+// events returns an event for each of the cgroupCounters that increased since they were
+// last checked.
+func (cgroup *processCgroup) events() []ProcessEvent {
+	files := map[string]map[string]uint64{}
+	var events []ProcessEvent
+	for _, counter := range cgroupCounters {
+		values, ok := files[counter.file]
+		if !ok {
+			values = readCgroupKeyedFile(filepath.Join(cgroup.dir, counter.file))
+			files[counter.file] = values
+		}
+		name := counter.file + ":" + counter.key
+		value, last := values[counter.key], cgroup.lastCounters[name]
+		if value <= last {
+			continue
+		}
+		cgroup.lastCounters[name] = value
+		events = append(events, ProcessEvent{
+			Type:     counter.eventType,
+			Resource: counter.resource,
+			Count:    value - last,
+		})
+	}
+	return events
+}
+
+// readCgroupKeyedFile reads the "key value" lines of a cgroup file, such as memory.events,
+// ignoring any it cannot read.
+func readCgroupKeyedFile(path string) map[string]uint64 {
+	values := map[string]uint64{}
+	rd, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(rd))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values
+}
+
+// remove removes the cgroup, which only succeeds once no processes are left in it.
+func (cgroup *processCgroup) remove() error {
+	return os.Remove(cgroup.dir)
+}
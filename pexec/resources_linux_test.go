@@ -0,0 +1,96 @@
+//go:build linux
+
+package pexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestResourceLimits(t *testing.T) {
+	t.Run("niceness and OOM score", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		proc := NewManagedProcess(ProcessConfig{
+			Name:      "sleep",
+			Args:      []string{"10"},
+			Resources: ResourceLimits{Nice: 5, OOMScoreAdj: 100},
+		}, logger)
+		test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+		defer func() {
+			test.That(t, proc.Stop(), test.ShouldBeNil)
+		}()
+		pid := proc.(*managedProcess).cmd.Process.Pid
+
+		oomScoreAdj, err := os.ReadFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, strings.TrimSpace(string(oomScoreAdj)), test.ShouldEqual, "100")
+
+		// the fields after the command name start with the state, the 3rd field, and include
+		// the niceness as the 19th.
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		test.That(t, err, test.ShouldBeNil)
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		test.That(t, fields[16], test.ShouldEqual, "5")
+	})
+
+	t.Run("one shot", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		var output strings.Builder
+		proc := NewManagedProcess(ProcessConfig{
+			Name:      "bash",
+			Args:      []string{"-c", "sleep 0.5; cat /proc/self/oom_score_adj"},
+			OneShot:   true,
+			LogWriter: &output,
+			Resources: ResourceLimits{OOMScoreAdj: 200},
+		}, logger)
+		test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+		test.That(t, output.String(), test.ShouldEqual, "200\n")
+	})
+
+	t.Run("cgroup events are reported", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		var events []ProcessEvent
+		pm := NewProcessManager(logger, WithProcessEventHook(func(event ProcessEvent) {
+			events = append(events, event)
+		}))
+		proc, err := pm.AddProcessFromConfig(context.Background(), ProcessConfig{ID: "limited", Name: "bash"})
+		test.That(t, err, test.ShouldBeNil)
+
+		cgroup := &processCgroup{dir: t.TempDir(), lastCounters: map[string]uint64{}}
+		writeCounters := func(memoryEvents, cpuStat string) {
+			test.That(t, os.WriteFile(filepath.Join(cgroup.dir, "memory.events"), []byte(memoryEvents), 0o600), test.ShouldBeNil)
+			test.That(t, os.WriteFile(filepath.Join(cgroup.dir, "cpu.stat"), []byte(cpuStat), 0o600), test.ShouldBeNil)
+		}
+
+		writeCounters("low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n", "usage_usec 100\nnr_throttled 0\n")
+		proc.(*managedProcess).reportCgroupEvents(cgroup)
+		test.That(t, events, test.ShouldBeEmpty)
+
+		writeCounters("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n", "usage_usec 200\nnr_throttled 2\n")
+		proc.(*managedProcess).reportCgroupEvents(cgroup)
+		test.That(t, events, test.ShouldHaveLength, 3)
+		for i, expected := range []ProcessEvent{
+			{ProcessID: "limited", Type: ProcessEventLimitExceeded, Resource: "memory", Count: 3},
+			{ProcessID: "limited", Type: ProcessEventOOMKilled, Resource: "memory", Count: 1},
+			{ProcessID: "limited", Type: ProcessEventLimitExceeded, Resource: "cpu", Count: 2},
+		} {
+			test.That(t, events[i].Time.IsZero(), test.ShouldBeFalse)
+			events[i].Time = expected.Time
+			test.That(t, events[i], test.ShouldResemble, expected)
+		}
+
+		// only increases are reported.
+		writeCounters("low 0\nhigh 0\nmax 4\noom 1\noom_kill 1\n", "usage_usec 300\nnr_throttled 2\n")
+		proc.(*managedProcess).reportCgroupEvents(cgroup)
+		test.That(t, events, test.ShouldHaveLength, 4)
+		test.That(t, events[3].Count, test.ShouldEqual, 1)
+		test.That(t, events[3].Resource, test.ShouldEqual, "memory")
+	})
+}
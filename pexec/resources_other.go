@@ -0,0 +1,12 @@
+//go:build !linux
+
+package pexec
+
+// applyResourceLimits warns that resource limits are unsupported, if any are set, and
+// returns a function doing nothing.
+func (p *managedProcess) applyResourceLimits(pid int) (func(), error) {
+	if !p.resources.isZero() {
+		p.logger.Warn("resource limits are only supported on linux")
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,204 @@
+package pexec
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Schedule determines when a scheduled process runs.
+type Schedule interface {
+	// Next returns the first time after the given one that the schedule fires, or the
+	// zero time if it never fires again.
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule parses a schedule specification, which is either
+//   - a standard five field cron expression ("minute hour day-of-month month day-of-week")
+//     supporting "*", "?", lists, ranges, steps, and month and day names,
+//   - one of the descriptors "@yearly" (or "@annually"), "@monthly", "@weekly", "@daily"
+//     (or "@midnight"), and "@hourly", or
+//   - "@every <duration>" to run at a fixed interval, such as "@every 1h30m".
+//
+// Cron expressions are evaluated in the location of the time passed to Next.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule %q", spec)
+		}
+		return IntervalSchedule(interval)
+	}
+	switch spec {
+	case "@yearly", "@annually":
+		spec = "0 0 1 1 *"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@hourly":
+		spec = "0 * * * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid schedule %q: expected 5 fields but got %d", spec, len(fields))
+	}
+	var sched cronSchedule
+	for i, dst := range []*uint64{&sched.minute, &sched.hour, &sched.dom, &sched.month, &sched.dow} {
+		bits, err := cronFields[i].parse(fields[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule %q", spec)
+		}
+		*dst = bits
+	}
+	// Sunday may be written as either 0 or 7.
+	if sched.dow&(1<<7) != 0 {
+		sched.dow = (sched.dow | 1) &^ (1 << 7)
+	}
+	sched.domStar = fields[2] == "*" || fields[2] == "?"
+	sched.dowStar = fields[4] == "*" || fields[4] == "?"
+	return &sched, nil
+}
+
+// IntervalSchedule returns a schedule firing at the given fixed interval, starting one
+// interval after the time it is first asked about.
+func IntervalSchedule(interval time.Duration) (Schedule, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("schedule interval %s should be positive", interval)
+	}
+	return intervalSchedule(interval), nil
+}
+
+type intervalSchedule time.Duration
+
+func (interval intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(interval))
+}
+
+// A cronSchedule holds the allowed values of each field as bitsets.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are set when the respective field is unrestricted, which
+	// determines how the two day fields combine.
+	domStar, dowStar bool
+}
+
+// cronSearchLimit bounds how far ahead Next searches for a matching time so that
+// schedules that can never fire, such as on February 30th, do not loop forever.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+func (sched *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		if sched.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !sched.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron in matching days on either day field if both are restricted.
+func (sched *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := sched.dom&(1<<uint(t.Day())) != 0
+	dowMatch := sched.dow&(1<<uint(t.Weekday())) != 0
+	if sched.domStar || sched.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{
+		"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+	}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// parse returns the bitset of values of the field expression, a comma separated list of
+// "*", values, or ranges, each optionally followed by a step.
+func (f cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid %s step %q", f.name, stepExpr)
+			}
+		}
+
+		var low, high int
+		switch rangeExpr {
+		case "*", "?":
+			low, high = f.min, f.max
+		default:
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = f.value(lowExpr); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if high, err = f.value(highExpr); err != nil {
+					return 0, err
+				}
+			case hasStep:
+				high = f.max
+			default:
+				high = low
+			}
+			if low > high {
+				return 0, errors.Errorf("invalid %s range %q", f.name, rangeExpr)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(expr string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(expr, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Errorf("invalid %s %q", f.name, expr)
+	}
+	return v, nil
+}
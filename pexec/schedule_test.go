@@ -0,0 +1,76 @@
+package pexec
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2023, time.March, 15, 10, 30, 20, 0, time.UTC) // a Wednesday
+	for _, tc := range []struct {
+		spec string
+		next []time.Time
+	}{
+		{"* * * * *", []time.Time{
+			time.Date(2023, time.March, 15, 10, 31, 0, 0, time.UTC),
+			time.Date(2023, time.March, 15, 10, 32, 0, 0, time.UTC),
+		}},
+		{"*/15 9-17 * * mon-fri", []time.Time{
+			time.Date(2023, time.March, 15, 10, 45, 0, 0, time.UTC),
+			time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 1,15 * *", []time.Time{
+			time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, time.April, 15, 0, 0, 0, 0, time.UTC),
+		}},
+		// both day fields are restricted so either matching is enough.
+		{"0 12 1 * 5", []time.Time{
+			time.Date(2023, time.March, 17, 12, 0, 0, 0, time.UTC),
+			time.Date(2023, time.March, 24, 12, 0, 0, 0, time.UTC),
+		}},
+		{"30 4 29 feb *", []time.Time{
+			time.Date(2024, time.February, 29, 4, 30, 0, 0, time.UTC),
+			time.Date(2028, time.February, 29, 4, 30, 0, 0, time.UTC),
+		}},
+		{"0 0 * * 7", []time.Time{
+			time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@daily", []time.Time{
+			time.Date(2023, time.March, 16, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@every 90m", []time.Time{
+			time.Date(2023, time.March, 15, 12, 0, 20, 0, time.UTC),
+			time.Date(2023, time.March, 15, 13, 30, 20, 0, time.UTC),
+		}},
+		{"0 0 30 2 *", []time.Time{{}}},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			sched, err := ParseSchedule(tc.spec)
+			test.That(t, err, test.ShouldBeNil)
+			at := from
+			for _, expected := range tc.next {
+				at = sched.Next(at)
+				test.That(t, at, test.ShouldEqual, expected)
+			}
+		})
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every",
+		"@every -1s",
+		"@fortnightly",
+	} {
+		_, err := ParseSchedule(spec)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
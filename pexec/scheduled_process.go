@@ -0,0 +1,251 @@
+package pexec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+
+	"go.viam.com/utils"
+)
+
+// An OverlapPolicy determines what happens when a scheduled process is due to run while
+// its previous run has not finished yet.
+type OverlapPolicy string
+
+// The available OverlapPolicies.
+const (
+	// OverlapPolicySkip skips the run that is due. It is the default.
+	OverlapPolicySkip OverlapPolicy = "skip"
+	// OverlapPolicyQueue runs the process again as soon as the previous run finishes. At
+	// most one run is queued; any further runs that are due are skipped.
+	OverlapPolicyQueue OverlapPolicy = "queue"
+)
+
+// A ScheduledProcess is a managed process that runs to completion each time its schedule
+// fires, rather than being kept running.
+type ScheduledProcess interface {
+	ManagedProcess
+
+	// ScheduleStatus returns the status of the process's runs.
+	ScheduleStatus() ScheduleStatus
+}
+
+// ScheduleStatus describes the runs of a ScheduledProcess.
+type ScheduleStatus struct {
+	// Running is whether the process is currently running.
+	Running bool
+	// Queued is whether another run is queued to start once the current one finishes.
+	Queued bool
+	// Runs is how many times the process was started.
+	Runs int
+	// Skipped is how many runs were skipped because the previous one had not finished.
+	Skipped int
+	// LastRunStart and LastRunEnd are when the last run started and finished. LastRunEnd
+	// is zero until the first run finishes.
+	LastRunStart time.Time
+	LastRunEnd   time.Time
+	// LastRunErr is the error the last finished run failed with, if any.
+	LastRunErr error
+	// NextRun is when the process is next due to run, or zero if it is not scheduled to
+	// run again.
+	NextRun time.Time
+}
+
+// newScheduledProcess returns a new, unstarted, process running on the schedule of the
+// given configuration.
+func newScheduledProcess(config ProcessConfig, logger golog.Logger) *scheduledProcess {
+	schedule, scheduleErr := ParseSchedule(config.Schedule)
+	overlap := config.ScheduleOverlap
+	if overlap == "" {
+		overlap = OverlapPolicySkip
+	}
+
+	runConfig := config
+	runConfig.OneShot = true
+	runConfig.Schedule = ""
+	runConfig.OnUnexpectedExit = nil
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	return &scheduledProcess{
+		config:      config,
+		id:          config.ID,
+		schedule:    schedule,
+		scheduleErr: scheduleErr,
+		overlap:     overlap,
+		order:       config.StopOrder,
+		run:         NewManagedProcess(runConfig, logger),
+		logger:      logger.Named(fmt.Sprintf("schedule.%s_%s", config.ID, config.Name)),
+		cancelCtx:   cancelCtx,
+		cancel:      cancel,
+	}
+}
+
+type scheduledProcess struct {
+	config      ProcessConfig
+	id          string
+	schedule    Schedule
+	scheduleErr error
+	overlap     OverlapPolicy
+	order       int
+	run         ManagedProcess
+	logger      golog.Logger
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	status  ScheduleStatus
+
+	cancelCtx     context.Context
+	cancel        func()
+	activeWorkers sync.WaitGroup
+}
+
+func (p *scheduledProcess) ID() string {
+	return p.id
+}
+
+// Start begins running the process on its schedule. The given context is not used; runs
+// in progress are canceled by Stop instead.
+func (p *scheduledProcess) Start(ctx context.Context) error {
+	if p.scheduleErr != nil {
+		return p.scheduleErr
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return errAlreadyStopped
+	}
+	if p.started {
+		return nil
+	}
+	p.started = true
+	p.activeWorkers.Add(1)
+	utils.ManagedGo(p.scheduleRuns, p.activeWorkers.Done)
+	return nil
+}
+
+// Stop stops scheduling runs, cancels the run in progress, if any, and waits for it to
+// finish.
+func (p *scheduledProcess) Stop() error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	p.status.NextRun = time.Time{}
+	p.mu.Unlock()
+
+	p.cancel()
+	p.activeWorkers.Wait()
+	return p.run.Stop()
+}
+
+func (p *scheduledProcess) ScheduleStatus() ScheduleStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// TailLogs returns the output of the process's runs.
+func (p *scheduledProcess) TailLogs(ctx context.Context, n int) ([]LogLine, error) {
+	return p.run.(LoggingProcess).TailLogs(ctx, n)
+}
+
+// SubscribeLogs follows the output of the process's runs.
+func (p *scheduledProcess) SubscribeLogs(ctx context.Context) (<-chan LogLine, error) {
+	return p.run.(LoggingProcess).SubscribeLogs(ctx)
+}
+
+func (p *scheduledProcess) processConfig() ProcessConfig {
+	return p.config
+}
+
+func (p *scheduledProcess) stopOrder() int {
+	return p.order
+}
+
+func (p *scheduledProcess) setEventHook(hook ProcessEventHook) {
+	if reporter, ok := p.run.(eventReporter); ok {
+		reporter.setEventHook(hook)
+	}
+}
+
+// scheduleRuns triggers a run each time the schedule fires until the process is stopped.
+func (p *scheduledProcess) scheduleRuns() {
+	for {
+		next := p.schedule.Next(time.Now())
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			return
+		}
+		p.status.NextRun = next
+		p.mu.Unlock()
+		if next.IsZero() {
+			p.logger.Info("schedule will not fire again")
+			return
+		}
+		if !utils.SelectContextOrWaitUntil(p.cancelCtx, next) {
+			return
+		}
+		p.trigger()
+	}
+}
+
+// trigger starts a run unless one is in progress, in which case the overlap policy
+// determines whether it is queued or skipped.
+func (p *scheduledProcess) trigger() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	if p.status.Running {
+		if p.overlap == OverlapPolicyQueue && !p.status.Queued {
+			p.status.Queued = true
+			return
+		}
+		p.status.Skipped++
+		p.logger.Warn("skipping run since the previous one has not finished")
+		return
+	}
+	p.status.Running = true
+	p.activeWorkers.Add(1)
+	utils.PanicCapturingGo(func() {
+		defer p.activeWorkers.Done()
+		p.runQueued()
+	})
+}
+
+// runQueued runs the process, and again for as long as runs are queued.
+func (p *scheduledProcess) runQueued() {
+	for {
+		p.mu.Lock()
+		p.status.Runs++
+		p.status.LastRunStart = time.Now()
+		p.mu.Unlock()
+
+		err := p.run.Start(p.cancelCtx)
+		// errors from runs canceled by Stop are expected.
+		if err != nil && p.cancelCtx.Err() == nil {
+			p.logger.Errorw("error running scheduled process", "error", err)
+		}
+
+		p.mu.Lock()
+		p.status.LastRunEnd = time.Now()
+		p.status.LastRunErr = err
+		if p.status.Queued && !p.stopped {
+			p.status.Queued = false
+			p.mu.Unlock()
+			continue
+		}
+		p.status.Running = false
+		p.status.Queued = false
+		p.mu.Unlock()
+		return
+	}
+}
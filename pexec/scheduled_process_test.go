@@ -0,0 +1,115 @@
+package pexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/testutils"
+)
+
+func TestScheduledProcess(t *testing.T) {
+	t.Run("runs on schedule until stopped", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+
+		tempFile := testutils.TempFile(t, "something.txt")
+		defer tempFile.Close()
+
+		pm := NewProcessManager(logger)
+		proc, err := pm.AddProcessFromConfig(context.Background(), ProcessConfig{
+			ID:       "1",
+			Name:     "bash",
+			Args:     []string{"-c", fmt.Sprintf("echo hello >> '%s'", tempFile.Name())},
+			Schedule: "@every 50ms",
+		})
+		test.That(t, err, test.ShouldBeNil)
+		sched, ok := proc.(ScheduledProcess)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, sched.ScheduleStatus(), test.ShouldResemble, ScheduleStatus{})
+
+		test.That(t, pm.Start(context.Background()), test.ShouldBeNil)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, sched.ScheduleStatus().Runs, test.ShouldBeGreaterThanOrEqualTo, 3)
+		})
+		status := sched.ScheduleStatus()
+		test.That(t, status.LastRunErr, test.ShouldBeNil)
+		test.That(t, status.NextRun, test.ShouldHappenAfter, status.LastRunStart)
+
+		test.That(t, pm.Stop(), test.ShouldBeNil)
+		status = sched.ScheduleStatus()
+		test.That(t, status.Running, test.ShouldBeFalse)
+		test.That(t, status.NextRun.IsZero(), test.ShouldBeTrue)
+
+		rd, err := os.ReadFile(tempFile.Name())
+		test.That(t, err, test.ShouldBeNil)
+		// the last run may have been canceled before it wrote anything.
+		test.That(t, strings.Count(string(rd), "hello"), test.ShouldBeBetweenOrEqual, status.Runs-1, status.Runs)
+	})
+
+	t.Run("failed runs are reported", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		proc := NewManagedProcess(ProcessConfig{
+			ID:       "1",
+			Name:     "bash",
+			Args:     []string{"-c", "exit 1"},
+			Schedule: "@every 50ms",
+		}, logger).(ScheduledProcess)
+		test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			status := proc.ScheduleStatus()
+			test.That(tb, status.LastRunErr, test.ShouldNotBeNil)
+			test.That(tb, status.LastRunErr.Error(), test.ShouldContainSubstring, "exit status 1")
+		})
+		test.That(t, proc.Stop(), test.ShouldBeNil)
+	})
+
+	t.Run("invalid schedule fails to start", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		proc := NewManagedProcess(ProcessConfig{
+			ID:       "1",
+			Name:     "bash",
+			Schedule: "whenever",
+		}, logger)
+		test.That(t, proc.Start(context.Background()), test.ShouldNotBeNil)
+		test.That(t, proc.Stop(), test.ShouldBeNil)
+	})
+
+	for _, overlap := range []OverlapPolicy{OverlapPolicySkip, OverlapPolicyQueue} {
+		t.Run(fmt.Sprintf("overlap=%s", overlap), func(t *testing.T) {
+			logger := golog.NewTestLogger(t)
+			proc := NewManagedProcess(ProcessConfig{
+				ID:              "1",
+				Name:            "bash",
+				Args:            []string{"-c", "sleep 0.5"},
+				Schedule:        "@every 50ms",
+				ScheduleOverlap: overlap,
+			}, logger).(ScheduledProcess)
+			test.That(t, proc.Start(context.Background()), test.ShouldBeNil)
+			testutils.WaitForAssertion(t, func(tb testing.TB) {
+				tb.Helper()
+				status := proc.ScheduleStatus()
+				test.That(tb, status.Running, test.ShouldBeTrue)
+				test.That(tb, status.Skipped, test.ShouldBeGreaterThan, 0)
+				test.That(tb, status.Queued, test.ShouldEqual, overlap == OverlapPolicyQueue)
+			})
+			test.That(t, proc.ScheduleStatus().Runs, test.ShouldEqual, 1)
+
+			// stopping cancels the run in progress rather than waiting for it.
+			start := time.Now()
+			test.That(t, proc.Stop(), test.ShouldBeNil)
+			test.That(t, time.Since(start), test.ShouldBeLessThan, 500*time.Millisecond)
+			status := proc.ScheduleStatus()
+			test.That(t, status.Running, test.ShouldBeFalse)
+			test.That(t, status.Queued, test.ShouldBeFalse)
+			test.That(t, status.Runs, test.ShouldEqual, 1)
+		})
+	}
+}
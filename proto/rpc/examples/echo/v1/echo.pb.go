@@ -209,6 +209,100 @@ func (x *EchoMultipleResponse) GetMessage() string {
 	return ""
 }
 
+type EchoClientStreamRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *EchoClientStreamRequest) Reset() {
+	*x = EchoClientStreamRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EchoClientStreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EchoClientStreamRequest) ProtoMessage() {}
+
+func (x *EchoClientStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EchoClientStreamRequest.ProtoReflect.Descriptor instead.
+func (*EchoClientStreamRequest) Descriptor() ([]byte, []int) {
+	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EchoClientStreamRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type EchoClientStreamResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *EchoClientStreamResponse) Reset() {
+	*x = EchoClientStreamResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EchoClientStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EchoClientStreamResponse) ProtoMessage() {}
+
+func (x *EchoClientStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EchoClientStreamResponse.ProtoReflect.Descriptor instead.
+func (*EchoClientStreamResponse) Descriptor() ([]byte, []int) {
+	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EchoClientStreamResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type EchoBiDiRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -220,7 +314,7 @@ type EchoBiDiRequest struct {
 func (x *EchoBiDiRequest) Reset() {
 	*x = EchoBiDiRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[4]
+		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -233,7 +327,7 @@ func (x *EchoBiDiRequest) String() string {
 func (*EchoBiDiRequest) ProtoMessage() {}
 
 func (x *EchoBiDiRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[4]
+	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -246,7 +340,7 @@ func (x *EchoBiDiRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EchoBiDiRequest.ProtoReflect.Descriptor instead.
 func (*EchoBiDiRequest) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{4}
+	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *EchoBiDiRequest) GetMessage() string {
@@ -267,7 +361,7 @@ type EchoBiDiResponse struct {
 func (x *EchoBiDiResponse) Reset() {
 	*x = EchoBiDiResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[5]
+		mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -280,7 +374,7 @@ func (x *EchoBiDiResponse) String() string {
 func (*EchoBiDiResponse) ProtoMessage() {}
 
 func (x *EchoBiDiResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[5]
+	mi := &file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -293,7 +387,7 @@ func (x *EchoBiDiResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EchoBiDiResponse.ProtoReflect.Descriptor instead.
 func (*EchoBiDiResponse) Descriptor() ([]byte, []int) {
-	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{5}
+	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *EchoBiDiResponse) GetMessage() string {
@@ -323,40 +417,54 @@ var file_proto_rpc_examples_echo_v1_echo_proto_rawDesc = []byte{
 	0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x30, 0x0a, 0x14, 0x45, 0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c,
 	0x74, 0x69, 0x70, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
 	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x2b, 0x0a, 0x0f, 0x45, 0x63, 0x68, 0x6f, 0x42,
-	0x69, 0x44, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x22, 0x2c, 0x0a, 0x10, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x33, 0x0a, 0x17, 0x45, 0x63, 0x68, 0x6f, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x34, 0x0a, 0x18,
+	0x45, 0x63, 0x68, 0x6f, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
 	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
 	0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x32, 0xf4, 0x02, 0x0a, 0x0b, 0x45, 0x63, 0x68, 0x6f, 0x53, 0x65, 0x72, 0x76, 0x69,
-	0x63, 0x65, 0x12, 0x80, 0x01, 0x0a, 0x04, 0x45, 0x63, 0x68, 0x6f, 0x12, 0x27, 0x2e, 0x70, 0x72,
+	0x67, 0x65, 0x22, 0x2b, 0x0a, 0x0f, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22,
+	0x2c, 0x0a, 0x10, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x32, 0xf5, 0x03,
+	0x0a, 0x0b, 0x45, 0x63, 0x68, 0x6f, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x80, 0x01,
+	0x0a, 0x04, 0x45, 0x63, 0x68, 0x6f, 0x12, 0x27, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72,
+	0x70, 0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f,
+	0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x28, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68,
+	0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x25, 0x82, 0xd3, 0xe4, 0x93, 0x02,
+	0x1f, 0x22, 0x1a, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73,
+	0x2f, 0x65, 0x63, 0x68, 0x6f, 0x2f, 0x76, 0x31, 0x2f, 0x65, 0x63, 0x68, 0x6f, 0x3a, 0x01, 0x2a,
+	0x12, 0x75, 0x0a, 0x0c, 0x45, 0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65,
+	0x12, 0x2f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63,
+	0x68, 0x6f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x30, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45,
+	0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12, 0x7f, 0x0a, 0x10, 0x45, 0x63, 0x68, 0x6f, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x33, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73,
-	0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63,
+	0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x34, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63,
+	0x68, 0x6f, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x12, 0x6b, 0x0a, 0x08, 0x45, 0x63, 0x68, 0x6f,
+	0x42, 0x69, 0x44, 0x69, 0x12, 0x2b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63,
 	0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76,
-	0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x25,
-	0x82, 0xd3, 0xe4, 0x93, 0x02, 0x1f, 0x22, 0x1a, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x61,
-	0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2f, 0x65, 0x63, 0x68, 0x6f, 0x2f, 0x76, 0x31, 0x2f, 0x65, 0x63,
-	0x68, 0x6f, 0x3a, 0x01, 0x2a, 0x12, 0x75, 0x0a, 0x0c, 0x45, 0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c,
-	0x74, 0x69, 0x70, 0x6c, 0x65, 0x12, 0x2f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70,
-	0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e,
-	0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x30, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72,
-	0x70, 0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f,
-	0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x65,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12, 0x6b, 0x0a, 0x08,
-	0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x12, 0x2b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63,
-	0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70,
-	0x63, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e,
-	0x76, 0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x2e, 0x5a, 0x2c, 0x67, 0x6f, 0x2e,
-	0x76, 0x69, 0x61, 0x6d, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2f, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65,
-	0x73, 0x2f, 0x65, 0x63, 0x68, 0x6f, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
+	0x31, 0x2e, 0x45, 0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x2c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2e, 0x65, 0x63, 0x68, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x45,
+	0x63, 0x68, 0x6f, 0x42, 0x69, 0x44, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x2e, 0x5a, 0x2c, 0x67, 0x6f, 0x2e, 0x76, 0x69, 0x61, 0x6d,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x75, 0x74, 0x69, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2f, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2f, 0x65, 0x63,
+	0x68, 0x6f, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -371,24 +479,28 @@ func file_proto_rpc_examples_echo_v1_echo_proto_rawDescGZIP() []byte {
 	return file_proto_rpc_examples_echo_v1_echo_proto_rawDescData
 }
 
-var file_proto_rpc_examples_echo_v1_echo_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_rpc_examples_echo_v1_echo_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_proto_rpc_examples_echo_v1_echo_proto_goTypes = []interface{}{
-	(*EchoRequest)(nil),          // 0: proto.rpc.examples.echo.v1.EchoRequest
-	(*EchoResponse)(nil),         // 1: proto.rpc.examples.echo.v1.EchoResponse
-	(*EchoMultipleRequest)(nil),  // 2: proto.rpc.examples.echo.v1.EchoMultipleRequest
-	(*EchoMultipleResponse)(nil), // 3: proto.rpc.examples.echo.v1.EchoMultipleResponse
-	(*EchoBiDiRequest)(nil),      // 4: proto.rpc.examples.echo.v1.EchoBiDiRequest
-	(*EchoBiDiResponse)(nil),     // 5: proto.rpc.examples.echo.v1.EchoBiDiResponse
+	(*EchoRequest)(nil),              // 0: proto.rpc.examples.echo.v1.EchoRequest
+	(*EchoResponse)(nil),             // 1: proto.rpc.examples.echo.v1.EchoResponse
+	(*EchoMultipleRequest)(nil),      // 2: proto.rpc.examples.echo.v1.EchoMultipleRequest
+	(*EchoMultipleResponse)(nil),     // 3: proto.rpc.examples.echo.v1.EchoMultipleResponse
+	(*EchoClientStreamRequest)(nil),  // 4: proto.rpc.examples.echo.v1.EchoClientStreamRequest
+	(*EchoClientStreamResponse)(nil), // 5: proto.rpc.examples.echo.v1.EchoClientStreamResponse
+	(*EchoBiDiRequest)(nil),          // 6: proto.rpc.examples.echo.v1.EchoBiDiRequest
+	(*EchoBiDiResponse)(nil),         // 7: proto.rpc.examples.echo.v1.EchoBiDiResponse
 }
 var file_proto_rpc_examples_echo_v1_echo_proto_depIdxs = []int32{
 	0, // 0: proto.rpc.examples.echo.v1.EchoService.Echo:input_type -> proto.rpc.examples.echo.v1.EchoRequest
 	2, // 1: proto.rpc.examples.echo.v1.EchoService.EchoMultiple:input_type -> proto.rpc.examples.echo.v1.EchoMultipleRequest
-	4, // 2: proto.rpc.examples.echo.v1.EchoService.EchoBiDi:input_type -> proto.rpc.examples.echo.v1.EchoBiDiRequest
-	1, // 3: proto.rpc.examples.echo.v1.EchoService.Echo:output_type -> proto.rpc.examples.echo.v1.EchoResponse
-	3, // 4: proto.rpc.examples.echo.v1.EchoService.EchoMultiple:output_type -> proto.rpc.examples.echo.v1.EchoMultipleResponse
-	5, // 5: proto.rpc.examples.echo.v1.EchoService.EchoBiDi:output_type -> proto.rpc.examples.echo.v1.EchoBiDiResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
+	4, // 2: proto.rpc.examples.echo.v1.EchoService.EchoClientStream:input_type -> proto.rpc.examples.echo.v1.EchoClientStreamRequest
+	6, // 3: proto.rpc.examples.echo.v1.EchoService.EchoBiDi:input_type -> proto.rpc.examples.echo.v1.EchoBiDiRequest
+	1, // 4: proto.rpc.examples.echo.v1.EchoService.Echo:output_type -> proto.rpc.examples.echo.v1.EchoResponse
+	3, // 5: proto.rpc.examples.echo.v1.EchoService.EchoMultiple:output_type -> proto.rpc.examples.echo.v1.EchoMultipleResponse
+	5, // 6: proto.rpc.examples.echo.v1.EchoService.EchoClientStream:output_type -> proto.rpc.examples.echo.v1.EchoClientStreamResponse
+	7, // 7: proto.rpc.examples.echo.v1.EchoService.EchoBiDi:output_type -> proto.rpc.examples.echo.v1.EchoBiDiResponse
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -449,7 +561,7 @@ func file_proto_rpc_examples_echo_v1_echo_proto_init() {
 			}
 		}
 		file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*EchoBiDiRequest); i {
+			switch v := v.(*EchoClientStreamRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -461,6 +573,30 @@ func file_proto_rpc_examples_echo_v1_echo_proto_init() {
 			}
 		}
 		file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EchoClientStreamResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EchoBiDiRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_rpc_examples_echo_v1_echo_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*EchoBiDiResponse); i {
 			case 0:
 				return &v.state
@@ -479,7 +615,7 @@ func file_proto_rpc_examples_echo_v1_echo_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_rpc_examples_echo_v1_echo_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
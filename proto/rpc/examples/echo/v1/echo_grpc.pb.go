@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion7
 type EchoServiceClient interface {
 	Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error)
 	EchoMultiple(ctx context.Context, in *EchoMultipleRequest, opts ...grpc.CallOption) (EchoService_EchoMultipleClient, error)
+	EchoClientStream(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoClientStreamClient, error)
 	EchoBiDi(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoBiDiClient, error)
 }
 
@@ -72,8 +73,42 @@ func (x *echoServiceEchoMultipleClient) Recv() (*EchoMultipleResponse, error) {
 	return m, nil
 }
 
+func (c *echoServiceClient) EchoClientStream(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoClientStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[1], "/proto.rpc.examples.echo.v1.EchoService/EchoClientStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &echoServiceEchoClientStreamClient{stream}
+	return x, nil
+}
+
+type EchoService_EchoClientStreamClient interface {
+	Send(*EchoClientStreamRequest) error
+	CloseAndRecv() (*EchoClientStreamResponse, error)
+	grpc.ClientStream
+}
+
+type echoServiceEchoClientStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *echoServiceEchoClientStreamClient) Send(m *EchoClientStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *echoServiceEchoClientStreamClient) CloseAndRecv() (*EchoClientStreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(EchoClientStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *echoServiceClient) EchoBiDi(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoBiDiClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[1], "/proto.rpc.examples.echo.v1.EchoService/EchoBiDi", opts...)
+	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[2], "/proto.rpc.examples.echo.v1.EchoService/EchoBiDi", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +144,7 @@ func (x *echoServiceEchoBiDiClient) Recv() (*EchoBiDiResponse, error) {
 type EchoServiceServer interface {
 	Echo(context.Context, *EchoRequest) (*EchoResponse, error)
 	EchoMultiple(*EchoMultipleRequest, EchoService_EchoMultipleServer) error
+	EchoClientStream(EchoService_EchoClientStreamServer) error
 	EchoBiDi(EchoService_EchoBiDiServer) error
 	mustEmbedUnimplementedEchoServiceServer()
 }
@@ -123,6 +159,9 @@ func (UnimplementedEchoServiceServer) Echo(context.Context, *EchoRequest) (*Echo
 func (UnimplementedEchoServiceServer) EchoMultiple(*EchoMultipleRequest, EchoService_EchoMultipleServer) error {
 	return status.Errorf(codes.Unimplemented, "method EchoMultiple not implemented")
 }
+func (UnimplementedEchoServiceServer) EchoClientStream(EchoService_EchoClientStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method EchoClientStream not implemented")
+}
 func (UnimplementedEchoServiceServer) EchoBiDi(EchoService_EchoBiDiServer) error {
 	return status.Errorf(codes.Unimplemented, "method EchoBiDi not implemented")
 }
@@ -178,6 +217,32 @@ func (x *echoServiceEchoMultipleServer) Send(m *EchoMultipleResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _EchoService_EchoClientStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EchoServiceServer).EchoClientStream(&echoServiceEchoClientStreamServer{stream})
+}
+
+type EchoService_EchoClientStreamServer interface {
+	SendAndClose(*EchoClientStreamResponse) error
+	Recv() (*EchoClientStreamRequest, error)
+	grpc.ServerStream
+}
+
+type echoServiceEchoClientStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *echoServiceEchoClientStreamServer) SendAndClose(m *EchoClientStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *echoServiceEchoClientStreamServer) Recv() (*EchoClientStreamRequest, error) {
+	m := new(EchoClientStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _EchoService_EchoBiDi_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(EchoServiceServer).EchoBiDi(&echoServiceEchoBiDiServer{stream})
 }
@@ -222,6 +287,11 @@ var EchoService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _EchoService_EchoMultiple_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "EchoClientStream",
+			Handler:       _EchoService_EchoClientStream_Handler,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "EchoBiDi",
 			Handler:       _EchoService_EchoBiDi_Handler,
@@ -0,0 +1,149 @@
+package protoutils
+
+import (
+	"hash/crc32"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// A Chunk is a part of a proto message split up by ChunkMessage so that it can be sent over
+// transports with small message limits, such as the SCTP data channels of WebRTC. Callers
+// carry its fields in a message of their own streaming RPC.
+type Chunk struct {
+	// Sequence is the position of the chunk, starting at zero.
+	Sequence uint64
+	// TotalSize is the size of the whole message. It is only set on the first chunk.
+	TotalSize uint64
+	// Checksum is the CRC-32 (Castagnoli) of the whole message. It is only set on the
+	// first chunk.
+	Checksum uint32
+	// Data is the part of the message in this chunk.
+	Data []byte
+}
+
+var chunkChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkMessage splits the marshaled message into chunks of at most maxChunkSize bytes of
+// data. A message that marshals to nothing is a single empty chunk.
+func ChunkMessage(msg proto.Message, maxChunkSize int) ([]Chunk, error) {
+	var chunks []Chunk
+	if err := SendChunked(msg, maxChunkSize, func(chunk Chunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// SendChunked splits the marshaled message into chunks of at most maxChunkSize bytes of
+// data and sends them in order.
+func SendChunked(msg proto.Message, maxChunkSize int, send func(chunk Chunk) error) error {
+	if maxChunkSize <= 0 {
+		return errors.New("expected positive max chunk size")
+	}
+	data, err := MarshalDeterministic(msg)
+	if err != nil {
+		return err
+	}
+	first := Chunk{
+		TotalSize: uint64(len(data)),
+		Checksum:  crc32.Checksum(data, chunkChecksumTable),
+	}
+	for seq := uint64(0); seq == 0 || len(data) > 0; seq++ {
+		chunk := Chunk{Sequence: seq}
+		if seq == 0 {
+			chunk = first
+		}
+		n := len(data)
+		if n > maxChunkSize {
+			n = maxChunkSize
+		}
+		chunk.Data, data = data[:n], data[n:]
+		if err := send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A Reassembler puts chunks of a message made by ChunkMessage or SendChunked back
+// together. It is not safe for concurrent use.
+type Reassembler struct {
+	maxSize uint64
+
+	started   bool
+	next      uint64
+	totalSize uint64
+	checksum  uint32
+	data      []byte
+}
+
+// NewReassembler returns a reassembler of messages of at most maxSize bytes, so that a
+// peer cannot make it hold arbitrarily much. If maxSize is zero, there is no limit.
+func NewReassembler(maxSize uint64) *Reassembler {
+	return &Reassembler{maxSize: maxSize}
+}
+
+// Add adds the next chunk and returns whether the message is complete. Chunks must be
+// added in order.
+func (r *Reassembler) Add(chunk Chunk) (bool, error) {
+	if r.started && uint64(len(r.data)) == r.totalSize {
+		return false, errors.New("message already complete")
+	}
+	if chunk.Sequence != r.next {
+		return false, errors.Errorf("expected chunk %d but got %d", r.next, chunk.Sequence)
+	}
+	if !r.started {
+		if r.maxSize != 0 && chunk.TotalSize > r.maxSize {
+			return false, errors.Errorf("message of %d bytes is larger than the max of %d", chunk.TotalSize, r.maxSize)
+		}
+		r.started = true
+		r.totalSize = chunk.TotalSize
+		r.checksum = chunk.Checksum
+		r.data = make([]byte, 0, chunk.TotalSize)
+	}
+	if uint64(len(r.data)+len(chunk.Data)) > r.totalSize {
+		return false, errors.Errorf("chunks exceed the message size of %d bytes", r.totalSize)
+	}
+	r.next++
+	r.data = append(r.data, chunk.Data...)
+	if uint64(len(r.data)) < r.totalSize {
+		return false, nil
+	}
+	if crc32.Checksum(r.data, chunkChecksumTable) != r.checksum {
+		return false, errors.New("message checksum mismatch")
+	}
+	return true, nil
+}
+
+// Unmarshal unmarshals the complete message into msg and readies the reassembler for the
+// next message.
+func (r *Reassembler) Unmarshal(msg proto.Message) error {
+	if !r.started || uint64(len(r.data)) != r.totalSize {
+		return errors.New("message not complete")
+	}
+	data := r.data
+	*r = Reassembler{maxSize: r.maxSize}
+	return proto.Unmarshal(data, msg)
+}
+
+// RecvChunked receives chunks until a message of at most maxSize bytes is complete and
+// unmarshals it into msg. If maxSize is zero, there is no limit.
+func RecvChunked(recv func() (Chunk, error), maxSize uint64, msg proto.Message) error {
+	r := NewReassembler(maxSize)
+	for {
+		chunk, err := recv()
+		if err != nil {
+			return err
+		}
+		done, err := r.Add(chunk)
+		if err != nil {
+			return err
+		}
+		if done {
+			return r.Unmarshal(msg)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package protoutils
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestChunkMessage(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"big": strings.Repeat("a", 1000)})
+	test.That(t, err, test.ShouldBeNil)
+	size := proto.Size(msg)
+
+	chunks, err := ChunkMessage(msg, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, chunks, test.ShouldHaveLength, (size+99)/100)
+	test.That(t, chunks[0].TotalSize, test.ShouldEqual, size)
+	test.That(t, chunks[0].Checksum, test.ShouldNotEqual, 0)
+	for i, chunk := range chunks {
+		test.That(t, chunk.Sequence, test.ShouldEqual, i)
+		test.That(t, len(chunk.Data), test.ShouldBeLessThanOrEqualTo, 100)
+		if i != 0 {
+			test.That(t, chunk.TotalSize, test.ShouldEqual, 0)
+		}
+	}
+
+	// a reassembler can be reused for message after message
+	r := NewReassembler(0)
+	for i := 0; i < 2; i++ {
+		for idx, chunk := range chunks {
+			done, err := r.Add(chunk)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, done, test.ShouldEqual, idx == len(chunks)-1)
+		}
+		var reassembled structpb.Struct
+		test.That(t, r.Unmarshal(&reassembled), test.ShouldBeNil)
+		test.That(t, proto.Equal(&reassembled, msg), test.ShouldBeTrue)
+	}
+
+	_, err = ChunkMessage(msg, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	empty, err := ChunkMessage(&structpb.Struct{}, 100)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, empty, test.ShouldHaveLength, 1)
+	done, err := NewReassembler(0).Add(empty[0])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, done, test.ShouldBeTrue)
+}
+
+func TestReassemblerErrors(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"big": strings.Repeat("a", 1000)})
+	test.That(t, err, test.ShouldBeNil)
+	chunks, err := ChunkMessage(msg, 100)
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = NewReassembler(100).Add(chunks[0])
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "larger than the max")
+
+	r := NewReassembler(0)
+	_, err = r.Add(chunks[1])
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expected chunk 0 but got 1")
+	test.That(t, r.Unmarshal(&structpb.Struct{}), test.ShouldNotBeNil)
+
+	r = NewReassembler(0)
+	corrupted := chunks[0]
+	corrupted.Checksum++
+	_, err = r.Add(corrupted)
+	test.That(t, err, test.ShouldBeNil)
+	var lastErr error
+	for _, chunk := range chunks[1:] {
+		_, lastErr = r.Add(chunk)
+	}
+	test.That(t, lastErr, test.ShouldNotBeNil)
+	test.That(t, lastErr.Error(), test.ShouldContainSubstring, "checksum")
+
+	r = NewReassembler(0)
+	oversized := chunks[0]
+	oversized.Data = make([]byte, oversized.TotalSize+1)
+	_, err = r.Add(oversized)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "exceed")
+}
+
+func TestSendRecvChunked(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{"big": strings.Repeat("a", 1000)})
+	test.That(t, err, test.ShouldBeNil)
+
+	stream := make(chan Chunk, 100)
+	test.That(t, SendChunked(msg, 64, func(chunk Chunk) error {
+		stream <- chunk
+		return nil
+	}), test.ShouldBeNil)
+	close(stream)
+	recv := func() (Chunk, error) {
+		chunk, ok := <-stream
+		if !ok {
+			return Chunk{}, io.EOF
+		}
+		return chunk, nil
+	}
+	var received structpb.Struct
+	test.That(t, RecvChunked(recv, 0, &received), test.ShouldBeNil)
+	test.That(t, proto.Equal(&received, msg), test.ShouldBeTrue)
+
+	// errors sending and receiving are returned as is
+	errSend := errors.New("whoops")
+	test.That(t, SendChunked(msg, 64, func(chunk Chunk) error { return errSend }), test.ShouldEqual, errSend)
+	test.That(t, RecvChunked(recv, 0, &received), test.ShouldEqual, io.EOF)
+}
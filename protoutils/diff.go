@@ -0,0 +1,198 @@
+package protoutils
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A FieldDiff is a field that differs between two messages.
+type FieldDiff struct {
+	// Path is the path to the field from the root message, such as
+	// fields["name"].list_value.values[2]. It is empty when the messages themselves differ,
+	// such as by type.
+	Path string
+	// A and B are the values of the field in each message, or <unset>.
+	A, B string
+}
+
+func (d FieldDiff) String() string {
+	if d.Path == "" {
+		return d.A + " != " + d.B
+	}
+	return d.Path + ": " + d.A + " != " + d.B
+}
+
+const unsetValue = "<unset>"
+
+// EqualWithDiff returns whether the messages are equal, as by proto.Equal, and if not
+// the fields that differ, with the deepest differing field of each path. Fields that are
+// only set in one message are reported with the other side as <unset>, except for fields
+// without presence, such as proto3 scalars, which are compared to their zero value.
+func EqualWithDiff(a, b proto.Message) (bool, []FieldDiff) {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return true, nil
+		}
+		return false, []FieldDiff{{A: formatMessage(a), B: formatMessage(b)}}
+	}
+	var diffs []FieldDiff
+	diffMessages("", a.ProtoReflect(), b.ProtoReflect(), &diffs)
+	return len(diffs) == 0, diffs
+}
+
+func formatMessage(msg proto.Message) string {
+	if msg == nil || !msg.ProtoReflect().IsValid() {
+		return unsetValue
+	}
+	return "{" + prototext.MarshalOptions{}.Format(msg) + "}"
+}
+
+func diffMessages(path string, a, b protoreflect.Message, diffs *[]FieldDiff) {
+	if a.Descriptor().FullName() != b.Descriptor().FullName() {
+		*diffs = append(*diffs, FieldDiff{
+			Path: path,
+			A:    string(a.Descriptor().FullName()),
+			B:    string(b.Descriptor().FullName()),
+		})
+		return
+	}
+	if a.IsValid() != b.IsValid() {
+		*diffs = append(*diffs, FieldDiff{
+			Path: path,
+			A:    formatMessage(a.Interface()),
+			B:    formatMessage(b.Interface()),
+		})
+		return
+	}
+
+	fields := a.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+		hasA, hasB := a.Has(fd), b.Has(fd)
+		switch {
+		case !hasA && !hasB:
+		case fd.IsList():
+			diffLists(fieldPath, fd, a.Get(fd).List(), b.Get(fd).List(), diffs)
+		case fd.IsMap():
+			diffMaps(fieldPath, fd, a.Get(fd).Map(), b.Get(fd).Map(), diffs)
+		case hasA != hasB && fd.HasPresence():
+			diffA, diffB := unsetValue, unsetValue
+			if hasA {
+				diffA = formatValue(fd, a.Get(fd))
+			} else {
+				diffB = formatValue(fd, b.Get(fd))
+			}
+			*diffs = append(*diffs, FieldDiff{Path: fieldPath, A: diffA, B: diffB})
+		default:
+			diffValues(fieldPath, fd, a.Get(fd), b.Get(fd), diffs)
+		}
+	}
+	if !bytes.Equal(a.GetUnknown(), b.GetUnknown()) {
+		*diffs = append(*diffs, FieldDiff{
+			Path: joinPath(path, "<unknown fields>"),
+			A:    fmt.Sprintf("%x", a.GetUnknown()),
+			B:    fmt.Sprintf("%x", b.GetUnknown()),
+		})
+	}
+}
+
+func diffLists(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.List, diffs *[]FieldDiff) {
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	for i := 0; i < n; i++ {
+		elemPath := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= a.Len():
+			*diffs = append(*diffs, FieldDiff{Path: elemPath, A: unsetValue, B: formatValue(fd, b.Get(i))})
+		case i >= b.Len():
+			*diffs = append(*diffs, FieldDiff{Path: elemPath, A: formatValue(fd, a.Get(i)), B: unsetValue})
+		default:
+			diffValues(elemPath, fd, a.Get(i), b.Get(i), diffs)
+		}
+	}
+}
+
+func diffMaps(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.Map, diffs *[]FieldDiff) {
+	keyPath := func(key protoreflect.MapKey) string {
+		return path + "[" + formatValue(fd.MapKey(), key.Value()) + "]"
+	}
+	for _, entry := range sortedMapEntries(fd, a) {
+		if !b.Has(entry.key) {
+			*diffs = append(*diffs, FieldDiff{
+				Path: keyPath(entry.key),
+				A:    formatValue(fd.MapValue(), entry.value),
+				B:    unsetValue,
+			})
+			continue
+		}
+		diffValues(keyPath(entry.key), fd.MapValue(), entry.value, b.Get(entry.key), diffs)
+	}
+	for _, entry := range sortedMapEntries(fd, b) {
+		if !a.Has(entry.key) {
+			*diffs = append(*diffs, FieldDiff{
+				Path: keyPath(entry.key),
+				A:    unsetValue,
+				B:    formatValue(fd.MapValue(), entry.value),
+			})
+		}
+	}
+}
+
+func diffValues(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.Value, diffs *[]FieldDiff) {
+	if fd.Message() != nil {
+		diffMessages(path, a.Message(), b.Message(), diffs)
+		return
+	}
+	if !scalarsEqual(fd, a, b) {
+		*diffs = append(*diffs, FieldDiff{Path: path, A: formatValue(fd, a), B: formatValue(fd, b)})
+	}
+}
+
+// scalarsEqual compares scalar values as proto.Equal does, with NaNs equal to each other.
+func scalarsEqual(fd protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	switch fd.Kind() {
+	case protoreflect.BytesKind:
+		return bytes.Equal(a.Bytes(), b.Bytes())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if math.IsNaN(a.Float()) || math.IsNaN(b.Float()) {
+			return math.IsNaN(a.Float()) && math.IsNaN(b.Float())
+		}
+		return a.Float() == b.Float()
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%q", v.Bytes())
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return formatMessage(v.Message().Interface())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,184 @@
+package protoutils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// MarshalDeterministic marshals the message with map entries in order of their keys so
+// that equal messages marshal the same within a binary. Use Hash to compare messages
+// across binaries.
+func MarshalDeterministic(msg proto.Message) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// Hash returns a SHA-256 digest of the message, in hex, that is the same for equal
+// messages no matter how they were built, such as for detecting changes to configuration
+// or as a cache key. Unlike hashing deterministically marshaled bytes, which the proto
+// library does not promise to keep stable, the digest is of a canonical encoding: fields
+// in order of their numbers, map entries in order of their keys, and Any messages by
+// their contents when their type is known. Unknown fields are included as they are.
+func Hash(msg proto.Message) string {
+	h := sha256.New()
+	w := hashWriter{h: h}
+	if msg == nil {
+		w.tag('N')
+	} else {
+		w.message(msg.ProtoReflect())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// A hashWriter writes the canonical encoding of values to a hash. Every value starts
+// with a tag of its kind and variable length values with their length so that the
+// encodings of different values never collide.
+type hashWriter struct {
+	h   hash.Hash
+	buf [8]byte
+}
+
+func (w *hashWriter) tag(tag byte) {
+	w.buf[0] = tag
+	w.h.Write(w.buf[:1])
+}
+
+func (w *hashWriter) uint(v uint64) {
+	binary.BigEndian.PutUint64(w.buf[:8], v)
+	w.h.Write(w.buf[:8])
+}
+
+func (w *hashWriter) bytes(tag byte, b []byte) {
+	w.tag(tag)
+	w.uint(uint64(len(b)))
+	w.h.Write(b)
+}
+
+func (w *hashWriter) message(m protoreflect.Message) {
+	if !m.IsValid() {
+		w.tag('N')
+		return
+	}
+	desc := m.Descriptor()
+	if desc.FullName() == "google.protobuf.Any" {
+		if any, ok := m.Interface().(*anypb.Any); ok {
+			if inner, err := any.UnmarshalNew(); err == nil {
+				w.tag('A')
+				w.message(inner.ProtoReflect())
+				return
+			}
+		}
+	}
+
+	w.bytes('M', []byte(desc.FullName()))
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Number() < fields[j].Number()
+	})
+	w.uint(uint64(len(fields)))
+	for _, fd := range fields {
+		w.uint(uint64(fd.Number()))
+		w.field(fd, m.Get(fd))
+	}
+	w.bytes('U', m.GetUnknown())
+}
+
+func (w *hashWriter) field(fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		w.tag('L')
+		w.uint(uint64(list.Len()))
+		for i := 0; i < list.Len(); i++ {
+			w.value(fd, list.Get(i))
+		}
+	case fd.IsMap():
+		entries := sortedMapEntries(fd, v.Map())
+		w.tag('P')
+		w.uint(uint64(len(entries)))
+		for _, entry := range entries {
+			w.value(fd.MapKey(), entry.key.Value())
+			w.value(fd.MapValue(), entry.value)
+		}
+	default:
+		w.value(fd, v)
+	}
+}
+
+func (w *hashWriter) value(fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if v.Bool() {
+			w.tag('T')
+		} else {
+			w.tag('F')
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		w.tag('i')
+		w.uint(uint64(v.Int()))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		w.tag('u')
+		w.uint(v.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		if math.IsNaN(f) {
+			// there are many NaNs but they are all the same to proto.Equal.
+			f = math.NaN()
+		}
+		w.tag('f')
+		w.uint(math.Float64bits(f))
+	case protoreflect.EnumKind:
+		w.tag('e')
+		w.uint(uint64(v.Enum()))
+	case protoreflect.StringKind:
+		w.bytes('s', []byte(v.String()))
+	case protoreflect.BytesKind:
+		w.bytes('b', v.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		w.message(v.Message())
+	}
+}
+
+type mapEntry struct {
+	key   protoreflect.MapKey
+	value protoreflect.Value
+}
+
+// sortedMapEntries returns the entries of the map in order of their keys.
+func sortedMapEntries(fd protoreflect.FieldDescriptor, m protoreflect.Map) []mapEntry {
+	entries := make([]mapEntry, 0, m.Len())
+	m.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+		entries = append(entries, mapEntry{key, value})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return mapKeyLess(fd.MapKey().Kind(), entries[i].key, entries[j].key)
+	})
+	return entries
+}
+
+func mapKeyLess(kind protoreflect.Kind, a, b protoreflect.MapKey) bool {
+	switch kind {
+	case protoreflect.BoolKind:
+		return !a.Bool() && b.Bool()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return a.Int() < b.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return a.Uint() < b.Uint()
+	default:
+		return a.String() < b.String()
+	}
+}
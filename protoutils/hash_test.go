@@ -0,0 +1,145 @@
+package protoutils
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestHash(t *testing.T) {
+	config := map[string]interface{}{
+		"name":    "arm",
+		"enabled": true,
+		"joints":  []interface{}{1.0, 2.0, 3.0},
+		"attrs":   map[string]interface{}{"a": "b", "c": "d", "e": "f", "g": "h"},
+	}
+	msg, err := structpb.NewStruct(config)
+	test.That(t, err, test.ShouldBeNil)
+	hash := Hash(msg)
+	test.That(t, hash, test.ShouldHaveLength, 64)
+
+	// maps are built in a different order each time
+	for i := 0; i < 10; i++ {
+		other, err := structpb.NewStruct(config)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, Hash(other), test.ShouldEqual, hash)
+	}
+
+	changed := proto.Clone(msg).(*structpb.Struct)
+	changed.Fields["joints"].GetListValue().Values[2] = structpb.NewNumberValue(4)
+	test.That(t, Hash(changed), test.ShouldNotEqual, hash)
+
+	t.Run("distinguishes types and values", func(t *testing.T) {
+		hashes := map[string]bool{}
+		for _, msg := range []proto.Message{
+			nil,
+			&structpb.Struct{},
+			&structpb.ListValue{},
+			wrapperspb.String(""),
+			wrapperspb.String("a"),
+			wrapperspb.Bytes([]byte("a")),
+			wrapperspb.Int64(1),
+			wrapperspb.UInt64(1),
+			wrapperspb.Double(1),
+			structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("ab")}}),
+			structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{
+				structpb.NewStringValue("a"), structpb.NewStringValue("b"),
+			}}),
+		} {
+			hash := Hash(msg)
+			test.That(t, hashes[hash], test.ShouldBeFalse)
+			hashes[hash] = true
+		}
+	})
+
+	t.Run("NaNs", func(t *testing.T) {
+		nan := math.Float64frombits(math.Float64bits(math.NaN()) + 1)
+		test.That(t, math.IsNaN(nan), test.ShouldBeTrue)
+		test.That(t, Hash(wrapperspb.Double(nan)), test.ShouldEqual, Hash(wrapperspb.Double(math.NaN())))
+	})
+
+	t.Run("any", func(t *testing.T) {
+		a, err := anypb.New(msg)
+		test.That(t, err, test.ShouldBeNil)
+		other, err := structpb.NewStruct(config)
+		test.That(t, err, test.ShouldBeNil)
+		b, err := anypb.New(other)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, Hash(a), test.ShouldEqual, Hash(b))
+		test.That(t, Hash(a), test.ShouldNotEqual, hash)
+	})
+}
+
+func TestEqualWithDiff(t *testing.T) {
+	a, err := structpb.NewStruct(map[string]interface{}{
+		"name":   "arm",
+		"joints": []interface{}{1.0, 2.0, 3.0},
+		"attrs":  map[string]interface{}{"a": "b"},
+		"old":    true,
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	equal, diffs := EqualWithDiff(a, proto.Clone(a))
+	test.That(t, equal, test.ShouldBeTrue)
+	test.That(t, diffs, test.ShouldBeEmpty)
+
+	b, err := structpb.NewStruct(map[string]interface{}{
+		"name":   "arm",
+		"joints": []interface{}{1.0, 4.0},
+		"attrs":  map[string]interface{}{"a": "c"},
+		"new":    "yes",
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	equal, diffs = EqualWithDiff(a, b)
+	test.That(t, equal, test.ShouldBeFalse)
+	var paths []string
+	for _, diff := range diffs {
+		paths = append(paths, diff.Path)
+	}
+	test.That(t, paths, test.ShouldResemble, []string{
+		`fields["attrs"].struct_value.fields["a"].string_value`,
+		`fields["joints"].list_value.values[1].number_value`,
+		`fields["joints"].list_value.values[2]`,
+		`fields["old"]`,
+		`fields["new"]`,
+	})
+	test.That(t, diffs[0].String(), test.ShouldEqual, `fields["attrs"].struct_value.fields["a"].string_value: "b" != "c"`)
+	test.That(t, diffs[1].String(), test.ShouldEqual, `fields["joints"].list_value.values[1].number_value: 2 != 4`)
+	test.That(t, diffs[2].B, test.ShouldEqual, "<unset>")
+	test.That(t, diffs[3].B, test.ShouldEqual, "<unset>")
+	test.That(t, diffs[4].A, test.ShouldEqual, "<unset>")
+
+	t.Run("oneof", func(t *testing.T) {
+		equal, diffs := EqualWithDiff(structpb.NewStringValue("1"), structpb.NewNumberValue(1))
+		test.That(t, equal, test.ShouldBeFalse)
+		test.That(t, diffs, test.ShouldResemble, []FieldDiff{
+			{Path: "number_value", A: "<unset>", B: "1"},
+			{Path: "string_value", A: `"1"`, B: "<unset>"},
+		})
+	})
+
+	t.Run("different types", func(t *testing.T) {
+		equal, diffs := EqualWithDiff(wrapperspb.String("a"), wrapperspb.Bytes([]byte("a")))
+		test.That(t, equal, test.ShouldBeFalse)
+		test.That(t, diffs, test.ShouldResemble, []FieldDiff{
+			{A: "google.protobuf.StringValue", B: "google.protobuf.BytesValue"},
+		})
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		equal, diffs := EqualWithDiff(nil, nil)
+		test.That(t, equal, test.ShouldBeTrue)
+		test.That(t, diffs, test.ShouldBeEmpty)
+
+		equal, diffs = EqualWithDiff(nil, wrapperspb.String("a"))
+		test.That(t, equal, test.ShouldBeFalse)
+		test.That(t, diffs, test.ShouldHaveLength, 1)
+		test.That(t, diffs[0].A, test.ShouldEqual, "<unset>")
+	})
+}
@@ -0,0 +1,367 @@
+package protoutils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// A NonFinitePolicy determines how NaN and infinite numbers, which JSON cannot represent,
+// are converted to and from structpb values.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError fails the conversion.
+	NonFiniteError NonFinitePolicy = iota
+	// NonFiniteNull converts them to null.
+	NonFiniteNull
+	// NonFiniteString converts them to the strings "NaN", "Infinity", and "-Infinity", as
+	// protojson does.
+	NonFiniteString
+)
+
+// StructOptions control how MapToStruct, ToValue, and StructToMap convert values.
+type StructOptions struct {
+	NonFinite NonFinitePolicy
+}
+
+// A structConverter converts values of a registered type.
+type structConverter struct {
+	to   func(v interface{}) (interface{}, error)
+	from mapstructure.DecodeHookFuncType
+}
+
+var (
+	structConvertersMu sync.RWMutex
+	structConverters   = map[reflect.Type]structConverter{}
+)
+
+// RegisterStructConverter registers how values of type T are converted to structpb values
+// by MapToStruct and ToValue, and back by DecodeStruct. to returns a value that can itself
+// be converted, such as a string or a map; from is given such a value as decoded from a
+// structpb value. Converters for time.Time (RFC 3339 strings) and []byte (base64 strings)
+// are built in but may be replaced.
+func RegisterStructConverter[T any](to func(v T) (interface{}, error), from func(v interface{}) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	structConvertersMu.Lock()
+	defer structConvertersMu.Unlock()
+	structConverters[t] = structConverter{
+		to: func(v interface{}) (interface{}, error) {
+			return to(v.(T))
+		},
+		from: func(fromType, toType reflect.Type, v interface{}) (interface{}, error) {
+			if toType != t || fromType == t {
+				return v, nil
+			}
+			return from(v)
+		},
+	}
+}
+
+func lookupStructConverter(t reflect.Type) (structConverter, bool) {
+	structConvertersMu.RLock()
+	defer structConvertersMu.RUnlock()
+	converter, ok := structConverters[t]
+	return converter, ok
+}
+
+func init() {
+	RegisterStructConverter(func(v time.Time) (interface{}, error) {
+		return v.Format(time.RFC3339Nano), nil
+	}, func(v interface{}) (time.Time, error) {
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, errors.Errorf("expected time as a string but got %T", v)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	})
+	RegisterStructConverter(func(v []byte) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString(v), nil
+	}, func(v interface{}) ([]byte, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("expected bytes as a base64 string but got %T", v)
+		}
+		return base64.StdEncoding.DecodeString(s)
+	})
+}
+
+// MapToStruct converts a map of native Go values to a *structpb.Struct. Unlike
+// structpb.NewStruct, it accepts values of any type: numbers of any kind, slices and
+// arrays of anything, maps keyed by strings or fmt.Stringers, structs (by their JSON
+// field names), pointers, and types with a registered converter.
+func MapToStruct(m map[string]interface{}, opts StructOptions) (*structpb.Struct, error) {
+	fields := make(map[string]*structpb.Value, len(m))
+	for key, v := range m {
+		value, err := ToValue(v, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %q", key)
+		}
+		fields[key] = value
+	}
+	return &structpb.Struct{Fields: fields}, nil
+}
+
+// ToValue converts a native Go value to a *structpb.Value. See MapToStruct.
+func ToValue(v interface{}, opts StructOptions) (*structpb.Value, error) {
+	if v == nil {
+		return structpb.NewNullValue(), nil
+	}
+	switch v := v.(type) {
+	case *structpb.Value:
+		return v, nil
+	case *structpb.Struct:
+		return structpb.NewStructValue(v), nil
+	case *structpb.ListValue:
+		return structpb.NewListValue(v), nil
+	}
+	return toValue(reflect.ValueOf(v), opts)
+}
+
+func toValue(v reflect.Value, opts StructOptions) (*structpb.Value, error) {
+	if !v.IsValid() {
+		return structpb.NewNullValue(), nil
+	}
+	if converter, ok := lookupStructConverter(v.Type()); ok {
+		converted, err := converter.to(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return ToValue(converted, opts)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return structpb.NewBoolValue(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return structpb.NewNumberValue(float64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return structpb.NewNumberValue(float64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return numberToValue(v.Float(), opts)
+	case reflect.String:
+		return structpb.NewStringValue(v.String()), nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return structpb.NewNullValue(), nil
+		}
+		return toValue(v.Elem(), opts)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return structpb.NewNullValue(), nil
+		}
+		values := make([]*structpb.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := toValue(v.Index(i), opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "index %d", i)
+			}
+			values = append(values, value)
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return structpb.NewNullValue(), nil
+		}
+		fields := make(map[string]*structpb.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := mapKeyString(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			value, err := toValue(iter.Value(), opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %q", key)
+			}
+			fields[key] = value
+		}
+		return structpb.NewStructValue(&structpb.Struct{Fields: fields}), nil
+	case reflect.Struct:
+		return structToValue(v, opts)
+	case reflect.Complex64, reflect.Complex128, reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Invalid:
+		fallthrough
+	default:
+		return nil, errors.Errorf("cannot convert value of type %s", v.Type())
+	}
+}
+
+// numberToValue converts a number, applying the policy to NaN and infinities.
+func numberToValue(f float64, opts StructOptions) (*structpb.Value, error) {
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return structpb.NewNumberValue(f), nil
+	}
+	switch opts.NonFinite {
+	case NonFiniteNull:
+		return structpb.NewNullValue(), nil
+	case NonFiniteString:
+		return structpb.NewStringValue(nonFiniteString(f)), nil
+	case NonFiniteError:
+		fallthrough
+	default:
+		return nil, errors.Errorf("cannot convert non-finite number %v", f)
+	}
+}
+
+func nonFiniteString(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return "NaN"
+	}
+}
+
+func mapKeyString(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if stringer, ok := key.Interface().(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+	return "", errors.Errorf("map keys of type %s are not strings and do not implement String", key.Type())
+}
+
+// structToValue converts the exported fields of a struct, named and omitted according to
+// their JSON tags.
+func structToValue(v reflect.Value, opts StructOptions) (*structpb.Value, error) {
+	t := v.Type()
+	fields := make(map[string]*structpb.Value, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sField := t.Field(i)
+		if !sField.IsExported() {
+			continue
+		}
+		tag := sField.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		key := sField.Name
+		if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+			key = tagName
+		}
+		field := v.Field(i)
+		if strings.Contains(tag, "omitempty") && isEmptyValue(field) {
+			continue
+		}
+		value, err := toValue(field, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %q", key)
+		}
+		fields[key] = value
+	}
+	return structpb.NewStructValue(&structpb.Struct{Fields: fields}), nil
+}
+
+// StructToMap converts a *structpb.Struct to a map of native Go values: nil, bool,
+// float64, string, []interface{}, and map[string]interface{}. Unlike Struct.AsMap, numbers
+// that are NaN or infinite are converted according to the policy. Use DecodeStruct to
+// convert to specific types instead.
+func StructToMap(s *structpb.Struct, opts StructOptions) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(s.GetFields()))
+	for key, value := range s.GetFields() {
+		v, err := fromValue(value, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %q", key)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func fromValue(value *structpb.Value, opts StructOptions) (interface{}, error) {
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_BoolValue:
+		return kind.BoolValue, nil
+	case *structpb.Value_NumberValue:
+		f := kind.NumberValue
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, nil
+		}
+		switch opts.NonFinite {
+		case NonFiniteNull:
+			return nil, nil
+		case NonFiniteString:
+			return nonFiniteString(f), nil
+		case NonFiniteError:
+			fallthrough
+		default:
+			return nil, errors.Errorf("cannot convert non-finite number %v", f)
+		}
+	case *structpb.Value_StringValue:
+		return kind.StringValue, nil
+	case *structpb.Value_ListValue:
+		values := kind.ListValue.GetValues()
+		list := make([]interface{}, 0, len(values))
+		for i, elem := range values {
+			v, err := fromValue(elem, opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "index %d", i)
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case *structpb.Value_StructValue:
+		return StructToMap(kind.StructValue, opts)
+	case *structpb.Value_NullValue, nil:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unknown value kind %T", kind)
+	}
+}
+
+// DecodeStruct decodes a *structpb.Struct into out, a pointer to a struct or map, by the
+// JSON names of fields. Values of types with a registered converter, such as time.Time
+// and []byte, are converted back from what MapToStruct converted them to. NaN and
+// infinite numbers may be given as the strings of NonFiniteString.
+func DecodeStruct(s *structpb.Struct, out interface{}) error {
+	m, err := StructToMap(s, StructOptions{NonFinite: NonFiniteString})
+	if err != nil {
+		return err
+	}
+	structConvertersMu.RLock()
+	hooks := make([]mapstructure.DecodeHookFunc, 0, len(structConverters)+1)
+	for _, converter := range structConverters {
+		hooks = append(hooks, converter.from)
+	}
+	structConvertersMu.RUnlock()
+	hooks = append(hooks, nonFiniteDecodeHook)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(hooks...),
+		Result:           out,
+		TagName:          "json",
+		WeaklyTypedInput: false,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(m)
+}
+
+// nonFiniteDecodeHook decodes the strings of NonFiniteString into floats.
+func nonFiniteDecodeHook(fromType, toType reflect.Type, v interface{}) (interface{}, error) {
+	if fromType.Kind() != reflect.String || (toType.Kind() != reflect.Float64 && toType.Kind() != reflect.Float32) {
+		return v, nil
+	}
+	switch v {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,138 @@
+package protoutils
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type structpbTestCelsius float64
+
+type structpbTestInner struct {
+	Name  string    `json:"name"`
+	At    time.Time `json:"at"`
+	Extra string    `json:"extra,omitempty"`
+}
+
+type structpbTestOuter struct {
+	ID      int                    `json:"id"`
+	Ratio   float64                `json:"ratio"`
+	Data    []byte                 `json:"data"`
+	Inners  []structpbTestInner    `json:"inners"`
+	Grid    [][]int                `json:"grid"`
+	Labels  map[string]string      `json:"labels"`
+	Any     map[string]interface{} `json:"any"`
+	Temp    structpbTestCelsius    `json:"temp"`
+	Skipped string                 `json:"-"`
+	hidden  string
+}
+
+func TestMapToStruct(t *testing.T) {
+	at := time.Date(2023, 5, 6, 7, 8, 9, 10, time.UTC)
+	s, err := MapToStruct(map[string]interface{}{
+		"nil":    nil,
+		"uint":   uint8(3),
+		"time":   at,
+		"bytes":  []byte("hello"),
+		"nested": [][]string{{"a"}, {"b", "c"}},
+		"ptr":    &at,
+		"struct": structpbTestInner{Name: "foo", At: at},
+	}, StructOptions{})
+	test.That(t, err, test.ShouldBeNil)
+
+	m := s.AsMap()
+	test.That(t, m["nil"], test.ShouldBeNil)
+	test.That(t, m["uint"], test.ShouldEqual, 3.0)
+	test.That(t, m["time"], test.ShouldEqual, "2023-05-06T07:08:09.00000001Z")
+	test.That(t, m["ptr"], test.ShouldEqual, "2023-05-06T07:08:09.00000001Z")
+	test.That(t, m["bytes"], test.ShouldEqual, "aGVsbG8=")
+	test.That(t, m["nested"], test.ShouldResemble, []interface{}{[]interface{}{"a"}, []interface{}{"b", "c"}})
+	test.That(t, m["struct"], test.ShouldResemble, map[string]interface{}{
+		"name": "foo",
+		"at":   "2023-05-06T07:08:09.00000001Z",
+	})
+
+	_, err = MapToStruct(map[string]interface{}{"bad": make(chan int)}, StructOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "bad")
+}
+
+func TestStructNonFinite(t *testing.T) {
+	m := map[string]interface{}{"nan": math.NaN(), "inf": []float64{math.Inf(1), math.Inf(-1)}}
+	_, err := MapToStruct(m, StructOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "non-finite")
+
+	s, err := MapToStruct(m, StructOptions{NonFinite: NonFiniteNull})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.AsMap(), test.ShouldResemble, map[string]interface{}{"nan": nil, "inf": []interface{}{nil, nil}})
+
+	s, err = MapToStruct(m, StructOptions{NonFinite: NonFiniteString})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.AsMap(), test.ShouldResemble, map[string]interface{}{
+		"nan": "NaN",
+		"inf": []interface{}{"Infinity", "-Infinity"},
+	})
+
+	// numbers in structs may be non-finite too
+	s = &structpb.Struct{Fields: map[string]*structpb.Value{"nan": structpb.NewNumberValue(math.NaN())}}
+	_, err = StructToMap(s, StructOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	converted, err := StructToMap(s, StructOptions{NonFinite: NonFiniteString})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, converted, test.ShouldResemble, map[string]interface{}{"nan": "NaN"})
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	RegisterStructConverter(func(v structpbTestCelsius) (interface{}, error) {
+		return map[string]interface{}{"celsius": float64(v)}, nil
+	}, func(v interface{}) (structpbTestCelsius, error) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return 0, errors.Errorf("unexpected %T", v)
+		}
+		celsius, _ := m["celsius"].(float64)
+		return structpbTestCelsius(celsius), nil
+	})
+
+	at := time.Date(2023, 5, 6, 7, 8, 9, 10, time.FixedZone("somewhere", 3600))
+	outer := structpbTestOuter{
+		ID:      5,
+		Ratio:   math.Inf(1),
+		Data:    []byte{0, 1, 2, 255},
+		Inners:  []structpbTestInner{{Name: "a", At: at}, {Name: "b", At: at, Extra: "extra"}},
+		Grid:    [][]int{{1, 2}, {3}},
+		Labels:  map[string]string{"foo": "bar"},
+		Any:     map[string]interface{}{"list": []interface{}{"x", 1.5, true}},
+		Temp:    21.5,
+		Skipped: "skipped",
+		hidden:  "hidden",
+	}
+	value, err := ToValue(outer, StructOptions{NonFinite: NonFiniteString})
+	test.That(t, err, test.ShouldBeNil)
+	s := value.GetStructValue()
+	test.That(t, s, test.ShouldNotBeNil)
+	test.That(t, s.Fields, test.ShouldNotContainKey, "Skipped")
+	test.That(t, s.Fields, test.ShouldNotContainKey, "hidden")
+	test.That(t, s.Fields["temp"].GetStructValue().AsMap(), test.ShouldResemble, map[string]interface{}{"celsius": 21.5})
+
+	var decoded structpbTestOuter
+	test.That(t, DecodeStruct(s, &decoded), test.ShouldBeNil)
+	outer.Skipped = ""
+	outer.hidden = ""
+	test.That(t, decoded.Inners[0].At.Equal(at), test.ShouldBeTrue)
+	test.That(t, decoded.Inners[1].At.Equal(at), test.ShouldBeTrue)
+	decoded.Inners[0].At, decoded.Inners[1].At = at, at
+	test.That(t, decoded, test.ShouldResemble, outer)
+
+	err = DecodeStruct(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"data": structpb.NewStringValue("not base64!"),
+	}}, &decoded)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, strings.Contains(err.Error(), "data"), test.ShouldBeTrue)
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Keyed holds a limiter for each key, such as each caller, so that keys are limited
+// separately. Limiters are made on first use and forgotten once unused for the TTL, so
+// the TTL should be at least how long a limiter takes to recover fully (e.g. the time to
+// refill a TokenBucket or the window of a SlidingWindow); otherwise forgetting a limiter
+// gives its key a fresh one early.
+type Keyed[K comparable, L Limiter] struct {
+	ttl        time.Duration
+	newLimiter func(key K) L
+
+	mu        sync.Mutex
+	limiters  map[K]*keyedLimiter[L]
+	lastSweep time.Time
+}
+
+type keyedLimiter[L Limiter] struct {
+	limiter  L
+	lastUsed time.Time
+}
+
+// NewKeyed returns a map of limiters made by newLimiter that are forgotten once unused
+// for the TTL.
+func NewKeyed[K comparable, L Limiter](ttl time.Duration, newLimiter func(key K) L) *Keyed[K, L] {
+	return &Keyed[K, L]{
+		ttl:        ttl,
+		newLimiter: newLimiter,
+		limiters:   map[K]*keyedLimiter[L]{},
+		lastSweep:  time.Now(),
+	}
+}
+
+// Get returns the limiter of the key, making it if need be. Limiters unused for the TTL
+// are swept away as a side effect, at most once every TTL.
+func (k *Keyed[K, L]) Get(key K) L {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	if now.Sub(k.lastSweep) >= k.ttl {
+		k.sweepLocked(now)
+	}
+	entry, ok := k.limiters[key]
+	if !ok {
+		entry = &keyedLimiter[L]{limiter: k.newLimiter(key)}
+		k.limiters[key] = entry
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+// TryAcquire takes a permit from the limiter of the key if one is available right away.
+func (k *Keyed[K, L]) TryAcquire(key K) bool {
+	ok, _ := k.TryAcquireN(key, 1)
+	return ok
+}
+
+// TryAcquireN takes n permits from the limiter of the key if they are available right
+// away. Otherwise it takes none and returns how long until they may be.
+func (k *Keyed[K, L]) TryAcquireN(key K, n int) (bool, time.Duration) {
+	return k.Get(key).TryAcquireN(n)
+}
+
+// WaitN waits until n permits are available from the limiter of the key and takes them.
+func (k *Keyed[K, L]) WaitN(ctx context.Context, key K, n int) error {
+	return k.Get(key).WaitN(ctx, n)
+}
+
+// Sweep forgets the limiters unused for the TTL.
+func (k *Keyed[K, L]) Sweep() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.sweepLocked(time.Now())
+}
+
+// sweepLocked forgets the limiters unused for the TTL as of now. It must be called with
+// mu held.
+func (k *Keyed[K, L]) sweepLocked(now time.Time) {
+	for key, entry := range k.limiters {
+		if now.Sub(entry.lastUsed) >= k.ttl {
+			delete(k.limiters, key)
+		}
+	}
+	k.lastSweep = now
+}
+
+// Len returns how many keys have a limiter.
+func (k *Keyed[K, L]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.limiters)
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestKeyed(t *testing.T) {
+	made := map[string]int{}
+	keyed := NewKeyed(20*time.Millisecond, func(key string) *TokenBucket {
+		made[key]++
+		return NewTokenBucket(0.001, 1)
+	})
+
+	// keys are limited separately
+	test.That(t, keyed.TryAcquire("foo"), test.ShouldBeTrue)
+	test.That(t, keyed.TryAcquire("foo"), test.ShouldBeFalse)
+	test.That(t, keyed.TryAcquire("bar"), test.ShouldBeTrue)
+	ok, retryAfter := keyed.TryAcquireN("bar", 1)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, retryAfter, test.ShouldBeGreaterThan, time.Second)
+	test.That(t, keyed.Get("foo"), test.ShouldEqual, keyed.Get("foo"))
+	test.That(t, keyed.Len(), test.ShouldEqual, 2)
+	test.That(t, made, test.ShouldResemble, map[string]int{"foo": 1, "bar": 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	test.That(t, keyed.WaitN(ctx, "foo", 1), test.ShouldEqual, context.DeadlineExceeded)
+
+	// limiters unused for the TTL are forgotten
+	time.Sleep(30 * time.Millisecond)
+	keyed.Sweep()
+	test.That(t, keyed.Len(), test.ShouldEqual, 0)
+	test.That(t, keyed.TryAcquire("foo"), test.ShouldBeTrue)
+	test.That(t, made["foo"], test.ShouldEqual, 2)
+
+	// and are swept away when others are used
+	time.Sleep(30 * time.Millisecond)
+	test.That(t, keyed.TryAcquire("baz"), test.ShouldBeTrue)
+	test.That(t, keyed.Len(), test.ShouldEqual, 1)
+}
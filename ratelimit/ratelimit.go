@@ -0,0 +1,49 @@
+// Package ratelimit contains limiters of how often something may happen, such as calls
+// to a method or bytes written to a connection, and maps of them by key to limit each
+// caller separately.
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+// A Limiter allows events up to some rate. Events are taken in permits, such as one per
+// call or one per byte.
+type Limiter interface {
+	// TryAcquireN takes n permits if they are available right away. Otherwise it takes
+	// none and returns how long until they may be.
+	TryAcquireN(n int) (bool, time.Duration)
+
+	// WaitN waits until n permits are available and takes them. It fails if the context
+	// is done first or if n is more than the limiter can ever allow at once.
+	WaitN(ctx context.Context, n int) error
+}
+
+// ErrExceedsLimit is returned when waiting for more permits than a limiter can ever
+// allow at once.
+var ErrExceedsLimit = errors.New("requested more permits than the limit allows at once")
+
+// waitN waits by repeatedly trying to take n permits from the limiter, which may allow
+// at most max at once. Waiters are not served in any particular order.
+func waitN(ctx context.Context, l Limiter, n, max int) error {
+	if n > max {
+		return errors.Wrapf(ErrExceedsLimit, "%d > %d", n, max)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, retryAfter := l.TryAcquireN(n)
+		if ok {
+			return nil
+		}
+		if !utils.SelectContextOrWait(ctx, retryAfter) {
+			return ctx.Err()
+		}
+	}
+}
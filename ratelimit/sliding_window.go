@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A SlidingWindow is a Limiter that allows up to a number of permits within any window
+// of time. Unlike a TokenBucket, permits taken are only given back all at once when
+// they leave the window, so it strictly bounds how many events there are over any
+// stretch of the window's length.
+type SlidingWindow struct {
+	limit  int
+	window time.Duration
+
+	mu sync.Mutex
+	// events are the permits taken within the window, oldest first.
+	events []slidingWindowEvent
+	taken  int
+}
+
+type slidingWindowEvent struct {
+	at time.Time
+	n  int
+}
+
+// NewSlidingWindow returns a limiter allowing up to limit permits within any window.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{limit: limit, window: window}
+}
+
+// TryAcquire takes a permit if one is available right away.
+func (w *SlidingWindow) TryAcquire() bool {
+	ok, _ := w.TryAcquireN(1)
+	return ok
+}
+
+// TryAcquireN takes n permits if they are available right away. Otherwise it takes none
+// and returns how long until enough of those taken leave the window.
+func (w *SlidingWindow) TryAcquireN(n int) (bool, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	w.expireLocked(now)
+	if w.taken+n <= w.limit {
+		if n > 0 {
+			w.events = append(w.events, slidingWindowEvent{at: now, n: n})
+			w.taken += n
+		}
+		return true, 0
+	}
+	if n > w.limit {
+		return false, w.window
+	}
+	remaining := w.taken
+	for _, event := range w.events {
+		remaining -= event.n
+		if remaining+n <= w.limit {
+			return false, event.at.Add(w.window).Sub(now)
+		}
+	}
+	return false, w.window
+}
+
+// Wait waits until a permit is available and takes it.
+func (w *SlidingWindow) Wait(ctx context.Context) error {
+	return w.WaitN(ctx, 1)
+}
+
+// WaitN waits until n permits are available and takes them. It fails right away if n is
+// more than the limit.
+func (w *SlidingWindow) WaitN(ctx context.Context, n int) error {
+	return waitN(ctx, w, n, w.limit)
+}
+
+// Taken returns how many permits were taken within the window.
+func (w *SlidingWindow) Taken() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expireLocked(time.Now())
+	return w.taken
+}
+
+// expireLocked forgets the events that left the window. It must be called with mu held.
+func (w *SlidingWindow) expireLocked(now time.Time) {
+	expired := 0
+	for expired < len(w.events) && !now.Before(w.events[expired].at.Add(w.window)) {
+		w.taken -= w.events[expired].n
+		expired++
+	}
+	if expired == 0 {
+		return
+	}
+	// shift rather than reslice so the events do not hold on to ever more memory.
+	w.events = w.events[:copy(w.events, w.events[expired:])]
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	window := NewSlidingWindow(3, 50*time.Millisecond)
+	test.That(t, window.TryAcquire(), test.ShouldBeTrue)
+	ok, retryAfter := window.TryAcquireN(2)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, retryAfter, test.ShouldEqual, 0)
+	test.That(t, window.Taken(), test.ShouldEqual, 3)
+
+	ok, retryAfter = window.TryAcquireN(1)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, retryAfter, test.ShouldBeGreaterThan, 0)
+	test.That(t, retryAfter, test.ShouldBeLessThanOrEqualTo, 50*time.Millisecond)
+	test.That(t, window.Taken(), test.ShouldEqual, 3)
+
+	// permits come back all at once when they leave the window
+	test.That(t, window.WaitN(context.Background(), 3), test.ShouldBeNil)
+	test.That(t, window.Taken(), test.ShouldEqual, 3)
+
+	err := window.WaitN(context.Background(), 4)
+	test.That(t, errors.Is(err, ErrExceedsLimit), test.ShouldBeTrue)
+	ok, retryAfter = window.TryAcquireN(4)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, retryAfter, test.ShouldEqual, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	test.That(t, window.Wait(ctx), test.ShouldEqual, context.DeadlineExceeded)
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A TokenBucketLimit allows bursts of up to Burst events, after which events are allowed
+// at a constant Rate. Its methods work on a TokenBucketState so that the state of a bucket
+// may be kept anywhere, such as in a database shared by replicas; TokenBucket keeps it in
+// memory.
+type TokenBucketLimit struct {
+	// Rate is how many permits per second are replenished.
+	Rate float64
+	// Burst is the most permits the bucket holds.
+	Burst int
+}
+
+// Validate returns an error if the limit allows nothing.
+func (l TokenBucketLimit) Validate() error {
+	if l.Rate <= 0 {
+		return errors.New("expected positive rate")
+	}
+	if l.Burst <= 0 {
+		return errors.New("expected positive burst")
+	}
+	return nil
+}
+
+// TokenBucketState is the state of a token bucket. The zero value is a full bucket.
+type TokenBucketState struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Replenish returns the state as of now with the tokens gained since it was last updated.
+func (l TokenBucketLimit) Replenish(state TokenBucketState, now time.Time) TokenBucketState {
+	if state.UpdatedAt.IsZero() {
+		return TokenBucketState{Tokens: float64(l.Burst), UpdatedAt: now}
+	}
+	if elapsed := now.Sub(state.UpdatedAt); elapsed > 0 {
+		state.Tokens = math.Min(float64(l.Burst), state.Tokens+elapsed.Seconds()*l.Rate)
+		state.UpdatedAt = now
+	}
+	return state
+}
+
+// Take takes n tokens from the state as of now if it has them. Otherwise it takes none
+// and returns how long until it will have them.
+func (l TokenBucketLimit) Take(state TokenBucketState, now time.Time, n int) (TokenBucketState, time.Duration) {
+	state = l.Replenish(state, now)
+	if missing := float64(n) - state.Tokens; missing > 0 {
+		// never report no wait while tokens are missing, however few.
+		if retryAfter := l.durationFor(missing); retryAfter > 0 {
+			return state, retryAfter
+		}
+		return state, time.Nanosecond
+	}
+	state.Tokens -= float64(n)
+	return state, 0
+}
+
+// UntilFull returns how long until the bucket is full again, after which its state no
+// longer matters.
+func (l TokenBucketLimit) UntilFull(state TokenBucketState) time.Duration {
+	if state.UpdatedAt.IsZero() {
+		return 0
+	}
+	return l.durationFor(float64(l.Burst) - state.Tokens)
+}
+
+// durationFor returns how long it takes to replenish the given number of tokens.
+func (l TokenBucketLimit) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// A TokenBucket is a Limiter that allows bursts of up to its capacity and is replenished
+// at a constant rate. It starts out full.
+type TokenBucket struct {
+	limit TokenBucketLimit
+
+	mu    sync.Mutex
+	state TokenBucketState
+}
+
+// NewTokenBucket returns a full bucket of burst tokens replenished at rate tokens per
+// second.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{limit: TokenBucketLimit{Rate: rate, Burst: burst}}
+}
+
+// TryAcquire takes a token if one is available right away.
+func (b *TokenBucket) TryAcquire() bool {
+	ok, _ := b.TryAcquireN(1)
+	return ok
+}
+
+// TryAcquireN takes n tokens if they are available right away. Otherwise it takes none
+// and returns how long until they will be.
+func (b *TokenBucket) TryAcquireN(n int) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var retryAfter time.Duration
+	b.state, retryAfter = b.limit.Take(b.state, time.Now(), n)
+	return retryAfter == 0, retryAfter
+}
+
+// Wait waits until a token is available and takes it.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return b.WaitN(ctx, 1)
+}
+
+// WaitN waits until n tokens are available and takes them. It fails right away if n is
+// more than the bucket holds.
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	return waitN(ctx, b, n, b.limit.Burst)
+}
+
+// Tokens returns how many tokens are available right now.
+func (b *TokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = b.limit.Replenish(b.state, time.Now())
+	return b.state.Tokens
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestTokenBucketLimit(t *testing.T) {
+	limit := TokenBucketLimit{Rate: 2, Burst: 3}
+	test.That(t, limit.Validate(), test.ShouldBeNil)
+	test.That(t, TokenBucketLimit{Burst: 1}.Validate(), test.ShouldNotBeNil)
+	test.That(t, TokenBucketLimit{Rate: 1}.Validate(), test.ShouldNotBeNil)
+
+	now := time.Now()
+	var state TokenBucketState
+	test.That(t, limit.UntilFull(state), test.ShouldEqual, 0)
+
+	// the zero state is full
+	state, retryAfter := limit.Take(state, now, 3)
+	test.That(t, retryAfter, test.ShouldEqual, 0)
+	test.That(t, state.Tokens, test.ShouldEqual, 0)
+	test.That(t, limit.UntilFull(state), test.ShouldEqual, 1500*time.Millisecond)
+
+	state, retryAfter = limit.Take(state, now, 1)
+	test.That(t, retryAfter, test.ShouldEqual, 500*time.Millisecond)
+	test.That(t, state.Tokens, test.ShouldEqual, 0)
+
+	state, retryAfter = limit.Take(state, now.Add(500*time.Millisecond), 1)
+	test.That(t, retryAfter, test.ShouldEqual, 0)
+	test.That(t, state.Tokens, test.ShouldEqual, 0)
+
+	// never more than the burst
+	state = limit.Replenish(state, now.Add(time.Hour))
+	test.That(t, state.Tokens, test.ShouldEqual, 3)
+	test.That(t, limit.UntilFull(state), test.ShouldEqual, 0)
+
+	// going back in time does not replenish anything
+	state, _ = limit.Take(state, now.Add(time.Hour), 3)
+	state = limit.Replenish(state, now)
+	test.That(t, state.Tokens, test.ShouldEqual, 0)
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(20, 2)
+	test.That(t, bucket.Tokens(), test.ShouldEqual, 2)
+	test.That(t, bucket.TryAcquire(), test.ShouldBeTrue)
+	test.That(t, bucket.TryAcquire(), test.ShouldBeTrue)
+	ok, retryAfter := bucket.TryAcquireN(1)
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, retryAfter, test.ShouldBeGreaterThan, 0)
+	test.That(t, retryAfter, test.ShouldBeLessThanOrEqualTo, 50*time.Millisecond)
+
+	test.That(t, bucket.Wait(context.Background()), test.ShouldBeNil)
+	test.That(t, bucket.WaitN(context.Background(), 2), test.ShouldBeNil)
+
+	err := bucket.WaitN(context.Background(), 3)
+	test.That(t, errors.Is(err, ErrExceedsLimit), test.ShouldBeTrue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.That(t, bucket.Wait(ctx), test.ShouldEqual, context.Canceled)
+
+	slowBucket := NewTokenBucket(0.001, 1)
+	test.That(t, slowBucket.TryAcquire(), test.ShouldBeTrue)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	test.That(t, slowBucket.Wait(ctx), test.ShouldEqual, context.DeadlineExceeded)
+}
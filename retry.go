@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// The defaults of a RetryPolicy.
+const (
+	defaultRetryInitialBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff        = 5 * time.Second
+	defaultRetryBackoffMultiplier = 2
+)
+
+// retryRandom picks where in the range of possible waits a retry waits until. It is a
+// variable so that tests can make waits predictable.
+//
+//nolint:gosec
+var retryRandom = rand.Float64
+
+// A RetryPolicy configures how Retry retries a failing function.
+type RetryPolicy struct {
+	// MaxAttempts is the most times the function is called, including the first call. If
+	// zero, the function is retried until it succeeds, is not to be retried, or the context
+	// is done.
+	MaxAttempts int
+
+	// MaxElapsedTime is how long after the first call to keep retrying for. No attempt is
+	// started, nor waited for, past it. If zero, there is no limit.
+	MaxElapsedTime time.Duration
+
+	// InitialBackoff is the most to wait before the first retry. The most to wait before each
+	// retry after it grows by BackoffMultiplier up to MaxBackoff and the actual wait is chosen
+	// at random up to that (full jitter) so that callers do not retry in lockstep. These
+	// default to 100ms, 5s, and 2.
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+
+	// Backoff, if set, decides how long to wait before retrying after the given attempt
+	// failed instead of the exponential backoff above.
+	Backoff func(attempt RetryAttempt) time.Duration
+
+	// RetryOn, if set, decides whether an error is retried. If unset, every error is.
+	RetryOn func(err error) bool
+
+	// OnAttempt, if set, is called after each attempt with how it went.
+	OnAttempt func(attempt RetryAttempt)
+}
+
+// A RetryAttempt describes one call of the function being retried.
+type RetryAttempt struct {
+	// Number is which attempt this is, starting at 1.
+	Number int
+	// Err is what the attempt failed with, if it did.
+	Err error
+	// Elapsed is how long it has been since the first attempt started.
+	Elapsed time.Duration
+	// Wait is how long will be waited before the next attempt, if there will be one.
+	Wait time.Duration
+	// Retrying is whether there will be another attempt.
+	Retrying bool
+}
+
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if policy.BackoffMultiplier < 1 {
+		policy.BackoffMultiplier = defaultRetryBackoffMultiplier
+	}
+	return policy
+}
+
+// backoff returns how long to wait before retrying after the given attempt failed.
+func (policy RetryPolicy) backoff(attempt RetryAttempt) time.Duration {
+	if policy.Backoff != nil {
+		return policy.Backoff(attempt)
+	}
+	most := math.Min(
+		float64(policy.InitialBackoff)*math.Pow(policy.BackoffMultiplier, float64(attempt.Number-1)),
+		float64(policy.MaxBackoff),
+	)
+	return time.Duration(retryRandom() * most)
+}
+
+// Retry calls fn until it succeeds, fails with an error the policy does not retry, runs
+// out of attempts or time, or the context is done, waiting in between attempts according
+// to the policy. fn is always called at least once. Retrying also stops once the next
+// attempt would come after the context's deadline. The error of the last attempt is
+// returned, even if retrying stopped because the context is done.
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	policy = policy.withDefaults()
+	start := time.Now()
+	for number := 1; ; number++ {
+		err := fn()
+		attempt := RetryAttempt{Number: number, Err: err, Elapsed: time.Since(start)}
+		if err != nil && ctx.Err() == nil &&
+			(policy.MaxAttempts <= 0 || number < policy.MaxAttempts) &&
+			(policy.RetryOn == nil || policy.RetryOn(err)) {
+			attempt.Wait = policy.backoff(attempt)
+			attempt.Retrying = policy.MaxElapsedTime <= 0 || attempt.Elapsed+attempt.Wait < policy.MaxElapsedTime
+			if !attempt.Retrying {
+				attempt.Wait = 0
+			}
+		}
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(attempt)
+		}
+		if !attempt.Retrying || !SelectContextOrWaitUntil(ctx, time.Now().Add(attempt.Wait)) {
+			return err
+		}
+	}
+}
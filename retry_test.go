@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+)
+
+func TestRetry(t *testing.T) {
+	errFailed := errors.New("failed")
+	failingFunc := func(failures int) (func() error, func() int) {
+		var calls int
+		return func() error {
+				calls++
+				if calls <= failures {
+					return errFailed
+				}
+				return nil
+			}, func() int {
+				return calls
+			}
+	}
+	quick := RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	t.Run("retries until success", func(t *testing.T) {
+		fn, calls := failingFunc(3)
+		var attempts []RetryAttempt
+		policy := quick
+		policy.OnAttempt = func(attempt RetryAttempt) {
+			attempts = append(attempts, attempt)
+		}
+		test.That(t, Retry(context.Background(), policy, fn), test.ShouldBeNil)
+		test.That(t, calls(), test.ShouldEqual, 4)
+		test.That(t, attempts, test.ShouldHaveLength, 4)
+		for i, attempt := range attempts[:3] {
+			test.That(t, attempt.Number, test.ShouldEqual, i+1)
+			test.That(t, attempt.Err, test.ShouldEqual, errFailed)
+			test.That(t, attempt.Retrying, test.ShouldBeTrue)
+		}
+		test.That(t, attempts[3].Err, test.ShouldBeNil)
+		test.That(t, attempts[3].Retrying, test.ShouldBeFalse)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		fn, calls := failingFunc(5)
+		policy := quick
+		policy.MaxAttempts = 3
+		test.That(t, Retry(context.Background(), policy, fn), test.ShouldEqual, errFailed)
+		test.That(t, calls(), test.ShouldEqual, 3)
+	})
+
+	t.Run("retry on", func(t *testing.T) {
+		fn, calls := failingFunc(5)
+		policy := quick
+		policy.RetryOn = func(err error) bool {
+			return !errors.Is(err, errFailed)
+		}
+		test.That(t, Retry(context.Background(), policy, fn), test.ShouldEqual, errFailed)
+		test.That(t, calls(), test.ShouldEqual, 1)
+	})
+
+	t.Run("max elapsed time", func(t *testing.T) {
+		fn, calls := failingFunc(5)
+		policy := RetryPolicy{
+			MaxElapsedTime: time.Minute,
+			Backoff: func(attempt RetryAttempt) time.Duration {
+				return time.Hour
+			},
+		}
+		test.That(t, Retry(context.Background(), policy, fn), test.ShouldEqual, errFailed)
+		test.That(t, calls(), test.ShouldEqual, 1)
+	})
+
+	t.Run("done context", func(t *testing.T) {
+		fn, calls := failingFunc(5)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		test.That(t, Retry(ctx, RetryPolicy{InitialBackoff: time.Hour}, fn), test.ShouldEqual, errFailed)
+		test.That(t, calls(), test.ShouldEqual, 1)
+	})
+
+	t.Run("context deadline", func(t *testing.T) {
+		fn, calls := failingFunc(5)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		policy := RetryPolicy{
+			Backoff: func(attempt RetryAttempt) time.Duration {
+				return time.Hour
+			},
+		}
+		start := time.Now()
+		test.That(t, Retry(ctx, policy, fn), test.ShouldEqual, errFailed)
+		test.That(t, calls(), test.ShouldEqual, 1)
+		// the wait would outlast the context, so there is no waiting for it to be done.
+		test.That(t, time.Since(start), test.ShouldBeLessThan, time.Minute)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		prevRandom := retryRandom
+		defer func() {
+			retryRandom = prevRandom
+		}()
+		policy := RetryPolicy{}.withDefaults()
+		retryRandom = func() float64 { return 1 }
+		test.That(t, policy.backoff(RetryAttempt{Number: 1}), test.ShouldEqual, defaultRetryInitialBackoff)
+		test.That(t, policy.backoff(RetryAttempt{Number: 3}), test.ShouldEqual, 4*defaultRetryInitialBackoff)
+		test.That(t, policy.backoff(RetryAttempt{Number: 20}), test.ShouldEqual, defaultRetryMaxBackoff)
+		retryRandom = func() float64 { return 0.5 }
+		test.That(t, policy.backoff(RetryAttempt{Number: 2}), test.ShouldEqual, defaultRetryInitialBackoff)
+	})
+}
@@ -2,10 +2,14 @@ package rpc
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	//nolint:gosec // using for fingerprint
 	"crypto/sha1"
 	"crypto/subtle"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -129,6 +133,34 @@ func MakePublicKeyProvider(pubKey *rsa.PublicKey) TokenVerificationKeyProvider {
 	)
 }
 
+// MakeECDSAPublicKeyProvider returns a TokenVerificationKeyProvider that provides an ECDSA public key
+// for JWT verification.
+func MakeECDSAPublicKeyProvider(pubKey *ecdsa.PublicKey) TokenVerificationKeyProvider {
+	return TokenVerificationKeyProviderFunc(
+		func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+			}
+
+			return pubKey, nil
+		},
+	)
+}
+
+// MakeEd25519PublicKeyProvider returns a TokenVerificationKeyProvider that provides an Ed25519 public key
+// for JWT verification.
+func MakeEd25519PublicKeyProvider(pubKey ed25519.PublicKey) TokenVerificationKeyProvider {
+	return TokenVerificationKeyProviderFunc(
+		func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodEd25519); !ok {
+				return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+			}
+
+			return pubKey, nil
+		},
+	)
+}
+
 // MakeOIDCKeyProvider returns a TokenVerificationKeyProvider that dynamically looks up a public key for
 // JWT verification by inspecting the JWT's kid field. The given issuer is used to discover the JWKs
 // used for verification. This issuer is expected to follow the OIDC Discovery protocol.
@@ -247,6 +279,16 @@ const (
 	// external authentication endpoint (see ExternalAuthService#AuthenticateTo) intended
 	// for another, different consumer at a different endpoint.
 	CredentialsTypeExternal = CredentialsType("external")
+
+	// CredentialsTypeOIDC is for ID or access tokens issued by an OIDC provider
+	// (e.g. Auth0, Google) that are exchanged for our own access tokens
+	// (see MakeOIDCAuthHandler).
+	CredentialsTypeOIDC = CredentialsType("oidc")
+
+	// CredentialsTypeWebSession is for access tokens the server signs for users logged in
+	// to a web session (see Server.SignAccessToken). It cannot be authenticated with
+	// directly.
+	CredentialsTypeWebSession = CredentialsType("web-session")
 )
 
 // Credentials packages up both a type of credential along with its payload which
@@ -268,6 +310,40 @@ func RSAPublicKeyThumbprint(key *rsa.PublicKey) (string, error) {
 	return base64.RawURLEncoding.EncodeToString(thumbPrint.Sum(nil)), nil
 }
 
+// PublicKeyThumbprint returns a thumbprint of the given public key to be used as a key ID (kid).
+// For RSA keys, it is the same as RSAPublicKeyThumbprint. For ECDSA and Ed25519 keys, it is the SHA1
+// of the key's DER encoded PKIX form Base64 URL encoded without padding.
+func PublicKeyThumbprint(pubKey crypto.PublicKey) (string, error) {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		return RSAPublicKeyThumbprint(key)
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		//nolint:gosec // using for fingerprint
+		thumbPrint := sha1.Sum(der)
+		return base64.RawURLEncoding.EncodeToString(thumbPrint[:]), nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+}
+
+// signingMethodForKey returns the JWT signing method to sign access tokens with the given key.
+func signingMethodForKey(privKey crypto.Signer) (jwt.SigningMethod, error) {
+	switch privKey.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privKey)
+	}
+	alg, err := jwks.SigningAlgorithm(privKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	return jwt.GetSigningMethod(alg), nil
+}
+
 type credAuthHandlers struct {
 	AuthHandler                  AuthHandler
 	EntityDataLoader             EntityDataLoader
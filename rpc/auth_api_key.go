@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mongoutils "go.viam.com/utils/mongo"
+)
+
+func init() {
+	mongoutils.MustRegisterNamespace(&mongodbAPIKeyStoreDBName, &mongodbAPIKeyStoreCollName)
+}
+
+// AuthMetadataScopesKey is the auth metadata key holding the comma separated method patterns
+// (see path.Match) an authenticated entity is limited to calling, e.g.
+// "/proto.rpc.examples.echo.v1.EchoService/*". When absent, all methods may be called.
+const AuthMetadataScopesKey = "rpc_scopes"
+
+// An APIKey is a credential of type CredentialsTypeAPIKey where the key ID is the entity
+// and the secret is the payload. Only a hash of the secret is kept.
+type APIKey struct {
+	ID         string            `bson:"_id"`
+	SecretHash []byte            `bson:"secret_hash"`
+	Scopes     []string          `bson:"scopes,omitempty"`
+	Metadata   map[string]string `bson:"metadata,omitempty"`
+	// ExpiresAt is when the key stops being valid; the zero value never expires.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// HashAPIKeySecret returns the hash of an API key secret that is stored in an APIKey.
+func HashAPIKeySecret(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
+
+// NewAPIKey generates a new API key limited to the given scopes, if any, that
+// carries the given metadata. The returned secret is not recoverable from the key.
+func NewAPIKey(scopes []string, md map[string]string) (APIKey, string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	return APIKey{
+		ID:         uuid.NewString(),
+		SecretHash: HashAPIKeySecret(secret),
+		Scopes:     scopes,
+		Metadata:   md,
+	}, secret, nil
+}
+
+// ErrAPIKeyNotFound is returned by an APIKeyStore when there is no key for an ID.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// An APIKeyStore stores API keys by their ID.
+type APIKeyStore interface {
+	// LookupAPIKey returns the key for the given ID or ErrAPIKeyNotFound.
+	LookupAPIKey(ctx context.Context, id string) (APIKey, error)
+
+	// PutAPIKey adds or replaces the given key.
+	PutAPIKey(ctx context.Context, key APIKey) error
+
+	// DeleteAPIKey removes the key for the given ID, if any.
+	DeleteAPIKey(ctx context.Context, id string) error
+}
+
+// MakeAPIKeyAuthHandler returns an AuthHandler for CredentialsTypeAPIKey that verifies
+// keys against the given store. The key's metadata and scopes (see AuthMetadataScopesKey)
+// become the auth metadata of the entity.
+func MakeAPIKeyAuthHandler(store APIKeyStore) AuthHandler {
+	return AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+		key, err := store.LookupAPIKey(ctx, entity)
+		if err != nil {
+			if errors.Is(err, ErrAPIKeyNotFound) {
+				return nil, errInvalidCredentials
+			}
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare(key.SecretHash, HashAPIKeySecret(payload)) != 1 {
+			return nil, errInvalidCredentials
+		}
+		if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+			return nil, status.Error(codes.Unauthenticated, "api key expired")
+		}
+
+		authMD := make(map[string]string, len(key.Metadata)+1)
+		for k, v := range key.Metadata {
+			authMD[k] = v
+		}
+		if len(key.Scopes) != 0 {
+			authMD[AuthMetadataScopesKey] = strings.Join(key.Scopes, ",")
+		}
+		return authMD, nil
+	})
+}
+
+// WithAPIKeyAuthHandler returns a ServerOption which lets entities authenticate with API keys
+// from the given store. See MakeAPIKeyAuthHandler.
+func WithAPIKeyAuthHandler(store APIKeyStore) ServerOption {
+	return WithAuthHandler(CredentialsTypeAPIKey, MakeAPIKeyAuthHandler(store))
+}
+
+// checkAuthScopes ensures that the authenticated entity, if it is limited to some scopes,
+// may call the given method.
+func checkAuthScopes(ctx context.Context, fullMethod string) error {
+	claims, ok := ContextAuthClaims(ctx)
+	if !ok {
+		return nil
+	}
+	scopes, ok := claims.Metadata()[AuthMetadataScopesKey]
+	if !ok {
+		return nil
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if matched, err := path.Match(scope, fullMethod); err == nil && matched {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "not allowed to call %q", fullMethod)
+}
+
+// NewMemoryAPIKeyStore returns a new in-memory API key store holding the given keys.
+func NewMemoryAPIKeyStore(keys ...APIKey) APIKeyStore {
+	store := &memoryAPIKeyStore{keys: make(map[string]APIKey, len(keys))}
+	for _, key := range keys {
+		store.keys[key.ID] = key
+	}
+	return store
+}
+
+type memoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+func (store *memoryAPIKeyStore) LookupAPIKey(ctx context.Context, id string) (APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	key, ok := store.keys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (store *memoryAPIKeyStore) PutAPIKey(ctx context.Context, key APIKey) error {
+	if key.ID == "" {
+		return errors.New("expected non-empty key ID")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.keys[key.ID] = key
+	return nil
+}
+
+func (store *memoryAPIKeyStore) DeleteAPIKey(ctx context.Context, id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.keys, id)
+	return nil
+}
+
+// Database and collection names used by the mongoDBAPIKeyStore.
+var (
+	mongodbAPIKeyStoreDBName   = "rpc"
+	mongodbAPIKeyStoreCollName = "api_keys"
+)
+
+// NewMongoDBAPIKeyStore returns a new API key store backed by the given MongoDB client.
+func NewMongoDBAPIKeyStore(client *mongo.Client) APIKeyStore {
+	coll := client.Database(mongodbAPIKeyStoreDBName).Collection(mongodbAPIKeyStoreCollName)
+	return &mongoDBAPIKeyStore{coll: coll}
+}
+
+type mongoDBAPIKeyStore struct {
+	coll *mongo.Collection
+}
+
+func (store *mongoDBAPIKeyStore) LookupAPIKey(ctx context.Context, id string) (APIKey, error) {
+	var key APIKey
+	if err := store.coll.FindOne(ctx, bson.D{{"_id", id}}).Decode(&key); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (store *mongoDBAPIKeyStore) PutAPIKey(ctx context.Context, key APIKey) error {
+	if key.ID == "" {
+		return errors.New("expected non-empty key ID")
+	}
+	_, err := store.coll.ReplaceOne(ctx, bson.D{{"_id", key.ID}}, key, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (store *mongoDBAPIKeyStore) DeleteAPIKey(ctx context.Context, id string) error {
+	_, err := store.coll.DeleteOne(ctx, bson.D{{"_id", id}})
+	return err
+}
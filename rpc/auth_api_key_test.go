@@ -0,0 +1,256 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func testAPIKeyStore(t *testing.T, store APIKeyStore) {
+	t.Helper()
+
+	key, secret, err := NewAPIKey([]string{"/a/*"}, map[string]string{"team": "robots"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, key.ID, test.ShouldNotBeEmpty)
+	test.That(t, secret, test.ShouldNotBeEmpty)
+	test.That(t, key.SecretHash, test.ShouldResemble, HashAPIKeySecret(secret))
+
+	_, err = store.LookupAPIKey(context.Background(), key.ID)
+	test.That(t, err, test.ShouldEqual, ErrAPIKeyNotFound)
+
+	test.That(t, store.PutAPIKey(context.Background(), key), test.ShouldBeNil)
+	stored, err := store.LookupAPIKey(context.Background(), key.ID)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stored.SecretHash, test.ShouldResemble, key.SecretHash)
+	test.That(t, stored.Scopes, test.ShouldResemble, key.Scopes)
+	test.That(t, stored.Metadata, test.ShouldResemble, key.Metadata)
+	test.That(t, stored.ExpiresAt.IsZero(), test.ShouldBeTrue)
+
+	key.ExpiresAt = time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	test.That(t, store.PutAPIKey(context.Background(), key), test.ShouldBeNil)
+	stored, err = store.LookupAPIKey(context.Background(), key.ID)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stored.ExpiresAt.Equal(key.ExpiresAt), test.ShouldBeTrue)
+
+	test.That(t, store.PutAPIKey(context.Background(), APIKey{}), test.ShouldNotBeNil)
+
+	test.That(t, store.DeleteAPIKey(context.Background(), key.ID), test.ShouldBeNil)
+	_, err = store.LookupAPIKey(context.Background(), key.ID)
+	test.That(t, err, test.ShouldEqual, ErrAPIKeyNotFound)
+	test.That(t, store.DeleteAPIKey(context.Background(), key.ID), test.ShouldBeNil)
+}
+
+func TestMemoryAPIKeyStore(t *testing.T) {
+	testAPIKeyStore(t, NewMemoryAPIKeyStore())
+}
+
+func TestMongoDBAPIKeyStore(t *testing.T) {
+	client := testutils.BackingMongoDBClient(t)
+	test.That(t, client.Database(mongodbAPIKeyStoreDBName).Collection(mongodbAPIKeyStoreCollName).Drop(context.Background()),
+		test.ShouldBeNil)
+	testAPIKeyStore(t, NewMongoDBAPIKeyStore(client))
+}
+
+func TestAPIKeyAuthHandler(t *testing.T) {
+	key, secret, err := NewAPIKey([]string{"/a/*", "/b/c"}, map[string]string{"team": "robots"})
+	test.That(t, err, test.ShouldBeNil)
+	unscopedKey, unscopedSecret, err := NewAPIKey(nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	expiredKey, expiredSecret, err := NewAPIKey(nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	expiredKey.ExpiresAt = time.Now().Add(-time.Minute)
+
+	handler := MakeAPIKeyAuthHandler(NewMemoryAPIKeyStore(key, unscopedKey, expiredKey))
+
+	authMD, err := handler.Authenticate(context.Background(), key.ID, secret)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, authMD, test.ShouldResemble, map[string]string{"team": "robots", AuthMetadataScopesKey: "/a/*,/b/c"})
+
+	authMD, err = handler.Authenticate(context.Background(), unscopedKey.ID, unscopedSecret)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, authMD, test.ShouldBeEmpty)
+
+	_, err = handler.Authenticate(context.Background(), key.ID, unscopedSecret)
+	test.That(t, err, test.ShouldEqual, errInvalidCredentials)
+
+	_, err = handler.Authenticate(context.Background(), "unknown", secret)
+	test.That(t, err, test.ShouldEqual, errInvalidCredentials)
+
+	_, err = handler.Authenticate(context.Background(), expiredKey.ID, expiredSecret)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expired")
+
+	t.Run("scopes", func(t *testing.T) {
+		scopedCtx := contextWithAuthClaims(context.Background(), JWTClaims{
+			AuthMetadata: map[string]string{AuthMetadataScopesKey: "/a/*,/b/c"},
+		})
+		test.That(t, checkAuthScopes(scopedCtx, "/a/d"), test.ShouldBeNil)
+		test.That(t, checkAuthScopes(scopedCtx, "/b/c"), test.ShouldBeNil)
+		err := checkAuthScopes(scopedCtx, "/b/d")
+		test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+		test.That(t, checkAuthScopes(context.Background(), "/b/d"), test.ShouldBeNil)
+		test.That(t, checkAuthScopes(contextWithAuthClaims(context.Background(), JWTClaims{}), "/b/d"), test.ShouldBeNil)
+	})
+}
+
+func TestServerAPIKeyAuth(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	echoMethod := "/" + pb.EchoService_ServiceDesc.ServiceName + "/Echo"
+	key, secret, err := NewAPIKey([]string{echoMethod}, map[string]string{"team": "robots"})
+	test.That(t, err, test.ShouldBeNil)
+
+	var mdMu sync.Mutex
+	var seenTeam string
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAPIKeyAuthHandler(NewMemoryAPIKeyStore(key)),
+		WithUnaryServerInterceptor(func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			if claims, ok := ContextAuthClaims(ctx); ok {
+				mdMu.Lock()
+				seenTeam = claims.Metadata()["team"]
+				mdMu.Unlock()
+			}
+			return handler(ctx, req)
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	echoServer := &echoserver.Server{
+		MustContextAuthEntity: func(ctx context.Context) echoserver.RPCEntityInfo {
+			ent := MustContextAuthEntity(ctx)
+			return echoserver.RPCEntityInfo{
+				Entity: ent.Entity,
+				Data:   ent.Data,
+			}
+		},
+	}
+	echoServer.SetAuthorized(true)
+	echoServer.SetExpectedAuthEntity(key.ID)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		echoServer,
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := Dial(
+		context.Background(),
+		httpListener.Addr().String(),
+		logger,
+		WithInsecure(),
+		WithForceDirectGRPC(),
+		WithEntityCredentials(key.ID, Credentials{Type: CredentialsTypeAPIKey, Payload: secret}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	client := pb.NewEchoServiceClient(conn)
+
+	echoResp, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+	mdMu.Lock()
+	test.That(t, seenTeam, test.ShouldEqual, "robots")
+	mdMu.Unlock()
+
+	// the key is not scoped for anything else
+	echoMultiClient, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echoMultiClient.Recv()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
+
+func TestServerAPIKeyAuthWebRTC(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	listenerAddr := listener.Addr().String()
+
+	echoMethod := "/" + pb.EchoService_ServiceDesc.ServiceName + "/Echo"
+	signalingKey, signalingSecret, err := NewAPIKey(nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAPIKeyAuthHandler(NewMemoryAPIKeyStore(signalingKey)),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{listenerAddr},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	conn, err := Dial(
+		context.Background(),
+		listenerAddr,
+		logger,
+		WithInsecure(),
+		WithDisableDirectGRPC(),
+		WithWebRTCOptions(DialWebRTCOptions{
+			SignalingInsecure:   true,
+			SignalingAuthEntity: signalingKey.ID,
+			SignalingCreds:      Credentials{Type: CredentialsTypeAPIKey, Payload: signalingSecret},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	client := pb.NewEchoServiceClient(conn)
+
+	// calls are limited to the scopes of the access token they are sent with
+	token, err := rpcServer.SignAccessToken(CredentialsTypeAPIKey, signalingKey.ID, time.Hour, map[string]string{
+		AuthMetadataScopesKey: echoMethod,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	scopedCtx := metadata.AppendToOutgoingContext(context.Background(), MetadataFieldAuthorization, AuthorizationValuePrefixBearer+token)
+
+	_, err = client.Echo(scopedCtx, &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	echoMultiClient, err := client.EchoMultiple(scopedCtx, &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echoMultiClient.Recv()
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/jwks"
+)
+
+// OIDCAuthHandlerOptions configure an AuthHandler that validates tokens issued by an
+// OIDC provider. See MakeOIDCAuthHandler.
+type OIDCAuthHandlerOptions struct {
+	// Issuer is the expected issuer (iss) of tokens. Unless JWKSURI or KeyProvider
+	// is set, the issuer must follow the OIDC Discovery protocol so that its keys can
+	// be found.
+	Issuer string
+
+	// Audience are the audiences (aud) a token may be for; it must be for at least one
+	// of them. For ID tokens, this is usually the client ID.
+	Audience []string
+
+	// JWKSURI, if set, is where the issuer's keys are fetched from instead of discovering
+	// them from the issuer.
+	JWKSURI string
+
+	// KeyProvider, if set, is used to look up the issuer's keys instead of fetching them.
+	KeyProvider jwks.KeyProvider
+
+	// EntityClaim is the claim identifying the entity a token is for. The entity
+	// authenticating must match its value. If empty, the subject (sub) is used.
+	EntityClaim string
+
+	// MetadataClaims are the names of string claims to copy into the auth metadata
+	// of the authenticated entity.
+	MetadataClaims []string
+}
+
+const defaultOIDCEntityClaim = "sub"
+
+// MakeOIDCAuthHandler returns an AuthHandler that validates ID or access tokens issued by
+// an OIDC provider (e.g. Auth0, Google) as the payload. Keys are looked up by the token's kid,
+// cached, and refetched when an unknown kid shows up in order to follow key rotations.
+// The returned function must be called to stop refreshing keys.
+func MakeOIDCAuthHandler(ctx context.Context, opts OIDCAuthHandlerOptions) (AuthHandler, func(ctx context.Context) error, error) {
+	if opts.Issuer == "" {
+		return nil, nil, errors.New("expected an issuer")
+	}
+	if len(opts.Audience) == 0 {
+		return nil, nil, errors.New("expected at least one audience")
+	}
+	entityClaim := opts.EntityClaim
+	if entityClaim == "" {
+		entityClaim = defaultOIDCEntityClaim
+	}
+
+	jwkProvider := opts.KeyProvider
+	if jwkProvider == nil {
+		var err error
+		if opts.JWKSURI != "" {
+			jwkProvider, err = jwks.NewCachingJWKKeyProvider(ctx, opts.JWKSURI)
+		} else {
+			jwkProvider, err = jwks.NewCachingOIDCJWKKeyProvider(ctx, opts.Issuer)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	keyProvider := MakeJWKSKeyProvider(jwkProvider)
+
+	handler := AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(
+			payload,
+			claims,
+			func(token *jwt.Token) (interface{}, error) {
+				switch token.Method.(type) {
+				case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+				default:
+					return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+				}
+				return keyProvider.TokenVerificationKey(ctx, token)
+			},
+			jwt.WithValidMethods(validSigningMethods),
+		); err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %s", err)
+		}
+
+		if !claims.VerifyIssuer(opts.Issuer, true) {
+			return nil, status.Error(codes.Unauthenticated, "invalid issuer")
+		}
+		audVerified := false
+		for _, allowedAud := range opts.Audience {
+			if claims.VerifyAudience(allowedAud, true) {
+				audVerified = true
+				break
+			}
+		}
+		if !audVerified {
+			return nil, status.Error(codes.Unauthenticated, "invalid audience")
+		}
+
+		claimsEntity, _ := claims[entityClaim].(string)
+		if claimsEntity == "" || subtle.ConstantTimeCompare([]byte(entity), []byte(claimsEntity)) != 1 {
+			return nil, errInvalidCredentials
+		}
+
+		authMD := map[string]string{}
+		for _, name := range opts.MetadataClaims {
+			if value, ok := claims[name].(string); ok {
+				authMD[name] = value
+			}
+		}
+		return authMD, nil
+	})
+	return handler, keyProvider.Close, nil
+}
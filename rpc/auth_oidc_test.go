@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/jwks"
+	"go.viam.com/utils/jwks/jwksutils"
+)
+
+func TestOIDCAuthHandler(t *testing.T) {
+	keyset, privKeys, err := jwksutils.NewTestKeySet(2)
+	test.That(t, err, test.ShouldBeNil)
+
+	issuer, closeFakeOIDC := jwksutils.ServeFakeOIDCEndpoint(t, keyset)
+	defer closeFakeOIDC()
+
+	makeToken := func(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+		t.Helper()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		tokenString, err := token.SignedString(key)
+		test.That(t, err, test.ShouldBeNil)
+		return tokenString
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   issuer,
+			"aud":   "client-id",
+			"sub":   "auth0|1234",
+			"email": "user@example.com",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		handler, closeHandler, err := MakeOIDCAuthHandler(context.Background(), OIDCAuthHandlerOptions{
+			Issuer:         issuer,
+			Audience:       []string{"other", "client-id"},
+			MetadataClaims: []string{"email", "missing"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, closeHandler(context.Background()), test.ShouldBeNil)
+		}()
+
+		authMD, err := handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-1", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, authMD, test.ShouldResemble, map[string]string{"email": "user@example.com"})
+
+		// keys are looked up by kid
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-2", privKeys[1], validClaims()))
+		test.That(t, err, test.ShouldBeNil)
+
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-2", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid token")
+
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-3", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "kid header does not exist")
+
+		_, err = handler.Authenticate(context.Background(), "someone-else", makeToken(t, "key-id-1", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldEqual, errInvalidCredentials)
+
+		claims := validClaims()
+		claims["iss"] = "https://elsewhere.example.com/"
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-1", privKeys[0], claims))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid issuer")
+
+		claims = validClaims()
+		claims["aud"] = "not-us"
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-1", privKeys[0], claims))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid audience")
+
+		claims = validClaims()
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-1", privKeys[0], claims))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "expired")
+	})
+
+	t.Run("entity claim", func(t *testing.T) {
+		handler, closeHandler, err := MakeOIDCAuthHandler(context.Background(), OIDCAuthHandlerOptions{
+			Issuer:      issuer,
+			Audience:    []string{"client-id"},
+			KeyProvider: jwks.NewStaticJWKKeyProvider(keyset),
+			EntityClaim: "email",
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, closeHandler(context.Background()), test.ShouldBeNil)
+		}()
+
+		authMD, err := handler.Authenticate(context.Background(), "user@example.com", makeToken(t, "key-id-1", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, authMD, test.ShouldBeEmpty)
+
+		_, err = handler.Authenticate(context.Background(), "auth0|1234", makeToken(t, "key-id-1", privKeys[0], validClaims()))
+		test.That(t, err, test.ShouldEqual, errInvalidCredentials)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		_, _, err := MakeOIDCAuthHandler(context.Background(), OIDCAuthHandlerOptions{Audience: []string{"client-id"}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "issuer")
+
+		_, _, err = MakeOIDCAuthHandler(context.Background(), OIDCAuthHandlerOptions{Issuer: issuer})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "audience")
+
+		opt, closeOpt, err := WithOIDCAuthHandler(context.Background(), OIDCAuthHandlerOptions{
+			Issuer:   issuer,
+			Audience: []string{"client-id"},
+			JWKSURI:  issuer + ".well-known/jwks.json",
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, closeOpt(context.Background()), test.ShouldBeNil)
+		}()
+		var sOpts serverOptions
+		test.That(t, opt.apply(&sOpts), test.ShouldBeNil)
+		test.That(t, sOpts.authHandlersForCreds[CredentialsTypeOIDC].AuthHandler, test.ShouldNotBeNil)
+	})
+}
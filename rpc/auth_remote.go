@@ -0,0 +1,250 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+// RemoteAuthHandlerOptions configure an AuthHandler that delegates verifying credentials
+// to a remote TokenVerifierService. See MakeRemoteAuthHandler.
+type RemoteAuthHandlerOptions struct {
+	// CacheTTL is how long a successful verification is remembered for. If zero,
+	// verifications are not cached.
+	CacheTTL time.Duration
+
+	// FailureThreshold is how many consecutive calls to the verifier may fail (e.g. because
+	// it is unavailable) before the verifier is no longer called for OpenTimeout. If zero,
+	// DefaultRemoteAuthFailureThreshold is used.
+	FailureThreshold int
+
+	// OpenTimeout is how long to wait before calling the verifier again once it has failed
+	// too many times. If zero, DefaultRemoteAuthOpenTimeout is used.
+	OpenTimeout time.Duration
+
+	// Fallback, if set, verifies credentials when the verifier cannot be reached.
+	Fallback AuthHandler
+}
+
+const (
+	// DefaultRemoteAuthFailureThreshold is the default number of consecutive failures
+	// before a remote verifier is skipped.
+	DefaultRemoteAuthFailureThreshold = 5
+
+	// DefaultRemoteAuthOpenTimeout is the default amount of time a remote verifier is
+	// skipped for after failing too many times.
+	DefaultRemoteAuthOpenTimeout = 30 * time.Second
+
+	remoteAuthCacheMaxEntries = 1024
+)
+
+// errRemoteAuthUnavailable is returned when the verifier cannot be reached and there
+// is no fallback.
+var errRemoteAuthUnavailable = status.Error(codes.Unavailable, "credential verifier unavailable")
+
+// MakeRemoteAuthHandler returns an AuthHandler for the given credentials type that
+// delegates verifying credentials to the TokenVerifierService served on the given
+// connection. Verifications are cached and, if the verifier keeps failing, it stops being
+// called for a while (circuit breaking) in favor of the fallback handler, if any.
+func MakeRemoteAuthHandler(forType CredentialsType, conn ClientConn, opts RemoteAuthHandlerOptions) AuthHandler {
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultRemoteAuthFailureThreshold
+	}
+	if opts.OpenTimeout == 0 {
+		opts.OpenTimeout = DefaultRemoteAuthOpenTimeout
+	}
+	handler := &remoteAuthHandler{
+		forType: forType,
+		client:  rpcpb.NewTokenVerifierServiceClient(conn),
+		opts:    opts,
+		cache:   map[[sha256.Size]byte]remoteAuthCacheEntry{},
+	}
+	return AuthHandlerFunc(handler.authenticate)
+}
+
+// WithRemoteAuthHandler returns a ServerOption which delegates verifying credentials of
+// the given type to a remote TokenVerifierService. See MakeRemoteAuthHandler.
+func WithRemoteAuthHandler(forType CredentialsType, conn ClientConn, opts RemoteAuthHandlerOptions) ServerOption {
+	return WithAuthHandler(forType, MakeRemoteAuthHandler(forType, conn, opts))
+}
+
+type remoteAuthCacheEntry struct {
+	authMD    map[string]string
+	expiresAt time.Time
+}
+
+type remoteAuthHandler struct {
+	forType CredentialsType
+	client  rpcpb.TokenVerifierServiceClient
+	opts    RemoteAuthHandlerOptions
+
+	mu                  sync.Mutex
+	cache               map[[sha256.Size]byte]remoteAuthCacheEntry
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+func (h *remoteAuthHandler) authenticate(ctx context.Context, entity, payload string) (map[string]string, error) {
+	cacheKey := sha256.Sum256([]byte(string(h.forType) + "\x00" + entity + "\x00" + payload))
+
+	h.mu.Lock()
+	if entry, ok := h.cache[cacheKey]; ok {
+		if time.Now().Before(entry.expiresAt) {
+			h.mu.Unlock()
+			return copyAuthMetadata(entry.authMD), nil
+		}
+		delete(h.cache, cacheKey)
+	}
+	open := time.Now().Before(h.openUntil)
+	h.mu.Unlock()
+
+	if open {
+		return h.fallback(ctx, entity, payload)
+	}
+
+	resp, err := h.client.VerifyCredentials(ctx, &rpcpb.AuthenticateRequest{
+		Entity: entity,
+		Credentials: &rpcpb.Credentials{
+			Type:    string(h.forType),
+			Payload: payload,
+		},
+	})
+	if err != nil {
+		if isRemoteAuthDecision(err) {
+			h.recordSuccess()
+			return nil, err
+		}
+		h.recordFailure()
+		return h.fallback(ctx, entity, payload)
+	}
+	h.recordSuccess()
+
+	authMD, err := authMetadataFromStruct(resp)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid verifier response: %s", err)
+	}
+	if h.opts.CacheTTL > 0 {
+		h.mu.Lock()
+		if len(h.cache) >= remoteAuthCacheMaxEntries {
+			h.evictExpiredLocked()
+		}
+		if len(h.cache) < remoteAuthCacheMaxEntries {
+			h.cache[cacheKey] = remoteAuthCacheEntry{
+				authMD:    copyAuthMetadata(authMD),
+				expiresAt: time.Now().Add(h.opts.CacheTTL),
+			}
+		}
+		h.mu.Unlock()
+	}
+	return authMD, nil
+}
+
+func (h *remoteAuthHandler) fallback(ctx context.Context, entity, payload string) (map[string]string, error) {
+	if h.opts.Fallback == nil {
+		return nil, errRemoteAuthUnavailable
+	}
+	return h.opts.Fallback.Authenticate(ctx, entity, payload)
+}
+
+func (h *remoteAuthHandler) recordSuccess() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.consecutiveFailures = 0
+	h.openUntil = time.Time{}
+}
+
+func (h *remoteAuthHandler) recordFailure() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.consecutiveFailures++
+	if h.consecutiveFailures >= h.opts.FailureThreshold {
+		// stays open until a call after the timeout succeeds; any failure in between reopens it.
+		h.openUntil = time.Now().Add(h.opts.OpenTimeout)
+	}
+}
+
+// evictExpiredLocked removes expired cache entries. It must be called with mu held.
+func (h *remoteAuthHandler) evictExpiredLocked() {
+	now := time.Now()
+	for key, entry := range h.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(h.cache, key)
+		}
+	}
+}
+
+// isRemoteAuthDecision returns whether the error is the verifier rejecting the credentials
+// as opposed to failing to verify them.
+func isRemoteAuthDecision(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument, codes.NotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+func authMetadataFromStruct(resp *structpb.Struct) (map[string]string, error) {
+	authMD := make(map[string]string, len(resp.GetFields()))
+	for key, value := range resp.GetFields() {
+		strValue, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return nil, errors.Errorf("expected string value for %q", key)
+		}
+		authMD[key] = strValue.StringValue
+	}
+	return authMD, nil
+}
+
+func copyAuthMetadata(authMD map[string]string) map[string]string {
+	mdCopy := make(map[string]string, len(authMD))
+	for k, v := range authMD {
+		mdCopy[k] = v
+	}
+	return mdCopy
+}
+
+// NewTokenVerifierServiceServer returns a TokenVerifierService server that verifies
+// credentials using the given handlers for each credentials type. It is intended to be
+// registered on a central auth service that other servers use MakeRemoteAuthHandler with.
+func NewTokenVerifierServiceServer(handlers map[CredentialsType]AuthHandler) rpcpb.TokenVerifierServiceServer {
+	return &tokenVerifierServer{handlers: handlers}
+}
+
+type tokenVerifierServer struct {
+	rpcpb.UnimplementedTokenVerifierServiceServer
+	handlers map[CredentialsType]AuthHandler
+}
+
+func (srv *tokenVerifierServer) VerifyCredentials(
+	ctx context.Context,
+	req *rpcpb.AuthenticateRequest,
+) (*structpb.Struct, error) {
+	if req.Entity == "" {
+		return nil, status.Error(codes.InvalidArgument, "expected entity")
+	}
+	handler, ok := srv.handlers[CredentialsType(req.GetCredentials().GetType())]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "do not know how to handle credential type %q", req.GetCredentials().GetType())
+	}
+	authMD, err := handler.Authenticate(ctx, req.Entity, req.GetCredentials().GetPayload())
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "failed to verify credentials: %s", err)
+	}
+	fields := make(map[string]*structpb.Value, len(authMD))
+	for k, v := range authMD {
+		fields[k] = structpb.NewStringValue(v)
+	}
+	return &structpb.Struct{Fields: fields}, nil
+}
@@ -0,0 +1,200 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+type countingAuthHandler struct {
+	mu      sync.Mutex
+	calls   int
+	failErr error
+	handler AuthHandler
+}
+
+func (h *countingAuthHandler) Authenticate(ctx context.Context, entity, payload string) (map[string]string, error) {
+	h.mu.Lock()
+	h.calls++
+	failErr := h.failErr
+	h.mu.Unlock()
+	if failErr != nil {
+		return nil, failErr
+	}
+	return h.handler.Authenticate(ctx, entity, payload)
+}
+
+func (h *countingAuthHandler) callCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+func (h *countingAuthHandler) setFailErr(err error) {
+	h.mu.Lock()
+	h.failErr = err
+	h.mu.Unlock()
+}
+
+func serveTokenVerifier(t *testing.T, handler AuthHandler) ClientConn {
+	t.Helper()
+	grpcServer := grpc.NewServer()
+	rpcpb.RegisterTokenVerifierServiceServer(grpcServer, NewTokenVerifierServiceServer(map[CredentialsType]AuthHandler{
+		CredentialsTypeAPIKey: handler,
+	}))
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	serveDone := make(chan error)
+	go func() {
+		serveDone <- grpcServer.Serve(listener)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+		grpcServer.Stop()
+		test.That(t, <-serveDone, test.ShouldBeNil)
+	})
+	return conn
+}
+
+func TestRemoteAuthHandler(t *testing.T) {
+	verifierHandler := &countingAuthHandler{
+		handler: AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			if entity != "foo" || payload != "bar" {
+				return nil, errInvalidCredentials
+			}
+			return map[string]string{"team": "robots"}, nil
+		}),
+	}
+	conn := serveTokenVerifier(t, verifierHandler)
+
+	t.Run("caching", func(t *testing.T) {
+		handler := MakeRemoteAuthHandler(CredentialsTypeAPIKey, conn, RemoteAuthHandlerOptions{CacheTTL: time.Hour})
+		startCalls := verifierHandler.callCount()
+
+		authMD, err := handler.Authenticate(context.Background(), "foo", "bar")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, authMD, test.ShouldResemble, map[string]string{"team": "robots"})
+
+		authMD["team"] = "changed"
+		authMD, err = handler.Authenticate(context.Background(), "foo", "bar")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, authMD, test.ShouldResemble, map[string]string{"team": "robots"})
+		test.That(t, verifierHandler.callCount(), test.ShouldEqual, startCalls+1)
+
+		// rejections are not cached
+		for i := 0; i < 2; i++ {
+			_, err = handler.Authenticate(context.Background(), "foo", "baz")
+			test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+		}
+		test.That(t, verifierHandler.callCount(), test.ShouldEqual, startCalls+3)
+
+		_, err = MakeRemoteAuthHandler(CredentialsTypeExternal, conn, RemoteAuthHandlerOptions{}).Authenticate(
+			context.Background(), "foo", "bar")
+		test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+	})
+
+	t.Run("circuit breaking", func(t *testing.T) {
+		fallback := MakeSimpleAuthHandler([]string{"foo"}, "fallback")
+		handler := MakeRemoteAuthHandler(CredentialsTypeAPIKey, conn, RemoteAuthHandlerOptions{
+			FailureThreshold: 2,
+			OpenTimeout:      time.Second,
+			Fallback:         fallback,
+		})
+		noFallbackHandler := MakeRemoteAuthHandler(CredentialsTypeAPIKey, conn, RemoteAuthHandlerOptions{})
+
+		verifierHandler.setFailErr(status.Error(codes.Internal, "oops"))
+		startCalls := verifierHandler.callCount()
+
+		_, err := noFallbackHandler.Authenticate(context.Background(), "foo", "bar")
+		test.That(t, err, test.ShouldEqual, errRemoteAuthUnavailable)
+
+		for i := 0; i < 3; i++ {
+			_, err = handler.Authenticate(context.Background(), "foo", "fallback")
+			test.That(t, err, test.ShouldBeNil)
+		}
+		_, err = handler.Authenticate(context.Background(), "foo", "bar")
+		test.That(t, err, test.ShouldEqual, errInvalidCredentials)
+		// the verifier is no longer called after failing twice
+		test.That(t, verifierHandler.callCount(), test.ShouldEqual, startCalls+3)
+
+		verifierHandler.setFailErr(nil)
+		time.Sleep(time.Second)
+		authMD, err := handler.Authenticate(context.Background(), "foo", "bar")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, authMD, test.ShouldResemble, map[string]string{"team": "robots"})
+		test.That(t, verifierHandler.callCount(), test.ShouldEqual, startCalls+4)
+	})
+
+	t.Run("server", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithRemoteAuthHandler(CredentialsTypeAPIKey, conn, RemoteAuthHandlerOptions{CacheTTL: time.Minute}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rpcServer.RegisterServiceServer(
+			context.Background(),
+			&pb.EchoService_ServiceDesc,
+			&echoserver.Server{},
+			pb.RegisterEchoServiceHandlerFromEndpoint,
+		), test.ShouldBeNil)
+
+		httpListener, err := net.Listen("tcp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		errChan := make(chan error)
+		go func() {
+			errChan <- rpcServer.Serve(httpListener)
+		}()
+
+		clientConn, err := Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithEntityCredentials("foo", Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		echoResp, err := pb.NewEchoServiceClient(clientConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+		test.That(t, clientConn.Close(), test.ShouldBeNil)
+
+		clientConn, err = Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithEntityCredentials("foo", Credentials{Type: CredentialsTypeAPIKey, Payload: "baz"}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		_, err = pb.NewEchoServiceClient(clientConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+		test.That(t, clientConn.Close(), test.ShouldBeNil)
+
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	})
+}
@@ -31,17 +31,23 @@ func StreamClientTracingInterceptor() grpc.StreamClientInterceptor {
 	}
 }
 
+// contextWithSpanMetadata sends the span in the context, if any, as a W3C Trace Context
+// alongside the trace-id, span-id, and trace-options keys older servers expect.
 func contextWithSpanMetadata(ctx context.Context) context.Context {
 	span := trace.FromContext(ctx)
 	if span == nil {
 		return ctx
 	}
 
-	ctx = metadata.AppendToOutgoingContext(
-		ctx,
+	kv := []string{
 		"trace-id", span.SpanContext().TraceID.String(),
 		"span-id", span.SpanContext().SpanID.String(),
 		"trace-options", fmt.Sprint(span.SpanContext().TraceOptions),
-	)
-	return ctx
+	}
+	for key, values := range injectTraceContext(ctx, span.SpanContext()) {
+		for _, value := range values {
+			kv = append(kv, key, value)
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
 }
@@ -0,0 +1,436 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils"
+)
+
+// ErrNoHealthyConnections happens when a ClientPool has no healthy connection to make a
+// call on.
+var ErrNoHealthyConnections = status.Error(codes.Unavailable, "no healthy connections in pool")
+
+// A ClientPoolBalancing decides which connection of a ClientPool each call is made on.
+type ClientPoolBalancing int
+
+// The ways a ClientPool can balance calls.
+const (
+	// ClientPoolRoundRobin makes calls on each healthy connection in turn.
+	ClientPoolRoundRobin ClientPoolBalancing = iota
+	// ClientPoolLeastLoaded makes calls on the healthy connection with the fewest calls
+	// in flight.
+	ClientPoolLeastLoaded
+)
+
+// The defaults of ClientPoolOptions.
+const (
+	defaultClientPoolHealthCheckInterval = 10 * time.Second
+	defaultClientPoolHealthCheckTimeout  = 5 * time.Second
+	defaultClientPoolResolveInterval     = 30 * time.Second
+	defaultClientPoolFailureThreshold    = 3
+)
+
+// ClientPoolOptions configure a ClientPool.
+type ClientPoolOptions struct {
+	// Addresses are the addresses of the servers to connect to.
+	Addresses []string
+
+	// DNSTarget, if set, is a host:port whose host is resolved every ResolveInterval
+	// (default 30s) to discover more servers to connect to at the given port. Servers
+	// that are no longer resolved are removed from the pool.
+	DNSTarget       string
+	ResolveInterval time.Duration
+
+	// ConnectionsPerAddress is how many connections are made to each server. Defaults to 1.
+	ConnectionsPerAddress int
+
+	// Balancing decides which connection each call is made on.
+	Balancing ClientPoolBalancing
+
+	// HealthCheckInterval is how often each connection is health checked and how often
+	// evicted connections are redialed. Defaults to 10s.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout bounds dialing and each health check. Defaults to 5s.
+	HealthCheckTimeout time.Duration
+
+	// HealthCheck checks that a connection is healthy. By default, the server's standard
+	// gRPC health service is checked if it has one.
+	HealthCheck func(ctx context.Context, conn ClientConn) error
+
+	// FailureThreshold is how many health checks in a row a connection must fail before
+	// it is closed and taken out of rotation until redialed. Defaults to 3.
+	FailureThreshold int
+
+	// DialOptions are used to dial each connection.
+	DialOptions []DialOption
+}
+
+// A ClientPool is a ClientConn that makes calls over a pool of connections to a set of
+// equivalent servers. It keeps the connections healthy in the background, redialing the
+// ones that fail, and balances calls across the healthy ones.
+type ClientPool struct {
+	opts   ClientPoolOptions
+	logger golog.Logger
+
+	mu        sync.Mutex
+	addresses []string
+	conns     []*pooledConn
+	next      uint64
+
+	cancel                  func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+// pooledConn is one connection of a ClientPool.
+type pooledConn struct {
+	address  string
+	inFlight atomic.Int64
+
+	// guarded by the pool's mutex
+	conn     ClientConn
+	healthy  bool
+	failures int
+}
+
+// NewClientPool dials every connection of a new pool and health checks them before
+// returning it. It fails if no connection could be made healthy.
+func NewClientPool(ctx context.Context, logger golog.Logger, opts ClientPoolOptions) (*ClientPool, error) {
+	if len(opts.Addresses) == 0 && opts.DNSTarget == "" {
+		return nil, errors.New("expected addresses or a DNS target")
+	}
+	if opts.DNSTarget != "" {
+		if _, _, err := net.SplitHostPort(opts.DNSTarget); err != nil {
+			return nil, errors.Wrap(err, "invalid DNS target")
+		}
+	}
+	if opts.ConnectionsPerAddress <= 0 {
+		opts.ConnectionsPerAddress = 1
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = defaultClientPoolHealthCheckInterval
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = defaultClientPoolHealthCheckTimeout
+	}
+	if opts.ResolveInterval <= 0 {
+		opts.ResolveInterval = defaultClientPoolResolveInterval
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultClientPoolFailureThreshold
+	}
+	if opts.HealthCheck == nil {
+		opts.HealthCheck = checkHealthService
+	}
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+
+	pool := &ClientPool{opts: opts, logger: logger.Named("client_pool")}
+	if err := pool.resolve(ctx); err != nil {
+		return nil, err
+	}
+	// warm up every connection before any call can be made.
+	pool.checkAll(ctx)
+	if pool.healthyCount() == 0 {
+		return nil, multierr.Combine(ErrNoHealthyConnections, pool.closeConns())
+	}
+
+	workerCtx, cancel := context.WithCancel(context.Background())
+	pool.cancel = cancel
+	pool.activeBackgroundWorkers.Add(1)
+	utils.PanicCapturingGo(func() {
+		defer pool.activeBackgroundWorkers.Done()
+		lastResolve := time.Now()
+		for utils.SelectContextOrWait(workerCtx, pool.opts.HealthCheckInterval) {
+			if pool.opts.DNSTarget != "" && time.Since(lastResolve) >= pool.opts.ResolveInterval {
+				lastResolve = time.Now()
+				if err := pool.resolve(workerCtx); err != nil {
+					pool.logger.Warnw("failed to resolve servers", "target", pool.opts.DNSTarget, "error", err)
+				}
+			}
+			pool.checkAll(workerCtx)
+		}
+	})
+	return pool, nil
+}
+
+// checkHealthService checks the standard gRPC health service. Servers without one are
+// considered healthy so long as they can be reached.
+func checkHealthService(ctx context.Context, conn ClientConn) error {
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf("server is %s", resp.Status)
+	}
+	return nil
+}
+
+// resolve updates the addresses connected to, adding connections to new ones and closing
+// those to ones no longer present.
+func (pool *ClientPool) resolve(ctx context.Context) error {
+	addresses := append([]string{}, pool.opts.Addresses...)
+	if pool.opts.DNSTarget != "" {
+		host, port, err := net.SplitHostPort(pool.opts.DNSTarget)
+		if err != nil {
+			return err
+		}
+		hosts, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve %q", host)
+		}
+		for _, resolved := range hosts {
+			addresses = append(addresses, net.JoinHostPort(resolved, port))
+		}
+	}
+	sort.Strings(addresses)
+
+	wanted := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		wanted[address] = true
+	}
+
+	pool.mu.Lock()
+	existing := make(map[string]bool, len(pool.addresses))
+	for _, address := range pool.addresses {
+		existing[address] = true
+	}
+	var kept []*pooledConn
+	var removed []ClientConn
+	for _, pc := range pool.conns {
+		if wanted[pc.address] {
+			kept = append(kept, pc)
+		} else if pc.conn != nil {
+			removed = append(removed, pc.conn)
+		}
+	}
+	for _, address := range addresses {
+		if existing[address] {
+			continue
+		}
+		existing[address] = true
+		for i := 0; i < pool.opts.ConnectionsPerAddress; i++ {
+			kept = append(kept, &pooledConn{address: address})
+		}
+	}
+	pool.addresses = addresses
+	pool.conns = kept
+	pool.mu.Unlock()
+
+	var errs error
+	for _, conn := range removed {
+		errs = multierr.Combine(errs, conn.Close())
+	}
+	if errs != nil {
+		pool.logger.Debugw("error closing connections to removed servers", "error", errs)
+	}
+	return nil
+}
+
+// checkAll dials or health checks every connection concurrently.
+func (pool *ClientPool) checkAll(ctx context.Context) {
+	pool.mu.Lock()
+	conns := append([]*pooledConn{}, pool.conns...)
+	pool.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, pc := range conns {
+		pc := pc
+		wg.Add(1)
+		utils.PanicCapturingGo(func() {
+			defer wg.Done()
+			pool.check(ctx, pc)
+		})
+	}
+	wg.Wait()
+}
+
+// check dials the connection if it is not connected and health checks it. Connections
+// failing too many checks in a row are closed so that the next check redials them.
+func (pool *ClientPool) check(ctx context.Context, pc *pooledConn) {
+	ctx, cancel := context.WithTimeout(ctx, pool.opts.HealthCheckTimeout)
+	defer cancel()
+
+	pool.mu.Lock()
+	conn := pc.conn
+	pool.mu.Unlock()
+
+	var err error
+	if conn == nil {
+		conn, err = Dial(ctx, pc.address, pool.logger, pool.opts.DialOptions...)
+		if err != nil {
+			pool.logger.Debugw("failed to dial server", "address", pc.address, "error", err)
+			return
+		}
+		pool.mu.Lock()
+		pc.conn = conn
+		pc.failures = 0
+		pool.mu.Unlock()
+	}
+	err = pool.opts.HealthCheck(ctx, conn)
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if err == nil {
+		pc.healthy = true
+		pc.failures = 0
+		return
+	}
+	pc.failures++
+	pool.logger.Debugw("server failed health check", "address", pc.address, "failures", pc.failures, "error", err)
+	if pc.failures < pool.opts.FailureThreshold && pc.healthy {
+		return
+	}
+	if pc.healthy {
+		pool.logger.Warnw("evicting unhealthy connection", "address", pc.address, "error", err)
+	}
+	pc.healthy = false
+	pc.conn = nil
+	if closeErr := conn.Close(); closeErr != nil {
+		pool.logger.Debugw("error closing unhealthy connection", "address", pc.address, "error", closeErr)
+	}
+}
+
+func (pool *ClientPool) healthyCount() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var count int
+	for _, pc := range pool.conns {
+		if pc.healthy {
+			count++
+		}
+	}
+	return count
+}
+
+// pick returns the healthy connection the next call should be made on.
+func (pool *ClientPool) pick() (*pooledConn, ClientConn, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var healthy []*pooledConn
+	for _, pc := range pool.conns {
+		if pc.healthy {
+			healthy = append(healthy, pc)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, nil, ErrNoHealthyConnections
+	}
+	// least loaded ties are broken round robin so that idle pools still spread calls.
+	start := int(pool.next % uint64(len(healthy)))
+	pool.next++
+	picked := healthy[start]
+	if pool.opts.Balancing == ClientPoolLeastLoaded {
+		for i := 1; i < len(healthy); i++ {
+			if pc := healthy[(start+i)%len(healthy)]; pc.inFlight.Load() < picked.inFlight.Load() {
+				picked = pc
+			}
+		}
+	}
+	return picked, picked.conn, nil
+}
+
+// Invoke makes a unary call on a healthy connection.
+func (pool *ClientPool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	pc, conn, err := pool.pick()
+	if err != nil {
+		return err
+	}
+	pc.inFlight.Add(1)
+	defer pc.inFlight.Add(-1)
+	return conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream starts a stream on a healthy connection.
+func (pool *ClientPool) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	pc, conn, err := pool.pick()
+	if err != nil {
+		return nil, err
+	}
+	pc.inFlight.Add(1)
+	stream, err := conn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		pc.inFlight.Add(-1)
+		return nil, err
+	}
+	pooled := &pooledClientStream{ClientStream: stream, done: make(chan struct{})}
+	pooled.finish = func() {
+		pooled.finishOnce.Do(func() {
+			close(pooled.done)
+			pc.inFlight.Add(-1)
+		})
+	}
+	// not a background worker of the pool since streams may outlive it.
+	utils.PanicCapturingGo(func() {
+		select {
+		case <-ctx.Done():
+			pooled.finish()
+		case <-pooled.done:
+		}
+	})
+	return pooled, nil
+}
+
+// pooledClientStream stops counting towards its connection's load once it ends.
+type pooledClientStream struct {
+	grpc.ClientStream
+	done       chan struct{}
+	finishOnce sync.Once
+	finish     func()
+}
+
+func (s *pooledClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.finish()
+	}
+	return err
+}
+
+// Close stops health checking and closes every connection. Streams in flight are
+// not waited on.
+func (pool *ClientPool) Close() error {
+	if pool.cancel != nil {
+		pool.cancel()
+	}
+	pool.activeBackgroundWorkers.Wait()
+	return pool.closeConns()
+}
+
+func (pool *ClientPool) closeConns() error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	var errs error
+	for _, pc := range pool.conns {
+		if pc.conn != nil {
+			errs = multierr.Combine(errs, pc.conn.Close())
+			pc.conn = nil
+		}
+		pc.healthy = false
+	}
+	return errs
+}
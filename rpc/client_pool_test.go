@@ -0,0 +1,227 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+// countingEchoServer serves echo calls and counts the unary ones. Its health checks fail
+// while it is unhealthy.
+type countingEchoServer struct {
+	address   string
+	calls     atomic.Int64
+	unhealthy atomic.Bool
+}
+
+func startCountingEchoServer(t *testing.T, logger golog.Logger) *countingEchoServer {
+	t.Helper()
+	server := &countingEchoServer{}
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithHealthService(),
+		WithUnaryServerInterceptor(func(
+			ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			switch info.FullMethod {
+			case "/proto.rpc.examples.echo.v1.EchoService/Echo":
+				server.calls.Add(1)
+			case "/grpc.health.v1.Health/Check":
+				if server.unhealthy.Load() {
+					return nil, status.Error(codes.Unavailable, "unhealthy")
+				}
+			}
+			return handler(ctx, req)
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	server.address = listener.Addr().String()
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	})
+
+	// the server only reports itself as serving once started
+	conn, err := Dial(context.Background(), server.address, logger, WithInsecure(), WithForceDirectGRPC())
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, checkHealthService(context.Background(), conn), test.ShouldBeNil)
+	})
+	return server
+}
+
+func TestClientPool(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	_, err := NewClientPool(context.Background(), logger, ClientPoolOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expected addresses")
+	_, err = NewClientPool(context.Background(), logger, ClientPoolOptions{DNSTarget: "localhost"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid DNS target")
+
+	server1 := startCountingEchoServer(t, logger)
+	server2 := startCountingEchoServer(t, logger)
+	dialOpts := []DialOption{WithInsecure(), WithForceDirectGRPC()}
+
+	echo := func(t *testing.T, pool *ClientPool, times int) {
+		t.Helper()
+		for i := 0; i < times; i++ {
+			resp, err := pb.NewEchoServiceClient(pool).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp.Message, test.ShouldEqual, "hello")
+		}
+	}
+	takeCalls := func() (int64, int64) {
+		return server1.calls.Swap(0), server2.calls.Swap(0)
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		pool, err := NewClientPool(context.Background(), logger, ClientPoolOptions{
+			Addresses:             []string{server1.address, server2.address},
+			ConnectionsPerAddress: 2,
+			DialOptions:           dialOpts,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, pool.Close(), test.ShouldBeNil)
+		}()
+		test.That(t, pool.conns, test.ShouldHaveLength, 4)
+
+		echo(t, pool, 8)
+		calls1, calls2 := takeCalls()
+		test.That(t, calls1, test.ShouldEqual, 4)
+		test.That(t, calls2, test.ShouldEqual, 4)
+	})
+
+	t.Run("least loaded", func(t *testing.T) {
+		pool, err := NewClientPool(context.Background(), logger, ClientPoolOptions{
+			Addresses:   []string{server1.address, server2.address},
+			Balancing:   ClientPoolLeastLoaded,
+			DialOptions: dialOpts,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, pool.Close(), test.ShouldBeNil)
+		}()
+
+		// a stream in flight loads its connection until it ends
+		streamCtx, cancelStream := context.WithCancel(context.Background())
+		stream, err := pb.NewEchoServiceClient(pool).EchoBiDi(streamCtx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, stream.Send(&pb.EchoBiDiRequest{Message: "h"}), test.ShouldBeNil)
+		_, err = stream.Recv()
+		test.That(t, err, test.ShouldBeNil)
+
+		echo(t, pool, 4)
+		calls1, calls2 := takeCalls()
+		test.That(t, calls1+calls2, test.ShouldEqual, 4)
+		test.That(t, calls1 == 0 || calls2 == 0, test.ShouldBeTrue)
+
+		cancelStream()
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			for _, pc := range pool.conns {
+				test.That(tb, pc.inFlight.Load(), test.ShouldEqual, 0)
+			}
+		})
+		echo(t, pool, 4)
+		calls1, calls2 = takeCalls()
+		test.That(t, calls1, test.ShouldEqual, 2)
+		test.That(t, calls2, test.ShouldEqual, 2)
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		pool, err := NewClientPool(context.Background(), logger, ClientPoolOptions{
+			Addresses:           []string{server1.address, server2.address},
+			HealthCheckInterval: 10 * time.Millisecond,
+			FailureThreshold:    2,
+			DialOptions:         dialOpts,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, pool.Close(), test.ShouldBeNil)
+		}()
+		test.That(t, pool.healthyCount(), test.ShouldEqual, 2)
+
+		server2.unhealthy.Store(true)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, pool.healthyCount(), test.ShouldEqual, 1)
+		})
+		takeCalls()
+		echo(t, pool, 4)
+		calls1, calls2 := takeCalls()
+		test.That(t, calls1, test.ShouldEqual, 4)
+		test.That(t, calls2, test.ShouldEqual, 0)
+
+		// evicted connections are redialed
+		server2.unhealthy.Store(false)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, pool.healthyCount(), test.ShouldEqual, 2)
+		})
+		echo(t, pool, 4)
+		calls1, calls2 = takeCalls()
+		test.That(t, calls1, test.ShouldEqual, 2)
+		test.That(t, calls2, test.ShouldEqual, 2)
+	})
+
+	t.Run("dns", func(t *testing.T) {
+		_, port, err := net.SplitHostPort(server1.address)
+		test.That(t, err, test.ShouldBeNil)
+		pool, err := NewClientPool(context.Background(), logger, ClientPoolOptions{
+			DNSTarget:          net.JoinHostPort("localhost", port),
+			HealthCheckTimeout: time.Second,
+			DialOptions:        dialOpts,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, pool.Close(), test.ShouldBeNil)
+		}()
+		test.That(t, pool.addresses, test.ShouldContain, net.JoinHostPort("127.0.0.1", port))
+
+		takeCalls()
+		echo(t, pool, 2)
+		calls1, _ := takeCalls()
+		test.That(t, calls1, test.ShouldEqual, 2)
+	})
+
+	t.Run("no healthy connections", func(t *testing.T) {
+		_, err := NewClientPool(context.Background(), logger, ClientPoolOptions{
+			Addresses:   []string{server1.address},
+			HealthCheck: func(ctx context.Context, conn ClientConn) error { return errors.New("unhealthy") },
+			DialOptions: dialOpts,
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, errors.Is(err, ErrNoHealthyConnections), test.ShouldBeTrue)
+	})
+}
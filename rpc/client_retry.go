@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/utils"
+)
+
+// The defaults of a RetryPolicy.
+const (
+	defaultRetryInitialBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff        = 5 * time.Second
+	defaultRetryBackoffMultiplier = 2
+)
+
+// A RetryPolicy configures how failed unary calls are retried. Streams are never retried.
+// If a failed attempt carries MetadataFieldRetryAfter, as rate limited calls do, the next
+// attempt waits at least that long.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a call is attempted, including the first attempt. Calls
+	// are not retried unless it is at least 2.
+	MaxAttempts int
+
+	// RetryableCodes are the codes a failed attempt must have to be retried. If empty, only
+	// codes.Unavailable is retried.
+	RetryableCodes []codes.Code
+
+	// InitialBackoff is the most to wait before the first retry. The most to wait before each
+	// retry after it grows by BackoffMultiplier up to MaxBackoff and the actual wait is chosen
+	// at random up to that so that clients do not retry in lockstep. These default to 100ms,
+	// 5s, and 2.
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+
+	// HedgedMethods are patterns (see path.Match) of the full names of idempotent methods
+	// whose calls are hedged: instead of waiting for an attempt to fail before retrying,
+	// another attempt is started every HedgingDelay, or as soon as one fails with a retryable
+	// code, until MaxAttempts are started. The first successful or non-retryable response is
+	// used and the other attempts are canceled.
+	HedgedMethods []string
+
+	// HedgingDelay is how long to wait for a response to a hedged call before starting
+	// another attempt. If zero, all attempts are started at once.
+	HedgingDelay time.Duration
+}
+
+// WithRetryPolicy returns a DialOption which retries failed unary calls according to the
+// policy. It applies to calls over both direct gRPC and WebRTC connections, and each attempt
+// passes through the interceptors given by WithUnaryClientInterceptor.
+func WithRetryPolicy(policy RetryPolicy) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.retryPolicy = &policy
+	})
+}
+
+// retrier retries calls according to a RetryPolicy.
+type retrier struct {
+	policy    RetryPolicy
+	retryable map[codes.Code]bool
+	random    func() float64
+}
+
+func newRetrier(policy RetryPolicy) *retrier {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if policy.BackoffMultiplier < 1 {
+		policy.BackoffMultiplier = defaultRetryBackoffMultiplier
+	}
+	retryable := map[codes.Code]bool{}
+	for _, code := range policy.RetryableCodes {
+		retryable[code] = true
+	}
+	if len(retryable) == 0 {
+		retryable[codes.Unavailable] = true
+	}
+	return &retrier{
+		policy:    policy,
+		retryable: retryable,
+		//nolint:gosec
+		random: rand.Float64,
+	}
+}
+
+// backoff returns how long to wait before retrying after the given (zero based) attempt
+// failed with the given metadata.
+func (r *retrier) backoff(attempt int, header, trailer metadata.MD) time.Duration {
+	most := math.Min(
+		float64(r.policy.InitialBackoff)*math.Pow(r.policy.BackoffMultiplier, float64(attempt)),
+		float64(r.policy.MaxBackoff),
+	)
+	backoff := time.Duration(r.random() * most)
+	if pushback := retryAfter(header, trailer); pushback > backoff {
+		return pushback
+	}
+	return backoff
+}
+
+// retryAfter returns how long the server asked to wait before trying again, if at all.
+func retryAfter(mds ...metadata.MD) time.Duration {
+	for _, md := range mds {
+		if values := md.Get(MetadataFieldRetryAfter); len(values) != 0 {
+			if seconds, err := strconv.Atoi(values[0]); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+func (r *retrier) unaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		if r.policy.MaxAttempts < 2 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		// hedged attempts each need their own reply to receive into.
+		if replyMsg, ok := reply.(proto.Message); ok && matchesAny(r.policy.HedgedMethods, method) {
+			return r.hedge(ctx, method, req, replyMsg, cc, invoker, opts...)
+		}
+		return r.retry(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
+
+// retry makes attempts one after the other until one succeeds, fails with a code that is
+// not retryable, or there are no attempts left.
+func (r *retrier) retry(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	var header, trailer metadata.MD
+	return utils.Retry(ctx, utils.RetryPolicy{
+		MaxAttempts: r.policy.MaxAttempts,
+		Backoff: func(attempt utils.RetryAttempt) time.Duration {
+			return r.backoff(attempt.Number-1, header, trailer)
+		},
+		RetryOn: func(err error) bool {
+			return r.retryable[status.Code(err)]
+		},
+	}, func() error {
+		header, trailer = nil, nil
+		attemptOpts := append(append([]grpc.CallOption{}, opts...), grpc.Header(&header), grpc.Trailer(&trailer))
+		return invoker(ctx, method, req, reply, cc, attemptOpts...)
+	})
+}
+
+// hedgedAttempt is the result of one attempt of a hedged call.
+type hedgedAttempt struct {
+	reply           proto.Message
+	header, trailer metadata.MD
+	err             error
+}
+
+// hedge makes attempts concurrently until one succeeds, fails with a code that is not
+// retryable, or all attempts have failed. Only the response of the attempt used is put
+// in the reply and header and trailer call options.
+func (r *retrier) hedge(ctx context.Context, method string, req interface{}, reply proto.Message,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var headerAddrs, trailerAddrs []*metadata.MD
+	var attemptOpts []grpc.CallOption
+	for _, opt := range opts {
+		switch optV := opt.(type) {
+		case grpc.HeaderCallOption:
+			headerAddrs = append(headerAddrs, optV.HeaderAddr)
+		case grpc.TrailerCallOption:
+			trailerAddrs = append(trailerAddrs, optV.TrailerAddr)
+		default:
+			attemptOpts = append(attemptOpts, opt)
+		}
+	}
+	use := func(attempt hedgedAttempt) error {
+		if attempt.err == nil {
+			proto.Reset(reply)
+			proto.Merge(reply, attempt.reply)
+		}
+		for _, addr := range headerAddrs {
+			*addr = attempt.header
+		}
+		for _, addr := range trailerAddrs {
+			*addr = attempt.trailer
+		}
+		return attempt.err
+	}
+
+	// buffered so that attempts finishing after one is used do not block.
+	results := make(chan hedgedAttempt, r.policy.MaxAttempts)
+	startAttempt := func() {
+		utils.PanicCapturingGo(func() {
+			attempt := hedgedAttempt{reply: reply.ProtoReflect().New().Interface()}
+			attempt.err = invoker(ctx, method, req, attempt.reply, cc,
+				append(append([]grpc.CallOption{}, attemptOpts...), grpc.Header(&attempt.header), grpc.Trailer(&attempt.trailer))...)
+			results <- attempt
+		})
+	}
+
+	started, pending := 1, 1
+	startAttempt()
+	// a single timer is reset for each attempt so that none are left running once the call
+	// is done.
+	hedgeTimer := time.NewTimer(r.policy.HedgingDelay)
+	defer hedgeTimer.Stop()
+	resetHedgeTimer := func(wait time.Duration) {
+		if !hedgeTimer.Stop() {
+			select {
+			case <-hedgeTimer.C:
+			default:
+			}
+		}
+		hedgeTimer.Reset(wait)
+	}
+	var last hedgedAttempt
+	for pending > 0 || started < r.policy.MaxAttempts {
+		var hedge <-chan time.Time
+		if started < r.policy.MaxAttempts {
+			hedge = hedgeTimer.C
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-hedge:
+			started++
+			pending++
+			startAttempt()
+			hedgeTimer.Reset(r.policy.HedgingDelay)
+		case attempt := <-results:
+			pending--
+			last = attempt
+			if attempt.err == nil || !r.retryable[status.Code(attempt.err)] {
+				return use(attempt)
+			}
+			// a retryable failure starts the next attempt right away unless the server asked to wait.
+			resetHedgeTimer(retryAfter(attempt.header, attempt.trailer))
+		}
+	}
+	return use(last)
+}
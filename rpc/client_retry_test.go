@@ -0,0 +1,237 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	const method = "/proto.rpc.examples.echo.v1.EchoService/Echo"
+
+	// failingInvoker fails the first calls with the given errors and then echoes.
+	failingInvoker := func(errs ...error) (grpc.UnaryInvoker, func() int) {
+		var mu sync.Mutex
+		var calls int
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				mu.Lock()
+				call := calls
+				calls++
+				mu.Unlock()
+				if call < len(errs) {
+					return errs[call]
+				}
+				reply.(*pb.EchoResponse).Message = req.(*pb.EchoRequest).Message
+				return nil
+			}, func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return calls
+			}
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	t.Run("retry", func(t *testing.T) {
+		r := newRetrier(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+		interceptor := r.unaryClientInterceptor()
+
+		invoker, calls := failingInvoker(unavailable, unavailable)
+		var resp pb.EchoResponse
+		err := interceptor(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp.Message, test.ShouldEqual, "hi")
+		test.That(t, calls(), test.ShouldEqual, 3)
+
+		// attempts run out
+		invoker, calls = failingInvoker(unavailable, unavailable, unavailable)
+		err = interceptor(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldEqual, unavailable)
+		test.That(t, calls(), test.ShouldEqual, 3)
+
+		// other codes are not retried
+		invalid := status.Error(codes.InvalidArgument, "invalid")
+		invoker, calls = failingInvoker(invalid)
+		err = interceptor(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldEqual, invalid)
+		test.That(t, calls(), test.ShouldEqual, 1)
+
+		// a done context stops retrying
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r = newRetrier(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+		invoker, calls = failingInvoker(unavailable, unavailable)
+		err = r.unaryClientInterceptor()(ctx, method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldEqual, unavailable)
+		test.That(t, calls(), test.ShouldEqual, 1)
+
+		// without a policy there is one attempt
+		invoker, calls = failingInvoker(unavailable)
+		err = newRetrier(RetryPolicy{}).unaryClientInterceptor()(context.Background(), method, &pb.EchoRequest{}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldEqual, unavailable)
+		test.That(t, calls(), test.ShouldEqual, 1)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		r := newRetrier(RetryPolicy{MaxAttempts: 5, RetryableCodes: []codes.Code{codes.Aborted}})
+		test.That(t, r.retryable, test.ShouldResemble, map[codes.Code]bool{codes.Aborted: true})
+		r.random = func() float64 { return 1 }
+		test.That(t, r.backoff(0, nil, nil), test.ShouldEqual, defaultRetryInitialBackoff)
+		test.That(t, r.backoff(2, nil, nil), test.ShouldEqual, 4*defaultRetryInitialBackoff)
+		test.That(t, r.backoff(10, nil, nil), test.ShouldEqual, defaultRetryMaxBackoff)
+		r.random = func() float64 { return 0.5 }
+		test.That(t, r.backoff(1, nil, nil), test.ShouldEqual, defaultRetryInitialBackoff)
+
+		// servers may ask to wait longer
+		pushback := metadata.Pairs(MetadataFieldRetryAfter, "7")
+		test.That(t, r.backoff(1, pushback, nil), test.ShouldEqual, 7*time.Second)
+		test.That(t, r.backoff(1, nil, pushback), test.ShouldEqual, 7*time.Second)
+		test.That(t, r.backoff(1, metadata.Pairs(MetadataFieldRetryAfter, "nope"), nil), test.ShouldEqual, defaultRetryInitialBackoff)
+	})
+
+	t.Run("hedge", func(t *testing.T) {
+		r := newRetrier(RetryPolicy{MaxAttempts: 3, HedgedMethods: []string{method}, HedgingDelay: 10 * time.Millisecond})
+		interceptor := r.unaryClientInterceptor()
+
+		// the first attempt never responds so the second is used
+		var mu sync.Mutex
+		var attempts int
+		slowFirst := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			mu.Lock()
+			attempts++
+			attempt := attempts
+			mu.Unlock()
+			if attempt == 1 {
+				<-ctx.Done()
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			for _, opt := range opts {
+				if header, ok := opt.(grpc.HeaderCallOption); ok {
+					*header.HeaderAddr = metadata.Pairs("attempt", "2")
+				}
+			}
+			reply.(*pb.EchoResponse).Message = req.(*pb.EchoRequest).Message
+			return nil
+		}
+		var resp pb.EchoResponse
+		var header metadata.MD
+		err := interceptor(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, slowFirst, grpc.Header(&header))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp.Message, test.ShouldEqual, "hi")
+		test.That(t, header.Get("attempt"), test.ShouldResemble, []string{"2"})
+		mu.Lock()
+		test.That(t, attempts, test.ShouldEqual, 2)
+		mu.Unlock()
+
+		// retryable failures start the next attempt right away
+		r = newRetrier(RetryPolicy{MaxAttempts: 3, HedgedMethods: []string{"/proto.rpc.examples.echo.v1.EchoService/*"}, HedgingDelay: time.Hour})
+		invoker, calls := failingInvoker(unavailable, unavailable)
+		err = r.unaryClientInterceptor()(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, calls(), test.ShouldEqual, 3)
+
+		invoker, calls = failingInvoker(unavailable, unavailable, unavailable)
+		err = r.unaryClientInterceptor()(context.Background(), method, &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldEqual, unavailable)
+		test.That(t, calls(), test.ShouldEqual, 3)
+
+		// other methods are retried, not hedged
+		invoker, calls = failingInvoker(unavailable)
+		r.random = func() float64 { return 0 }
+		err = r.unaryClientInterceptor()(context.Background(), "/other/Method", &pb.EchoRequest{Message: "hi"}, &resp, nil, invoker)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, calls(), test.ShouldEqual, 2)
+	})
+}
+
+func TestDialRetryPolicy(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	listenerAddr := listener.Addr().String()
+
+	// every call fails twice before succeeding
+	var mu sync.Mutex
+	var attempts int
+	failTwice := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if info.FullMethod != "/proto.rpc.examples.echo.v1.EchoService/Echo" {
+			return handler(ctx, req)
+		}
+		mu.Lock()
+		attempts++
+		attempt := attempts
+		mu.Unlock()
+		if attempt%3 != 0 {
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		return handler(ctx, req)
+	}
+	takeAttempts := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		taken := attempts
+		attempts = 0
+		return taken
+	}
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{listenerAddr},
+		}),
+		WithUnaryServerInterceptor(failTwice),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	policy := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	for _, tc := range []struct {
+		name string
+		opts []DialOption
+	}{
+		{"grpc", []DialOption{WithForceDirectGRPC()}},
+		{"webrtc", []DialOption{
+			WithDisableDirectGRPC(),
+			WithWebRTCOptions(DialWebRTCOptions{SignalingInsecure: true}),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := Dial(context.Background(), listenerAddr, logger, append([]DialOption{WithInsecure(), policy}, tc.opts...)...)
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+			takeAttempts()
+
+			resp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp.Message, test.ShouldEqual, "hello")
+			test.That(t, takeAttempts(), test.ShouldEqual, 3)
+		})
+	}
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -0,0 +1,120 @@
+// Package main decodes frame traces written by a traced WebRTC channel into a
+// human readable form along with a per stream summary.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/edaniels/golog"
+
+	"go.viam.com/utils"
+	"go.viam.com/utils/rpc"
+)
+
+var logger = golog.NewDevelopmentLogger("frametrace")
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logger)
+}
+
+// Arguments for the command.
+type Arguments struct {
+	Path    string `flag:"0,usage=trace file to read; defaults to stdin"`
+	Summary bool   `flag:"summary,usage=only print the per stream summary"`
+}
+
+type streamKey struct {
+	channel uint64
+	stream  uint64
+}
+
+type streamSummary struct {
+	frames   map[rpc.FrameDirection]int
+	bytes    map[rpc.FrameDirection]int
+	messages map[rpc.FrameDirection]int
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) error {
+	var argsParsed Arguments
+	if err := utils.ParseFlags(args, &argsParsed); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if argsParsed.Path != "" {
+		//nolint:gosec
+		f, err := os.Open(argsParsed.Path)
+		if err != nil {
+			return err
+		}
+		defer utils.UncheckedErrorFunc(f.Close)
+		in = f
+	}
+
+	summaries := map[streamKey]*streamSummary{}
+	dec := rpc.NewFrameTraceDecoder(in)
+	for {
+		trace, err := dec.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if !argsParsed.Summary {
+			var flags string
+			if trace.EOM {
+				flags += " eom"
+			}
+			if trace.EOS {
+				flags += " eos"
+			}
+			fmt.Fprintf(os.Stdout, "%s ch=%d %-3s stream=%d %-10s %6d bytes%s\n",
+				trace.Time.Format("15:04:05.000000"), trace.Channel, trace.Direction, trace.Stream, trace.Type, trace.Size, flags)
+		}
+
+		key := streamKey{trace.Channel, trace.Stream}
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &streamSummary{
+				frames:   map[rpc.FrameDirection]int{},
+				bytes:    map[rpc.FrameDirection]int{},
+				messages: map[rpc.FrameDirection]int{},
+			}
+			summaries[key] = summary
+		}
+		summary.frames[trace.Direction]++
+		summary.bytes[trace.Direction] += trace.Size
+		if trace.EOM {
+			summary.messages[trace.Direction]++
+		}
+	}
+
+	keys := make([]streamKey, 0, len(summaries))
+	for key := range summaries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].channel != keys[j].channel {
+			return keys[i].channel < keys[j].channel
+		}
+		return keys[i].stream < keys[j].stream
+	})
+	if !argsParsed.Summary && len(keys) != 0 {
+		fmt.Fprintln(os.Stdout)
+	}
+	for _, key := range keys {
+		summary := summaries[key]
+		fmt.Fprintf(os.Stdout, "ch=%d stream=%d out: %d frames, %d messages, %d bytes; in: %d frames, %d messages, %d bytes\n",
+			key.channel, key.stream,
+			summary.frames[rpc.FrameDirectionOutbound], summary.messages[rpc.FrameDirectionOutbound], summary.bytes[rpc.FrameDirectionOutbound],
+			summary.frames[rpc.FrameDirectionInbound], summary.messages[rpc.FrameDirectionInbound], summary.bytes[rpc.FrameDirectionInbound],
+		)
+	}
+	return nil
+}
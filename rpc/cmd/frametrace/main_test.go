@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+	"go.viam.com/utils/testutils"
+)
+
+func TestMainMain(t *testing.T) {
+	dir := t.TempDir()
+
+	tracePath := filepath.Join(dir, "trace.jsonl")
+	traceFile, err := os.Create(tracePath)
+	test.That(t, err, test.ShouldBeNil)
+	enc := json.NewEncoder(traceFile)
+	now := time.Now()
+	for _, trace := range []rpc.FrameTrace{
+		{Time: now, Channel: 1, Direction: rpc.FrameDirectionOutbound, Stream: 1, Type: rpc.FrameTypeHeaders, Size: 42},
+		{Time: now, Channel: 1, Direction: rpc.FrameDirectionOutbound, Stream: 1, Type: rpc.FrameTypeMessage, Size: 10, EOM: true, EOS: true},
+		{Time: now, Channel: 1, Direction: rpc.FrameDirectionInbound, Stream: 1, Type: rpc.FrameTypeTrailers, Size: 5},
+		{Time: now, Channel: 2, Direction: rpc.FrameDirectionInbound, Stream: 3, Type: rpc.FrameTypeRstStream, Size: 2},
+	} {
+		test.That(t, enc.Encode(trace), test.ShouldBeNil)
+	}
+	test.That(t, traceFile.Close(), test.ShouldBeNil)
+
+	emptyPath := filepath.Join(dir, "empty.jsonl")
+	test.That(t, os.WriteFile(emptyPath, nil, 0o600), test.ShouldBeNil)
+
+	badPath := filepath.Join(dir, "bad.jsonl")
+	test.That(t, os.WriteFile(badPath, []byte("not a trace\n"), 0o600), test.ShouldBeNil)
+
+	testutils.TestMain(t, mainWithArgs, []testutils.MainTestCase{
+		{
+			Name: "missing trace",
+			Args: []string{filepath.Join(dir, "missing.jsonl")},
+			Err:  "missing.jsonl",
+		},
+		{
+			Name: "bad trace",
+			Args: []string{badPath},
+			Err:  "invalid character",
+		},
+		{
+			Name: "empty trace",
+			Args: []string{emptyPath},
+		},
+		{
+			Name: "trace",
+			Args: []string{tracePath},
+		},
+		{
+			Name: "summary",
+			Args: []string{tracePath, "--summary"},
+		},
+	})
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables that override the config file. The rest of
+// a variable's name is the path of keys to the setting it overrides, uppercased and joined
+// by underscores (e.g. SIGNALING_SERVER_MONGODB_URI). Lists are comma separated.
+const EnvPrefix = "SIGNALING_SERVER"
+
+// Defaults used for unset fields of a Config.
+const (
+	DefaultBindAddress         = "localhost:8080"
+	DefaultMaxHostCallers      = 50
+	DefaultShutdownGracePeriod = 10 * time.Second
+)
+
+// Config configures a signaling server. It is read from YAML, which JSON is also valid as.
+type Config struct {
+	// BindAddress is where the server listens for gRPC, gRPC-Web, and REST requests.
+	BindAddress string `yaml:"bind_address"`
+
+	// InstanceNames name the server for issuing access tokens.
+	InstanceNames []string `yaml:"instance_names"`
+
+	// Hosts, if set, are the only hosts that may be called and answered for.
+	Hosts []string `yaml:"hosts"`
+
+	// ShutdownGracePeriod is how long calls in flight are given to finish when stopping.
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+
+	TLS       TLSConfig       `yaml:"tls"`
+	Auth      AuthConfig      `yaml:"auth"`
+	MongoDB   MongoDBConfig   `yaml:"mongodb"`
+	CallQueue CallQueueConfig `yaml:"call_queue"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+
+	// ICEServers, such as TURN relays, are given to callers and answerers to connect with.
+	ICEServers []ICEServerConfig `yaml:"ice_servers"`
+}
+
+// TLSConfig sets the certificate the server is served with. Without one, it is served
+// insecurely.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// AuthConfig sets how callers and answerers authenticate.
+type AuthConfig struct {
+	// Unauthenticated turns off authentication. It cannot be combined with any other way
+	// to authenticate.
+	Unauthenticated bool `yaml:"unauthenticated"`
+
+	// APIKeys are API keys that are always valid. They cannot be combined with
+	// MongoDBAPIKeys.
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+
+	// MongoDBAPIKeys looks up API keys in MongoDB.
+	MongoDBAPIKeys bool `yaml:"mongodb_api_keys"`
+
+	// PrivateKeyFile is a PEM encoded PKCS #8 key that access tokens are signed with. If
+	// unset, a key is generated on every start.
+	PrivateKeyFile string `yaml:"private_key_file"`
+
+	// Audience is what access tokens are for. It defaults to the instance names.
+	Audience []string `yaml:"audience"`
+
+	OIDC OIDCConfig `yaml:"oidc"`
+}
+
+// An APIKeyConfig is an API key by its ID and secret.
+type APIKeyConfig struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+// OIDCConfig lets entities authenticate with tokens of an OIDC provider when an issuer is
+// set. See rpc.OIDCAuthHandlerOptions.
+type OIDCConfig struct {
+	Issuer      string   `yaml:"issuer"`
+	Audience    []string `yaml:"audience"`
+	JWKSURI     string   `yaml:"jwks_uri"`
+	EntityClaim string   `yaml:"entity_claim"`
+}
+
+// MongoDBConfig sets the deployment that calls are queued in and API keys are looked up
+// in. Without a URI, calls are queued in memory and only one server can be run.
+type MongoDBConfig struct {
+	URI     string `yaml:"uri"`
+	AppName string `yaml:"app_name"`
+}
+
+// CallQueueConfig configures queueing calls in MongoDB.
+type CallQueueConfig struct {
+	// OperatorID uniquely identifies this server among those sharing the queue. It defaults
+	// to the hostname.
+	OperatorID string `yaml:"operator_id"`
+
+	// MaxHostCallers is about how many calls may wait on a host. It defaults to
+	// DefaultMaxHostCallers.
+	MaxHostCallers uint64 `yaml:"max_host_callers"`
+}
+
+// MetricsConfig configures Prometheus metrics.
+type MetricsConfig struct {
+	// Enable serves metrics about the server at rpc.MetricsPath.
+	Enable bool `yaml:"enable"`
+}
+
+// An ICEServerConfig is a STUN or TURN server.
+type ICEServerConfig struct {
+	URLs       []string `yaml:"urls"`
+	Username   string   `yaml:"username"`
+	Credential string   `yaml:"credential"`
+}
+
+// LoadConfig reads a config from the YAML or JSON file at the given path, overrides it with
+// the environment, and fills in defaults.
+func LoadConfig(path string) (*Config, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data, os.LookupEnv)
+}
+
+func parseConfig(data []byte, lookupEnv func(key string) (string, bool)) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "error parsing config")
+	}
+	if err := overrideFromEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix, lookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.BindAddress == "" {
+		cfg.BindAddress = DefaultBindAddress
+	}
+	if cfg.ShutdownGracePeriod == 0 {
+		cfg.ShutdownGracePeriod = DefaultShutdownGracePeriod
+	}
+	if cfg.CallQueue.MaxHostCallers == 0 {
+		cfg.CallQueue.MaxHostCallers = DefaultMaxHostCallers
+	}
+	if cfg.MongoDB.URI != "" && cfg.CallQueue.OperatorID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting hostname for operator ID")
+		}
+		cfg.CallQueue.OperatorID = hostname
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return errors.New("must provide both tls.cert_file and tls.key_file")
+	}
+	if cfg.ShutdownGracePeriod < 0 {
+		return errors.New("shutdown_grace_period must not be negative")
+	}
+	for i, key := range cfg.Auth.APIKeys {
+		if key.ID == "" || key.Secret == "" {
+			return errors.Errorf("auth.api_keys[%d] must have an id and secret", i)
+		}
+	}
+	if cfg.Auth.MongoDBAPIKeys && cfg.MongoDB.URI == "" {
+		return errors.New("auth.mongodb_api_keys requires mongodb.uri")
+	}
+	if cfg.Auth.MongoDBAPIKeys && len(cfg.Auth.APIKeys) != 0 {
+		return errors.New("auth.api_keys cannot be combined with auth.mongodb_api_keys; store them in MongoDB instead")
+	}
+	authenticates := len(cfg.Auth.APIKeys) != 0 || cfg.Auth.MongoDBAPIKeys || cfg.Auth.OIDC.Issuer != ""
+	if cfg.Auth.Unauthenticated && authenticates {
+		return errors.New("auth.unauthenticated cannot be combined with other auth settings")
+	}
+	if !cfg.Auth.Unauthenticated && !authenticates {
+		return errors.New("expected a way to authenticate or auth.unauthenticated")
+	}
+	for i, server := range cfg.ICEServers {
+		if len(server.URLs) == 0 {
+			return errors.Errorf("ice_servers[%d] must have at least one url", i)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// overrideFromEnv sets the fields of the given struct from the environment variables named
+// after their keys under the given prefix.
+func overrideFromEnv(v reflect.Value, prefix string, lookupEnv func(key string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+		fieldV := v.Field(i)
+		if fieldV.Kind() == reflect.Struct {
+			if err := overrideFromEnv(fieldV, name, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fieldV, value); err != nil {
+			return errors.Wrapf(err, "error overriding from %s", name)
+		}
+	}
+	return nil
+}
+
+func setFromEnv(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	//nolint:exhaustive
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("cannot set a list of %s from the environment", v.Type().Elem())
+		}
+		var values []string
+		if value != "" {
+			values = strings.Split(value, ",")
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		return errors.Errorf("cannot set a %s from the environment", v.Type())
+	}
+	return nil
+}
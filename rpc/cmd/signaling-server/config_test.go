@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestLoadConfig(t *testing.T) {
+	noEnv := func(key string) (string, bool) {
+		return "", false
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		cfg, err := parseConfig([]byte(`
+bind_address: 0.0.0.0:9000
+hosts: [robot1, robot2]
+shutdown_grace_period: 30s
+auth:
+  api_keys:
+    - id: key1
+      secret: shh
+mongodb:
+  uri: mongodb://localhost:27017
+call_queue:
+  operator_id: op1
+metrics:
+  enable: true
+ice_servers:
+  - urls: ["turn:turn.example.com"]
+    username: user
+    credential: pass
+`), noEnv)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfg, test.ShouldResemble, &Config{
+			BindAddress:         "0.0.0.0:9000",
+			Hosts:               []string{"robot1", "robot2"},
+			ShutdownGracePeriod: 30 * time.Second,
+			Auth:                AuthConfig{APIKeys: []APIKeyConfig{{ID: "key1", Secret: "shh"}}},
+			MongoDB:             MongoDBConfig{URI: "mongodb://localhost:27017"},
+			CallQueue:           CallQueueConfig{OperatorID: "op1", MaxHostCallers: DefaultMaxHostCallers},
+			Metrics:             MetricsConfig{Enable: true},
+			ICEServers: []ICEServerConfig{
+				{URLs: []string{"turn:turn.example.com"}, Username: "user", Credential: "pass"},
+			},
+		})
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cfg, err := parseConfig([]byte(`{"auth": {"unauthenticated": true}, "hosts": ["robot1"]}`), noEnv)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfg.Auth.Unauthenticated, test.ShouldBeTrue)
+		test.That(t, cfg.Hosts, test.ShouldResemble, []string{"robot1"})
+		test.That(t, cfg.BindAddress, test.ShouldEqual, DefaultBindAddress)
+		test.That(t, cfg.ShutdownGracePeriod, test.ShouldEqual, DefaultShutdownGracePeriod)
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		env := map[string]string{
+			"SIGNALING_SERVER_BIND_ADDRESS":                "localhost:9001",
+			"SIGNALING_SERVER_HOSTS":                       "robot3,robot4",
+			"SIGNALING_SERVER_SHUTDOWN_GRACE_PERIOD":       "1m",
+			"SIGNALING_SERVER_AUTH_UNAUTHENTICATED":        "false",
+			"SIGNALING_SERVER_AUTH_OIDC_ISSUER":            "https://issuer.example.com/",
+			"SIGNALING_SERVER_CALL_QUEUE_MAX_HOST_CALLERS": "10",
+		}
+		cfg, err := parseConfig([]byte(`
+bind_address: 0.0.0.0:9000
+auth:
+  unauthenticated: true
+`), func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfg.BindAddress, test.ShouldEqual, "localhost:9001")
+		test.That(t, cfg.Hosts, test.ShouldResemble, []string{"robot3", "robot4"})
+		test.That(t, cfg.ShutdownGracePeriod, test.ShouldEqual, time.Minute)
+		test.That(t, cfg.Auth.Unauthenticated, test.ShouldBeFalse)
+		test.That(t, cfg.Auth.OIDC.Issuer, test.ShouldEqual, "https://issuer.example.com/")
+		test.That(t, cfg.CallQueue.MaxHostCallers, test.ShouldEqual, uint64(10))
+
+		env = map[string]string{"SIGNALING_SERVER_METRICS_ENABLE": "maybe"}
+		_, err = parseConfig([]byte(`{"auth": {"unauthenticated": true}}`), func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "SIGNALING_SERVER_METRICS_ENABLE")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			config string
+			err    string
+		}{
+			{`bind_adress: localhost:9000`, "field bind_adress not found"},
+			{`{}`, "expected a way to authenticate"},
+			{`{"auth": {"unauthenticated": true, "api_keys": [{"id": "a", "secret": "b"}]}}`, "cannot be combined"},
+			{`{"auth": {"api_keys": [{"id": "a"}]}}`, "auth.api_keys[0] must have an id and secret"},
+			{`{"auth": {"mongodb_api_keys": true}}`, "requires mongodb.uri"},
+			{`{"auth": {"unauthenticated": true}, "tls": {"cert_file": "cert.pem"}}`, "must provide both"},
+			{`{"auth": {"unauthenticated": true}, "ice_servers": [{}]}`, "ice_servers[0] must have at least one url"},
+		} {
+			_, err := parseConfig([]byte(tc.config), noEnv)
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		test.That(t, os.WriteFile(path, []byte("auth:\n  unauthenticated: true\n"), 0o600), test.ShouldBeNil)
+		cfg, err := LoadConfig(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfg.Auth.Unauthenticated, test.ShouldBeTrue)
+
+		_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
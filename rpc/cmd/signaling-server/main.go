@@ -0,0 +1,276 @@
+// Package main runs a standalone WebRTC signaling server that callers and answerers
+// rendezvous through, configured by a YAML or JSON file that environment variables can
+// override (see EnvPrefix).
+//
+// Calls are queued in memory unless MongoDB is configured, in which case they are queued
+// in it so that many servers can share the load. Callers and answerers authenticate with
+// API keys, kept in the config or in MongoDB, or with tokens of an OIDC provider. ICE servers,
+// such as TURN relays, can be handed out to peers, and Prometheus metrics can be served.
+// See config.example.yaml for every setting.
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+	mongoutils "go.viam.com/utils/mongo"
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+	"go.viam.com/utils/rpc"
+)
+
+var logger = golog.Global().Named("signaling-server")
+
+// closeTimeout is how long each component is given to close, beyond the shutdown grace
+// period of the server.
+const closeTimeout = 10 * time.Second
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logger)
+}
+
+// Arguments for the command.
+type Arguments struct {
+	ConfigPath string `flag:"0,required,usage=path to the YAML or JSON config file"`
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) error {
+	var argsParsed Arguments
+	if err := utils.ParseFlags(args, &argsParsed); err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(argsParsed.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return runServer(ctx, cfg, logger)
+}
+
+func runServer(ctx context.Context, cfg *Config, logger golog.Logger) (err error) {
+	closer := utils.NewCloser()
+	defer func() {
+		err = multierr.Combine(err, closer.Close(context.Background()))
+	}()
+
+	listener, err := net.Listen("tcp", cfg.BindAddress)
+	if err != nil {
+		return err
+	}
+	listenerTCPAddr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return multierr.Combine(
+			errors.Errorf("expected *net.TCPAddr but got %T", listener.Addr()),
+			listener.Close(),
+		)
+	}
+
+	serverOpts := []rpc.ServerOption{
+		rpc.WithExternalListenerAddress(listenerTCPAddr),
+		rpc.WithDisableMulticastDNS(),
+		rpc.WithHealthService(),
+		rpc.WithAllowUnauthenticatedHealthCheck(),
+		rpc.WithShutdownGracePeriod(cfg.ShutdownGracePeriod),
+	}
+	if len(cfg.InstanceNames) != 0 {
+		serverOpts = append(serverOpts, rpc.WithInstanceNames(cfg.InstanceNames...))
+	}
+
+	var mongoClient *mongoutils.Client
+	if cfg.MongoDB.URI != "" {
+		mongoClient, err = mongoutils.NewClient(ctx, mongoutils.ClientConfig{
+			URI:     cfg.MongoDB.URI,
+			AppName: cfg.MongoDB.AppName,
+		})
+		if err != nil {
+			return multierr.Combine(err, listener.Close())
+		}
+		closer.Add("mongodb client", closeTimeout, mongoClient.Disconnect)
+	}
+
+	authOpts, err := authServerOptions(ctx, cfg.Auth, mongoClient, closer)
+	if err != nil {
+		return multierr.Combine(err, listener.Close())
+	}
+	serverOpts = append(serverOpts, authOpts...)
+
+	if cfg.Metrics.Enable {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		serverOpts = append(serverOpts, rpc.WithMetrics(registry))
+	}
+
+	var callQueue rpc.WebRTCCallQueue
+	callQueueKind := "memory"
+	if mongoClient != nil {
+		callQueueKind = "mongodb"
+		callQueue, err = rpc.NewMongoDBWebRTCCallQueue(
+			ctx,
+			cfg.CallQueue.OperatorID,
+			cfg.CallQueue.MaxHostCallers,
+			mongoClient.Client,
+			logger,
+			func(hostnames []string, atTime time.Time) {},
+		)
+		if err != nil {
+			return multierr.Combine(err, listener.Close())
+		}
+	} else {
+		callQueue = rpc.NewMemoryWebRTCCallQueue(logger)
+	}
+	closer.Add("call queue", closeTimeout, utils.CloseWithoutContext(callQueue.Close))
+
+	rpcServer, err := rpc.NewServer(logger, serverOpts...)
+	if err != nil {
+		return multierr.Combine(err, listener.Close())
+	}
+	closer.Add("rpc server", cfg.ShutdownGracePeriod+closeTimeout, utils.CloseWithoutContext(rpcServer.Stop))
+
+	var configProvider rpc.WebRTCConfigProvider
+	if len(cfg.ICEServers) != 0 {
+		configProvider = newStaticWebRTCConfigProvider(cfg.ICEServers)
+	}
+	signalingServer := rpc.NewWebRTCSignalingServer(callQueue, configProvider, logger, cfg.Hosts...)
+	// closed before the rpc server so that answerers waiting on calls do not hold up draining it.
+	closer.Add("signaling server", closeTimeout, func(ctx context.Context) error {
+		signalingServer.Close()
+		return nil
+	})
+	if err := rpcServer.RegisterServiceServer(
+		ctx,
+		&webrtcpb.SignalingService_ServiceDesc,
+		signalingServer,
+		webrtcpb.RegisterSignalingServiceHandlerFromEndpoint,
+	); err != nil {
+		return multierr.Combine(err, listener.Close())
+	}
+
+	if cfg.TLS.CertFile != "" {
+		err = rpcServer.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile, nil)
+	} else {
+		err = rpcServer.Serve(listener)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infow(
+		"serving",
+		"address", listener.Addr().String(),
+		"secure", cfg.TLS.CertFile != "",
+		"call_queue", callQueueKind,
+	)
+	utils.ContextMainReadyFunc(ctx)()
+	<-ctx.Done()
+	return nil
+}
+
+// authServerOptions returns the options that set up how the server authenticates. Anything
+// that must be stopped is added to the closer.
+func authServerOptions(
+	ctx context.Context,
+	cfg AuthConfig,
+	mongoClient *mongoutils.Client,
+	closer *utils.Closer,
+) ([]rpc.ServerOption, error) {
+	if cfg.Unauthenticated {
+		return []rpc.ServerOption{rpc.WithUnauthenticated()}, nil
+	}
+
+	var opts []rpc.ServerOption
+	if cfg.PrivateKeyFile != "" {
+		key, err := readPrivateKey(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, rpc.WithAuthPrivateKey(key))
+	}
+	if len(cfg.Audience) != 0 {
+		opts = append(opts, rpc.WithAuthAudience(cfg.Audience...))
+	}
+
+	switch {
+	case cfg.MongoDBAPIKeys:
+		opts = append(opts, rpc.WithAPIKeyAuthHandler(rpc.NewMongoDBAPIKeyStore(mongoClient.Client)))
+	case len(cfg.APIKeys) != 0:
+		keys := make([]rpc.APIKey, 0, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			keys = append(keys, rpc.APIKey{ID: key.ID, SecretHash: rpc.HashAPIKeySecret(key.Secret)})
+		}
+		opts = append(opts, rpc.WithAPIKeyAuthHandler(rpc.NewMemoryAPIKeyStore(keys...)))
+	}
+
+	if cfg.OIDC.Issuer != "" {
+		oidcOpt, stop, err := rpc.WithOIDCAuthHandler(ctx, rpc.OIDCAuthHandlerOptions{
+			Issuer:      cfg.OIDC.Issuer,
+			Audience:    cfg.OIDC.Audience,
+			JWKSURI:     cfg.OIDC.JWKSURI,
+			EntityClaim: cfg.OIDC.EntityClaim,
+		})
+		if err != nil {
+			return nil, err
+		}
+		closer.Add("oidc key refresher", closeTimeout, stop)
+		opts = append(opts, oidcOpt)
+	}
+	return opts, nil
+}
+
+// readPrivateKey reads a PEM encoded PKCS #8 private key.
+func readPrivateKey(path string) (crypto.Signer, error) {
+	//nolint:gosec
+	rd, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(rd)
+	if block == nil {
+		return nil, errors.Errorf("expected PEM encoded private key in %q", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("expected private key to be a crypto.Signer but got %T", key)
+	}
+	return signer, nil
+}
+
+// staticWebRTCConfigProvider hands out the same ICE servers to everyone.
+type staticWebRTCConfigProvider struct {
+	iceServers []*webrtcpb.ICEServer
+}
+
+func newStaticWebRTCConfigProvider(servers []ICEServerConfig) *staticWebRTCConfigProvider {
+	iceServers := make([]*webrtcpb.ICEServer, 0, len(servers))
+	for _, server := range servers {
+		iceServers = append(iceServers, &webrtcpb.ICEServer{
+			Urls:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
+	}
+	return &staticWebRTCConfigProvider{iceServers: iceServers}
+}
+
+func (p *staticWebRTCConfigProvider) Config(ctx context.Context) (rpc.WebRTCConfig, error) {
+	return rpc.WebRTCConfig{
+		ICEServers: p.iceServers,
+		// they never change so they may be cached for as long as the server runs.
+		Expires: time.Now().Add(24 * time.Hour),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+	"go.viam.com/utils/rpc/examples/echo/echotest"
+	"go.viam.com/utils/testutils"
+)
+
+func TestMainMain(t *testing.T) {
+	const host = "yeehaw"
+	listener := testutils.ReserveRandomListener(t)
+	signalingAddr := listener.Addr().String()
+	test.That(t, listener.Close(), test.ShouldBeNil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	test.That(t, os.WriteFile(configPath, []byte(fmt.Sprintf(`
+hosts: [%s]
+metrics:
+  enable: true
+auth:
+  api_keys:
+    - id: %[1]s
+      secret: shh
+`, host)), 0o600), test.ShouldBeNil)
+
+	testutils.TestMain(t, mainWithArgs, []testutils.MainTestCase{
+		{
+			Name: "no config",
+			Args: nil,
+			Err:  "required",
+		},
+		{
+			Name: "missing config",
+			Args: []string{filepath.Join(t.TempDir(), "missing.yaml")},
+			Err:  "missing.yaml",
+		},
+		{
+			Name: "serving",
+			Args: []string{configPath},
+			Before: func(t *testing.T, _ golog.Logger, _ *testutils.ContextualMainExecution) {
+				t.Setenv(EnvPrefix+"_BIND_ADDRESS", signalingAddr)
+			},
+			During: func(ctx context.Context, t *testing.T, exec *testutils.ContextualMainExecution) {
+				logger := golog.NewTestLogger(t)
+				creds := rpc.WithEntityCredentials(host, rpc.Credentials{Type: rpc.CredentialsTypeAPIKey, Payload: "shh"})
+
+				answerer, err := rpc.NewServer(
+					logger,
+					rpc.WithDisableMulticastDNS(),
+					rpc.WithUnauthenticated(),
+					rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
+						Enable:                    true,
+						ExternalSignalingAddress:  signalingAddr,
+						ExternalSignalingHosts:    []string{host},
+						ExternalSignalingDialOpts: []rpc.DialOption{rpc.WithInsecure(), creds},
+					}),
+				)
+				test.That(t, err, test.ShouldBeNil)
+				_, err = echotest.Register(ctx, answerer)
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, answerer.Start(), test.ShouldBeNil)
+				defer func() {
+					test.That(t, answerer.Stop(), test.ShouldBeNil)
+				}()
+
+				dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				defer cancel()
+				conn, err := rpc.DialWebRTC(dialCtx, signalingAddr, host, logger,
+					rpc.WithWebRTCOptions(rpc.DialWebRTCOptions{SignalingInsecure: true}),
+					creds,
+				)
+				test.That(t, err, test.ShouldBeNil)
+				echotest.AssertEcho(t, conn)
+				test.That(t, conn.Close(), test.ShouldBeNil)
+
+				//nolint:noctx
+				resp, err := http.Get(fmt.Sprintf("http://%s%s", signalingAddr, rpc.MetricsPath))
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, resp.Body.Close(), test.ShouldBeNil)
+				test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+			},
+		},
+	})
+}
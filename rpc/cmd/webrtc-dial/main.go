@@ -0,0 +1,313 @@
+// Package main dials a host the way clients of this module do, either over WebRTC or
+// directly over gRPC, and reports how the connection came together: every ICE candidate
+// gathered and received, the candidate pair that was selected, and how long each step of
+// negotiation took. Once connected, it can make echo calls to a host serving the
+// proto/rpc/examples/echo/v1 service to measure round trips over the connection.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	"go.viam.com/utils/rpc"
+)
+
+var logger = golog.NewDevelopmentLogger("webrtc-dial")
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logger)
+}
+
+// Arguments for the command.
+type Arguments struct {
+	Host              string `flag:"0,required,usage=host to dial"`
+	SignalingServer   string `flag:"signaling-server,usage=signaling server to dial through; defaults to the host"`
+	GRPC              bool   `flag:"grpc,usage=dial the host directly over gRPC instead of over WebRTC"`
+	Insecure          bool   `flag:"insecure,usage=dial the host or signaling server without TLS"`
+	Entity            string `flag:"entity,usage=entity to authenticate as; defaults to the host"`
+	APIKey            string `flag:"api-key,usage=API key to authenticate with"`
+	LANOnly           bool   `flag:"lan-only,usage=only connect over the local network"`
+	DisableTrickleICE bool   `flag:"disable-trickle-ice,usage=gather every candidate before making the offer"`
+	SDP               bool   `flag:"sdp,usage=print the offer and answer"`
+	Echo              string `flag:"echo,usage=message to echo once connected"`
+	Count             int    `flag:"count,default=1,usage=how many times to echo the message"`
+	Debug             bool   `flag:"debug"`
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger golog.Logger) (err error) {
+	var argsParsed Arguments
+	if err := utils.ParseFlags(args, &argsParsed); err != nil {
+		return err
+	}
+	if argsParsed.Count < 1 {
+		return errors.New("count must be at least 1")
+	}
+	if argsParsed.GRPC && argsParsed.SignalingServer != "" {
+		return errors.New("signaling-server cannot be used with grpc")
+	}
+
+	var dialOpts []rpc.DialOption
+	if argsParsed.Debug {
+		dialOpts = append(dialOpts, rpc.WithDialDebug())
+	}
+	if argsParsed.APIKey != "" {
+		creds := rpc.Credentials{Type: rpc.CredentialsTypeAPIKey, Payload: argsParsed.APIKey}
+		if argsParsed.Entity != "" {
+			dialOpts = append(dialOpts, rpc.WithEntityCredentials(argsParsed.Entity, creds))
+		} else {
+			dialOpts = append(dialOpts, rpc.WithCredentials(creds))
+		}
+	}
+
+	report := newDialReport(os.Stdout, argsParsed.SDP)
+	var conn rpc.ClientConn
+	if argsParsed.GRPC {
+		if argsParsed.Insecure {
+			dialOpts = append(dialOpts, rpc.WithInsecure())
+		}
+		report.printf("dialing %s directly", argsParsed.Host)
+		conn, err = rpc.DialDirectGRPC(ctx, argsParsed.Host, logger, dialOpts...)
+	} else {
+		signalingServer := argsParsed.SignalingServer
+		if signalingServer == "" {
+			signalingServer = argsParsed.Host
+		}
+		dialOpts = append(dialOpts, rpc.WithWebRTCOptions(rpc.DialWebRTCOptions{
+			SignalingInsecure:   argsParsed.Insecure,
+			LANOnly:             argsParsed.LANOnly,
+			DisableTrickleICE:   argsParsed.DisableTrickleICE,
+			OnLocalDescription:  report.onDescription,
+			OnRemoteDescription: report.onDescription,
+			OnPeerEvent:         report.onPeerEvent,
+			// falling back would hide why WebRTC failed to connect.
+			DisableWebSocketFallback: true,
+		}))
+		report.printf("dialing %s over WebRTC through %s", argsParsed.Host, signalingServer)
+		conn, err = rpc.DialWebRTC(ctx, signalingServer, argsParsed.Host, logger, dialOpts...)
+	}
+	if err != nil {
+		report.printf("failed to connect: %v", err)
+		return err
+	}
+	defer func() {
+		err = multierr.Combine(err, conn.Close())
+	}()
+	report.step(stepReady)
+	report.printSelectedCandidatePair()
+	report.printTimings()
+
+	if argsParsed.Echo == "" {
+		return nil
+	}
+	return echo(ctx, report, echopb.NewEchoServiceClient(conn), argsParsed.Echo, argsParsed.Count)
+}
+
+// echo echoes the message the given number of times and prints how long each round trip
+// took followed by a summary of them.
+func echo(ctx context.Context, report *dialReport, client echopb.EchoServiceClient, message string, count int) error {
+	var minRTT, maxRTT, totalRTT time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: message})
+		if err != nil {
+			return errors.Wrap(err, "error echoing")
+		}
+		rtt := time.Since(start)
+		if resp.Message != message {
+			return errors.Errorf("expected echo of %q but got %q", message, resp.Message)
+		}
+		report.printf("echo %d: %q in %s", i+1, resp.Message, rtt)
+
+		totalRTT += rtt
+		if i == 0 || rtt < minRTT {
+			minRTT = rtt
+		}
+		if rtt > maxRTT {
+			maxRTT = rtt
+		}
+	}
+	fmt.Fprintf(report.out, "\necho round trips: min=%s avg=%s max=%s\n", minRTT, totalRTT/time.Duration(count), maxRTT)
+	return nil
+}
+
+// A dialStep is a point in connecting that the timing breakdown is given in terms of.
+type dialStep string
+
+// The steps of connecting. Which happen, and in what order, depends on how the connection
+// is made.
+const (
+	stepGatheringStarted     dialStep = "gathering started"
+	stepOffer                dialStep = "offer"
+	stepFirstLocalCandidate  dialStep = "first local candidate"
+	stepAnswer               dialStep = "answer"
+	stepFirstRemoteCandidate dialStep = "first remote candidate"
+	stepConnected            dialStep = "peer connected"
+	stepReady                dialStep = "ready"
+)
+
+// A dialReport prints what happens while dialing as it happens, along with how long it has
+// been since dialing started. Peer events arrive from many goroutines so it is safe for
+// concurrent use.
+type dialReport struct {
+	out     io.Writer
+	start   time.Time
+	showSDP bool
+
+	mu               sync.Mutex
+	steps            map[dialStep]time.Duration
+	peerConn         *webrtc.PeerConnection
+	localCandidates  int
+	remoteCandidates int
+}
+
+func newDialReport(out io.Writer, showSDP bool) *dialReport {
+	return &dialReport{
+		out:     out,
+		start:   time.Now(),
+		showSDP: showSDP,
+		steps:   map[dialStep]time.Duration{},
+	}
+}
+
+func (r *dialReport) printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.printfLocked(format, args...)
+}
+
+func (r *dialReport) printfLocked(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "%12s  %s\n", time.Since(r.start).Round(10*time.Microsecond), fmt.Sprintf(format, args...))
+}
+
+// step records when the given step happened unless it already has.
+func (r *dialReport) step(step dialStep) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stepLocked(step)
+}
+
+func (r *dialReport) stepLocked(step dialStep) {
+	if _, ok := r.steps[step]; !ok {
+		r.steps[step] = time.Since(r.start)
+	}
+}
+
+// onDescription is the SDP hook for both local and remote descriptions. When dialing, the
+// offer is always local and the answer always remote.
+func (r *dialReport) onDescription(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch desc.Type {
+	case webrtc.SDPTypeOffer:
+		r.stepLocked(stepOffer)
+		r.printfLocked("offer created")
+	case webrtc.SDPTypeAnswer:
+		r.stepLocked(stepAnswer)
+		r.printfLocked("answer received")
+	case webrtc.SDPTypePranswer, webrtc.SDPTypeRollback:
+		r.printfLocked("%s description", desc.Type)
+	}
+	if r.showSDP {
+		fmt.Fprintf(r.out, "%s\n", indent(desc.SDP))
+	}
+	return desc, nil
+}
+
+func (r *dialReport) onPeerEvent(event rpc.PeerEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.peerConn == nil {
+		r.peerConn = event.PeerConn
+	}
+	switch event.Type {
+	case rpc.PeerEventGatheringStarted:
+		r.stepLocked(stepGatheringStarted)
+		r.printfLocked("gathering candidates")
+	case rpc.PeerEventCandidateAdded:
+		if event.Candidate == nil || event.Candidate.Candidate == "" {
+			return
+		}
+		if event.Remote {
+			r.remoteCandidates++
+			r.stepLocked(stepFirstRemoteCandidate)
+			r.printfLocked("remote candidate %s", event.Candidate.Candidate)
+		} else {
+			r.localCandidates++
+			r.stepLocked(stepFirstLocalCandidate)
+			r.printfLocked("local candidate  %s", event.Candidate.Candidate)
+		}
+	case rpc.PeerEventConnected:
+		r.stepLocked(stepConnected)
+		r.printfLocked("peer connected")
+	case rpc.PeerEventRenegotiated:
+		r.printfLocked("renegotiated")
+	case rpc.PeerEventDisconnected:
+		r.printfLocked("peer disconnected")
+	case rpc.PeerEventClosed:
+		r.printfLocked("peer closed")
+	}
+}
+
+// printSelectedCandidatePair prints the candidate pair that ICE settled on along with its
+// round trip time, if connected over WebRTC.
+func (r *dialReport) printSelectedCandidatePair() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.peerConn == nil {
+		return
+	}
+	fmt.Fprintf(r.out, "\ncandidates: %d local, %d remote\n", r.localCandidates, r.remoteCandidates)
+	pair, err := r.peerConn.SCTP().Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		fmt.Fprintln(r.out, "selected pair: none")
+		return
+	}
+	fmt.Fprintf(r.out, "selected pair: %s\n", pair)
+	for _, stat := range r.peerConn.GetStats() {
+		pairStats, ok := stat.(webrtc.ICECandidatePairStats)
+		if !ok || !pairStats.Nominated || pairStats.State != webrtc.StatsICECandidatePairStateSucceeded {
+			continue
+		}
+		fmt.Fprintf(r.out, "selected pair round trip: %s\n",
+			time.Duration(pairStats.CurrentRoundTripTime*float64(time.Second)).Round(10*time.Microsecond))
+		break
+	}
+}
+
+// printTimings prints when each step that happened did, both since dialing started and
+// since the step before it.
+func (r *dialReport) printTimings() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	steps := make([]dialStep, 0, len(r.steps))
+	for step := range r.steps {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return r.steps[steps[i]] < r.steps[steps[j]]
+	})
+	fmt.Fprintln(r.out, "\ntimings:")
+	var last time.Duration
+	for _, step := range steps {
+		at := r.steps[step]
+		fmt.Fprintf(r.out, "  %-24s %12s  (+%s)\n", step, at.Round(10*time.Microsecond), (at - last).Round(10*time.Microsecond))
+		last = at
+	}
+}
+
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(strings.TrimRight(s, "\r\n"), "\n", "\n    ")
+}
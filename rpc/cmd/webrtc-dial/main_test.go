@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+	"go.viam.com/utils/rpc/examples/echo/echotest"
+	"go.viam.com/utils/testutils"
+)
+
+func TestMainMain(t *testing.T) {
+	const host = "yeehaw"
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := rpc.NewServer(
+		logger,
+		rpc.WithDisableMulticastDNS(),
+		rpc.WithUnauthenticated(),
+		rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{host},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echotest.Register(context.Background(), rpcServer)
+	test.That(t, err, test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.Serve(listener), test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+	addr := listener.Addr().String()
+
+	testutils.TestMain(t, mainWithArgs, []testutils.MainTestCase{
+		{
+			Name: "no host",
+			Err:  "required",
+		},
+		{
+			Name: "bad count",
+			Args: []string{host, "--count=0"},
+			Err:  "count must be at least 1",
+		},
+		{
+			Name: "signaling server with grpc",
+			Args: []string{addr, "--grpc", "--signaling-server=" + addr},
+			Err:  "cannot be used with grpc",
+		},
+		{
+			Name: "webrtc",
+			Args: []string{host, "--signaling-server=" + addr, "--insecure", "--sdp", "--echo=hello", "--count=3"},
+		},
+		{
+			Name: "grpc",
+			Args: []string{addr, "--grpc", "--insecure", "--echo=hello"},
+		},
+		{
+			Name: "unknown host",
+			Args: []string{"unknown", "--signaling-server=" + addr, "--insecure"},
+			Err:  "no signaler",
+		},
+	})
+}
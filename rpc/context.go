@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 
 	"github.com/pion/webrtc/v3"
@@ -15,6 +16,8 @@ const (
 	ctxKeyPeerConnection
 	ctxKeyAuthEntity
 	ctxKeyAuthClaims // all jwt claims
+	ctxKeyClientCertificate
+	ctxKeySignaledAuthEntity
 )
 
 // contextWithHost attaches a host name to the given context.
@@ -77,6 +80,20 @@ func ContextAuthEntity(ctx context.Context) (EntityInfo, bool) {
 	return authEntity, true
 }
 
+// contextWithSignaledAuthEntity attaches the entity of a call tunneled over WebRTC that
+// only approximates who authenticated to the signaler (see webrtcServerChannel.authAudience).
+// Since it was not verified, it is not used to authorize the call.
+func contextWithSignaledAuthEntity(ctx context.Context, authEntity EntityInfo) context.Context {
+	return context.WithValue(ContextWithAuthEntity(ctx, authEntity), ctxKeySignaledAuthEntity, true)
+}
+
+// contextHasSignaledAuthEntity returns whether the entity of the context was attached with
+// contextWithSignaledAuthEntity.
+func contextHasSignaledAuthEntity(ctx context.Context) bool {
+	signaled, _ := ctx.Value(ctxKeySignaledAuthEntity).(bool)
+	return signaled
+}
+
 // MustContextAuthEntity returns the entity associated with this authentication context;
 // it panics if there is none set.
 func MustContextAuthEntity(ctx context.Context) EntityInfo {
@@ -86,3 +103,50 @@ func MustContextAuthEntity(ctx context.Context) EntityInfo {
 	}
 	return authEntity
 }
+
+// contextWithClientCertificate attaches the verified client certificate a caller authenticated with.
+func contextWithClientCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, ctxKeyClientCertificate, cert)
+}
+
+// ContextClientCertificate returns the verified certificate the caller authenticated with
+// over mutual TLS, if any. Its subject and SANs identify the caller.
+func ContextClientCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(ctxKeyClientCertificate).(*x509.Certificate)
+	return cert, ok
+}
+
+// contextWithAuthClaims attaches the claims of an authenticated access token to the given context.
+func contextWithAuthClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ctxKeyAuthClaims, claims)
+}
+
+// ContextAuthClaims returns the claims of the access token the caller authenticated with, if any.
+// Interceptors can use its metadata to make authorization decisions.
+func ContextAuthClaims(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(ctxKeyAuthClaims).(Claims)
+	return claims, ok
+}
+
+// AuthClaims returns the claims of the access token the caller authenticated with as the
+// given type (e.g. AuthClaims[JWTClaims](ctx)), if any and if they are of that type.
+func AuthClaims[T Claims](ctx context.Context) (T, bool) {
+	claims, ok := ctx.Value(ctxKeyAuthClaims).(T)
+	return claims, ok
+}
+
+// MustAuthClaims returns the claims of the access token the caller authenticated with as
+// the given type; it panics if there are none or they are of another type.
+func MustAuthClaims[T Claims](ctx context.Context) T {
+	claims, ok := AuthClaims[T](ctx)
+	if !ok {
+		panic(errors.New("no auth claims present"))
+	}
+	return claims
+}
+
+// MustAuthEntity returns the name of the entity associated with this authentication
+// context; it panics if there is none set.
+func MustAuthEntity(ctx context.Context) string {
+	return MustContextAuthEntity(ctx).Entity
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/pion/webrtc/v3"
 	"go.viam.com/test"
 )
@@ -38,3 +39,25 @@ func TestContextPeerConnection(t *testing.T) {
 	test.That(t, ok, test.ShouldBeTrue)
 	test.That(t, pc2, test.ShouldEqual, &pc)
 }
+
+func TestContextAuthClaims(t *testing.T) {
+	_, ok := AuthClaims[JWTClaims](context.Background())
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, func() { MustAuthClaims[JWTClaims](context.Background()) }, test.ShouldPanic)
+	test.That(t, func() { MustAuthEntity(context.Background()) }, test.ShouldPanic)
+
+	claims := JWTClaims{
+		RegisteredClaims:    jwt.RegisteredClaims{Subject: "foo"},
+		AuthCredentialsType: CredentialsTypeAPIKey,
+	}
+	ctx := contextWithAuthClaims(ContextWithAuthEntity(context.Background(), EntityInfo{Entity: "foo"}), claims)
+	typedClaims, ok := AuthClaims[JWTClaims](ctx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, typedClaims, test.ShouldResemble, claims)
+	test.That(t, MustAuthClaims[JWTClaims](ctx).CredentialsType(), test.ShouldEqual, CredentialsTypeAPIKey)
+	test.That(t, MustAuthEntity(ctx), test.ShouldEqual, "foo")
+
+	// claims of another type are not returned
+	_, ok = AuthClaims[*JWTClaims](ctx)
+	test.That(t, ok, test.ShouldBeFalse)
+}
@@ -14,10 +14,14 @@ import (
 	"github.com/edaniels/zeroconf"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+
+	"go.viam.com/utils"
 )
 
 // Dial attempts to make the most convenient connection to the given address. It attempts to connect
 // via WebRTC if a signaling server is detected or provided. Otherwise it attempts to connect directly.
+// Addresses like unix:///path/to.sock are always connected to directly, and insecurely, over a UNIX
+// domain socket.
 // TODO(GOUT-7): figure out decent way to handle reconnect on connection termination.
 func Dial(ctx context.Context, address string, logger golog.Logger, opts ...DialOption) (ClientConn, error) {
 	var dOpts dialOptions
@@ -112,7 +116,7 @@ func dial(
 
 	var isJustDomain bool
 	switch {
-	case strings.HasPrefix(address, "unix://"):
+	case utils.IsUnixAddress(address):
 		dOpts.mdnsOptions.Disable = true
 		dOpts.webrtcOpts.Disable = true
 		dOpts.insecure = true
@@ -193,7 +197,7 @@ func dial(
 				fmt.Sprintf("%s->%s", dOpts.webrtcOpts.SignalingServerAddress, originalAddress),
 				dOpts.cacheKey(),
 				func() (ClientConn, error) {
-					return dialWebRTC(
+					return dialWebRTCOrWebSocketTunnel(
 						ctxParallel,
 						dOpts.webrtcOpts.SignalingServerAddress,
 						originalAddress,
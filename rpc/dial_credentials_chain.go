@@ -0,0 +1,239 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/edaniels/golog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// ChainedCredentials are one way of authenticating tried by WithCredentialsChain. Exactly
+// one of Credentials or AuthMaterial may be set; if neither is, no access token is sent
+// and the connection relies on its TLS client certificate (see WithClientCertificate).
+type ChainedCredentials struct {
+	// Entity and Credentials are used to authenticate like with WithEntityCredentials.
+	Entity      string
+	Credentials Credentials
+
+	// AuthMaterial is an already obtained access token used like with
+	// WithStaticAuthenticationMaterial.
+	AuthMaterial string
+}
+
+// WithCredentialsChain returns a DialOption which tries each of the given ways of
+// authenticating in order, moving on to the next whenever the server rejects calls as
+// unauthenticated. Unary calls that are rejected are retried with the next credentials.
+// Which credentials succeeded is remembered for later connections to the same address
+// with the same chain. The chain is used for direct gRPC connections and is mutually
+// exclusive with the other auth options.
+func WithCredentialsChain(chain ...ChainedCredentials) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.authEntity = ""
+		o.creds = Credentials{}
+		o.authMaterial = ""
+		o.credsChain = chain
+	})
+}
+
+// credentialsChainSuccesses remembers which credentials of a chain, keyed by the address
+// and chain, last succeeded.
+var credentialsChainSuccesses = struct {
+	mu      sync.Mutex
+	indexes map[string]int
+}{indexes: map[string]int{}}
+
+// chainedPerRPCCredentials sends the access token of the current credentials in a chain.
+type chainedPerRPCCredentials struct {
+	rememberKey string
+	links       []credentials.PerRPCCredentials // nil when relying on TLS
+	jwtCreds    []*perRPCJWTCredentials
+	logger      golog.Logger
+
+	mu      sync.Mutex
+	current int
+}
+
+func newChainedPerRPCCredentials(
+	rememberKey string,
+	chain []ChainedCredentials,
+	debug bool,
+	logger golog.Logger,
+) *chainedPerRPCCredentials {
+	chained := &chainedPerRPCCredentials{
+		rememberKey: rememberKey,
+		links:       make([]credentials.PerRPCCredentials, len(chain)),
+		logger:      logger,
+	}
+	for idx, link := range chain {
+		switch {
+		case link.Credentials.Type != "":
+			jwtCreds := &perRPCJWTCredentials{
+				entity: link.Entity,
+				creds:  link.Credentials,
+				debug:  debug,
+				logger: logger,
+			}
+			chained.jwtCreds = append(chained.jwtCreds, jwtCreds)
+			chained.links[idx] = jwtCreds
+		case link.AuthMaterial != "":
+			chained.links[idx] = &staticPerRPCJWTCredentials{link.AuthMaterial}
+		}
+	}
+
+	credentialsChainSuccesses.mu.Lock()
+	if idx, ok := credentialsChainSuccesses.indexes[rememberKey]; ok && idx < len(chain) {
+		chained.current = idx
+	}
+	credentialsChainSuccesses.mu.Unlock()
+	return chained
+}
+
+// setConn sets the connection that credentials authenticate over.
+func (creds *chainedPerRPCCredentials) setConn(conn ClientConn) {
+	for _, jwtCreds := range creds.jwtCreds {
+		jwtCreds.conn = conn
+	}
+}
+
+func (creds *chainedPerRPCCredentials) currentLink() (int, credentials.PerRPCCredentials) {
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	return creds.current, creds.links[creds.current]
+}
+
+// advance moves on from the given credentials to the next ones in the chain, returning
+// false if there are none left. If the chain already moved on, it returns true.
+func (creds *chainedPerRPCCredentials) advance(from int) bool {
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	if creds.current != from {
+		return true
+	}
+	if creds.current+1 >= len(creds.links) {
+		return false
+	}
+	creds.current++
+	creds.logger.Debugw("credentials rejected; trying next in chain", "index", creds.current)
+	return true
+}
+
+// succeeded remembers that the given credentials were accepted.
+func (creds *chainedPerRPCCredentials) succeeded(idx int) {
+	credentialsChainSuccesses.mu.Lock()
+	defer credentialsChainSuccesses.mu.Unlock()
+	credentialsChainSuccesses.indexes[creds.rememberKey] = idx
+}
+
+func (creds *chainedPerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	for {
+		idx, link := creds.currentLink()
+		if link == nil {
+			//nolint:nilnil
+			return nil, nil
+		}
+		md, err := link.GetRequestMetadata(ctx, uri...)
+		if err == nil {
+			return md, nil
+		}
+		if !isCredentialsRejection(err) || !creds.advance(idx) {
+			return nil, err
+		}
+	}
+}
+
+func (creds *chainedPerRPCCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (creds *chainedPerRPCCredentials) close() {
+	for _, jwtCreds := range creds.jwtCreds {
+		jwtCreds.close()
+	}
+}
+
+func isCredentialsRejection(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	default:
+		return false
+	}
+}
+
+func isAuthServiceMethod(method string) bool {
+	return strings.HasPrefix(method, "/proto.rpc.v1.AuthService/")
+}
+
+// unaryInterceptor retries calls rejected as unauthenticated with the next credentials.
+func (creds *chainedPerRPCCredentials) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	if isAuthServiceMethod(method) {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	for {
+		idx, _ := creds.currentLink()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			if err == nil {
+				creds.succeeded(idx)
+			}
+			return err
+		}
+		if !creds.advance(idx) {
+			return err
+		}
+	}
+}
+
+// streamInterceptor moves on to the next credentials when a stream is rejected as
+// unauthenticated. Streams are not retried since messages may already have been sent.
+func (creds *chainedPerRPCCredentials) streamInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	if isAuthServiceMethod(method) {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	idx, _ := creds.currentLink()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			creds.advance(idx)
+		}
+		return nil, err
+	}
+	return &chainedCredentialsClientStream{ClientStream: stream, creds: creds, idx: idx}, nil
+}
+
+type chainedCredentialsClientStream struct {
+	grpc.ClientStream
+	creds *chainedPerRPCCredentials
+	idx   int
+	once  sync.Once
+}
+
+func (s *chainedCredentialsClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.once.Do(func() { s.creds.succeeded(s.idx) })
+	case status.Code(err) == codes.Unauthenticated:
+		s.creds.advance(s.idx)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+func TestDialCredentialsChain(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	chain := []ChainedCredentials{
+		{AuthMaterial: "not-a-token"},
+		{Entity: "foo", Credentials: Credentials{Type: CredentialsTypeAPIKey, Payload: "wrong"}},
+		{Entity: "foo", Credentials: Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}},
+	}
+	dialChain := func(chain ...ChainedCredentials) ClientConn {
+		conn, err := Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithCredentialsChain(chain...),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		return conn
+	}
+
+	conn := dialChain(chain...)
+	client := pb.NewEchoServiceClient(conn)
+	resp, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.Message, test.ShouldEqual, "hello")
+	stream, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hi"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = stream.Recv()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	// the credentials that succeeded are remembered for reconnects
+	var remembered []int
+	credentialsChainSuccesses.mu.Lock()
+	for key, idx := range credentialsChainSuccesses.indexes {
+		if strings.HasPrefix(key, httpListener.Addr().String()) {
+			remembered = append(remembered, idx)
+		}
+	}
+	credentialsChainSuccesses.mu.Unlock()
+	test.That(t, remembered, test.ShouldResemble, []int{2})
+	conn = dialChain(chain...)
+	_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	// fails once no credentials are left
+	conn = dialChain(chain[:2]...)
+	_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, isCredentialsRejection(err), test.ShouldBeTrue)
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"crypto/tls"
+	"net"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
@@ -17,6 +18,10 @@ type dialOptions struct {
 	// tlsConfig is the TLS config to use for any secured connections.
 	tlsConfig *tls.Config
 
+	// clientCert is presented to servers that ask for a client certificate
+	// on any secured connections.
+	clientCert *tls.Certificate
+
 	// allowInsecureDowngrade determines if it is acceptable to downgrade
 	// an insecure connection if detected. This is only used when credentials
 	// are not present.
@@ -34,6 +39,9 @@ type dialOptions struct {
 	// credentials.
 	creds Credentials
 
+	// credsChain are tried in order to authenticate the request. See WithCredentialsChain.
+	credsChain []ChainedCredentials
+
 	// webrtcOpts control how WebRTC is utilized in a dial attempt.
 	webrtcOpts    DialWebRTCOptions
 	webrtcOptsSet bool
@@ -64,6 +72,15 @@ type dialOptions struct {
 	// interceptors
 	unaryInterceptor  grpc.UnaryClientInterceptor
 	streamInterceptor grpc.StreamClientInterceptor
+
+	// retryPolicy, if set, retries failed unary calls. See WithRetryPolicy.
+	retryPolicy *RetryPolicy
+
+	// webrtcPeerOpts control how the WebRTC peer connection is set up.
+	webrtcPeerOpts webrtcPeerOptions
+
+	// proxy, if set, determines which proxy, if any, connections are made through. See WithProxy.
+	proxy proxyFunc
 }
 
 // DialMulticastDNSOptions dictate any special settings to apply while dialing via mDNS.
@@ -117,6 +134,7 @@ func WithCredentials(creds Credentials) DialOption {
 	return newFuncDialOption(func(o *dialOptions) {
 		o.authEntity = ""
 		o.creds = creds
+		o.credsChain = nil
 	})
 }
 
@@ -127,6 +145,7 @@ func WithEntityCredentials(entity string, creds Credentials) DialOption {
 	return newFuncDialOption(func(o *dialOptions) {
 		o.authEntity = entity
 		o.creds = creds
+		o.credsChain = nil
 	})
 }
 
@@ -164,6 +183,7 @@ func WithStaticAuthenticationMaterial(authMaterial string) DialOption {
 	return newFuncDialOption(func(o *dialOptions) {
 		o.authEntity = ""
 		o.creds = Credentials{}
+		o.credsChain = nil
 		o.authMaterial = authMaterial
 	})
 }
@@ -186,6 +206,15 @@ func WithTLSConfig(config *tls.Config) DialOption {
 	})
 }
 
+// WithClientCertificate returns a DialOption which presents the given certificate and key pair
+// to servers asking for a client certificate (see WithRequiredClientCertificates) on any
+// secured connections. Use tls.LoadX509KeyPair to load one from files.
+func WithClientCertificate(cert tls.Certificate) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.clientCert = &cert
+	})
+}
+
 // WithWebRTCOptions returns a DialOption which sets the WebRTC options
 // to use if the dialer tries to establish a WebRTC connection.
 func WithWebRTCOptions(webrtcOpts DialWebRTCOptions) DialOption {
@@ -280,3 +309,31 @@ func WithForceDirectGRPC() DialOption {
 		o.disableDirect = false
 	})
 }
+
+// WithInterfaceFilter returns a DialOption which restricts which network interfaces
+// (e.g. to exclude docker0 or VPN tunnels) may be used to gather ICE candidates
+// when connecting via WebRTC. The filter should return true for interfaces that
+// are allowed.
+func WithInterfaceFilter(filter func(name string) bool) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.webrtcPeerOpts.interfaceFilter = filter
+	})
+}
+
+// WithICETimeouts returns a DialOption which tunes the ICE timeouts used when
+// connecting via WebRTC. Zero fields use their defaults.
+func WithICETimeouts(timeouts ICETimeouts) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.webrtcPeerOpts.iceTimeouts = timeouts
+	})
+}
+
+// WithIPFilter returns a DialOption which restricts which IPs (e.g. to exclude
+// certain subnets) may be used to gather ICE candidates when connecting via WebRTC.
+// The filter should return true for IPs that are allowed. Note that only IPv4
+// addresses are ever considered.
+func WithIPFilter(filter func(ip net.IP) bool) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.webrtcPeerOpts.ipFilter = filter
+	})
+}
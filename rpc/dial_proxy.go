@@ -0,0 +1,224 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+
+	"go.viam.com/utils"
+)
+
+// A proxyFunc returns the URL of the proxy to connect to the given address (host:port)
+// through. A nil URL means the address is connected to directly.
+type proxyFunc func(address string) (*url.URL, error)
+
+// WithProxy returns a DialOption which connects through the proxy at the given URL.
+// SOCKS5 (socks5:// or socks5h://) and HTTP CONNECT (http:// or https://) proxies are
+// supported, authenticating with the URL's user info, if any. The proxy is used for gRPC
+// connections, including those to signaling servers and external auth services, for
+// WebSocket tunnels, and for gathering TURN relay candidates over TCP or TLS when
+// connecting via WebRTC. Since a proxy can only relay TCP, other ICE candidates are
+// gathered as usual.
+func WithProxy(proxyURL *url.URL) DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.proxy = func(address string) (*url.URL, error) {
+			return proxyURL, nil
+		}
+	})
+}
+
+// WithProxyFromEnvironment returns a DialOption which connects through the proxy named by
+// the HTTPS_PROXY environment variable, or otherwise ALL_PROXY (or their lowercase
+// versions), except for addresses matched by NO_PROXY. Connections to localhost are never
+// proxied. See WithProxy for what is connected through the proxy.
+func WithProxyFromEnvironment() DialOption {
+	return newFuncDialOption(func(o *dialOptions) {
+		o.proxy = proxyFromEnvironment
+	})
+}
+
+func proxyFromEnvironment(address string) (*url.URL, error) {
+	config := httpproxy.FromEnvironment()
+	if config.HTTPSProxy == "" {
+		config.HTTPSProxy = os.Getenv("ALL_PROXY")
+		if config.HTTPSProxy == "" {
+			config.HTTPSProxy = os.Getenv("all_proxy")
+		}
+	}
+	return config.ProxyFunc()(&url.URL{Scheme: "https", Host: address})
+}
+
+// dialContext connects to the address over TCP through the proxy it should be reached
+// through, if any.
+func (pf proxyFunc) dialContext(ctx context.Context, address string) (net.Conn, error) {
+	var proxyURL *url.URL
+	if pf != nil {
+		var err error
+		proxyURL, err = pf(address)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if proxyURL == nil {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+	return dialProxy(ctx, proxyURL, address)
+}
+
+// Dial implements proxy.Dialer so that TURN servers may be reached through the proxy
+// while gathering ICE candidates. Only TCP networks are supported.
+func (pf proxyFunc) Dial(network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.Errorf("cannot connect over %q through a proxy", network)
+	}
+	return pf.dialContext(context.Background(), address)
+}
+
+var _ = proxy.Dialer(proxyFunc(nil))
+
+// dialProxy connects to the address through the proxy at the given URL.
+func dialProxy(ctx context.Context, proxyURL *url.URL, address string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.SOCKS5("tcp", proxyHostPort(proxyURL, "1080"), auth, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to %s through SOCKS5 proxy", address)
+		}
+		return conn, nil
+	case "http", "https":
+		return dialHTTPConnect(ctx, proxyURL, auth, address)
+	default:
+		return nil, errors.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// proxyHostPort returns the host:port of the proxy at the given URL.
+func proxyHostPort(proxyURL *url.URL, defaultPort string) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), defaultPort)
+}
+
+// dialHTTPConnect connects to the address through an HTTP proxy by asking it to CONNECT
+// to the address.
+func dialHTTPConnect(ctx context.Context, proxyURL *url.URL, auth *proxy.Auth, address string) (net.Conn, error) {
+	defaultPort := "80"
+	if proxyURL.Scheme == "https" {
+		defaultPort = "443"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyHostPort(proxyURL, defaultPort))
+	if err != nil {
+		return nil, err
+	}
+	var successful bool
+	defer func() {
+		if !successful {
+			utils.UncheckedError(conn.Close())
+		}
+	}()
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: proxyURL.Hostname(),
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// the CONNECT request is abandoned once the context's deadline passes.
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if auth != nil {
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.User + ":" + auth.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, errors.Wrap(err, "failed to send CONNECT to proxy")
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CONNECT response from proxy")
+	}
+	utils.UncheckedError(resp.Body.Close())
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("proxy refused to connect to %s: %s", address, resp.Status)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	successful = true
+	if reader.Buffered() != 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// A bufferedConn is a connection whose first bytes were already read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *bufferedConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
+
+// dialTLS connects to the address over TLS through the proxy it should be reached
+// through, if any.
+func (pf proxyFunc) dialTLS(ctx context.Context, address string, tlsConfig *tls.Config) (net.Conn, error) {
+	conn, err := pf.dialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, multierr.Combine(err, conn.Close())
+		}
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, multierr.Combine(err, conn.Close())
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,310 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+// pipeConns copies between the two connections until either is done and then closes both.
+func pipeConns(conn1, conn2 net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyConn := func(dst, src net.Conn) {
+		defer wg.Done()
+		//nolint:errcheck
+		io.Copy(dst, src)
+		//nolint:errcheck
+		dst.Close()
+		//nolint:errcheck
+		src.Close()
+	}
+	go copyConn(conn1, conn2)
+	go copyConn(conn2, conn1)
+	wg.Wait()
+}
+
+// newTestHTTPConnectProxy returns an HTTP CONNECT proxy which requires the given
+// Proxy-Authorization, if any, along with how many connections it has relayed.
+func newTestHTTPConnectProxy(t *testing.T, proxyAuth string) (*url.URL, *int32) {
+	t.Helper()
+	var connections int32
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodConnect {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if proxyAuth != "" && r.Header.Get("Proxy-Authorization") != proxyAuth {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+			targetConn, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			clientConn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				//nolint:errcheck
+				targetConn.Close()
+				return
+			}
+			atomic.AddInt32(&connections, 1)
+			pipeConns(clientConn, targetConn)
+		}),
+	}
+	go func() {
+		//nolint:errcheck
+		httpServer.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		test.That(t, httpServer.Close(), test.ShouldBeNil)
+	})
+	return &url.URL{Scheme: "http", Host: listener.Addr().String()}, &connections
+}
+
+// newTestSOCKS5Proxy returns a SOCKS5 proxy without authentication along with how many
+// connections it has relayed.
+func newTestSOCKS5Proxy(t *testing.T) (*url.URL, *int32) {
+	t.Helper()
+	var connections int32
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	serveConn := func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		greeting := make([]byte, 2)
+		if _, err := io.ReadFull(reader, greeting); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(reader, make([]byte, greeting[1])); err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return
+		}
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(reader, request); err != nil {
+			return
+		}
+		var host string
+		switch request[3] {
+		case 1:
+			addr := make([]byte, net.IPv4len)
+			if _, err := io.ReadFull(reader, addr); err != nil {
+				return
+			}
+			host = net.IP(addr).String()
+		case 3:
+			addrLen, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			addr := make([]byte, addrLen)
+			if _, err := io.ReadFull(reader, addr); err != nil {
+				return
+			}
+			host = string(addr)
+		default:
+			return
+		}
+		port := make([]byte, 2)
+		if _, err := io.ReadFull(reader, port); err != nil {
+			return
+		}
+		targetConn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		if err != nil {
+			//nolint:errcheck
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			//nolint:errcheck
+			targetConn.Close()
+			return
+		}
+		atomic.AddInt32(&connections, 1)
+		pipeConns(&bufferedConn{Conn: conn, reader: reader}, targetConn)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					//nolint:errcheck
+					conn.Close()
+				}()
+				serveConn(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		test.That(t, listener.Close(), test.ShouldBeNil)
+	})
+	return &url.URL{Scheme: "socks5", Host: listener.Addr().String()}, &connections
+}
+
+func TestDialWithProxy(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(logger, WithUnauthenticated(), WithDisableMulticastDNS())
+	test.That(t, err, test.ShouldBeNil)
+	es := echoserver.Server{}
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	dialEcho := func(t *testing.T, proxyURL *url.URL) error {
+		t.Helper()
+		conn, err := Dial(context.Background(), listener.Addr().String(), logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithDialMulticastDNSOptions(DialMulticastDNSOptions{Disable: true}),
+			WithProxy(proxyURL),
+		)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		resp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		if err != nil {
+			return err
+		}
+		test.That(t, resp.GetMessage(), test.ShouldEqual, "hello")
+		return nil
+	}
+
+	t.Run("HTTP CONNECT", func(t *testing.T) {
+		proxyURL, connections := newTestHTTPConnectProxy(t, "")
+		test.That(t, dialEcho(t, proxyURL), test.ShouldBeNil)
+		test.That(t, atomic.LoadInt32(connections), test.ShouldBeGreaterThan, 0)
+	})
+
+	t.Run("HTTP CONNECT with authentication", func(t *testing.T) {
+		proxyURL, connections := newTestHTTPConnectProxy(t, "Basic dXNlcjpwYXNz")
+		proxyURL.User = url.UserPassword("user", "pass")
+		test.That(t, dialEcho(t, proxyURL), test.ShouldBeNil)
+		test.That(t, atomic.LoadInt32(connections), test.ShouldBeGreaterThan, 0)
+
+		_, err := proxyFunc(func(address string) (*url.URL, error) {
+			return &url.URL{Scheme: "http", Host: proxyURL.Host}, nil
+		}).dialContext(context.Background(), listener.Addr().String())
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "407")
+	})
+
+	t.Run("SOCKS5", func(t *testing.T) {
+		proxyURL, connections := newTestSOCKS5Proxy(t)
+		test.That(t, dialEcho(t, proxyURL), test.ShouldBeNil)
+		test.That(t, atomic.LoadInt32(connections), test.ShouldBeGreaterThan, 0)
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := proxyFunc(func(address string) (*url.URL, error) {
+			return &url.URL{Scheme: "ftp", Host: "localhost:21"}, nil
+		}).dialContext(context.Background(), listener.Addr().String())
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported proxy scheme")
+	})
+}
+
+func TestProxyFuncDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, listener.Close(), test.ShouldBeNil)
+	}()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		//nolint:errcheck
+		conn.Write([]byte("hello"))
+		//nolint:errcheck
+		conn.Close()
+	}()
+
+	proxyURL, connections := newTestSOCKS5Proxy(t)
+	dialer := proxyFunc(func(address string) (*url.URL, error) {
+		return proxyURL, nil
+	})
+
+	_, err = dialer.Dial("udp", listener.Addr().String())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "udp")
+
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+	rd, err := io.ReadAll(conn)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(rd), test.ShouldEqual, "hello")
+	test.That(t, atomic.LoadInt32(connections), test.ShouldEqual, 1)
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+
+	proxyURL, err := proxyFromEnvironment("example.com:443")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL, test.ShouldBeNil)
+
+	t.Setenv("ALL_PROXY", "socks5://allproxy:1080")
+	proxyURL, err = proxyFromEnvironment("example.com:443")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL.String(), test.ShouldEqual, "socks5://allproxy:1080")
+
+	t.Setenv("HTTPS_PROXY", "http://httpsproxy:3128")
+	proxyURL, err = proxyFromEnvironment("example.com:443")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL.String(), test.ShouldEqual, "http://httpsproxy:3128")
+
+	// localhost is never proxied.
+	proxyURL, err = proxyFromEnvironment("localhost:8080")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL, test.ShouldBeNil)
+
+	t.Setenv("NO_PROXY", "example.com")
+	proxyURL, err = proxyFromEnvironment("example.com:443")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL, test.ShouldBeNil)
+	proxyURL, err = proxyFromEnvironment("other.example.org:443")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, proxyURL.String(), test.ShouldEqual, "http://httpsproxy:3128")
+}
@@ -24,6 +24,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/multierr"
 	"go.viam.com/test"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
@@ -1537,6 +1538,119 @@ func TestDialMutualTLSAuth(t *testing.T) {
 	}
 }
 
+func TestDialRequiredClientCertificates(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	cert, _, _, certPool, err := testutils.GenerateSelfSignedCertificate("somename")
+	test.That(t, err, test.ShouldBeNil)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	test.That(t, err, test.ShouldBeNil)
+
+	var certMu sync.Mutex
+	var seenCert *x509.Certificate
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithInternalTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}),
+		WithRequiredClientCertificates(certPool),
+		WithUnaryServerInterceptor(func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			clientCert, ok := ContextClientCertificate(ctx)
+			if ok {
+				certMu.Lock()
+				seenCert = clientCert
+				certMu.Unlock()
+			}
+			return handler(ctx, req)
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	echoServer := &echoserver.Server{
+		MustContextAuthEntity: func(ctx context.Context) echoserver.RPCEntityInfo {
+			ent := MustContextAuthEntity(ctx)
+			return echoserver.RPCEntityInfo{
+				Entity: ent.Entity,
+				Data:   ent.Data,
+			}
+		},
+	}
+	echoServer.SetExpectedAuthEntity(leaf.Issuer.String() + ":" + leaf.SerialNumber.String())
+	echoServer.SetAuthorized(true)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		echoServer,
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+	test.That(t, rpcServer.Start(), test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	clientTLSConfig := &tls.Config{
+		RootCAs:    certPool,
+		ServerName: "somename",
+		MinVersion: tls.VersionTLS12,
+	}
+
+	t.Run("with a client certificate", func(t *testing.T) {
+		conn, err := Dial(
+			context.Background(),
+			rpcServer.InternalAddr().String(),
+			logger,
+			WithTLSConfig(clientTLSConfig),
+			WithClientCertificate(cert),
+			WithForceDirectGRPC(),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+
+		echoResp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+
+		certMu.Lock()
+		defer certMu.Unlock()
+		test.That(t, seenCert, test.ShouldNotBeNil)
+		test.That(t, seenCert.Subject.CommonName, test.ShouldEqual, "somename")
+		test.That(t, seenCert.DNSNames, test.ShouldResemble, leaf.DNSNames)
+	})
+
+	t.Run("without a client certificate", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := Dial(
+			ctx,
+			rpcServer.InternalAddr().String(),
+			logger,
+			WithTLSConfig(clientTLSConfig),
+			WithForceDirectGRPC(),
+		)
+		if err == nil {
+			// depending on the TLS version, the handshake may only fail once used.
+			_, err = pb.NewEchoServiceClient(conn).Echo(ctx, &pb.EchoRequest{Message: "hello"})
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("cannot be unauthenticated", func(t *testing.T) {
+		_, err := NewServer(logger, WithUnauthenticated(), WithRequiredClientCertificates(certPool))
+		test.That(t, err, test.ShouldEqual, errMixedUnauthAndAuth)
+	})
+}
+
 func TestDialForceDirect(t *testing.T) {
 	logger := golog.NewTestLogger(t)
 	rpcServer, err := NewServer(
@@ -6,10 +6,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash/fnv"
+	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
 	"github.com/pkg/errors"
@@ -68,6 +71,13 @@ type ClientConnAuthenticator interface {
 	Authenticate(ctx context.Context) (string, error)
 }
 
+// A ClientConnRenegotiator supports renegotiating the session of a WebRTC based
+// connection, such as after adding tracks or data channels to it.
+type ClientConnRenegotiator interface {
+	ClientConn
+	Renegotiate(ctx context.Context) error
+}
+
 type cachedDialer struct {
 	mu    sync.Mutex // Note(erd): not suitable for highly concurrent usage
 	conns map[string]*refCountedConnWrapper
@@ -234,12 +244,22 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 		if tlsConfig == nil {
 			tlsConfig = newDefaultTLSConfig()
 		}
+		if dOpts.clientCert != nil {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.Certificates = append(tlsConfig.Certificates, *dOpts.clientCert)
+		}
 
 		var downgrade bool
 		if dOpts.allowInsecureDowngrade || dOpts.allowInsecureWithCredsDowngrade {
-			var dialer tls.Dialer
-			dialer.Config = tlsConfig
-			conn, err := dialer.DialContext(ctx, "tcp", address)
+			var conn net.Conn
+			var err error
+			if dOpts.proxy == nil {
+				var dialer tls.Dialer
+				dialer.Config = tlsConfig
+				conn, err = dialer.DialContext(ctx, "tcp", address)
+			} else {
+				conn, err = dOpts.proxy.dialTLS(ctx, address, tlsConfig)
+			}
 			if err == nil {
 				// will use TLS
 				utils.UncheckedError(conn.Close())
@@ -264,6 +284,9 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 	if dOpts.statsHandler != nil {
 		dialOpts = append(dialOpts, grpc.WithStatsHandler(dOpts.statsHandler))
 	}
+	if dOpts.proxy != nil && !utils.IsUnixAddress(address) {
+		dialOpts = append(dialOpts, grpc.WithContextDialer(dOpts.proxy.dialContext))
+	}
 
 	grpcLogger := logger.Desugar()
 	if !(dOpts.debug || utils.Debug) {
@@ -272,6 +295,9 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 	var unaryInterceptors []grpc.UnaryClientInterceptor
 	unaryInterceptors = append(unaryInterceptors, grpc_zap.UnaryClientInterceptor(grpcLogger))
 	unaryInterceptors = append(unaryInterceptors, UnaryClientTracingInterceptor())
+	if dOpts.retryPolicy != nil {
+		unaryInterceptors = append(unaryInterceptors, newRetrier(*dOpts.retryPolicy).unaryClientInterceptor())
+	}
 	if dOpts.unaryInterceptor != nil {
 		unaryInterceptors = append(unaryInterceptors, dOpts.unaryInterceptor)
 	}
@@ -290,9 +316,22 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 	var connPtr *ClientConn
 	var closeCredsFunc func() error
 	var rpcCreds *perRPCJWTCredentials
+	var chainedCreds *chainedPerRPCCredentials
 
 	if dOpts.authMaterial != "" {
 		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(&staticPerRPCJWTCredentials{dOpts.authMaterial}))
+	} else if len(dOpts.credsChain) != 0 {
+		chainedCreds = newChainedPerRPCCredentials(address+dOpts.cacheKey(), dOpts.credsChain, dOpts.debug, logger)
+		closeCredsFunc = func() error {
+			chainedCreds.close()
+			return nil
+		}
+		dialOpts = append(dialOpts,
+			grpc.WithPerRPCCredentials(chainedCreds),
+			// these run after the other interceptors so that each retry picks up the current credentials.
+			grpc.WithChainUnaryInterceptor(chainedCreds.unaryInterceptor),
+			grpc.WithChainStreamInterceptor(chainedCreds.streamInterceptor),
+		)
 	} else if dOpts.creds.Type != "" || dOpts.externalAuthMaterial != "" {
 		rpcCreds = &perRPCJWTCredentials{
 			entity: dOpts.authEntity,
@@ -319,6 +358,14 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 		} else {
 			connPtr = &rpcCreds.conn
 		}
+		closeExternalConn := closeCredsFunc
+		closeCredsFunc = func() error {
+			rpcCreds.close()
+			if closeExternalConn == nil {
+				return nil
+			}
+			return closeExternalConn()
+		}
 		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(rpcCreds))
 	}
 
@@ -342,6 +389,9 @@ func dialDirectGRPC(ctx context.Context, address string, dOpts dialOptions, logg
 	if connPtr != nil {
 		*connPtr = conn
 	}
+	if chainedCreds != nil {
+		chainedCreds.setConn(conn)
+	}
 	if rpcCreds != nil {
 		conn = clientConnRPCAuthenticator{conn, rpcCreds}
 	}
@@ -363,6 +413,7 @@ func dialExternalAuthEntity(ctx context.Context, logger golog.Logger, dOpts dial
 	dOpts.insecure = dOpts.externalAuthInsecure
 	dOpts.externalAuthMaterial = ""
 	dOpts.creds = Credentials{}
+	dOpts.credsChain = nil
 	dOpts.authEntity = ""
 
 	// reset the tls config that is used for the external Auth Service.
@@ -390,6 +441,12 @@ func (dOpts dialOptions) cacheKey() string {
 	if dOpts.creds.Payload != "" {
 		hasher.Write([]byte(dOpts.creds.Payload))
 	}
+	for _, chained := range dOpts.credsChain {
+		hasher.Write([]byte(chained.Entity))
+		hasher.Write([]byte(chained.Credentials.Type))
+		hasher.Write([]byte(chained.Credentials.Payload))
+		hasher.Write([]byte(chained.AuthMaterial))
+	}
 	if dOpts.externalAuthAddr != "" {
 		hasher.Write([]byte(dOpts.externalAuthAddr))
 	}
@@ -402,6 +459,9 @@ func (dOpts dialOptions) cacheKey() string {
 	if dOpts.webrtcOpts.SignalingServerAddress != "" {
 		hasher.Write([]byte(dOpts.webrtcOpts.SignalingServerAddress))
 	}
+	for _, addr := range dOpts.webrtcOpts.AdditionalSignalingServerAddresses {
+		hasher.Write([]byte(addr))
+	}
 	if dOpts.webrtcOpts.SignalingExternalAuthAddress != "" {
 		hasher.Write([]byte(dOpts.webrtcOpts.SignalingExternalAuthAddress))
 	}
@@ -417,6 +477,11 @@ func (dOpts dialOptions) cacheKey() string {
 	if dOpts.webrtcOpts.SignalingCreds.Payload != "" {
 		hasher.Write([]byte(dOpts.webrtcOpts.SignalingCreds.Payload))
 	}
+	if dOpts.clientCert != nil {
+		for _, certDER := range dOpts.clientCert.Certificate {
+			hasher.Write(certDER)
+		}
+	}
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
@@ -485,6 +550,14 @@ func (creds *staticPerRPCJWTCredentials) RequireTransportSecurity() bool {
 	return false
 }
 
+// tokenRefreshWindow is how long before an access token expires that it is refreshed
+// in the background. Tokens with a shorter lifetime are refreshed halfway through it.
+var tokenRefreshWindow = time.Minute
+
+// tokenExpiryLeeway is how close to expiring an access token may be for it to still be
+// sent instead of re-authenticating first.
+var tokenExpiryLeeway = 5 * time.Second
+
 type perRPCJWTCredentials struct {
 	mu                   sync.RWMutex
 	conn                 ClientConn
@@ -495,11 +568,15 @@ type perRPCJWTCredentials struct {
 	// The static external auth material used against the AuthenticateTo request to obtain final accessToken
 	externalAuthMaterial string
 
+	// expiresAt is when accessToken expires; zero if it does not or it cannot be refreshed.
+	expiresAt    time.Time
+	refreshTimer *time.Timer
+	closed       bool
+
 	debug  bool
 	logger golog.Logger
 }
 
-// TODO(GOUT-10): handle expiration.
 func (creds *perRPCJWTCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
 	for _, uriVal := range uri {
 		if strings.HasSuffix(uriVal, "/proto.rpc.v1.AuthService") {
@@ -515,73 +592,164 @@ func (creds *perRPCJWTCredentials) GetRequestMetadata(ctx context.Context, uri .
 	return map[string]string{"Authorization": "Bearer " + accessToken}, nil
 }
 
+// accessTokenValid returns whether the current access token can still be used. It must be
+// called with mu held.
+func (creds *perRPCJWTCredentials) accessTokenValid() bool {
+	if creds.accessToken == "" {
+		return false
+	}
+	return creds.expiresAt.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(creds.expiresAt)
+}
+
 func (creds *perRPCJWTCredentials) authenticate(ctx context.Context) (string, error) {
 	creds.mu.RLock()
 	accessToken := creds.accessToken
+	valid := creds.accessTokenValid()
 	creds.mu.RUnlock()
-	if accessToken == "" {
-		creds.mu.Lock()
-		defer creds.mu.Unlock()
-		accessToken = creds.accessToken
-		if accessToken == "" {
-			// skip authenticate call when a static access token for the external auth is used.
-			if creds.externalAuthMaterial == "" {
-				if creds.debug {
-					creds.logger.Debugw("authenticating as entity", "entity", creds.entity)
-				}
-				authClient := rpcpb.NewAuthServiceClient(creds.conn)
-
-				// Check external auth creds...
-				resp, err := authClient.Authenticate(ctx, &rpcpb.AuthenticateRequest{
-					Entity: creds.entity,
-					Credentials: &rpcpb.Credentials{
-						Type:    string(creds.creds.Type),
-						Payload: creds.creds.Payload,
-					},
-				})
-				if err != nil {
-					return "", err
-				}
-				accessToken = resp.AccessToken
-			} else {
-				accessToken = creds.externalAuthMaterial
-			}
+	if valid {
+		return accessToken, nil
+	}
 
-			// now perform external auth
-			if creds.externalAuthToEntity == "" {
-				if creds.debug {
-					creds.logger.Debug("not external auth for an entity; done")
-				}
-				creds.accessToken = accessToken
-			} else {
-				if creds.debug {
-					creds.logger.Debugw("authenticating to external entity", "entity", creds.externalAuthToEntity)
-				}
-				// now perform external auth
-				md := make(metadata.MD)
-				bearer := fmt.Sprintf("Bearer %s", accessToken)
-				md.Set("authorization", bearer)
-				externalCtx := metadata.NewOutgoingContext(ctx, md)
-
-				externalAuthClient := rpcpb.NewExternalAuthServiceClient(creds.conn)
-				externalResp, err := externalAuthClient.AuthenticateTo(externalCtx, &rpcpb.AuthenticateToRequest{
-					Entity: creds.externalAuthToEntity,
-				})
-				if err != nil {
-					return "", err
-				}
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	if creds.accessTokenValid() {
+		return creds.accessToken, nil
+	}
+	accessToken, err := creds.fetchAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	creds.setAccessToken(accessToken)
+	return accessToken, nil
+}
 
-				if creds.debug {
-					creds.logger.Debugw("external auth done", "auth_to", creds.externalAuthToEntity)
-				}
+// fetchAccessToken gets a new access token by authenticating and then, if needed,
+// authenticating to the external entity.
+func (creds *perRPCJWTCredentials) fetchAccessToken(ctx context.Context) (string, error) {
+	var accessToken string
+	// skip authenticate call when a static access token for the external auth is used.
+	if creds.externalAuthMaterial == "" {
+		if creds.debug {
+			creds.logger.Debugw("authenticating as entity", "entity", creds.entity)
+		}
+		authClient := rpcpb.NewAuthServiceClient(creds.conn)
+
+		// Check external auth creds...
+		resp, err := authClient.Authenticate(ctx, &rpcpb.AuthenticateRequest{
+			Entity: creds.entity,
+			Credentials: &rpcpb.Credentials{
+				Type:    string(creds.creds.Type),
+				Payload: creds.creds.Payload,
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		accessToken = resp.AccessToken
+	} else {
+		accessToken = creds.externalAuthMaterial
+	}
 
-				accessToken = externalResp.AccessToken
-				creds.accessToken = externalResp.AccessToken
-			}
+	// now perform external auth
+	if creds.externalAuthToEntity == "" {
+		if creds.debug {
+			creds.logger.Debug("not external auth for an entity; done")
 		}
+		return accessToken, nil
+	}
+	if creds.debug {
+		creds.logger.Debugw("authenticating to external entity", "entity", creds.externalAuthToEntity)
 	}
+	md := make(metadata.MD)
+	bearer := fmt.Sprintf("Bearer %s", accessToken)
+	md.Set("authorization", bearer)
+	externalCtx := metadata.NewOutgoingContext(ctx, md)
 
-	return accessToken, nil
+	externalAuthClient := rpcpb.NewExternalAuthServiceClient(creds.conn)
+	externalResp, err := externalAuthClient.AuthenticateTo(externalCtx, &rpcpb.AuthenticateToRequest{
+		Entity: creds.externalAuthToEntity,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if creds.debug {
+		creds.logger.Debugw("external auth done", "auth_to", creds.externalAuthToEntity)
+	}
+	return externalResp.AccessToken, nil
+}
+
+// setAccessToken stores the given access token and schedules it to be refreshed before
+// it expires. It must be called with mu held.
+func (creds *perRPCJWTCredentials) setAccessToken(accessToken string) {
+	creds.accessToken = accessToken
+	creds.expiresAt = time.Time{}
+	if creds.refreshTimer != nil {
+		creds.refreshTimer.Stop()
+		creds.refreshTimer = nil
+	}
+	// a static token cannot be refreshed by authenticating again.
+	if creds.closed || (creds.externalAuthMaterial != "" && creds.externalAuthToEntity == "") {
+		return
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, &claims); err != nil || claims.ExpiresAt == nil {
+		return
+	}
+	creds.expiresAt = claims.ExpiresAt.Time
+
+	untilExpiry := time.Until(creds.expiresAt)
+	refreshIn := untilExpiry - tokenRefreshWindow
+	if refreshIn < untilExpiry/2 {
+		refreshIn = untilExpiry / 2
+	}
+	if creds.debug {
+		creds.logger.Debugw("will refresh access token", "expires_at", creds.expiresAt, "refresh_in", refreshIn)
+	}
+	creds.refreshTimer = time.AfterFunc(refreshIn, creds.refresh)
+}
+
+// refresh re-authenticates in the background and swaps in the new access token. Until
+// then, the current token continues to be used. If refreshing fails, re-authenticating
+// is tried again on the next RPC once the current token is about to expire.
+func (creds *perRPCJWTCredentials) refresh() {
+	creds.mu.RLock()
+	expiresAt := creds.expiresAt
+	closed := creds.closed
+	creds.mu.RUnlock()
+	if closed {
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), expiresAt)
+	defer cancel()
+	accessToken, err := creds.fetchAccessToken(ctx)
+	if err != nil {
+		creds.logger.Warnw("failed to refresh access token", "error", err)
+		return
+	}
+
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	if creds.closed {
+		return
+	}
+	if creds.debug {
+		creds.logger.Debug("refreshed access token")
+	}
+	creds.setAccessToken(accessToken)
+}
+
+// close stops any background refreshing.
+func (creds *perRPCJWTCredentials) close() {
+	creds.mu.Lock()
+	defer creds.mu.Unlock()
+	creds.closed = true
+	if creds.refreshTimer != nil {
+		creds.refreshTimer.Stop()
+		creds.refreshTimer = nil
+	}
 }
 
 func (creds *perRPCJWTCredentials) RequireTransportSecurity() bool {
@@ -2,10 +2,14 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
 	"go.viam.com/test"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -13,7 +17,9 @@ import (
 	"google.golang.org/grpc/status"
 
 	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
 	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
 )
 
 func TestCachedDialer(t *testing.T) {
@@ -542,3 +548,145 @@ func TestWithStreamInterceptor(t *testing.T) {
 	)
 	test.That(t, interceptedCount, test.ShouldEqual, 1)
 }
+
+type expiringAuthServer struct {
+	rpcpb.UnimplementedAuthServiceServer
+	lifetime time.Duration
+
+	mu    sync.Mutex
+	count int
+}
+
+func (svc *expiringAuthServer) Authenticate(
+	ctx context.Context,
+	req *rpcpb.AuthenticateRequest,
+) (*rpcpb.AuthenticateResponse, error) {
+	svc.mu.Lock()
+	svc.count++
+	count := svc.count
+	svc.mu.Unlock()
+
+	claims := jwt.RegisteredClaims{
+		Subject: req.Entity,
+		ID:      fmt.Sprint(count),
+	}
+	if svc.lifetime != 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(svc.lifetime))
+	}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.AuthenticateResponse{AccessToken: tokenString}, nil
+}
+
+func (svc *expiringAuthServer) authCount() int {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	return svc.count
+}
+
+func TestPerRPCJWTCredentialsRefresh(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	prevRefreshWindow, prevExpiryLeeway := tokenRefreshWindow, tokenExpiryLeeway
+	tokenRefreshWindow = 2 * time.Second
+	tokenExpiryLeeway = 500 * time.Millisecond
+	defer func() {
+		tokenRefreshWindow, tokenExpiryLeeway = prevRefreshWindow, prevExpiryLeeway
+	}()
+
+	setup := func(t *testing.T, lifetime time.Duration) (*expiringAuthServer, *perRPCJWTCredentials) {
+		t.Helper()
+		authServer := &expiringAuthServer{lifetime: lifetime}
+		grpcServer := grpc.NewServer()
+		rpcpb.RegisterAuthServiceServer(grpcServer, authServer)
+
+		listener, err := net.Listen("tcp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		serveDone := make(chan error)
+		go func() {
+			serveDone <- grpcServer.Serve(listener)
+		}()
+
+		conn, err := grpc.DialContext(
+			context.Background(),
+			listener.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		test.That(t, err, test.ShouldBeNil)
+
+		creds := &perRPCJWTCredentials{
+			conn:   conn,
+			entity: "foo",
+			creds:  Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"},
+			logger: logger,
+		}
+		t.Cleanup(func() {
+			creds.close()
+			test.That(t, conn.Close(), test.ShouldBeNil)
+			grpcServer.Stop()
+			test.That(t, <-serveDone, test.ShouldBeNil)
+		})
+		return authServer, creds
+	}
+
+	bearerToken := func(t *testing.T, creds *perRPCJWTCredentials) string {
+		t.Helper()
+		md, err := creds.GetRequestMetadata(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		return md["Authorization"]
+	}
+
+	t.Run("refreshes in the background before expiring", func(t *testing.T) {
+		authServer, creds := setup(t, 4*time.Second)
+
+		token := bearerToken(t, creds)
+		test.That(t, token, test.ShouldStartWith, "Bearer ")
+		test.That(t, authServer.authCount(), test.ShouldEqual, 1)
+
+		// the same token is used until it is refreshed
+		test.That(t, bearerToken(t, creds), test.ShouldEqual, token)
+		test.That(t, authServer.authCount(), test.ShouldEqual, 1)
+
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, authServer.authCount(), test.ShouldEqual, 2)
+		})
+		refreshedToken := bearerToken(t, creds)
+		test.That(t, refreshedToken, test.ShouldNotEqual, token)
+		test.That(t, authServer.authCount(), test.ShouldEqual, 2)
+
+		creds.close()
+		creds.mu.RLock()
+		test.That(t, creds.refreshTimer, test.ShouldBeNil)
+		creds.mu.RUnlock()
+	})
+
+	t.Run("re-authenticates when about to expire", func(t *testing.T) {
+		authServer, creds := setup(t, time.Hour)
+
+		token := bearerToken(t, creds)
+		test.That(t, authServer.authCount(), test.ShouldEqual, 1)
+
+		creds.mu.Lock()
+		creds.expiresAt = time.Now().Add(tokenExpiryLeeway / 2)
+		creds.mu.Unlock()
+
+		test.That(t, bearerToken(t, creds), test.ShouldNotEqual, token)
+		test.That(t, authServer.authCount(), test.ShouldEqual, 2)
+	})
+
+	t.Run("tokens without expiry are not refreshed", func(t *testing.T) {
+		authServer, creds := setup(t, 0)
+
+		token := bearerToken(t, creds)
+		test.That(t, bearerToken(t, creds), test.ShouldEqual, token)
+		test.That(t, authServer.authCount(), test.ShouldEqual, 1)
+
+		creds.mu.RLock()
+		test.That(t, creds.expiresAt.IsZero(), test.ShouldBeTrue)
+		test.That(t, creds.refreshTimer, test.ShouldBeNil)
+		creds.mu.RUnlock()
+	})
+}
@@ -5,6 +5,12 @@ In a server context, this package should be preferred over gRPC directly
 since it provides higher level configuration with more features built in,
 such as grpc-web, gRPC via RESTful JSON, and gRPC via WebRTC.
 
+grpc-web and RESTful JSON (see https://github.com/grpc-ecosystem/grpc-gateway) are served
+alongside native gRPC by the server's http.Handler, so browsers can call services without
+WebRTC. Both go through the same authentication as any other call. Services add themselves
+to the gateway when registered; WithGatewayHandlers adds any other routes and WithCORS
+configures which origins may make requests.
+
 WebRTC services gRPC over DataChannels. The work was initially adapted from
 https://github.com/jsmouret/grpc-over-webrtc.
 
@@ -36,6 +42,15 @@ a DataChannel is established that we use to tunnel over gRPC method calls
 Using this is a powerful means of connection because it exposes ContextPeerConnection which makes it possible
 to use gRPC methods to modify the Video/Audio part of the connection.
 
+# Tracing
+
+Clients send the OpenCensus span in their context with each call, both as a W3C Trace Context (traceparent
+and tracestate), injected with the OpenTelemetry propagator, and as the older trace-id, span-id, and
+trace-options metadata, and servers continue the trace from it. This lets peers tracing with OpenTelemetry
+join traces started here and the other way around. Over WebRTC this metadata travels in the headers of each
+stream, so traces span both transports alike. Dialing over WebRTC also records spans for the signaling
+exchange, ICE connectivity, and renegotiations.
+
 Multicast DNS (mDNS)
 
 By default, a server will broadcast its ability to be connected to over gRPC/WebRTC over mDNS. When a dial
@@ -64,7 +79,17 @@ intercepts all calls and ensures that there is a JWT present in the header and i
 An optionally supplied TokenVerificationKeyProvider associated with the credential type can be used to provide
 a key used to verify the JWT if it was not signed by the RPC service provider. Once verified an optionally supplied
 EntityDataLoader associated with the credential type can use the JWT metadata to produce application to produce
-data for the entity to be accessible via rpc.MustContextAuthEntity.
+data for the entity to be accessible via rpc.MustContextAuthEntity. The verified claims are accessible via
+rpc.AuthClaims (e.g. rpc.AuthClaims[rpc.JWTClaims](ctx)).
+
+Access tokens are signed with the server's auth private key (see WithAuthPrivateKey). WithAuthKeyRotation
+replaces that key with a newly generated one on a schedule, continuing to accept tokens signed by a retired key
+for an overlap period, and WithAuthKeysEndpoint publishes the public keys at AuthKeysPath as a JWKS so that
+other services can verify tokens issued by the server.
+
+Attempts to authenticate can be limited per entity and per peer address with WithAuthRateLimit, which
+also locks either out for a while after too many consecutive failures. Its state can be kept in a shared
+store (e.g. NewMongoDBAuthRateLimitStore) so that limits hold across replicas.
 
 Additionally, authentication via mutual TLS is supported by way of the WithTLSAuthHandler and
 WithInternalTLSConfig ServerOptions. Using these two options in tandem will ask clients connecting
@@ -74,7 +99,13 @@ client certificate match that of the entities checked in WithTLSAuthHandler, the
 allowed to proceed.
 
 For WebRTC, we assume that signaling is implemented as an authenticated/authorized service and for now,
-do not pass any JWTs over the WebRTC data channels that are established. For more info,
+do not require JWTs over the WebRTC data channels that are established. Calls that do send one in their
+metadata have it verified and their entity and claims populated, and are limited by auth scopes and
+authorization policies, just as with direct gRPC. A client dialed with credentials (e.g. WithEntityCredentials)
+authenticates to the host over the data channel and sends the resulting JWT along with its calls, so that
+rpc.MustAuthClaims works the same over WebRTC as over gRPC as long as the host accepts those credentials.
+Otherwise, the entity of a call is the host it was signaled for and it has no claims. Calls without one are denied when authorizing with
+WithAuthorizationDefaultDeny, since who authenticated to the signaler is not known. For more info,
 see https://github.com/viamrobotics/goutils/issues/12.
 
 There is an additional feature, called AuthenticateTo provided by the ExternalAuthService which allows
@@ -85,14 +116,21 @@ for that server, and then it will call AuthenticateTo to get a JWT destined for
 connected to. AuthenticateTo requires an entity to authenticate as. You can think of this feature as
 the ability to assume the role of another entity.
 
-Expiration of JWTs is not yet handled/support; see:
-- https://github.com/viamrobotics/goutils/issues/10
+When an access token carries an expiration (exp), the client tracks it and re-authenticates in the
+background shortly before it expires, swapping in the new token without dropping the connection.
+Issuing expiring tokens from the server is not yet handled/supported; see:
 - https://github.com/viamrobotics/goutils/issues/11
 - https://github.com/viamrobotics/goutils/issues/13
 
 # Authorization Modes
 
-Authorization is strictly not handled by this framework. It's up to your registered services/methods
-to handle authorization.
+Coarse, per-method authorization can be configured with WithAuthorizationPolicies, which maps
+authenticated entities, optionally narrowed by their auth metadata, to the method patterns they may
+call. By default, entities that no policy applies to are unrestricted; WithAuthorizationDefaultDeny
+denies them instead and WithAuthorizationDenialHook allows for auditing denials. Anything finer
+grained is up to your registered services/methods to handle.
+
+Calls can be audited with WithAuditLog, which records the method, authenticated entity, peer, latency, and
+status code of every call (including those that failed to authenticate) to one or more AuditSinks.
 */
 package rpc
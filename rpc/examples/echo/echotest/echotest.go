@@ -0,0 +1,151 @@
+// Package echotest registers the echo service of proto/rpc/examples/echo/v1 and checks that
+// every kind of call to it works, so that a server and dialer configuration, including
+// authentication and WebRTC, can be smoke-tested through whatever transport it uses.
+package echotest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	"go.viam.com/utils/rpc/examples/echo/server"
+)
+
+// A Registrar registers services along with their gateway handlers, such as an rpc.Server.
+type Registrar interface {
+	RegisterServiceServer(
+		ctx context.Context,
+		svcDesc *grpc.ServiceDesc,
+		svcServer interface{},
+		svcHandlers ...interface{},
+	) error
+}
+
+// Register registers a new echo server and its gateway handler. The server is returned so
+// that it can be made to fail or check authentication.
+func Register(ctx context.Context, registrar Registrar) (*server.Server, error) {
+	srv := &server.Server{}
+	if err := registrar.RegisterServiceServer(
+		ctx,
+		&echopb.EchoService_ServiceDesc,
+		srv,
+		echopb.RegisterEchoServiceHandlerFromEndpoint,
+	); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// Check makes a unary, server streaming, client streaming, and bidirectional streaming call
+// to the echo service over the connection and returns what went wrong with each.
+func Check(ctx context.Context, conn grpc.ClientConnInterface) error {
+	client := echopb.NewEchoServiceClient(conn)
+	return multierr.Combine(
+		errors.Wrap(checkUnary(ctx, client), "unary call failed"),
+		errors.Wrap(checkServerStream(ctx, client), "server streaming call failed"),
+		errors.Wrap(checkClientStream(ctx, client), "client streaming call failed"),
+		errors.Wrap(checkBiDiStream(ctx, client), "bidirectional streaming call failed"),
+	)
+}
+
+// AssertEcho asserts that every kind of call to the echo service over the connection works.
+func AssertEcho(tb testing.TB, conn grpc.ClientConnInterface) {
+	tb.Helper()
+	test.That(tb, Check(context.Background(), conn), test.ShouldBeNil)
+}
+
+func checkUnary(ctx context.Context, client echopb.EchoServiceClient) error {
+	resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: "hello"})
+	if err != nil {
+		return err
+	}
+	return expectMessage(resp.Message, "hello")
+}
+
+func checkServerStream(ctx context.Context, client echopb.EchoServiceClient) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.EchoMultiple(ctx, &echopb.EchoMultipleRequest{Message: "hello"})
+	if err != nil {
+		return err
+	}
+	for _, expected := range []string{"h", "e", "l", "l", "o"} {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := expectMessage(resp.Message, expected); err != nil {
+			return err
+		}
+	}
+	return expectEOF(stream.Recv())
+}
+
+func checkClientStream(ctx context.Context, client echopb.EchoServiceClient) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.EchoClientStream(ctx)
+	if err != nil {
+		return err
+	}
+	for _, message := range []string{"hel", "lo"} {
+		if err := stream.Send(&echopb.EchoClientStreamRequest{Message: message}); err != nil {
+			return err
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return expectMessage(resp.Message, "hello")
+}
+
+func checkBiDiStream(ctx context.Context, client echopb.EchoServiceClient) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.EchoBiDi(ctx)
+	if err != nil {
+		return err
+	}
+	for _, message := range []string{"hi", "yo"} {
+		if err := stream.Send(&echopb.EchoBiDiRequest{Message: message}); err != nil {
+			return err
+		}
+		for _, expected := range message {
+			resp, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			if err := expectMessage(resp.Message, string(expected)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	return expectEOF(stream.Recv())
+}
+
+func expectMessage(actual, expected string) error {
+	if actual != expected {
+		return errors.Errorf("expected message %q but got %q", expected, actual)
+	}
+	return nil
+}
+
+func expectEOF[T any](_ T, err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errors.New("expected end of stream but got another message")
+}
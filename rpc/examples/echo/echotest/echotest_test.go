@@ -0,0 +1,81 @@
+package echotest_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+	"go.viam.com/utils/rpc/examples/echo/echotest"
+)
+
+func TestEcho(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	listenerAddr := listener.Addr().String()
+
+	rpcServer, err := rpc.NewServer(
+		logger,
+		rpc.WithDisableMulticastDNS(),
+		rpc.WithAuthHandler(rpc.CredentialsTypeAPIKey, rpc.MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{listenerAddr},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	srv, err := echotest.Register(context.Background(), rpcServer)
+	test.That(t, err, test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	creds := rpc.WithEntityCredentials("foo", rpc.Credentials{Type: rpc.CredentialsTypeAPIKey, Payload: "bar"})
+	for _, tc := range []struct {
+		name string
+		opts []rpc.DialOption
+	}{
+		{"grpc", []rpc.DialOption{rpc.WithForceDirectGRPC()}},
+		{"webrtc", []rpc.DialOption{
+			rpc.WithDisableDirectGRPC(),
+			rpc.WithWebRTCOptions(rpc.DialWebRTCOptions{SignalingInsecure: true}),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := rpc.Dial(context.Background(), listenerAddr, logger, append([]rpc.DialOption{rpc.WithInsecure(), creds}, tc.opts...)...)
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+			echotest.AssertEcho(t, conn)
+
+			srv.SetFail(true)
+			defer srv.SetFail(false)
+			err = echotest.Check(context.Background(), conn)
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, "unary call failed")
+			test.That(t, err.Error(), test.ShouldContainSubstring, "whoops")
+			test.That(t, err.Error(), test.ShouldNotContainSubstring, "streaming call failed")
+		})
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		conn, err := rpc.Dial(context.Background(), listenerAddr, logger, rpc.WithInsecure(), rpc.WithForceDirectGRPC())
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		err = echotest.Check(context.Background(), conn)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "authentication required")
+	})
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
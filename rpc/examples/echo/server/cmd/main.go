@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/edaniels/golog"
@@ -40,6 +41,9 @@ var (
 	logger      = golog.Global().Named("server")
 )
 
+// shutdownTimeout is how long each of the servers is given to shut down.
+const shutdownTimeout = 10 * time.Second
+
 // Arguments for the command.
 type Arguments struct {
 	Port               utils.NetPortFlag `flag:"0"`
@@ -166,6 +170,8 @@ func runServer(
 		signalingOpts = append(signalingOpts, rpc.WithInsecure())
 	}
 	serverOpts = append(serverOpts, rpc.WithExternalListenerAddress(listener.Addr().(*net.TCPAddr)))
+	// allows for exploring the services with tools like grpcurl.
+	serverOpts = append(serverOpts, rpc.WithReflection())
 	serverOpts = append(serverOpts, rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
 		Enable:                    true,
 		ExternalSignalingDialOpts: signalingOpts,
@@ -220,9 +226,11 @@ func runServer(
 	if err != nil {
 		return err
 	}
+	closer := utils.NewCloser()
 	defer func() {
-		err = multierr.Combine(err, rpcServer.Stop())
+		err = multierr.Combine(err, closer.Close(context.Background()))
 	}()
+	closer.Add("rpc server", shutdownTimeout, utils.CloseWithoutContext(rpcServer.Stop))
 
 	if err := rpcServer.RegisterServiceServer(
 		ctx,
@@ -296,20 +304,15 @@ func runServer(
 	if err != nil {
 		return err
 	}
+	closer.Add("http server", shutdownTimeout, httpServer.Shutdown)
 
 	done := make(chan struct{})
 	defer func() { <-done }()
 	utils.PanicCapturingGo(func() {
 		defer close(done)
 		<-ctx.Done()
-		defer func() {
-			if err := rpcServer.Stop(); err != nil {
-				panic(err)
-			}
-		}()
-		if err := httpServer.Shutdown(ctx); err != nil && utils.FilterOutError(err, context.Canceled) != nil {
-			panic(err)
-		}
+		// the result is returned by the deferred close.
+		utils.UncheckedError(closer.Close(context.Background()))
 	})
 	utils.PanicCapturingGo(func() {
 		if err := rpcServer.Start(); err != nil {
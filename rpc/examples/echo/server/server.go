@@ -4,6 +4,7 @@ package server
 import (
 	"context"
 	"io"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -101,6 +102,21 @@ func (srv *Server) EchoMultiple(req *echopb.EchoMultipleRequest, server echopb.E
 	return nil
 }
 
+// EchoClientStream responds back with all the messages sent to it joined together.
+func (srv *Server) EchoClientStream(server echopb.EchoService_EchoClientStreamServer) error {
+	var message strings.Builder
+	for {
+		req, err := server.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return server.SendAndClose(&echopb.EchoClientStreamResponse{Message: message.String()})
+			}
+			return err
+		}
+		message.WriteString(req.Message)
+	}
+}
+
 // EchoBiDi responds back with the same message one character at a time for each message sent to it.
 func (srv *Server) EchoBiDi(server echopb.EchoService_EchoBiDiServer) error {
 	for {
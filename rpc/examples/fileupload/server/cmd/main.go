@@ -155,6 +155,8 @@ func runServer(
 		signalingOpts = append(signalingOpts, rpc.WithInsecure())
 	}
 	serverOpts = append(serverOpts, rpc.WithExternalListenerAddress(listener.Addr().(*net.TCPAddr)))
+	// allows for exploring the services with tools like grpcurl.
+	serverOpts = append(serverOpts, rpc.WithReflection())
 	serverOpts = append(serverOpts, rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
 		Enable:                    true,
 		ExternalSignalingDialOpts: signalingOpts,
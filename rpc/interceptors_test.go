@@ -4,11 +4,13 @@ import (
 	"context"
 	"io"
 	"net"
+	"sync"
 	"testing"
 
 	"github.com/edaniels/golog"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
 	"go.uber.org/atomic"
 	"go.viam.com/test"
 	"google.golang.org/grpc"
@@ -25,8 +27,14 @@ func TestTracingInterceptors(t *testing.T) {
 	testutils.SkipUnlessInternet(t)
 	logger := golog.NewTestLogger(t)
 
+	exporter := &spanRecorder{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	// only the client's trace is sampled so that the global tracing config is left alone;
+	// spans continuing it are sampled along with it.
 	var clientSpan *trace.Span
-	ctx, clientSpan := trace.StartSpan(context.Background(), "client")
+	ctx, clientSpan := trace.StartSpan(context.Background(), "client", trace.WithSampler(trace.AlwaysSample()))
 	defer clientSpan.End()
 
 	unaryServerTestingInterceptor := func(
@@ -182,4 +190,123 @@ func TestTracingInterceptors(t *testing.T) {
 	client = pb.NewEchoServiceClient(rtcConn)
 	unaryTest(ctx, client)
 	streamTest(ctx, client)
+
+	// WebRTC propagates the trace without any interceptors given
+	plainRTCConn, err := dialWebRTC(ctx, listener.Addr().String(), internalSignalingHost, dialOptions{
+		webrtcOpts: DialWebRTCOptions{
+			SignalingInsecure: true,
+		},
+		webrtcOptsSet: true,
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, plainRTCConn.Close(), test.ShouldBeNil)
+	}()
+
+	client = pb.NewEchoServiceClient(plainRTCConn)
+	unaryTest(ctx, client)
+	streamTest(ctx, client)
+
+	test.That(t, plainRTCConn.Renegotiate(ctx), test.ShouldBeNil)
+
+	clientSpans := exporter.spans(clientSpan.SpanContext().TraceID)
+	for _, name := range []string{"rpc.webrtc.dial", "rpc.webrtc.signaling", "rpc.webrtc.ice", "rpc.webrtc.renegotiate"} {
+		test.That(t, clientSpans, test.ShouldContainKey, name)
+		test.That(t, clientSpans[name].Status.Code, test.ShouldEqual, 0)
+	}
+	test.That(t, clientSpans["rpc.webrtc.signaling"].ParentSpanID, test.ShouldEqual, clientSpans["rpc.webrtc.dial"].SpanID)
+	test.That(t, clientSpans["rpc.webrtc.ice"].ParentSpanID, test.ShouldEqual, clientSpans["rpc.webrtc.dial"].SpanID)
+	test.That(t, clientSpans["rpc.webrtc.dial"].ParentSpanID, test.ShouldEqual, clientSpan.SpanContext().SpanID)
+}
+
+func TestTraceContext(t *testing.T) {
+	traceState, err := tracestate.New(nil, tracestate.Entry{Key: "vendor", Value: "value"})
+	test.That(t, err, test.ShouldBeNil)
+	spanContext := trace.SpanContext{
+		TraceID:      trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:       trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceOptions: 1,
+		Tracestate:   traceState,
+	}
+	md := injectTraceContext(context.Background(), spanContext)
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	test.That(t, md.Get("traceparent"), test.ShouldResemble, []string{traceparent})
+	test.That(t, md.Get("tracestate"), test.ShouldResemble, []string{"vendor=value"})
+
+	parsed, ok := extractTraceContext(context.Background(), md)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, parsed, test.ShouldResemble, spanContext)
+
+	// future versions may add fields
+	parsed, ok = extractTraceContext(context.Background(), metadata.Pairs(
+		"traceparent", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future",
+	))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, parsed.TraceOptions, test.ShouldEqual, trace.TraceOptions(0))
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, ok := extractTraceContext(context.Background(), metadata.Pairs("traceparent", invalid))
+		test.That(t, ok, test.ShouldBeFalse)
+	}
+
+	// the trace context is preferred over the older keys
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"trace-id", "00000000000000000000000000000001",
+		"span-id", "0000000000000001",
+		"trace-options", "0",
+		"traceparent", traceparent,
+		"tracestate", "vendor=value",
+	))
+	parsed, err = remoteSpanContextFromContext(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, parsed, test.ShouldResemble, spanContext)
+
+	// and the older keys are used when it is invalid.
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"trace-id", spanContext.TraceID.String(),
+		"span-id", spanContext.SpanID.String(),
+		"trace-options", "1",
+		"traceparent", "invalid",
+	))
+	parsed, err = remoteSpanContextFromContext(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	spanContext.Tracestate = nil
+	test.That(t, parsed, test.ShouldResemble, spanContext)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("trace-id", spanContext.TraceID.String()))
+	_, err = remoteSpanContextFromContext(ctx)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// spanRecorder is a trace.Exporter that records every span exported to it.
+type spanRecorder struct {
+	mu       sync.Mutex
+	exported []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exported = append(r.exported, s)
+}
+
+// spans returns the last span exported with each name in the trace.
+func (r *spanRecorder) spans(traceID trace.TraceID) map[string]*trace.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := map[string]*trace.SpanData{}
+	for _, s := range r.exported {
+		if s.TraceID == traceID {
+			spans[s.Name] = s
+		}
+	}
+	return spans
 }
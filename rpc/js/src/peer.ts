@@ -64,9 +64,15 @@ export async function newPeerConnectionForClient(
   };
   negotiationChannel.onmessage = async (event: MessageEvent<any>) => {
     try {
-      const description = new RTCSessionDescription(
-        JSON.parse(atob(event.data))
-      );
+      const message = JSON.parse(atob(event.data));
+      if (message.type === 'close') {
+        // the remote peer is telling us why it is closing the connection.
+        console.debug(
+          `peer connection closed by remote: ${message.message} (code=${message.code})`
+        );
+        return;
+      }
+      const description = new RTCSessionDescription(message);
 
       const offerCollision =
         description.type === 'offer' &&
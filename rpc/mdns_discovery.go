@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/edaniels/zeroconf"
+	"go.uber.org/zap"
+)
+
+// DefaultLocalDiscoveryTimeout is how long DiscoverLocalServers browses for when the
+// given context has no deadline.
+var DefaultLocalDiscoveryTimeout = 3 * time.Second
+
+// A LocalServer is an RPC server discovered on the local network via mDNS.
+type LocalServer struct {
+	// Names are the instance names the server advertises itself as.
+	Names []string
+
+	// Address is the IPv4 host:port the server can be reached at.
+	Address string
+
+	// HostName is the name of the machine the server is running on.
+	HostName string
+
+	// GRPC and WebRTC indicate which ways of connecting the server supports.
+	GRPC   bool
+	WebRTC bool
+}
+
+// DiscoverLocalServers browses the local network via mDNS for RPC servers that have not
+// disabled mDNS advertisement (see WithDisableMulticastDNS). Browsing continues until the
+// context is done or, if it has no deadline, for DefaultLocalDiscoveryTimeout. The servers
+// found are returned sorted by address; the context ending is not considered an error.
+func DiscoverLocalServers(ctx context.Context, logger golog.Logger) ([]LocalServer, error) {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, DefaultLocalDiscoveryTimeout)
+		defer cancel()
+	}
+
+	resolver, err := zeroconf.NewResolver(logger, zeroconf.SelectIPRecordType(zeroconf.IPv4))
+	if err != nil {
+		return nil, err
+	}
+	defer resolver.Shutdown()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, "_rpc._tcp", "local.", entries); err != nil {
+		return nil, err
+	}
+
+	var found []*zeroconf.ServiceEntry
+	for {
+		select {
+		case <-ctx.Done():
+			return localServersFromEntries(found), nil
+		case entry, ok := <-entries:
+			if !ok {
+				return localServersFromEntries(found), nil
+			}
+			if entry != nil {
+				found = append(found, entry)
+			}
+		}
+	}
+}
+
+// localServersFromEntries merges mDNS entries that refer to the same address into a
+// single server. Servers advertise each of their instance names separately.
+func localServersFromEntries(entries []*zeroconf.ServiceEntry) []LocalServer {
+	byAddress := map[string]*LocalServer{}
+	for _, entry := range entries {
+		// IPv6 with scope does not work with grpc-go which we would want here.
+		if len(entry.AddrIPv4) == 0 {
+			continue
+		}
+		address := fmt.Sprintf("%s:%d", entry.AddrIPv4[0], entry.Port)
+		server, ok := byAddress[address]
+		if !ok {
+			server = &LocalServer{Address: address, HostName: entry.HostName}
+			byAddress[address] = server
+		}
+		var hasName bool
+		for _, name := range server.Names {
+			if name == entry.Instance {
+				hasName = true
+				break
+			}
+		}
+		if !hasName {
+			server.Names = append(server.Names, entry.Instance)
+		}
+		for _, field := range entry.Text {
+			// mdns service may advertise TXT field following https://datatracker.ietf.org/doc/html/rfc1464 (ex grpc=)
+			if strings.Contains(field, "grpc") {
+				server.GRPC = true
+			}
+			if strings.Contains(field, "webrtc") {
+				server.WebRTC = true
+			}
+		}
+	}
+
+	servers := make([]LocalServer, 0, len(byAddress))
+	for _, server := range byAddress {
+		servers = append(servers, *server)
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Address < servers[j].Address
+	})
+	return servers
+}
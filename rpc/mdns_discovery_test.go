@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/edaniels/zeroconf"
+	"go.viam.com/test"
+)
+
+func TestLocalServersFromEntries(t *testing.T) {
+	newEntry := func(instance string, ip string, port int, text ...string) *zeroconf.ServiceEntry {
+		entry := zeroconf.NewServiceEntry(instance, "_rpc._tcp", "local.")
+		entry.HostName = "somehost.local."
+		entry.Port = port
+		entry.Text = text
+		if ip != "" {
+			entry.AddrIPv4 = []net.IP{net.ParseIP(ip)}
+		}
+		return entry
+	}
+
+	servers := localServersFromEntries([]*zeroconf.ServiceEntry{
+		newEntry("robot.local", "192.168.1.2", 8080, "grpc"),
+		newEntry("robot-local", "192.168.1.2", 8080, "grpc", "webrtc"),
+		newEntry("robot.local", "192.168.1.2", 8080, "grpc"),
+		newEntry("other", "192.168.1.1", 9090, "webrtc"),
+		newEntry("nowhere", "", 9090, "grpc"),
+	})
+	test.That(t, servers, test.ShouldResemble, []LocalServer{
+		{
+			Names:    []string{"other"},
+			Address:  "192.168.1.1:9090",
+			HostName: "somehost.local.",
+			WebRTC:   true,
+		},
+		{
+			Names:    []string{"robot.local", "robot-local"},
+			Address:  "192.168.1.2:8080",
+			HostName: "somehost.local.",
+			GRPC:     true,
+			WebRTC:   true,
+		},
+	})
+}
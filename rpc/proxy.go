@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"go.viam.com/utils"
+)
+
+// proxyStreamDesc describes every proxied call since whether either side streams only
+// matters to the endpoints.
+var proxyStreamDesc = &grpc.StreamDesc{
+	StreamName:    "proxy",
+	ClientStreams: true,
+	ServerStreams: true,
+}
+
+// NewProxyStreamHandler returns a stream handler that forwards every call it handles to
+// the given connection, which may be over any transport, such as one returned by Dial or
+// DialWebRTC. Use it with WithUnknownServiceHandler so that a server forwards all the
+// methods it does not serve itself, bridging, for example, gRPC over TCP to WebRTC or
+// vice versa.
+//
+// Messages are forwarded as is without knowing their types. The incoming metadata,
+// deadline, and cancellation carry over to the forwarded call and the response headers,
+// trailers, and status carry back. Authorization metadata is not forwarded since the
+// connection authenticates with its own credentials, if any.
+func NewProxyStreamHandler(conn ClientConn) grpc.StreamHandler {
+	return func(srv interface{}, serverStream grpc.ServerStream) error {
+		method, ok := grpc.MethodFromServerStream(serverStream)
+		if !ok {
+			return status.Error(codes.Internal, "failed to determine method of proxied call")
+		}
+
+		ctx, cancel := context.WithCancel(serverStream.Context())
+		defer cancel()
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = metadata.NewOutgoingContext(ctx, proxiedMetadata(md))
+		clientStream, err := conn.NewStream(ctx, proxyStreamDesc, method)
+		if err != nil {
+			return err
+		}
+
+		requestsDone := make(chan error, 1)
+		utils.PanicCapturingGo(func() {
+			requestsDone <- forwardProxiedRequests(serverStream, clientStream)
+		})
+		responsesDone := make(chan error, 1)
+		utils.PanicCapturingGo(func() {
+			responsesDone <- forwardProxiedResponses(clientStream, serverStream)
+		})
+
+		for {
+			select {
+			case err := <-requestsDone:
+				if !errors.Is(err, io.EOF) {
+					// the caller went away so the forwarded call is canceled.
+					return err
+				}
+				// the caller is done sending; keep forwarding responses.
+				if err := clientStream.CloseSend(); err != nil {
+					return err
+				}
+				requestsDone = nil
+			case err := <-responsesDone:
+				serverStream.SetTrailer(proxiedMetadata(clientStream.Trailer()))
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				// the status of the forwarded call, or why it could not be made.
+				return err
+			}
+		}
+	}
+}
+
+// proxiedMetadata returns the metadata of a call, or of its response, that is forwarded
+// by a proxy.
+func proxiedMetadata(md metadata.MD) metadata.MD {
+	proxied := make(metadata.MD, len(md))
+	for key, values := range md {
+		switch {
+		case strings.HasPrefix(key, ":"), strings.HasPrefix(key, "grpc-"):
+			// pseudo and reserved headers are set by the transport.
+			continue
+		case key == "content-type", key == "user-agent", key == "te", key == "authorization":
+			continue
+		}
+		proxied[key] = values
+	}
+	return proxied
+}
+
+// forwardProxiedRequests forwards the messages of the caller until either it is done
+// sending or the forwarded call ended, at which point it returns io.EOF.
+func forwardProxiedRequests(src grpc.ServerStream, dst grpc.ClientStream) error {
+	for {
+		// messages are forwarded as is by preserving them as unknown fields.
+		msg := &emptypb.Empty{}
+		if err := src.RecvMsg(msg); err != nil {
+			return err
+		}
+		if err := dst.SendMsg(msg); err != nil {
+			// on io.EOF, the forwarded call ended and its status is forwarded with its responses.
+			return err
+		}
+	}
+}
+
+// forwardProxiedResponses forwards the headers and messages of the forwarded call until
+// it ends, at which point it returns io.EOF or the status it ended with.
+func forwardProxiedResponses(src grpc.ClientStream, dst grpc.ServerStream) error {
+	headerSent := false
+	for {
+		msg := &emptypb.Empty{}
+		err := src.RecvMsg(msg)
+		if !headerSent {
+			// headers are only available once the first message or status is received.
+			if header, headerErr := src.Header(); headerErr == nil && header.Len() != 0 {
+				if err := dst.SendHeader(header); err != nil {
+					return err
+				}
+			}
+			headerSent = true
+		}
+		if err != nil {
+			return err
+		}
+		if err := dst.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
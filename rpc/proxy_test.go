@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+// startTestServer serves the given options on a random local port until the test ends
+// and returns its address.
+func startTestServer(t *testing.T, logger golog.Logger, register func(Server), opts ...ServerOption) string {
+	t.Helper()
+	rpcServer, err := NewServer(logger, append([]ServerOption{WithDisableMulticastDNS(), WithUnauthenticated()}, opts...)...)
+	test.That(t, err, test.ShouldBeNil)
+	if register != nil {
+		register(rpcServer)
+	}
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	})
+	return listener.Addr().String()
+}
+
+func TestProxyStreamHandler(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	echoServer := &echoserver.Server{}
+	registerEcho := func(rpcServer Server) {
+		test.That(t, rpcServer.RegisterServiceServer(context.Background(), &echopb.EchoService_ServiceDesc, echoServer), test.ShouldBeNil)
+	}
+
+	type received struct {
+		md          metadata.MD
+		hasDeadline bool
+	}
+	receivedCh := make(chan received, 1)
+	backendAddr := startTestServer(t, logger, registerEcho, WithUnaryServerInterceptor(func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		_, hasDeadline := ctx.Deadline()
+		select {
+		case receivedCh <- received{md, hasDeadline}:
+		default:
+		}
+		test.That(t, grpc.SetHeader(ctx, metadata.Pairs("x-header", "h")), test.ShouldBeNil)
+		test.That(t, grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "t")), test.ShouldBeNil)
+		return handler(ctx, req)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, echoServer)
+
+	backendConn, err := Dial(context.Background(), backendAddr, logger, WithInsecure(), WithForceDirectGRPC())
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, backendConn.Close(), test.ShouldBeNil)
+	}()
+
+	for _, tc := range []struct {
+		name    string
+		backend ClientConn
+	}{
+		{"grpc", backendConn},
+		{"webrtc", pair.Client()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			proxyAddr := startTestServer(t, logger, nil, WithUnknownServiceHandler(NewProxyStreamHandler(tc.backend)))
+			conn, err := Dial(context.Background(), proxyAddr, logger, WithInsecure(), WithForceDirectGRPC())
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+			client := echopb.NewEchoServiceClient(conn)
+
+			callCtx, callCancel := context.WithTimeout(context.Background(), time.Minute)
+			defer callCancel()
+			callCtx = metadata.AppendToOutgoingContext(callCtx, "x-request", "r", "authorization", "Bearer secret")
+			var header, trailer metadata.MD
+			resp, err := client.Echo(callCtx, &echopb.EchoRequest{Message: "hello"}, grpc.Header(&header), grpc.Trailer(&trailer))
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp.Message, test.ShouldEqual, "hello")
+			if tc.name == "grpc" {
+				got := <-receivedCh
+				test.That(t, got.md.Get("x-request"), test.ShouldResemble, []string{"r"})
+				test.That(t, got.md.Get("authorization"), test.ShouldBeEmpty)
+				test.That(t, got.hasDeadline, test.ShouldBeTrue)
+				test.That(t, header.Get("x-header"), test.ShouldResemble, []string{"h"})
+				test.That(t, trailer.Get("x-trailer"), test.ShouldResemble, []string{"t"})
+			}
+
+			multiClient, err := client.EchoMultiple(context.Background(), &echopb.EchoMultipleRequest{Message: "howdy"})
+			test.That(t, err, test.ShouldBeNil)
+			var multiReceived string
+			for {
+				resp, err := multiClient.Recv()
+				if err != nil {
+					test.That(t, err, test.ShouldEqual, io.EOF)
+					break
+				}
+				multiReceived += resp.Message
+			}
+			test.That(t, multiReceived, test.ShouldEqual, "howdy")
+
+			biDiClient, err := client.EchoBiDi(context.Background())
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, biDiClient.Send(&echopb.EchoBiDiRequest{Message: "hi"}), test.ShouldBeNil)
+			for _, expected := range []string{"h", "i"} {
+				resp, err := biDiClient.Recv()
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, resp.Message, test.ShouldEqual, expected)
+			}
+			test.That(t, biDiClient.CloseSend(), test.ShouldBeNil)
+			_, err = biDiClient.Recv()
+			test.That(t, err, test.ShouldEqual, io.EOF)
+
+			// statuses carry back
+			echoServer.SetFail(true)
+			_, err = client.Echo(context.Background(), &echopb.EchoRequest{Message: "hello"})
+			echoServer.SetFail(false)
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, status.Convert(err).Message(), test.ShouldContainSubstring, "whoops")
+		})
+	}
+}
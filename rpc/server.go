@@ -3,7 +3,6 @@ package rpc
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -31,14 +30,18 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"go.viam.com/utils"
+	"go.viam.com/utils/errs"
 	rpcpb "go.viam.com/utils/proto/rpc/v1"
 	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+	"go.viam.com/utils/web/cors"
 )
 
 const (
@@ -69,9 +72,14 @@ type Server interface {
 
 	// ServeTLS will externally serve, using the given cert/key, the
 	// all in one handler described by http.Handler. The provided tlsConfig
-	// will be used for any extra TLS settings. If using mutual TLS authentication
+	// will be used for any extra TLS settings. The cert/key may be empty if
+	// the tlsConfig provides certificates itself, such as via GetCertificate to
+	// choose them by server name (SNI) or to reload them (see
+	// utils.CertificateReloader), or if certificates are obtained via ACME
+	// (see WithAutocert). If using mutual TLS authentication
 	// (see WithTLSAuthHandler), then the tls.Config should have ClientAuth,
-	// at a minimum, set to tls.VerifyClientCertIfGiven.
+	// at a minimum, set to tls.VerifyClientCertIfGiven. With WithHTTP3, HTTP/3
+	// is also served over UDP on the listener's port.
 	ServeTLS(listener net.Listener, certFile, keyFile string, tlsConfig *tls.Config) error
 
 	// Stop stops the internal gRPC and the HTTP server if it
@@ -101,6 +109,16 @@ type Server interface {
 	http.Handler
 
 	EnsureAuthed(ctx context.Context) (context.Context, error)
+
+	// SignAccessToken signs an access token for this server on behalf of an entity that was
+	// authenticated by other means, such as a user logged in to a web session. The token
+	// claims the given credentials type, which must be known to the server, and expires
+	// after the given lifetime. It fails if the server is unauthenticated.
+	SignAccessToken(forType CredentialsType, entity string, lifetime time.Duration, authMD map[string]string) (string, error)
+
+	// VerifyAccessToken returns the claims of the access token if it is valid and meant for
+	// this server.
+	VerifyAccessToken(ctx context.Context, token string) (JWTClaims, error)
 }
 
 type simpleServer struct {
@@ -116,6 +134,7 @@ type simpleServer struct {
 	instanceNames           []string
 	webrtcServer            *webrtcServer
 	webrtcAnswerers         []*webrtcSignalingAnswerer
+	webrtcHostServers       map[string]*webrtcServer
 	serviceServerCancels    []func()
 	signalingCallQueue      WebRTCCallQueue
 	signalingServer         *WebRTCSignalingServer
@@ -125,19 +144,63 @@ type simpleServer struct {
 	// public methods attempt, but do not require, authentication
 	publicMethods        map[string]bool
 	tlsConfig            *tls.Config
+	clientCAs            *x509.CertPool
 	firstSeenTLSCertLeaf *x509.Certificate
+	started              bool
 	stopped              bool
 	logger               golog.Logger
 
 	// auth
 
+	// unauthenticated is whether authentication is disabled altogether.
+	unauthenticated      bool
 	internalUUID         string
 	internalCreds        Credentials
 	tlsAuthHandler       func(ctx context.Context, entities ...string) error
-	authRSAPrivKey       *rsa.PrivateKey
-	authRSAPrivKeyKID    string
+	authKeys             *authKeyRing
+	publishAuthKeys      bool
 	authHandlersForCreds map[CredentialsType]credAuthHandlers
 	authToHandler        AuthenticateToHandler
+	// authorizer, if set, limits what methods authenticated entities may call.
+	authorizer *authorizer
+	// authRateLimiter, if set, limits attempts to authenticate.
+	authRateLimiter *authRateLimiter
+
+	// autocert, if set, provides certificates obtained via ACME.
+	autocert *serverAutocert
+
+	// http3 is whether HTTP/3 is served alongside TLS listeners by http3Servers.
+	http3        bool
+	http3Servers []*serverHTTP3
+
+	// webrtcTunnel is whether WebRTC channels may be tunneled over WebSockets at
+	// WebSocketTunnelPath.
+	webrtcTunnel bool
+
+	// peersDebug is whether peer connections are served at PeersDebugPath.
+	peersDebug bool
+
+	// debugHandler, if set, serves the debug endpoints of WithDebugEndpoints to requests
+	// from debugAllowedNetworks, or from anywhere if there are none, which is only the case
+	// for authenticated servers. The networks apply to peersDebug as well.
+	debugHandler         http.Handler
+	debugAllowedNetworks []*net.IPNet
+
+	reflection   bool
+	healthServer *health.Server
+
+	// shutdownGracePeriod, if set, is how long Stop waits for the calls tracked by
+	// calls to finish.
+	shutdownGracePeriod time.Duration
+	calls               *callTracker
+
+	// cors, if set, handles CORS for requests served over HTTP.
+	cors *cors.Cors
+
+	// metrics, if set, records metrics about the server and metricsHandler, if set,
+	// serves them.
+	metrics        *serverMetrics
+	metricsHandler http.Handler
 
 	// authAudience is the JWT audience (aud) that will be used/expected
 	// for our service.
@@ -160,9 +223,17 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 			return nil, err
 		}
 	}
-	if sOpts.unauthenticated && (len(sOpts.authHandlersForCreds) != 0 || sOpts.tlsAuthHandler != nil) {
+	if sOpts.unauthenticated && (len(sOpts.authHandlersForCreds) != 0 || sOpts.tlsAuthHandler != nil || sOpts.clientCAs != nil ||
+		len(sOpts.authzPolicies) != 0 || sOpts.authzDefaultDeny || sOpts.authKeyRotation != nil || sOpts.publishAuthKeys ||
+		sOpts.authRateLimit != nil) {
 		return nil, errMixedUnauthAndAuth
 	}
+	if sOpts.clientCAs != nil && sOpts.tlsAuthHandler == nil {
+		// the certificate was already verified against the client CAs during the handshake.
+		sOpts.tlsAuthHandler = func(ctx context.Context, entities ...string) error {
+			return nil
+		}
+	}
 
 	grpcBindAddr := sOpts.bindAddress
 	if grpcBindAddr == "" {
@@ -173,7 +244,15 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		}
 	}
 
-	grpcListener, err := net.Listen("tcp", grpcBindAddr)
+	var (
+		grpcListener net.Listener
+		err          error
+	)
+	if utils.IsUnixAddress(grpcBindAddr) {
+		grpcListener, err = utils.ListenUnix(grpcBindAddr, sOpts.unixSocketPerm)
+	} else {
+		grpcListener, err = net.Listen("tcp", grpcBindAddr)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -182,23 +261,29 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 
 	var firstSeenTLSCert *tls.Certificate
 	if sOpts.tlsConfig != nil {
-		if len(sOpts.tlsConfig.Certificates) == 0 {
-			if cert, err := sOpts.tlsConfig.GetCertificate(&tls.ClientHelloInfo{}); err == nil {
+		switch {
+		case len(sOpts.tlsConfig.Certificates) != 0:
+			firstSeenTLSCert = &sOpts.tlsConfig.Certificates[0]
+		case sOpts.tlsConfig.GetCertificate != nil:
+			// the certificate may depend on the client hello, such as its server name, in which
+			// case there is no certificate to see yet.
+			if cert, err := sOpts.tlsConfig.GetCertificate(&tls.ClientHelloInfo{}); err == nil && cert != nil {
 				firstSeenTLSCert = cert
-			} else {
-				return nil, errors.New("invalid *tls.Config; expected at least 1 certificate")
 			}
-		} else {
-			firstSeenTLSCert = &sOpts.tlsConfig.Certificates[0]
+		case sOpts.tlsConfig.GetConfigForClient == nil:
+			return nil, errors.New("invalid *tls.Config; expected at least 1 certificate")
 		}
-		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(sOpts.tlsConfig)))
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(requireClientCertificates(sOpts.tlsConfig, sOpts.clientCAs))))
 	}
 
 	var firstSeenTLSCertLeaf *x509.Certificate
 	if firstSeenTLSCert != nil {
-		leaf, err := x509.ParseCertificate(firstSeenTLSCert.Certificate[0])
-		if err != nil {
-			return nil, err
+		leaf := firstSeenTLSCert.Leaf
+		if leaf == nil {
+			leaf, err = x509.ParseCertificate(firstSeenTLSCert.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
 		}
 		firstSeenTLSCertLeaf = leaf
 	}
@@ -207,21 +292,16 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		ReadTimeout:    10 * time.Second,
 		MaxHeaderBytes: MaxMessageSize,
 	}
+	if sOpts.connectionAge != nil {
+		httpServer.IdleTimeout = sOpts.connectionAge.MaxIdle
+	}
+	serverOpts = append(serverOpts, grpcConnectionLimitOptions(sOpts)...)
 
-	var authRSAPrivKeyThumbprint string
-	authRSAPrivKey := sOpts.authRSAPrivateKey
+	var authKeys *authKeyRing
 	if !sOpts.unauthenticated {
-		if authRSAPrivKey == nil {
-			privKey, err := rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
-			if err != nil {
-				return nil, err
-			}
-			authRSAPrivKey = privKey
-		}
-
-		// create KID from authRSAPrivKey, this is used as the KID in the JWT header. This KID can be useful when more
-		// than one KID is accepted.
-		authRSAPrivKeyThumbprint, err = RSAPublicKeyThumbprint(&authRSAPrivKey.PublicKey)
+		// each key has a KID derived from its public key that is used in the JWT header. This KID
+		// allows the right key to be chosen when more than one KID is accepted.
+		authKeys, err = newAuthKeyRing(context.Background(), sOpts.authPrivateKey, sOpts.authKeyRotation, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -237,7 +317,7 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		sOpts.authHandlersForCreds = make(map[CredentialsType]credAuthHandlers)
 	}
 
-	grpcGatewayHandler := runtime.NewServeMux(
+	grpcGatewayHandler := runtime.NewServeMux(append([]runtime.ServeMuxOption{
 		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
 			MarshalOptions: protojson.MarshalOptions{
 				UseProtoNames: true,
@@ -246,14 +326,15 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 				DiscardUnknown: true,
 			},
 		}),
-	)
+	}, sOpts.gatewayMuxOpts...)...)
 
 	server := &simpleServer{
 		grpcListener:       grpcListener,
 		httpServer:         httpServer,
 		grpcGatewayHandler: grpcGatewayHandler,
-		authRSAPrivKey:     authRSAPrivKey,
-		authRSAPrivKeyKID:  authRSAPrivKeyThumbprint,
+		authKeys:           authKeys,
+		publishAuthKeys:    sOpts.publishAuthKeys,
+		unauthenticated:    sOpts.unauthenticated,
 		internalUUID:       uuid.NewString(),
 		internalCreds: Credentials{
 			Type:    credentialsTypeInternal,
@@ -267,9 +348,43 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		exemptMethods:        make(map[string]bool),
 		publicMethods:        make(map[string]bool),
 		tlsConfig:            sOpts.tlsConfig,
+		clientCAs:            sOpts.clientCAs,
 		firstSeenTLSCertLeaf: firstSeenTLSCertLeaf,
+		cors:                 sOpts.cors,
+		shutdownGracePeriod:  sOpts.shutdownGracePeriod,
+		http3:                sOpts.http3,
 		logger:               logger,
 	}
+	if server.shutdownGracePeriod > 0 {
+		server.calls = &callTracker{}
+	}
+	if len(sOpts.authzPolicies) != 0 || sOpts.authzDefaultDeny {
+		server.authorizer = &authorizer{
+			policies:    sOpts.authzPolicies,
+			defaultDeny: sOpts.authzDefaultDeny,
+			onDenial:    sOpts.authzDenialHook,
+		}
+	}
+	if sOpts.authRateLimit != nil {
+		server.authRateLimiter = &authRateLimiter{opts: *sOpts.authRateLimit}
+	}
+	if sOpts.metricsRegisterer != nil {
+		server.metrics, err = newServerMetrics(sOpts.metricsRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		server.metricsHandler, _ = metricsHandler(sOpts.metricsRegisterer)
+	}
+	if sOpts.debugEndpoints != nil {
+		server.debugHandler = newDebugHandler()
+		server.debugAllowedNetworks = parseDebugNetworks(sOpts.debugEndpoints.AllowedNetworks, sOpts.unauthenticated)
+	}
+	if sOpts.autocert != nil {
+		server.autocert, err = newServerAutocert(*sOpts.autocert, sOpts.metricsRegisterer, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	grpcLogger := logger.Desugar()
 	if !(sOpts.debug || utils.Debug) {
@@ -289,24 +404,43 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		grpc_zap.UnaryServerInterceptor(grpcLogger),
 		unaryServerCodeInterceptor(),
 	)
+	if server.calls != nil {
+		unaryInterceptors = append(unaryInterceptors, server.calls.unaryInterceptor)
+	}
+	unaryMetricsIntPos := -1
+	if server.metrics != nil {
+		unaryInterceptors = append(unaryInterceptors, server.metrics.unaryInterceptor(metricsTransportGRPC))
+		unaryMetricsIntPos = len(unaryInterceptors) - 1
+	}
 	unaryInterceptors = append(unaryInterceptors, UnaryServerTracingInterceptor(grpcLogger))
+	var audit *auditLogger
+	if sOpts.auditLog != nil {
+		// audit before authenticating so that failures to authenticate are recorded too.
+		audit = &auditLogger{opts: *sOpts.auditLog, logger: logger}
+		unaryInterceptors = append(unaryInterceptors, audit.unaryInterceptor)
+	}
+	unaryInterceptors = append(unaryInterceptors, sOpts.unaryInterceptors[InterceptorStageBeforeAuth]...)
 	unaryAuthIntPos := -1
 	if !sOpts.unauthenticated {
 		unaryInterceptors = append(unaryInterceptors, server.authUnaryInterceptor)
 		unaryAuthIntPos = len(unaryInterceptors) - 1
 	}
+	if audit != nil {
+		unaryInterceptors = append(unaryInterceptors, auditCaptureUnaryInterceptor)
+	}
+	var methodRateLimiter *MethodRateLimiter
+	if len(sOpts.methodRateLimits) != 0 {
+		methodRateLimiter, err = NewMethodRateLimiter(sOpts.methodRateLimits...)
+		if err != nil {
+			return nil, err
+		}
+		unaryInterceptors = append(unaryInterceptors, methodRateLimiter.UnaryServerInterceptor())
+	}
+	for _, interceptor := range sOpts.unaryInterceptors[InterceptorStageAfterAuth] {
+		unaryInterceptors = append(unaryInterceptors, server.unaryInterceptorSkippingExempt(interceptor))
+	}
 	if sOpts.unaryInterceptor != nil {
-		unaryInterceptors = append(unaryInterceptors, func(
-			ctx context.Context,
-			req interface{},
-			info *grpc.UnaryServerInfo,
-			handler grpc.UnaryHandler,
-		) (interface{}, error) {
-			if server.exemptMethods[info.FullMethod] {
-				return handler(ctx, req)
-			}
-			return sOpts.unaryInterceptor(ctx, req, info, handler)
-		})
+		unaryInterceptors = append(unaryInterceptors, server.unaryInterceptorSkippingExempt(sOpts.unaryInterceptor))
 	}
 	unaryInterceptor := grpc_middleware.ChainUnaryServer(unaryInterceptors...)
 	serverOpts = append(serverOpts, grpc.UnaryInterceptor(unaryInterceptor))
@@ -322,24 +456,35 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		grpc_zap.StreamServerInterceptor(grpcLogger),
 		streamServerCodeInterceptor(),
 	)
+	if server.calls != nil {
+		streamInterceptors = append(streamInterceptors, server.calls.streamInterceptor)
+	}
+	streamMetricsIntPos := -1
+	if server.metrics != nil {
+		streamInterceptors = append(streamInterceptors, server.metrics.streamInterceptor(metricsTransportGRPC))
+		streamMetricsIntPos = len(streamInterceptors) - 1
+	}
 	streamInterceptors = append(streamInterceptors, StreamServerTracingInterceptor(grpcLogger))
+	if audit != nil {
+		streamInterceptors = append(streamInterceptors, audit.streamInterceptor)
+	}
+	streamInterceptors = append(streamInterceptors, sOpts.streamInterceptors[InterceptorStageBeforeAuth]...)
 	streamAuthIntPos := -1
 	if !sOpts.unauthenticated {
 		streamInterceptors = append(streamInterceptors, server.authStreamInterceptor)
 		streamAuthIntPos = len(streamInterceptors) - 1
 	}
+	if audit != nil {
+		streamInterceptors = append(streamInterceptors, auditCaptureStreamInterceptor)
+	}
+	if methodRateLimiter != nil {
+		streamInterceptors = append(streamInterceptors, methodRateLimiter.StreamServerInterceptor())
+	}
+	for _, interceptor := range sOpts.streamInterceptors[InterceptorStageAfterAuth] {
+		streamInterceptors = append(streamInterceptors, server.streamInterceptorSkippingExempt(interceptor))
+	}
 	if sOpts.streamInterceptor != nil {
-		streamInterceptors = append(streamInterceptors, func(
-			srv interface{},
-			serverStream grpc.ServerStream,
-			info *grpc.StreamServerInfo,
-			handler grpc.StreamHandler,
-		) error {
-			if server.exemptMethods[info.FullMethod] {
-				return handler(srv, serverStream)
-			}
-			return sOpts.streamInterceptor(srv, serverStream, info, handler)
-		})
+		streamInterceptors = append(streamInterceptors, server.streamInterceptorSkippingExempt(sOpts.streamInterceptor))
 	}
 	streamInterceptor := grpc_middleware.ChainStreamServer(streamInterceptors...)
 	serverOpts = append(serverOpts, grpc.StreamInterceptor(streamInterceptor))
@@ -351,9 +496,15 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 	grpcServer := grpc.NewServer(
 		serverOpts...,
 	)
-	reflection.Register(grpcServer)
+	if sOpts.healthService {
+		server.healthServer = health.NewServer()
+		server.healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	server.reflection = sOpts.reflection
+	server.registerStandardServices(grpcServer)
 	grpcWebServer := grpcweb.WrapServer(grpcServer, grpcweb.WithOriginFunc(func(origin string) bool {
-		return true
+		// leave CORS to the configured handler, if any.
+		return sOpts.cors == nil
 	}))
 
 	server.grpcServer = grpcServer
@@ -372,6 +523,11 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 			AuthHandler: MakeSimpleAuthHandler(
 				[]string{server.internalUUID}, server.internalCreds.Payload),
 		}
+		// tokens for web session users can only be signed by the server itself, so they are
+		// always accepted but cannot be authenticated for directly.
+		if _, ok := server.authHandlersForCreds[CredentialsTypeWebSession]; !ok {
+			server.authHandlersForCreds[CredentialsTypeWebSession] = credAuthHandlers{}
+		}
 		// Update this if the proto method or path changes
 		server.exemptMethods["/proto.rpc.v1.AuthService/Authenticate"] = true
 	}
@@ -399,6 +555,10 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 	var mDNSAddress *net.TCPAddr
 	if sOpts.listenerAddress != nil {
 		mDNSAddress = sOpts.listenerAddress
+	} else if unixAddr, ok := grpcListener.Addr().(*net.UnixAddr); ok {
+		// UNIX domain sockets are only reachable on this host.
+		logger.Debugw("not advertising over mDNS since bound to a UNIX domain socket", "path", unixAddr.Name)
+		sOpts.disableMDNS = true
 	} else {
 		var ok bool
 		mDNSAddress, ok = grpcListener.Addr().(*net.TCPAddr)
@@ -413,9 +573,12 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 	}
 	instanceNames := sOpts.instanceNames
 	if len(instanceNames) == 0 {
-		instanceName, err := InstanceNameFromAddress(mDNSAddress.String())
-		if err != nil {
-			return nil, err
+		instanceName := uuid.NewString()
+		if mDNSAddress != nil {
+			instanceName, err = InstanceNameFromAddress(mDNSAddress.String())
+			if err != nil {
+				return nil, err
+			}
 		}
 		instanceNames = []string{instanceName}
 	}
@@ -498,18 +661,25 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 	if sOpts.webrtcOpts.Enable {
 		// TODO(GOUT-11): Handle auth; right now we assume
 		// successful auth to the signaler implies that auth should be allowed here, which is not 100%
-		// true.
+		// true. Access tokens sent along with calls, as clients dialed with credentials do, are still
+		// verified and authorized so that handlers see the same entity and claims as they would over gRPC.
 		webrtcUnaryInterceptors := make([]grpc.UnaryServerInterceptor, 0, len(unaryInterceptors))
 		webrtcStreamInterceptors := make([]grpc.StreamServerInterceptor, 0, len(streamInterceptors))
 		for idx, interceptor := range unaryInterceptors {
-			if idx == unaryAuthIntPos {
-				continue
+			switch idx {
+			case unaryAuthIntPos:
+				interceptor = server.webrtcAuthUnaryInterceptor
+			case unaryMetricsIntPos:
+				interceptor = server.metrics.unaryInterceptor(metricsTransportWebRTC)
 			}
 			webrtcUnaryInterceptors = append(webrtcUnaryInterceptors, interceptor)
 		}
 		for idx, interceptor := range streamInterceptors {
-			if idx == streamAuthIntPos {
-				continue
+			switch idx {
+			case streamAuthIntPos:
+				interceptor = server.webrtcAuthStreamInterceptor
+			case streamMetricsIntPos:
+				interceptor = server.metrics.streamInterceptor(metricsTransportWebRTC)
 			}
 			webrtcStreamInterceptors = append(webrtcStreamInterceptors, interceptor)
 		}
@@ -536,7 +706,40 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		if sOpts.webrtcOpts.OnPeerRemoved != nil {
 			server.webrtcServer.onPeerRemoved = sOpts.webrtcOpts.OnPeerRemoved
 		}
-		reflection.Register(server.webrtcServer)
+		server.webrtcServer.onPeerEvent = sOpts.webrtcOpts.OnPeerEvent
+		server.webrtcServer.peerOpts = webrtcPeerOptions{
+			interfaceFilter:     sOpts.webrtcOpts.InterfaceFilter,
+			ipFilter:            sOpts.webrtcOpts.IPFilter,
+			onLocalDescription:  sOpts.webrtcOpts.OnLocalDescription,
+			onRemoteDescription: sOpts.webrtcOpts.OnRemoteDescription,
+			iceTimeouts:         sOpts.webrtcOpts.ICETimeouts,
+		}
+		server.webrtcServer.heartbeatInterval = sOpts.webrtcOpts.HeartbeatInterval
+		server.webrtcServer.heartbeatTimeout = sOpts.webrtcOpts.HeartbeatTimeout
+		if sOpts.keepalive != nil {
+			server.webrtcServer.heartbeatInterval, server.webrtcServer.heartbeatTimeout = webrtcHeartbeatFromKeepalive(
+				*sOpts.keepalive,
+				server.webrtcServer.heartbeatInterval,
+				server.webrtcServer.heartbeatTimeout,
+			)
+		}
+		server.webrtcServer.maxRecvMsgSize = sOpts.maxRecvMsgSize
+		server.webrtcServer.maxSendMsgSize = sOpts.maxSendMsgSize
+		if sOpts.connectionAge != nil {
+			server.webrtcServer.connectionAge = *sOpts.connectionAge
+		}
+		server.webrtcServer.admission.max = sOpts.webrtcOpts.MaxPeerConnections
+		server.webrtcServer.admission.policy = sOpts.webrtcOpts.PeerConnectionLimitPolicy
+		server.webrtcServer.compressors = sOpts.webrtcOpts.Compressors
+		server.webrtcServer.frameTracer = newWebRTCFrameTracer(sOpts.webrtcOpts.FrameTraceWriter)
+		server.webrtcServer.maxChannelSendRate = sOpts.webrtcOpts.MaxChannelSendRate
+		server.webrtcServer.metrics = server.metrics
+		server.webrtcTunnel = sOpts.webrtcOpts.EnableWebSocketTunnel
+		server.peersDebug = sOpts.webrtcOpts.EnablePeersDebugEndpoint
+		if server.peersDebug && sOpts.debugEndpoints == nil {
+			server.debugAllowedNetworks = parseDebugNetworks(nil, sOpts.unauthenticated)
+		}
+		server.registerStandardServices(server.webrtcServer)
 
 		config := DefaultWebRTCConfiguration
 		if sOpts.webrtcOpts.Config != nil {
@@ -584,7 +787,7 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 				return nil, err
 			}
 
-			address := grpcListener.Addr().String()
+			address := internalDialAddress(grpcListener.Addr())
 			logger.Debugw(
 				"will run internal signaling answerer",
 				"signaling_address", address,
@@ -593,7 +796,7 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 			var answererDialOpts []DialOption
 			if sOpts.tlsConfig != nil {
 				tlsConfig := sOpts.tlsConfig.Clone()
-				tlsConfig.ServerName = server.firstSeenTLSCertLeaf.Subject.CommonName
+				tlsConfig.ServerName = server.internalTLSServerName()
 				answererDialOpts = append(answererDialOpts, WithTLSConfig(tlsConfig))
 			} else {
 				answererDialOpts = append(answererDialOpts, WithInsecure())
@@ -612,9 +815,39 @@ func NewServer(logger golog.Logger, opts ...ServerOption) (Server, error) {
 		}
 	}
 
+	if len(sOpts.gatewayHandlers) != 0 {
+		stopCtx, stopCancel := context.WithCancel(context.Background())
+		server.serviceServerCancels = append(server.serviceServerCancels, stopCancel)
+		if err := server.registerGatewayHandlers(stopCtx, sOpts.gatewayHandlers); err != nil {
+			return nil, err
+		}
+	}
+
 	return server, nil
 }
 
+// unaryInterceptorSkippingExempt returns an interceptor that does not intercept methods
+// exempt from auth.
+func (ss *simpleServer) unaryInterceptorSkippingExempt(interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if ss.exemptMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// streamInterceptorSkippingExempt returns an interceptor that does not intercept methods
+// exempt from auth.
+func (ss *simpleServer) streamInterceptorSkippingExempt(interceptor grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, serverStream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if ss.exemptMethods[info.FullMethod] {
+			return handler(srv, serverStream)
+		}
+		return interceptor(srv, serverStream, info, handler)
+	}
+}
+
 func (ss *simpleServer) InstanceNames() []string {
 	return ss.instanceNames
 }
@@ -644,14 +877,22 @@ func requestWithHost(r *http.Request) *http.Request {
 	return r.WithContext(contextWithHost(r.Context(), host))
 }
 
+// withCORS wraps the handler with the configured CORS handler, if any.
+func (ss *simpleServer) withCORS(h http.Handler) http.Handler {
+	if ss.cors == nil {
+		return h
+	}
+	return ss.cors.Handler(h)
+}
+
 func (ss *simpleServer) GatewayHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return ss.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ss.grpcGatewayHandler.ServeHTTP(w, requestWithHost(r))
-	})
+	}))
 }
 
 func (ss *simpleServer) GRPCHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return ss.withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		r = requestWithHost(r)
 		switch ss.getRequestType(r) {
 		case requestTypeGRPC:
@@ -663,7 +904,7 @@ func (ss *simpleServer) GRPCHandler() http.Handler {
 		default:
 			w.WriteHeader(http.StatusBadRequest)
 		}
-	})
+	}))
 }
 
 func (ss *simpleServer) EnsureAuthed(ctx context.Context) (context.Context, error) {
@@ -674,6 +915,20 @@ func (ss *simpleServer) EnsureAuthed(ctx context.Context) (context.Context, erro
 // in a scenario where all gRPC is served from the root path due to limitations of normal
 // gRPC being served from a non-root path.
 func (ss *simpleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ss.cors != nil {
+		ss.cors.ServeHTTP(w, r, ss.serveHTTP)
+		return
+	}
+	ss.serveHTTP(w, r)
+}
+
+func (ss *simpleServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if ss.calls != nil {
+		// a call's status is written after its handler returns so the request is tracked
+		// until then too.
+		ss.calls.track()
+		defer ss.calls.done()
+	}
 	r = requestWithHost(r)
 	switch ss.getRequestType(r) {
 	case requestTypeGRPC:
@@ -683,6 +938,26 @@ func (ss *simpleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case requestTypeNone:
 		fallthrough
 	default:
+		if ss.autocert != nil && strings.HasPrefix(r.URL.Path, autocertChallengePathPrefix) {
+			ss.autocert.challengeHandler.ServeHTTP(w, r)
+			return
+		}
+		if ss.publishAuthKeys && r.URL.Path == AuthKeysPath {
+			ss.authKeys.ServeHTTP(w, r)
+			return
+		}
+		if ss.metricsHandler != nil && r.URL.Path == MetricsPath {
+			ss.metricsHandler.ServeHTTP(w, r)
+			return
+		}
+		if ss.webrtcTunnel && r.URL.Path == WebSocketTunnelPath {
+			ss.serveWebSocketTunnel(w, r)
+			return
+		}
+		if ss.servesDebug(r) {
+			ss.serveDebug(w, r)
+			return
+		}
 		ss.grpcGatewayHandler.ServeHTTP(w, r)
 	}
 }
@@ -691,12 +966,24 @@ func (ss *simpleServer) InternalAddr() net.Addr {
 	return ss.grpcListener.Addr()
 }
 
+// registerStandardServices registers the reflection and health services, if enabled, on
+// the given server.
+func (ss *simpleServer) registerStandardServices(server reflection.GRPCServer) {
+	if ss.reflection {
+		reflection.Register(server)
+	}
+	if ss.healthServer != nil {
+		healthpb.RegisterHealthServer(server, ss.healthServer)
+	}
+}
+
 func (ss *simpleServer) Start() error {
 	ss.mu.Lock()
 	if ss.stopped {
 		ss.mu.Unlock()
 		return errors.New("server stopped")
 	}
+	ss.started = true
 	ss.mu.Unlock()
 
 	var err error
@@ -712,6 +999,15 @@ func (ss *simpleServer) Start() error {
 	for _, answerer := range ss.webrtcAnswerers {
 		answerer.Start()
 	}
+	if ss.authKeys != nil {
+		ss.authKeys.startRotating()
+	}
+	if ss.autocert != nil {
+		ss.autocert.startMonitoring()
+	}
+	if ss.healthServer != nil {
+		ss.healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	}
 
 	errMu.Lock()
 	defer errMu.Unlock()
@@ -734,28 +1030,59 @@ func (ss *simpleServer) serveTLS(listener net.Listener, certFile, keyFile string
 	}
 	ss.httpServer.Addr = listener.Addr().String()
 	ss.httpServer.Handler = ss
+	if ss.autocert != nil && certFile == "" && keyFile == "" && !tlsConfigHasCertificates(tlsConfig) {
+		tlsConfig = ss.autocert.tlsConfig(tlsConfig)
+	}
 	secure := true
-	if certFile == "" && keyFile == "" {
+	if certFile == "" && keyFile == "" && !tlsConfigHasCertificates(tlsConfig) {
 		secure = false
 		http2Server, err := utils.NewHTTP2Server()
 		if err != nil {
 			return err
 		}
+		http2Server.HTTP2.IdleTimeout = ss.httpServer.IdleTimeout
 		ss.httpServer.RegisterOnShutdown(func() {
 			utils.UncheckedErrorFunc(http2Server.Close)
 		})
 		ss.httpServer.Handler = h2c.NewHandler(ss.httpServer.Handler, http2Server.HTTP2)
 	}
 
+	var h3 *serverHTTP3
+	if ss.http3 {
+		if secure {
+			var err error
+			h3, err = ss.listenHTTP3(listener, certFile, keyFile, tlsConfig)
+			if err != nil {
+				ss.mu.Unlock()
+				return err
+			}
+			ss.http3Servers = append(ss.http3Servers, h3)
+			ss.httpServer.Handler = h3.advertise(ss.httpServer.Handler)
+		} else {
+			ss.logger.Warn("HTTP/3 is only served over TLS; not serving it")
+		}
+	}
+
 	var err error
 	var errMu sync.Mutex
+	if h3 != nil {
+		ss.activeBackgroundWorkers.Add(1)
+		utils.ManagedGo(func() {
+			if serveErr := h3.serve(); serveErr != nil {
+				ss.logger.Errorw("error serving HTTP/3", "error", serveErr)
+			}
+		}, ss.activeBackgroundWorkers.Done)
+	}
 	ss.activeBackgroundWorkers.Add(1)
 	ss.mu.Unlock()
 	utils.ManagedGo(func() {
 		var serveErr error
 		if secure {
+			if tlsConfig == nil && ss.clientCAs != nil {
+				tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
 			if tlsConfig != nil {
-				ss.httpServer.TLSConfig = tlsConfig.Clone()
+				ss.httpServer.TLSConfig = requireClientCertificates(tlsConfig, ss.clientCAs)
 			}
 			serveErr = ss.httpServer.ServeTLS(listener, certFile, keyFile)
 		} else {
@@ -774,6 +1101,24 @@ func (ss *simpleServer) serveTLS(listener net.Listener, certFile, keyFile string
 	return err
 }
 
+// tlsConfigHasCertificates returns whether the config provides certificates to serve
+// without needing any files.
+func tlsConfigHasCertificates(tlsConfig *tls.Config) bool {
+	return tlsConfig != nil &&
+		(len(tlsConfig.Certificates) != 0 || tlsConfig.GetCertificate != nil || tlsConfig.GetConfigForClient != nil)
+}
+
+// requireClientCertificates returns a copy of the config that requires client certificates
+// verified against the given CAs, if any.
+func requireClientCertificates(tlsConfig *tls.Config, clientCAs *x509.CertPool) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	if clientCAs != nil {
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}
+
 func (ss *simpleServer) Stop() error {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
@@ -783,6 +1128,9 @@ func (ss *simpleServer) Stop() error {
 	ss.stopped = true
 	var err error
 	ss.logger.Info("stopping")
+	if ss.healthServer != nil {
+		ss.healthServer.Shutdown()
+	}
 	for idx, answerer := range ss.webrtcAnswerers {
 		ss.logger.Debugw("stopping WebRTC answerer", "num", idx)
 		answerer.Stop()
@@ -794,6 +1142,9 @@ func (ss *simpleServer) Stop() error {
 	if ss.signalingCallQueue != nil {
 		err = multierr.Combine(err, ss.signalingCallQueue.Close())
 	}
+	if ss.calls != nil {
+		err = multierr.Combine(err, ss.drain())
+	}
 	ss.logger.Debug("stopping gRPC server")
 	defer ss.grpcServer.Stop()
 	ss.logger.Debug("canceling service servers for gateway")
@@ -806,17 +1157,79 @@ func (ss *simpleServer) Stop() error {
 		ss.webrtcServer.Stop()
 		ss.logger.Debug("WebRTC server stopped")
 	}
+	for host, hostServer := range ss.webrtcHostServers {
+		ss.logger.Debugw("stopping WebRTC host server", "host", host)
+		hostServer.Stop()
+	}
 	for _, mdnsServer := range ss.mdnsServers {
 		mdnsServer.Shutdown()
 	}
-	ss.logger.Debug("shutting down HTTP server")
-	err = multierr.Combine(err, ss.httpServer.Shutdown(context.Background()))
-	ss.logger.Debug("HTTP server shut down")
+	if ss.authKeys != nil {
+		ss.authKeys.close()
+	}
+	if ss.autocert != nil {
+		ss.autocert.close()
+	}
+	// by now any calls over HTTP/3 have been drained, if desired.
+	for _, h3 := range ss.http3Servers {
+		err = multierr.Combine(err, h3.close())
+	}
+	// draining already shut the HTTP server down.
+	if ss.calls == nil {
+		ss.logger.Debug("shutting down HTTP server")
+		err = multierr.Combine(err, ss.httpServer.Shutdown(context.Background()))
+		ss.logger.Debug("HTTP server shut down")
+	}
 	ss.activeBackgroundWorkers.Wait()
 	ss.logger.Info("stopped cleanly")
 	return err
 }
 
+// A WebRTCHostServiceRegistrar registers services that are only served to WebRTC peers
+// that connected to a specific host. This allows a single server answering for several
+// hosts over one signaling connection to give each host its own set of services.
+type WebRTCHostServiceRegistrar interface {
+	// RegisterWebRTCHostServiceServer associates a service description with its
+	// implementation for peers of the given host only. Once any service is registered
+	// for a host, its peers are no longer served the services registered through
+	// RegisterServiceServer. The host must be one the server answers for and services
+	// must be registered before the server is started.
+	RegisterWebRTCHostServiceServer(host string, svcDesc *grpc.ServiceDesc, svcServer interface{}) error
+}
+
+var _ = WebRTCHostServiceRegistrar(&simpleServer{})
+
+func (ss *simpleServer) RegisterWebRTCHostServiceServer(host string, svcDesc *grpc.ServiceDesc, svcServer interface{}) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.webrtcServer == nil {
+		return errors.New("WebRTC is not enabled")
+	}
+	if ss.started {
+		return errors.New("host services must be registered before the server is started")
+	}
+	hostServer, ok := ss.webrtcHostServers[host]
+	if !ok {
+		hostServer = ss.webrtcServer.newHostServer(host)
+		var answered bool
+		for _, answerer := range ss.webrtcAnswerers {
+			if answerer.serveHost(host, hostServer) {
+				answered = true
+			}
+		}
+		if !answered {
+			return errors.Errorf("not answering for host %q", host)
+		}
+		ss.registerStandardServices(hostServer)
+		if ss.webrtcHostServers == nil {
+			ss.webrtcHostServers = map[string]*webrtcServer{}
+		}
+		ss.webrtcHostServers[host] = hostServer
+	}
+	hostServer.RegisterService(svcDesc, svcServer)
+	return nil
+}
+
 // A RegisterServiceHandlerFromEndpointFunc is a means to have a service attach itself to a gRPC gateway mux.
 type RegisterServiceHandlerFromEndpointFunc func(
 	ctx context.Context,
@@ -840,20 +1253,53 @@ func (ss *simpleServer) RegisterServiceServer(
 		//nolint:contextcheck
 		ss.webrtcServer.RegisterService(svcDesc, svcServer)
 	}
-	if len(svcHandlers) != 0 {
-		addr := ss.grpcListener.Addr().String()
-		opts := []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(MaxMessageSize))}
-		if ss.tlsConfig == nil {
-			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		} else {
-			tlsConfig := ss.tlsConfig.Clone()
-			tlsConfig.ServerName = ss.firstSeenTLSCertLeaf.DNSNames[0]
-			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	return ss.registerGatewayHandlers(stopCtx, svcHandlers)
+}
+
+// registerGatewayHandlers registers the handlers on the gateway mux such that they proxy
+// requests to the internal gRPC server.
+// internalDialAddress returns the address to dial the gRPC listener bound to the given address at.
+func internalDialAddress(addr net.Addr) string {
+	if unixAddr, ok := addr.(*net.UnixAddr); ok {
+		return utils.UnixAddressPrefix + unixAddr.Name
+	}
+	return addr.String()
+}
+
+// internalTLSServerName returns the server name to verify when dialing the internal
+// listener over TLS: a name of the first certificate seen, if any, or else the name of
+// the instance, which a certificate chosen by server name (SNI) is expected to cover.
+func (ss *simpleServer) internalTLSServerName() string {
+	if ss.firstSeenTLSCertLeaf != nil {
+		if len(ss.firstSeenTLSCertLeaf.DNSNames) != 0 {
+			return ss.firstSeenTLSCertLeaf.DNSNames[0]
 		}
-		for _, h := range svcHandlers {
-			if err := h(stopCtx, ss.grpcGatewayHandler, addr, opts); err != nil {
-				return err
-			}
+		if ss.firstSeenTLSCertLeaf.Subject.CommonName != "" {
+			return ss.firstSeenTLSCertLeaf.Subject.CommonName
+		}
+	}
+	if len(ss.instanceNames) != 0 {
+		return ss.instanceNames[0]
+	}
+	return ""
+}
+
+func (ss *simpleServer) registerGatewayHandlers(ctx context.Context, handlers []RegisterServiceHandlerFromEndpointFunc) error {
+	if len(handlers) == 0 {
+		return nil
+	}
+	addr := internalDialAddress(ss.grpcListener.Addr())
+	opts := []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(MaxMessageSize))}
+	if ss.tlsConfig == nil {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig := ss.tlsConfig.Clone()
+		tlsConfig.ServerName = ss.internalTLSServerName()
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	for _, h := range handlers {
+		if err := h(ctx, ss.grpcGatewayHandler, addr, opts); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -865,6 +1311,9 @@ func unaryServerCodeInterceptor() grpc.UnaryServerInterceptor {
 		if err == nil {
 			return resp, nil
 		}
+		if s, ok := errs.ToStatus(err); ok {
+			return nil, s.Err()
+		}
 		if _, ok := status.FromError(err); ok {
 			return nil, err
 		}
@@ -881,6 +1330,9 @@ func streamServerCodeInterceptor() grpc.StreamServerInterceptor {
 		if err == nil {
 			return nil
 		}
+		if s, ok := errs.ToStatus(err); ok {
+			return s.Err()
+		}
 		if _, ok := status.FromError(err); ok {
 			return err
 		}
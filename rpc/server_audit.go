@@ -0,0 +1,347 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	mongoutils "go.viam.com/utils/mongo"
+)
+
+func init() {
+	mongoutils.MustRegisterNamespace(&mongodbAuditSinkDBName, &mongodbAuditSinkCollName)
+}
+
+// An AuditRecord describes a single RPC call made to a server. Records are written as JSON
+// by file sinks, one object per line:
+//
+//	{"time":"2023-01-01T12:00:00Z","method":"/proto.rpc.examples.echo.v1.EchoService/Echo","entity":"foo","code":"OK","latency":1200000}
+//
+// Latency is in nanoseconds. WebRTC calls have the ID of their peer connection and the
+// addresses of its remote candidates by type instead of a peer address.
+type AuditRecord struct {
+	Time             time.Time         `json:"time" bson:"time"`
+	Method           string            `json:"method" bson:"method"`
+	Entity           string            `json:"entity,omitempty" bson:"entity,omitempty"`
+	CredentialsType  CredentialsType   `json:"credentials_type,omitempty" bson:"credentials_type,omitempty"`
+	PeerAddress      string            `json:"peer_address,omitempty" bson:"peer_address,omitempty"`
+	PeerConnectionID string            `json:"peer_connection_id,omitempty" bson:"peer_connection_id,omitempty"`
+	RemoteCandidates map[string]string `json:"remote_candidates,omitempty" bson:"remote_candidates,omitempty"`
+	Code             string            `json:"code" bson:"code"`
+	Latency          time.Duration     `json:"latency" bson:"latency"`
+}
+
+// An AuditSink records audited calls. Record is called in line with every audited call
+// once it finishes, so it should return quickly.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// AuditLogOptions configure what calls are audited and where they are recorded to.
+type AuditLogOptions struct {
+	// Sinks are what every audited call is recorded to.
+	Sinks []AuditSink
+
+	// IncludeMethods are patterns (see path.Match) of the full method names to audit. If
+	// empty, all methods are audited.
+	IncludeMethods []string
+
+	// ExcludeMethods are patterns (see path.Match) of the full method names not to audit,
+	// even if included.
+	ExcludeMethods []string
+}
+
+// WithAuditLog returns a ServerOption which records every call to the server, whether
+// or not it succeeded, to the given sinks. Calls that fail authentication are recorded
+// without an entity.
+func WithAuditLog(opts AuditLogOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if len(opts.Sinks) == 0 {
+			return errors.New("expected at least one audit sink")
+		}
+		for _, pattern := range append(append([]string{}, opts.IncludeMethods...), opts.ExcludeMethods...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Wrapf(err, "invalid pattern %q", pattern)
+			}
+		}
+		o.auditLog = &opts
+		return nil
+	})
+}
+
+// auditLogger records calls to audit sinks.
+type auditLogger struct {
+	opts   AuditLogOptions
+	logger golog.Logger
+}
+
+func (a *auditLogger) audits(fullMethod string) bool {
+	if len(a.opts.IncludeMethods) != 0 && !matchesAny(a.opts.IncludeMethods, fullMethod) {
+		return false
+	}
+	return !matchesAny(a.opts.ExcludeMethods, fullMethod)
+}
+
+// auditCall is where the entity of a call is captured by an interceptor that runs after
+// authentication so that it can be recorded by the one that started auditing the call.
+type auditCall struct {
+	entity          string
+	credentialsType CredentialsType
+}
+
+type auditCallCtxKey struct{}
+
+func (a *auditLogger) start(ctx context.Context) (context.Context, *auditCall) {
+	call := &auditCall{}
+	return context.WithValue(ctx, auditCallCtxKey{}, call), call
+}
+
+func (a *auditLogger) finish(ctx context.Context, fullMethod string, call *auditCall, started time.Time, err error) {
+	record := AuditRecord{
+		Time:            started,
+		Method:          fullMethod,
+		Entity:          call.entity,
+		CredentialsType: call.credentialsType,
+		Code:            status.Code(err).String(),
+		Latency:         time.Since(started),
+	}
+	if pc, ok := ContextPeerConnection(ctx); ok {
+		stats := getWebRTCPeerConnectionStats(pc)
+		record.PeerConnectionID = stats.ID
+		record.RemoteCandidates = stats.RemoteCandidates
+	} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		record.PeerAddress = p.Addr.String()
+	}
+	for _, sink := range a.opts.Sinks {
+		if err := sink.Record(ctx, record); err != nil {
+			a.logger.Warnw("failed to record audited call", "method", fullMethod, "error", err)
+		}
+	}
+}
+
+// captureAuditEntity remembers the authenticated entity of the call, if any.
+func captureAuditEntity(ctx context.Context) {
+	call, ok := ctx.Value(auditCallCtxKey{}).(*auditCall)
+	if !ok {
+		return
+	}
+	if entity, ok := ContextAuthEntity(ctx); ok {
+		call.entity = entity.Entity
+	}
+	if claims, ok := ContextAuthClaims(ctx); ok {
+		call.credentialsType = claims.CredentialsType()
+	}
+}
+
+func (a *auditLogger) unaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if !a.audits(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	started := time.Now()
+	ctx, call := a.start(ctx)
+	resp, err := handler(ctx, req)
+	a.finish(ctx, info.FullMethod, call, started, err)
+	return resp, err
+}
+
+func (a *auditLogger) streamInterceptor(
+	srv interface{},
+	serverStream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if !a.audits(info.FullMethod) {
+		return handler(srv, serverStream)
+	}
+	started := time.Now()
+	ctx, call := a.start(serverStream.Context())
+	err := handler(srv, wrapServerStream(ctx, serverStream))
+	a.finish(ctx, info.FullMethod, call, started, err)
+	return err
+}
+
+func auditCaptureUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	captureAuditEntity(ctx)
+	return handler(ctx, req)
+}
+
+func auditCaptureStreamInterceptor(
+	srv interface{},
+	serverStream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	captureAuditEntity(serverStream.Context())
+	return handler(srv, serverStream)
+}
+
+// NewLoggerAuditSink returns an AuditSink that writes structured log entries.
+func NewLoggerAuditSink(logger golog.Logger) AuditSink {
+	return &loggerAuditSink{logger: logger}
+}
+
+type loggerAuditSink struct {
+	logger golog.Logger
+}
+
+func (sink *loggerAuditSink) Record(ctx context.Context, record AuditRecord) error {
+	fields := []interface{}{
+		"method", record.Method,
+		"code", record.Code,
+		"latency", record.Latency,
+	}
+	if record.Entity != "" {
+		fields = append(fields, "entity", record.Entity, "credentials_type", record.CredentialsType)
+	}
+	if record.PeerAddress != "" {
+		fields = append(fields, "peer_address", record.PeerAddress)
+	}
+	if record.PeerConnectionID != "" {
+		fields = append(fields, "peer_connection_id", record.PeerConnectionID, "remote_candidates", record.RemoteCandidates)
+	}
+	sink.logger.Infow("audit", fields...)
+	return nil
+}
+
+// A FileAuditSink is an AuditSink that writes records as JSON lines to a file, rotating
+// it once it grows too large.
+type FileAuditSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileAuditSink returns a FileAuditSink appending to the file at the given path. Once
+// the file would exceed maxSize bytes, it is renamed to path.1 (shifting older files to
+// path.2 and so on, keeping at most maxBackups of them) and a new file is started. If
+// maxSize is zero, the file is never rotated.
+func NewFileAuditSink(path string, maxSize int64, maxBackups int) (*FileAuditSink, error) {
+	sink := &FileAuditSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (sink *FileAuditSink) open() error {
+	//nolint:gosec
+	file, err := os.OpenFile(sink.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return multierr.Combine(err, file.Close())
+	}
+	sink.file = file
+	sink.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to the first backup and opens a new one.
+func (sink *FileAuditSink) rotate() error {
+	if err := sink.file.Close(); err != nil {
+		return err
+	}
+	sink.file = nil
+	if sink.maxBackups <= 0 {
+		if err := os.Remove(sink.path); err != nil {
+			return err
+		}
+		return sink.open()
+	}
+	for i := sink.maxBackups - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", sink.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", sink.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(sink.path, sink.path+".1"); err != nil {
+		return err
+	}
+	return sink.open()
+}
+
+// Record writes the record to the file.
+func (sink *FileAuditSink) Record(ctx context.Context, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.file == nil {
+		return errors.New("audit file closed")
+	}
+	if sink.maxSize > 0 && sink.size > 0 && sink.size+int64(len(line)) > sink.maxSize {
+		if err := sink.rotate(); err != nil {
+			return errors.Wrap(err, "failed to rotate audit file")
+		}
+	}
+	n, err := sink.file.Write(line)
+	sink.size += int64(n)
+	return err
+}
+
+// Close closes the file.
+func (sink *FileAuditSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.file == nil {
+		return nil
+	}
+	err := sink.file.Close()
+	sink.file = nil
+	return err
+}
+
+// Database and collection names used by the mongoDBAuditSink.
+var (
+	mongodbAuditSinkDBName   = "rpc"
+	mongodbAuditSinkCollName = "audit_log"
+)
+
+// NewMongoDBAuditSink returns an AuditSink that inserts records into a MongoDB collection.
+func NewMongoDBAuditSink(client *mongo.Client) AuditSink {
+	coll := client.Database(mongodbAuditSinkDBName).Collection(mongodbAuditSinkCollName)
+	return &mongoDBAuditSink{coll: coll}
+}
+
+type mongoDBAuditSink struct {
+	coll *mongo.Collection
+}
+
+func (sink *mongoDBAuditSink) Record(ctx context.Context, record AuditRecord) error {
+	// the call may have been canceled but it should still be recorded.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := sink.coll.InsertOne(ctx, record)
+	return err
+}
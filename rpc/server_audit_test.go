@@ -0,0 +1,214 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (sink *memoryAuditSink) Record(ctx context.Context, record AuditRecord) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+func (sink *memoryAuditSink) recorded() []AuditRecord {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]AuditRecord(nil), sink.records...)
+}
+
+func readAuditFile(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	//nolint:gosec
+	file, err := os.Open(path)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, file.Close(), test.ShouldBeNil)
+	}()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		test.That(t, json.Unmarshal(scanner.Bytes(), &record), test.ShouldBeNil)
+		records = append(records, record)
+	}
+	test.That(t, scanner.Err(), test.ShouldBeNil)
+	return records
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := os.MkdirTemp("", "viam-test-*")
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, os.RemoveAll(dir), test.ShouldBeNil)
+	}()
+	path := filepath.Join(dir, "audit.log")
+
+	record := AuditRecord{
+		Time:    time.Now().UTC().Truncate(time.Millisecond),
+		Method:  "/proto.rpc.examples.echo.v1.EchoService/Echo",
+		Entity:  "foo",
+		Code:    codes.OK.String(),
+		Latency: time.Millisecond,
+	}
+	line, err := json.Marshal(record)
+	test.That(t, err, test.ShouldBeNil)
+
+	// fits two records per file
+	sink, err := NewFileAuditSink(path, int64(2*(len(line)+1)), 2)
+	test.That(t, err, test.ShouldBeNil)
+	for i := 0; i < 7; i++ {
+		test.That(t, sink.Record(context.Background(), record), test.ShouldBeNil)
+	}
+	test.That(t, sink.Close(), test.ShouldBeNil)
+	test.That(t, sink.Record(context.Background(), record), test.ShouldNotBeNil)
+
+	test.That(t, readAuditFile(t, path), test.ShouldHaveLength, 1)
+	backup := readAuditFile(t, path+".1")
+	test.That(t, backup, test.ShouldHaveLength, 2)
+	test.That(t, backup[0].Time.Equal(record.Time), test.ShouldBeTrue)
+	backup[0].Time = record.Time
+	test.That(t, backup[0], test.ShouldResemble, record)
+	test.That(t, readAuditFile(t, path+".2"), test.ShouldHaveLength, 2)
+	_, err = os.Stat(path + ".3")
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	// appends to an existing file
+	sink, err = NewFileAuditSink(path, 0, 0)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sink.Record(context.Background(), record), test.ShouldBeNil)
+	test.That(t, sink.Close(), test.ShouldBeNil)
+	test.That(t, readAuditFile(t, path), test.ShouldHaveLength, 2)
+}
+
+func TestMongoDBAuditSink(t *testing.T) {
+	client := testutils.BackingMongoDBClient(t)
+	coll := client.Database(mongodbAuditSinkDBName).Collection(mongodbAuditSinkCollName)
+	test.That(t, coll.Drop(context.Background()), test.ShouldBeNil)
+
+	sink := NewMongoDBAuditSink(client)
+	record := AuditRecord{
+		Time:        time.Now().UTC().Truncate(time.Millisecond),
+		Method:      "/proto.rpc.examples.echo.v1.EchoService/Echo",
+		PeerAddress: "127.0.0.1:1234",
+		Code:        codes.Unauthenticated.String(),
+		Latency:     time.Millisecond,
+	}
+	test.That(t, sink.Record(context.Background(), record), test.ShouldBeNil)
+
+	var stored AuditRecord
+	test.That(t, coll.FindOne(context.Background(), bson.D{{"method", record.Method}}).Decode(&stored), test.ShouldBeNil)
+	test.That(t, stored.Time.Equal(record.Time), test.ShouldBeTrue)
+	stored.Time = record.Time
+	test.That(t, stored, test.ShouldResemble, record)
+}
+
+func TestServerAuditLog(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	sink := &memoryAuditSink{}
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuditLog(AuditLogOptions{
+			Sinks:          []AuditSink{sink, NewLoggerAuditSink(logger)},
+			ExcludeMethods: []string{"/proto.rpc.v1.AuthService/*"},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := Dial(
+		context.Background(),
+		httpListener.Addr().String(),
+		logger,
+		WithInsecure(),
+		WithForceDirectGRPC(),
+		WithEntityCredentials("foo", Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	client := pb.NewEchoServiceClient(conn)
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	stream, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hi"})
+	test.That(t, err, test.ShouldBeNil)
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	unauthConn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = pb.NewEchoServiceClient(unauthConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, unauthConn.Close(), test.ShouldBeNil)
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+
+	records := sink.recorded()
+	test.That(t, records, test.ShouldHaveLength, 3)
+	for _, record := range records {
+		test.That(t, record.PeerAddress, test.ShouldNotBeEmpty)
+		test.That(t, record.Time.IsZero(), test.ShouldBeFalse)
+		test.That(t, record.Latency, test.ShouldBeGreaterThan, 0)
+	}
+	test.That(t, records[0].Method, test.ShouldEqual, "/proto.rpc.examples.echo.v1.EchoService/Echo")
+	test.That(t, records[0].Entity, test.ShouldEqual, "foo")
+	test.That(t, records[0].CredentialsType, test.ShouldEqual, CredentialsTypeAPIKey)
+	test.That(t, records[0].Code, test.ShouldEqual, codes.OK.String())
+	test.That(t, records[1].Method, test.ShouldEqual, "/proto.rpc.examples.echo.v1.EchoService/EchoMultiple")
+	test.That(t, records[1].Entity, test.ShouldEqual, "foo")
+	test.That(t, records[1].Code, test.ShouldEqual, codes.OK.String())
+	test.That(t, records[2].Method, test.ShouldEqual, "/proto.rpc.examples.echo.v1.EchoService/Echo")
+	test.That(t, records[2].Entity, test.ShouldBeEmpty)
+	test.That(t, records[2].Code, test.ShouldEqual, codes.Unauthenticated.String())
+
+	_, err = NewServer(logger, WithAuditLog(AuditLogOptions{}))
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -3,7 +3,7 @@ package rpc
 import (
 	"context"
 	"crypto/x509"
-	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -88,17 +88,35 @@ func (ss *simpleServer) Authenticate(ctx context.Context, req *rpcpb.Authenticat
 	if handlers.AuthHandler == nil {
 		return nil, status.Errorf(codes.Unimplemented, "direct authentication not supported for %q", forType)
 	}
+	var entityKey, peerKey string
+	if ss.authRateLimiter != nil {
+		entityKey, peerKey = authRateLimitKeys(ctx, req.Entity)
+		if err := ss.authRateLimiter.allow(ctx, entityKey, peerKey); err != nil {
+			return nil, err
+		}
+	}
 	authMD, err := handlers.AuthHandler.Authenticate(ctx, req.Entity, req.Credentials.Payload)
 	if err != nil {
+		ss.metrics.authFailed(authFailureCredentials)
+		if ss.authRateLimiter != nil {
+			if recordErr := ss.authRateLimiter.recordFailure(ctx, entityKey, peerKey); recordErr != nil {
+				ss.logger.Warnw("failed to record authentication failure", "error", recordErr)
+			}
+		}
 		if _, ok := status.FromError(err); ok {
 			return nil, err
 		}
 		return nil, status.Errorf(codes.PermissionDenied, "failed to authenticate: %s", err.Error())
 	}
+	if ss.authRateLimiter != nil {
+		if err := ss.authRateLimiter.recordSuccess(ctx, entityKey); err != nil {
+			ss.logger.Warnw("failed to record authentication success", "error", err)
+		}
+	}
 
 	// We sign tokens destined for ourselves. If they are not for ourselves but for the entity, then
 	// AuthenticateTo should be used.
-	token, err := ss.signAccessTokenForEntity(forType, ss.authAudience, req.Entity, authMD)
+	token, err := ss.signAccessTokenForEntity(forType, ss.authAudience, req.Entity, 0, authMD)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +138,7 @@ func (ss *simpleServer) AuthenticateTo(ctx context.Context, req *rpcpb.Authentic
 		return nil, err
 	}
 
-	token, err := ss.signAccessTokenForEntity(CredentialsTypeExternal, []string{req.Entity}, entity.Entity, authMD)
+	token, err := ss.signAccessTokenForEntity(CredentialsTypeExternal, []string{req.Entity}, entity.Entity, 0, authMD)
 	if err != nil {
 		return nil, err
 	}
@@ -130,32 +148,84 @@ func (ss *simpleServer) AuthenticateTo(ctx context.Context, req *rpcpb.Authentic
 	}, nil
 }
 
+// SignAccessToken signs an access token for this server on behalf of an entity that was
+// authenticated by other means.
+func (ss *simpleServer) SignAccessToken(
+	forType CredentialsType,
+	entity string,
+	lifetime time.Duration,
+	authMD map[string]string,
+) (string, error) {
+	if ss.authKeys == nil {
+		return "", errors.New("cannot sign access tokens when unauthenticated")
+	}
+	if forType == credentialsTypeInternal {
+		return "", errors.Errorf("cannot sign access tokens for %q", forType)
+	}
+	if _, err := ss.authHandlers(forType); err != nil {
+		return "", err
+	}
+	if entity == "" {
+		return "", errors.New("entity cannot be empty")
+	}
+	if lifetime <= 0 {
+		return "", errors.New("expected positive access token lifetime")
+	}
+	return ss.signAccessTokenForEntity(forType, ss.authAudience, entity, lifetime, authMD)
+}
+
+// VerifyAccessToken returns the claims of the access token if it is valid and meant for
+// this server.
+func (ss *simpleServer) VerifyAccessToken(ctx context.Context, token string) (JWTClaims, error) {
+	if ss.authKeys == nil {
+		return JWTClaims{}, errors.New("cannot verify access tokens when unauthenticated")
+	}
+	authedCtx, err := ss.verifyAccessToken(ctx, token)
+	if err != nil {
+		return JWTClaims{}, err
+	}
+	return MustAuthClaims[JWTClaims](authedCtx), nil
+}
+
+// signAccessTokenForEntity signs an access token for the entity that expires after the
+// lifetime, if positive.
 func (ss *simpleServer) signAccessTokenForEntity(
 	forType CredentialsType,
 	audience []string,
 	entity string,
+	lifetime time.Duration,
 	authMD map[string]string,
 ) (string, error) {
-	// TODO(GOUT-13): expiration
+	// TODO(GOUT-13): expiration of tokens from Authenticate
 	// TODO(GOUT-12): refresh token
 	// TODO(GOUT-9): more complete info
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, JWTClaims{
+	authKey, signingMethod, err := ss.authKeys.signingKey()
+	if err != nil {
+		ss.logger.Errorw("failed to get signing key", "error", err)
+		return "", status.Error(codes.PermissionDenied, "failed to authenticate")
+	}
+	now := time.Now()
+	claims := JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:  entity,
 			Audience: audience,
 			Issuer:   ss.authIssuer,
-			IssuedAt: jwt.NewNumericDate(time.Now()),
+			IssuedAt: jwt.NewNumericDate(now),
 			ID:       uuid.NewString(),
 		},
 		AuthCredentialsType: forType,
 		AuthMetadata:        authMD,
-	})
+	}
+	if lifetime > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(lifetime))
+	}
+	token := jwt.NewWithClaims(signingMethod, claims)
 
 	// Set the Key ID (kid) to allow the auth handlers to selectively choose which key was used
 	// to sign the token.
-	token.Header["kid"] = ss.authRSAPrivKeyKID
+	token.Header["kid"] = authKey.ID
 
-	tokenString, err := token.SignedString(ss.authRSAPrivKey)
+	tokenString, err := token.SignedString(authKey.PrivateKey)
 	if err != nil {
 		ss.logger.Errorw("failed to sign JWT", "error", err)
 		return "", status.Error(codes.PermissionDenied, "failed to authenticate")
@@ -195,6 +265,9 @@ func (ss *simpleServer) authUnaryInterceptor(
 	if err != nil {
 		return nil, err
 	}
+	if err := ss.authorizeCall(nextCtx, info.FullMethod); err != nil {
+		return nil, err
+	}
 
 	return handler(nextCtx, req)
 }
@@ -224,11 +297,83 @@ func (ss *simpleServer) authStreamInterceptor(
 	if err != nil {
 		return err
 	}
+	if err := ss.authorizeCall(nextCtx, info.FullMethod); err != nil {
+		return err
+	}
 
 	serverStream = ctxWrappedServerStream{serverStream, nextCtx}
 	return handler(srv, serverStream)
 }
 
+// authorizeCall ensures that the authenticated entity, if any, may call the given method
+// according to its auth scopes and the authorization policies of the server.
+func (ss *simpleServer) authorizeCall(ctx context.Context, fullMethod string) error {
+	if err := checkAuthScopes(ctx, fullMethod); err != nil {
+		return err
+	}
+	if ss.authorizer != nil {
+		return ss.authorizer.authorize(ctx, fullMethod)
+	}
+	return nil
+}
+
+// webrtcAuth verifies the access token sent along with a call tunneled over WebRTC, if
+// any. Connecting over WebRTC already required authenticating to the signaler, so calls
+// without a token are allowed through. Their entity, if only signaled, is not verified
+// and so the returned context to authorize the call with has none.
+func (ss *simpleServer) webrtcAuth(ctx context.Context) (nextCtx, authzCtx context.Context, err error) {
+	if _, err := tokenFromContext(ctx); err != nil {
+		if contextHasSignaledAuthEntity(ctx) {
+			return ctx, ContextWithAuthEntity(ctx, EntityInfo{}), nil
+		}
+		return ctx, ctx, nil
+	}
+	nextCtx, err = ss.ensureAuthed(ctx)
+	return nextCtx, nextCtx, err
+}
+
+func (ss *simpleServer) webrtcAuthUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if ss.exemptMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	nextCtx, authzCtx, err := ss.webrtcAuth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ss.isPublicMethod(info.FullMethod) {
+		if err := ss.authorizeCall(authzCtx, info.FullMethod); err != nil {
+			return nil, err
+		}
+	}
+	return handler(nextCtx, req)
+}
+
+func (ss *simpleServer) webrtcAuthStreamInterceptor(
+	srv interface{},
+	serverStream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if ss.exemptMethods[info.FullMethod] {
+		return handler(srv, serverStream)
+	}
+	nextCtx, authzCtx, err := ss.webrtcAuth(serverStream.Context())
+	if err != nil {
+		return err
+	}
+	if !ss.isPublicMethod(info.FullMethod) {
+		if err := ss.authorizeCall(authzCtx, info.FullMethod); err != nil {
+			return err
+		}
+	}
+	return handler(srv, ctxWrappedServerStream{serverStream, nextCtx})
+}
+
 type ctxWrappedServerStream struct {
 	grpc.ServerStream
 	ctx context.Context
@@ -271,6 +416,20 @@ var validSigningMethods = []string{
 	"RS256",
 }
 
+// authenticateHTTPRequest authenticates a request served over plain HTTP, outside of
+// gRPC, by its authorization header or verified client certificate.
+func (ss *simpleServer) authenticateHTTPRequest(r *http.Request) (context.Context, error) {
+	md := metadata.MD{}
+	if authHeader := r.Header.Get(MetadataFieldAuthorization); authHeader != "" {
+		md.Set(MetadataFieldAuthorization, authHeader)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	if r.TLS != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *r.TLS}})
+	}
+	return ss.ensureAuthed(ctx)
+}
+
 // tryAuth is called for public methods where auth is not required but preferable.
 func (ss *simpleServer) tryAuth(ctx context.Context) (context.Context, error) {
 	nextCtx, err := ss.ensureAuthed(ctx)
@@ -314,13 +473,23 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (context.Context, erro
 			nextCtx := ContextWithAuthEntity(ctx, EntityInfo{
 				Entity: verifiedCert.Issuer.String() + ":" + verifiedCert.SerialNumber.String(),
 			})
-			return nextCtx, nil
+			return contextWithClientCertificate(nextCtx, verifiedCert), nil
 		} else if !errors.Is(tlsErr, errNotTLSAuthed) {
 			return nil, multierr.Combine(err, tlsErr)
 		}
 		return nil, err
 	}
 
+	nextCtx, err := ss.verifyAccessToken(ctx, tokenString)
+	if status.Code(err) == codes.Unauthenticated {
+		ss.metrics.authFailed(authFailureAccessToken)
+	}
+	return nextCtx, err
+}
+
+// verifyAccessToken returns a context with the entity and claims of the access token if it
+// is valid and meant for this server.
+func (ss *simpleServer) verifyAccessToken(ctx context.Context, tokenString string) (context.Context, error) {
 	var claims JWTClaims
 	var handlers credAuthHandlers
 	if _, err := jwt.ParseWithClaims(
@@ -338,11 +507,8 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (context.Context, erro
 			}
 
 			// signed internally
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
-			}
-
-			return &ss.authRSAPrivKey.PublicKey, nil
+			kid, _ := token.Header["kid"].(string)
+			return ss.authKeys.verificationKey(kid, token.Method)
 		},
 		jwt.WithValidMethods(validSigningMethods),
 	); err != nil {
@@ -366,8 +532,7 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (context.Context, erro
 	// Note(erd): may want to verify issuers in the future where the claims/scope are
 	// treated differently if it comes down to permissions encoded in a JWT.
 
-	err = claims.Valid()
-	if err != nil {
+	if err := claims.Valid(); err != nil {
 		return nil, status.Errorf(codes.Unauthenticated, "unauthenticated: %s", err)
 	}
 
@@ -388,5 +553,5 @@ func (ss *simpleServer) ensureAuthed(ctx context.Context) (context.Context, erro
 		entityData = data
 	}
 
-	return ContextWithAuthEntity(ctx, EntityInfo{claimsEntity, entityData}), nil
+	return contextWithAuthClaims(ContextWithAuthEntity(ctx, EntityInfo{claimsEntity, entityData}), claims), nil
 }
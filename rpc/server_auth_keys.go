@@ -0,0 +1,368 @@
+package rpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+	"go.viam.com/utils/jwks"
+)
+
+// AuthKeysPath is the HTTP path the server publishes the public keys that verify its
+// access tokens at when WithAuthKeysEndpoint is used.
+const AuthKeysPath = "/.well-known/jwks.json"
+
+// An AuthKey is a key the server signs access tokens with.
+type AuthKey struct {
+	// ID is the key ID (kid) of the key, derived from its public key (see PublicKeyThumbprint).
+	ID         string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+
+	// RetiredAt is when the key stopped being used for signing. It is zero for the current
+	// key. Retired keys continue to verify access tokens for the rotation overlap.
+	RetiredAt time.Time
+}
+
+// An AuthKeyStore persists the server's auth keys so that they survive restarts and can be
+// shared between replicas.
+type AuthKeyStore interface {
+	// LoadAuthKeys returns all stored keys, if any.
+	LoadAuthKeys(ctx context.Context) ([]AuthKey, error)
+
+	// SaveAuthKeys replaces the stored keys with the given ones.
+	SaveAuthKeys(ctx context.Context, keys []AuthKey) error
+}
+
+// AuthKeyRotationOptions configure how the server rotates the keys it signs access tokens with.
+type AuthKeyRotationOptions struct {
+	// Interval is how long a key is used for signing before it is replaced by a new one.
+	Interval time.Duration
+
+	// Overlap is how long a replaced key continues to verify (and be published for)
+	// access tokens it signed. It should be at least as long as access tokens are valid for.
+	// If zero, Interval is used.
+	Overlap time.Duration
+
+	// GenerateKey, if set, generates new keys. It defaults to generating RSA keys.
+	// See WithAuthPrivateKey for the supported key types.
+	GenerateKey func() (crypto.Signer, error)
+
+	// Store, if set, persists the keys. Keys are loaded from it when the server is
+	// created and saved to it on every rotation.
+	Store AuthKeyStore
+}
+
+// WithAuthKeyRotation returns a ServerOption which rotates the keys used to sign access
+// tokens on a schedule. If a key is set with WithAuthPrivateKey, it is used as the first key
+// unless keys are loaded from the store.
+func WithAuthKeyRotation(opts AuthKeyRotationOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if opts.Interval <= 0 {
+			return errors.New("expected positive rotation interval")
+		}
+		if opts.Overlap < 0 {
+			return errors.New("expected non-negative rotation overlap")
+		}
+		if opts.Overlap == 0 {
+			opts.Overlap = opts.Interval
+		}
+		o.authKeyRotation = &opts
+		return nil
+	})
+}
+
+// WithAuthKeysEndpoint returns a ServerOption which publishes the public keys that verify
+// the server's access tokens as a JSON Web Key Set at AuthKeysPath. This allows others to
+// verify them (e.g. with MakeJWKSKeyProvider).
+func WithAuthKeysEndpoint() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.publishAuthKeys = true
+		return nil
+	})
+}
+
+// authKeyRing holds the current signing key and the retired keys that can still verify.
+type authKeyRing struct {
+	rotation *AuthKeyRotationOptions
+	logger   golog.Logger
+
+	mu   sync.RWMutex
+	keys []AuthKey // current key first
+
+	cancel                  func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+func newAuthKey(privKey crypto.Signer) (AuthKey, error) {
+	kid, err := PublicKeyThumbprint(privKey.Public())
+	if err != nil {
+		return AuthKey{}, err
+	}
+	return AuthKey{ID: kid, PrivateKey: privKey, CreatedAt: time.Now()}, nil
+}
+
+func generateDefaultAuthKey() (crypto.Signer, error) {
+	return rsa.GenerateKey(rand.Reader, generatedRSAKeyBits)
+}
+
+// newAuthKeyRing returns a key ring starting with the given key, if any, unless keys are
+// loaded from the rotation's store.
+func newAuthKeyRing(
+	ctx context.Context,
+	privKey crypto.Signer,
+	rotation *AuthKeyRotationOptions,
+	logger golog.Logger,
+) (*authKeyRing, error) {
+	ring := &authKeyRing{rotation: rotation, logger: logger}
+	if rotation != nil && rotation.Store != nil {
+		keys, err := rotation.Store.LoadAuthKeys(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load auth keys")
+		}
+		for _, key := range keys {
+			if _, err := signingMethodForKey(key.PrivateKey); err != nil {
+				return nil, errors.Wrapf(err, "invalid stored auth key %q", key.ID)
+			}
+		}
+		ring.keys = ring.pruneKeys(keys, time.Now())
+	}
+	if len(ring.keys) != 0 && ring.keys[0].RetiredAt.IsZero() {
+		return ring, nil
+	}
+
+	if privKey == nil {
+		var err error
+		privKey, err = ring.generateKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := signingMethodForKey(privKey); err != nil {
+		return nil, err
+	}
+	key, err := newAuthKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	ring.keys = append([]AuthKey{key}, ring.keys...)
+	if rotation != nil && rotation.Store != nil {
+		if err := rotation.Store.SaveAuthKeys(ctx, ring.keys); err != nil {
+			return nil, errors.Wrap(err, "failed to save auth keys")
+		}
+	}
+	return ring, nil
+}
+
+func (ring *authKeyRing) generateKey() (crypto.Signer, error) {
+	if ring.rotation != nil && ring.rotation.GenerateKey != nil {
+		return ring.rotation.GenerateKey()
+	}
+	return generateDefaultAuthKey()
+}
+
+// pruneKeys orders the keys with the current one first and drops retired keys past the
+// rotation overlap.
+func (ring *authKeyRing) pruneKeys(keys []AuthKey, now time.Time) []AuthKey {
+	var current []AuthKey
+	var retired []AuthKey
+	for _, key := range keys {
+		switch {
+		case key.RetiredAt.IsZero():
+			current = append(current, key)
+		case ring.rotation != nil && now.Before(key.RetiredAt.Add(ring.rotation.Overlap)):
+			retired = append(retired, key)
+		}
+	}
+	// only the newest unretired key stays current.
+	for i := 1; i < len(current); i++ {
+		if current[i].CreatedAt.After(current[0].CreatedAt) {
+			current[0], current[i] = current[i], current[0]
+		}
+	}
+	for i := 1; i < len(current); i++ {
+		current[i].RetiredAt = now
+		retired = append(retired, current[i])
+	}
+	if len(current) == 0 {
+		return retired
+	}
+	return append([]AuthKey{current[0]}, retired...)
+}
+
+// signingKey returns the current key along with the method to sign with it.
+func (ring *authKeyRing) signingKey() (AuthKey, jwt.SigningMethod, error) {
+	ring.mu.RLock()
+	key := ring.keys[0]
+	ring.mu.RUnlock()
+	method, err := signingMethodForKey(key.PrivateKey)
+	return key, method, err
+}
+
+// verificationKey returns the public key to verify a token signed by the key with the
+// given ID, or the current key if there is no ID.
+func (ring *authKeyRing) verificationKey(kid string, method jwt.SigningMethod) (crypto.PublicKey, error) {
+	ring.mu.RLock()
+	keys := ring.keys
+	ring.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		if kid != "" && key.ID != kid {
+			continue
+		}
+		if !key.RetiredAt.IsZero() && !now.Before(key.RetiredAt.Add(ring.rotation.Overlap)) {
+			break
+		}
+		keyMethod, err := signingMethodForKey(key.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		if method.Alg() != keyMethod.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q", method.Alg())
+		}
+		return key.PrivateKey.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// nextRotation returns when the current key is due to be rotated.
+func (ring *authKeyRing) nextRotation() time.Time {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.keys[0].CreatedAt.Add(ring.rotation.Interval)
+}
+
+// rotate retires the current key in favor of a newly generated one.
+func (ring *authKeyRing) rotate(ctx context.Context) error {
+	privKey, err := ring.generateKey()
+	if err != nil {
+		return err
+	}
+	if _, err := signingMethodForKey(privKey); err != nil {
+		return err
+	}
+	newKey, err := newAuthKey(privKey)
+	if err != nil {
+		return err
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	now := time.Now()
+	newKey.CreatedAt = now
+	keys := make([]AuthKey, 0, len(ring.keys)+1)
+	keys = append(keys, newKey)
+	for _, key := range ring.keys {
+		if key.RetiredAt.IsZero() {
+			key.RetiredAt = now
+		}
+		keys = append(keys, key)
+	}
+	keys = ring.pruneKeys(keys, now)
+	if ring.rotation.Store != nil {
+		if err := ring.rotation.Store.SaveAuthKeys(ctx, keys); err != nil {
+			return errors.Wrap(err, "failed to save auth keys")
+		}
+	}
+	ring.keys = keys
+	ring.logger.Infow("rotated auth key", "kid", newKey.ID)
+	return nil
+}
+
+// authKeyRotationRetryInterval is how long to wait to rotate again after failing to.
+var authKeyRotationRetryInterval = time.Minute
+
+// startRotating rotates keys in the background until closed, if rotation is configured.
+func (ring *authKeyRing) startRotating() {
+	if ring.rotation == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ring.cancel = cancel
+	ring.activeBackgroundWorkers.Add(1)
+	utils.PanicCapturingGo(func() {
+		defer ring.activeBackgroundWorkers.Done()
+		for {
+			if !utils.SelectContextOrWaitUntil(ctx, ring.nextRotation()) {
+				return
+			}
+			if err := ring.rotate(ctx); err != nil {
+				ring.logger.Errorw("failed to rotate auth key", "error", err)
+				if !utils.SelectContextOrWait(ctx, authKeyRotationRetryInterval) {
+					return
+				}
+			}
+		}
+	})
+}
+
+func (ring *authKeyRing) close() {
+	if ring.cancel != nil {
+		ring.cancel()
+	}
+	ring.activeBackgroundWorkers.Wait()
+}
+
+// defaultAuthKeysMaxAge is how long published keys may be cached for when they are not rotated.
+const defaultAuthKeysMaxAge = time.Hour
+
+// ServeHTTP publishes the public keys that can verify access tokens. They may be cached until
+// the next rotation at which point a new key will be used.
+func (ring *authKeyRing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ring.mu.RLock()
+	now := time.Now()
+	pubKeys := make(map[string]crypto.PublicKey, len(ring.keys))
+	for _, key := range ring.keys {
+		if !key.RetiredAt.IsZero() && !now.Before(key.RetiredAt.Add(ring.rotation.Overlap)) {
+			continue
+		}
+		pubKeys[key.ID] = key.PrivateKey.Public()
+	}
+	ring.mu.RUnlock()
+
+	maxAge := defaultAuthKeysMaxAge
+	if ring.rotation != nil {
+		maxAge = time.Until(ring.nextRotation())
+		if maxAge < 0 {
+			maxAge = 0
+		}
+	}
+
+	keyset, err := jwks.NewPublicKeySet(pubKeys)
+	if err != nil {
+		ring.logger.Errorw("failed to make auth key set", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	out, err := json.Marshal(keyset)
+	if err != nil {
+		ring.logger.Errorw("failed to marshal auth key set", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(out); err != nil {
+		ring.logger.Debugw("failed to write auth key set", "error", err)
+	}
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/golang-jwt/jwt/v4"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/jwks"
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+type memoryAuthKeyStore struct {
+	mu   sync.Mutex
+	keys []AuthKey
+}
+
+func (store *memoryAuthKeyStore) LoadAuthKeys(ctx context.Context) ([]AuthKey, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return append([]AuthKey(nil), store.keys...), nil
+}
+
+func (store *memoryAuthKeyStore) SaveAuthKeys(ctx context.Context, keys []AuthKey) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.keys = append([]AuthKey(nil), keys...)
+	return nil
+}
+
+func (store *memoryAuthKeyStore) storedKeys() []AuthKey {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return append([]AuthKey(nil), store.keys...)
+}
+
+func generateECDSAAuthKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func TestAuthKeyRing(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	store := &memoryAuthKeyStore{}
+	rotation := &AuthKeyRotationOptions{
+		Interval:    time.Hour,
+		Overlap:     time.Hour,
+		GenerateKey: generateECDSAAuthKey,
+		Store:       store,
+	}
+
+	ring, err := newAuthKeyRing(context.Background(), nil, rotation, logger)
+	test.That(t, err, test.ShouldBeNil)
+	firstKey, method, err := ring.signingKey()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, method.Alg(), test.ShouldEqual, "ES256")
+	test.That(t, store.storedKeys(), test.ShouldHaveLength, 1)
+	test.That(t, ring.nextRotation(), test.ShouldEqual, firstKey.CreatedAt.Add(time.Hour))
+
+	// keys are loaded from the store
+	loadedRing, err := newAuthKeyRing(context.Background(), nil, rotation, logger)
+	test.That(t, err, test.ShouldBeNil)
+	loadedKey, _, err := loadedRing.signingKey()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, loadedKey.ID, test.ShouldEqual, firstKey.ID)
+
+	test.That(t, ring.rotate(context.Background()), test.ShouldBeNil)
+	secondKey, _, err := ring.signingKey()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, secondKey.ID, test.ShouldNotEqual, firstKey.ID)
+	storedKeys := store.storedKeys()
+	test.That(t, storedKeys, test.ShouldHaveLength, 2)
+	test.That(t, storedKeys[0].ID, test.ShouldEqual, secondKey.ID)
+	test.That(t, storedKeys[0].RetiredAt.IsZero(), test.ShouldBeTrue)
+	test.That(t, storedKeys[1].ID, test.ShouldEqual, firstKey.ID)
+	test.That(t, storedKeys[1].RetiredAt.IsZero(), test.ShouldBeFalse)
+
+	// retired keys verify within the overlap
+	for _, key := range []AuthKey{firstKey, secondKey} {
+		pubKey, err := ring.verificationKey(key.ID, jwt.SigningMethodES256)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pubKey, test.ShouldResemble, key.PrivateKey.Public())
+	}
+	pubKey, err := ring.verificationKey("", jwt.SigningMethodES256)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pubKey, test.ShouldResemble, secondKey.PrivateKey.Public())
+
+	_, err = ring.verificationKey(secondKey.ID, jwt.SigningMethodRS256)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unexpected signing method")
+	_, err = ring.verificationKey("unknown", jwt.SigningMethodES256)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown key")
+
+	// and are dropped after it
+	ring.mu.Lock()
+	ring.keys[1].RetiredAt = time.Now().Add(-2 * time.Hour)
+	ring.mu.Unlock()
+	_, err = ring.verificationKey(firstKey.ID, jwt.SigningMethodES256)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, ring.rotate(context.Background()), test.ShouldBeNil)
+	storedKeys = store.storedKeys()
+	test.That(t, storedKeys, test.ShouldHaveLength, 2)
+	test.That(t, storedKeys[1].ID, test.ShouldEqual, secondKey.ID)
+
+	_, err = newAuthKeyRing(context.Background(), nil, &AuthKeyRotationOptions{
+		Interval: time.Hour,
+		Overlap:  time.Hour,
+		GenerateKey: func() (crypto.Signer, error) {
+			return nil, fmt.Errorf("whoops")
+		},
+	}, logger)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "whoops")
+
+	var sOpts serverOptions
+	test.That(t, WithAuthKeyRotation(AuthKeyRotationOptions{}).apply(&sOpts), test.ShouldNotBeNil)
+	test.That(t, WithAuthKeyRotation(AuthKeyRotationOptions{Interval: time.Minute}).apply(&sOpts), test.ShouldBeNil)
+	test.That(t, sOpts.authKeyRotation.Overlap, test.ShouldEqual, time.Minute)
+}
+
+func TestServerAuthKeyRotation(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+		WithAuthKeyRotation(AuthKeyRotationOptions{
+			Interval:    2 * time.Second,
+			Overlap:     time.Minute,
+			GenerateKey: generateECDSAAuthKey,
+		}),
+		WithAuthKeysEndpoint(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	jwksURL := fmt.Sprintf("http://%s%s", httpListener.Addr().String(), AuthKeysPath)
+	fetchKeySet := func(tb testing.TB) jwks.KeySet {
+		tb.Helper()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, jwksURL, nil)
+		test.That(tb, err, test.ShouldBeNil)
+		resp, err := http.DefaultClient.Do(req)
+		test.That(tb, err, test.ShouldBeNil)
+		defer func() {
+			test.That(tb, resp.Body.Close(), test.ShouldBeNil)
+		}()
+		test.That(tb, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+		test.That(tb, resp.Header.Get("Cache-Control"), test.ShouldStartWith, "public, max-age=")
+		body, err := io.ReadAll(resp.Body)
+		test.That(tb, err, test.ShouldBeNil)
+		keyset, err := jwks.ParseKeySet(string(body))
+		test.That(tb, err, test.ShouldBeNil)
+		return keyset
+	}
+	test.That(t, fetchKeySet(t).Len(), test.ShouldEqual, 1)
+
+	authenticate := func(t *testing.T) (string, string) {
+		t.Helper()
+		conn, err := Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithEntityCredentials("foo", Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		accessToken, err := conn.(ClientConnAuthenticator).Authenticate(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		token, _, err := jwt.NewParser().ParseUnverified(accessToken, &JWTClaims{})
+		test.That(t, err, test.ShouldBeNil)
+		return accessToken, token.Header["kid"].(string)
+	}
+	echoWith := func(t *testing.T, accessToken string) error {
+		t.Helper()
+		conn, err := Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithStaticAuthenticationMaterial(accessToken),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		return err
+	}
+
+	firstToken, firstKID := authenticate(t)
+	_, ok := fetchKeySet(t).LookupKeyID(firstKID)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	jwkProvider, err := jwks.NewCachingJWKKeyProvider(context.Background(), jwksURL)
+	test.That(t, err, test.ShouldBeNil)
+	keyProvider := MakeJWKSKeyProvider(jwkProvider)
+	defer func() {
+		test.That(t, keyProvider.Close(context.Background()), test.ShouldBeNil)
+	}()
+
+	testutils.WaitForAssertionWithSleep(t, 100*time.Millisecond, 100, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, fetchKeySet(tb).Len(), test.ShouldEqual, 2)
+	})
+
+	secondToken, secondKID := authenticate(t)
+	test.That(t, secondKID, test.ShouldNotEqual, firstKID)
+
+	// tokens signed by either key are accepted
+	test.That(t, echoWith(t, firstToken), test.ShouldBeNil)
+	test.That(t, echoWith(t, secondToken), test.ShouldBeNil)
+
+	// the new key is picked up by others verifying with the published keys
+	for _, accessToken := range []string{firstToken, secondToken} {
+		_, err = jwt.ParseWithClaims(accessToken, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+			return keyProvider.TokenVerificationKey(context.Background(), token)
+		})
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	// tokens from keys the server does not know are rejected
+	otherKey, err := generateECDSAAuthKey()
+	test.That(t, err, test.ShouldBeNil)
+	forged := jwt.NewWithClaims(jwt.SigningMethodES256, JWTClaims{
+		RegisteredClaims:    jwt.RegisteredClaims{Subject: "foo", Audience: rpcServer.InstanceNames()},
+		AuthCredentialsType: CredentialsTypeAPIKey,
+	})
+	forged.Header["kid"] = "other"
+	forgedToken, err := forged.SignedString(otherKey)
+	test.That(t, err, test.ShouldBeNil)
+	err = echoWith(t, forgedToken)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown key")
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -0,0 +1,329 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	mongoutils "go.viam.com/utils/mongo"
+	"go.viam.com/utils/ratelimit"
+)
+
+func init() {
+	mongoutils.MustRegisterNamespace(&mongodbAuthRateLimitStoreDBName, &mongodbAuthRateLimitStoreCollName)
+}
+
+// AuthRateLimitOptions configure how attempts to authenticate are limited. Attempts are
+// limited separately for each entity and for each peer address with a token bucket.
+type AuthRateLimitOptions struct {
+	// Rate is how many attempts per second are replenished.
+	Rate float64
+
+	// Burst is the most attempts that may be made at once.
+	Burst int
+
+	// MaxFailures is how many consecutive failed attempts for an entity or from a peer
+	// address lock it out for LockoutDuration. If zero, there is no lockout.
+	MaxFailures int
+
+	// LockoutDuration is how long an entity or peer address is locked out for.
+	LockoutDuration time.Duration
+
+	// Store holds the state of the limiter. If nil, the state is kept in memory. Use a
+	// shared store (e.g. NewMongoDBAuthRateLimitStore) to limit across replicas.
+	Store AuthRateLimitStore
+}
+
+// AuthRateLimitState is the state of the limiter for a single entity or peer address.
+type AuthRateLimitState struct {
+	Tokens      float64   `bson:"tokens"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+	Failures    int       `bson:"failures"`
+	LockedUntil time.Time `bson:"locked_until"`
+	// ExpiresAt is when the state no longer matters and may be forgotten.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (state AuthRateLimitState) tokenBucket() ratelimit.TokenBucketState {
+	return ratelimit.TokenBucketState{Tokens: state.Tokens, UpdatedAt: state.UpdatedAt}
+}
+
+func (state AuthRateLimitState) withTokenBucket(bucket ratelimit.TokenBucketState) AuthRateLimitState {
+	state.Tokens = bucket.Tokens
+	state.UpdatedAt = bucket.UpdatedAt
+	return state
+}
+
+// An AuthRateLimitStore holds the state of an authentication rate limiter.
+type AuthRateLimitStore interface {
+	// UpdateAuthRateLimitState atomically replaces the state for the given key with the
+	// result of calling update on its current state (the zero value if there is none or it
+	// has expired) and returns the new state. update may be called more than once.
+	UpdateAuthRateLimitState(
+		ctx context.Context,
+		key string,
+		update func(state AuthRateLimitState) AuthRateLimitState,
+	) (AuthRateLimitState, error)
+}
+
+// WithAuthRateLimit returns a ServerOption which limits how often Authenticate may be
+// called for each entity and from each peer address, locking either out after too many
+// failures. If the limiter's store cannot be used, attempts are rejected.
+func WithAuthRateLimit(opts AuthRateLimitOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if err := (ratelimit.TokenBucketLimit{Rate: opts.Rate, Burst: opts.Burst}).Validate(); err != nil {
+			return err
+		}
+		if opts.MaxFailures < 0 {
+			return errors.New("expected non-negative max failures")
+		}
+		if opts.MaxFailures > 0 && opts.LockoutDuration <= 0 {
+			return errors.New("expected positive lockout duration")
+		}
+		if opts.Store == nil {
+			opts.Store = NewMemoryAuthRateLimitStore()
+		}
+		o.authRateLimit = &opts
+		return nil
+	})
+}
+
+// authRateLimiter limits attempts to authenticate.
+type authRateLimiter struct {
+	opts AuthRateLimitOptions
+}
+
+// authRateLimitKeys returns the keys that an attempt to authenticate as the entity is
+// limited by.
+func authRateLimitKeys(ctx context.Context, entity string) (entityKey, peerKey string) {
+	entityKey = "entity:" + entity
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		peerKey = "peer:" + addr
+	}
+	return entityKey, peerKey
+}
+
+// bucket returns the token bucket the state of a key is limited by.
+func (l *authRateLimiter) bucket() ratelimit.TokenBucketLimit {
+	return ratelimit.TokenBucketLimit{Rate: l.opts.Rate, Burst: l.opts.Burst}
+}
+
+// replenish returns the state as of now with tokens added since it was last updated.
+func (l *authRateLimiter) replenish(state AuthRateLimitState, now time.Time) AuthRateLimitState {
+	if !state.UpdatedAt.IsZero() && !now.Before(state.ExpiresAt) {
+		state = AuthRateLimitState{}
+	}
+	return state.withTokenBucket(l.bucket().Replenish(state.tokenBucket(), now))
+}
+
+// withExpiration sets when the state is no longer needed, which is once the bucket is
+// full again and any lockout is over. Failures are forgotten along with it.
+func (l *authRateLimiter) withExpiration(state AuthRateLimitState) AuthRateLimitState {
+	state.ExpiresAt = state.UpdatedAt.Add(l.bucket().UntilFull(state.tokenBucket()))
+	if l.opts.MaxFailures > 0 {
+		// keep failures around for at least as long as a lockout would last.
+		state.ExpiresAt = state.ExpiresAt.Add(l.opts.LockoutDuration)
+	}
+	if state.LockedUntil.After(state.ExpiresAt) {
+		state.ExpiresAt = state.LockedUntil
+	}
+	return state
+}
+
+// allow takes an attempt from each key, returning an error if any of them has none left
+// or is locked out.
+func (l *authRateLimiter) allow(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		var retryAfter time.Duration
+		if _, err := l.opts.Store.UpdateAuthRateLimitState(ctx, key, func(state AuthRateLimitState) AuthRateLimitState {
+			now := time.Now()
+			state = l.replenish(state, now)
+			if now.Before(state.LockedUntil) {
+				retryAfter = state.LockedUntil.Sub(now)
+				return l.withExpiration(state)
+			}
+			bucket, wait := l.bucket().Take(state.tokenBucket(), now, 1)
+			retryAfter = wait
+			return l.withExpiration(state.withTokenBucket(bucket))
+		}); err != nil {
+			return status.Errorf(codes.Unavailable, "failed to check authentication rate limit: %s", err)
+		}
+		if retryAfter > 0 {
+			return status.Errorf(
+				codes.ResourceExhausted,
+				"too many authentication attempts; try again in %s",
+				retryAfter.Round(time.Millisecond),
+			)
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt against each key, locking out those that failed
+// too many times in a row.
+func (l *authRateLimiter) recordFailure(ctx context.Context, keys ...string) error {
+	var errs error
+	for _, key := range keys {
+		if key == "" || l.opts.MaxFailures == 0 {
+			continue
+		}
+		if _, err := l.opts.Store.UpdateAuthRateLimitState(ctx, key, func(state AuthRateLimitState) AuthRateLimitState {
+			now := time.Now()
+			state = l.replenish(state, now)
+			state.Failures++
+			if state.Failures >= l.opts.MaxFailures {
+				state.Failures = 0
+				state.LockedUntil = now.Add(l.opts.LockoutDuration)
+			}
+			return l.withExpiration(state)
+		}); err != nil {
+			errs = multierr.Combine(errs, errors.Wrapf(err, "failed to record authentication failure for %q", key))
+		}
+	}
+	return errs
+}
+
+// recordSuccess resets the consecutive failures of the key. Only the entity's failures
+// are reset so that a peer cannot clear its failures by authenticating as an entity it owns.
+func (l *authRateLimiter) recordSuccess(ctx context.Context, key string) error {
+	_, err := l.opts.Store.UpdateAuthRateLimitState(ctx, key, func(state AuthRateLimitState) AuthRateLimitState {
+		state = l.replenish(state, time.Now())
+		state.Failures = 0
+		return l.withExpiration(state)
+	})
+	return err
+}
+
+// memoryAuthRateLimitStoreMaxEntries is how many entries the memory store holds before
+// it starts forgetting expired ones.
+const memoryAuthRateLimitStoreMaxEntries = 4096
+
+// NewMemoryAuthRateLimitStore returns a new in-memory authentication rate limit store.
+func NewMemoryAuthRateLimitStore() AuthRateLimitStore {
+	return &memoryAuthRateLimitStore{states: map[string]AuthRateLimitState{}}
+}
+
+type memoryAuthRateLimitStore struct {
+	mu     sync.Mutex
+	states map[string]AuthRateLimitState
+}
+
+func (store *memoryAuthRateLimitStore) UpdateAuthRateLimitState(
+	ctx context.Context,
+	key string,
+	update func(state AuthRateLimitState) AuthRateLimitState,
+) (AuthRateLimitState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	now := time.Now()
+	state, ok := store.states[key]
+	if ok && !now.Before(state.ExpiresAt) {
+		state = AuthRateLimitState{}
+	}
+	if !ok && len(store.states) >= memoryAuthRateLimitStoreMaxEntries {
+		for otherKey, otherState := range store.states {
+			if !now.Before(otherState.ExpiresAt) {
+				delete(store.states, otherKey)
+			}
+		}
+	}
+	state = update(state)
+	store.states[key] = state
+	return state, nil
+}
+
+// Database and collection names used by the mongoDBAuthRateLimitStore.
+var (
+	mongodbAuthRateLimitStoreDBName   = "rpc"
+	mongodbAuthRateLimitStoreCollName = "auth_rate_limits"
+)
+
+var mongodbAuthRateLimitStoreExpireName = "expires_at_1"
+
+type mongodbAuthRateLimitDoc struct {
+	ID      string             `bson:"_id"`
+	Version int64              `bson:"version"`
+	State   AuthRateLimitState `bson:",inline"`
+}
+
+// NewMongoDBAuthRateLimitStore returns a new authentication rate limit store backed by the
+// given MongoDB client so that it may be shared across replicas.
+func NewMongoDBAuthRateLimitStore(ctx context.Context, client *mongo.Client) (AuthRateLimitStore, error) {
+	coll := client.Database(mongodbAuthRateLimitStoreDBName).Collection(mongodbAuthRateLimitStoreCollName)
+	expireAfterSecondsZero := int32(0)
+	if err := mongoutils.EnsureIndexes(ctx, coll, mongo.IndexModel{
+		Keys: bson.D{{"expires_at", 1}},
+		Options: &options.IndexOptions{
+			Name:               &mongodbAuthRateLimitStoreExpireName,
+			ExpireAfterSeconds: &expireAfterSecondsZero,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return &mongoDBAuthRateLimitStore{coll: coll}, nil
+}
+
+type mongoDBAuthRateLimitStore struct {
+	coll *mongo.Collection
+}
+
+func (store *mongoDBAuthRateLimitStore) UpdateAuthRateLimitState(
+	ctx context.Context,
+	key string,
+	update func(state AuthRateLimitState) AuthRateLimitState,
+) (AuthRateLimitState, error) {
+	// optimistically update the state, retrying if another replica changed it first.
+	for {
+		if err := ctx.Err(); err != nil {
+			return AuthRateLimitState{}, err
+		}
+		var doc mongodbAuthRateLimitDoc
+		exists := true
+		if err := store.coll.FindOne(ctx, bson.D{{"_id", key}}).Decode(&doc); err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				return AuthRateLimitState{}, err
+			}
+			exists = false
+		}
+		state := doc.State
+		if exists && !time.Now().Before(state.ExpiresAt) {
+			state = AuthRateLimitState{}
+		}
+		newDoc := mongodbAuthRateLimitDoc{ID: key, Version: doc.Version + 1, State: update(state)}
+
+		if !exists {
+			if _, err := store.coll.InsertOne(ctx, newDoc); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					continue
+				}
+				return AuthRateLimitState{}, err
+			}
+			return newDoc.State, nil
+		}
+		result, err := store.coll.ReplaceOne(ctx, bson.D{{"_id", key}, {"version", doc.Version}}, newDoc)
+		if err != nil {
+			return AuthRateLimitState{}, err
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+		return newDoc.State, nil
+	}
+}
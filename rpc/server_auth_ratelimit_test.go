@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	"go.viam.com/utils/testutils"
+)
+
+func testAuthRateLimitStore(t *testing.T, store AuthRateLimitStore) {
+	t.Helper()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	state, err := store.UpdateAuthRateLimitState(context.Background(), "key1", func(state AuthRateLimitState) AuthRateLimitState {
+		test.That(t, state, test.ShouldResemble, AuthRateLimitState{})
+		state.Failures = 1
+		state.ExpiresAt = expiresAt
+		return state
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, state.Failures, test.ShouldEqual, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.UpdateAuthRateLimitState(context.Background(), "key1", func(state AuthRateLimitState) AuthRateLimitState {
+				state.Failures++
+				return state
+			})
+			test.That(t, err, test.ShouldBeNil)
+		}()
+	}
+	wg.Wait()
+
+	state, err = store.UpdateAuthRateLimitState(context.Background(), "key1", func(state AuthRateLimitState) AuthRateLimitState {
+		return state
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, state.Failures, test.ShouldEqual, 11)
+	test.That(t, state.ExpiresAt.Equal(expiresAt), test.ShouldBeTrue)
+
+	// keys are independent
+	state, err = store.UpdateAuthRateLimitState(context.Background(), "key2", func(state AuthRateLimitState) AuthRateLimitState {
+		test.That(t, state.Failures, test.ShouldEqual, 0)
+		state.Failures = 5
+		state.ExpiresAt = time.Now().Add(-time.Second)
+		return state
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, state.Failures, test.ShouldEqual, 5)
+
+	// expired states are forgotten
+	_, err = store.UpdateAuthRateLimitState(context.Background(), "key2", func(state AuthRateLimitState) AuthRateLimitState {
+		test.That(t, state, test.ShouldResemble, AuthRateLimitState{})
+		return state
+	})
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestMemoryAuthRateLimitStore(t *testing.T) {
+	testAuthRateLimitStore(t, NewMemoryAuthRateLimitStore())
+}
+
+func TestMongoDBAuthRateLimitStore(t *testing.T) {
+	client := testutils.BackingMongoDBClient(t)
+	test.That(t, client.Database(mongodbAuthRateLimitStoreDBName).Collection(mongodbAuthRateLimitStoreCollName).Drop(context.Background()),
+		test.ShouldBeNil)
+	store, err := NewMongoDBAuthRateLimitStore(context.Background(), client)
+	test.That(t, err, test.ShouldBeNil)
+	testAuthRateLimitStore(t, store)
+}
+
+func TestAuthRateLimiter(t *testing.T) {
+	limiter := &authRateLimiter{opts: AuthRateLimitOptions{
+		Rate:            10,
+		Burst:           2,
+		MaxFailures:     3,
+		LockoutDuration: time.Hour,
+		Store:           NewMemoryAuthRateLimitStore(),
+	}}
+
+	t.Run("burst", func(t *testing.T) {
+		test.That(t, limiter.allow(context.Background(), "burst"), test.ShouldBeNil)
+		test.That(t, limiter.allow(context.Background(), "burst"), test.ShouldBeNil)
+		err := limiter.allow(context.Background(), "burst")
+		test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "too many authentication attempts")
+
+		// other keys are unaffected
+		test.That(t, limiter.allow(context.Background(), "other", ""), test.ShouldBeNil)
+		test.That(t, status.Code(limiter.allow(context.Background(), "other", "burst")), test.ShouldEqual, codes.ResourceExhausted)
+
+		// attempts are replenished over time
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, limiter.allow(context.Background(), "burst"), test.ShouldBeNil)
+		})
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			test.That(t, limiter.recordFailure(context.Background(), "lockout"), test.ShouldBeNil)
+		}
+		test.That(t, limiter.recordSuccess(context.Background(), "lockout"), test.ShouldBeNil)
+		for i := 0; i < 2; i++ {
+			test.That(t, limiter.recordFailure(context.Background(), "lockout"), test.ShouldBeNil)
+		}
+		test.That(t, limiter.allow(context.Background(), "lockout"), test.ShouldBeNil)
+
+		test.That(t, limiter.recordFailure(context.Background(), "lockout"), test.ShouldBeNil)
+		err := limiter.allow(context.Background(), "lockout")
+		test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "try again in")
+	})
+
+	var sOpts serverOptions
+	test.That(t, WithAuthRateLimit(AuthRateLimitOptions{}).apply(&sOpts), test.ShouldNotBeNil)
+	test.That(t, WithAuthRateLimit(AuthRateLimitOptions{Rate: 1}).apply(&sOpts), test.ShouldNotBeNil)
+	test.That(t, WithAuthRateLimit(AuthRateLimitOptions{Rate: 1, Burst: 1, MaxFailures: 1}).apply(&sOpts), test.ShouldNotBeNil)
+	test.That(t, WithAuthRateLimit(AuthRateLimitOptions{Rate: 1, Burst: 1}).apply(&sOpts), test.ShouldBeNil)
+	test.That(t, sOpts.authRateLimit.Store, test.ShouldNotBeNil)
+}
+
+func TestServerAuthRateLimit(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleMultiAuthHandler([]string{"foo", "other"}, []string{"bar"})),
+		WithAuthRateLimit(AuthRateLimitOptions{
+			Rate:            0.01,
+			Burst:           10,
+			MaxFailures:     3,
+			LockoutDuration: time.Hour,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		httpListener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	authClient := rpcpb.NewAuthServiceClient(conn)
+	authenticate := func(entity, payload string) error {
+		_, err := authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+			Entity: entity,
+			Credentials: &rpcpb.Credentials{
+				Type:    string(CredentialsTypeAPIKey),
+				Payload: payload,
+			},
+		})
+		return err
+	}
+
+	test.That(t, status.Code(authenticate("foo", "wrong")), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, status.Code(authenticate("foo", "wrong")), test.ShouldEqual, codes.Unauthenticated)
+	test.That(t, authenticate("foo", "bar"), test.ShouldBeNil)
+
+	// succeeding only resets the entity's failures, not those of the peer
+	test.That(t, status.Code(authenticate("other", "wrong")), test.ShouldEqual, codes.Unauthenticated)
+	err = authenticate("foo", "bar")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
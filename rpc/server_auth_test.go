@@ -2,6 +2,10 @@ package rpc
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
@@ -1266,3 +1270,147 @@ func TestServerAuthToHandlerWithExternalAuthOIDCTokenVerifier(t *testing.T) {
 	err = <-errChan
 	test.That(t, err, test.ShouldBeNil)
 }
+
+func TestServerAuthPrivateKeyTypes(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, tc := range []struct {
+		privKey crypto.Signer
+		alg     string
+	}{
+		{ecKey, "ES256"},
+		{edKey, "EdDSA"},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			rpcServer, err := NewServer(
+				logger,
+				WithDisableMulticastDNS(),
+				WithAuthHandler(CredentialsTypeAPIKey, MakeSimpleAuthHandler([]string{"foo"}, "bar")),
+				WithAuthPrivateKey(tc.privKey),
+			)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, rpcServer.RegisterServiceServer(
+				context.Background(),
+				&pb.EchoService_ServiceDesc,
+				&echoserver.Server{},
+				pb.RegisterEchoServiceHandlerFromEndpoint,
+			), test.ShouldBeNil)
+
+			httpListener, err := net.Listen("tcp", "localhost:0")
+			test.That(t, err, test.ShouldBeNil)
+			errChan := make(chan error)
+			go func() {
+				errChan <- rpcServer.Serve(httpListener)
+			}()
+
+			conn, err := Dial(
+				context.Background(),
+				httpListener.Addr().String(),
+				logger,
+				WithInsecure(),
+				WithForceDirectGRPC(),
+				WithEntityCredentials("foo", Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}),
+			)
+			test.That(t, err, test.ShouldBeNil)
+
+			accessToken, err := conn.(ClientConnAuthenticator).Authenticate(context.Background())
+			test.That(t, err, test.ShouldBeNil)
+			token, _, err := jwt.NewParser().ParseUnverified(accessToken, &JWTClaims{})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, token.Method.Alg(), test.ShouldEqual, tc.alg)
+			expectedKID, err := PublicKeyThumbprint(tc.privKey.Public())
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, token.Header["kid"], test.ShouldEqual, expectedKID)
+
+			echoResp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+			test.That(t, conn.Close(), test.ShouldBeNil)
+
+			// tokens signed with another algorithm are rejected
+			//nolint:gosec
+			rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
+			test.That(t, err, test.ShouldBeNil)
+			forgedToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, token.Claims).SignedString(rsaKey)
+			test.That(t, err, test.ShouldBeNil)
+			conn, err = Dial(
+				context.Background(),
+				httpListener.Addr().String(),
+				logger,
+				WithInsecure(),
+				WithForceDirectGRPC(),
+				WithStaticAuthenticationMaterial(forgedToken),
+			)
+			test.That(t, err, test.ShouldBeNil)
+			_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+			test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+			test.That(t, err.Error(), test.ShouldContainSubstring, "unexpected signing method")
+			test.That(t, conn.Close(), test.ShouldBeNil)
+
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+			test.That(t, <-errChan, test.ShouldBeNil)
+		})
+	}
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = NewServer(logger, WithAuthPrivateKey(p384Key))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported ECDSA curve")
+}
+
+func TestServerSignAccessToken(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := NewServer(logger, WithAuthAudience("aud1"))
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	_, err = rpcServer.SignAccessToken(CredentialsTypeWebSession, "", time.Minute, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = rpcServer.SignAccessToken(CredentialsTypeWebSession, "alice", 0, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = rpcServer.SignAccessToken("unknown", "alice", time.Minute, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = rpcServer.SignAccessToken(credentialsTypeInternal, "alice", time.Minute, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	token, err := rpcServer.SignAccessToken(CredentialsTypeWebSession, "alice", time.Minute, map[string]string{"a": "b"})
+	test.That(t, err, test.ShouldBeNil)
+	claims, err := rpcServer.VerifyAccessToken(context.Background(), token)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.Entity(), test.ShouldEqual, "alice")
+	test.That(t, claims.CredentialsType(), test.ShouldEqual, CredentialsTypeWebSession)
+	test.That(t, claims.Metadata(), test.ShouldResemble, map[string]string{"a": "b"})
+	test.That(t, claims.Audience, test.ShouldResemble, jwt.ClaimStrings{"aud1"})
+	test.That(t, claims.ExpiresAt.Time, test.ShouldHappenWithin, time.Minute+time.Second, time.Now())
+
+	_, err = rpcServer.VerifyAccessToken(context.Background(), token+"x")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+
+	// web session tokens cannot be obtained by authenticating.
+	_, err = rpcServer.(*simpleServer).Authenticate(
+		metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+		&rpcpb.AuthenticateRequest{
+			Entity:      "alice",
+			Credentials: &rpcpb.Credentials{Type: string(CredentialsTypeWebSession), Payload: "alice"},
+		},
+	)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.Unimplemented)
+
+	unauthServer, err := NewServer(logger, WithUnauthenticated())
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, unauthServer.Stop(), test.ShouldBeNil)
+	}()
+	_, err = unauthServer.SignAccessToken(CredentialsTypeWebSession, "alice", time.Minute, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = unauthServer.VerifyAccessToken(context.Background(), token)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// An AuthorizationPolicy allows the authenticated entities it applies to to call
+// the methods it lists. Patterns are matched with path.Match.
+type AuthorizationPolicy struct {
+	// Entities are patterns of the entities the policy applies to. If empty, it
+	// applies to all entities.
+	Entities []string
+
+	// AuthMetadata are auth metadata (see Claims.Metadata) entries an entity must
+	// all have with exactly these values for the policy to apply to it.
+	AuthMetadata map[string]string
+
+	// Methods are patterns of the full gRPC method names (e.g. "/proto.rpc.examples.echo.v1.EchoService/*")
+	// that may be called.
+	Methods []string
+}
+
+func (p AuthorizationPolicy) validate() error {
+	for _, pattern := range append(append([]string{}, p.Entities...), p.Methods...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// appliesTo returns whether the policy applies to the given entity and its auth metadata.
+func (p AuthorizationPolicy) appliesTo(entity string, authMD map[string]string) bool {
+	for k, v := range p.AuthMetadata {
+		if mdV, ok := authMD[k]; !ok || mdV != v {
+			return false
+		}
+	}
+	if len(p.Entities) == 0 {
+		return true
+	}
+	return matchesAny(p.Entities, entity)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// An AuthorizationDenial describes a call that was not authorized.
+type AuthorizationDenial struct {
+	// Entity is empty when the caller has no known entity.
+	Entity       string
+	AuthMetadata map[string]string
+	FullMethod   string
+	// NoPolicy is true when no policy applied to the entity and the call was denied
+	// by default (see WithAuthorizationDefaultDeny).
+	NoPolicy bool
+}
+
+// authorizer evaluates the authorization policies of a server.
+type authorizer struct {
+	policies    []AuthorizationPolicy
+	defaultDeny bool
+	onDenial    func(ctx context.Context, denial AuthorizationDenial)
+}
+
+// authorize ensures that the authenticated entity, if any, may call the given method.
+// An entity that no policy applies to, or a caller with no known entity, may call any
+// method unless denying by default.
+func (a *authorizer) authorize(ctx context.Context, fullMethod string) error {
+	entity, ok := ContextAuthEntity(ctx)
+	if !ok {
+		if !a.defaultDeny {
+			return nil
+		}
+		return a.deny(ctx, AuthorizationDenial{FullMethod: fullMethod, NoPolicy: true})
+	}
+	var authMD map[string]string
+	if claims, ok := ContextAuthClaims(ctx); ok {
+		// the server's own internal credentials are always allowed.
+		if claims.CredentialsType() == credentialsTypeInternal {
+			return nil
+		}
+		authMD = claims.Metadata()
+	}
+
+	var applied bool
+	for _, policy := range a.policies {
+		if !policy.appliesTo(entity.Entity, authMD) {
+			continue
+		}
+		applied = true
+		if matchesAny(policy.Methods, fullMethod) {
+			return nil
+		}
+	}
+	if !applied && !a.defaultDeny {
+		return nil
+	}
+	return a.deny(ctx, AuthorizationDenial{
+		Entity:       entity.Entity,
+		AuthMetadata: authMD,
+		FullMethod:   fullMethod,
+		NoPolicy:     !applied,
+	})
+}
+
+// deny reports the denial to the hook, if any, and returns the error to fail the call with.
+func (a *authorizer) deny(ctx context.Context, denial AuthorizationDenial) error {
+	if a.onDenial != nil {
+		a.onDenial(ctx, denial)
+	}
+	return status.Errorf(codes.PermissionDenied, "not authorized to call %q", denial.FullMethod)
+}
+
+// WithAuthorizationPolicies returns a ServerOption which adds policies limiting what
+// methods authenticated entities may call. An entity may call a method if any policy
+// that applies to it allows the method. Entities that no policy applies to may call
+// any method unless WithAuthorizationDefaultDeny is used. Public methods
+// (see WithPublicMethods) and entities using the server's internal credentials are
+// not subject to authorization.
+func WithAuthorizationPolicies(policies ...AuthorizationPolicy) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, policy := range policies {
+			if err := policy.validate(); err != nil {
+				return err
+			}
+		}
+		o.authzPolicies = append(o.authzPolicies, policies...)
+		return nil
+	})
+}
+
+// WithAuthorizationDefaultDeny returns a ServerOption which denies authenticated entities
+// that no authorization policy applies to from calling any method. Calls tunneled over
+// WebRTC without an access token, whose caller is only known to have authenticated to the
+// signaler, are denied as well.
+func WithAuthorizationDefaultDeny() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authzDefaultDeny = true
+		return nil
+	})
+}
+
+// WithAuthorizationDenialHook returns a ServerOption which sets a hook that is called
+// with every call that is denied by authorization. It is intended for audit logging
+// and must not block.
+func WithAuthorizationDenialHook(hook func(ctx context.Context, denial AuthorizationDenial)) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.authzDenialHook = hook
+		return nil
+	})
+}
@@ -0,0 +1,268 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestAuthorizer(t *testing.T) {
+	authedCtx := func(entity string, credType CredentialsType, authMD map[string]string) context.Context {
+		ctx := ContextWithAuthEntity(context.Background(), EntityInfo{Entity: entity})
+		return contextWithAuthClaims(ctx, JWTClaims{AuthCredentialsType: credType, AuthMetadata: authMD})
+	}
+
+	var denials []AuthorizationDenial
+	authz := &authorizer{
+		policies: []AuthorizationPolicy{
+			{Entities: []string{"robot-*"}, Methods: []string{"/a/b"}},
+			{AuthMetadata: map[string]string{"role": "admin"}, Methods: []string{"/a/*", "/c/*"}},
+		},
+		onDenial: func(ctx context.Context, denial AuthorizationDenial) {
+			denials = append(denials, denial)
+		},
+	}
+
+	test.That(t, authz.authorize(authedCtx("robot-1", CredentialsTypeAPIKey, nil), "/a/b"), test.ShouldBeNil)
+	err := authz.authorize(authedCtx("robot-1", CredentialsTypeAPIKey, nil), "/a/c")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, denials, test.ShouldResemble, []AuthorizationDenial{{Entity: "robot-1", FullMethod: "/a/c"}})
+
+	// policies that apply combine
+	adminMD := map[string]string{"role": "admin"}
+	test.That(t, authz.authorize(authedCtx("robot-1", CredentialsTypeAPIKey, adminMD), "/a/c"), test.ShouldBeNil)
+	test.That(t, authz.authorize(authedCtx("someone", CredentialsTypeAPIKey, adminMD), "/c/d"), test.ShouldBeNil)
+	err = authz.authorize(authedCtx("someone", CredentialsTypeAPIKey, adminMD), "/d/e")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, denials, test.ShouldHaveLength, 2)
+	test.That(t, denials[1].AuthMetadata, test.ShouldResemble, adminMD)
+
+	// no policy applies
+	test.That(t, authz.authorize(authedCtx("someone", CredentialsTypeAPIKey, nil), "/d/e"), test.ShouldBeNil)
+	authz.defaultDeny = true
+	err = authz.authorize(authedCtx("someone", CredentialsTypeAPIKey, nil), "/d/e")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, denials, test.ShouldHaveLength, 3)
+	test.That(t, denials[2].NoPolicy, test.ShouldBeTrue)
+
+	// internal
+	test.That(t, authz.authorize(authedCtx("someone", credentialsTypeInternal, nil), "/d/e"), test.ShouldBeNil)
+	test.That(t, denials, test.ShouldHaveLength, 3)
+
+	// no entity
+	err = authz.authorize(context.Background(), "/d/e")
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, denials, test.ShouldHaveLength, 4)
+	test.That(t, denials[3], test.ShouldResemble, AuthorizationDenial{FullMethod: "/d/e", NoPolicy: true})
+	authz.defaultDeny = false
+	test.That(t, authz.authorize(context.Background(), "/d/e"), test.ShouldBeNil)
+	test.That(t, denials, test.ShouldHaveLength, 4)
+
+	var sOpts serverOptions
+	err = WithAuthorizationPolicies(AuthorizationPolicy{Methods: []string{"/a/["}}).apply(&sOpts)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid pattern")
+}
+
+func TestServerAuthorization(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	echoMethod := "/" + pb.EchoService_ServiceDesc.ServiceName + "/Echo"
+	var denialsMu sync.Mutex
+	var denials []AuthorizationDenial
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			if payload != "bar" {
+				return nil, errInvalidCredentials
+			}
+			if entity == "bob" {
+				return map[string]string{"role": "admin"}, nil
+			}
+			return nil, nil
+		})),
+		WithAuthorizationPolicies(
+			AuthorizationPolicy{Entities: []string{"alice"}, Methods: []string{echoMethod}},
+			AuthorizationPolicy{
+				AuthMetadata: map[string]string{"role": "admin"},
+				Methods:      []string{"/" + pb.EchoService_ServiceDesc.ServiceName + "/*"},
+			},
+		),
+		WithAuthorizationDefaultDeny(),
+		WithAuthorizationDenialHook(func(ctx context.Context, denial AuthorizationDenial) {
+			denialsMu.Lock()
+			denials = append(denials, denial)
+			denialsMu.Unlock()
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	dialAs := func(t *testing.T, entity string) pb.EchoServiceClient {
+		t.Helper()
+		conn, err := Dial(
+			context.Background(),
+			httpListener.Addr().String(),
+			logger,
+			WithInsecure(),
+			WithForceDirectGRPC(),
+			WithEntityCredentials(entity, Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		t.Cleanup(func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		})
+		return pb.NewEchoServiceClient(conn)
+	}
+
+	echoMultiple := func(client pb.EchoServiceClient) error {
+		echoMultiClient, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hello"})
+		if err != nil {
+			return err
+		}
+		_, err = echoMultiClient.Recv()
+		return err
+	}
+
+	t.Run("entity policy", func(t *testing.T) {
+		client := dialAs(t, "alice")
+		_, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		err = echoMultiple(client)
+		test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	})
+
+	t.Run("metadata policy", func(t *testing.T) {
+		client := dialAs(t, "bob")
+		_, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, echoMultiple(client), test.ShouldBeNil)
+	})
+
+	t.Run("default deny", func(t *testing.T) {
+		client := dialAs(t, "carol")
+		_, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+	})
+
+	denialsMu.Lock()
+	test.That(t, denials, test.ShouldResemble, []AuthorizationDenial{
+		{Entity: "alice", FullMethod: "/" + pb.EchoService_ServiceDesc.ServiceName + "/EchoMultiple"},
+		{Entity: "carol", FullMethod: echoMethod, NoPolicy: true},
+	})
+	denialsMu.Unlock()
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
+
+func TestServerAuthorizationWebRTC(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	listenerAddr := listener.Addr().String()
+
+	echoMethod := "/" + pb.EchoService_ServiceDesc.ServiceName + "/Echo"
+	var denialsMu sync.Mutex
+	var denials []AuthorizationDenial
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler(CredentialsTypeAPIKey, AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			if payload != "bar" {
+				return nil, errInvalidCredentials
+			}
+			return nil, nil
+		})),
+		WithAuthorizationPolicies(AuthorizationPolicy{
+			Entities: []string{"alice"},
+			Methods:  []string{echoMethod, "/proto.rpc.webrtc.v1.SignalingService/*"},
+		}),
+		WithAuthorizationDefaultDeny(),
+		WithAuthorizationDenialHook(func(ctx context.Context, denial AuthorizationDenial) {
+			denialsMu.Lock()
+			denials = append(denials, denial)
+			denialsMu.Unlock()
+		}),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{listenerAddr},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	conn, err := Dial(
+		context.Background(),
+		listenerAddr,
+		logger,
+		WithInsecure(),
+		WithDisableDirectGRPC(),
+		WithWebRTCOptions(DialWebRTCOptions{
+			SignalingInsecure:   true,
+			SignalingAuthEntity: "alice",
+			SignalingCreds:      Credentials{Type: CredentialsTypeAPIKey, Payload: "bar"},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	client := pb.NewEchoServiceClient(conn)
+
+	// without an access token, only authenticating to the signaler is known of the caller.
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	token, err := rpcServer.SignAccessToken(CredentialsTypeAPIKey, "alice", time.Hour, nil)
+	test.That(t, err, test.ShouldBeNil)
+	authedCtx := metadata.AppendToOutgoingContext(context.Background(), MetadataFieldAuthorization, AuthorizationValuePrefixBearer+token)
+	_, err = client.Echo(authedCtx, &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	echoMultiClient, err := client.EchoMultiple(authedCtx, &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echoMultiClient.Recv()
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+
+	denialsMu.Lock()
+	test.That(t, denials, test.ShouldResemble, []AuthorizationDenial{
+		{FullMethod: echoMethod, NoPolicy: true},
+		{Entity: "alice", FullMethod: "/" + pb.EchoService_ServiceDesc.ServiceName + "/EchoMultiple"},
+	})
+	denialsMu.Unlock()
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
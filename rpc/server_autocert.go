@@ -0,0 +1,348 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"go.viam.com/utils"
+	mongoutils "go.viam.com/utils/mongo"
+)
+
+func init() {
+	mongoutils.MustRegisterNamespace(&mongodbAutocertCacheDBName, &mongodbAutocertCacheCollName)
+}
+
+// autocertChallengePathPrefix is where ACME HTTP-01 challenges are served from.
+const autocertChallengePathPrefix = "/.well-known/acme-challenge/"
+
+// defaultAutocertExpiryAlertBefore is how close to expiring a certificate gets, by default,
+// before it is alerted on.
+const defaultAutocertExpiryAlertBefore = 7 * 24 * time.Hour
+
+// autocertCheckInterval is how often the expiry of certificates is checked.
+var autocertCheckInterval = time.Hour
+
+// AutocertOptions configure obtaining and renewing certificates automatically from an ACME
+// certificate authority such as Let's Encrypt.
+type AutocertOptions struct {
+	// Domains are the only domains certificates are obtained for.
+	Domains []string
+
+	// Email is optionally given to the certificate authority to contact about problems
+	// with certificates.
+	Email string
+
+	// CacheDir is the directory certificates and the ACME account key are kept in so that
+	// they are not obtained again on every restart. It is ignored if Cache is set.
+	CacheDir string
+
+	// Cache is where certificates and the ACME account key are kept. Use a shared cache
+	// (e.g. NewMongoDBAutocertCache) so that replicas share certificates.
+	Cache autocert.Cache
+
+	// DirectoryURL is the ACME directory of the certificate authority. If unset, Let's
+	// Encrypt is used.
+	DirectoryURL string
+
+	// RenewBefore is how long before certificates expire that they are renewed. If unset,
+	// they are renewed 30 days before expiring.
+	RenewBefore time.Duration
+
+	// ExpiryAlertBefore is how close to expiring a certificate may get before it is
+	// alerted on as having failed to renew. If unset, it is 7 days.
+	ExpiryAlertBefore time.Duration
+}
+
+// WithAutocert returns a ServerOption which obtains and renews certificates for the given
+// domains automatically using ACME. ServeTLS uses them when it is not given certificates
+// some other way and HTTP-01 challenges are answered by the server's http.Handler, so the
+// server must also be served on port 80 for them to succeed; otherwise only TLS-ALPN-01
+// challenges are used. If metrics are recorded (see WithMetrics), certificate expiry and
+// renewals are recorded too.
+func WithAutocert(opts AutocertOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if len(opts.Domains) == 0 {
+			return errors.New("expected at least one autocert domain")
+		}
+		if opts.Cache == nil && opts.CacheDir == "" {
+			// without a cache, certificates are obtained on every restart, which quickly runs
+			// into the rate limits of certificate authorities.
+			return errors.New("expected an autocert cache or cache directory")
+		}
+		if opts.RenewBefore < 0 || opts.ExpiryAlertBefore < 0 {
+			return errors.New("autocert durations must not be negative")
+		}
+		o.autocert = &opts
+		return nil
+	})
+}
+
+// serverAutocert manages the certificates of a server obtained via ACME.
+type serverAutocert struct {
+	manager          *autocert.Manager
+	challengeHandler http.Handler
+	domains          []string
+	alertBefore      time.Duration
+	metrics          *autocertMetrics
+	logger           golog.Logger
+
+	cancel                  func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+func newServerAutocert(opts AutocertOptions, registerer prometheus.Registerer, logger golog.Logger) (*serverAutocert, error) {
+	sa := &serverAutocert{
+		domains:     opts.Domains,
+		alertBefore: opts.ExpiryAlertBefore,
+		logger:      logger.Named("autocert"),
+	}
+	if sa.alertBefore == 0 {
+		sa.alertBefore = defaultAutocertExpiryAlertBefore
+	}
+	if registerer != nil {
+		var err error
+		if sa.metrics, err = newAutocertMetrics(registerer); err != nil {
+			return nil, err
+		}
+	}
+
+	cache := opts.Cache
+	if cache == nil {
+		cache = autocert.DirCache(opts.CacheDir)
+	}
+	sa.manager = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		HostPolicy:  autocert.HostWhitelist(opts.Domains...),
+		Cache:       &observedAutocertCache{Cache: cache, onCertificate: sa.certificateObtained},
+		Email:       opts.Email,
+		RenewBefore: opts.RenewBefore,
+	}
+	if opts.DirectoryURL != "" {
+		sa.manager.Client = &acme.Client{DirectoryURL: opts.DirectoryURL}
+	}
+	// only challenge requests are routed to the handler so there is no fallback.
+	sa.challengeHandler = sa.manager.HTTPHandler(http.NotFoundHandler())
+	return sa, nil
+}
+
+// tlsConfig returns the given TLS config, or a new one if nil, with certificates provided
+// by ACME.
+func (sa *serverAutocert) tlsConfig(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.GetCertificate = sa.manager.GetCertificate
+	nextProtos := utils.NewStringSet(tlsConfig.NextProtos...)
+	for _, proto := range []string{"h2", "http/1.1", acme.ALPNProto} {
+		if _, ok := nextProtos[proto]; !ok {
+			tlsConfig.NextProtos = append(tlsConfig.NextProtos, proto)
+		}
+	}
+	return tlsConfig
+}
+
+// startMonitoring checks the expiry of certificates in the background until closed.
+func (sa *serverAutocert) startMonitoring() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sa.cancel = cancel
+	sa.activeBackgroundWorkers.Add(1)
+	utils.PanicCapturingGo(func() {
+		defer sa.activeBackgroundWorkers.Done()
+		for {
+			sa.checkCertificates(ctx)
+			if !utils.SelectContextOrWait(ctx, autocertCheckInterval) {
+				return
+			}
+		}
+	})
+}
+
+// checkCertificates records when the certificate of each domain expires and alerts on any
+// that should have been renewed by now. Certificates not obtained yet are skipped.
+func (sa *serverAutocert) checkCertificates(ctx context.Context) {
+	for _, domain := range sa.domains {
+		data, err := sa.manager.Cache.Get(ctx, domain)
+		if err != nil {
+			if !errors.Is(err, autocert.ErrCacheMiss) && ctx.Err() == nil {
+				sa.logger.Warnw("failed to check certificate", "domain", domain, "error", err)
+			}
+			continue
+		}
+		leaf, err := autocertLeaf(data)
+		if err != nil {
+			sa.logger.Warnw("failed to check certificate", "domain", domain, "error", err)
+			continue
+		}
+		sa.metrics.certificateExpires(domain, leaf.NotAfter)
+		if untilExpiry := time.Until(leaf.NotAfter); untilExpiry < sa.alertBefore {
+			sa.logger.Errorw(
+				"certificate is about to expire and has not been renewed",
+				"domain", domain,
+				"expires_at", leaf.NotAfter,
+				"expires_in", untilExpiry.Round(time.Second),
+			)
+		}
+	}
+}
+
+// certificateObtained is called whenever a certificate is obtained, whether for the first
+// time or by renewing it.
+func (sa *serverAutocert) certificateObtained(domain string, leaf *x509.Certificate) {
+	sa.logger.Infow("obtained certificate", "domain", domain, "expires_at", leaf.NotAfter)
+	sa.metrics.certificateObtained(domain, leaf.NotAfter)
+}
+
+func (sa *serverAutocert) close() {
+	if sa.cancel != nil {
+		sa.cancel()
+	}
+	sa.activeBackgroundWorkers.Wait()
+}
+
+// autocertLeaf returns the leaf certificate of a cached certificate, which is its private
+// key followed by its chain, all PEM encoded.
+func autocertLeaf(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// observedAutocertCache is an autocert.Cache that observes certificates being obtained.
+type observedAutocertCache struct {
+	autocert.Cache
+	onCertificate func(domain string, leaf *x509.Certificate)
+}
+
+func (cache *observedAutocertCache) Put(ctx context.Context, key string, data []byte) error {
+	if err := cache.Cache.Put(ctx, key, data); err != nil {
+		return err
+	}
+	// other than certificates, the account key and challenge tokens are cached.
+	if strings.HasPrefix(key, "acme_account") || strings.HasSuffix(key, "+token") {
+		return nil
+	}
+	if leaf, err := autocertLeaf(data); err == nil {
+		cache.onCertificate(strings.TrimSuffix(key, "+rsa"), leaf)
+	}
+	return nil
+}
+
+// autocertMetrics are the metrics recorded about certificates obtained via ACME. All
+// methods may be called on a nil *autocertMetrics, in which case nothing is recorded.
+type autocertMetrics struct {
+	certificateExpiry    *prometheus.GaugeVec
+	certificatesObtained *prometheus.CounterVec
+}
+
+func newAutocertMetrics(registerer prometheus.Registerer) (*autocertMetrics, error) {
+	metrics := &autocertMetrics{
+		certificateExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "rpc",
+			Subsystem: "autocert",
+			Name:      "certificate_expiry_timestamp_seconds",
+			Help:      "When the certificate of a domain expires, in seconds since the Unix epoch.",
+		}, []string{"domain"}),
+		certificatesObtained: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "rpc",
+			Subsystem: "autocert",
+			Name:      "certificates_obtained_total",
+			Help:      "The number of certificates obtained, including renewals.",
+		}, []string{"domain"}),
+	}
+	for _, collector := range []prometheus.Collector{metrics.certificateExpiry, metrics.certificatesObtained} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, errors.Wrap(err, "failed to register autocert metrics")
+		}
+	}
+	return metrics, nil
+}
+
+func (m *autocertMetrics) certificateExpires(domain string, expiresAt time.Time) {
+	if m == nil {
+		return
+	}
+	m.certificateExpiry.WithLabelValues(domain).Set(float64(expiresAt.Unix()))
+}
+
+func (m *autocertMetrics) certificateObtained(domain string, expiresAt time.Time) {
+	if m == nil {
+		return
+	}
+	m.certificatesObtained.WithLabelValues(domain).Inc()
+	m.certificateExpires(domain, expiresAt)
+}
+
+// Database and collection names used by the mongoDBAutocertCache.
+var (
+	mongodbAutocertCacheDBName   = "rpc"
+	mongodbAutocertCacheCollName = "autocert_cache"
+)
+
+type mongodbAutocertCacheDoc struct {
+	ID        string    `bson:"_id"`
+	Data      []byte    `bson:"data"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// NewMongoDBAutocertCache returns a new autocert cache backed by the given MongoDB client
+// so that replicas share certificates. The cache holds private keys so access to it must
+// be restricted accordingly.
+func NewMongoDBAutocertCache(client *mongo.Client) autocert.Cache {
+	return &mongoDBAutocertCache{
+		coll: client.Database(mongodbAutocertCacheDBName).Collection(mongodbAutocertCacheCollName),
+	}
+}
+
+type mongoDBAutocertCache struct {
+	coll *mongo.Collection
+}
+
+func (cache *mongoDBAutocertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var doc mongodbAutocertCacheDoc
+	if err := cache.coll.FindOne(ctx, bson.D{{"_id", key}}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return doc.Data, nil
+}
+
+func (cache *mongoDBAutocertCache) Put(ctx context.Context, key string, data []byte) error {
+	_, err := cache.coll.ReplaceOne(
+		ctx,
+		bson.D{{"_id", key}},
+		mongodbAutocertCacheDoc{ID: key, Data: data, UpdatedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (cache *mongoDBAutocertCache) Delete(ctx context.Context, key string) error {
+	_, err := cache.coll.DeleteOne(ctx, bson.D{{"_id", key}})
+	return err
+}
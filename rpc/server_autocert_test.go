@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.viam.com/test"
+	"golang.org/x/crypto/acme/autocert"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+// autocertCacheEntry returns a self-signed ECDSA certificate for the domain as autocert caches
+// it along with its leaf and a pool that verifies it.
+func autocertCacheEntry(t *testing.T, domain string) ([]byte, *x509.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.That(t, err, test.ShouldBeNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	test.That(t, err, test.ShouldBeNil)
+	leaf, err := x509.ParseCertificate(der)
+	test.That(t, err, test.ShouldBeNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	test.That(t, err, test.ShouldBeNil)
+
+	var entry bytes.Buffer
+	test.That(t, pem.Encode(&entry, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), test.ShouldBeNil)
+	test.That(t, pem.Encode(&entry, &pem.Block{Type: "CERTIFICATE", Bytes: der}), test.ShouldBeNil)
+	certPool := x509.NewCertPool()
+	certPool.AddCert(leaf)
+	return entry.Bytes(), leaf, certPool
+}
+
+func testAutocertCache(t *testing.T, cache autocert.Cache) {
+	t.Helper()
+
+	_, err := cache.Get(context.Background(), "example.com")
+	test.That(t, err, test.ShouldEqual, autocert.ErrCacheMiss)
+
+	test.That(t, cache.Put(context.Background(), "example.com", []byte("first")), test.ShouldBeNil)
+	data, err := cache.Get(context.Background(), "example.com")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data, test.ShouldResemble, []byte("first"))
+
+	test.That(t, cache.Put(context.Background(), "example.com", []byte("second")), test.ShouldBeNil)
+	data, err = cache.Get(context.Background(), "example.com")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data, test.ShouldResemble, []byte("second"))
+
+	test.That(t, cache.Delete(context.Background(), "example.com"), test.ShouldBeNil)
+	_, err = cache.Get(context.Background(), "example.com")
+	test.That(t, err, test.ShouldEqual, autocert.ErrCacheMiss)
+	// deleting what is not there is fine
+	test.That(t, cache.Delete(context.Background(), "example.com"), test.ShouldBeNil)
+}
+
+func TestMongoDBAutocertCache(t *testing.T) {
+	client := testutils.BackingMongoDBClient(t)
+	test.That(t, client.Database(mongodbAutocertCacheDBName).Collection(mongodbAutocertCacheCollName).Drop(context.Background()),
+		test.ShouldBeNil)
+	testAutocertCache(t, NewMongoDBAutocertCache(client))
+}
+
+func TestWithAutocert(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	for _, opts := range []AutocertOptions{
+		{CacheDir: t.TempDir()},
+		{Domains: []string{"example.com"}},
+		{Domains: []string{"example.com"}, CacheDir: t.TempDir(), RenewBefore: -time.Hour},
+		{Domains: []string{"example.com"}, CacheDir: t.TempDir(), ExpiryAlertBefore: -time.Hour},
+	} {
+		_, err := NewServer(logger, WithAutocert(opts))
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+func TestServerAutocert(t *testing.T) {
+	logger, observedLogs := golog.NewObservedTestLogger(t)
+	const domain = "example.com"
+
+	cacheDir := t.TempDir()
+	entry, leaf, certPool := autocertCacheEntry(t, domain)
+	test.That(t, autocert.DirCache(cacheDir).Put(context.Background(), domain, entry), test.ShouldBeNil)
+
+	registry := prometheus.NewRegistry()
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithMetrics(registry),
+		WithAutocert(AutocertOptions{
+			Domains:  []string{domain},
+			CacheDir: cacheDir,
+			// the certificate is cached so the certificate authority is never reached.
+			DirectoryURL:      "http://127.0.0.1:1/directory",
+			ExpiryAlertBefore: 60 * 24 * time.Hour,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+	sa := rpcServer.(*simpleServer).autocert
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.ServeTLS(listener, "", "", nil)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	t.Run("serves cached certificates", func(t *testing.T) {
+		conn, err := Dial(context.Background(), listener.Addr().String(), logger, WithForceDirectGRPC(), WithTLSConfig(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    certPool,
+			ServerName: domain,
+		}))
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		resp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp.Message, test.ShouldEqual, "hi")
+	})
+
+	t.Run("challenges", func(t *testing.T) {
+		for _, tc := range []struct {
+			host     string
+			expected int
+		}{
+			// there is no pending challenge for the token
+			{domain, http.StatusNotFound},
+			{"other.example.com", http.StatusForbidden},
+		} {
+			recorder := httptest.NewRecorder()
+			rpcServer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://"+tc.host+autocertChallengePathPrefix+"token", nil))
+			test.That(t, recorder.Code, test.ShouldEqual, tc.expected)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		expiry := sa.metrics.certificateExpiry.WithLabelValues(domain)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, testutil.ToFloat64(expiry), test.ShouldEqual, float64(leaf.NotAfter.Unix()))
+			test.That(tb, observedLogs.FilterMessage("certificate is about to expire and has not been renewed").Len(), test.ShouldEqual, 1)
+		})
+	})
+
+	t.Run("obtained certificates", func(t *testing.T) {
+		obtained := sa.metrics.certificatesObtained.WithLabelValues(domain)
+		test.That(t, sa.manager.Cache.Put(context.Background(), "acme_account+key", []byte("key")), test.ShouldBeNil)
+		test.That(t, sa.manager.Cache.Put(context.Background(), domain+"+token", []byte("token")), test.ShouldBeNil)
+		test.That(t, testutil.ToFloat64(obtained), test.ShouldEqual, 0)
+
+		test.That(t, sa.manager.Cache.Put(context.Background(), domain+"+rsa", entry), test.ShouldBeNil)
+		test.That(t, testutil.ToFloat64(obtained), test.ShouldEqual, 1)
+		test.That(t, observedLogs.FilterMessage("obtained certificate").Len(), test.ShouldEqual, 1)
+	})
+}
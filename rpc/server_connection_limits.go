@@ -0,0 +1,261 @@
+package rpc
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils"
+)
+
+var (
+	errPeerConnectionIdle     = &PeerCloseReason{Code: codes.Unavailable, Message: "connection idle for too long"}
+	errPeerConnectionMaxAge   = &PeerCloseReason{Code: codes.Unavailable, Message: "connection reached its max age"}
+	errPeerConnectionDraining = status.Error(codes.Unavailable, "connection is draining; retry on a new connection")
+)
+
+// KeepaliveOptions configure how the server keeps connections alive and which keepalive
+// pings it tolerates from clients. Over WebRTC, they apply to heartbeats unless those
+// are configured with WebRTCServerOptions.
+type KeepaliveOptions struct {
+	// Time is how long a connection may go without activity before the server pings the
+	// client to see if it is still alive. If zero, gRPC's default of two hours is used.
+	Time time.Duration
+
+	// Timeout is how long the server waits for a response to a ping before closing the
+	// connection. If zero, gRPC's default of 20 seconds is used.
+	Timeout time.Duration
+
+	// MinTime is how long clients must wait between pings. Clients that ping more often
+	// are disconnected. If zero, gRPC's default of five minutes is used.
+	MinTime time.Duration
+
+	// PermitWithoutStream allows clients to ping when they have no calls in flight.
+	PermitWithoutStream bool
+}
+
+// ConnectionAgeOptions limit how long connections to the server live so that clients
+// reconnect, and thereby rebalance, from time to time. A zero limit is not enforced.
+type ConnectionAgeOptions struct {
+	// MaxIdle is how long a connection may go without calls in flight before it is closed.
+	MaxIdle time.Duration
+
+	// MaxAge is about how long a connection may live, give or take 10% so that many
+	// connections do not close at once, before the server stops accepting new calls on it.
+	MaxAge time.Duration
+
+	// MaxAgeGrace is how long calls in flight have to finish after a connection reaches its
+	// max age before it is closed. If zero, calls in flight are waited on indefinitely.
+	MaxAgeGrace time.Duration
+}
+
+// WithMaxRecvMsgSize returns a ServerOption which sets the largest message, in bytes, the
+// server accepts over any transport. Larger messages fail their calls with
+// codes.ResourceExhausted. If unset, gRPC's default of 4MB is used for direct gRPC
+// connections and MaxMessageSize for WebRTC.
+func WithMaxRecvMsgSize(size int) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if size <= 0 {
+			return errors.New("expected positive max receive message size")
+		}
+		o.maxRecvMsgSize = size
+		return nil
+	})
+}
+
+// WithMaxSendMsgSize returns a ServerOption which sets the largest message, in bytes, the
+// server sends over any transport. Larger messages fail their calls with
+// codes.ResourceExhausted. If unset, there is no limit.
+func WithMaxSendMsgSize(size int) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if size <= 0 {
+			return errors.New("expected positive max send message size")
+		}
+		o.maxSendMsgSize = size
+		return nil
+	})
+}
+
+// WithKeepalive returns a ServerOption which configures keepalive pings and their
+// enforcement. Pings are only sent and enforced by the internal gRPC server (see Start)
+// and over WebRTC; connections served via Serve or ServeTLS are managed by the HTTP server.
+func WithKeepalive(opts KeepaliveOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if opts.Time < 0 || opts.Timeout < 0 || opts.MinTime < 0 {
+			return errors.New("keepalive durations must not be negative")
+		}
+		o.keepalive = &opts
+		return nil
+	})
+}
+
+// WithConnectionAgeLimits returns a ServerOption which limits how long connections live.
+// The limits are enforced by the internal gRPC server (see Start) and over WebRTC, where a
+// connection is a peer connection. Connections served via Serve or ServeTLS are only
+// subject to MaxIdle.
+func WithConnectionAgeLimits(opts ConnectionAgeOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if opts.MaxIdle < 0 || opts.MaxAge < 0 || opts.MaxAgeGrace < 0 {
+			return errors.New("connection age limits must not be negative")
+		}
+		o.connectionAge = &opts
+		return nil
+	})
+}
+
+// grpcConnectionLimitOptions returns the gRPC server options that apply the message size,
+// keepalive, and connection age settings.
+func grpcConnectionLimitOptions(sOpts serverOptions) []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if sOpts.maxRecvMsgSize != 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(sOpts.maxRecvMsgSize))
+	}
+	if sOpts.maxSendMsgSize != 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(sOpts.maxSendMsgSize))
+	}
+	if sOpts.keepalive == nil && sOpts.connectionAge == nil {
+		return opts
+	}
+	var params keepalive.ServerParameters
+	if sOpts.keepalive != nil {
+		params.Time = sOpts.keepalive.Time
+		params.Timeout = sOpts.keepalive.Timeout
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             sOpts.keepalive.MinTime,
+			PermitWithoutStream: sOpts.keepalive.PermitWithoutStream,
+		}))
+	}
+	if sOpts.connectionAge != nil {
+		params.MaxConnectionIdle = sOpts.connectionAge.MaxIdle
+		params.MaxConnectionAge = sOpts.connectionAge.MaxAge
+		params.MaxConnectionAgeGrace = sOpts.connectionAge.MaxAgeGrace
+	}
+	return append(opts, grpc.KeepaliveParams(params))
+}
+
+// webrtcHeartbeatFromKeepalive returns the WebRTC heartbeat interval and timeout equivalent
+// to the keepalive options, keeping whichever are already set.
+func webrtcHeartbeatFromKeepalive(opts KeepaliveOptions, interval, timeout time.Duration) (time.Duration, time.Duration) {
+	if interval == 0 && opts.Time != 0 {
+		interval = opts.Time
+	}
+	if timeout == 0 && opts.Time != 0 {
+		pingTimeout := opts.Timeout
+		if pingTimeout == 0 {
+			pingTimeout = DefaultWebRTCHeartbeatTimeout
+		}
+		// the peer is dead if it has not answered the last ping within the ping timeout.
+		timeout = interval + pingTimeout
+	}
+	return interval, timeout
+}
+
+// enforceConnectionAge closes the channel once it has been idle or alive for too long.
+// Once it reaches its max age, new calls are refused so that clients move to a new
+// connection, and it is closed when the calls in flight finish or the grace period ends.
+func (ch *webrtcServerChannel) enforceConnectionAge(opts ConnectionAgeOptions) {
+	if opts.MaxIdle == 0 && opts.MaxAge == 0 {
+		return
+	}
+	ch.webrtcBaseChannel.mu.Lock()
+	if ch.webrtcBaseChannel.closed {
+		ch.webrtcBaseChannel.mu.Unlock()
+		return
+	}
+	ch.activeBackgroundWorkers.Add(1)
+	ch.webrtcBaseChannel.mu.Unlock()
+
+	utils.PanicCapturingGo(func() {
+		defer ch.activeBackgroundWorkers.Done()
+
+		var maxAgeC <-chan time.Time
+		if opts.MaxAge != 0 {
+			// jitter by +/- 10% like gRPC does.
+			jitter := time.Duration(rand.Int63n(int64(opts.MaxAge)/5+1)) - opts.MaxAge/10 //nolint:gosec
+			maxAgeTimer := time.NewTimer(opts.MaxAge + jitter)
+			defer maxAgeTimer.Stop()
+			maxAgeC = maxAgeTimer.C
+		}
+		var idleC <-chan time.Time
+		if opts.MaxIdle != 0 {
+			checkInterval := opts.MaxIdle / 10
+			if checkInterval < time.Millisecond {
+				checkInterval = time.Millisecond
+			}
+			idleTicker := time.NewTicker(checkInterval)
+			defer idleTicker.Stop()
+			idleC = idleTicker.C
+		}
+
+		idleSince := time.Now()
+		for {
+			select {
+			case <-ch.ctx.Done():
+				return
+			case <-idleC:
+				if ch.numStreams() != 0 {
+					idleSince = time.Now()
+					continue
+				}
+				if time.Since(idleSince) < opts.MaxIdle {
+					continue
+				}
+				ch.webrtcBaseChannel.logger.Debugw("closing idle peer connection", "max_idle", opts.MaxIdle)
+				if err := ch.closeWithPeerReason(errPeerConnectionIdle); err != nil {
+					ch.webrtcBaseChannel.logger.Errorw("error closing channel", "error", err)
+				}
+				return
+			case <-maxAgeC:
+				ch.drainForMaxAge(opts.MaxAgeGrace)
+				return
+			}
+		}
+	})
+}
+
+// drainForMaxAge refuses new calls on the channel and closes it once the calls in flight
+// finish or the grace period, if any, ends.
+func (ch *webrtcServerChannel) drainForMaxAge(grace time.Duration) {
+	ch.mu.Lock()
+	ch.draining = true
+	ch.mu.Unlock()
+	ch.webrtcBaseChannel.logger.Debugw("peer connection reached its max age; draining", "grace", grace)
+
+	var graceC <-chan time.Time
+	if grace != 0 {
+		graceTimer := time.NewTimer(grace)
+		defer graceTimer.Stop()
+		graceC = graceTimer.C
+	}
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for ch.numStreams() != 0 {
+		select {
+		case <-ch.ctx.Done():
+			return
+		case <-graceC:
+			ch.webrtcBaseChannel.logger.Debug("max age grace period elapsed with calls still in flight")
+			ch.closeForMaxAge()
+			return
+		case <-ticker.C:
+		}
+	}
+	ch.closeForMaxAge()
+}
+
+func (ch *webrtcServerChannel) closeForMaxAge() {
+	if err := ch.closeWithPeerReason(errPeerConnectionMaxAge); err != nil {
+		ch.webrtcBaseChannel.logger.Errorw("error closing channel", "error", err)
+	}
+}
+
+func (ch *webrtcServerChannel) numStreams() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return len(ch.streams)
+}
@@ -0,0 +1,226 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestConnectionLimitOptions(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	for _, opt := range []ServerOption{
+		WithMaxRecvMsgSize(0),
+		WithMaxSendMsgSize(-1),
+		WithKeepalive(KeepaliveOptions{Timeout: -time.Second}),
+		WithConnectionAgeLimits(ConnectionAgeOptions{MaxAge: -time.Second}),
+	} {
+		_, err := NewServer(logger, opt)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+
+	test.That(t, grpcConnectionLimitOptions(serverOptions{}), test.ShouldBeEmpty)
+	test.That(t, grpcConnectionLimitOptions(serverOptions{maxRecvMsgSize: 1, maxSendMsgSize: 1}), test.ShouldHaveLength, 2)
+	ageOpts := grpcConnectionLimitOptions(serverOptions{connectionAge: &ConnectionAgeOptions{MaxAge: time.Second}})
+	test.That(t, ageOpts, test.ShouldHaveLength, 1)
+	keepaliveOpts := grpcConnectionLimitOptions(serverOptions{keepalive: &KeepaliveOptions{Time: time.Second}})
+	test.That(t, keepaliveOpts, test.ShouldHaveLength, 2)
+
+	interval, timeout := webrtcHeartbeatFromKeepalive(KeepaliveOptions{}, 0, 0)
+	test.That(t, interval, test.ShouldEqual, 0)
+	test.That(t, timeout, test.ShouldEqual, 0)
+	interval, timeout = webrtcHeartbeatFromKeepalive(KeepaliveOptions{Time: time.Second}, 0, 0)
+	test.That(t, interval, test.ShouldEqual, time.Second)
+	test.That(t, timeout, test.ShouldEqual, time.Second+DefaultWebRTCHeartbeatTimeout)
+	interval, timeout = webrtcHeartbeatFromKeepalive(KeepaliveOptions{Time: time.Second, Timeout: time.Second}, 0, 0)
+	test.That(t, interval, test.ShouldEqual, time.Second)
+	test.That(t, timeout, test.ShouldEqual, 2*time.Second)
+	// heartbeats configured explicitly win
+	interval, timeout = webrtcHeartbeatFromKeepalive(KeepaliveOptions{Time: time.Second}, time.Minute, time.Hour)
+	test.That(t, interval, test.ShouldEqual, time.Minute)
+	test.That(t, timeout, test.ShouldEqual, time.Hour)
+}
+
+func TestServerMessageSizeLimits(t *testing.T) {
+	const limit = 1 << 10
+	small := strings.Repeat("a", limit/2)
+	large := strings.Repeat("a", 2*limit)
+
+	t.Run("grpc", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		for _, tc := range []struct {
+			name string
+			opt  ServerOption
+		}{
+			{"recv", WithMaxRecvMsgSize(limit)},
+			{"send", WithMaxSendMsgSize(limit)},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				rpcServer, err := NewServer(logger, WithDisableMulticastDNS(), WithUnauthenticated(), tc.opt)
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, rpcServer.RegisterServiceServer(
+					context.Background(),
+					&echopb.EchoService_ServiceDesc,
+					&echoserver.Server{},
+				), test.ShouldBeNil)
+				listener, err := net.Listen("tcp", "localhost:0")
+				test.That(t, err, test.ShouldBeNil)
+				errChan := make(chan error)
+				go func() {
+					errChan <- rpcServer.Serve(listener)
+				}()
+				defer func() {
+					test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+					test.That(t, <-errChan, test.ShouldBeNil)
+				}()
+
+				conn, err := Dial(context.Background(), listener.Addr().String(), logger, WithInsecure(), WithForceDirectGRPC())
+				test.That(t, err, test.ShouldBeNil)
+				defer func() {
+					test.That(t, conn.Close(), test.ShouldBeNil)
+				}()
+				client := echopb.NewEchoServiceClient(conn)
+
+				resp, err := client.Echo(context.Background(), &echopb.EchoRequest{Message: small})
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, resp.Message, test.ShouldEqual, small)
+				_, err = client.Echo(context.Background(), &echopb.EchoRequest{Message: large})
+				test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+			})
+		}
+	})
+
+	t.Run("webrtc", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			set  func(srv *webrtcServer)
+		}{
+			{"recv", func(srv *webrtcServer) { srv.maxRecvMsgSize = limit }},
+			{"send", func(srv *webrtcServer) { srv.maxSendMsgSize = limit }},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				logger := golog.NewTestLogger(t)
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+
+				pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+				test.That(t, err, test.ShouldBeNil)
+				defer func() {
+					test.That(t, pair.Close(), test.ShouldBeNil)
+				}()
+				tc.set(pair.server)
+				pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+				client := echopb.NewEchoServiceClient(pair.Client())
+
+				resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: small})
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, resp.Message, test.ShouldEqual, small)
+				_, err = client.Echo(ctx, &echopb.EchoRequest{Message: large})
+				test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+
+				// the channel is still usable
+				resp, err = client.Echo(ctx, &echopb.EchoRequest{Message: small})
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, resp.Message, test.ShouldEqual, small)
+			})
+		}
+	})
+}
+
+func TestWebRTCConnectionAge(t *testing.T) {
+	newPair := func(t *testing.T) (*InMemoryWebRTCChannelPair, *webrtcServerChannel) {
+		t.Helper()
+		logger := golog.NewTestLogger(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+		test.That(t, err, test.ShouldBeNil)
+		t.Cleanup(func() {
+			test.That(t, pair.Close(), test.ShouldBeNil)
+		})
+		pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+
+		pair.server.mu.Lock()
+		defer pair.server.mu.Unlock()
+		for _, serverCh := range pair.server.peerConns {
+			return pair, serverCh
+		}
+		t.Fatal("expected a server channel")
+		return nil, nil
+	}
+	waitForClientClosed := func(t *testing.T, pair *InMemoryWebRTCChannelPair) {
+		t.Helper()
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			closed, _ := pair.client.Closed()
+			test.That(tb, closed, test.ShouldBeTrue)
+		})
+	}
+
+	t.Run("max idle", func(t *testing.T) {
+		pair, serverCh := newPair(t)
+		serverCh.enforceConnectionAge(ConnectionAgeOptions{MaxIdle: 200 * time.Millisecond})
+
+		// calls in flight keep the connection alive
+		stream, err := echopb.NewEchoServiceClient(pair.Client()).EchoBiDi(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, stream.Send(&echopb.EchoBiDiRequest{Message: "h"}), test.ShouldBeNil)
+		_, err = stream.Recv()
+		test.That(t, err, test.ShouldBeNil)
+		time.Sleep(400 * time.Millisecond)
+		closed, _ := pair.client.Closed()
+		test.That(t, closed, test.ShouldBeFalse)
+
+		test.That(t, stream.CloseSend(), test.ShouldBeNil)
+		waitForClientClosed(t, pair)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		pair, serverCh := newPair(t)
+		client := echopb.NewEchoServiceClient(pair.Client())
+		stream, err := client.EchoBiDi(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, stream.Send(&echopb.EchoBiDiRequest{Message: "h"}), test.ShouldBeNil)
+		_, err = stream.Recv()
+		test.That(t, err, test.ShouldBeNil)
+
+		serverCh.enforceConnectionAge(ConnectionAgeOptions{MaxAge: 100 * time.Millisecond})
+
+		// new calls are refused once the connection reaches its max age
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			_, err := client.Echo(context.Background(), &echopb.EchoRequest{Message: "hi"})
+			test.That(tb, status.Code(err), test.ShouldEqual, codes.Unavailable)
+		})
+
+		// while calls in flight finish
+		test.That(t, stream.Send(&echopb.EchoBiDiRequest{Message: "i"}), test.ShouldBeNil)
+		resp, err := stream.Recv()
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp.Message, test.ShouldEqual, "i")
+		test.That(t, stream.CloseSend(), test.ShouldBeNil)
+		waitForClientClosed(t, pair)
+	})
+
+	t.Run("max age grace", func(t *testing.T) {
+		pair, serverCh := newPair(t)
+		stream, err := echopb.NewEchoServiceClient(pair.Client()).EchoBiDi(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, stream.Send(&echopb.EchoBiDiRequest{Message: "h"}), test.ShouldBeNil)
+		_, err = stream.Recv()
+		test.That(t, err, test.ShouldBeNil)
+
+		serverCh.enforceConnectionAge(ConnectionAgeOptions{MaxAge: 100 * time.Millisecond, MaxAgeGrace: 100 * time.Millisecond})
+		waitForClientClosed(t, pair)
+	})
+}
@@ -0,0 +1,142 @@
+package rpc
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/zpages"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DebugPathPrefix is the HTTP path under which a server serves its debug endpoints. See
+// WithDebugEndpoints and WebRTCServerOptions.EnablePeersDebugEndpoint.
+const DebugPathPrefix = "/debug"
+
+// DebugEndpointsOptions control who may reach the debug endpoints of a server.
+type DebugEndpointsOptions struct {
+	// AllowedNetworks, if set, are the networks, in CIDR notation (e.g. "10.0.0.0/8"), that
+	// requests for anything under DebugPathPrefix must come from. The address of the
+	// connection is checked rather than any forwarding header, so behind a proxy it is the
+	// address of the proxy. Since nothing else protects the debug endpoints of an
+	// unauthenticated server, they are only served to loopback addresses unless networks
+	// are set.
+	AllowedNetworks []string
+}
+
+// loopbackDebugNetworks are the networks an unauthenticated server serves its debug
+// endpoints to when no others are allowed.
+var loopbackDebugNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+// WithDebugEndpoints returns a ServerOption which serves the profiles of net/http/pprof at
+// DebugPathPrefix/pprof/, the OpenCensus zpages at DebugPathPrefix/rpcz and
+// DebugPathPrefix/tracez, and a snapshot of expvar variables at DebugPathPrefix/vars.
+// Requests must authenticate like calls do unless the server is unauthenticated, in which
+// case they must come from DebugEndpointsOptions.AllowedNetworks or loopback addresses.
+// Auth scopes and authorization policies see them as calls to a method named after their
+// path.
+func WithDebugEndpoints(opts DebugEndpointsOptions) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, network := range opts.AllowedNetworks {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return errors.Wrapf(err, "invalid allowed debug network %q", network)
+			}
+		}
+		o.debugEndpoints = &opts
+		return nil
+	})
+}
+
+// newDebugHandler returns the handler of everything WithDebugEndpoints serves. The pprof
+// handlers are registered explicitly since importing net/http/pprof only registers them on
+// http.DefaultServeMux.
+func newDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DebugPathPrefix+"/pprof/", pprof.Index)
+	mux.HandleFunc(DebugPathPrefix+"/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc(DebugPathPrefix+"/pprof/profile", pprof.Profile)
+	mux.HandleFunc(DebugPathPrefix+"/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc(DebugPathPrefix+"/pprof/trace", pprof.Trace)
+	zpages.Handle(mux, DebugPathPrefix)
+	mux.Handle(DebugPathPrefix+"/vars", expvar.Handler())
+	return mux
+}
+
+// parseDebugNetworks parses networks already validated by WithDebugEndpoints. Unless the
+// server is authenticated, no networks means loopback addresses only.
+func parseDebugNetworks(networks []string, unauthenticated bool) []*net.IPNet {
+	if len(networks) == 0 && unauthenticated {
+		networks = loopbackDebugNetworks
+	}
+	parsed := make([]*net.IPNet, 0, len(networks))
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, ipNet)
+	}
+	return parsed
+}
+
+// servesDebug returns whether the request is for one of the debug endpoints being served.
+func (ss *simpleServer) servesDebug(r *http.Request) bool {
+	return (ss.peersDebug || ss.debugHandler != nil) && strings.HasPrefix(r.URL.Path, DebugPathPrefix+"/")
+}
+
+func (ss *simpleServer) serveDebug(w http.ResponseWriter, r *http.Request) {
+	if !ss.debugAddrAllowed(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !ss.unauthenticated {
+		ctx, err := ss.authenticateHTTPRequest(r)
+		if err == nil {
+			err = ss.authorizeCall(ctx, r.URL.Path)
+		}
+		if err != nil {
+			code := http.StatusUnauthorized
+			if status.Code(err) == codes.PermissionDenied {
+				code = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+	}
+
+	if ss.peersDebug && (r.URL.Path == PeersDebugPath || strings.HasPrefix(r.URL.Path, PeersDebugPath+"/")) {
+		ss.servePeersDebug(w, r)
+		return
+	}
+	if ss.debugHandler != nil {
+		ss.debugHandler.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// debugAddrAllowed returns whether a request from the given remote address may reach the
+// debug endpoints.
+func (ss *simpleServer) debugAddrAllowed(remoteAddr string) bool {
+	if len(ss.debugAllowedNetworks) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range ss.debugAllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
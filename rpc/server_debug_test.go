@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	loopbackRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		return req
+	}
+
+	t.Run("serves pprof, zpages, and expvar", func(t *testing.T) {
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithUnauthenticated(),
+			WithDebugEndpoints(DebugEndpointsOptions{}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		for _, path := range []string{
+			DebugPathPrefix + "/pprof/",
+			DebugPathPrefix + "/pprof/goroutine?debug=1",
+			DebugPathPrefix + "/rpcz",
+			DebugPathPrefix + "/tracez",
+		} {
+			w := httptest.NewRecorder()
+			rpcServer.ServeHTTP(w, loopbackRequest(path))
+			test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		}
+
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, loopbackRequest(DebugPathPrefix+"/vars"))
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		var vars map[string]interface{}
+		test.That(t, json.NewDecoder(w.Body).Decode(&vars), test.ShouldBeNil)
+		test.That(t, vars, test.ShouldContainKey, "memstats")
+
+		w = httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, loopbackRequest(PeersDebugPath))
+		test.That(t, w.Code, test.ShouldEqual, http.StatusNotFound)
+	})
+
+	t.Run("unauthenticated servers default to loopback", func(t *testing.T) {
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithUnauthenticated(),
+			WithDebugEndpoints(DebugEndpointsOptions{}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		for remoteAddr, expectedCode := range map[string]int{
+			"127.0.0.1:1234": http.StatusOK,
+			"[::1]:1234":     http.StatusOK,
+			"10.1.2.3:1234":  http.StatusForbidden,
+			"192.0.2.1:1234": http.StatusForbidden,
+		} {
+			req := httptest.NewRequest(http.MethodGet, DebugPathPrefix+"/vars", nil)
+			req.RemoteAddr = remoteAddr
+			w := httptest.NewRecorder()
+			rpcServer.ServeHTTP(w, req)
+			test.That(t, w.Code, test.ShouldEqual, expectedCode)
+		}
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithAuthHandler("fake", AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+				return map[string]string{}, nil
+			})),
+			WithDebugEndpoints(DebugEndpointsOptions{}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		req := httptest.NewRequest(http.MethodGet, DebugPathPrefix+"/vars", nil)
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, req)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusUnauthorized)
+
+		req.Header.Set(MetadataFieldAuthorization, "Bearer notatoken")
+		w = httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, req)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusUnauthorized)
+	})
+
+	t.Run("limited to auth scopes", func(t *testing.T) {
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithAPIKeyAuthHandler(NewMemoryAPIKeyStore()),
+			WithDebugEndpoints(DebugEndpointsOptions{}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		token, err := rpcServer.SignAccessToken(CredentialsTypeAPIKey, "someone", time.Hour, map[string]string{
+			AuthMetadataScopesKey: DebugPathPrefix + "/pprof/*",
+		})
+		test.That(t, err, test.ShouldBeNil)
+
+		for path, expectedCode := range map[string]int{
+			DebugPathPrefix + "/pprof/": http.StatusOK,
+			DebugPathPrefix + "/vars":   http.StatusForbidden,
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set(MetadataFieldAuthorization, AuthorizationValuePrefixBearer+token)
+			w := httptest.NewRecorder()
+			rpcServer.ServeHTTP(w, req)
+			test.That(t, w.Code, test.ShouldEqual, expectedCode)
+		}
+	})
+
+	t.Run("allowed networks", func(t *testing.T) {
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithUnauthenticated(),
+			WithDebugEndpoints(DebugEndpointsOptions{AllowedNetworks: []string{"10.0.0.0/8", "::1/128"}}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		for remoteAddr, expectedCode := range map[string]int{
+			"10.1.2.3:1234":  http.StatusOK,
+			"[::1]:1234":     http.StatusOK,
+			"192.0.2.1:1234": http.StatusForbidden,
+			"notanaddress":   http.StatusForbidden,
+		} {
+			req := httptest.NewRequest(http.MethodGet, DebugPathPrefix+"/vars", nil)
+			req.RemoteAddr = remoteAddr
+			w := httptest.NewRecorder()
+			rpcServer.ServeHTTP(w, req)
+			test.That(t, w.Code, test.ShouldEqual, expectedCode)
+		}
+	})
+
+	t.Run("invalid allowed network", func(t *testing.T) {
+		_, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithUnauthenticated(),
+			WithDebugEndpoints(DebugEndpointsOptions{AllowedNetworks: []string{"10.0.0.0"}}),
+		)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "10.0.0.0")
+	})
+
+	t.Run("not served", func(t *testing.T) {
+		rpcServer, err := NewServer(logger, WithDisableMulticastDNS(), WithUnauthenticated())
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		}()
+
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPathPrefix+"/vars", nil))
+		test.That(t, w.Code, test.ShouldNotEqual, http.StatusOK)
+	})
+}
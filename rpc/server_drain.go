@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils"
+)
+
+// errServerDraining is returned for calls made after a server started draining so that
+// clients retry them elsewhere.
+var errServerDraining = status.Error(codes.Unavailable, "server is shutting down")
+
+// callTracker tracks the calls in flight on a server, over any transport, so that it
+// can wait for them to finish when stopping.
+type callTracker struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	idle     chan struct{}
+}
+
+// start returns whether a new call may start, in which case done must be called once
+// it finishes.
+func (t *callTracker) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.inFlight++
+	return true
+}
+
+// track tracks a request, even while draining, until done is called. It is used for
+// requests whose responses are still being written after their calls finish.
+func (t *callTracker) track() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight++
+}
+
+func (t *callTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if t.draining && t.inFlight == 0 {
+		select {
+		case <-t.idle:
+			// requests tracked while draining may finish after it was already idle.
+		default:
+			close(t.idle)
+		}
+	}
+}
+
+// drain refuses new calls and returns a channel that is closed once there are no calls in
+// flight. It must only be called once.
+func (t *callTracker) drain() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.draining = true
+	t.idle = make(chan struct{})
+	if t.inFlight == 0 {
+		close(t.idle)
+	}
+	return t.idle
+}
+
+func (t *callTracker) unaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if !t.start() {
+		return nil, errServerDraining
+	}
+	defer t.done()
+	return handler(ctx, req)
+}
+
+func (t *callTracker) streamInterceptor(
+	srv interface{},
+	serverStream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if !t.start() {
+		return errServerDraining
+	}
+	defer t.done()
+	return handler(srv, serverStream)
+}
+
+// drain stops accepting new HTTP connections, refuses new calls, and waits up to the
+// shutdown grace period for the calls in flight to finish. Calls still in flight after
+// that are canceled as the server finishes stopping.
+func (ss *simpleServer) drain() error {
+	ss.logger.Infow("draining", "grace_period", ss.shutdownGracePeriod)
+	ctx, cancel := context.WithTimeout(context.Background(), ss.shutdownGracePeriod)
+	defer cancel()
+
+	// connections are shut down concurrently since idle HTTP/2 connections may otherwise be
+	// waited on for the whole grace period.
+	shutdownErr := make(chan error, 1)
+	utils.PanicCapturingGo(func() {
+		shutdownErr <- ss.httpServer.Shutdown(ctx)
+	})
+
+	select {
+	case <-ss.calls.drain():
+		ss.logger.Info("drained")
+	case <-ctx.Done():
+		ss.logger.Warn("shutdown grace period elapsed with calls still in flight")
+	}
+	cancel()
+
+	err := <-shutdownErr
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		err = ss.httpServer.Close()
+	}
+	return err
+}
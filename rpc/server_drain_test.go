@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestCallTracker(t *testing.T) {
+	var tracker callTracker
+	test.That(t, tracker.start(), test.ShouldBeTrue)
+	test.That(t, tracker.start(), test.ShouldBeTrue)
+	tracker.done()
+
+	idle := tracker.drain()
+	test.That(t, tracker.start(), test.ShouldBeFalse)
+	select {
+	case <-idle:
+		t.Fatal("expected a call to still be in flight")
+	default:
+	}
+	tracker.done()
+	<-idle
+
+	// requests tracked while draining are waited on but never refused
+	tracker.track()
+	tracker.done()
+
+	var idleTracker callTracker
+	<-idleTracker.drain()
+}
+
+func TestServerShutdownGracePeriod(t *testing.T) {
+	_, err := NewServer(golog.NewTestLogger(t), WithShutdownGracePeriod(-time.Second))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "must not be negative")
+
+	// startBlockingServer starts a server whose echo calls of "block" wait for release.
+	startBlockingServer := func(t *testing.T, gracePeriod time.Duration) (Server, string, <-chan struct{}, chan<- struct{}, <-chan error) {
+		t.Helper()
+		logger := golog.NewTestLogger(t)
+		blocked := make(chan struct{}, 1)
+		release := make(chan struct{})
+		rpcServer, err := NewServer(
+			logger,
+			WithDisableMulticastDNS(),
+			WithUnauthenticated(),
+			WithShutdownGracePeriod(gracePeriod),
+			WithUnaryServerInterceptor(func(
+				ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+			) (interface{}, error) {
+				if echoReq, ok := req.(*pb.EchoRequest); ok && echoReq.Message == "block" {
+					blocked <- struct{}{}
+					select {
+					case <-release:
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					}
+				}
+				return handler(ctx, req)
+			}),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+		listener, err := net.Listen("tcp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- rpcServer.Serve(listener)
+		}()
+		return rpcServer, listener.Addr().String(), blocked, release, serveErr
+	}
+
+	t.Run("drains calls in flight", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		rpcServer, address, blocked, release, serveErr := startBlockingServer(t, time.Minute)
+
+		conn, err := Dial(context.Background(), address, logger, WithInsecure(), WithForceDirectGRPC())
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		client := pb.NewEchoServiceClient(conn)
+
+		callErr := make(chan error, 1)
+		go func() {
+			resp, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "block"})
+			if err == nil && resp.Message != "block" {
+				err = status.Errorf(codes.Internal, "unexpected response %q", resp.Message)
+			}
+			callErr <- err
+		}()
+		<-blocked
+
+		stopErr := make(chan error, 1)
+		go func() {
+			stopErr <- rpcServer.Stop()
+		}()
+
+		// new calls are refused while the one in flight finishes
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			_, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+			test.That(tb, status.Code(err), test.ShouldEqual, codes.Unavailable)
+		})
+		select {
+		case err := <-stopErr:
+			t.Fatalf("expected stop to wait for the call in flight but it returned %v", err)
+		default:
+		}
+
+		close(release)
+		test.That(t, <-callErr, test.ShouldBeNil)
+		test.That(t, <-stopErr, test.ShouldBeNil)
+		test.That(t, <-serveErr, test.ShouldBeNil)
+	})
+
+	t.Run("grace period elapses", func(t *testing.T) {
+		logger := golog.NewTestLogger(t)
+		rpcServer, address, blocked, release, serveErr := startBlockingServer(t, 100*time.Millisecond)
+		defer close(release)
+
+		conn, err := Dial(context.Background(), address, logger, WithInsecure(), WithForceDirectGRPC())
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+
+		callErr := make(chan error, 1)
+		go func() {
+			_, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "block"})
+			callErr <- err
+		}()
+		<-blocked
+
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-serveErr, test.ShouldBeNil)
+		test.That(t, <-callErr, test.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// WithHTTP3 returns a server option that, when serving TLS, also serves HTTP/3 (QUIC) over
+// UDP on the same port as the listener given to ServeTLS. Responses served over TCP
+// advertise it with an Alt-Svc header so that browsers switch to it, which performs better
+// on lossy networks. It uses the same TLS configuration as HTTP/1.1 and HTTP/2 and serves
+// the same handler, so gRPC-Web and gateway requests work over it; native gRPC clients keep
+// using HTTP/2.
+func WithHTTP3() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.http3 = true
+		return nil
+	})
+}
+
+// serverHTTP3 serves HTTP/3 on a UDP connection.
+type serverHTTP3 struct {
+	server *http3.Server
+	conn   net.PacketConn
+}
+
+// listenHTTP3 listens for HTTP/3 on the UDP port of the given TCP listener using the
+// certificates and settings that the listener is served with.
+func (ss *simpleServer) listenHTTP3(listener net.Listener, certFile, keyFile string, tlsConfig *tls.Config) (*serverHTTP3, error) {
+	tcpAddr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return nil, errors.Errorf("HTTP/3 can only be served alongside a TCP listener but got %s", listener.Addr().Network())
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS13}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	// like http.Server.ServeTLS, certificate files take precedence over those in the config.
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: tcpAddr.IP, Port: tcpAddr.Port, Zone: tcpAddr.Zone})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen for HTTP/3")
+	}
+	return &serverHTTP3{
+		server: &http3.Server{
+			Port:           tcpAddr.Port,
+			TLSConfig:      requireClientCertificates(tlsConfig, ss.clientCAs),
+			Handler:        ss,
+			MaxHeaderBytes: ss.httpServer.MaxHeaderBytes,
+			QuicConfig: &quic.Config{
+				MaxIdleTimeout: ss.httpServer.IdleTimeout,
+			},
+		},
+		conn: conn,
+	}, nil
+}
+
+// serve serves HTTP/3 until closed.
+func (sh *serverHTTP3) serve() error {
+	err := sh.server.Serve(sh.conn)
+	if errors.Is(err, http.ErrServerClosed) || errors.Is(err, quic.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// advertise returns a handler that advertises HTTP/3 to clients of the given handler.
+func (sh *serverHTTP3) advertise(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// this only fails before HTTP/3 is being served, in which case it is not advertised.
+		utils.UncheckedError(sh.server.SetQuicHeaders(w.Header()))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// close immediately closes all HTTP/3 connections. Calls should already have been drained,
+// if desired, since HTTP/3 connections cannot be shut down gracefully yet.
+func (sh *serverHTTP3) close() error {
+	return multierr.Combine(sh.server.Close(), sh.conn.Close())
+}
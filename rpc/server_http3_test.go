@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"go.viam.com/test"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestServerHTTP3(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	_, certFile, keyFile, certPool, err := testutils.GenerateSelfSignedCertificate("localhost")
+	test.That(t, err, test.ShouldBeNil)
+	tlsConf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    certPool,
+		ServerName: "localhost",
+	}
+
+	rpcServer, err := NewServer(logger, WithDisableMulticastDNS(), WithUnauthenticated(), WithHTTP3())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.ServeTLS(listener, certFile, keyFile, nil)
+	}()
+	stopped := false
+	defer func() {
+		if !stopped {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+			test.That(t, <-errChan, test.ShouldBeNil)
+		}
+	}()
+
+	echoJSON := func(tb testing.TB, client *http.Client, message string) *http.Response {
+		tb.Helper()
+		httpURL := fmt.Sprintf("https://%s/rpc/examples/echo/v1/echo", listener.Addr().String())
+		req, err := http.NewRequest(http.MethodPost, httpURL, strings.NewReader(fmt.Sprintf(`{"message": %q}`, message)))
+		test.That(tb, err, test.ShouldBeNil)
+		req.Header.Add("content-type", "application/json")
+		httpResp, err := client.Do(req)
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, httpResp.StatusCode, test.ShouldEqual, 200)
+		var echoM map[string]interface{}
+		test.That(tb, json.NewDecoder(httpResp.Body).Decode(&echoM), test.ShouldBeNil)
+		test.That(tb, httpResp.Body.Close(), test.ShouldBeNil)
+		test.That(tb, echoM, test.ShouldResemble, map[string]interface{}{"message": message})
+		return httpResp
+	}
+
+	h3Transport := &http3.RoundTripper{
+		TLSClientConfig: tlsConf,
+		QuicConfig:      &quic.Config{HandshakeIdleTimeout: time.Second},
+	}
+	defer func() {
+		test.That(t, h3Transport.Close(), test.ShouldBeNil)
+	}()
+	h3Client := &http.Client{Transport: h3Transport}
+
+	t.Run("advertised over tcp", func(t *testing.T) {
+		transport := &http.Transport{TLSClientConfig: tlsConf}
+		defer transport.CloseIdleConnections()
+		httpClient := &http.Client{Transport: transport}
+
+		altSvc := fmt.Sprintf(`h3=":%d"`, listener.Addr().(*net.TCPAddr).Port)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			httpResp := echoJSON(tb, httpClient, "world")
+			test.That(tb, httpResp.ProtoMajor, test.ShouldNotEqual, 3)
+			test.That(tb, httpResp.Header.Get("Alt-Svc"), test.ShouldContainSubstring, altSvc)
+		})
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		httpResp := echoJSON(t, h3Client, "world")
+		test.That(t, httpResp.ProtoMajor, test.ShouldEqual, 3)
+		test.That(t, httpResp.Header.Get("Alt-Svc"), test.ShouldBeEmpty)
+	})
+
+	t.Run("grpc-web", func(t *testing.T) {
+		httpURL := fmt.Sprintf("https://%s/proto.rpc.examples.echo.v1.EchoService/Echo", listener.Addr().String())
+		req, err := http.NewRequest(http.MethodPost, httpURL, strings.NewReader(`AAAAAAYKBGhleSE=`))
+		test.That(t, err, test.ShouldBeNil)
+		req.Header.Add("content-type", "application/grpc-web-text")
+		httpResp, err := h3Client.Do(req)
+		test.That(t, err, test.ShouldBeNil)
+		defer httpResp.Body.Close()
+		test.That(t, httpResp.StatusCode, test.ShouldEqual, 200)
+		test.That(t, httpResp.ProtoMajor, test.ShouldEqual, 3)
+		rd, err := io.ReadAll(httpResp.Body)
+		test.That(t, err, test.ShouldBeNil)
+		// it says hey!
+		test.That(t, string(rd), test.ShouldResemble, "AAAAAAYKBGhleSE=gAAAABBncnBjLXN0YXR1czogMA0K")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		stopped = true
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+
+		// a new connection is needed since the old one was closed.
+		test.That(t, h3Transport.Close(), test.ShouldBeNil)
+		httpURL := fmt.Sprintf("https://%s/rpc/examples/echo/v1/echo", listener.Addr().String())
+		_, err := h3Client.Post(httpURL, "application/json", strings.NewReader(`{"message": "world"}`))
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
+
+func TestServerHTTP3Insecure(t *testing.T) {
+	logger, observedLogs := golog.NewObservedTestLogger(t)
+	rpcServer, err := NewServer(logger, WithDisableMulticastDNS(), WithUnauthenticated(), WithHTTP3())
+	test.That(t, err, test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, observedLogs.FilterMessage("HTTP/3 is only served over TLS; not serving it").Len(), test.ShouldEqual, 1)
+	})
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+	test.That(t, rpcServer.(*simpleServer).http3Servers, test.ShouldBeEmpty)
+}
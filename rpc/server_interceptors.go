@@ -83,6 +83,12 @@ func remoteSpanContextFromContext(ctx context.Context) (trace.SpanContext, error
 		return trace.SpanContext{}, errors.New("no metadata in context")
 	}
 
+	// Prefer the standard trace context and fall back to the trace-id, span-id, and
+	// trace-options sent by older clients.
+	if remoteSpanContext, ok := extractTraceContext(ctx, md); ok {
+		return remoteSpanContext, nil
+	}
+
 	// Extract trace-id
 	traceIDMetadata := md.Get("trace-id")
 	if len(traceIDMetadata) == 0 {
@@ -98,6 +104,9 @@ func remoteSpanContextFromContext(ctx context.Context) (trace.SpanContext, error
 
 	// Extract span-id
 	spanIDMetadata := md.Get("span-id")
+	if len(spanIDMetadata) == 0 {
+		return trace.SpanContext{}, errors.New("span-id is missing from metadata")
+	}
 	spanIDBytes, err := hex.DecodeString(spanIDMetadata[0])
 	if err != nil {
 		return trace.SpanContext{}, fmt.Errorf("span-id could not be decoded: %w", err)
@@ -119,3 +128,11 @@ func remoteSpanContextFromContext(ctx context.Context) (trace.SpanContext, error
 
 	return trace.SpanContext{TraceID: traceID, SpanID: spanID, TraceOptions: traceOptions, Tracestate: nil}, nil
 }
+
+// endSpan ends the span, recording the error, if any, as its status.
+func endSpan(span *trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: int32(status.Code(err)), Message: err.Error()})
+	}
+	span.End()
+}
@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// redactedValue replaces the value of redacted string fields.
+const redactedValue = "[REDACTED]"
+
+// A MethodSampleRate is the fraction of successful calls to the methods it matches that
+// are logged.
+type MethodSampleRate struct {
+	// Methods are patterns (see path.Match) of the full method names the rate applies to.
+	// If empty, it applies to all methods.
+	Methods []string
+
+	// Rate is between 0 (never log) and 1 (always log).
+	Rate float64
+}
+
+// RequestLoggingOptions configure what a RequestLogger logs.
+type RequestLoggingOptions struct {
+	// SampleRates sample successful calls to high volume methods. The first rate matching
+	// a method applies; methods matching none are always logged. Failed calls are always
+	// logged.
+	SampleRates []MethodSampleRate
+
+	// Metadata are the keys of incoming metadata to log, if present.
+	Metadata []string
+
+	// LogRequests logs the request messages of unary calls as JSON.
+	LogRequests bool
+
+	// RedactFields are the full names of proto fields (e.g. proto.rpc.v1.Credentials.payload)
+	// whose values are never logged. String fields are replaced and all others are cleared.
+	RedactFields []string
+}
+
+// A RequestLogger logs the method, duration, status, request size, and selected metadata
+// of calls. Register its interceptors with WithUnaryInterceptors and WithStreamInterceptors;
+// at InterceptorStageBeforeAuth it also logs calls that fail to authenticate.
+type RequestLogger struct {
+	opts         RequestLoggingOptions
+	redactFields map[protoreflect.FullName]bool
+	logger       golog.Logger
+	random       func() float64
+}
+
+// NewRequestLogger returns a new RequestLogger logging to the given logger.
+func NewRequestLogger(logger golog.Logger, opts RequestLoggingOptions) (*RequestLogger, error) {
+	for _, rate := range opts.SampleRates {
+		if rate.Rate < 0 || rate.Rate > 1 {
+			return nil, errors.Errorf("expected sample rate between 0 and 1 but got %v", rate.Rate)
+		}
+		for _, pattern := range rate.Methods {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern %q", pattern)
+			}
+		}
+	}
+	redactFields := make(map[protoreflect.FullName]bool, len(opts.RedactFields))
+	for _, field := range opts.RedactFields {
+		name := protoreflect.FullName(field)
+		if !name.IsValid() {
+			return nil, errors.Errorf("invalid field name %q", field)
+		}
+		redactFields[name] = true
+	}
+	return &RequestLogger{
+		opts:         opts,
+		redactFields: redactFields,
+		logger:       logger,
+		//nolint:gosec
+		random: rand.Float64,
+	}, nil
+}
+
+// sampled returns whether a successful call to the method should be logged.
+func (l *RequestLogger) sampled(fullMethod string) bool {
+	for _, rate := range l.opts.SampleRates {
+		if len(rate.Methods) == 0 || matchesAny(rate.Methods, fullMethod) {
+			return l.random() < rate.Rate
+		}
+	}
+	return true
+}
+
+func (l *RequestLogger) log(ctx context.Context, fullMethod string, started time.Time, requestSize int, req interface{}, err error) {
+	if err == nil && !l.sampled(fullMethod) {
+		return
+	}
+	fields := []interface{}{
+		"method", fullMethod,
+		"duration", time.Since(started),
+		"code", status.Code(err).String(),
+		"request_size", requestSize,
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(l.opts.Metadata) != 0 {
+		logged := map[string][]string{}
+		for _, key := range l.opts.Metadata {
+			if values := md.Get(key); len(values) != 0 {
+				logged[key] = values
+			}
+		}
+		if len(logged) != 0 {
+			fields = append(fields, "metadata", logged)
+		}
+	}
+	if msg, ok := req.(proto.Message); ok && l.opts.LogRequests {
+		if reqJSON, marshalErr := protojson.Marshal(l.redact(msg)); marshalErr == nil {
+			fields = append(fields, "request", string(reqJSON))
+		}
+	}
+	if err != nil {
+		l.logger.Warnw("request failed", append(fields, "error", status.Convert(err).Message())...)
+		return
+	}
+	l.logger.Infow("request", fields...)
+}
+
+// redact returns a copy of the message without the values of redacted fields.
+func (l *RequestLogger) redact(msg proto.Message) proto.Message {
+	if len(l.redactFields) == 0 {
+		return msg
+	}
+	msg = proto.Clone(msg)
+	l.redactMessage(msg.ProtoReflect())
+	return msg
+}
+
+func (l *RequestLogger) redactMessage(msg protoreflect.Message) {
+	// fields are redacted after ranging over them since the message may not be mutated while ranging.
+	var redacted []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if l.redactFields[fd.FullName()] {
+			redacted = append(redacted, fd)
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				l.redactMessage(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				l.redactMessage(v.Message())
+				return true
+			})
+		case fd.Message() != nil && !fd.IsMap():
+			l.redactMessage(v.Message())
+		}
+		return true
+	})
+	for _, fd := range redacted {
+		if fd.Kind() == protoreflect.StringKind && fd.Cardinality() != protoreflect.Repeated {
+			msg.Set(fd, protoreflect.ValueOfString(redactedValue))
+		} else {
+			msg.Clear(fd)
+		}
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor logging unary calls.
+func (l *RequestLogger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started := time.Now()
+		resp, err := handler(ctx, req)
+		var requestSize int
+		if msg, ok := req.(proto.Message); ok {
+			requestSize = proto.Size(msg)
+		}
+		l.log(ctx, info.FullMethod, started, requestSize, req, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor logging streams once they finish. The
+// request size of a stream is the total size of the messages received on it.
+func (l *RequestLogger) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, serverStream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		started := time.Now()
+		stream := &sizeCountingServerStream{ServerStream: serverStream}
+		err := handler(srv, stream)
+		l.log(serverStream.Context(), info.FullMethod, started, stream.received, nil, err)
+		return err
+	}
+}
+
+// sizeCountingServerStream counts the size of the messages received on a stream.
+type sizeCountingServerStream struct {
+	grpc.ServerStream
+	received int
+}
+
+func (s *sizeCountingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if msg, ok := m.(proto.Message); ok {
+		s.received += proto.Size(msg)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+)
+
+func TestRequestLogger(t *testing.T) {
+	_, err := NewRequestLogger(golog.NewTestLogger(t), RequestLoggingOptions{
+		SampleRates: []MethodSampleRate{{Rate: 2}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "between 0 and 1")
+
+	_, err = NewRequestLogger(golog.NewTestLogger(t), RequestLoggingOptions{
+		SampleRates: []MethodSampleRate{{Methods: []string{"["}, Rate: 1}},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid pattern")
+
+	_, err = NewRequestLogger(golog.NewTestLogger(t), RequestLoggingOptions{
+		RedactFields: []string{"not a field"},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid field name")
+
+	const authMethod = "/proto.rpc.v1.AuthService/Authenticate"
+	const echoMethod = "/proto.rpc.examples.echo.v1.EchoService/Echo"
+
+	t.Run("unary", func(t *testing.T) {
+		logger, observedLogs := golog.NewObservedTestLogger(t)
+		requestLogger, err := NewRequestLogger(logger, RequestLoggingOptions{
+			Metadata:     []string{"user-agent", "missing"},
+			LogRequests:  true,
+			RedactFields: []string{"proto.rpc.v1.Credentials.payload"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		interceptor := requestLogger.UnaryServerInterceptor()
+
+		req := &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "api-key", Payload: "secret"},
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "test", "authorization", "token"))
+		_, err = interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: authMethod},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &rpcpb.AuthenticateResponse{}, nil
+			})
+		test.That(t, err, test.ShouldBeNil)
+		// the request itself is left alone
+		test.That(t, req.Credentials.Payload, test.ShouldEqual, "secret")
+
+		entries := observedLogs.FilterMessage("request").All()
+		test.That(t, entries, test.ShouldHaveLength, 1)
+		fields := entries[0].ContextMap()
+		test.That(t, fields["method"], test.ShouldEqual, authMethod)
+		test.That(t, fields["code"], test.ShouldEqual, codes.OK.String())
+		test.That(t, fields["request_size"], test.ShouldBeGreaterThan, 0)
+		test.That(t, fields["metadata"], test.ShouldResemble, map[string][]string{"user-agent": {"test"}})
+		test.That(t, fields["request"], test.ShouldContainSubstring, "foo")
+		test.That(t, fields["request"], test.ShouldContainSubstring, redactedValue)
+		test.That(t, fields["request"], test.ShouldNotContainSubstring, "secret")
+
+		_, err = interceptor(context.Background(), &pb.EchoRequest{Message: "hi"}, &grpc.UnaryServerInfo{FullMethod: echoMethod},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.InvalidArgument, "bad")
+			})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.InvalidArgument)
+		entries = observedLogs.FilterMessage("request failed").All()
+		test.That(t, entries, test.ShouldHaveLength, 1)
+		fields = entries[0].ContextMap()
+		test.That(t, fields["code"], test.ShouldEqual, codes.InvalidArgument.String())
+		test.That(t, fields["error"], test.ShouldEqual, "bad")
+		test.That(t, fields["request"], test.ShouldContainSubstring, "hi")
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		logger, observedLogs := golog.NewObservedTestLogger(t)
+		requestLogger, err := NewRequestLogger(logger, RequestLoggingOptions{
+			SampleRates: []MethodSampleRate{
+				{Methods: []string{"/proto.rpc.examples.echo.v1.EchoService/*"}, Rate: 0.25},
+				{Rate: 0},
+			},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		random := 0.5
+		requestLogger.random = func() float64 { return random }
+		interceptor := requestLogger.UnaryServerInterceptor()
+
+		call := func(method string, callErr error) {
+			t.Helper()
+			_, err := interceptor(context.Background(), &pb.EchoRequest{}, &grpc.UnaryServerInfo{FullMethod: method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, callErr
+				})
+			test.That(t, err, test.ShouldEqual, callErr)
+		}
+
+		call(echoMethod, nil)
+		test.That(t, observedLogs.Len(), test.ShouldEqual, 0)
+		random = 0.1
+		call(echoMethod, nil)
+		test.That(t, observedLogs.Len(), test.ShouldEqual, 1)
+
+		// rates without methods apply to all others
+		call(authMethod, nil)
+		test.That(t, observedLogs.Len(), test.ShouldEqual, 1)
+
+		// failures are always logged
+		random = 0.9
+		call(echoMethod, status.Error(codes.Internal, "whoops"))
+		test.That(t, observedLogs.Len(), test.ShouldEqual, 2)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		logger, observedLogs := golog.NewObservedTestLogger(t)
+		requestLogger, err := NewRequestLogger(logger, RequestLoggingOptions{})
+		test.That(t, err, test.ShouldBeNil)
+		interceptor := requestLogger.StreamServerInterceptor()
+
+		const bidiMethod = "/proto.rpc.examples.echo.v1.EchoService/EchoBiDi"
+		stream := &recvServerStream{ctx: context.Background(), msgs: []string{"hello", "world"}}
+		err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: bidiMethod}, func(srv interface{}, stream grpc.ServerStream) error {
+			for {
+				var req pb.EchoBiDiRequest
+				if err := stream.RecvMsg(&req); err != nil {
+					return nil
+				}
+			}
+		})
+		test.That(t, err, test.ShouldBeNil)
+
+		entries := observedLogs.FilterMessage("request").All()
+		test.That(t, entries, test.ShouldHaveLength, 1)
+		fields := entries[0].ContextMap()
+		test.That(t, fields["method"], test.ShouldEqual, bidiMethod)
+		test.That(t, fields["request_size"], test.ShouldEqual, int64(14))
+	})
+}
+
+// recvServerStream is a server stream that receives echo messages.
+type recvServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	msgs []string
+}
+
+func (s *recvServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *recvServerStream) RecvMsg(m interface{}) error {
+	if len(s.msgs) == 0 {
+		return status.Error(codes.Canceled, "done")
+	}
+	m.(*pb.EchoBiDiRequest).Message = s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return nil
+}
@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsPath is where a server's http.Handler serves its metrics when they are recorded
+// on a registry that can be gathered. See WithMetrics.
+const MetricsPath = "/metrics"
+
+// The transports that calls are labeled with.
+const (
+	metricsTransportGRPC   = "grpc"
+	metricsTransportWebRTC = "webrtc"
+)
+
+// WithMetrics returns a ServerOption which records Prometheus metrics about the server on
+// the given registerer: the latency and number of in flight calls per transport and method,
+// bytes sent and received over WebRTC data channels, active WebRTC peer connections, how
+// long answering WebRTC signaling negotiations takes, and authentication failures. If the
+// registerer is also a prometheus.Gatherer (e.g. a *prometheus.Registry), everything it
+// gathers is served at MetricsPath by the server's http.Handler.
+func WithMetrics(registerer prometheus.Registerer) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if registerer == nil {
+			return errors.New("expected a metrics registerer")
+		}
+		o.metricsRegisterer = registerer
+		return nil
+	})
+}
+
+// serverMetrics are the metrics recorded about a server. All methods may be called on a
+// nil *serverMetrics, in which case nothing is recorded.
+type serverMetrics struct {
+	callsInFlight       *prometheus.GaugeVec
+	callDuration        *prometheus.HistogramVec
+	dataChannelBytes    *prometheus.CounterVec
+	peerConnections     prometheus.Gauge
+	negotiationDuration *prometheus.HistogramVec
+	authFailures        *prometheus.CounterVec
+}
+
+func newServerMetrics(registerer prometheus.Registerer) (*serverMetrics, error) {
+	metrics := &serverMetrics{
+		callsInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "rpc",
+			Subsystem: "server",
+			Name:      "calls_in_flight",
+			Help:      "The number of calls currently being handled.",
+		}, []string{"transport", "method"}),
+		callDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "rpc",
+			Subsystem: "server",
+			Name:      "call_duration_seconds",
+			Help:      "How long calls took to handle, including the entire lifetime of streams.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"transport", "method", "code"}),
+		dataChannelBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "rpc",
+			Subsystem: "webrtc",
+			Name:      "data_channel_bytes_total",
+			Help:      "The number of bytes sent and received over data channels.",
+		}, []string{"direction"}),
+		peerConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "rpc",
+			Subsystem: "webrtc",
+			Name:      "peer_connections",
+			Help:      "The number of peer connections currently held by the server.",
+		}),
+		negotiationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "rpc",
+			Subsystem: "webrtc",
+			Name:      "signaling_negotiation_duration_seconds",
+			Help:      "How long answering an offer took until the peer connection was ready or failed.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "rpc",
+			Subsystem: "server",
+			Name:      "auth_failures_total",
+			Help:      "The number of rejected credentials and access tokens.",
+		}, []string{"type"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		metrics.callsInFlight,
+		metrics.callDuration,
+		metrics.dataChannelBytes,
+		metrics.peerConnections,
+		metrics.negotiationDuration,
+		metrics.authFailures,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, errors.Wrap(err, "failed to register metrics")
+		}
+	}
+	return metrics, nil
+}
+
+// metricsHandler returns a handler serving the metrics gathered by the registerer, if it
+// can be gathered.
+func metricsHandler(registerer prometheus.Registerer) (http.Handler, bool) {
+	gatherer, ok := registerer.(prometheus.Gatherer)
+	if !ok {
+		return nil, false
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}), true
+}
+
+func (m *serverMetrics) callStarted(transport, method string) func(err error) {
+	if m == nil {
+		return func(err error) {}
+	}
+	started := time.Now()
+	inFlight := m.callsInFlight.WithLabelValues(transport, method)
+	inFlight.Inc()
+	return func(err error) {
+		inFlight.Dec()
+		m.callDuration.WithLabelValues(transport, method, status.Code(err).String()).Observe(time.Since(started).Seconds())
+	}
+}
+
+func (m *serverMetrics) unaryInterceptor(transport string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := m.callStarted(transport, info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+func (m *serverMetrics) streamInterceptor(transport string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, serverStream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := m.callStarted(transport, info.FullMethod)
+		err := handler(srv, serverStream)
+		done(err)
+		return err
+	}
+}
+
+func (m *serverMetrics) dataChannelBytesSent(n int) {
+	if m == nil {
+		return
+	}
+	m.dataChannelBytes.WithLabelValues("sent").Add(float64(n))
+}
+
+func (m *serverMetrics) dataChannelBytesReceived(n int) {
+	if m == nil {
+		return
+	}
+	m.dataChannelBytes.WithLabelValues("received").Add(float64(n))
+}
+
+func (m *serverMetrics) peerConnectionAdded() {
+	if m == nil {
+		return
+	}
+	m.peerConnections.Inc()
+}
+
+func (m *serverMetrics) peerConnectionRemoved() {
+	if m == nil {
+		return
+	}
+	m.peerConnections.Dec()
+}
+
+func (m *serverMetrics) negotiationDone(started time.Time, successful bool) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if !successful {
+		result = "failure"
+	}
+	m.negotiationDuration.WithLabelValues(result).Observe(time.Since(started).Seconds())
+}
+
+// The types of authentication failures.
+const (
+	authFailureCredentials = "credentials"
+	authFailureAccessToken = "access_token"
+)
+
+func (m *serverMetrics) authFailed(failureType string) {
+	if m == nil {
+		return
+	}
+	m.authFailures.WithLabelValues(failureType).Inc()
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestServerMetrics(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	registry := prometheus.NewRegistry()
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithMetrics(registry),
+		WithAuthHandler("fake", AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			if payload != "pass" {
+				return nil, errors.New("wrong password")
+			}
+			return map[string]string{}, nil
+		})),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	// the same registry cannot be used twice
+	_, err = NewServer(logger, WithUnauthenticated(), WithMetrics(registry))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "failed to register metrics")
+
+	es := echoserver.Server{}
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	metrics := rpcServer.(*simpleServer).metrics
+	const echoMethod = "/proto.rpc.examples.echo.v1.EchoService/Echo"
+
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.DialContext(
+			context.Background(),
+			listener.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithBlock(),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+
+		authClient := rpcpb.NewAuthServiceClient(conn)
+		_, err = authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "fail"},
+		})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+		test.That(t, testutil.ToFloat64(metrics.authFailures.WithLabelValues(authFailureCredentials)), test.ShouldEqual, 1)
+
+		client := pb.NewEchoServiceClient(conn)
+		badCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer notatoken"))
+		_, err = client.Echo(badCtx, &pb.EchoRequest{Message: "hello"})
+		test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+		test.That(t, testutil.ToFloat64(metrics.authFailures.WithLabelValues(authFailureAccessToken)), test.ShouldEqual, 1)
+
+		authResp, err := authClient.Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "pass"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.AccessToken))
+		_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+
+		test.That(t, testutil.CollectAndCount(metrics.callDuration.MustCurryWith(prometheus.Labels{
+			"transport": metricsTransportGRPC,
+			"method":    echoMethod,
+		})), test.ShouldEqual, 2)
+		test.That(t, testutil.ToFloat64(metrics.callsInFlight.WithLabelValues(metricsTransportGRPC, echoMethod)), test.ShouldEqual, 0)
+	})
+
+	t.Run("webrtc", func(t *testing.T) {
+		var rtcConn ClientConn
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			var err error
+			rtcConn, err = dialWebRTC(context.Background(), listener.Addr().String(), "yeehaw", dialOptions{
+				webrtcOpts: DialWebRTCOptions{
+					SignalingInsecure: true,
+					SignalingCreds:    Credentials{Type: "fake", Payload: "pass"},
+				},
+				webrtcOptsSet: true,
+			}, logger)
+			test.That(tb, err, test.ShouldBeNil)
+		})
+
+		_, err = pb.NewEchoServiceClient(rtcConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+
+		test.That(t, testutil.ToFloat64(metrics.peerConnections), test.ShouldEqual, 1)
+		test.That(t, testutil.CollectAndCount(metrics.negotiationDuration), test.ShouldBeGreaterThanOrEqualTo, 1)
+		test.That(t, testutil.ToFloat64(metrics.dataChannelBytes.WithLabelValues("sent")), test.ShouldBeGreaterThan, 0)
+		test.That(t, testutil.ToFloat64(metrics.dataChannelBytes.WithLabelValues("received")), test.ShouldBeGreaterThan, 0)
+		test.That(t, testutil.CollectAndCount(metrics.callDuration.MustCurryWith(prometheus.Labels{
+			"transport": metricsTransportWebRTC,
+			"method":    echoMethod,
+		})), test.ShouldEqual, 1)
+
+		test.That(t, rtcConn.Close(), test.ShouldBeNil)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, testutil.ToFloat64(metrics.peerConnections), test.ShouldEqual, 0)
+		})
+	})
+
+	t.Run("served", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, MetricsPath, nil)
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, req)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		body, err := io.ReadAll(w.Body)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, string(body), test.ShouldContainSubstring, "rpc_server_call_duration_seconds")
+		test.That(t, string(body), test.ShouldContainSubstring, `rpc_server_auth_failures_total{type="credentials"} 1`)
+	})
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -2,27 +2,40 @@ package rpc
 
 import (
 	"context"
+	"crypto"
 	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
+	"io"
 	"net"
+	"os"
+	"time"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/stats"
 
 	"go.viam.com/utils/jwks"
+	"go.viam.com/utils/web/cors"
 )
 
 // serverOptions change the runtime behavior of the server.
 type serverOptions struct {
 	bindAddress       string
+	unixSocketPerm    os.FileMode
 	listenerAddress   *net.TCPAddr
 	tlsConfig         *tls.Config
 	webrtcOpts        WebRTCServerOptions
 	unaryInterceptor  grpc.UnaryServerInterceptor
 	streamInterceptor grpc.StreamServerInterceptor
 
+	// unaryInterceptors and streamInterceptors are run by every transport at their stage.
+	unaryInterceptors  map[InterceptorStage][]grpc.UnaryServerInterceptor
+	streamInterceptors map[InterceptorStage][]grpc.StreamServerInterceptor
+
 	// instanceNames are the name of this server and will be used
 	// to report itself over mDNS.
 	instanceNames []string
@@ -30,14 +43,48 @@ type serverOptions struct {
 	// unauthenticated determines if requests should be authenticated.
 	unauthenticated bool
 
+	// reflection registers the gRPC server reflection service.
+	reflection bool
+
+	// healthService registers the standard gRPC health service.
+	healthService bool
+
+	// shutdownGracePeriod is how long stopping waits for calls in flight to finish.
+	shutdownGracePeriod time.Duration
+
+	// maxRecvMsgSize and maxSendMsgSize, if set, limit the size of messages over every transport.
+	maxRecvMsgSize int
+	maxSendMsgSize int
+
+	// keepalive, if set, configures keepalive pings and their enforcement.
+	keepalive *KeepaliveOptions
+
+	// connectionAge, if set, limits how long connections live.
+	connectionAge *ConnectionAgeOptions
+
+	// cors, if set, handles CORS for gRPC-Web and gateway requests.
+	cors *cors.Cors
+
+	// gatewayMuxOpts configure the gateway mux.
+	gatewayMuxOpts []runtime.ServeMuxOption
+
+	// gatewayHandlers are registered on the gateway mux when the server is created.
+	gatewayHandlers []RegisterServiceHandlerFromEndpointFunc
+
 	// allowUnauthenticatedHealthCheck allows the server to have an unauthenticated healthcheck endpoint
 	allowUnauthenticatedHealthCheck bool
 
 	// publicMethods are api routes that attempt, but do not require, authentication
 	publicMethods []string
 
-	// authRSAPrivateKey is used to sign JWTs for authentication
-	authRSAPrivateKey *rsa.PrivateKey
+	// authPrivateKey is used to sign JWTs for authentication
+	authPrivateKey crypto.Signer
+
+	// authKeyRotation, if set, rotates the keys used to sign JWTs.
+	authKeyRotation *AuthKeyRotationOptions
+
+	// publishAuthKeys publishes the public keys that verify JWTs.
+	publishAuthKeys bool
 
 	// debug is helpful to turn on when the library isn't working quite right.
 	// It will output much more logs.
@@ -46,6 +93,10 @@ type serverOptions struct {
 	tlsAuthHandler       func(ctx context.Context, entities ...string) error
 	authHandlersForCreds map[CredentialsType]credAuthHandlers
 
+	// clientCAs, if set, are used to verify the client certificates that are
+	// required of every TLS connection.
+	clientCAs *x509.CertPool
+
 	// authAudience is the JWT audience (aud) that will be used/expected
 	// for our service. When unset, it will be debug logged that
 	// the instance names will be used instead.
@@ -59,9 +110,36 @@ type serverOptions struct {
 	authToHandler AuthenticateToHandler
 	disableMDNS   bool
 
+	// authRateLimit, if set, limits attempts to authenticate.
+	authRateLimit *AuthRateLimitOptions
+
+	// autocert, if set, obtains certificates via ACME.
+	autocert *AutocertOptions
+
+	// http3, if set, also serves HTTP/3 when serving TLS.
+	http3 bool
+
+	// auditLog, if set, records calls to the server.
+	auditLog *AuditLogOptions
+
+	// methodRateLimits limit how often methods may be called.
+	methodRateLimits []MethodRateLimit
+
+	// authzPolicies, authzDefaultDeny, and authzDenialHook configure what methods
+	// authenticated entities may call.
+	authzPolicies    []AuthorizationPolicy
+	authzDefaultDeny bool
+	authzDenialHook  func(ctx context.Context, denial AuthorizationDenial)
+
 	// stats monitoring on the connections.
 	statsHandler stats.Handler
 
+	// metricsRegisterer, if set, is where metrics about the server are registered.
+	metricsRegisterer prometheus.Registerer
+
+	// debugEndpoints, if set, serves debug endpoints under DebugPathPrefix.
+	debugEndpoints *DebugEndpointsOptions
+
 	unknownStreamDesc *grpc.StreamDesc
 }
 
@@ -103,7 +181,93 @@ type WebRTCServerOptions struct {
 
 	// OnPeerRemoved is called when an existing peer connection is removed.
 	OnPeerRemoved func(pc *webrtc.PeerConnection)
-}
+
+	// InterfaceFilter, if set, restricts which network interfaces may be used to
+	// gather ICE candidates for answered peers. It should return true for
+	// interfaces that are allowed.
+	InterfaceFilter func(name string) bool
+
+	// IPFilter, if set, restricts which IPs may be used to gather ICE candidates
+	// for answered peers. It should return true for IPs that are allowed. Note
+	// that only IPv4 addresses are ever considered.
+	IPFilter func(ip net.IP) bool
+
+	// ICETimeouts tune the ICE timeouts of answered peers. Zero fields use their defaults.
+	ICETimeouts ICETimeouts
+
+	// HeartbeatInterval is how often a heartbeat is sent to peers that participate
+	// in heartbeats. If zero, DefaultWebRTCHeartbeatInterval is used. If negative,
+	// heartbeats are disabled.
+	HeartbeatInterval time.Duration
+
+	// HeartbeatTimeout is how long to go without a heartbeat from a peer before
+	// its connection is closed. If zero, DefaultWebRTCHeartbeatTimeout is used.
+	HeartbeatTimeout time.Duration
+
+	// MaxPeerConnections is the maximum number of peer connections the server will
+	// have at any one time across all of the hosts it answers for, including WebSocket
+	// tunnels. If zero or negative, there is no limit.
+	MaxPeerConnections int
+
+	// PeerConnectionLimitPolicy determines what happens to new offers once
+	// MaxPeerConnections is reached. It defaults to rejecting them.
+	PeerConnectionLimitPolicy PeerConnectionLimitPolicy
+
+	// OnLocalDescription, if set, is called with every local session description
+	// of an answered peer right before it is applied and may return a modified
+	// version of it.
+	OnLocalDescription SDPHook
+
+	// OnRemoteDescription, if set, is called with every remote session description
+	// of an answered peer right before it is applied and may return a modified
+	// version of it.
+	OnRemoteDescription SDPHook
+
+	// OnPeerEvent, if set, is called with every lifecycle event of every answered
+	// peer connection.
+	OnPeerEvent func(event PeerEvent)
+
+	// Compressors are the names of registered compressors the server may compress
+	// responses with, in order of preference. A compressor is only used for a stream
+	// when the client advertises support for it. If empty, responses are never
+	// compressed.
+	Compressors []string
+
+	// FrameTraceWriter, if set, receives a trace of every frame sent or received over
+	// the WebRTC channels of answered peers. See FrameTrace for the format.
+	FrameTraceWriter io.Writer
+
+	// MaxChannelSendRate, if positive, is the most bytes per second the server sends on
+	// the channel of each answered peer, shared by all of its streams. Bursts of up to a
+	// second's worth of bytes, and never less than a full data channel message, are allowed.
+	MaxChannelSendRate float64
+
+	// EnableWebSocketTunnel accepts channels tunneled over WebSockets at WebSocketTunnelPath
+	// for clients on networks where WebRTC cannot connect, such as those blocking all UDP.
+	// Tunneled channels are served just like WebRTC ones but must authenticate when
+	// opening the tunnel since no signaler vouches for them.
+	EnableWebSocketTunnel bool
+
+	// EnablePeersDebugEndpoint serves the peer connections of the server, and actions on
+	// them, at PeersDebugPath. Unless the server is unauthenticated, requests must
+	// authenticate like any other call, and authorization policies see them as calls to
+	// a method named after their path. The networks allowed by WithDebugEndpoints apply to
+	// it as well, so an unauthenticated server only serves it to loopback addresses unless
+	// others are allowed. Peers are only closed by requests from the same origin.
+	EnablePeersDebugEndpoint bool
+}
+
+// A PeerConnectionLimitPolicy determines how a server at its maximum number of
+// peer connections handles a new offer.
+type PeerConnectionLimitPolicy int
+
+const (
+	// PeerConnectionLimitReject rejects new offers until an existing peer goes away.
+	PeerConnectionLimitReject PeerConnectionLimitPolicy = iota
+	// PeerConnectionLimitEvictIdle evicts the least recently active peer in order to
+	// make room for the new one.
+	PeerConnectionLimitEvictIdle
+)
 
 // A ServerOption changes the runtime behavior of the server.
 // Cribbed from https://github.com/grpc/grpc-go/blob/aff571cc86e6e7e740130dbbb32a9741558db805/dialoptions.go#L41
@@ -130,7 +294,10 @@ func newFuncServerOption(f func(*serverOptions) error) *funcServerOption {
 // WithInternalBindAddress returns a ServerOption which sets the bind address
 // for the gRPC listener. If unset, the address is localhost on a
 // random port unless TLS is turned on and authentication is enabled
-// in which case the server will bind to all interfaces.
+// in which case the server will bind to all interfaces. An address like
+// unix:///path/to.sock binds to a UNIX domain socket instead (see utils.ListenUnix),
+// in which case the server is not advertised over mDNS unless an external
+// listener address is also set.
 func WithInternalBindAddress(address string) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
 		o.bindAddress = address
@@ -138,6 +305,19 @@ func WithInternalBindAddress(address string) ServerOption {
 	})
 }
 
+// WithUnixSocketPermissions returns a ServerOption which sets the permissions of the
+// UNIX domain socket the gRPC listener binds to when its address is one (see
+// WithInternalBindAddress). If unset, they are determined by the umask.
+func WithUnixSocketPermissions(perm os.FileMode) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if perm&^os.ModePerm != 0 {
+			return errors.Errorf("invalid UNIX socket permissions %v", perm)
+		}
+		o.unixSocketPerm = perm
+		return nil
+	})
+}
+
 // WithExternalListenerAddress returns a ServerOption which sets the listener address
 // if the server is going to be served via its handlers and not internally.
 // This is only helpful for mDNS broadcasting. If the server has TLS enabled
@@ -155,13 +335,19 @@ func WithExternalListenerAddress(address *net.TCPAddr) ServerOption {
 // work (see WithTLSAuthHandler). When using ServeTLS on the server, which serves
 // from an external listener, with mutual TLS authentication, you will want to pass
 // its own tls.Config with ClientAuth, at a minimum, set to tls.VerifyClientCertIfGiven.
+// The config may provide its certificates via GetCertificate instead of Certificates,
+// such as to choose them by server name (SNI) or to reload them (see
+// utils.CertificateReloader). If ClientAuth is unset, it defaults to
+// tls.VerifyClientCertIfGiven; otherwise the given policy is kept.
 func WithInternalTLSConfig(config *tls.Config) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
 		o.tlsConfig = config.Clone()
-		if o.tlsConfig.ClientAuth == 0 {
-			o.tlsConfig.ClientAuth = tls.VersionTLS12
+		if o.tlsConfig.MinVersion == 0 {
+			o.tlsConfig.MinVersion = tls.VersionTLS12
+		}
+		if o.tlsConfig.ClientAuth == tls.NoClientCert {
+			o.tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
 		}
-		o.tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
 		return nil
 	})
 }
@@ -177,7 +363,7 @@ func WithWebRTCServerOptions(webrtcOpts WebRTCServerOptions) ServerOption {
 
 // WithUnaryServerInterceptor returns a ServerOption that sets a interceptor for
 // all unary grpc methods registered. It will run after authentication and prior
-// to the registered method.
+// to the registered method. See WithUnaryInterceptors to add more than one.
 func WithUnaryServerInterceptor(unaryInterceptor grpc.UnaryServerInterceptor) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
 		o.unaryInterceptor = unaryInterceptor
@@ -187,7 +373,7 @@ func WithUnaryServerInterceptor(unaryInterceptor grpc.UnaryServerInterceptor) Se
 
 // WithStreamServerInterceptor returns a ServerOption that sets a interceptor for
 // all stream grpc methods registered. It will run after authentication and prior
-// to the registered method.
+// to the registered method. See WithStreamInterceptors to add more than one.
 func WithStreamServerInterceptor(streamInterceptor grpc.StreamServerInterceptor) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
 		o.streamInterceptor = streamInterceptor
@@ -195,6 +381,64 @@ func WithStreamServerInterceptor(streamInterceptor grpc.StreamServerInterceptor)
 	})
 }
 
+// An InterceptorStage is where in the server's chain of interceptors the interceptors
+// added by WithUnaryInterceptors and WithStreamInterceptors run.
+type InterceptorStage int
+
+const (
+	// InterceptorStageAfterAuth runs interceptors after authentication, authorization, and
+	// rate limiting, such that the authenticated entity is available in the context. Methods
+	// that do not perform any auth (e.g. Authenticate) are not intercepted. These run prior
+	// to any interceptor set by WithUnaryServerInterceptor or WithStreamServerInterceptor.
+	InterceptorStageAfterAuth InterceptorStage = iota
+	// InterceptorStageBeforeAuth runs interceptors for every call before it is authenticated,
+	// after recovering from panics, logging, and tracing.
+	InterceptorStageBeforeAuth
+)
+
+// WithUnaryInterceptors returns a ServerOption that adds interceptors for all unary
+// methods at the given stage. Unlike WithUnaryServerInterceptor, it may be used more than
+// once. Interceptors at the same stage run in the order they were added. They run
+// identically for calls made over direct gRPC, grpc-web, the gateway, and WebRTC.
+func WithUnaryInterceptors(stage InterceptorStage, interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if err := stage.validate(); err != nil {
+			return err
+		}
+		if o.unaryInterceptors == nil {
+			o.unaryInterceptors = map[InterceptorStage][]grpc.UnaryServerInterceptor{}
+		}
+		o.unaryInterceptors[stage] = append(o.unaryInterceptors[stage], interceptors...)
+		return nil
+	})
+}
+
+// WithStreamInterceptors returns a ServerOption that adds interceptors for all stream
+// methods at the given stage. Unlike WithStreamServerInterceptor, it may be used more than
+// once. Interceptors at the same stage run in the order they were added. They run
+// identically for calls made over direct gRPC, grpc-web, the gateway, and WebRTC.
+func WithStreamInterceptors(stage InterceptorStage, interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if err := stage.validate(); err != nil {
+			return err
+		}
+		if o.streamInterceptors == nil {
+			o.streamInterceptors = map[InterceptorStage][]grpc.StreamServerInterceptor{}
+		}
+		o.streamInterceptors[stage] = append(o.streamInterceptors[stage], interceptors...)
+		return nil
+	})
+}
+
+func (stage InterceptorStage) validate() error {
+	switch stage {
+	case InterceptorStageAfterAuth, InterceptorStageBeforeAuth:
+		return nil
+	default:
+		return errors.Errorf("unknown interceptor stage %d", stage)
+	}
+}
+
 // WithInstanceNames returns a ServerOption which sets the names for this
 // server instance. These names will be used for auth token issuance (first name) and
 // mDNS service discovery to report the server itself. If unset the value
@@ -222,8 +466,24 @@ func WithUnauthenticated() ServerOption {
 // WithAuthRSAPrivateKey returns a ServerOption which sets the private key to
 // use for signed JWTs.
 func WithAuthRSAPrivateKey(authRSAPrivateKey *rsa.PrivateKey) ServerOption {
+	if authRSAPrivateKey == nil {
+		return newFuncServerOption(func(o *serverOptions) error {
+			o.authPrivateKey = nil
+			return nil
+		})
+	}
+	return WithAuthPrivateKey(authRSAPrivateKey)
+}
+
+// WithAuthPrivateKey returns a ServerOption which sets the private key to use for
+// signed JWTs. RSA (*rsa.PrivateKey), ECDSA P-256 (*ecdsa.PrivateKey), and Ed25519
+// (ed25519.PrivateKey) keys are supported and sign with RS256, ES256, and EdDSA respectively.
+func WithAuthPrivateKey(authPrivateKey crypto.Signer) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
-		o.authRSAPrivateKey = authRSAPrivateKey
+		if _, err := signingMethodForKey(authPrivateKey); err != nil {
+			return err
+		}
+		o.authPrivateKey = authPrivateKey
 		return nil
 	})
 }
@@ -281,6 +541,22 @@ func WithTLSAuthHandler(entities []string) ServerOption {
 	})
 }
 
+// WithRequiredClientCertificates returns a ServerOption which requires every TLS connection to
+// present a client certificate that verifies against the given CAs. Callers with a verified
+// certificate are authenticated; the certificate is available via ContextClientCertificate.
+// Unless WithTLSAuthHandler is also used to restrict which certificates are accepted, any
+// certificate issued by the CAs is accepted. Note that when the server answers WebRTC calls
+// through its own signaling server, its certificate must verify against the CAs as well.
+func WithRequiredClientCertificates(clientCAs *x509.CertPool) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if clientCAs == nil {
+			return errors.New("expected client CAs")
+		}
+		o.clientCAs = clientCAs
+		return nil
+	})
+}
+
 // WithAuthHandler returns a ServerOption which adds an auth handler associated
 // to the given credential type to use for authentication requests.
 func WithAuthHandler(forType CredentialsType, handler AuthHandler) ServerOption {
@@ -377,6 +653,16 @@ func WithExternalAuthOIDCTokenVerifier(ctx context.Context, issuer string) (Serv
 	return WithExternalTokenVerificationKeyProvider(provider), provider.Close, nil
 }
 
+// WithOIDCAuthHandler returns a ServerOption which lets entities authenticate with tokens
+// issued by an OIDC provider under CredentialsTypeOIDC. See MakeOIDCAuthHandler.
+func WithOIDCAuthHandler(ctx context.Context, opts OIDCAuthHandlerOptions) (ServerOption, func(ctx context.Context) error, error) {
+	handler, closeHandler, err := MakeOIDCAuthHandler(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return WithAuthHandler(CredentialsTypeOIDC, handler), closeHandler, nil
+}
+
 // WithAuthenticateToHandler returns a ServerOption which adds an authentication
 // handler designed to allow the caller to authenticate itself to some other entity.
 // This is useful when externally authenticating as one entity for the purpose of
@@ -438,6 +724,74 @@ func WithAllowUnauthenticatedHealthCheck() ServerOption {
 	})
 }
 
+// WithReflection returns a server option that registers the gRPC server reflection service
+// (e.g. for use with grpcurl) on both the direct gRPC and WebRTC servers.
+func WithReflection() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.reflection = true
+		return nil
+	})
+}
+
+// WithHealthService returns a server option that registers the standard gRPC health service
+// (grpc.health.v1.Health) on both the direct gRPC and WebRTC servers. The server reports
+// itself as serving once started and not serving once stopped. Use
+// WithAllowUnauthenticatedHealthCheck to let orchestrators check it without credentials.
+func WithHealthService() ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.healthService = true
+		return nil
+	})
+}
+
+// WithShutdownGracePeriod returns a server option that makes stopping the server drain it
+// first: it reports itself as not serving if it has a health service, stops accepting
+// connections, refuses new calls with codes.Unavailable so that clients retry them
+// elsewhere, and waits up to the given period for the calls in flight over any transport
+// to finish before closing everything. Pair it with inherited listeners (see
+// utils.InheritedListeners and utils.StartReplacementProcess) to restart a server
+// without downtime.
+func WithShutdownGracePeriod(period time.Duration) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		if period < 0 {
+			return errors.New("shutdown grace period must not be negative")
+		}
+		o.shutdownGracePeriod = period
+		return nil
+	})
+}
+
+// WithCORS returns a server option that handles CORS for all requests served over HTTP
+// (e.g. gRPC-Web and gateway requests) with the given handler instead of allowing gRPC-Web
+// requests from any origin. The handler should expose the Grpc-Status and Grpc-Message
+// headers for gRPC-Web clients, as those from the web/cors package do.
+func WithCORS(c *cors.Cors) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.cors = c
+		return nil
+	})
+}
+
+// WithGatewayServeMuxOptions returns a server option that configures the gRPC gateway mux
+// (e.g. to forward extra headers as metadata). They are applied after the defaults.
+func WithGatewayServeMuxOptions(opts ...runtime.ServeMuxOption) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.gatewayMuxOpts = append(o.gatewayMuxOpts, opts...)
+		return nil
+	})
+}
+
+// WithGatewayHandlers returns a server option that registers the given handlers on the
+// gRPC gateway mux once the server is created. This is useful for services that are not
+// registered with RegisterServiceServer or for adding custom routes with HandlePath.
+// Requests proxied to the server go through the same authentication as any other call.
+func WithGatewayHandlers(handlers ...RegisterServiceHandlerFromEndpointFunc) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		o.gatewayHandlers = append(o.gatewayHandlers, handlers...)
+		return nil
+	})
+}
+
 // WithPublicMethods returns a server option with grpc methods that can bypass auth validation.
 func WithPublicMethods(fullMethods []string) ServerOption {
 	return newFuncServerOption(func(o *serverOptions) error {
@@ -0,0 +1,149 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/ratelimit"
+)
+
+// MetadataFieldRetryAfter is the header set on calls that were rate limited with how many
+// seconds to wait before trying again.
+const MetadataFieldRetryAfter = "retry-after"
+
+// A MethodRateLimit limits how often the methods it matches may be called.
+type MethodRateLimit struct {
+	// Methods are patterns (see path.Match) of the full method names the limit applies to.
+	// If empty, it applies to all methods.
+	Methods []string
+
+	// Rate is how many calls per second are replenished.
+	Rate float64
+
+	// Burst is the most calls that may be made at once.
+	Burst int
+
+	// PerEntity limits each authenticated entity separately instead of all callers
+	// sharing the limit. Unauthenticated callers share a limit.
+	PerEntity bool
+}
+
+func (l MethodRateLimit) validate() error {
+	if err := (ratelimit.TokenBucketLimit{Rate: l.Rate, Burst: l.Burst}).Validate(); err != nil {
+		return err
+	}
+	for _, pattern := range l.Methods {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// A MethodRateLimiter limits calls with token buckets. All limits that apply to a method
+// must allow a call for it to proceed; otherwise it fails with codes.ResourceExhausted
+// and MetadataFieldRetryAfter is set in the header.
+type MethodRateLimiter struct {
+	limits []MethodRateLimit
+	// buckets holds the buckets of each limit by entity.
+	buckets []*ratelimit.Keyed[string, *ratelimit.TokenBucket]
+}
+
+// NewMethodRateLimiter returns a new limiter enforcing the given limits.
+func NewMethodRateLimiter(limits ...MethodRateLimit) (*MethodRateLimiter, error) {
+	l := &MethodRateLimiter{limits: limits}
+	for _, limit := range limits {
+		if err := limit.validate(); err != nil {
+			return nil, err
+		}
+		limit := limit
+		// a bucket unused for as long as it takes to refill is full and need not be kept.
+		untilFull := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+		l.buckets = append(l.buckets, ratelimit.NewKeyed(untilFull, func(entity string) *ratelimit.TokenBucket {
+			return ratelimit.NewTokenBucket(limit.Rate, limit.Burst)
+		}))
+	}
+	return l, nil
+}
+
+// allow takes a call from every limit that applies to the method, returning how long
+// to wait before trying again if any has none left.
+func (l *MethodRateLimiter) allow(ctx context.Context, fullMethod string) time.Duration {
+	var entity string
+	if authEntity, ok := ContextAuthEntity(ctx); ok {
+		entity = authEntity.Entity
+	}
+
+	for idx, limit := range l.limits {
+		if len(limit.Methods) != 0 && !matchesAny(limit.Methods, fullMethod) {
+			continue
+		}
+		var key string
+		if limit.PerEntity {
+			key = entity
+		}
+		if ok, retryAfter := l.buckets[idx].TryAcquireN(key, 1); !ok {
+			return retryAfter
+		}
+	}
+	return 0
+}
+
+func rateLimitedError(retryAfter time.Duration) (metadata.MD, error) {
+	md := metadata.Pairs(MetadataFieldRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return md, status.Errorf(codes.ResourceExhausted, "rate limited; try again in %s", retryAfter.Round(time.Millisecond))
+}
+
+// UnaryServerInterceptor returns an interceptor limiting unary calls. It must run after
+// authentication for per entity limits to apply.
+func (l *MethodRateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if retryAfter := l.allow(ctx, info.FullMethod); retryAfter > 0 {
+			md, err := rateLimitedError(retryAfter)
+			if setErr := grpc.SetHeader(ctx, md); setErr != nil {
+				return nil, setErr
+			}
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor limiting the creation of streams. It
+// must run after authentication for per entity limits to apply.
+func (l *MethodRateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, serverStream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if retryAfter := l.allow(serverStream.Context(), info.FullMethod); retryAfter > 0 {
+			md, err := rateLimitedError(retryAfter)
+			if setErr := serverStream.SetHeader(md); setErr != nil {
+				return setErr
+			}
+			return err
+		}
+		return handler(srv, serverStream)
+	}
+}
+
+// WithMethodRateLimits returns a ServerOption which limits how often methods may be
+// called. The limits are enforced after authentication and prior to any interceptors set
+// by WithUnaryServerInterceptor or WithStreamServerInterceptor. See MethodRateLimiter.
+func WithMethodRateLimits(limits ...MethodRateLimit) ServerOption {
+	return newFuncServerOption(func(o *serverOptions) error {
+		for _, limit := range limits {
+			if err := limit.validate(); err != nil {
+				return err
+			}
+		}
+		o.methodRateLimits = append(o.methodRateLimits, limits...)
+		return nil
+	})
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+func TestMethodRateLimiter(t *testing.T) {
+	limiter, err := NewMethodRateLimiter(
+		MethodRateLimit{Methods: []string{"/a/*"}, Rate: 1, Burst: 2, PerEntity: true},
+		MethodRateLimit{Methods: []string{"/b/Expensive"}, Rate: 100, Burst: 1},
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	fooCtx := ContextWithAuthEntity(context.Background(), EntityInfo{Entity: "foo"})
+	barCtx := ContextWithAuthEntity(context.Background(), EntityInfo{Entity: "bar"})
+
+	test.That(t, limiter.allow(fooCtx, "/a/One"), test.ShouldEqual, 0)
+	test.That(t, limiter.allow(fooCtx, "/a/Two"), test.ShouldEqual, 0)
+	retryAfter := limiter.allow(fooCtx, "/a/One")
+	test.That(t, retryAfter, test.ShouldBeGreaterThan, 0)
+	test.That(t, retryAfter, test.ShouldBeLessThanOrEqualTo, time.Second)
+
+	// entities are limited separately
+	test.That(t, limiter.allow(barCtx, "/a/One"), test.ShouldEqual, 0)
+	test.That(t, limiter.allow(context.Background(), "/a/One"), test.ShouldEqual, 0)
+
+	// methods without a limit are not limited
+	for i := 0; i < 10; i++ {
+		test.That(t, limiter.allow(fooCtx, "/b/Cheap"), test.ShouldEqual, 0)
+	}
+
+	// shared limits apply to everyone
+	test.That(t, limiter.allow(fooCtx, "/b/Expensive"), test.ShouldEqual, 0)
+	test.That(t, limiter.allow(barCtx, "/b/Expensive"), test.ShouldBeGreaterThan, 0)
+	time.Sleep(20 * time.Millisecond)
+	test.That(t, limiter.allow(barCtx, "/b/Expensive"), test.ShouldEqual, 0)
+
+	_, err = NewMethodRateLimiter(MethodRateLimit{Burst: 1})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewMethodRateLimiter(MethodRateLimit{Rate: 1})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewMethodRateLimiter(MethodRateLimit{Rate: 1, Burst: 1, Methods: []string{"["}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestServerMethodRateLimits(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithMethodRateLimits(MethodRateLimit{
+			Methods: []string{"/proto.rpc.examples.echo.v1.EchoService/Echo"},
+			Rate:    0.01,
+			Burst:   2,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	), test.ShouldBeNil)
+
+	httpListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(httpListener)
+	}()
+
+	conn, err := Dial(context.Background(), httpListener.Addr().String(), logger, WithInsecure(), WithForceDirectGRPC())
+	test.That(t, err, test.ShouldBeNil)
+	client := pb.NewEchoServiceClient(conn)
+
+	for i := 0; i < 2; i++ {
+		_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+	}
+	var header metadata.MD
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"}, grpc.Header(&header))
+	test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+	test.That(t, header.Get(MetadataFieldRetryAfter), test.ShouldResemble, []string{"100"})
+
+	// other methods are not limited
+	stream, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hi"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = stream.Recv()
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+
+	_, err = NewServer(logger, WithMethodRateLimits(MethodRateLimit{}))
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -1,15 +1,22 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -17,19 +24,26 @@ import (
 
 	"github.com/edaniels/golog"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pkg/errors"
 	"go.viam.com/test"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"go.viam.com/utils"
 	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
 	rpcpb "go.viam.com/utils/proto/rpc/v1"
 	echoserver "go.viam.com/utils/rpc/examples/echo/server"
 	"go.viam.com/utils/testutils"
+	"go.viam.com/utils/web/cors"
 )
 
 func TestServer(t *testing.T) {
@@ -309,6 +323,24 @@ func TestServer(t *testing.T) {
 							test.That(t, status.Convert(err).Message(), test.ShouldEqual, "whoops")
 							es.SetFail(false)
 
+							if withAuthentication {
+								// access tokens sent over WebRTC are verified and populate the entity
+								es.MustContextAuthEntity = func(ctx context.Context) echoserver.RPCEntityInfo {
+									test.That(t, MustAuthClaims[JWTClaims](ctx).Entity(), test.ShouldEqual, "foo")
+									return echoserver.RPCEntityInfo{Entity: MustAuthEntity(ctx)}
+								}
+								es.SetAuthorized(true)
+								es.SetExpectedAuthEntity("foo")
+								echoResp, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+								test.That(t, err, test.ShouldBeNil)
+								test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+								es.SetAuthorized(false)
+
+								badCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer bad"))
+								_, err = client.Echo(badCtx, &pb.EchoRequest{Message: "hello"})
+								test.That(t, status.Code(err), test.ShouldEqual, codes.Unauthenticated)
+							}
+
 							test.That(t, rpcServer.Stop(), test.ShouldBeNil)
 							err = <-errChan
 							test.That(t, err, test.ShouldBeNil)
@@ -510,3 +542,514 @@ func TestWithStatsHandler(t *testing.T) {
 	test.That(t, conn.Close(), test.ShouldBeNil)
 	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
 }
+
+func TestServerWebRTCHostServices(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	hosts := []string{"yeehaw", "woahthere"}
+	rpcServer, err := NewServer(
+		logger,
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: hosts,
+		}),
+		WithUnauthenticated(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	registrar, ok := rpcServer.(WebRTCHostServiceRegistrar)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	es := echoserver.Server{}
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+	hostES := echoserver.Server{}
+	hostES.SetFail(true)
+	test.That(t, registrar.RegisterWebRTCHostServiceServer(hosts[1], &pb.EchoService_ServiceDesc, &hostES), test.ShouldBeNil)
+
+	err = registrar.RegisterWebRTCHostServiceServer("elsewhere", &pb.EchoService_ServiceDesc, &hostES)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "not answering")
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	dial := func(host string) ClientConn {
+		t.Helper()
+		var rtcConn ClientConn
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			var err error
+			rtcConn, err = dialWebRTC(context.Background(), listener.Addr().String(), host, dialOptions{
+				webrtcOpts: DialWebRTCOptions{
+					SignalingInsecure: true,
+				},
+				webrtcOptsSet: true,
+			}, logger)
+			test.That(tb, err, test.ShouldBeNil)
+		})
+		return rtcConn
+	}
+
+	// the first host is served the server wide services
+	rtcConn := dial(hosts[0])
+	echoResp, err := pb.NewEchoServiceClient(rtcConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+	test.That(t, rtcConn.Close(), test.ShouldBeNil)
+
+	// while the second host gets its own
+	rtcConn = dial(hosts[1])
+	_, err = pb.NewEchoServiceClient(rtcConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, status.Convert(err).Message(), test.ShouldEqual, "whoops")
+	test.That(t, rtcConn.Close(), test.ShouldBeNil)
+
+	err = registrar.RegisterWebRTCHostServiceServer(hosts[0], &pb.EchoService_ServiceDesc, &hostES)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "before the server is started")
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
+
+func TestServerReflectionAndHealth(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			serverOpts := []ServerOption{
+				WithDisableMulticastDNS(),
+				WithAuthHandler("fake", AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+					return map[string]string{}, nil
+				})),
+				WithAllowUnauthenticatedHealthCheck(),
+				WithWebRTCServerOptions(WebRTCServerOptions{
+					Enable:                 true,
+					InternalSignalingHosts: []string{"yeehaw"},
+				}),
+			}
+			if enabled {
+				serverOpts = append(serverOpts, WithReflection(), WithHealthService())
+			}
+			rpcServer, err := NewServer(logger, serverOpts...)
+			test.That(t, err, test.ShouldBeNil)
+
+			webrtcServices := rpcServer.(*simpleServer).webrtcServer.GetServiceInfo()
+			_, hasHealth := webrtcServices["grpc.health.v1.Health"]
+			test.That(t, hasHealth, test.ShouldEqual, enabled)
+			_, hasReflection := webrtcServices["grpc.reflection.v1alpha.ServerReflection"]
+			test.That(t, hasReflection, test.ShouldEqual, enabled)
+
+			test.That(t, rpcServer.Start(), test.ShouldBeNil)
+
+			conn, err := grpc.DialContext(
+				context.Background(),
+				rpcServer.InternalAddr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock(),
+			)
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+
+			healthResp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if !enabled {
+				test.That(t, status.Code(err), test.ShouldEqual, codes.Unimplemented)
+				test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+				return
+			}
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, healthResp.Status, test.ShouldEqual, healthpb.HealthCheckResponse_SERVING)
+
+			// reflection requires authentication like any other service
+			authResp, err := rpcpb.NewAuthServiceClient(conn).Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+				Entity:      "foo",
+				Credentials: &rpcpb.Credentials{Type: "fake"},
+			})
+			test.That(t, err, test.ShouldBeNil)
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.AccessToken))
+			reflectionClient, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, reflectionClient.Send(&reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+			}), test.ShouldBeNil)
+			reflectionResp, err := reflectionClient.Recv()
+			test.That(t, err, test.ShouldBeNil)
+			var services []string
+			for _, service := range reflectionResp.GetListServicesResponse().GetService() {
+				services = append(services, service.Name)
+			}
+			test.That(t, services, test.ShouldContain, "proto.rpc.v1.AuthService")
+			test.That(t, services, test.ShouldContain, "grpc.health.v1.Health")
+			test.That(t, reflectionClient.CloseSend(), test.ShouldBeNil)
+
+			healthServer := rpcServer.(*simpleServer).healthServer
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+			healthResp, err = healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, healthResp.Status, test.ShouldEqual, healthpb.HealthCheckResponse_NOT_SERVING)
+		})
+	}
+}
+
+func TestServerCORSAndGatewayHandlers(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithUnauthenticated(),
+		WithDisableMulticastDNS(),
+		WithCORS(cors.AllowAll()),
+		WithGatewayHandlers(func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+			return mux.HandlePath(http.MethodGet, "/hello", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+				_, err := w.Write([]byte("hello"))
+				utils.UncheckedError(err)
+			})
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	es := echoserver.Server{}
+	err = rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&es,
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.Start(), test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	httpServer := httptest.NewServer(rpcServer)
+	defer httpServer.Close()
+
+	do := func(method, path, contentType string, body []byte) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(method, httpServer.URL+path, bytes.NewReader(body))
+		test.That(t, err, test.ShouldBeNil)
+		req.Header.Set("Origin", "http://example.com")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, resp.Body.Close(), test.ShouldBeNil)
+		}()
+		respBody, err := io.ReadAll(resp.Body)
+		test.That(t, err, test.ShouldBeNil)
+		return resp, respBody
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		resp, _ := do(http.MethodOptions, "/proto.rpc.examples.echo.v1.EchoService/Echo", "", nil)
+		test.That(t, resp.Header.Get("Access-Control-Allow-Origin"), test.ShouldEqual, "*")
+		test.That(t, resp.Header.Get("Access-Control-Allow-Methods"), test.ShouldEqual, http.MethodPost)
+	})
+
+	t.Run("custom gateway handler", func(t *testing.T) {
+		resp, body := do(http.MethodGet, "/hello", "", nil)
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+		test.That(t, resp.Header.Get("Access-Control-Allow-Origin"), test.ShouldEqual, "*")
+		test.That(t, string(body), test.ShouldEqual, "hello")
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		resp, body := do(http.MethodPost, "/rpc/examples/echo/v1/echo", "application/json", []byte(`{"message":"hi"}`))
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+		test.That(t, resp.Header.Get("Access-Control-Allow-Origin"), test.ShouldEqual, "*")
+		var echoResp map[string]interface{}
+		test.That(t, json.Unmarshal(body, &echoResp), test.ShouldBeNil)
+		test.That(t, echoResp, test.ShouldResemble, map[string]interface{}{"message": "hi"})
+	})
+
+	t.Run("grpc-web", func(t *testing.T) {
+		msg, err := proto.Marshal(&pb.EchoRequest{Message: "hi"})
+		test.That(t, err, test.ShouldBeNil)
+		frame := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+		frame = append(frame, msg...)
+
+		resp, body := do(http.MethodPost, "/proto.rpc.examples.echo.v1.EchoService/Echo", "application/grpc-web+proto", frame)
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusOK)
+		test.That(t, resp.Header.Get("Access-Control-Allow-Origin"), test.ShouldEqual, "*")
+		test.That(t, len(body), test.ShouldBeGreaterThan, 5)
+		respLen := binary.BigEndian.Uint32(body[1:5])
+		var echoResp pb.EchoResponse
+		test.That(t, proto.Unmarshal(body[5:5+respLen], &echoResp), test.ShouldBeNil)
+		test.That(t, echoResp.Message, test.ShouldEqual, "hi")
+	})
+}
+
+func TestServerInterceptorStages(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(ctx context.Context, name, method string) {
+		// ignore the calls made by the internal signaling answerer
+		if !strings.HasPrefix(method, "/proto.rpc.examples.echo.v1.EchoService/") {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		entity, _ := ContextAuthEntity(ctx)
+		calls = append(calls, fmt.Sprintf("%s %s %s", name, method, entity.Entity))
+	}
+	takeCalls := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		taken := calls
+		calls = nil
+		return taken
+	}
+	unary := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			record(ctx, name, info.FullMethod)
+			return handler(ctx, req)
+		}
+	}
+	stream := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			record(ss.Context(), name, info.FullMethod)
+			return handler(srv, ss)
+		}
+	}
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler("fake", AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			return map[string]string{}, nil
+		})),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+		}),
+		WithUnaryServerInterceptor(unary("legacy")),
+		WithUnaryInterceptors(InterceptorStageAfterAuth, unary("after1")),
+		WithUnaryInterceptors(InterceptorStageBeforeAuth, unary("before1"), unary("before2")),
+		WithUnaryInterceptors(InterceptorStageAfterAuth, unary("after2")),
+		WithStreamInterceptors(InterceptorStageAfterAuth, stream("after")),
+		WithStreamInterceptors(InterceptorStageBeforeAuth, stream("before")),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = NewServer(logger, WithUnaryInterceptors(InterceptorStage(5), unary("bad")))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown interceptor stage")
+
+	es := echoserver.Server{}
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	const echoMethod = "/proto.rpc.examples.echo.v1.EchoService/Echo"
+	const echoMultipleMethod = "/proto.rpc.examples.echo.v1.EchoService/EchoMultiple"
+
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := grpc.DialContext(
+			context.Background(),
+			listener.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithBlock(),
+		)
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+
+		authResp, err := rpcpb.NewAuthServiceClient(conn).Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+			Entity:      "foo",
+			Credentials: &rpcpb.Credentials{Type: "fake"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+authResp.AccessToken))
+		client := pb.NewEchoServiceClient(conn)
+		_, err = client.Echo(ctx, &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, takeCalls(), test.ShouldResemble, []string{
+			"before1 " + echoMethod + " ",
+			"before2 " + echoMethod + " ",
+			"after1 " + echoMethod + " foo",
+			"after2 " + echoMethod + " foo",
+			"legacy " + echoMethod + " foo",
+		})
+
+		echoClient, err := client.EchoMultiple(ctx, &pb.EchoMultipleRequest{Message: "hi"})
+		test.That(t, err, test.ShouldBeNil)
+		for {
+			if _, err := echoClient.Recv(); err != nil {
+				test.That(t, err, test.ShouldEqual, io.EOF)
+				break
+			}
+		}
+		test.That(t, takeCalls(), test.ShouldResemble, []string{
+			"before " + echoMultipleMethod + " ",
+			"after " + echoMultipleMethod + " foo",
+		})
+	})
+
+	t.Run("webrtc", func(t *testing.T) {
+		var rtcConn ClientConn
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			var err error
+			rtcConn, err = dialWebRTC(context.Background(), listener.Addr().String(), "yeehaw", dialOptions{
+				webrtcOpts: DialWebRTCOptions{
+					SignalingInsecure: true,
+					SignalingCreds:    Credentials{Type: "fake"},
+				},
+				webrtcOptsSet: true,
+			}, logger)
+			test.That(tb, err, test.ShouldBeNil)
+		})
+		defer func() {
+			test.That(t, rtcConn.Close(), test.ShouldBeNil)
+		}()
+
+		_, err = pb.NewEchoServiceClient(rtcConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, takeCalls(), test.ShouldResemble, []string{
+			"before1 " + echoMethod + " ",
+			"before2 " + echoMethod + " ",
+			"after1 " + echoMethod + " ",
+			"after2 " + echoMethod + " ",
+			"legacy " + echoMethod + " ",
+		})
+	})
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
+
+func TestServerInternalUnixSocket(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("UNIX domain socket permissions are not supported on windows")
+	}
+	logger := golog.NewTestLogger(t)
+	socketPath := filepath.Join(t.TempDir(), "internal.sock")
+
+	_, err := NewServer(logger, WithUnixSocketPermissions(os.ModeDir|0o600))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid UNIX socket permissions")
+
+	rpcServer, err := NewServer(
+		logger,
+		WithUnauthenticated(),
+		WithInternalBindAddress(utils.UnixAddressPrefix+socketPath),
+		WithUnixSocketPermissions(0o600),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.InternalAddr().String(), test.ShouldEqual, socketPath)
+	test.That(t, rpcServer.InstanceNames(), test.ShouldHaveLength, 1)
+	info, err := os.Stat(socketPath)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, info.Mode().Perm(), test.ShouldEqual, os.FileMode(0o600))
+
+	err = rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+		pb.RegisterEchoServiceHandlerFromEndpoint,
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.Start(), test.ShouldBeNil)
+
+	conn, err := Dial(context.Background(), utils.UnixAddressPrefix+socketPath, logger)
+	test.That(t, err, test.ShouldBeNil)
+	resp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.Message, test.ShouldEqual, "hi")
+	test.That(t, conn.Close(), test.ShouldBeNil)
+
+	// the gateway reaches the gRPC server over the socket too
+	httpServer := httptest.NewServer(rpcServer)
+	httpResp, err := http.Post(httpServer.URL+"/rpc/examples/echo/v1/echo", "application/json", strings.NewReader(`{"message":"hi"}`))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, httpResp.StatusCode, test.ShouldEqual, http.StatusOK)
+	test.That(t, httpResp.Body.Close(), test.ShouldBeNil)
+	httpServer.Close()
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	_, err = os.Stat(socketPath)
+	test.That(t, errors.Is(err, os.ErrNotExist), test.ShouldBeTrue)
+}
+
+func TestServerServeTLSWithTLSConfig(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	names := []string{"first.example", "second.example"}
+	certPools := map[string]*x509.CertPool{}
+	var certFiles []utils.CertificateFiles
+	for _, name := range names {
+		_, certFile, keyFile, certPool, err := testutils.GenerateSelfSignedCertificate(name)
+		test.That(t, err, test.ShouldBeNil)
+		certPools[name] = certPool
+		certFiles = append(certFiles, utils.CertificateFiles{CertFile: certFile, KeyFile: keyFile})
+	}
+	reloader, err := utils.NewCertificateReloader(logger, certFiles...)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, reloader.Close(), test.ShouldBeNil)
+	}()
+
+	_, err = NewServer(logger, WithInternalTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expected at least 1 certificate")
+
+	rpcServer, err := NewServer(logger, WithDisableMulticastDNS(), WithUnauthenticated())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(
+		context.Background(),
+		&pb.EchoService_ServiceDesc,
+		&echoserver.Server{},
+	), test.ShouldBeNil)
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		// no cert/key files since the config provides the certificates
+		errChan <- rpcServer.ServeTLS(listener, "", "", reloader.TLSConfig())
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	// each name is only verifiable if the server chose its certificate
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			conn, err := Dial(context.Background(), listener.Addr().String(), logger, WithForceDirectGRPC(), WithTLSConfig(&tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    certPools[name],
+				ServerName: name,
+			}))
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, conn.Close(), test.ShouldBeNil)
+			}()
+			resp, err := pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp.Message, test.ShouldEqual, "hi")
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceContextPropagator injects and extracts the W3C Trace Context
+// (https://www.w3.org/TR/trace-context/) of calls, that is their traceparent and
+// tracestate, with OpenTelemetry so that peers tracing with OpenTelemetry, or any other
+// tracer following the standard, continue traces started here and the other way around.
+// Spans here are still OpenCensus spans, so their contexts are bridged to and from
+// OpenTelemetry ones. Since metadata is carried in the headers of streams, this works
+// the same over gRPC and WebRTC.
+var traceContextPropagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// metadataCarrier carries a trace context in gRPC metadata.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
+
+func (mc metadataCarrier) Get(key string) string {
+	values := metadata.MD(mc).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (mc metadataCarrier) Set(key, value string) {
+	metadata.MD(mc).Set(key, value)
+}
+
+func (mc metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for key := range mc {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// injectTraceContext returns the metadata carrying the trace context of the span.
+func injectTraceContext(ctx context.Context, spanContext trace.SpanContext) metadata.MD {
+	md := metadata.MD{}
+	traceContextPropagator.Inject(
+		oteltrace.ContextWithSpanContext(ctx, otelSpanContext(spanContext)),
+		metadataCarrier(md),
+	)
+	return md
+}
+
+// extractTraceContext returns the remote span context carried in the metadata, if any.
+func extractTraceContext(ctx context.Context, md metadata.MD) (trace.SpanContext, bool) {
+	spanContext := oteltrace.SpanContextFromContext(traceContextPropagator.Extract(ctx, metadataCarrier(md)))
+	if !spanContext.IsValid() {
+		return trace.SpanContext{}, false
+	}
+	return openCensusSpanContext(spanContext), true
+}
+
+// otelSpanContext bridges an OpenCensus span context to an OpenTelemetry one.
+func otelSpanContext(spanContext trace.SpanContext) oteltrace.SpanContext {
+	var traceFlags oteltrace.TraceFlags
+	if spanContext.IsSampled() {
+		traceFlags = oteltrace.FlagsSampled
+	}
+	var traceState oteltrace.TraceState
+	if spanContext.Tracestate != nil {
+		members := make([]string, 0, len(spanContext.Tracestate.Entries()))
+		for _, entry := range spanContext.Tracestate.Entries() {
+			members = append(members, entry.Key+"="+entry.Value)
+		}
+		// a trace state OpenTelemetry considers invalid is dropped.
+		traceState, _ = oteltrace.ParseTraceState(strings.Join(members, ","))
+	}
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID(spanContext.TraceID),
+		SpanID:     oteltrace.SpanID(spanContext.SpanID),
+		TraceFlags: traceFlags,
+		TraceState: traceState,
+	})
+}
+
+// openCensusSpanContext bridges an OpenTelemetry span context to an OpenCensus one.
+// Its trace state is dropped if OpenCensus considers it invalid.
+func openCensusSpanContext(spanContext oteltrace.SpanContext) trace.SpanContext {
+	var traceOptions trace.TraceOptions
+	if spanContext.IsSampled() {
+		traceOptions = 1
+	}
+	var entries []tracestate.Entry
+	if encoded := spanContext.TraceState().String(); encoded != "" {
+		for _, member := range strings.Split(encoded, ",") {
+			if key, value, ok := strings.Cut(member, "="); ok {
+				entries = append(entries, tracestate.Entry{Key: key, Value: value})
+			}
+		}
+	}
+	var traceState *tracestate.Tracestate
+	if len(entries) != 0 {
+		if parsed, err := tracestate.New(nil, entries...); err == nil {
+			traceState = parsed
+		}
+	}
+	return trace.SpanContext{
+		TraceID:      trace.TraceID(spanContext.TraceID()),
+		SpanID:       trace.SpanID(spanContext.SpanID()),
+		TraceOptions: traceOptions,
+		Tracestate:   traceState,
+	}
+}
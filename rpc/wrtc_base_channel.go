@@ -6,6 +6,8 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/dtls/v2"
@@ -14,12 +16,30 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"go.viam.com/utils"
+	"go.viam.com/utils/ratelimit"
 )
 
+// A webrtcDataChannel carries the messages of a channel. It is normally a WebRTC data
+// channel but may be anything else that preserves message boundaries, such as a WebSocket
+// tunnel (see webrtcWebSocketDataChannel).
+type webrtcDataChannel interface {
+	ID() *uint16
+	OnOpen(f func())
+	OnClose(f func())
+	OnError(f func(err error))
+	OnMessage(f func(msg webrtc.DataChannelMessage))
+	SetBufferedAmountLowThreshold(th uint64)
+	OnBufferedAmountLow(f func())
+	BufferedAmount() uint64
+	Send(data []byte) error
+	Close() error
+}
+
 type webrtcBaseChannel struct {
-	mu                      sync.Mutex
+	mu sync.Mutex
+	// peerConn is nil when the channel is not carried over WebRTC.
 	peerConn                *webrtc.PeerConnection
-	dataChannel             *webrtc.DataChannel
+	dataChannel             webrtcDataChannel
 	ctx                     context.Context
 	cancel                  func()
 	ready                   chan struct{}
@@ -29,6 +49,14 @@ type webrtcBaseChannel struct {
 	logger                  golog.Logger
 	bufferWriteMu           sync.RWMutex
 	bufferWriteCond         *sync.Cond
+	lastHeartbeat           atomic.Int64
+	lastActivity            atomic.Int64
+	negotiator              *webrtcNegotiator
+	writeScheduler          *webrtcWriteScheduler
+	tracer                  *webrtcChannelFrameTracer
+	metrics                 *serverMetrics
+	// sendLimiter, if set, limits the bytes per second sent on the channel.
+	sendLimiter *ratelimit.TokenBucket
 }
 
 const bufferThreshold = 1024 * 1024
@@ -36,20 +64,22 @@ const bufferThreshold = 1024 * 1024
 func newBaseChannel(
 	ctx context.Context,
 	peerConn *webrtc.PeerConnection,
-	dataChannel *webrtc.DataChannel,
+	dataChannel webrtcDataChannel,
 	onPeerDone func(),
 	logger golog.Logger,
 ) *webrtcBaseChannel {
 	ctx, cancel := context.WithCancel(ctx)
 	ch := &webrtcBaseChannel{
-		peerConn:    peerConn,
-		dataChannel: dataChannel,
-		ctx:         ctx,
-		cancel:      cancel,
-		ready:       make(chan struct{}),
-		logger:      logger.With("ch", dataChannel.ID()),
+		peerConn:       peerConn,
+		dataChannel:    dataChannel,
+		ctx:            ctx,
+		cancel:         cancel,
+		ready:          make(chan struct{}),
+		logger:         logger.With("ch", dataChannel.ID()),
+		writeScheduler: newWebRTCWriteScheduler(),
 	}
 	ch.bufferWriteCond = sync.NewCond(ch.bufferWriteMu.RLocker())
+	ch.markActivity()
 	dataChannel.OnOpen(ch.onChannelOpen)
 	dataChannel.OnClose(ch.onChannelClose)
 	dataChannel.OnError(ch.onChannelError)
@@ -59,6 +89,9 @@ func newBaseChannel(
 		ch.bufferWriteCond.Broadcast()
 		ch.bufferWriteMu.Unlock()
 	})
+	if peerConn == nil {
+		return ch
+	}
 
 	var connID string
 	var connIDMu sync.Mutex
@@ -106,6 +139,7 @@ func newBaseChannel(
 				logger.Debugw("connection state changed",
 					"conn_id", currConnID,
 					"conn_state", connectionState.String(),
+					"last_heartbeat", ch.LastHeartbeat(),
 				)
 				doPeerDone()
 			case webrtc.ICEConnectionStateChecking, webrtc.ICEConnectionStateCompleted,
@@ -113,7 +147,7 @@ func newBaseChannel(
 				fallthrough
 			default:
 				candPair, hasCandPair := webrtcPeerConnCandPair(peerConn)
-				connInfo := getWebRTCPeerConnectionStats(peerConn)
+				connInfo := ch.stats()
 				connIDMu.Lock()
 				connID = connInfo.ID
 				connIDMu.Unlock()
@@ -121,6 +155,7 @@ func newBaseChannel(
 					"conn_id", connInfo.ID,
 					"conn_state", connectionState.String(),
 					"conn_remote_candidates", connInfo.RemoteCandidates,
+					"last_heartbeat", connInfo.LastHeartbeat,
 				)
 				if hasCandPair {
 					logger.Debugw("selected candidate pair",
@@ -139,6 +174,17 @@ func newBaseChannel(
 	return ch
 }
 
+// stats returns statistics about the underlying peer connection along with when
+// the remote peer was last heard from.
+func (ch *webrtcBaseChannel) stats() webrtcPeerConnectionStats {
+	if ch.peerConn == nil {
+		return webrtcPeerConnectionStats{LastHeartbeat: ch.LastHeartbeat()}
+	}
+	stats := getWebRTCPeerConnectionStats(ch.peerConn)
+	stats.LastHeartbeat = ch.LastHeartbeat()
+	return stats
+}
+
 func (ch *webrtcBaseChannel) closeWithReason(err error) error {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
@@ -150,6 +196,15 @@ func (ch *webrtcBaseChannel) closeWithReason(err error) error {
 	ch.cancel()
 	ch.bufferWriteCond.Broadcast()
 
+	if ch.peerConn == nil {
+		if err := ch.dataChannel.Close(); err != nil {
+			return err
+		}
+		return nil
+	}
+	if ch.negotiator != nil {
+		ch.negotiator.close()
+	}
 	// Underlying connection may already be closed; ignore "conn is closed"
 	// errors.
 	if err := ch.peerConn.Close(); !errors.Is(err, dtls.ErrConnClosed) {
@@ -158,6 +213,87 @@ func (ch *webrtcBaseChannel) closeWithReason(err error) error {
 	return nil
 }
 
+// markActivity records that a message was just sent or received on this channel.
+func (ch *webrtcBaseChannel) markActivity() {
+	ch.lastActivity.Store(time.Now().UnixNano())
+}
+
+// LastActivity returns when a message was last sent or received on this channel.
+func (ch *webrtcBaseChannel) LastActivity() time.Time {
+	return time.Unix(0, ch.lastActivity.Load())
+}
+
+// useNegotiator associates the negotiator of this channel's peer connection with this
+// channel so that close reasons can be exchanged with the remote peer.
+func (ch *webrtcBaseChannel) useNegotiator(negotiator *webrtcNegotiator) {
+	ch.mu.Lock()
+	ch.negotiator = negotiator
+	ch.mu.Unlock()
+	negotiator.OnCloseReason(ch.onPeerCloseReason)
+}
+
+// onPeerCloseReason is called when the remote peer says why it is closing the connection.
+func (ch *webrtcBaseChannel) onPeerCloseReason(reason *PeerCloseReason) {
+	if err := ch.closeWithReason(reason); err != nil {
+		ch.logger.Errorw("error closing channel", "error", err)
+	}
+}
+
+// sendCloseReason tells the remote peer why this channel is about to be closed, if
+// the remote peer is able to hear it.
+func (ch *webrtcBaseChannel) sendCloseReason(reason *PeerCloseReason) {
+	ch.mu.Lock()
+	negotiator := ch.negotiator
+	ch.mu.Unlock()
+	if negotiator == nil {
+		return
+	}
+	if err := negotiator.sendCloseReason(reason); err != nil {
+		ch.logger.Debugw("error sending close reason to peer", "error", err)
+	}
+}
+
+// sendWindowUpdate acknowledges to the remote peer that increment bytes were consumed
+// on the given flow controlled stream.
+func (ch *webrtcBaseChannel) sendWindowUpdate(streamID uint64, increment int) {
+	ch.mu.Lock()
+	negotiator := ch.negotiator
+	ch.mu.Unlock()
+	if negotiator == nil {
+		return
+	}
+	if err := negotiator.sendWindowUpdate(streamID, increment); err != nil {
+		ch.logger.Debugw("error sending window update to peer", "error", err)
+	}
+}
+
+// hasNegotiator returns whether control messages can be exchanged with the remote peer.
+func (ch *webrtcBaseChannel) hasNegotiator() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.negotiator != nil
+}
+
+// closeWithPeerReason tells the remote peer why this channel is being closed and
+// then closes it with that reason.
+func (ch *webrtcBaseChannel) closeWithPeerReason(reason *PeerCloseReason) error {
+	ch.sendCloseReason(reason)
+	return ch.closeWithReason(reason)
+}
+
+// Renegotiate renegotiates the session with the remote peer, such as after adding
+// tracks or data channels to the peer connection. It blocks until the new session is
+// live or the context is done.
+func (ch *webrtcBaseChannel) Renegotiate(ctx context.Context) error {
+	ch.mu.Lock()
+	negotiator := ch.negotiator
+	ch.mu.Unlock()
+	if negotiator == nil {
+		return errors.New("renegotiation is not supported by this channel")
+	}
+	return negotiator.renegotiate(ctx)
+}
+
 func (ch *webrtcBaseChannel) Close() error {
 	defer ch.activeBackgroundWorkers.Wait()
 	return ch.closeWithReason(nil)
@@ -207,14 +343,47 @@ func (ch *webrtcBaseChannel) onChannelError(err error) {
 
 const maxDataChannelSize = 65535
 
+// newChannelSendLimiter returns a limiter of the bytes per second sent on a channel. It
+// allows bursts of a second's worth of bytes but always at least a full message so that
+// every message can be sent.
+func newChannelSendLimiter(bytesPerSecond float64) *ratelimit.TokenBucket {
+	burst := int(bytesPerSecond)
+	if burst < maxDataChannelSize {
+		burst = maxDataChannelSize
+	}
+	return ratelimit.NewTokenBucket(bytesPerSecond, burst)
+}
+
 func (ch *webrtcBaseChannel) write(msg proto.Message) error {
+	return ch.writeWithPriority(msg, StreamPriorityNormal)
+}
+
+// writeWithPriority writes the message once the channel's write scheduler gives the
+// priority its turn.
+func (ch *webrtcBaseChannel) writeWithPriority(msg proto.Message, priority StreamPriority) error {
 	data, err := proto.Marshal(msg)
 	if err != nil {
 		return err
 	}
+	if err := ch.writeScheduler.acquire(ch.ctx, priority); err != nil {
+		return io.ErrClosedPipe
+	}
+	defer ch.writeScheduler.release()
+	if ch.sendLimiter != nil {
+		// the rare message larger than a data channel message, such as one with huge
+		// headers, counts as a full one so that it never waits for more than the burst.
+		n := len(data)
+		if n > maxDataChannelSize {
+			n = maxDataChannelSize
+		}
+		if err := ch.sendLimiter.WaitN(ch.ctx, n); err != nil {
+			return io.ErrClosedPipe
+		}
+	}
 	ch.bufferWriteCond.L.Lock()
 	for {
 		if ch.ctx.Err() != nil {
+			ch.bufferWriteCond.L.Unlock()
 			return io.ErrClosedPipe
 		}
 		if ch.dataChannel.BufferedAmount() >= bufferThreshold {
@@ -230,5 +399,8 @@ func (ch *webrtcBaseChannel) write(msg proto.Message) error {
 		}
 		return err
 	}
+	ch.markActivity()
+	ch.tracer.trace(FrameDirectionOutbound, msg, len(data))
+	ch.metrics.dataChannelBytesSent(len(data))
 	return nil
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/webrtc/v3"
@@ -18,13 +20,13 @@ func setupWebRTCPeers(t *testing.T) (client, server *webrtc.PeerConnection, clie
 	t.Helper()
 	logger := golog.NewTestLogger(t)
 
-	pc1, dc1, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, logger)
+	pc1, dc1, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
 	test.That(t, err, test.ShouldBeNil)
 
 	encodedSDP, err := encodeSDP(pc1.LocalDescription())
 	test.That(t, err, test.ShouldBeNil)
 
-	pc2, dc2, err := newPeerConnectionForServer(context.Background(), encodedSDP, webrtc.Configuration{}, true, logger)
+	pc2, dc2, _, err := newPeerConnectionForServer(context.Background(), encodedSDP, webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
 	test.That(t, err, test.ShouldBeNil)
 
 	test.That(t, pc1.SetRemoteDescription(*pc2.LocalDescription()), test.ShouldBeNil)
@@ -103,3 +105,210 @@ func TestWebRTCBaseChannel(t *testing.T) {
 
 	test.That(t, bc2.write(someStatus.Proto()), test.ShouldEqual, io.ErrClosedPipe)
 }
+
+func TestWebRTCBaseChannelSendLimit(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	bc1, bc2, _, _ := setupWebRTCBaseChannels(t)
+	defer func() {
+		test.That(t, bc2.Close(), test.ShouldBeNil)
+	}()
+
+	bc1.sendLimiter = newChannelSendLimiter(1)
+	someStatus, _ := status.FromError(errors.New("ouch"))
+	test.That(t, bc1.write(someStatus.Proto()), test.ShouldBeNil)
+
+	// once the burst is used up, writes wait until the channel closes
+	ok, _ := bc1.sendLimiter.TryAcquireN(int(bc1.sendLimiter.Tokens()))
+	test.That(t, ok, test.ShouldBeTrue)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- bc1.write(someStatus.Proto())
+	}()
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected write to wait but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	test.That(t, bc1.Close(), test.ShouldBeNil)
+	test.That(t, <-errCh, test.ShouldEqual, io.ErrClosedPipe)
+}
+
+func TestWebRTCPeerICEFilters(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	var checkedIfaces, checkedIPs bool
+	peerOpts := webrtcPeerOptions{
+		interfaceFilter: func(name string) bool {
+			checkedIfaces = true
+			return true
+		},
+		ipFilter: func(ip net.IP) bool {
+			checkedIPs = true
+			return ip.IsLoopback()
+		},
+	}
+	pc, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, peerOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pc.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, checkedIfaces, test.ShouldBeTrue)
+	test.That(t, checkedIPs, test.ShouldBeTrue)
+}
+
+func TestICETimeouts(t *testing.T) {
+	test.That(t, ICETimeouts{}.withDefaults(), test.ShouldResemble, ICETimeouts{
+		Disconnected:           DefaultICEDisconnectedTimeout,
+		Failed:                 DefaultICEFailedTimeout,
+		Keepalive:              DefaultICEKeepaliveInterval,
+		RelayAcceptanceMinWait: DefaultICERelayAcceptanceMinWait,
+	})
+	test.That(t, ICETimeouts{Failed: time.Minute, RelayAcceptanceMinWait: time.Second}.withDefaults(), test.ShouldResemble, ICETimeouts{
+		Disconnected:           DefaultICEDisconnectedTimeout,
+		Failed:                 time.Minute,
+		Keepalive:              DefaultICEKeepaliveInterval,
+		RelayAcceptanceMinWait: time.Second,
+	})
+
+	logger := golog.NewTestLogger(t)
+	peerOpts := webrtcPeerOptions{
+		iceTimeouts: ICETimeouts{
+			Disconnected: 2 * time.Second,
+			Failed:       4 * time.Second,
+			Keepalive:    500 * time.Millisecond,
+		},
+	}
+	pc, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, peerOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Close(), test.ShouldBeNil)
+}
+
+func TestWebRTCBaseChannelActivity(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	bc1, bc2, _, _ := setupWebRTCBaseChannels(t)
+	defer func() {
+		test.That(t, bc2.Close(), test.ShouldBeNil)
+	}()
+
+	// sending alone counts as activity, as when only streaming responses to a peer.
+	created := bc1.LastActivity()
+	time.Sleep(10 * time.Millisecond)
+	someStatus, _ := status.FromError(errors.New("ouch"))
+	test.That(t, bc1.write(someStatus.Proto()), test.ShouldBeNil)
+	written := bc1.LastActivity()
+	test.That(t, written.After(created), test.ShouldBeTrue)
+
+	time.Sleep(10 * time.Millisecond)
+	test.That(t, bc1.Close(), test.ShouldBeNil)
+	test.That(t, bc1.LastActivity().Equal(written), test.ShouldBeTrue)
+}
+
+func TestWebRTCBaseChannelHeartbeat(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+
+	t.Run("alive", func(t *testing.T) {
+		bc1, bc2, _, _ := setupWebRTCBaseChannels(t)
+		defer func() {
+			test.That(t, bc1.Close(), test.ShouldBeNil)
+			test.That(t, bc2.Close(), test.ShouldBeNil)
+		}()
+		test.That(t, bc1.LastHeartbeat().IsZero(), test.ShouldBeTrue)
+
+		bc2.acceptHeartbeat(10*time.Millisecond, 200*time.Millisecond)
+		test.That(t, bc1.startHeartbeat(10*time.Millisecond, 200*time.Millisecond), test.ShouldBeNil)
+
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, bc1.LastHeartbeat().IsZero(), test.ShouldBeFalse)
+			test.That(tb, bc2.LastHeartbeat().IsZero(), test.ShouldBeFalse)
+		})
+		time.Sleep(500 * time.Millisecond)
+		isClosed, _ := bc1.Closed()
+		test.That(t, isClosed, test.ShouldBeFalse)
+		isClosed, _ = bc2.Closed()
+		test.That(t, isClosed, test.ShouldBeFalse)
+		test.That(t, bc1.stats().LastHeartbeat, test.ShouldNotResemble, time.Time{})
+	})
+
+	t.Run("dead", func(t *testing.T) {
+		bc1, bc2, peer1Done, _ := setupWebRTCBaseChannels(t)
+		defer func() {
+			test.That(t, bc2.Close(), test.ShouldBeNil)
+		}()
+
+		// the server never answers heartbeats but we pretend to have heard from it once.
+		bc1.lastHeartbeat.Store(time.Now().UnixNano())
+		test.That(t, bc1.startHeartbeat(10*time.Millisecond, 100*time.Millisecond), test.ShouldBeNil)
+
+		<-peer1Done
+		isClosed, reason := bc1.Closed()
+		test.That(t, isClosed, test.ShouldBeTrue)
+		test.That(t, reason, test.ShouldEqual, errPeerHeartbeatTimeout)
+	})
+
+	t.Run("dead before first heartbeat", func(t *testing.T) {
+		bc1, bc2, peer1Done, _ := setupWebRTCBaseChannels(t)
+		defer func() {
+			test.That(t, bc2.Close(), test.ShouldBeNil)
+		}()
+
+		// the server accepts the heartbeat channel but never sends a heartbeat on it.
+		test.That(t, bc1.startHeartbeat(10*time.Millisecond, 100*time.Millisecond), test.ShouldBeNil)
+
+		<-peer1Done
+		isClosed, reason := bc1.Closed()
+		test.That(t, isClosed, test.ShouldBeTrue)
+		test.That(t, reason, test.ShouldEqual, errPeerHeartbeatTimeout)
+		test.That(t, bc1.LastHeartbeat().IsZero(), test.ShouldBeTrue)
+	})
+}
+
+func TestWebRTCPeerSDPHooks(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	var clientLocalCalled, serverRemoteCalled, serverLocalCalled bool
+	clientOpts := webrtcPeerOptions{
+		onLocalDescription: func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			clientLocalCalled = true
+			test.That(t, desc.Type, test.ShouldEqual, webrtc.SDPTypeOffer)
+			return desc, nil
+		},
+	}
+	pc1, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, clientOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pc1.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, clientLocalCalled, test.ShouldBeTrue)
+
+	encodedSDP, err := encodeSDP(pc1.LocalDescription())
+	test.That(t, err, test.ShouldBeNil)
+
+	serverOpts := webrtcPeerOptions{
+		onRemoteDescription: func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			serverRemoteCalled = true
+			test.That(t, desc.Type, test.ShouldEqual, webrtc.SDPTypeOffer)
+			return desc, nil
+		},
+		onLocalDescription: func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			serverLocalCalled = true
+			test.That(t, desc.Type, test.ShouldEqual, webrtc.SDPTypeAnswer)
+			return desc, nil
+		},
+	}
+	pc2, _, _, err := newPeerConnectionForServer(context.Background(), encodedSDP, webrtc.Configuration{}, true, serverOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pc2.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, serverRemoteCalled, test.ShouldBeTrue)
+	test.That(t, serverLocalCalled, test.ShouldBeTrue)
+
+	errHook := errors.New("bad sdp")
+	_, _, _, err = newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{
+		onLocalDescription: func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			return webrtc.SessionDescription{}, errHook
+		},
+	}, logger)
+	test.That(t, err, test.ShouldEqual, errHook)
+}
@@ -130,22 +130,76 @@ func (queue *memoryWebRTCCallQueue) SendOfferInit(
 	hostQueueForSend.mu.Lock()
 	exchange := &memoryWebRTCCallOfferExchange{
 		offer:            offer,
+		queue:            queue,
+		hostQueue:        hostQueueForSend,
 		callerDoneCtx:    callerDoneCtx,
 		callerDoneCancel: callerDoneCancel,
+		requeued:         make(chan struct{}),
 	}
 	hostQueueForSend.activeOffers[offer.uuid] = exchange
 	hostQueueForSend.mu.Unlock()
 
+	queue.enqueue(ctx, hostQueueForSend, exchange)
+	return newUUID, answererResponses, sendCtx.Done(), func() { sendCtxCancel() }, nil
+}
+
+// enqueue waits for an answerer to pick up the exchange for as long as the offer is alive.
+func (queue *memoryWebRTCCallQueue) enqueue(
+	ctx context.Context,
+	hostQueue *singleWebRTCHostQueue,
+	exchange *memoryWebRTCCallOfferExchange,
+) {
 	queue.activeBackgroundWorkers.Add(1)
 	utils.PanicCapturingGo(func() {
 		queue.activeBackgroundWorkers.Done()
 		select {
-		case <-sendCtx.Done():
+		case <-exchange.offer.answererDoneCtx.Done():
 		case <-ctx.Done():
-		case hostQueueForSend.exchangeCh <- exchange:
+		case hostQueue.exchangeCh <- exchange:
 		}
 	})
-	return newUUID, answererResponses, sendCtx.Done(), func() { sendCtxCancel() }, nil
+}
+
+// requeue puts an offer that an answerer gave up on without answering (e.g. because it is restarting)
+// back onto its host queue so that the next answerer can pick it up while the offer is still alive.
+// Any candidates the caller already sent are replayed to the next answerer. It returns false if the
+// offer should not be answered anymore.
+func (queue *memoryWebRTCCallQueue) requeue(exchange *memoryWebRTCCallOfferExchange) bool {
+	if queue.cancelCtx.Err() != nil {
+		return false
+	}
+	// like the MongoDB queue, do not hand out offers that are about to expire.
+	if time.Until(exchange.offer.deadline) < getDefaultOfferCloseToDeadline() {
+		return false
+	}
+
+	hostQueue := exchange.hostQueue
+	hostQueue.mu.Lock()
+	if exchange.answered || exchange.callerErr != nil || hostQueue.activeOffers[exchange.offer.uuid] != exchange {
+		hostQueue.mu.Unlock()
+		return false
+	}
+	offer := exchange.offer
+	offer.callerCandidates = make(chan webrtc.ICECandidateInit, len(exchange.sentCallerCandidates))
+	for _, cand := range exchange.sentCallerCandidates {
+		offer.callerCandidates <- cand
+	}
+	next := &memoryWebRTCCallOfferExchange{
+		offer:                offer,
+		queue:                queue,
+		hostQueue:            hostQueue,
+		callerDoneCtx:        exchange.callerDoneCtx,
+		callerDoneCancel:     exchange.callerDoneCancel,
+		requeued:             make(chan struct{}),
+		sentCallerCandidates: exchange.sentCallerCandidates,
+	}
+	hostQueue.activeOffers[offer.uuid] = next
+	close(exchange.requeued)
+	hostQueue.mu.Unlock()
+
+	queue.logger.Debugw("requeueing abandoned offer", "uuid", offer.uuid)
+	queue.enqueue(queue.cancelCtx, hostQueue, next)
+	return true
 }
 
 // SendOfferUpdate updates the offer associated with the given UUID with a newly discovered
@@ -153,17 +207,22 @@ func (queue *memoryWebRTCCallQueue) SendOfferInit(
 func (queue *memoryWebRTCCallQueue) SendOfferUpdate(ctx context.Context, host, uuid string, candidate webrtc.ICECandidateInit) error {
 	hostQueue := queue.getOrMakeHostsQueue([]string{host})
 
-	hostQueue.mu.RLock()
+	hostQueue.mu.Lock()
 	offer, ok := hostQueue.activeOffers[uuid]
 	if !ok {
-		defer hostQueue.mu.RUnlock()
+		defer hostQueue.mu.Unlock()
 		return newInactiveOfferErr(uuid)
 	}
-	hostQueue.mu.RUnlock()
+	// remember the candidate in case the offer needs to be handed to another answerer.
+	offer.sentCallerCandidates = append(offer.sentCallerCandidates, candidate)
+	hostQueue.mu.Unlock()
 
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
+	case <-offer.requeued:
+		// the next answerer will have the candidate replayed to it.
+		return nil
 	case offer.offer.callerCandidates <- candidate:
 		return nil
 	}
@@ -236,10 +295,18 @@ func (queue *memoryWebRTCCallQueue) Close() error {
 
 type memoryWebRTCCallOfferExchange struct {
 	offer            memoryWebRTCCallOfferInit
+	queue            *memoryWebRTCCallQueue
+	hostQueue        *singleWebRTCHostQueue
 	callerDoneCtx    context.Context
 	callerDoneCancel func()
 	callerErr        error
 	answererDoneOnce sync.Once
+
+	// answered is set once the answerer has responded with an SDP or error. Until then,
+	// the offer can be requeued, which closes requeued.
+	answered             bool
+	requeued             chan struct{}
+	sentCallerCandidates []webrtc.ICECandidateInit
 }
 
 func (resp *memoryWebRTCCallOfferExchange) UUID() string {
@@ -282,6 +349,11 @@ func (resp *memoryWebRTCCallOfferExchange) CallerErr() error {
 func (resp *memoryWebRTCCallOfferExchange) AnswererRespond(ctx context.Context, ans WebRTCCallAnswer) error {
 	select {
 	case resp.offer.answererResponses <- ans:
+		if ans.InitialSDP != nil || ans.Err != nil {
+			resp.hostQueue.mu.Lock()
+			resp.answered = true
+			resp.hostQueue.mu.Unlock()
+		}
 		return nil
 	case <-resp.offer.answererDoneCtx.Done():
 		return resp.offer.answererDoneCtx.Err()
@@ -292,6 +364,9 @@ func (resp *memoryWebRTCCallOfferExchange) AnswererRespond(ctx context.Context,
 
 func (resp *memoryWebRTCCallOfferExchange) AnswererDone(ctx context.Context) error {
 	resp.answererDoneOnce.Do(func() {
+		if resp.queue.requeue(resp) {
+			return
+		}
 		resp.offer.answererDoneCancel()
 	})
 	return nil
@@ -132,12 +132,32 @@ func NewMongoDBWebRTCCallQueue(
 	client *mongo.Client,
 	logger golog.Logger,
 	activeAnswerersfunc func(hostnames []string, atTime time.Time),
+) (WebRTCCallQueue, error) {
+	return newMongoDBWebRTCCallQueue(
+		ctx,
+		operatorID,
+		maxHostCallers,
+		client.Database(mongodbWebRTCCallQueueDBName),
+		logger,
+		activeAnswerersfunc,
+	)
+}
+
+// newMongoDBWebRTCCallQueue returns a new MongoDB based call queue keeping its collections
+// in the given database.
+func newMongoDBWebRTCCallQueue(
+	ctx context.Context,
+	operatorID string,
+	maxHostCallers uint64,
+	db *mongo.Database,
+	logger golog.Logger,
+	activeAnswerersfunc func(hostnames []string, atTime time.Time),
 ) (WebRTCCallQueue, error) {
 	if operatorID == "" {
 		return nil, errors.New("expected non-empty operatorID")
 	}
-	callsColl := client.Database(mongodbWebRTCCallQueueDBName).Collection(mongodbWebRTCCallQueueCallsCollName)
-	operatorsColl := client.Database(mongodbWebRTCCallQueueDBName).Collection(mongodbWebRTCCallQueueOperatorsCollName)
+	callsColl := db.Collection(mongodbWebRTCCallQueueCallsCollName)
+	operatorsColl := db.Collection(mongodbWebRTCCallQueueOperatorsCollName)
 
 	mongodbWebRTCCallQueueExpireAfter := int32(getDefaultOfferDeadline().Seconds())
 	mongodbWebRTCCallQueueCallsIndexes := []mongo.IndexModel{
@@ -177,11 +197,25 @@ func NewMongoDBWebRTCCallQueue(
 		},
 	}
 
-	if err := mongoutils.EnsureIndexes(ctx, callsColl, mongodbWebRTCCallQueueCallsIndexes...); err != nil {
-		return nil, err
-	}
-	if err := mongoutils.EnsureIndexes(ctx, operatorsColl, mongodbWebRTCCallQueueOperatorsIndexes...); err != nil {
-		return nil, err
+	for _, collIndexes := range []struct {
+		coll    *mongo.Collection
+		indexes []mongo.IndexModel
+	}{
+		{callsColl, mongodbWebRTCCallQueueCallsIndexes},
+		{operatorsColl, mongodbWebRTCCallQueueOperatorsIndexes},
+	} {
+		diff, err := mongoutils.EnsureIndexesWithOptions(ctx, collIndexes.coll, mongoutils.EnsureIndexesOptions{}, collIndexes.indexes...)
+		if err != nil {
+			return nil, err
+		}
+		if len(diff.Created) != 0 || len(diff.Extra) != 0 {
+			logger.Infow(
+				"ensured indexes",
+				"collection", collIndexes.coll.Name(),
+				"created", diff.Created,
+				"extra", diff.Extra,
+			)
+		}
 	}
 
 	if _, err := operatorsColl.InsertOne(ctx, bson.D{
@@ -1205,11 +1239,17 @@ func (queue *mongoDBWebRTCCallQueue) RecvOffer(ctx context.Context, hosts []stri
 	exchange := mongoDBWebRTCCallOfferExchange{
 		call:             callReq,
 		coll:             queue.callsColl,
+		operatorID:       queue.operatorID,
 		callerCandidates: make(chan webrtc.ICECandidateInit),
 		callerDoneCtx:    callerDoneCtx,
 		deadline:         offerDeadline,
+		stop:             cleanup,
 	}
 	setErr := func(errToSet error) {
+		if exchange.released.Load() {
+			// another answerer may pick the offer up.
+			return
+		}
 		if !(errors.Is(errToSet, context.Canceled) || errors.Is(errToSet, context.DeadlineExceeded)) {
 			queue.logger.Errorw("error in RecvOffer", "error", errToSet, "id", callReq.ID)
 		}
@@ -1262,7 +1302,9 @@ func (queue *mongoDBWebRTCCallQueue) RecvOffer(ctx context.Context, hosts []stri
 		defer callerDoneCancel()
 		defer cleanup()
 
-		candLen := len(callReq.CallerCandidates)
+		// start from no candidates so that an offer released by a previous answerer
+		// has all of its candidates replayed.
+		candLen := 0
 		latestReq := callReq
 		for {
 			// because of our usage of update lookup being a full document,
@@ -1367,10 +1409,17 @@ func (queue *mongoDBWebRTCCallQueue) Close() error {
 type mongoDBWebRTCCallOfferExchange struct {
 	call             mongodbWebRTCCall
 	coll             *mongo.Collection
+	operatorID       string
 	callerCandidates chan webrtc.ICECandidateInit
 	callerDoneCtx    context.Context
 	callerErr        error
 	deadline         time.Time
+
+	// answered is set once the answerer has responded with an SDP or error; until then,
+	// the offer is released back to the queue when the answerer is done with it.
+	answered atomic.Bool
+	released atomic.Bool
+	stop     func()
 }
 
 func (resp *mongoDBWebRTCCallOfferExchange) UUID() string {
@@ -1448,10 +1497,51 @@ func (resp *mongoDBWebRTCCallOfferExchange) AnswererRespond(ctx context.Context,
 	if updateResult.MatchedCount == 0 {
 		return newInactiveOfferErr(resp.call.ID)
 	}
+	if ans.InitialSDP != nil || ans.Err != nil {
+		resp.answered.Store(true)
+	}
 	return nil
 }
 
+// release gives up the claim on an offer that this answerer never answered (e.g. because it is
+// restarting) so that the next answerer for the host can pick it up while the offer is still alive.
+// It returns false if the offer should not be answered anymore.
+func (resp *mongoDBWebRTCCallOfferExchange) release(ctx context.Context) (bool, error) {
+	// do not hand out offers that are about to expire; see RecvOffer.
+	if resp.answered.Load() || time.Until(resp.deadline) < getDefaultOfferCloseToDeadline() {
+		return false, nil
+	}
+	updateResult, err := resp.coll.UpdateOne(ctx, bson.D{
+		{webrtcCallIDField, resp.UUID()},
+		{webrtcCallHostField, resp.call.Host},
+		{webrtcCallAnswererOperatorIDField, resp.operatorID},
+		{webrtcCallAnsweredField, true},
+		{webrtcCallAnswererSDPField, bson.D{{"$exists", false}}},
+		{webrtcCallAnswererErrorField, bson.D{{"$exists", false}}},
+		{webrtcCallCallerErrorField, bson.D{{"$exists", false}}},
+	}, bson.D{
+		{"$set", bson.D{{webrtcCallAnsweredField, false}}},
+		{"$unset", bson.D{{webrtcCallAnswererOperatorIDField, ""}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	if updateResult.ModifiedCount == 0 {
+		return false, nil
+	}
+	resp.released.Store(true)
+	resp.stop()
+	return true, nil
+}
+
 func (resp *mongoDBWebRTCCallOfferExchange) AnswererDone(ctx context.Context) error {
+	released, err := resp.release(ctx)
+	if err != nil {
+		return err
+	}
+	if released {
+		return nil
+	}
 	updateResult, err := resp.coll.UpdateOne(ctx, bson.D{
 		{webrtcCallIDField, resp.UUID()},
 		{webrtcCallHostField, resp.call.Host},
@@ -15,13 +15,13 @@ import (
 )
 
 func TestMongoDBWebRTCCallQueue(t *testing.T) {
-	client := testutils.BackingMongoDBClient(t)
+	testutils.SkipUnlessBackingMongoDBURI(t)
 
 	testWebRTCCallQueue(t, func(t *testing.T) (WebRTCCallQueue, WebRTCCallQueue, func()) {
 		t.Helper()
-		test.That(t, client.Database(mongodbWebRTCCallQueueDBName).Drop(context.Background()), test.ShouldBeNil)
+		db := testutils.BackingMongoDBDatabase(t)
 		logger := golog.NewTestLogger(t)
-		callQueue, err := NewMongoDBWebRTCCallQueue(context.Background(), uuid.NewString(), 50, client, logger,
+		callQueue, err := newMongoDBWebRTCCallQueue(context.Background(), uuid.NewString(), 50, db, logger,
 			func(hosts []string, atTime time.Time) {})
 		test.That(t, err, test.ShouldBeNil)
 		return callQueue, callQueue, func() {
@@ -31,20 +31,20 @@ func TestMongoDBWebRTCCallQueue(t *testing.T) {
 }
 
 func TestMongoDBWebRTCCallQueueMulti(t *testing.T) {
-	client := testutils.BackingMongoDBClient(t)
+	testutils.SkipUnlessBackingMongoDBURI(t)
 
 	// we will use this to be able to have enough callers matched to answerers
 	const maxCallerQueueSize = (maxHostAnswerersSize * 2)
 	setupQueues := func(t *testing.T) (WebRTCCallQueue, WebRTCCallQueue, func()) {
 		t.Helper()
-		test.That(t, client.Database(mongodbWebRTCCallQueueDBName).Drop(context.Background()), test.ShouldBeNil)
+		db := testutils.BackingMongoDBDatabase(t)
 		logger := golog.NewTestLogger(t)
-		callerQueue, err := NewMongoDBWebRTCCallQueue(context.Background(), uuid.NewString()+"-caller",
-			maxCallerQueueSize, client, logger, func(hosts []string, atTime time.Time) {})
+		callerQueue, err := newMongoDBWebRTCCallQueue(context.Background(), uuid.NewString()+"-caller",
+			maxCallerQueueSize, db, logger, func(hosts []string, atTime time.Time) {})
 		test.That(t, err, test.ShouldBeNil)
 
-		answererQueue, err := NewMongoDBWebRTCCallQueue(context.Background(), uuid.NewString()+"-answerer",
-			maxCallerQueueSize, client, logger, func(hosts []string, atTime time.Time) {})
+		answererQueue, err := newMongoDBWebRTCCallQueue(context.Background(), uuid.NewString()+"-answerer",
+			maxCallerQueueSize, db, logger, func(hosts []string, atTime time.Time) {})
 		test.That(t, err, test.ShouldBeNil)
 		return callerQueue, answererQueue, func() {
 			test.That(t, callerQueue.Close(), test.ShouldBeNil)
@@ -158,7 +158,6 @@ func TestMongoDBWebRTCCallQueueMulti(t *testing.T) {
 	})
 
 	t.Run("ActiveAnswerer", func(t *testing.T) {
-		client := testutils.BackingMongoDBClient(t)
 		activeAnswererChannelStub := make(chan int, 3)
 		defer close(activeAnswererChannelStub)
 
@@ -166,10 +165,10 @@ func TestMongoDBWebRTCCallQueueMulti(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		test.That(t, client.Database(mongodbWebRTCCallQueueDBName).Drop(context.Background()), test.ShouldBeNil)
-		answererQueue, err := NewMongoDBWebRTCCallQueue(
+		db := testutils.BackingMongoDBDatabase(t)
+		answererQueue, err := newMongoDBWebRTCCallQueue(
 			context.Background(), uuid.NewString()+"-answerer",
-			1, client, logger, func(hostnames []string, atTime time.Time) { activeAnswererChannelStub <- len(hostnames) })
+			1, db, logger, func(hostnames []string, atTime time.Time) { activeAnswererChannelStub <- len(hostnames) })
 		test.That(t, err, test.ShouldBeNil)
 		defer answererQueue.Close()
 
@@ -257,6 +257,51 @@ func testWebRTCCallQueue(t *testing.T, setupQueues func(t *testing.T) (WebRTCCal
 		test.That(t, <-recvErrCh, test.ShouldBeNil)
 	})
 
+	t.Run("offers abandoned by an answerer are requeued", func(t *testing.T) {
+		callerQueue, answererQueue, teardown := setupQueues(t)
+		defer teardown()
+
+		host := primitive.NewObjectID().Hex()
+		newUUID, answers, answersDone, cancel, err := callerQueue.SendOfferInit(context.Background(), host, "hello", false)
+		defer cancel()
+		test.That(t, err, test.ShouldBeNil)
+
+		abandoned, err := answererQueue.RecvOffer(context.Background(), []string{host})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, abandoned.UUID(), test.ShouldEqual, newUUID)
+
+		// the update may only complete once the offer is handed over
+		c1 := webrtc.ICECandidateInit{Candidate: "c1"}
+		updateErrCh := make(chan error, 1)
+		go func() {
+			updateErrCh <- callerQueue.SendOfferUpdate(context.Background(), host, newUUID, c1)
+		}()
+
+		// the answerer goes away without answering
+		test.That(t, abandoned.AnswererDone(context.Background()), test.ShouldBeNil)
+
+		offer, err := answererQueue.RecvOffer(context.Background(), []string{host})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, offer.UUID(), test.ShouldEqual, newUUID)
+		test.That(t, offer.SDP(), test.ShouldEqual, "hello")
+		test.That(t, <-offer.CallerCandidates(), test.ShouldResemble, c1)
+		test.That(t, <-updateErrCh, test.ShouldBeNil)
+
+		recvErrCh := make(chan error, 2)
+		go func() {
+			sdp := "world"
+			recvErrCh <- offer.AnswererRespond(context.Background(), WebRTCCallAnswer{InitialSDP: &sdp})
+			recvErrCh <- offer.AnswererDone(context.Background())
+		}()
+		ans := <-answers
+		test.That(t, ans.InitialSDP, test.ShouldNotBeNil)
+		test.That(t, *ans.InitialSDP, test.ShouldEqual, "world")
+		test.That(t, <-recvErrCh, test.ShouldBeNil)
+		<-answersDone
+		test.That(t, <-recvErrCh, test.ShouldBeNil)
+		test.That(t, callerQueue.SendOfferDone(context.Background(), host, newUUID), test.ShouldBeNil)
+	})
+
 	t.Run("receiving from a host not sent to should not work", func(t *testing.T) {
 		callerQueue, answererQueue, teardown := setupQueues(t)
 		defer teardown()
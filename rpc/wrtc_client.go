@@ -6,12 +6,15 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
 	"go.uber.org/multierr"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
@@ -28,6 +31,13 @@ type DialWebRTCOptions struct {
 	// Disable prevents a WebRTC connection attempt.
 	Disable bool
 
+	// AdditionalSignalingServerAddresses are equivalent signaling servers (host:port)
+	// to fail over to when SignalingServerAddress, or whichever server is being tried,
+	// fails its health check. Servers are tried starting from the last one that worked
+	// for the host being dialed, or otherwise in round-robin order. All servers share
+	// the same signaling credentials and security settings.
+	AdditionalSignalingServerAddresses []string
+
 	// SignalingInsecure determines if the signaling connection is insecure.
 	SignalingInsecure bool
 
@@ -73,8 +83,53 @@ type DialWebRTCOptions struct {
 	// AllowAutoDetectAuthOptions allows authentication options to be automatically
 	// detected. Only use this if you trust the signaling server.
 	AllowAutoDetectAuthOptions bool
+
+	// LANOnly restricts connection establishment to the local network. Any public
+	// ICE servers (STUN/TURN), including those provided by the signaling server, are
+	// stripped so that only host and mDNS candidates are used. Candidate gathering is
+	// also bounded by a shorter timeout since there is nothing external to wait on.
+	LANOnly bool
+
+	// HeartbeatInterval is how often a heartbeat is sent to the remote peer in order
+	// to detect dead peers. If zero, DefaultWebRTCHeartbeatInterval is used. If
+	// negative, heartbeats are disabled.
+	HeartbeatInterval time.Duration
+
+	// HeartbeatTimeout is how long to go without a heartbeat from the remote peer
+	// before the connection is closed. If zero, DefaultWebRTCHeartbeatTimeout is used.
+	HeartbeatTimeout time.Duration
+
+	// OnLocalDescription, if set, is called with every local session description
+	// right before it is applied and may return a modified version of it.
+	OnLocalDescription SDPHook
+
+	// OnRemoteDescription, if set, is called with every remote session description
+	// right before it is applied and may return a modified version of it.
+	OnRemoteDescription SDPHook
+
+	// OnPeerEvent, if set, is called with every lifecycle event of the peer connection.
+	OnPeerEvent func(event PeerEvent)
+
+	// StreamWindowSize is how many bytes of response messages the server may send on a
+	// single stream before waiting for them to be consumed, so that concurrent streams
+	// share the connection fairly. If zero, DefaultWebRTCStreamWindowSize is used. If
+	// negative, responses are not flow controlled.
+	StreamWindowSize int
+
+	// FrameTraceWriter, if set, receives a trace of every frame sent or received over
+	// the WebRTC channel. See FrameTrace for the format.
+	FrameTraceWriter io.Writer
+
+	// DisableWebSocketFallback prevents tunneling the connection over a WebSocket to the
+	// signaling server when WebRTC fails to connect. The fallback is only used when the
+	// signaling server enables it.
+	DisableWebSocketFallback bool
 }
 
+// lanOnlyGatherTimeout is how long to wait on candidate gathering when dialing in
+// LAN only mode. Once it passes, the dial goes on with the candidates gathered so far.
+const lanOnlyGatherTimeout = 2 * time.Second
+
 // DialWebRTC connects to the signaling service at the given address and attempts to establish
 // a WebRTC connection with the corresponding peer reflected in the address.
 // It provider client/server functionality for gRPC serviced over
@@ -103,16 +158,21 @@ func dialWebRTC(
 	logger golog.Logger,
 ) (ch *webrtcClientChannel, err error) {
 	logger = logger.Named("webrtc")
-	dialCtx, timeoutCancel := context.WithTimeout(ctx, getDefaultOfferDeadline())
+	ctx, span := trace.StartSpan(ctx, "rpc.webrtc.dial")
+	span.AddAttributes(trace.StringAttribute("host", host))
+	defer func() {
+		endSpan(span, err)
+	}()
+	// the signaling span ends once the answer is applied and the ICE span once the peer
+	// connection is ready; ending either more than once is a no-op.
+	signalingCtx, signalingSpan := trace.StartSpan(ctx, "rpc.webrtc.signaling")
+	defer func() {
+		endSpan(signalingSpan, err)
+	}()
+	dialCtx, timeoutCancel := context.WithTimeout(signalingCtx, getDefaultOfferDeadline())
 	defer timeoutCancel()
 
-	logger.Debugw(
-		"connecting to signaling server",
-		"signaling_server", signalingServer,
-		"host", host,
-	)
-
-	conn, err := dialSignalingServer(dialCtx, signalingServer, host, logger, dOpts)
+	conn, configResp, err := dialHealthySignalingServer(dialCtx, signalingServer, host, logger, dOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -120,28 +180,27 @@ func dialWebRTC(
 		err = multierr.Combine(err, conn.Close())
 	}()
 
-	logger.Debugw("connected to signaling server", "signaling_server", signalingServer)
-
 	md := metadata.New(map[string]string{RPCHostMetadataField: host})
 	signalCtx := metadata.NewOutgoingContext(dialCtx, md)
 
 	signalingClient := webrtcpb.NewSignalingServiceClient(conn)
-	configResp, err := signalingClient.OptionalWebRTCConfig(signalCtx, &webrtcpb.OptionalWebRTCConfigRequest{})
-	if err != nil {
-		// this would be where we would hit an unimplemented signaler error first.
-		if s, ok := status.FromError(err); ok && (s.Code() == codes.Unimplemented ||
-			(s.Code() == codes.InvalidArgument && s.Message() == hostNotAllowedMsg)) {
-			return nil, ErrNoWebRTCSignaler
-		}
-		return nil, err
-	}
 
-	config := DefaultWebRTCConfiguration
-	if dOpts.webrtcOpts.Config != nil {
-		config = *dOpts.webrtcOpts.Config
+	extendedConfig := dialWebRTCConfig(dOpts.webrtcOpts, configResp.Config)
+	peerOpts := dOpts.webrtcPeerOpts
+	peerOpts.onLocalDescription = dOpts.webrtcOpts.OnLocalDescription
+	peerOpts.onRemoteDescription = dOpts.webrtcOpts.OnRemoteDescription
+	peerOpts.events = newWebRTCPeerEvents(dOpts.webrtcOpts.OnPeerEvent)
+	peerOpts.proxy = dOpts.proxy
+	if dOpts.webrtcOpts.LANOnly {
+		peerOpts.gatherTimeout = lanOnlyGatherTimeout
 	}
-	extendedConfig := extendWebRTCConfig(&config, configResp.Config)
-	peerConn, dataChannel, err := newPeerConnectionForClient(ctx, extendedConfig, dOpts.webrtcOpts.DisableTrickleICE, logger)
+	peerConn, dataChannel, negotiator, err := newPeerConnectionForClient(
+		ctx,
+		extendedConfig,
+		dOpts.webrtcOpts.DisableTrickleICE,
+		peerOpts,
+		logger,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -196,6 +255,7 @@ func dialWebRTC(
 				return
 			}
 			if icecandidate != nil {
+				peerOpts.events.candidateAdded(icecandidate.ToJSON(), false)
 				pendingCandidates.Add(1)
 				if icecandidate.Typ == webrtc.ICECandidateTypeHost {
 					waitOneHostOnce.Do(func() {
@@ -232,14 +292,18 @@ func dialWebRTC(
 			})
 		})
 
-		err = peerConn.SetLocalDescription(offer)
+		err = peerOpts.setLocalDescription(peerConn, offer)
 		if err != nil {
 			return nil, err
 		}
 
+		gatherTimeout, stopGatherTimeout := peerOpts.gatherTimeoutC()
+		defer stopGatherTimeout()
 		select {
 		case <-exchangeCtx.Done():
 			return nil, exchangeCtx.Err()
+		case <-gatherTimeout:
+			// candidates gathered after the offer still trickle to the answerer.
 		case <-waitOneHost:
 		}
 	}
@@ -254,17 +318,38 @@ func dialWebRTC(
 		return nil, err
 	}
 
-	// TODO(GOUT-11): do separate auth here
-	if dOpts.externalAuthAddr != "" {
-		// TODO(GOUT-11): prepare AuthenticateTo here
-		// for client channel.
-	} else if dOpts.creds.Type != "" { //nolint:staticcheck
-		// TODO(GOUT-11): prepare Authenticate here
-		// for client channel
+	// TODO(GOUT-11): prepare AuthenticateTo for the client channel when authenticating
+	// through an external auth server.
+	var channelCreds *webrtcClientCredentials
+	if dOpts.externalAuthAddr == "" && dOpts.creds.Type != "" {
+		channelCreds = newWebRTCClientCredentials(dOpts, host, logger)
 	}
 
+	_, iceSpan := trace.StartSpan(ctx, "rpc.webrtc.ice")
+	defer func() {
+		endSpan(iceSpan, err)
+	}()
+
+	unaryInterceptor, streamInterceptor := webrtcClientInterceptors(dOpts, channelCreds)
 	//nolint:contextcheck
-	clientCh := newWebRTCClientChannel(peerConn, dataChannel, logger, dOpts.unaryInterceptor, dOpts.streamInterceptor)
+	clientCh := newWebRTCClientChannel(
+		peerConn,
+		dataChannel,
+		logger,
+		unaryInterceptor,
+		streamInterceptor,
+	)
+	if channelCreds != nil {
+		channelCreds.useChannel(clientCh)
+	}
+	clientCh.useNegotiator(negotiator)
+	clientCh.tracer = newWebRTCFrameTracer(dOpts.webrtcOpts.FrameTraceWriter).forChannel()
+	if dOpts.webrtcOpts.StreamWindowSize != 0 {
+		clientCh.streamWindowSize = dOpts.webrtcOpts.StreamWindowSize
+	}
+	if err := clientCh.startHeartbeat(dOpts.webrtcOpts.HeartbeatInterval, dOpts.webrtcOpts.HeartbeatTimeout); err != nil {
+		return nil, multierr.Combine(err, clientCh.Close())
+	}
 
 	exchangeCandidates := func() error {
 		haveInit := false
@@ -295,11 +380,12 @@ func dialWebRTC(
 					return err
 				}
 
-				err = peerConn.SetRemoteDescription(answer)
+				err = peerOpts.setRemoteDescription(peerConn, answer)
 				if err != nil {
 					return err
 				}
 				close(remoteDescSet)
+				signalingSpan.End()
 
 				if dOpts.webrtcOpts.DisableTrickleICE {
 					return sendDone()
@@ -315,6 +401,7 @@ func dialWebRTC(
 				if err := peerConn.AddICECandidate(cand); err != nil {
 					return err
 				}
+				peerOpts.events.candidateAdded(cand, true)
 			default:
 				return errors.Errorf("unexpected stage %T", s)
 			}
@@ -340,7 +427,9 @@ func dialWebRTC(
 		}
 	}
 
-	if callErr := doCall(); callErr != nil {
+	callErr := doCall()
+	endSpan(iceSpan, callErr)
+	if callErr != nil {
 		var err error
 		sendDoneErrorOnce.Do(func() {
 			_, err = signalingClient.CallUpdate(exchangeCtx, &webrtcpb.CallUpdateRequest{
@@ -359,6 +448,47 @@ func dialWebRTC(
 	return clientCh, nil
 }
 
+// webrtcClientInterceptors returns the interceptors calls over a client channel go through.
+// The credentials, if any, come last so that each retry picks up the current access token.
+func webrtcClientInterceptors(
+	dOpts dialOptions,
+	creds *webrtcClientCredentials,
+) (grpc.UnaryClientInterceptor, grpc.StreamClientInterceptor) {
+	// trace context is propagated in the headers of each stream just as it is for gRPC.
+	unaryInterceptors := []grpc.UnaryClientInterceptor{UnaryClientTracingInterceptor()}
+	if dOpts.retryPolicy != nil {
+		unaryInterceptors = append(unaryInterceptors, newRetrier(*dOpts.retryPolicy).unaryClientInterceptor())
+	}
+	if dOpts.unaryInterceptor != nil {
+		unaryInterceptors = append(unaryInterceptors, dOpts.unaryInterceptor)
+	}
+	streamInterceptors := []grpc.StreamClientInterceptor{StreamClientTracingInterceptor()}
+	if dOpts.streamInterceptor != nil {
+		streamInterceptors = append(streamInterceptors, dOpts.streamInterceptor)
+	}
+	if creds != nil {
+		unaryInterceptors = append(unaryInterceptors, creds.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, creds.streamInterceptor)
+	}
+	return grpc_middleware.ChainUnaryClient(unaryInterceptors...), grpc_middleware.ChainStreamClient(streamInterceptors...)
+}
+
+// dialWebRTCConfig returns the WebRTC configuration to use for a dial based on the
+// given options and the optional configuration sent by the signaling server.
+func dialWebRTCConfig(webrtcOpts DialWebRTCOptions, optional *webrtcpb.WebRTCConfig) webrtc.Configuration {
+	config := DefaultWebRTCConfiguration
+	if webrtcOpts.Config != nil {
+		config = *webrtcOpts.Config
+	}
+	if webrtcOpts.LANOnly {
+		// external ICE servers are either unreachable or undesired; rely on host
+		// and mDNS candidates instead.
+		config.ICEServers = nil
+		return config
+	}
+	return extendWebRTCConfig(&config, optional)
+}
+
 func dialSignalingServer(
 	ctx context.Context,
 	signalingServer string,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"path"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,8 @@ var (
 	errWebRTCMaxStreams  = errors.New("stream limit hit")
 )
 
+var _ = ClientConnRenegotiator(&webrtcClientChannel{})
+
 // A webrtcClientChannel reflects the client end of a gRPC connection serviced over
 // a WebRTC data channel.
 type webrtcClientChannel struct {
@@ -38,6 +41,9 @@ type webrtcClientChannel struct {
 	streams           map[uint64]activeWebRTCClientStream
 	unaryInterceptor  grpc.UnaryClientInterceptor
 	streamInterceptor grpc.StreamClientInterceptor
+	// streamWindowSize is the window size asked for when flow controlling responses. If
+	// zero or negative, responses are not flow controlled.
+	streamWindowSize int
 }
 
 type activeWebRTCClientStream struct {
@@ -48,7 +54,7 @@ type activeWebRTCClientStream struct {
 // of a gRPC connection.
 func newWebRTCClientChannel(
 	peerConn *webrtc.PeerConnection,
-	dataChannel *webrtc.DataChannel,
+	dataChannel webrtcDataChannel,
 	logger golog.Logger,
 	unaryInterceptor grpc.UnaryClientInterceptor,
 	streamInterceptor grpc.StreamClientInterceptor,
@@ -65,11 +71,46 @@ func newWebRTCClientChannel(
 		streams:           map[uint64]activeWebRTCClientStream{},
 		unaryInterceptor:  unaryInterceptor,
 		streamInterceptor: streamInterceptor,
+		streamWindowSize:  DefaultWebRTCStreamWindowSize,
 	}
 	dataChannel.OnMessage(ch.onChannelMessage)
 	return ch
 }
 
+// useNegotiator associates the negotiator of this channel's peer connection with this
+// channel. In addition to closing the channel, any close reason received from the
+// remote peer fails all in-flight calls with the reason's status.
+func (ch *webrtcClientChannel) useNegotiator(negotiator *webrtcNegotiator) {
+	ch.webrtcBaseChannel.useNegotiator(negotiator)
+	negotiator.OnCloseReason(ch.onPeerCloseReason)
+}
+
+func (ch *webrtcClientChannel) onPeerCloseReason(reason *PeerCloseReason) {
+	ch.mu.Lock()
+	streamsToClose := make(map[uint64]activeWebRTCClientStream, len(ch.streams))
+	for k, v := range ch.streams {
+		streamsToClose[k] = v
+	}
+	ch.mu.Unlock()
+	for _, s := range streamsToClose {
+		s.cs.mu.Lock()
+		s.cs.closeWithError(reason, false)
+		s.cs.mu.Unlock()
+	}
+	ch.webrtcBaseChannel.onPeerCloseReason(reason)
+}
+
+// ClosedReason returns the reason the remote peer gave for closing this channel. It
+// is nil if the channel is open or the remote peer gave no reason.
+func (ch *webrtcClientChannel) ClosedReason() *PeerCloseReason {
+	_, err := ch.Closed()
+	var reason *PeerCloseReason
+	if errors.As(err, &reason) {
+		return reason
+	}
+	return nil
+}
+
 // Close closes all streams and the underlying channel.
 func (ch *webrtcClientChannel) Close() error {
 	ch.mu.Lock()
@@ -126,6 +167,7 @@ func (ch *webrtcClientChannel) invoke(
 	args, reply interface{},
 	opts ...grpc.CallOption,
 ) error {
+	ctx = contextWithStreamPriority(ctx, opts)
 	clientStream, err := ch.newStream(ctx, ch.nextStreamID())
 	if err != nil {
 		return err
@@ -144,13 +186,14 @@ func (ch *webrtcClientChannel) invoke(
 				if clientStream.trailers != nil {
 					*optV.TrailerAddr = clientStream.trailers.Copy()
 				}
+			case StreamPriorityCallOption:
 			default:
 				clientStream.webrtcBaseStream.logger.Errorf("do not know how to handle call option %T", opt)
 			}
 		}
 	}()
 
-	if err := clientStream.writeHeaders(makeRequestHeaders(ctx, method)); err != nil {
+	if err := clientStream.writeHeaders(makeRequestHeaders(ctx, method, ch.advertisedStreamWindowSize())); err != nil {
 		return err
 	}
 
@@ -185,15 +228,19 @@ func (ch *webrtcClientChannel) NewStream(
 ) (grpc.ClientStream, error) {
 	fields := newClientLoggerFields(method)
 	startTime := time.Now()
-	clientStream, err := ch.streamWithInterceptor(ctx, method)
+	clientStream, err := ch.streamWithInterceptor(ctx, method, opts...)
 	newCtx := ctxzap.ToContext(ctx, ch.webrtcBaseChannel.logger.Desugar().With(fields...))
 	logFinalClientLine(newCtx, startTime, err, "finished client streaming call")
 	return clientStream, err
 }
 
-func (ch *webrtcClientChannel) streamWithInterceptor(ctx context.Context, method string) (grpc.ClientStream, error) {
+func (ch *webrtcClientChannel) streamWithInterceptor(
+	ctx context.Context,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
 	if ch.streamInterceptor == nil {
-		return ch.newClientStream(ctx, method)
+		return ch.newClientStream(ctx, method, opts...)
 	}
 
 	// change signature of streamer to be compatible with grpc stream interceptor
@@ -204,29 +251,58 @@ func (ch *webrtcClientChannel) streamWithInterceptor(ctx context.Context, method
 		method string,
 		opts ...grpc.CallOption,
 	) (grpc.ClientStream, error) {
-		return ch.newClientStream(ctx, method)
+		return ch.newClientStream(ctx, method, opts...)
 	}
-	return ch.streamInterceptor(ctx, nil, nil, method, streamer)
+	return ch.streamInterceptor(ctx, nil, nil, method, streamer, opts...)
 }
 
-func (ch *webrtcClientChannel) newClientStream(ctx context.Context, method string) (grpc.ClientStream, error) {
+func (ch *webrtcClientChannel) newClientStream(
+	ctx context.Context,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	ctx = contextWithStreamPriority(ctx, opts)
 	clientStream, err := ch.newStream(ctx, ch.nextStreamID())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := clientStream.writeHeaders(makeRequestHeaders(ctx, method)); err != nil {
+	if err := clientStream.writeHeaders(makeRequestHeaders(ctx, method, ch.advertisedStreamWindowSize())); err != nil {
 		return nil, err
 	}
 
 	return clientStream, nil
 }
 
-func makeRequestHeaders(ctx context.Context, method string) *webrtcpb.RequestHeaders {
+// advertisedStreamWindowSize returns the window size to ask servers to flow control
+// responses with, if any. Flow control needs window updates to be sent over the
+// negotiation channel.
+func (ch *webrtcClientChannel) advertisedStreamWindowSize() int {
+	if ch.streamWindowSize <= 0 || !ch.hasNegotiator() {
+		return 0
+	}
+	return ch.streamWindowSize
+}
+
+func makeRequestHeaders(ctx context.Context, method string, windowSize int) *webrtcpb.RequestHeaders {
 	headersMD, _ := metadata.FromOutgoingContext(ctx)
+	headersMD = headersMD.Copy()
 	var timeout time.Duration
 	if deadline, ok := ctx.Deadline(); ok {
 		timeout = time.Until(deadline)
+		if timeout == 0 {
+			// zero means no timeout so make sure an expired deadline still is one.
+			timeout = -1
+		}
+		// the absolute deadline lets the server account for the time spent getting
+		// the headers to it.
+		headersMD.Set(webrtcDeadlineMetadataField, deadline.UTC().Format(time.RFC3339Nano))
+	}
+	if encodings := acceptedEncodings(); encodings != "" {
+		headersMD.Set(webrtcAcceptEncodingMetadataField, encodings)
+	}
+	if windowSize > 0 {
+		headersMD.Set(webrtcWindowSizeMetadataField, strconv.Itoa(windowSize))
 	}
 
 	return &webrtcpb.RequestHeaders{
@@ -275,12 +351,14 @@ func (ch *webrtcClientChannel) newStream(
 }
 
 func (ch *webrtcClientChannel) onChannelMessage(msg webrtc.DataChannelMessage) {
+	ch.markActivity()
 	resp := &webrtcpb.Response{}
 	err := proto.Unmarshal(msg.Data, resp)
 	if err != nil {
 		ch.webrtcBaseChannel.logger.Errorw("error unmarshaling message; discarding", "error", err)
 		return
 	}
+	ch.tracer.trace(FrameDirectionInbound, resp, len(msg.Data))
 
 	stream := resp.Stream
 	if stream == nil {
@@ -301,31 +379,31 @@ func (ch *webrtcClientChannel) onChannelMessage(msg webrtc.DataChannelMessage) {
 	activeStream.cs.onResponse(resp)
 }
 
-func (ch *webrtcClientChannel) writeHeaders(stream *webrtcpb.Stream, headers *webrtcpb.RequestHeaders) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Request{
+func (ch *webrtcClientChannel) writeHeaders(stream *webrtcpb.Stream, headers *webrtcpb.RequestHeaders, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Request{
 		Stream: stream,
 		Type: &webrtcpb.Request_Headers{
 			Headers: headers,
 		},
-	})
+	}, priority)
 }
 
-func (ch *webrtcClientChannel) writeMessage(stream *webrtcpb.Stream, msg *webrtcpb.RequestMessage) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Request{
+func (ch *webrtcClientChannel) writeMessage(stream *webrtcpb.Stream, msg *webrtcpb.RequestMessage, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Request{
 		Stream: stream,
 		Type: &webrtcpb.Request_Message{
 			Message: msg,
 		},
-	})
+	}, priority)
 }
 
-func (ch *webrtcClientChannel) writeReset(stream *webrtcpb.Stream) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Request{
+func (ch *webrtcClientChannel) writeReset(stream *webrtcpb.Stream, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Request{
 		Stream: stream,
 		Type: &webrtcpb.Request_RstStream{
 			RstStream: true,
 		},
-	})
+	}, priority)
 }
 
 // taken from
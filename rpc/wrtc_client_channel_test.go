@@ -599,3 +599,88 @@ func TestWebRTCClientChannelCanStopStreamRecvMsg(t *testing.T) {
 
 	<-serverFinished
 }
+
+// setupNegotiatingWebRTCChannels connects a client and server channel that are able
+// to exchange control messages and renegotiate over their negotiation channels.
+func setupNegotiatingWebRTCChannels(t *testing.T) (*webrtcClientChannel, *webrtcServerChannel) {
+	t.Helper()
+	logger := golog.NewTestLogger(t)
+
+	pc1, dc1, negotiator1, err := newPeerConnectionForClient(
+		context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	encodedSDP, err := encodeSDP(pc1.LocalDescription())
+	test.That(t, err, test.ShouldBeNil)
+	pc2, dc2, negotiator2, err := newPeerConnectionForServer(
+		context.Background(), encodedSDP, webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc1.SetRemoteDescription(*pc2.LocalDescription()), test.ShouldBeNil)
+
+	clientCh := newWebRTCClientChannel(pc1, dc1, logger, nil, nil)
+	clientCh.useNegotiator(negotiator1)
+	server := newWebRTCServer(logger)
+	serverCh := newWebRTCServerChannel(server, pc2, dc2, nil, logger)
+	serverCh.useNegotiator(negotiator2)
+	t.Cleanup(func() {
+		test.That(t, clientCh.Close(), test.ShouldBeNil)
+		test.That(t, serverCh.Close(), test.ShouldBeNil)
+		server.Stop()
+	})
+
+	<-clientCh.Ready()
+	<-serverCh.Ready()
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		for _, negotiator := range []*webrtcNegotiator{negotiator1, negotiator2} {
+			negotiator.mu.Lock()
+			isOpen := negotiator.open
+			negotiator.mu.Unlock()
+			test.That(tb, isOpen, test.ShouldBeTrue)
+		}
+	})
+	return clientCh, serverCh
+}
+
+func TestWebRTCClientChannelClosedReason(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	clientCh, serverCh := setupNegotiatingWebRTCChannels(t)
+	test.That(t, clientCh.ClosedReason(), test.ShouldBeNil)
+
+	clientStream, err := clientCh.newStream(context.Background(), clientCh.nextStreamID())
+	test.That(t, err, test.ShouldBeNil)
+
+	reason := &PeerCloseReason{Code: codes.PermissionDenied, Message: "credentials expired"}
+	test.That(t, serverCh.closeWithPeerReason(reason), test.ShouldBeNil)
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, clientCh.ClosedReason(), test.ShouldNotBeNil)
+	})
+	test.That(t, clientCh.ClosedReason().Code, test.ShouldEqual, codes.PermissionDenied)
+	test.That(t, clientCh.ClosedReason().Message, test.ShouldEqual, "credentials expired")
+
+	err = clientStream.RecvMsg(&webrtcpb.Response{})
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+}
+
+func TestWebRTCChannelRenegotiate(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	clientCh, serverCh := setupNegotiatingWebRTCChannels(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	test.That(t, clientCh.Renegotiate(ctx), test.ShouldBeNil)
+	test.That(t, clientCh.peerConn.SignalingState(), test.ShouldEqual, webrtc.SignalingStateStable)
+	test.That(t, serverCh.Renegotiate(ctx), test.ShouldBeNil)
+	test.That(t, serverCh.peerConn.SignalingState(), test.ShouldEqual, webrtc.SignalingStateStable)
+
+	// channels without a negotiator cannot renegotiate
+	logger := golog.NewTestLogger(t)
+	pc, dc, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	plainCh := newWebRTCClientChannel(pc, dc, logger, nil, nil)
+	defer func() {
+		test.That(t, plainCh.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, plainCh.Renegotiate(ctx), test.ShouldNotBeNil)
+}
@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/edaniels/golog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils"
+)
+
+// webrtcClientCredentials sends an access token along with the calls over a client
+// channel so that the host sees the same entity and claims as it would over gRPC. The
+// token is gotten by authenticating over the channel itself. Hosts that do not
+// authenticate, or do not accept the credentials since they were only meant for the
+// signaler, are called without one.
+type webrtcClientCredentials struct {
+	jwtCreds *perRPCJWTCredentials
+	logger   golog.Logger
+
+	mu sync.Mutex
+	// unsupported is set once the host did not hand out an access token for the credentials.
+	unsupported bool
+}
+
+// newWebRTCClientCredentials returns credentials authenticating to the host with the
+// credentials of the dial. The entity is the host unless one was given.
+func newWebRTCClientCredentials(dOpts dialOptions, host string, logger golog.Logger) *webrtcClientCredentials {
+	entity := dOpts.authEntity
+	if entity == "" {
+		entity = host
+	}
+	return &webrtcClientCredentials{
+		jwtCreds: &perRPCJWTCredentials{
+			entity: entity,
+			creds:  dOpts.creds,
+			debug:  dOpts.debug,
+			logger: logger,
+		},
+		logger: logger,
+	}
+}
+
+// useChannel sets the channel to authenticate over and stops refreshing the access token
+// once the channel closes.
+func (creds *webrtcClientCredentials) useChannel(ch *webrtcClientChannel) {
+	creds.jwtCreds.conn = ch
+	ch.activeBackgroundWorkers.Add(1)
+	utils.PanicCapturingGo(func() {
+		defer ch.activeBackgroundWorkers.Done()
+		<-ch.ctx.Done()
+		creds.jwtCreds.close()
+	})
+}
+
+// contextWithAccessToken returns the context to make the call with. Calls that already
+// carry an access token are left alone.
+func (creds *webrtcClientCredentials) contextWithAccessToken(ctx context.Context, method string) (context.Context, error) {
+	if isAuthServiceMethod(method) {
+		return ctx, nil
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(MetadataFieldAuthorization)) != 0 {
+		return ctx, nil
+	}
+	creds.mu.Lock()
+	unsupported := creds.unsupported
+	creds.mu.Unlock()
+	if unsupported {
+		return ctx, nil
+	}
+
+	accessToken, err := creds.jwtCreds.authenticate(ctx)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unimplemented, codes.Unauthenticated, codes.PermissionDenied:
+			creds.logger.Debugw("host does not accept credentials; calling without an access token", "error", err)
+			creds.mu.Lock()
+			creds.unsupported = true
+			creds.mu.Unlock()
+			return ctx, nil
+		default:
+			return nil, err
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataFieldAuthorization, AuthorizationValuePrefixBearer+accessToken), nil
+}
+
+func (creds *webrtcClientCredentials) unaryInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	ctx, err := creds.contextWithAccessToken(ctx, method)
+	if err != nil {
+		return err
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (creds *webrtcClientCredentials) streamInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	ctx, err := creds.contextWithAccessToken(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/edaniels/golog"
 	protov1 "github.com/golang/protobuf/proto" //nolint:staticcheck
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -32,6 +33,12 @@ type webrtcClientStream struct {
 	headersReceived  chan struct{}
 	trailersReceived bool
 	sendClosed       bool
+	// compressor, if set, is what the server compresses the messages of this stream with.
+	compressor Compressor
+	// recvWindow, if set, tracks what to acknowledge to the server for flow control.
+	recvWindow *webrtcRecvWindow
+	// priority is what this stream's frames are scheduled with.
+	priority StreamPriority
 }
 
 // newWebRTCClientStream creates a gRPC stream from the given client channel with a
@@ -44,6 +51,8 @@ func newWebRTCClientStream(
 	onDone func(id uint64),
 	logger golog.Logger,
 ) *webrtcClientStream {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	priority := streamPriorityFromMetadata(md)
 	ctx, cancel := utils.MergeContext(channel.ctx, ctx)
 	bs := newWebRTCBaseStream(ctx, cancel, stream, onDone, logger)
 	s := &webrtcClientStream{
@@ -52,6 +61,7 @@ func newWebRTCClientStream(
 		cancel:           cancel,
 		ch:               channel,
 		headersReceived:  make(chan struct{}),
+		priority:         priority,
 	}
 	channel.activeBackgroundWorkers.Add(1)
 	utils.PanicCapturingGo(func() {
@@ -159,7 +169,7 @@ func (s *webrtcClientStream) resetStream() (err error) {
 	defer func() {
 		s.webrtcBaseStream.closeWithError(checkWriteErrForStreamClose(err), false)
 	}()
-	return s.ch.writeReset(s.webrtcBaseStream.stream)
+	return s.ch.writeReset(s.webrtcBaseStream.stream, s.priority)
 }
 
 func (s *webrtcClientStream) Close() {
@@ -184,7 +194,7 @@ func (s *webrtcClientStream) writeHeaders(headers *webrtcpb.RequestHeaders) (err
 			s.webrtcBaseStream.closeWithError(err, false)
 		}
 	}()
-	return s.ch.writeHeaders(s.webrtcBaseStream.stream, headers)
+	return s.ch.writeHeaders(s.webrtcBaseStream.stream, headers, s.priority)
 }
 
 var maxRequestMessagePacketDataSize int
@@ -256,7 +266,7 @@ func (s *webrtcClientStream) writeMessage(m interface{}, eos bool) (err error) {
 				Eom: true,
 			},
 			Eos: eos,
-		})
+		}, s.priority)
 	}
 
 	for len(data) != 0 {
@@ -275,7 +285,7 @@ func (s *webrtcClientStream) writeMessage(m interface{}, eos bool) (err error) {
 			HasMessage:    m != nil, // maybe no data but a non-nil message
 			PacketMessage: packet,
 			Eos:           eos,
-		}); err != nil {
+		}, s.priority); err != nil {
 			return err
 		}
 	}
@@ -318,6 +328,21 @@ func (s *webrtcClientStream) onResponse(resp *webrtcpb.Response) {
 func (s *webrtcClientStream) processHeaders(headers *webrtcpb.ResponseHeaders) {
 	s.webrtcBaseStream.mu.Lock()
 	s.headers = metadataFromProto(headers.Metadata)
+	if encoding := s.headers.Get(webrtcEncodingMetadataField); len(encoding) != 0 {
+		delete(s.headers, webrtcEncodingMetadataField)
+		compressor, ok := getCompressor(encoding[0])
+		if !ok {
+			s.webrtcBaseStream.closeWithError(status.Errorf(codes.Internal, "unsupported message encoding %q", encoding[0]), false)
+			s.webrtcBaseStream.mu.Unlock()
+			return
+		}
+		s.compressor = compressor
+	}
+	if _, ok := streamWindowSizeFromMetadata(s.headers); ok {
+		delete(s.headers, webrtcWindowSizeMetadataField)
+		// the server honors the window we asked for
+		s.recvWindow = newWebRTCRecvWindow(s.ch.streamWindowSize)
+	}
 	s.userCtx = metadata.NewIncomingContext(s.ctx, s.headers)
 	s.webrtcBaseStream.mu.Unlock()
 	close(s.headersReceived)
@@ -328,10 +353,21 @@ func (s *webrtcClientStream) processMessage(msg *webrtcpb.ResponseMessage) {
 		s.webrtcBaseStream.logger.Error("message received after trailers")
 		return
 	}
+	packetSize := len(msg.PacketMessage.GetData())
 	data, eop := s.webrtcBaseStream.processMessage(msg.PacketMessage)
 	if !eop {
+		s.consumed(packetSize)
 		return
 	}
+	if s.compressor != nil {
+		var err error
+		if data, err = s.compressor.Decompress(data, MaxMessageSize); err != nil {
+			s.webrtcBaseStream.mu.Lock()
+			s.webrtcBaseStream.closeWithError(status.Errorf(codes.Internal, "error decompressing message: %s", err), false)
+			s.webrtcBaseStream.mu.Unlock()
+			return
+		}
+	}
 	s.webrtcBaseStream.mu.Lock()
 	if s.webrtcBaseStream.recvClosed.Load() {
 		s.webrtcBaseStream.mu.Unlock()
@@ -345,11 +381,22 @@ func (s *webrtcClientStream) processMessage(msg *webrtcpb.ResponseMessage) {
 		defer s.webrtcBaseStream.activeSenders.Done()
 		select {
 		case msgCh <- data:
+			s.consumed(packetSize)
 		case <-s.ctx.Done():
 		}
 	}()
 }
 
+// consumed acknowledges consumed bytes to the server if this stream is flow controlled.
+func (s *webrtcClientStream) consumed(n int) {
+	if s.recvWindow == nil {
+		return
+	}
+	if increment := s.recvWindow.consumed(n); increment > 0 {
+		s.ch.sendWindowUpdate(s.webrtcBaseStream.stream.Id, increment)
+	}
+}
+
 func (s *webrtcClientStream) processTrailers(trailers *webrtcpb.ResponseTrailers) {
 	s.webrtcBaseStream.mu.Lock()
 	defer s.webrtcBaseStream.mu.Unlock()
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -499,14 +500,14 @@ func testWebRTCClientAnswerConcurrent(t *testing.T, signalingCallQueue WebRTCCal
 	md.Append(RPCHostMetadataField, host)
 	callCtx := metadata.NewOutgoingContext(context.Background(), md)
 
-	pc1, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, logger)
+	pc1, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
 	test.That(t, err, test.ShouldBeNil)
 	defer pc1.Close()
 
 	encodedSDP1, err := encodeSDP(pc1.LocalDescription())
 	test.That(t, err, test.ShouldBeNil)
 
-	pc2, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, logger)
+	pc2, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, webrtcPeerOptions{}, logger)
 	test.That(t, err, test.ShouldBeNil)
 	defer pc2.Close()
 
@@ -610,3 +611,126 @@ func TestWebRTCClientSubsequentStreams(t *testing.T) {
 	err = <-errChan
 	test.That(t, err, test.ShouldBeNil)
 }
+
+func TestDialWebRTCConfigLANOnly(t *testing.T) {
+	optional := &webrtcpb.WebRTCConfig{
+		AdditionalIceServers: []*webrtcpb.ICEServer{{Urls: []string{"turn:example.com"}}},
+	}
+
+	config := dialWebRTCConfig(DialWebRTCOptions{}, optional)
+	test.That(t, config.ICEServers, test.ShouldNotBeEmpty)
+
+	config = dialWebRTCConfig(DialWebRTCOptions{LANOnly: true}, optional)
+	test.That(t, config.ICEServers, test.ShouldBeEmpty)
+
+	config = dialWebRTCConfig(DialWebRTCOptions{
+		LANOnly: true,
+		Config:  &webrtc.Configuration{ICEServers: DefaultICEServers},
+	}, nil)
+	test.That(t, config.ICEServers, test.ShouldBeEmpty)
+}
+
+func TestPeerConnectionForClientGatherTimeout(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	// gathering from an unreachable STUN server takes far longer than the timeout.
+	config := webrtc.Configuration{ICEServers: []webrtc.ICEServer{{URLs: []string{"stun:192.0.2.1:3478"}}}}
+	start := time.Now()
+	pc, _, _, err := newPeerConnectionForClient(
+		context.Background(),
+		config,
+		true,
+		webrtcPeerOptions{gatherTimeout: 100 * time.Millisecond},
+		logger,
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pc.Close(), test.ShouldBeNil)
+	}()
+	test.That(t, time.Since(start), test.ShouldBeLessThan, 2*time.Second)
+	test.That(t, pc.LocalDescription(), test.ShouldNotBeNil)
+}
+
+func TestWebRTCClientCredentials(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	listenerAddr := listener.Addr().String()
+
+	// handlers see the entity and claims of the credentials dialed with, not just the
+	// host the call was signaled for.
+	var streamEntityMu sync.Mutex
+	var streamEntity string
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"alice"}, "sosecret")),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{listenerAddr},
+		}),
+		WithStreamServerInterceptor(func(
+			srv interface{},
+			serverStream grpc.ServerStream,
+			info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler,
+		) error {
+			if info.FullMethod == "/"+echopb.EchoService_ServiceDesc.ServiceName+"/EchoMultiple" {
+				streamEntityMu.Lock()
+				streamEntity = MustAuthClaims[JWTClaims](serverStream.Context()).Entity()
+				streamEntityMu.Unlock()
+			}
+			return handler(srv, serverStream)
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	echoServer := &echoserver.Server{
+		MustContextAuthEntity: func(ctx context.Context) echoserver.RPCEntityInfo {
+			return echoserver.RPCEntityInfo{Entity: MustAuthClaims[JWTClaims](ctx).Entity()}
+		},
+	}
+	echoServer.SetAuthorized(true)
+	echoServer.SetExpectedAuthEntity("alice")
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &echopb.EchoService_ServiceDesc, echoServer), test.ShouldBeNil)
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	conn, err := Dial(
+		context.Background(),
+		listenerAddr,
+		logger,
+		WithInsecure(),
+		WithDisableDirectGRPC(),
+		WithEntityCredentials("alice", Credentials{Type: "fake", Payload: "sosecret"}),
+		WithWebRTCOptions(DialWebRTCOptions{SignalingInsecure: true}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	_, ok := conn.(*webrtcClientChannel)
+	test.That(t, ok, test.ShouldBeTrue)
+	client := echopb.NewEchoServiceClient(conn)
+
+	resp, err := client.Echo(context.Background(), &echopb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, "hello")
+
+	echoMultiClient, err := client.EchoMultiple(context.Background(), &echopb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	for {
+		if _, err := echoMultiClient.Recv(); err != nil {
+			test.That(t, err, test.ShouldEqual, io.EOF)
+			break
+		}
+	}
+	streamEntityMu.Lock()
+	test.That(t, streamEntity, test.ShouldEqual, "alice")
+	streamEntityMu.Unlock()
+
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// GzipCompressorName is the name of the built-in gzip compressor.
+	GzipCompressorName = "gzip"
+	// SnappyCompressorName is the name of the built-in snappy compressor.
+	SnappyCompressorName = "snappy"
+
+	// webrtcAcceptEncodingMetadataField carries the compressors a client can decode
+	// in the request headers of a stream.
+	webrtcAcceptEncodingMetadataField = "rpc-webrtc-accept-encoding"
+	// webrtcEncodingMetadataField carries the compressor, if any, that a server chose
+	// for the messages of a stream in the response headers of that stream.
+	webrtcEncodingMetadataField = "rpc-webrtc-encoding"
+)
+
+// A Compressor compresses messages tunneled over a WebRTC channel. Compression is
+// negotiated per stream: clients advertise every registered compressor and the server
+// compresses its responses with the first of its configured compressors that the client
+// advertised. If there is none, messages are sent as is.
+type Compressor interface {
+	// Name is the name the compressor is negotiated by.
+	Name() string
+	// Compress returns the compressed form of data.
+	Compress(data []byte) ([]byte, error)
+	// Decompress returns the decompressed form of data. It must fail instead of
+	// returning more than maxSize bytes.
+	Decompress(data []byte, maxSize int) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{}
+	// compressorNames keeps registration order so that what a client advertises is stable.
+	compressorNames []string
+)
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+	RegisterCompressor(snappyCompressor{})
+}
+
+// RegisterCompressor makes a compressor available to WebRTC channels under its name,
+// replacing any compressor already registered under that name. It should only be
+// called during initialization.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	if _, ok := compressors[c.Name()]; !ok {
+		compressorNames = append(compressorNames, c.Name())
+	}
+	compressors[c.Name()] = c
+}
+
+// getCompressor returns the compressor registered under the given name, if any.
+func getCompressor(name string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// acceptedEncodings returns the names of all registered compressors as advertised
+// by clients.
+func acceptedEncodings() string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return strings.Join(compressorNames, ",")
+}
+
+// negotiateCompressor returns the first of the preferred compressors that is both
+// registered and accepted by the remote peer according to its request metadata. If
+// there is none, nil is returned and no compression should be used.
+func negotiateCompressor(preferred []string, md metadata.MD) Compressor {
+	accepted := map[string]bool{}
+	for _, value := range md.Get(webrtcAcceptEncodingMetadataField) {
+		for _, name := range strings.Split(value, ",") {
+			accepted[strings.TrimSpace(name)] = true
+		}
+	}
+	for _, name := range preferred {
+		if !accepted[name] {
+			continue
+		}
+		if c, ok := getCompressor(name); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string {
+	return GzipCompressorName
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	//nolint:gosec // bounded by the limit reader
+	decompressed, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > maxSize {
+		return nil, errors.Errorf("decompressed message size larger than max %d", maxSize)
+	}
+	return decompressed, r.Close()
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string {
+	return SnappyCompressorName
+}
+
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, errors.Errorf("decompressed message size larger than max %d", maxSize)
+	}
+	return snappy.Decode(nil, data)
+}
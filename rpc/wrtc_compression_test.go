@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+func TestCompressors(t *testing.T) {
+	data := bytes.Repeat([]byte("point cloud "), 1000)
+	for _, name := range []string{GzipCompressorName, SnappyCompressorName} {
+		t.Run(name, func(t *testing.T) {
+			c, ok := getCompressor(name)
+			test.That(t, ok, test.ShouldBeTrue)
+			test.That(t, c.Name(), test.ShouldEqual, name)
+
+			compressed, err := c.Compress(data)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, len(compressed), test.ShouldBeLessThan, len(data))
+
+			decompressed, err := c.Decompress(compressed, len(data))
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, decompressed, test.ShouldResemble, data)
+
+			_, err = c.Decompress(compressed, len(data)-1)
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, "larger than max")
+
+			_, err = c.Decompress([]byte("garbage"), len(data))
+			test.That(t, err, test.ShouldNotBeNil)
+		})
+	}
+}
+
+func TestNegotiateCompressor(t *testing.T) {
+	accepting := func(value string) metadata.MD {
+		return metadata.Pairs(webrtcAcceptEncodingMetadataField, value)
+	}
+
+	test.That(t, negotiateCompressor(nil, accepting(acceptedEncodings())), test.ShouldBeNil)
+	test.That(t, negotiateCompressor([]string{GzipCompressorName}, nil), test.ShouldBeNil)
+	test.That(t, negotiateCompressor([]string{GzipCompressorName}, accepting(SnappyCompressorName)), test.ShouldBeNil)
+	test.That(t, negotiateCompressor([]string{"unregistered"}, accepting("unregistered")), test.ShouldBeNil)
+
+	c := negotiateCompressor([]string{SnappyCompressorName, GzipCompressorName}, accepting("gzip, snappy"))
+	test.That(t, c, test.ShouldNotBeNil)
+	test.That(t, c.Name(), test.ShouldEqual, SnappyCompressorName)
+
+	c = negotiateCompressor([]string{"unregistered", GzipCompressorName}, accepting("unregistered,gzip"))
+	test.That(t, c, test.ShouldNotBeNil)
+	test.That(t, c.Name(), test.ShouldEqual, GzipCompressorName)
+
+	encodings := strings.Split(acceptedEncodings(), ",")
+	test.That(t, encodings, test.ShouldContain, GzipCompressorName)
+	test.That(t, encodings, test.ShouldContain, SnappyCompressorName)
+}
+
+func TestWebRTCChannelCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		compressors []string
+	}{
+		{"identity", nil},
+		{"gzip", []string{GzipCompressorName}},
+		{"snappy", []string{SnappyCompressorName, GzipCompressorName}},
+		{"unsupported by client", []string{"unregistered"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := golog.NewTestLogger(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+			test.That(t, err, test.ShouldBeNil)
+			defer func() {
+				test.That(t, pair.Close(), test.ShouldBeNil)
+			}()
+			pair.server.compressors = tc.compressors
+			pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+
+			client := echopb.NewEchoServiceClient(pair.Client())
+			message := strings.Repeat("hello", 1<<16)
+			var header metadata.MD
+			resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: message}, grpc.Header(&header))
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, resp.GetMessage(), test.ShouldEqual, message)
+			// the encoding is an implementation detail of the channel
+			test.That(t, header.Get(webrtcEncodingMetadataField), test.ShouldBeEmpty)
+
+			multiClient, err := client.EchoMultiple(ctx, &echopb.EchoMultipleRequest{Message: "howdy"})
+			test.That(t, err, test.ShouldBeNil)
+			var received string
+			for {
+				resp, err := multiClient.Recv()
+				if err != nil {
+					break
+				}
+				received += resp.GetMessage()
+			}
+			test.That(t, received, test.ShouldEqual, "howdy")
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultWebRTCStreamWindowSize is how many bytes of response messages a server may
+// send on a single stream before the client must acknowledge them.
+const DefaultWebRTCStreamWindowSize = 1 << 20
+
+// webrtcWindowSizeMetadataField carries the window size of a stream. Clients send it in
+// request headers to ask for flow control of responses and servers send it back in
+// response headers to say they honor it. Window updates are sent over the negotiation
+// channel so that they are never stuck behind the messages they regulate.
+const webrtcWindowSizeMetadataField = "rpc-webrtc-window-size"
+
+// streamWindowSizeFromMetadata returns the window size requested in the given metadata,
+// if there is a valid one.
+func streamWindowSizeFromMetadata(md metadata.MD) (int, bool) {
+	values := md.Get(webrtcWindowSizeMetadataField)
+	if len(values) == 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(values[0])
+	if err != nil || size <= 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// A webrtcSendWindow limits how many unacknowledged bytes a stream may have in flight
+// so that one stream cannot monopolize the data channel it shares with others.
+type webrtcSendWindow struct {
+	size int
+
+	mu        sync.Mutex
+	available int
+	updated   chan struct{}
+}
+
+func newWebRTCSendWindow(size int) *webrtcSendWindow {
+	return &webrtcSendWindow{
+		size:      size,
+		available: size,
+		updated:   make(chan struct{}),
+	}
+}
+
+// take waits until there is room in the window and then takes n bytes from it. The
+// window may go negative so that packets larger than the window still make progress.
+func (w *webrtcSendWindow) take(ctx context.Context, n int) error {
+	for {
+		w.mu.Lock()
+		if w.available > 0 {
+			w.available -= n
+			w.mu.Unlock()
+			return nil
+		}
+		updated := w.updated
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		}
+	}
+}
+
+// release returns n acknowledged bytes to the window.
+func (w *webrtcSendWindow) release(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.available += n
+	close(w.updated)
+	w.updated = make(chan struct{})
+}
+
+// A webrtcRecvWindow tracks the bytes a stream has consumed but not yet acknowledged.
+// It is only used from the message handler of a channel and is not safe for concurrent use.
+type webrtcRecvWindow struct {
+	size    int
+	unacked int
+}
+
+func newWebRTCRecvWindow(size int) *webrtcRecvWindow {
+	return &webrtcRecvWindow{size: size}
+}
+
+// consumed records that n bytes were consumed and returns how many bytes should be
+// acknowledged to the sender now, if any. Acknowledgements are batched to a quarter
+// of the window in order to limit control traffic.
+func (w *webrtcRecvWindow) consumed(n int) int {
+	w.unacked += n
+	if w.unacked < w.size/4 {
+		return 0
+	}
+	increment := w.unacked
+	w.unacked = 0
+	return increment
+}
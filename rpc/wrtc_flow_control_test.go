@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+func TestStreamWindowSizeFromMetadata(t *testing.T) {
+	_, ok := streamWindowSizeFromMetadata(nil)
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = streamWindowSizeFromMetadata(metadata.Pairs(webrtcWindowSizeMetadataField, "big"))
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = streamWindowSizeFromMetadata(metadata.Pairs(webrtcWindowSizeMetadataField, "0"))
+	test.That(t, ok, test.ShouldBeFalse)
+	size, ok := streamWindowSizeFromMetadata(metadata.Pairs(webrtcWindowSizeMetadataField, "1024"))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, size, test.ShouldEqual, 1024)
+}
+
+func TestWebRTCSendWindow(t *testing.T) {
+	w := newWebRTCSendWindow(10)
+	test.That(t, w.take(context.Background(), 6), test.ShouldBeNil)
+	// going past the window is allowed as long as there was room to begin with
+	test.That(t, w.take(context.Background(), 6), test.ShouldBeNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	test.That(t, errors.Is(w.take(ctx, 1), context.DeadlineExceeded), test.ShouldBeTrue)
+
+	taken := make(chan error, 1)
+	go func() {
+		taken <- w.take(context.Background(), 1)
+	}()
+	w.release(2)
+	select {
+	case <-taken:
+		t.Fatal("expected take to wait for the window to open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.release(1)
+	test.That(t, <-taken, test.ShouldBeNil)
+}
+
+func TestWebRTCRecvWindow(t *testing.T) {
+	w := newWebRTCRecvWindow(100)
+	test.That(t, w.consumed(10), test.ShouldEqual, 0)
+	test.That(t, w.consumed(10), test.ShouldEqual, 0)
+	test.That(t, w.consumed(5), test.ShouldEqual, 25)
+	test.That(t, w.consumed(24), test.ShouldEqual, 0)
+	test.That(t, w.consumed(200), test.ShouldEqual, 224)
+}
+
+func TestWebRTCChannelFlowControl(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	// small enough that every call below has to wait on window updates
+	pair.client.streamWindowSize = 64
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+	client := echopb.NewEchoServiceClient(pair.Client())
+
+	message := strings.Repeat("a", 512)
+	results := make(chan string, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			multiClient, err := client.EchoMultiple(ctx, &echopb.EchoMultipleRequest{Message: message})
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			var received string
+			for {
+				resp, err := multiClient.Recv()
+				if err != nil {
+					break
+				}
+				received += resp.GetMessage()
+			}
+			results <- received
+		}()
+	}
+
+	// a message larger than the window still gets through
+	largeMessage := strings.Repeat("hello", 1<<14)
+	var header metadata.MD
+	resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: largeMessage}, grpc.Header(&header))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, largeMessage)
+	// the window is an implementation detail of the channel
+	test.That(t, header.Get(webrtcWindowSizeMetadataField), test.ShouldBeEmpty)
+
+	wg.Wait()
+	close(results)
+	for received := range results {
+		test.That(t, received, test.ShouldEqual, message)
+	}
+}
@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/utils"
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+)
+
+// A FrameDirection is which way a traced frame went over a WebRTC channel.
+type FrameDirection string
+
+// The known frame directions, relative to the traced end of the channel.
+const (
+	FrameDirectionInbound  FrameDirection = "in"
+	FrameDirectionOutbound FrameDirection = "out"
+)
+
+// The known frame types. Requests carry headers, messages, and stream resets while
+// responses carry headers, messages, and trailers.
+const (
+	FrameTypeHeaders   = "headers"
+	FrameTypeMessage   = "message"
+	FrameTypeTrailers  = "trailers"
+	FrameTypeRstStream = "rst_stream"
+	FrameTypeUnknown   = "unknown"
+)
+
+// A FrameTrace records a single frame sent or received over a WebRTC channel. Traces
+// are written as JSON, one object per line, in the order the frames were seen:
+//
+//	{"time":"2023-01-01T12:00:00.000000001Z","channel":1,"direction":"out","stream":1,"type":"headers","size":42}
+//
+// Channel tells apart the channels traced to the same writer, such as all peers of a
+// server, in the order they were created. Size is the size in bytes of the frame as it was on the wire. EOM and EOS are only
+// set on message frames that end a message or the sending side of a stream respectively.
+type FrameTrace struct {
+	Time      time.Time      `json:"time"`
+	Channel   uint64         `json:"channel"`
+	Direction FrameDirection `json:"direction"`
+	Stream    uint64         `json:"stream"`
+	Type      string         `json:"type"`
+	Size      int            `json:"size"`
+	EOM       bool           `json:"eom,omitempty"`
+	EOS       bool           `json:"eos,omitempty"`
+}
+
+// newFrameTrace describes the given request or response frame.
+func newFrameTrace(direction FrameDirection, frame proto.Message, size int) FrameTrace {
+	trace := FrameTrace{
+		Time:      time.Now(),
+		Direction: direction,
+		Type:      FrameTypeUnknown,
+		Size:      size,
+	}
+	switch f := frame.(type) {
+	case *webrtcpb.Request:
+		trace.Stream = f.GetStream().GetId()
+		switch r := f.Type.(type) {
+		case *webrtcpb.Request_Headers:
+			trace.Type = FrameTypeHeaders
+		case *webrtcpb.Request_Message:
+			trace.Type = FrameTypeMessage
+			trace.EOM = r.Message.GetPacketMessage().GetEom()
+			trace.EOS = r.Message.GetEos()
+		case *webrtcpb.Request_RstStream:
+			trace.Type = FrameTypeRstStream
+		}
+	case *webrtcpb.Response:
+		trace.Stream = f.GetStream().GetId()
+		switch r := f.Type.(type) {
+		case *webrtcpb.Response_Headers:
+			trace.Type = FrameTypeHeaders
+		case *webrtcpb.Response_Message:
+			trace.Type = FrameTypeMessage
+			trace.EOM = r.Message.GetPacketMessage().GetEom()
+		case *webrtcpb.Response_Trailers:
+			trace.Type = FrameTypeTrailers
+		}
+	}
+	return trace
+}
+
+// A webrtcFrameTracer writes frame traces of channels to a writer.
+type webrtcFrameTracer struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	channels uint64
+}
+
+func newWebRTCFrameTracer(w io.Writer) *webrtcFrameTracer {
+	if w == nil {
+		return nil
+	}
+	return &webrtcFrameTracer{enc: json.NewEncoder(w)}
+}
+
+// forChannel returns a tracer for a newly created channel.
+func (t *webrtcFrameTracer) forChannel() *webrtcChannelFrameTracer {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channels++
+	return &webrtcChannelFrameTracer{tracer: t, channel: t.channels}
+}
+
+// A webrtcChannelFrameTracer traces the frames of a single channel. It is nil safe so
+// that channels without tracing need not check for it.
+type webrtcChannelFrameTracer struct {
+	tracer  *webrtcFrameTracer
+	channel uint64
+}
+
+func (t *webrtcChannelFrameTracer) trace(direction FrameDirection, frame proto.Message, size int) {
+	if t == nil {
+		return
+	}
+	trace := newFrameTrace(direction, frame, size)
+	trace.Channel = t.channel
+	t.tracer.mu.Lock()
+	defer t.tracer.mu.Unlock()
+	// tracing must never interfere with the channel
+	utils.UncheckedError(t.tracer.enc.Encode(trace))
+}
+
+// A FrameTraceDecoder reads frame traces written by a traced WebRTC channel.
+type FrameTraceDecoder struct {
+	dec *json.Decoder
+}
+
+// NewFrameTraceDecoder returns a decoder reading frame traces from r.
+func NewFrameTraceDecoder(r io.Reader) *FrameTraceDecoder {
+	return &FrameTraceDecoder{dec: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next returns the next frame trace. It returns io.EOF when there are no more.
+func (d *FrameTraceDecoder) Next() (FrameTrace, error) {
+	var trace FrameTrace
+	if err := d.dec.Decode(&trace); err != nil {
+		return FrameTrace{}, err
+	}
+	return trace, nil
+}
@@ -0,0 +1,131 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/proto"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestNewFrameTrace(t *testing.T) {
+	stream := &webrtcpb.Stream{Id: 3}
+	for _, tc := range []struct {
+		frame    proto.Message
+		expected FrameTrace
+	}{
+		{
+			&webrtcpb.Request{Stream: stream, Type: &webrtcpb.Request_Headers{Headers: &webrtcpb.RequestHeaders{}}},
+			FrameTrace{Stream: 3, Type: FrameTypeHeaders},
+		},
+		{
+			&webrtcpb.Request{Stream: stream, Type: &webrtcpb.Request_Message{Message: &webrtcpb.RequestMessage{
+				HasMessage:    true,
+				PacketMessage: &webrtcpb.PacketMessage{Eom: true},
+				Eos:           true,
+			}}},
+			FrameTrace{Stream: 3, Type: FrameTypeMessage, EOM: true, EOS: true},
+		},
+		{
+			&webrtcpb.Request{Stream: stream, Type: &webrtcpb.Request_RstStream{RstStream: true}},
+			FrameTrace{Stream: 3, Type: FrameTypeRstStream},
+		},
+		{
+			&webrtcpb.Response{Stream: stream, Type: &webrtcpb.Response_Headers{Headers: &webrtcpb.ResponseHeaders{}}},
+			FrameTrace{Stream: 3, Type: FrameTypeHeaders},
+		},
+		{
+			&webrtcpb.Response{Stream: stream, Type: &webrtcpb.Response_Message{Message: &webrtcpb.ResponseMessage{
+				PacketMessage: &webrtcpb.PacketMessage{Data: []byte{1}},
+			}}},
+			FrameTrace{Stream: 3, Type: FrameTypeMessage},
+		},
+		{
+			&webrtcpb.Response{Stream: stream, Type: &webrtcpb.Response_Trailers{Trailers: &webrtcpb.ResponseTrailers{}}},
+			FrameTrace{Stream: 3, Type: FrameTypeTrailers},
+		},
+		{
+			&webrtcpb.Stream{Id: 4},
+			FrameTrace{Type: FrameTypeUnknown},
+		},
+	} {
+		trace := newFrameTrace(FrameDirectionInbound, tc.frame, 7)
+		test.That(t, trace.Time.IsZero(), test.ShouldBeFalse)
+		trace.Time = time.Time{}
+		tc.expected.Direction = FrameDirectionInbound
+		tc.expected.Size = 7
+		test.That(t, trace, test.ShouldResemble, tc.expected)
+	}
+}
+
+func TestWebRTCChannelFrameTracing(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	var buf bytes.Buffer
+	tracer := newWebRTCFrameTracer(&buf)
+	pair.client.tracer = tracer.forChannel()
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+
+	client := echopb.NewEchoServiceClient(pair.Client())
+	resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, "hello")
+
+	// trailers may still be on their way after the call returns
+	var traces []FrameTrace
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		tracer.mu.Lock()
+		dec := NewFrameTraceDecoder(bytes.NewReader(buf.Bytes()))
+		tracer.mu.Unlock()
+		traces = nil
+		for {
+			trace, err := dec.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			test.That(tb, err, test.ShouldBeNil)
+			traces = append(traces, trace)
+		}
+		test.That(tb, traces, test.ShouldHaveLength, 5)
+	})
+
+	type frame struct {
+		direction FrameDirection
+		frameType string
+	}
+	var frames []frame
+	for _, trace := range traces {
+		test.That(t, trace.Channel, test.ShouldEqual, 1)
+		test.That(t, trace.Stream, test.ShouldEqual, 1)
+		test.That(t, trace.Size, test.ShouldBeGreaterThan, 0)
+		frames = append(frames, frame{trace.Direction, trace.Type})
+	}
+	test.That(t, frames, test.ShouldResemble, []frame{
+		{FrameDirectionOutbound, FrameTypeHeaders},
+		{FrameDirectionOutbound, FrameTypeMessage},
+		{FrameDirectionInbound, FrameTypeHeaders},
+		{FrameDirectionInbound, FrameTypeMessage},
+		{FrameDirectionInbound, FrameTypeTrailers},
+	})
+	test.That(t, traces[1].EOM, test.ShouldBeTrue)
+	test.That(t, traces[1].EOS, test.ShouldBeTrue)
+	test.That(t, traces[3].EOM, test.ShouldBeTrue)
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"go.viam.com/utils"
+)
+
+var (
+	// DefaultWebRTCHeartbeatInterval is how often a heartbeat is sent to the remote peer
+	// of a WebRTC channel.
+	DefaultWebRTCHeartbeatInterval = 5 * time.Second
+
+	// DefaultWebRTCHeartbeatTimeout is how long a WebRTC channel will go without a
+	// heartbeat from its remote peer before considering it dead.
+	DefaultWebRTCHeartbeatTimeout = 20 * time.Second
+
+	errPeerHeartbeatTimeout = errors.New("peer heartbeat timed out")
+)
+
+const (
+	heartbeatChannelLabel = "heartbeat"
+	heartbeatPing         = "ping"
+)
+
+// startHeartbeat opens a dedicated heartbeat data channel to the remote peer. This is
+// done by the dialing side and is accepted by the answering side via acceptHeartbeat.
+// A negative interval disables heartbeats.
+func (ch *webrtcBaseChannel) startHeartbeat(interval, timeout time.Duration) error {
+	if interval < 0 {
+		return nil
+	}
+	ordered := false
+	hbChannel, err := ch.peerConn.CreateDataChannel(heartbeatChannelLabel, &webrtc.DataChannelInit{
+		Ordered: &ordered,
+	})
+	if err != nil {
+		return err
+	}
+	ch.runHeartbeat(hbChannel, interval, timeout)
+	return nil
+}
+
+// acceptHeartbeat waits for the remote peer to open a heartbeat data channel and
+// participates in it once it does. A negative interval disables heartbeats.
+func (ch *webrtcBaseChannel) acceptHeartbeat(interval, timeout time.Duration) {
+	if interval < 0 {
+		return
+	}
+	ch.peerConn.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if dc.Label() != heartbeatChannelLabel {
+			return
+		}
+		ch.runHeartbeat(dc, interval, timeout)
+	})
+}
+
+// runHeartbeat periodically pings the remote peer over the given data channel and
+// closes this channel if the remote peer has not been heard from within the timeout.
+// Until the first heartbeat is received, the timeout counts from when the heartbeat
+// channel opened since a peer that opens it participates in heartbeats.
+func (ch *webrtcBaseChannel) runHeartbeat(hbChannel *webrtc.DataChannel, interval, timeout time.Duration) {
+	if interval == 0 {
+		interval = DefaultWebRTCHeartbeatInterval
+	}
+	if timeout == 0 {
+		timeout = DefaultWebRTCHeartbeatTimeout
+	}
+
+	hbChannel.OnMessage(func(msg webrtc.DataChannelMessage) {
+		ch.lastHeartbeat.Store(time.Now().UnixNano())
+	})
+	hbChannel.OnOpen(func() {
+		ch.mu.Lock()
+		if ch.closed {
+			ch.mu.Unlock()
+			return
+		}
+		ch.activeBackgroundWorkers.Add(1)
+		ch.mu.Unlock()
+		opened := time.Now()
+
+		utils.PanicCapturingGo(func() {
+			defer ch.activeBackgroundWorkers.Done()
+			defer func() {
+				utils.UncheckedError(hbChannel.Close())
+			}()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ch.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+
+				heardFrom := ch.LastHeartbeat()
+				if heardFrom.Before(opened) {
+					heardFrom = opened
+				}
+				if time.Since(heardFrom) > timeout {
+					ch.logger.Warnw(
+						"no heartbeat from peer; closing",
+						"last_heartbeat", ch.LastHeartbeat(),
+						"timeout", timeout.String(),
+					)
+					if err := ch.closeWithReason(errPeerHeartbeatTimeout); err != nil {
+						ch.logger.Errorw("error closing channel", "error", err)
+					}
+					return
+				}
+				if err := hbChannel.SendText(heartbeatPing); err != nil {
+					ch.logger.Debugw("error sending heartbeat", "error", err)
+				}
+			}
+		})
+	})
+}
+
+// LastHeartbeat returns when a heartbeat was last received from the remote peer. It
+// is the zero time if none has been received yet.
+func (ch *webrtcBaseChannel) LastHeartbeat() time.Time {
+	lastHeartbeat := ch.lastHeartbeat.Load()
+	if lastHeartbeat == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastHeartbeat)
+}
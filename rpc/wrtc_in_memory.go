@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/transport/v2"
+	"github.com/pion/transport/v2/vnet"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+)
+
+// An InMemoryWebRTCChannelPair is a client and server connected over the WebRTC channel
+// layer where the underlying network is entirely in memory. No real sockets are used
+// and ICE only ever sees the virtual network, which makes it suitable for deterministic
+// tests of code that uses WebRTC connections.
+type InMemoryWebRTCChannelPair struct {
+	client *webrtcClientChannel
+	server *webrtcServer
+	router *vnet.Router
+}
+
+// inMemoryWebRTCNetworkCIDR is the network that in memory channel pairs are placed on.
+const inMemoryWebRTCNetworkCIDR = "10.0.0.0/24"
+
+// InMemoryWebRTCOptions control the network of an in memory channel pair.
+type InMemoryWebRTCOptions struct {
+	// WrapNet, if set, wraps the virtual network of each peer, such as to simulate the
+	// conditions of a real one. Each peer's network carries what that peer sends.
+	WrapNet func(n transport.Net) transport.Net
+}
+
+// NewInMemoryWebRTCChannelPair returns a connected in memory client and server. Services
+// should be registered on the pair before making calls on its client.
+func NewInMemoryWebRTCChannelPair(ctx context.Context, logger golog.Logger) (*InMemoryWebRTCChannelPair, error) {
+	return NewInMemoryWebRTCChannelPairWithOptions(ctx, logger, InMemoryWebRTCOptions{})
+}
+
+// NewInMemoryWebRTCChannelPairWithOptions returns a connected in memory client and server
+// whose network is controlled by the given options.
+func NewInMemoryWebRTCChannelPairWithOptions(
+	ctx context.Context,
+	logger golog.Logger,
+	opts InMemoryWebRTCOptions,
+) (pair *InMemoryWebRTCChannelPair, err error) {
+	router, err := vnet.NewRouter(&vnet.RouterConfig{
+		CIDR:          inMemoryWebRTCNetworkCIDR,
+		LoggerFactory: WebRTCLoggerFactory{logger.Named("vnet")},
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientNet, err := vnet.NewNet(&vnet.NetConfig{StaticIPs: []string{"10.0.0.1"}})
+	if err != nil {
+		return nil, err
+	}
+	serverNet, err := vnet.NewNet(&vnet.NetConfig{StaticIPs: []string{"10.0.0.2"}})
+	if err != nil {
+		return nil, err
+	}
+	if err := router.AddNet(clientNet); err != nil {
+		return nil, err
+	}
+	if err := router.AddNet(serverNet); err != nil {
+		return nil, err
+	}
+	if err := router.Start(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Combine(err, router.Stop())
+		}
+	}()
+	var clientTransport, serverTransport transport.Net = clientNet, serverNet
+	if opts.WrapNet != nil {
+		clientTransport = opts.WrapNet(clientNet)
+		serverTransport = opts.WrapNet(serverNet)
+	}
+
+	clientPC, clientDC, clientNegotiator, err := newPeerConnectionForClient(
+		ctx,
+		webrtc.Configuration{},
+		true,
+		webrtcPeerOptions{net: clientTransport},
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Combine(err, clientPC.Close())
+		}
+	}()
+	encodedSDP, err := encodeSDP(clientPC.LocalDescription())
+	if err != nil {
+		return nil, err
+	}
+
+	serverPC, serverDC, serverNegotiator, err := newPeerConnectionForServer(
+		ctx,
+		encodedSDP,
+		webrtc.Configuration{},
+		true,
+		webrtcPeerOptions{net: serverTransport},
+		logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			err = multierr.Combine(err, serverPC.Close())
+		}
+	}()
+	if err := clientPC.SetRemoteDescription(*serverPC.LocalDescription()); err != nil {
+		return nil, err
+	}
+
+	server := newWebRTCServer(logger)
+	serverCh := server.NewChannel(serverPC, serverDC, nil)
+	serverCh.useNegotiator(serverNegotiator)
+	clientCh := newWebRTCClientChannel(clientPC, clientDC, logger, nil, nil)
+	clientCh.useNegotiator(clientNegotiator)
+
+	for _, ready := range []<-chan struct{}{clientCh.Ready(), serverCh.Ready()} {
+		select {
+		case <-ctx.Done():
+			server.Stop()
+			return nil, multierr.Combine(ctx.Err(), clientCh.Close())
+		case <-ready:
+		}
+	}
+
+	return &InMemoryWebRTCChannelPair{
+		client: clientCh,
+		server: server,
+		router: router,
+	}, nil
+}
+
+// Client returns the client end of the pair.
+func (p *InMemoryWebRTCChannelPair) Client() ClientConn {
+	return p.client
+}
+
+// RegisterService registers the given implementation of a service to be handled by the
+// server end of the pair.
+func (p *InMemoryWebRTCChannelPair) RegisterService(sd *grpc.ServiceDesc, ss interface{}) {
+	p.server.RegisterService(sd, ss)
+}
+
+// Close closes both ends of the pair and the network connecting them.
+func (p *InMemoryWebRTCChannelPair) Close() error {
+	err := p.client.Close()
+	p.server.Stop()
+	return multierr.Combine(err, p.router.Stop())
+}
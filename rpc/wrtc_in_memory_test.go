@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/transport/v2"
+	"go.viam.com/test"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	"go.viam.com/utils/rpc/examples/echo/echotest"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestInMemoryWebRTCChannelPair(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+
+	client := echopb.NewEchoServiceClient(pair.Client())
+	resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, "hello")
+
+	multiClient, err := client.EchoMultiple(ctx, &echopb.EchoMultipleRequest{Message: "howdy"})
+	test.That(t, err, test.ShouldBeNil)
+	var received string
+	for {
+		resp, err := multiClient.Recv()
+		if err != nil {
+			break
+		}
+		received += resp.GetMessage()
+	}
+	test.That(t, received, test.ShouldEqual, "howdy")
+}
+
+func TestInMemoryWebRTCChannelPairSimulatedNetwork(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	profile := testutils.NetworkProfile{
+		Latency:   10 * time.Millisecond,
+		Jitter:    5 * time.Millisecond,
+		Bandwidth: 1 << 20,
+		Loss:      0.05,
+		Reorder:   0.05,
+		Seed:      1,
+	}
+	pair, err := NewInMemoryWebRTCChannelPairWithOptions(ctx, logger, InMemoryWebRTCOptions{
+		WrapNet: func(n transport.Net) transport.Net {
+			return testutils.SimulateNet(n, profile)
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+	echotest.AssertEcho(t, pair.Client())
+
+	// large enough to be split across many lossy packets.
+	message := strings.Repeat("a", 1<<18)
+	resp, err := echopb.NewEchoServiceClient(pair.Client()).Echo(ctx, &echopb.EchoRequest{Message: message})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, message)
+}
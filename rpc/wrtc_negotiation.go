@@ -0,0 +1,342 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils"
+)
+
+// A PeerCloseReason describes why a peer connection was closed by the peer on the
+// other end of it. It is compatible with the status package so that in-flight calls
+// failed by it carry its code.
+type PeerCloseReason struct {
+	Code    codes.Code
+	Message string
+}
+
+// Error returns a human readable form of the reason.
+func (r *PeerCloseReason) Error() string {
+	return fmt.Sprintf("peer connection closed by remote: %s (code=%s)", r.Message, r.Code)
+}
+
+// GRPCStatus returns the reason as a gRPC status.
+func (r *PeerCloseReason) GRPCStatus() *status.Status {
+	return status.New(r.Code, r.Message)
+}
+
+const (
+	negotiationMessageTypeClose        = "close"
+	negotiationMessageTypeWindowUpdate = "window_update"
+)
+
+// negotiationControlMessage is a message sent over the negotiation channel that is
+// not a session description. Its type field is distinct from all SDP types.
+type negotiationControlMessage struct {
+	Type      string     `json:"type"`
+	Code      codes.Code `json:"code,omitempty"`
+	Message   string     `json:"message,omitempty"`
+	Stream    uint64     `json:"stream,omitempty"`
+	Increment int        `json:"increment,omitempty"`
+}
+
+// closeReasonFlushTimeout is how long to wait for a close reason to be written out
+// before the peer connection is torn down.
+const closeReasonFlushTimeout = 250 * time.Millisecond
+
+// negotiationNeededDebounce is how long to wait for more changes to the peer connection
+// that need negotiating, such as tracks being added one after the other, before making
+// a single offer for all of them.
+const negotiationNeededDebounce = 20 * time.Millisecond
+
+// A webrtcNegotiator services the negotiated "negotiation" data channel of a peer
+// connection. It performs renegotiation with the remote peer and carries control
+// messages such as why a peer connection is being closed.
+type webrtcNegotiator struct {
+	peerConn *webrtc.PeerConnection
+	dc       *webrtc.DataChannel
+	polite   bool
+	peerOpts webrtcPeerOptions
+	logger   golog.Logger
+
+	negotiationNeeded *utils.CoalescedFunc
+
+	mu             sync.Mutex
+	open           bool
+	makingOffer    bool
+	onCloseReason  func(reason *PeerCloseReason)
+	onWindowUpdate func(streamID uint64, increment int)
+}
+
+// newWebRTCNegotiator creates the negotiation data channel on the given peer connection.
+// Exactly one side of the connection should be polite.
+func newWebRTCNegotiator(
+	peerConn *webrtc.PeerConnection,
+	polite bool,
+	peerOpts webrtcPeerOptions,
+	logger golog.Logger,
+) (*webrtcNegotiator, error) {
+	n := &webrtcNegotiator{
+		peerConn: peerConn,
+		polite:   polite,
+		peerOpts: peerOpts,
+		logger:   logger,
+	}
+	n.negotiationNeeded = utils.Debounce(negotiationNeededDebounce, n.onNegotiationNeeded)
+	peerConn.OnNegotiationNeeded(n.negotiationNeeded.Call)
+
+	negotiated := true
+	ordered := true
+	negotiationChannelID := uint16(1)
+	negotiationChannel, err := peerConn.CreateDataChannel("negotiation", &webrtc.DataChannelInit{
+		ID:         &negotiationChannelID,
+		Negotiated: &negotiated,
+		Ordered:    &ordered,
+	})
+	if err != nil {
+		return nil, err
+	}
+	n.mu.Lock()
+	n.dc = negotiationChannel
+	n.mu.Unlock()
+	negotiationChannel.OnError(initialDataChannelOnError(peerConn, logger))
+	negotiationChannel.OnOpen(func() {
+		n.mu.Lock()
+		n.open = true
+		n.mu.Unlock()
+	})
+	negotiationChannel.OnMessage(n.onMessage)
+	return n, nil
+}
+
+// OnCloseReason sets the function to call when the remote peer says why it is
+// closing the connection.
+func (n *webrtcNegotiator) OnCloseReason(f func(reason *PeerCloseReason)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onCloseReason = f
+}
+
+// OnWindowUpdate sets the function to call when the remote peer acknowledges bytes
+// received on a flow controlled stream.
+func (n *webrtcNegotiator) OnWindowUpdate(f func(streamID uint64, increment int)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onWindowUpdate = f
+}
+
+// sendWindowUpdate acknowledges to the remote peer that increment bytes were consumed
+// on the given stream.
+func (n *webrtcNegotiator) sendWindowUpdate(streamID uint64, increment int) error {
+	n.mu.Lock()
+	isOpen := n.open
+	n.mu.Unlock()
+	if !isOpen {
+		return errNegotiationChannelNotOpen
+	}
+	md, err := json.Marshal(negotiationControlMessage{
+		Type:      negotiationMessageTypeWindowUpdate,
+		Stream:    streamID,
+		Increment: increment,
+	})
+	if err != nil {
+		return err
+	}
+	return n.dc.SendText(base64.StdEncoding.EncodeToString(md))
+}
+
+// sendCloseReason tells the remote peer why the connection is about to be closed. It
+// waits a short while for the message to be written out so that closing the peer
+// connection immediately afterwards does not drop it.
+func (n *webrtcNegotiator) sendCloseReason(reason *PeerCloseReason) error {
+	n.mu.Lock()
+	isOpen := n.open
+	n.mu.Unlock()
+	if !isOpen {
+		return nil
+	}
+	md, err := json.Marshal(negotiationControlMessage{
+		Type:    negotiationMessageTypeClose,
+		Code:    reason.Code,
+		Message: reason.Message,
+	})
+	if err != nil {
+		return err
+	}
+	if err := n.dc.SendText(base64.StdEncoding.EncodeToString(md)); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(closeReasonFlushTimeout)
+	for n.dc.BufferedAmount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// close stops the negotiator from making offers for changes still waiting to be
+// negotiated since the peer connection is going away.
+func (n *webrtcNegotiator) close() {
+	n.negotiationNeeded.Stop()
+}
+
+func (n *webrtcNegotiator) onNegotiationNeeded() {
+	if err := n.makeOffer(); err != nil && !errors.Is(err, errNegotiationChannelNotOpen) {
+		n.logger.Errorw("renegotiation: error making offer", "error", err)
+	}
+}
+
+var errNegotiationChannelNotOpen = errors.New("negotiation channel not open")
+
+// makeOffer creates a new offer, applies it locally, and sends it to the remote peer.
+func (n *webrtcNegotiator) makeOffer() error {
+	n.mu.Lock()
+	if !n.open {
+		n.mu.Unlock()
+		return errNegotiationChannelNotOpen
+	}
+	n.makingOffer = true
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		n.makingOffer = false
+		n.mu.Unlock()
+	}()
+	offer, err := n.peerConn.CreateOffer(nil)
+	if err != nil {
+		return errors.Wrap(err, "error creating offer")
+	}
+	if err := n.peerOpts.setLocalDescription(n.peerConn, offer); err != nil {
+		return errors.Wrap(err, "error setting local description")
+	}
+	encodedSDP, err := encodeSDP(n.peerConn.LocalDescription())
+	if err != nil {
+		return errors.Wrap(err, "error encoding SDP")
+	}
+	if err := n.dc.SendText(encodedSDP); err != nil {
+		return errors.Wrap(err, "error sending SDP")
+	}
+	return nil
+}
+
+// renegotiationPollInterval is how often the signaling state is checked while waiting
+// for a renegotiation to complete.
+const renegotiationPollInterval = 10 * time.Millisecond
+
+// renegotiate sends a new offer to the remote peer and waits until its answer has been
+// applied and the signaling state is stable again.
+func (n *webrtcNegotiator) renegotiate(ctx context.Context) (err error) {
+	_, span := trace.StartSpan(ctx, "rpc.webrtc.renegotiate")
+	defer func() {
+		endSpan(span, err)
+	}()
+	if err := n.makeOffer(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(renegotiationPollInterval)
+	defer ticker.Stop()
+	for {
+		if n.peerConn.SignalingState() == webrtc.SignalingStateStable {
+			return nil
+		}
+		if n.peerConn.ConnectionState() == webrtc.PeerConnectionStateClosed {
+			return errors.New("peer connection closed during renegotiation")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *webrtcNegotiator) onMessage(msg webrtc.DataChannelMessage) {
+	decoded, err := base64.StdEncoding.DecodeString(string(msg.Data))
+	if err != nil {
+		n.logger.Errorw("negotiation: error decoding message", "error", err)
+		return
+	}
+	var controlMsg negotiationControlMessage
+	if err := json.Unmarshal(decoded, &controlMsg); err != nil {
+		n.logger.Errorw("negotiation: error decoding message", "error", err)
+		return
+	}
+	if controlMsg.Type == negotiationMessageTypeWindowUpdate {
+		n.mu.Lock()
+		onWindowUpdate := n.onWindowUpdate
+		n.mu.Unlock()
+		if onWindowUpdate != nil {
+			onWindowUpdate(controlMsg.Stream, controlMsg.Increment)
+		}
+		return
+	}
+	if controlMsg.Type == negotiationMessageTypeClose {
+		n.mu.Lock()
+		onCloseReason := n.onCloseReason
+		n.mu.Unlock()
+		reason := &PeerCloseReason{Code: controlMsg.Code, Message: controlMsg.Message}
+		n.logger.Debugw("remote peer is closing connection", "reason", reason)
+		if onCloseReason != nil {
+			onCloseReason(reason)
+		}
+		return
+	}
+	n.onDescription(decoded)
+}
+
+func (n *webrtcNegotiator) onDescription(encodedDescription []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	description := webrtc.SessionDescription{}
+	if err := json.Unmarshal(encodedDescription, &description); err != nil {
+		n.logger.Errorw("renegotiation: error decoding SDP", "error", err)
+		return
+	}
+	offerCollision := (description.Type == webrtc.SDPTypeOffer) &&
+		(n.makingOffer || n.peerConn.SignalingState() != webrtc.SignalingStateStable)
+	ignoreOffer := !n.polite && offerCollision
+	if ignoreOffer {
+		n.logger.Debugw("ignoring offer", "polite", n.polite, "offer_collision", offerCollision)
+	}
+
+	if err := n.peerOpts.setRemoteDescription(n.peerConn, description); err != nil {
+		n.logger.Errorw("renegotiation: error setting remote description", "error", err)
+		return
+	}
+	if description.Type == webrtc.SDPTypeAnswer {
+		n.peerOpts.events.renegotiated()
+	}
+
+	if description.Type == webrtc.SDPTypeOffer {
+		answer, err := n.peerConn.CreateAnswer(nil)
+		if err != nil {
+			n.logger.Errorw("renegotiation: error creating answer", "error", err)
+			return
+		}
+		if err := n.peerOpts.setLocalDescription(n.peerConn, answer); err != nil {
+			n.logger.Errorw("renegotiation: error setting local description", "error", err)
+			return
+		}
+		encodedSDP, err := encodeSDP(n.peerConn.LocalDescription())
+		if err != nil {
+			n.logger.Errorw("renegotiation: error encoding SDP", "error", err)
+			return
+		}
+		if err := n.dc.SendText(encodedSDP); err != nil {
+			n.logger.Errorw("renegotiation: error sending SDP", "error", err)
+			return
+		}
+		n.peerOpts.events.renegotiated()
+	}
+}
@@ -5,13 +5,13 @@ import (
 	"errors"
 	"io"
 	"net"
-	"sync"
 	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/ice/v2"
 	"github.com/pion/interceptor"
 	"github.com/pion/sctp"
+	"github.com/pion/transport/v2"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/multierr"
 
@@ -33,7 +33,130 @@ var DefaultWebRTCConfiguration = webrtc.Configuration{
 	ICEServers: DefaultICEServers,
 }
 
-func newWebRTCAPI(isClient bool, logger golog.Logger) (*webrtc.API, error) {
+// webrtcPeerOptions control how the WebRTC API backing a peer connection is set up.
+type webrtcPeerOptions struct {
+	// interfaceFilter, if set, is consulted for which network interfaces may be
+	// used to gather ICE candidates.
+	interfaceFilter func(name string) bool
+
+	// ipFilter, if set, is consulted for which IPs may be used to gather ICE
+	// candidates. It is applied in addition to the built-in IPv4 only filter.
+	ipFilter func(ip net.IP) bool
+
+	// onLocalDescription, if set, may modify a local session description before
+	// it is applied.
+	onLocalDescription SDPHook
+
+	// onRemoteDescription, if set, may modify a remote session description before
+	// it is applied.
+	onRemoteDescription SDPHook
+
+	// events, if set, receives the lifecycle events of the peer connection.
+	events *webrtcPeerEvents
+
+	// net, if set, is the network used for ICE instead of the host's. mDNS is not
+	// used in this case.
+	net transport.Net
+
+	// iceTimeouts tune how quickly ICE gives up on or keeps alive candidate pairs.
+	iceTimeouts ICETimeouts
+
+	// proxy, if set, is used to reach TURN servers over TCP or TLS.
+	proxy proxyFunc
+
+	// gatherTimeout, if set, bounds how long a client waits on gathering candidates
+	// before offering those gathered so far.
+	gatherTimeout time.Duration
+}
+
+// gatherTimeoutC returns a channel that receives once gatherTimeout has passed, or never
+// if it is not set, and a function to stop it with.
+func (opts webrtcPeerOptions) gatherTimeoutC() (<-chan time.Time, func()) {
+	if opts.gatherTimeout <= 0 {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(opts.gatherTimeout)
+	return timer.C, func() { timer.Stop() }
+}
+
+// Defaults for ICETimeouts.
+const (
+	DefaultICEDisconnectedTimeout    = 5 * time.Second
+	DefaultICEFailedTimeout          = 25 * time.Second
+	DefaultICEKeepaliveInterval      = 2 * time.Second
+	DefaultICERelayAcceptanceMinWait = 3 * time.Second
+)
+
+// ICETimeouts tune ICE connection establishment and liveness for networks where the
+// defaults are a poor fit, such as very lossy or high latency links. Zero fields use
+// their defaults.
+type ICETimeouts struct {
+	// Disconnected is how long without network activity before a peer connection is
+	// considered disconnected.
+	Disconnected time.Duration
+
+	// Failed is how long a peer connection may stay disconnected before it is
+	// considered failed.
+	Failed time.Duration
+
+	// Keepalive is how often to send traffic on an otherwise idle connection.
+	Keepalive time.Duration
+
+	// RelayAcceptanceMinWait is how long to wait for a better candidate pair before
+	// accepting one that goes through a relay (TURN).
+	RelayAcceptanceMinWait time.Duration
+}
+
+// withDefaults returns the timeouts with all zero fields replaced by their defaults.
+func (t ICETimeouts) withDefaults() ICETimeouts {
+	if t.Disconnected == 0 {
+		t.Disconnected = DefaultICEDisconnectedTimeout
+	}
+	if t.Failed == 0 {
+		t.Failed = DefaultICEFailedTimeout
+	}
+	if t.Keepalive == 0 {
+		t.Keepalive = DefaultICEKeepaliveInterval
+	}
+	if t.RelayAcceptanceMinWait == 0 {
+		t.RelayAcceptanceMinWait = DefaultICERelayAcceptanceMinWait
+	}
+	return t
+}
+
+// An SDPHook is given a session description right before it is applied to a peer
+// connection and returns the session description to apply in its place. This can be
+// used to munge the SDP (e.g. to force codecs, set bandwidth lines, or strip
+// extensions). Returning an error aborts applying the description.
+type SDPHook func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error)
+
+// setLocalDescription sets the local description of the peer connection after
+// passing it through the local description hook, if any.
+func (opts webrtcPeerOptions) setLocalDescription(peerConn *webrtc.PeerConnection, desc webrtc.SessionDescription) error {
+	if opts.onLocalDescription != nil {
+		var err error
+		desc, err = opts.onLocalDescription(desc)
+		if err != nil {
+			return err
+		}
+	}
+	return peerConn.SetLocalDescription(desc)
+}
+
+// setRemoteDescription sets the remote description of the peer connection after
+// passing it through the remote description hook, if any.
+func (opts webrtcPeerOptions) setRemoteDescription(peerConn *webrtc.PeerConnection, desc webrtc.SessionDescription) error {
+	if opts.onRemoteDescription != nil {
+		var err error
+		desc, err = opts.onRemoteDescription(desc)
+		if err != nil {
+			return err
+		}
+	}
+	return peerConn.SetRemoteDescription(desc)
+}
+
+func newWebRTCAPI(isClient bool, peerOpts webrtcPeerOptions, logger golog.Logger) (*webrtc.API, error) {
 	m := webrtc.MediaEngine{}
 	if err := m.RegisterDefaultCodecs(); err != nil {
 		return nil, err
@@ -53,18 +176,31 @@ func newWebRTCAPI(isClient bool, logger golog.Logger) (*webrtc.API, error) {
 	// server/client (controlled/controlling) can include 127.0.0.1 as a candidate
 	// while the client (controlling) provides an mDNS candidate that may resolve to 127.0.0.1.
 	settingEngine.SetIncludeLoopbackCandidate(true)
-	settingEngine.SetRelayAcceptanceMinWait(3 * time.Second)
+	iceTimeouts := peerOpts.iceTimeouts.withDefaults()
+	settingEngine.SetRelayAcceptanceMinWait(iceTimeouts.RelayAcceptanceMinWait)
+	settingEngine.SetICETimeouts(iceTimeouts.Disconnected, iceTimeouts.Failed, iceTimeouts.Keepalive)
 	settingEngine.SetIPFilter(func(ip net.IP) bool {
 		// Disallow ipv6 addresses since grpc-go does not currently support IPv6 scoped literals.
 		// See related grpc-go issue: https://github.com/grpc/grpc-go/issues/3272.
 		//
 		// Stolen from net/ip.go, `IP.String` method.
-		if p4 := ip.To4(); len(p4) == net.IPv4len {
-			return true
+		if p4 := ip.To4(); len(p4) != net.IPv4len {
+			return false
 		}
 
-		return false
+		return peerOpts.ipFilter == nil || peerOpts.ipFilter(ip)
 	})
+	if peerOpts.interfaceFilter != nil {
+		settingEngine.SetInterfaceFilter(peerOpts.interfaceFilter)
+	}
+	if peerOpts.proxy != nil {
+		settingEngine.SetICEProxyDialer(peerOpts.proxy)
+	}
+	if peerOpts.net != nil {
+		settingEngine.SetNet(peerOpts.net)
+		settingEngine.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
+		settingEngine.SetIncludeLoopbackCandidate(false)
+	}
 
 	options := []func(a *webrtc.API){webrtc.WithMediaEngine(&m), webrtc.WithInterceptorRegistry(&i)}
 	if utils.Debug {
@@ -78,17 +214,19 @@ func newPeerConnectionForClient(
 	ctx context.Context,
 	config webrtc.Configuration,
 	disableTrickle bool,
+	peerOpts webrtcPeerOptions,
 	logger golog.Logger,
-) (*webrtc.PeerConnection, *webrtc.DataChannel, error) {
-	webAPI, err := newWebRTCAPI(true, logger)
+) (*webrtc.PeerConnection, *webrtc.DataChannel, *webrtcNegotiator, error) {
+	webAPI, err := newWebRTCAPI(true, peerOpts, logger)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	peerConn, err := webAPI.NewPeerConnection(config)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
+	peerOpts.events.bind(peerConn)
 	var successful bool
 	defer func() {
 		if !successful {
@@ -105,20 +243,25 @@ func newPeerConnectionForClient(
 		Ordered:    &ordered,
 	})
 	if err != nil {
-		return peerConn, nil, err
+		return peerConn, nil, nil, err
 	}
 	dataChannel.OnError(initialDataChannelOnError(peerConn, logger))
 
+	negotiator, err := newWebRTCNegotiator(peerConn, true, peerOpts, logger)
+	if err != nil {
+		return peerConn, nil, nil, err
+	}
+
 	if disableTrickle {
 		offer, err := peerConn.CreateOffer(nil)
 		if err != nil {
-			return peerConn, nil, err
+			return peerConn, nil, nil, err
 		}
 
 		// Sets the LocalDescription, and starts our UDP listeners
-		err = peerConn.SetLocalDescription(offer)
+		err = peerOpts.setLocalDescription(peerConn, offer)
 		if err != nil {
-			return peerConn, nil, err
+			return peerConn, nil, nil, err
 		}
 
 		// Create channel that is blocked until ICE Gathering is complete
@@ -126,17 +269,21 @@ func newPeerConnectionForClient(
 
 		// Block until ICE Gathering is complete since we signal back one complete SDP
 		// and do not want to wait on trickle ICE.
+		gatherTimeout, stopGatherTimeout := peerOpts.gatherTimeoutC()
+		defer stopGatherTimeout()
 		select {
 		case <-ctx.Done():
-			return peerConn, nil, ctx.Err()
+			return peerConn, nil, nil, ctx.Err()
 		case <-gatherComplete:
+		case <-gatherTimeout:
+			// the local description has every candidate gathered so far.
 		}
 	}
 
 	// Will not wait for connection to establish. If you want this in the future,
 	// add a state check to OnICEConnectionStateChange for webrtc.ICEConnectionStateConnected.
 	successful = true
-	return peerConn, dataChannel, nil
+	return peerConn, dataChannel, negotiator, nil
 }
 
 func newPeerConnectionForServer(
@@ -144,17 +291,19 @@ func newPeerConnectionForServer(
 	sdp string,
 	config webrtc.Configuration,
 	disableTrickle bool,
+	peerOpts webrtcPeerOptions,
 	logger golog.Logger,
-) (*webrtc.PeerConnection, *webrtc.DataChannel, error) {
-	webAPI, err := newWebRTCAPI(false, logger)
+) (*webrtc.PeerConnection, *webrtc.DataChannel, *webrtcNegotiator, error) {
+	webAPI, err := newWebRTCAPI(false, peerOpts, logger)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	peerConn, err := webAPI.NewPeerConnection(config)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
+	peerOpts.events.bind(peerConn)
 	var successful bool
 	defer func() {
 		if !successful {
@@ -162,41 +311,6 @@ func newPeerConnectionForServer(
 		}
 	}()
 
-	var negOpen bool
-	var negMu sync.Mutex
-	var negotiationChannel *webrtc.DataChannel
-	var makingOffer bool
-	peerConn.OnNegotiationNeeded(func() {
-		negMu.Lock()
-		if !negOpen {
-			negMu.Unlock()
-			return
-		}
-		negMu.Unlock()
-		makingOffer = true
-		defer func() {
-			makingOffer = false
-		}()
-		offer, err := peerConn.CreateOffer(nil)
-		if err != nil {
-			logger.Errorw("renegotiation: error creating offer", "error", err)
-			return
-		}
-		if err := peerConn.SetLocalDescription(offer); err != nil {
-			logger.Errorw("renegotiation: error setting local description", "error", err)
-			return
-		}
-		encodedSDP, err := encodeSDP(peerConn.LocalDescription())
-		if err != nil {
-			logger.Errorw("renegotiation: error encoding SDP", "error", err)
-			return
-		}
-		if err := negotiationChannel.SendText(encodedSDP); err != nil {
-			logger.Errorw("renegotiation: error sending SDP", "error", err)
-			return
-		}
-	})
-
 	negotiated := true
 	ordered := true
 	dataChannelID := uint16(0)
@@ -206,91 +320,34 @@ func newPeerConnectionForServer(
 		Ordered:    &ordered,
 	})
 	if err != nil {
-		return peerConn, dataChannel, err
+		return peerConn, dataChannel, nil, err
 	}
 	dataChannel.OnError(initialDataChannelOnError(peerConn, logger))
 
-	negotiationChannelID := uint16(1)
-	negotiationChannel, err = peerConn.CreateDataChannel("negotiation", &webrtc.DataChannelInit{
-		ID:         &negotiationChannelID,
-		Negotiated: &negotiated,
-		Ordered:    &ordered,
-	})
+	negotiator, err := newWebRTCNegotiator(peerConn, false, peerOpts, logger)
 	if err != nil {
-		return peerConn, dataChannel, err
+		return peerConn, dataChannel, nil, err
 	}
-	negotiationChannel.OnError(initialDataChannelOnError(peerConn, logger))
-
-	negotiationChannel.OnOpen(func() {
-		negMu.Lock()
-		negOpen = true
-		negMu.Unlock()
-	})
-
-	const polite = false
-	var ignoreOffer bool
-	negotiationChannel.OnMessage(func(msg webrtc.DataChannelMessage) {
-		negMu.Lock()
-		defer negMu.Unlock()
-
-		description := webrtc.SessionDescription{}
-		if err := decodeSDP(string(msg.Data), &description); err != nil {
-			logger.Errorw("renegotiation: error decoding SDP", "error", err)
-			return
-		}
-		offerCollision := (description.Type == webrtc.SDPTypeOffer) &&
-			(makingOffer || peerConn.SignalingState() != webrtc.SignalingStateStable)
-		ignoreOffer = !polite && offerCollision
-		if ignoreOffer {
-			logger.Debugw("ignoring offer", "polite", polite, "offer_collision", offerCollision)
-		}
-
-		if err := peerConn.SetRemoteDescription(description); err != nil {
-			logger.Errorw("renegotiation: error setting remote description", "error", err)
-			return
-		}
-
-		if description.Type == webrtc.SDPTypeOffer {
-			answer, err := peerConn.CreateAnswer(nil)
-			if err != nil {
-				logger.Errorw("renegotiation: error creating answer", "error", err)
-				return
-			}
-			if err := peerConn.SetLocalDescription(answer); err != nil {
-				logger.Errorw("renegotiation: error setting local description", "error", err)
-				return
-			}
-			encodedSDP, err := encodeSDP(peerConn.LocalDescription())
-			if err != nil {
-				logger.Errorw("renegotiation: error encoding SDP", "error", err)
-				return
-			}
-			if err := negotiationChannel.SendText(encodedSDP); err != nil {
-				logger.Errorw("renegotiation: error sending SDP", "error", err)
-				return
-			}
-		}
-	})
 
 	offer := webrtc.SessionDescription{}
 	if err := decodeSDP(sdp, &offer); err != nil {
-		return peerConn, dataChannel, err
+		return peerConn, dataChannel, nil, err
 	}
 
-	err = peerConn.SetRemoteDescription(offer)
+	err = peerOpts.setRemoteDescription(peerConn, offer)
 	if err != nil {
-		return peerConn, dataChannel, err
+		return peerConn, dataChannel, nil, err
 	}
 
 	if disableTrickle {
 		answer, err := peerConn.CreateAnswer(nil)
 		if err != nil {
-			return peerConn, dataChannel, err
+			return peerConn, dataChannel, nil, err
 		}
 
-		err = peerConn.SetLocalDescription(answer)
+		err = peerOpts.setLocalDescription(peerConn, answer)
 		if err != nil {
-			return peerConn, dataChannel, err
+			return peerConn, dataChannel, nil, err
 		}
 
 		// Create channel that is blocked until ICE Gathering is complete
@@ -298,20 +355,25 @@ func newPeerConnectionForServer(
 
 		// Block until ICE Gathering is complete since we signal back one complete SDP
 		// and do not want to wait on trickle ICE.
+		gatherTimeout, stopGatherTimeout := peerOpts.gatherTimeoutC()
+		defer stopGatherTimeout()
 		select {
 		case <-ctx.Done():
-			return peerConn, nil, ctx.Err()
+			return peerConn, nil, nil, ctx.Err()
 		case <-gatherComplete:
+		case <-gatherTimeout:
+			// the local description has every candidate gathered so far.
 		}
 	}
 
 	successful = true
-	return peerConn, dataChannel, nil
+	return peerConn, dataChannel, negotiator, nil
 }
 
 type webrtcPeerConnectionStats struct {
 	ID               string
 	RemoteCandidates map[string]string
+	LastHeartbeat    time.Time
 }
 
 func webrtcPeerConnCandPair(peerConnection *webrtc.PeerConnection) (*webrtc.ICECandidatePair, bool) {
@@ -359,7 +421,7 @@ func getWebRTCPeerConnectionStats(peerConnection *webrtc.PeerConnection) webrtcP
 		}
 		connInfo[candidateType] = candidateStats.IP
 	}
-	return webrtcPeerConnectionStats{connID, connInfo}
+	return webrtcPeerConnectionStats{ID: connID, RemoteCandidates: connInfo}
 }
 
 func initialDataChannelOnError(pc io.Closer, logger golog.Logger) func(err error) {
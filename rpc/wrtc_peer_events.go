@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+)
+
+// A PeerEventType is the kind of lifecycle event a peer connection went through.
+type PeerEventType int
+
+// The known set of peer event types.
+const (
+	PeerEventGatheringStarted PeerEventType = iota
+	PeerEventCandidateAdded
+	PeerEventConnected
+	PeerEventRenegotiated
+	PeerEventDisconnected
+	PeerEventClosed
+)
+
+// String returns a human readable form of the event type.
+func (t PeerEventType) String() string {
+	switch t {
+	case PeerEventGatheringStarted:
+		return "gathering_started"
+	case PeerEventCandidateAdded:
+		return "candidate_added"
+	case PeerEventConnected:
+		return "connected"
+	case PeerEventRenegotiated:
+		return "renegotiated"
+	case PeerEventDisconnected:
+		return "disconnected"
+	case PeerEventClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// A PeerEvent describes a single lifecycle event of a peer connection.
+type PeerEvent struct {
+	Type PeerEventType
+	Time time.Time
+
+	// PeerID uniquely identifies the peer connection within this process.
+	PeerID   string
+	PeerConn *webrtc.PeerConnection
+
+	// Candidate is set for PeerEventCandidateAdded and Remote indicates whether
+	// it came from the remote peer or was gathered locally.
+	Candidate *webrtc.ICECandidateInit
+	Remote    bool
+}
+
+// webrtcPeerEvents emits the lifecycle events of a single peer connection. A nil
+// *webrtcPeerEvents emits nothing.
+type webrtcPeerEvents struct {
+	peerID   string
+	peerConn *webrtc.PeerConnection
+	onEvent  func(event PeerEvent)
+}
+
+// newWebRTCPeerEvents returns events for a new peer connection that are delivered to
+// the given function. It returns nil if there is no function to deliver to.
+func newWebRTCPeerEvents(onEvent func(event PeerEvent)) *webrtcPeerEvents {
+	if onEvent == nil {
+		return nil
+	}
+	return &webrtcPeerEvents{
+		peerID:  uuid.NewString(),
+		onEvent: onEvent,
+	}
+}
+
+// bind associates the events with the given peer connection and starts emitting
+// its gathering and connection state changes.
+func (e *webrtcPeerEvents) bind(peerConn *webrtc.PeerConnection) {
+	if e == nil {
+		return
+	}
+	e.peerConn = peerConn
+	peerConn.OnICEGatheringStateChange(func(state webrtc.ICEGathererState) {
+		if state == webrtc.ICEGathererStateGathering {
+			e.emit(PeerEvent{Type: PeerEventGatheringStarted})
+		}
+	})
+	peerConn.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			e.emit(PeerEvent{Type: PeerEventConnected})
+		case webrtc.PeerConnectionStateDisconnected, webrtc.PeerConnectionStateFailed:
+			e.emit(PeerEvent{Type: PeerEventDisconnected})
+		case webrtc.PeerConnectionStateClosed:
+			e.emit(PeerEvent{Type: PeerEventClosed})
+		case webrtc.PeerConnectionStateNew, webrtc.PeerConnectionStateConnecting:
+		}
+	})
+}
+
+// candidateAdded emits that a local or remote ICE candidate was added.
+func (e *webrtcPeerEvents) candidateAdded(cand webrtc.ICECandidateInit, remote bool) {
+	if e == nil {
+		return
+	}
+	e.emit(PeerEvent{Type: PeerEventCandidateAdded, Candidate: &cand, Remote: remote})
+}
+
+// renegotiated emits that the session was renegotiated with the remote peer.
+func (e *webrtcPeerEvents) renegotiated() {
+	if e == nil {
+		return
+	}
+	e.emit(PeerEvent{Type: PeerEventRenegotiated})
+}
+
+func (e *webrtcPeerEvents) emit(event PeerEvent) {
+	event.Time = time.Now()
+	event.PeerID = e.peerID
+	event.PeerConn = e.peerConn
+	e.onEvent(event)
+}
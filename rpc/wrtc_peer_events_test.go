@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/testutils"
+)
+
+type peerEventRecorder struct {
+	mu     sync.Mutex
+	events []PeerEvent
+}
+
+func (r *peerEventRecorder) record(event PeerEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *peerEventRecorder) types() []PeerEventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]PeerEventType, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestWebRTCPeerEvents(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	var clientEvents, serverEvents peerEventRecorder
+	clientOpts := webrtcPeerOptions{events: newWebRTCPeerEvents(clientEvents.record)}
+	serverOpts := webrtcPeerOptions{events: newWebRTCPeerEvents(serverEvents.record)}
+
+	pc1, _, _, err := newPeerConnectionForClient(context.Background(), webrtc.Configuration{}, true, clientOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	encodedSDP, err := encodeSDP(pc1.LocalDescription())
+	test.That(t, err, test.ShouldBeNil)
+	pc2, _, _, err := newPeerConnectionForServer(context.Background(), encodedSDP, webrtc.Configuration{}, true, serverOpts, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc1.SetRemoteDescription(*pc2.LocalDescription()), test.ShouldBeNil)
+
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, clientEvents.types(), test.ShouldContain, PeerEventConnected)
+		test.That(tb, serverEvents.types(), test.ShouldContain, PeerEventConnected)
+	})
+	test.That(t, clientEvents.types()[0], test.ShouldEqual, PeerEventGatheringStarted)
+	test.That(t, serverEvents.types()[0], test.ShouldEqual, PeerEventGatheringStarted)
+
+	test.That(t, pc1.Close(), test.ShouldBeNil)
+	test.That(t, pc2.Close(), test.ShouldBeNil)
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		test.That(tb, clientEvents.types(), test.ShouldContain, PeerEventClosed)
+	})
+
+	clientEvents.mu.Lock()
+	defer clientEvents.mu.Unlock()
+	for _, event := range clientEvents.events {
+		test.That(t, event.PeerID, test.ShouldEqual, clientEvents.events[0].PeerID)
+		test.That(t, event.PeerConn, test.ShouldEqual, pc1)
+		test.That(t, event.Time.IsZero(), test.ShouldBeFalse)
+	}
+	test.That(t, PeerEventRenegotiated.String(), test.ShouldEqual, "renegotiated")
+}
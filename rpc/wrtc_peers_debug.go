@@ -0,0 +1,293 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"google.golang.org/grpc/codes"
+)
+
+// PeersDebugPath is the HTTP path at which a server with
+// WebRTCServerOptions.EnablePeersDebugEndpoint lists its peer connections as JSON, or as
+// a page for browsers. The session descriptions of a peer are served at
+// PeersDebugPath/sdp?id=<id> and a peer is closed by POSTing to PeersDebugPath/close?id=<id>.
+const PeersDebugPath = DebugPathPrefix + "/peers"
+
+const (
+	peersDebugSDPPath   = PeersDebugPath + "/sdp"
+	peersDebugClosePath = PeersDebugPath + "/close"
+)
+
+var errPeerClosedForDebug = &PeerCloseReason{Code: codes.Aborted, Message: "closed from the debug endpoint"}
+
+// A PeerDebugInfo describes a peer connection held by a server. Durations are in
+// nanoseconds.
+type PeerDebugInfo struct {
+	// ID is the same as the peer connection ID of audit records.
+	ID string `json:"id"`
+	// Host is set for peers of a host with its own services.
+	Host            string        `json:"host,omitempty"`
+	Entity          string        `json:"entity,omitempty"`
+	ConnectionState string        `json:"connection_state"`
+	Created         time.Time     `json:"created"`
+	Uptime          time.Duration `json:"uptime"`
+	LastActivity    time.Time     `json:"last_activity"`
+	LastHeartbeat   time.Time     `json:"last_heartbeat"`
+	Streams         int           `json:"streams"`
+	// BufferedAmount is how many bytes are queued on the data channel to be sent.
+	BufferedAmount        uint64                   `json:"buffered_amount"`
+	SelectedCandidatePair string                   `json:"selected_candidate_pair,omitempty"`
+	CandidatePairs        []CandidatePairDebugInfo `json:"candidate_pairs"`
+}
+
+// A CandidatePairDebugInfo describes an ICE candidate pair of a peer connection.
+type CandidatePairDebugInfo struct {
+	Local         string        `json:"local"`
+	Remote        string        `json:"remote"`
+	State         string        `json:"state"`
+	Nominated     bool          `json:"nominated"`
+	BytesSent     uint64        `json:"bytes_sent"`
+	BytesReceived uint64        `json:"bytes_received"`
+	RoundTripTime time.Duration `json:"round_trip_time"`
+}
+
+// peerDebugInfo describes the peer connection of the given channel.
+func peerDebugInfo(peerConn *webrtc.PeerConnection, ch *webrtcServerChannel) PeerDebugInfo {
+	info := PeerDebugInfo{
+		Entity:          ch.entity(),
+		ConnectionState: peerConn.ConnectionState().String(),
+		Created:         ch.created,
+		Uptime:          time.Since(ch.created),
+		LastActivity:    ch.LastActivity(),
+		LastHeartbeat:   ch.LastHeartbeat(),
+		BufferedAmount:  ch.dataChannel.BufferedAmount(),
+	}
+	ch.mu.Lock()
+	info.Streams = len(ch.streams)
+	ch.mu.Unlock()
+	if pair, ok := webrtcPeerConnCandPair(peerConn); ok {
+		info.SelectedCandidatePair = pair.String()
+	}
+
+	candidates := map[string]webrtc.ICECandidateStats{}
+	var pairs []webrtc.ICECandidatePairStats
+	for _, stat := range peerConn.GetStats() {
+		switch stat := stat.(type) {
+		case webrtc.PeerConnectionStats:
+			info.ID = stat.ID
+		case webrtc.ICECandidateStats:
+			candidates[stat.ID] = stat
+		case webrtc.ICECandidatePairStats:
+			pairs = append(pairs, stat)
+		}
+	}
+	describeCandidate := func(id string) string {
+		cand, ok := candidates[id]
+		if !ok {
+			return id
+		}
+		return fmt.Sprintf("%s %s %s:%d", cand.CandidateType, cand.Protocol, cand.IP, cand.Port)
+	}
+	info.CandidatePairs = make([]CandidatePairDebugInfo, 0, len(pairs))
+	for _, pair := range pairs {
+		info.CandidatePairs = append(info.CandidatePairs, CandidatePairDebugInfo{
+			Local:         describeCandidate(pair.LocalCandidateID),
+			Remote:        describeCandidate(pair.RemoteCandidateID),
+			State:         string(pair.State),
+			Nominated:     pair.Nominated,
+			BytesSent:     pair.BytesSent,
+			BytesReceived: pair.BytesReceived,
+			RoundTripTime: time.Duration(pair.CurrentRoundTripTime * float64(time.Second)),
+		})
+	}
+	sort.Slice(info.CandidatePairs, func(i, j int) bool {
+		if info.CandidatePairs[i].Local != info.CandidatePairs[j].Local {
+			return info.CandidatePairs[i].Local < info.CandidatePairs[j].Local
+		}
+		return info.CandidatePairs[i].Remote < info.CandidatePairs[j].Remote
+	})
+	return info
+}
+
+// debugPeer is a peer connection held by one of the WebRTC servers of a server.
+type debugPeer struct {
+	host     string
+	peerConn *webrtc.PeerConnection
+	channel  *webrtcServerChannel
+}
+
+// debugPeers returns every peer connection held by the server, oldest first.
+func (ss *simpleServer) debugPeers() []debugPeer {
+	var peers []debugPeer
+	addPeers := func(host string, srv *webrtcServer) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		for peerConn, ch := range srv.peerConns {
+			peers = append(peers, debugPeer{host: host, peerConn: peerConn, channel: ch})
+		}
+	}
+	if ss.webrtcServer != nil {
+		addPeers("", ss.webrtcServer)
+	}
+	for host, hostServer := range ss.webrtcHostServers {
+		addPeers(host, hostServer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].channel.created.Before(peers[j].channel.created)
+	})
+	return peers
+}
+
+// findDebugPeer returns the peer connection with the given ID, if it is still held.
+func (ss *simpleServer) findDebugPeer(id string) (debugPeer, bool) {
+	for _, peer := range ss.debugPeers() {
+		if getWebRTCPeerConnectionStats(peer.peerConn).ID == id {
+			return peer, true
+		}
+	}
+	return debugPeer{}, false
+}
+
+// servePeersDebug serves requests under PeersDebugPath that serveDebug has already
+// authorized.
+func (ss *simpleServer) servePeersDebug(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case PeersDebugPath:
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peers := ss.debugPeers()
+		infos := make([]PeerDebugInfo, 0, len(peers))
+		for _, peer := range peers {
+			info := peerDebugInfo(peer.peerConn, peer.channel)
+			info.Host = peer.host
+			infos = append(infos, info)
+		}
+		if wantsHTML(r) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := peersDebugTemplate.Execute(w, peersDebugPage{
+				Peers:     infos,
+				SDPPath:   peersDebugSDPPath,
+				ClosePath: peersDebugClosePath,
+			}); err != nil {
+				ss.logger.Debugw("error writing peers debug page", "error", err)
+			}
+			return
+		}
+		ss.writeDebugJSON(w, map[string]interface{}{"peers": infos})
+	case peersDebugSDPPath:
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peer, ok := ss.findDebugPeer(r.URL.Query().Get("id"))
+		if !ok {
+			http.Error(w, "no such peer", http.StatusNotFound)
+			return
+		}
+		ss.writeDebugJSON(w, map[string]*webrtc.SessionDescription{
+			"local":  peer.peerConn.LocalDescription(),
+			"remote": peer.peerConn.RemoteDescription(),
+		})
+	case peersDebugClosePath:
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOriginRequest(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		peer, ok := ss.findDebugPeer(id)
+		if !ok {
+			http.Error(w, "no such peer", http.StatusNotFound)
+			return
+		}
+		ss.logger.Infow("closing peer from the debug endpoint", "peer_connection_id", id, "entity", peer.channel.entity())
+		if err := peer.channel.closeWithPeerReason(errPeerClosedForDebug); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if wantsHTML(r) {
+			http.Redirect(w, r, PeersDebugPath, http.StatusSeeOther)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (ss *simpleServer) writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		ss.logger.Debugw("error writing debug response", "error", err)
+	}
+}
+
+// sameOriginRequest returns whether the request came from a page of the server itself or
+// from something other than a browser, so that other sites cannot have the browser of
+// someone allowed to reach the server close its peers.
+func sameOriginRequest(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		originURL, err := url.Parse(origin)
+		return err == nil && originURL.Host == r.Host
+	}
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+		return true
+	default:
+		return false
+	}
+}
+
+// wantsHTML returns whether the request came from a browser rather than a program.
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+type peersDebugPage struct {
+	Peers     []PeerDebugInfo
+	SDPPath   string
+	ClosePath string
+}
+
+var peersDebugTemplate = template.Must(template.New("peers").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Peers</title></head>
+<body>
+<h1>{{len .Peers}} peers</h1>
+<table border="1" cellpadding="4">
+<tr><th>ID</th><th>Host</th><th>Entity</th><th>State</th><th>Uptime</th><th>Streams</th><th>Buffered</th><th>Selected pair</th><th>Candidate pairs</th><th></th></tr>
+{{range .Peers}}
+<tr>
+<td>{{.ID}}</td>
+<td>{{.Host}}</td>
+<td>{{.Entity}}</td>
+<td>{{.ConnectionState}}</td>
+<td>{{.Uptime}}</td>
+<td>{{.Streams}}</td>
+<td>{{.BufferedAmount}}</td>
+<td>{{.SelectedCandidatePair}}</td>
+<td>{{range .CandidatePairs}}{{.Local}} &harr; {{.Remote}} {{.State}}{{if .Nominated}} (nominated, rtt {{.RoundTripTime}}){{end}}<br>{{end}}</td>
+<td>
+<a href="{{$.SDPPath}}?id={{.ID}}">SDP</a>
+<form method="post" action="{{$.ClosePath}}?id={{.ID}}"><button type="submit">Close</button></form>
+</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,252 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	rpcpb "go.viam.com/utils/proto/rpc/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+func TestPeersDebugEndpoint(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler("fake", AuthHandlerFunc(func(ctx context.Context, entity, payload string) (map[string]string, error) {
+			if payload != "pass" {
+				return nil, errors.New("wrong password")
+			}
+			return map[string]string{}, nil
+		})),
+		WithAuthorizationPolicies(
+			AuthorizationPolicy{Entities: []string{"admin"}, Methods: []string{PeersDebugPath, PeersDebugPath + "/*"}},
+			AuthorizationPolicy{Entities: []string{"user"}, Methods: []string{"/proto.rpc.examples.echo.v1.EchoService/*"}},
+		),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                   true,
+			InternalSignalingHosts:   []string{"yeehaw"},
+			EnablePeersDebugEndpoint: true,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	es := echoserver.Server{}
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	grpcConn, err := grpc.DialContext(
+		context.Background(),
+		listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, grpcConn.Close(), test.ShouldBeNil)
+	}()
+	accessToken := func(t *testing.T, entity string) string {
+		t.Helper()
+		resp, err := rpcpb.NewAuthServiceClient(grpcConn).Authenticate(context.Background(), &rpcpb.AuthenticateRequest{
+			Entity:      entity,
+			Credentials: &rpcpb.Credentials{Type: "fake", Payload: "pass"},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return resp.AccessToken
+	}
+	adminToken := accessToken(t, "admin")
+
+	serve := func(method, target, token string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if token != "" {
+			req.Header.Set(MetadataFieldAuthorization, "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, req)
+		return w
+	}
+	listPeers := func(tb testing.TB) []PeerDebugInfo {
+		tb.Helper()
+		w := serve(http.MethodGet, PeersDebugPath, adminToken, nil)
+		test.That(tb, w.Code, test.ShouldEqual, http.StatusOK)
+		var resp struct {
+			Peers []PeerDebugInfo `json:"peers"`
+		}
+		test.That(tb, json.NewDecoder(w.Body).Decode(&resp), test.ShouldBeNil)
+		return resp.Peers
+	}
+
+	t.Run("requires authentication and authorization", func(t *testing.T) {
+		test.That(t, serve(http.MethodGet, PeersDebugPath, "", nil).Code, test.ShouldEqual, http.StatusUnauthorized)
+		test.That(t, serve(http.MethodGet, PeersDebugPath, "notatoken", nil).Code, test.ShouldEqual, http.StatusUnauthorized)
+		userToken := accessToken(t, "user")
+		test.That(t, serve(http.MethodGet, PeersDebugPath, userToken, nil).Code, test.ShouldEqual, http.StatusForbidden)
+		test.That(t, listPeers(t), test.ShouldBeEmpty)
+	})
+
+	rtcConn, err := dialWebRTC(context.Background(), listener.Addr().String(), "yeehaw", dialOptions{
+		webrtcOpts: DialWebRTCOptions{
+			SignalingInsecure: true,
+			SignalingCreds:    Credentials{Type: "fake", Payload: "pass"},
+		},
+		webrtcOptsSet: true,
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rtcConn.Close(), test.ShouldBeNil)
+	}()
+	_, err = pb.NewEchoServiceClient(rtcConn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	var peer PeerDebugInfo
+	t.Run("lists peers", func(t *testing.T) {
+		peers := listPeers(t)
+		test.That(t, peers, test.ShouldHaveLength, 1)
+		peer = peers[0]
+		test.That(t, peer.ID, test.ShouldNotBeEmpty)
+		test.That(t, peer.Entity, test.ShouldEqual, "yeehaw")
+		test.That(t, peer.ConnectionState, test.ShouldEqual, webrtc.PeerConnectionStateConnected.String())
+		test.That(t, peer.Uptime, test.ShouldBeGreaterThan, 0)
+		test.That(t, peer.SelectedCandidatePair, test.ShouldNotBeEmpty)
+		test.That(t, peer.CandidatePairs, test.ShouldNotBeEmpty)
+
+		w := serve(http.MethodGet, PeersDebugPath, adminToken, http.Header{"Accept": []string{"text/html"}})
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		test.That(t, w.Header().Get("Content-Type"), test.ShouldContainSubstring, "text/html")
+		test.That(t, w.Body.String(), test.ShouldContainSubstring, peer.ID)
+	})
+
+	t.Run("dumps SDP", func(t *testing.T) {
+		w := serve(http.MethodGet, peersDebugSDPPath+"?id="+peer.ID, adminToken, nil)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		var descs map[string]webrtc.SessionDescription
+		test.That(t, json.NewDecoder(w.Body).Decode(&descs), test.ShouldBeNil)
+		test.That(t, descs["local"].Type, test.ShouldEqual, webrtc.SDPTypeAnswer)
+		test.That(t, descs["remote"].Type, test.ShouldEqual, webrtc.SDPTypeOffer)
+		test.That(t, descs["remote"].SDP, test.ShouldNotBeEmpty)
+
+		w = serve(http.MethodGet, peersDebugSDPPath+"?id=unknown", adminToken, nil)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusNotFound)
+	})
+
+	t.Run("closes peers", func(t *testing.T) {
+		w := serve(http.MethodGet, peersDebugClosePath+"?id="+peer.ID, adminToken, nil)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusMethodNotAllowed)
+		w = serve(http.MethodPost, peersDebugClosePath+"?id=unknown", adminToken, nil)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusNotFound)
+
+		// other sites cannot have a browser close peers.
+		w = serve(http.MethodPost, peersDebugClosePath+"?id="+peer.ID, adminToken, http.Header{
+			"Origin": []string{"https://evil.example"},
+		})
+		test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+		w = serve(http.MethodPost, peersDebugClosePath+"?id="+peer.ID, adminToken, http.Header{
+			"Sec-Fetch-Site": []string{"cross-site"},
+		})
+		test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+		test.That(t, listPeers(t), test.ShouldHaveLength, 1)
+
+		w = serve(http.MethodPost, peersDebugClosePath+"?id="+peer.ID, adminToken, nil)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusNoContent)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			closed, _ := rtcConn.(*webrtcClientChannel).Closed()
+			test.That(tb, closed, test.ShouldBeTrue)
+		})
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			test.That(tb, listPeers(tb), test.ShouldBeEmpty)
+		})
+	})
+}
+
+func TestPeersDebugEndpointNotServed(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, PeersDebugPath, nil)
+	w := httptest.NewRecorder()
+	rpcServer.ServeHTTP(w, req)
+	test.That(t, w.Code, test.ShouldNotEqual, http.StatusOK)
+}
+
+func TestPeersDebugEndpointUnauthenticated(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithUnauthenticated(),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                   true,
+			InternalSignalingHosts:   []string{"yeehaw"},
+			EnablePeersDebugEndpoint: true,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	for remoteAddr, expectedCode := range map[string]int{
+		"127.0.0.1:1234": http.StatusOK,
+		"[::1]:1234":     http.StatusOK,
+		"192.0.2.1:1234": http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, PeersDebugPath, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		rpcServer.ServeHTTP(w, req)
+		test.That(t, w.Code, test.ShouldEqual, expectedCode)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, peersDebugClosePath+"?id=unknown", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("Origin", "http://"+req.Host)
+	w := httptest.NewRecorder()
+	rpcServer.ServeHTTP(w, req)
+	test.That(t, w.Code, test.ShouldEqual, http.StatusNotFound)
+
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	rpcServer.ServeHTTP(w, req)
+	test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+}
@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// A StreamPriority is the priority class of a stream multiplexed over a WebRTC channel.
+// When the channel is congested, frames of higher priority streams are written first
+// while lower priority streams still get a smaller share of the channel.
+type StreamPriority int
+
+// The known stream priorities. Streams are of normal priority unless tagged otherwise.
+const (
+	StreamPriorityLow StreamPriority = iota - 1
+	StreamPriorityNormal
+	StreamPriorityHigh
+)
+
+// StreamPriorityMetadataKey is the metadata key a stream's priority can be set with
+// as an alternative to WithStreamPriority. Its value is the priority's name.
+const StreamPriorityMetadataKey = "rpc-stream-priority"
+
+// String returns the name of the priority.
+func (p StreamPriority) String() string {
+	switch p {
+	case StreamPriorityLow:
+		return "low"
+	case StreamPriorityHigh:
+		return "high"
+	case StreamPriorityNormal:
+		fallthrough
+	default:
+		return "normal"
+	}
+}
+
+// streamPriorityFromMetadata returns the priority set in the given metadata, defaulting
+// to normal priority.
+func streamPriorityFromMetadata(md metadata.MD) StreamPriority {
+	values := md.Get(StreamPriorityMetadataKey)
+	if len(values) == 0 {
+		return StreamPriorityNormal
+	}
+	switch values[0] {
+	case StreamPriorityLow.String():
+		return StreamPriorityLow
+	case StreamPriorityHigh.String():
+		return StreamPriorityHigh
+	default:
+		return StreamPriorityNormal
+	}
+}
+
+// A StreamPriorityCallOption sets the priority of a call made over a WebRTC channel.
+// Other connections ignore it.
+type StreamPriorityCallOption struct {
+	grpc.EmptyCallOption
+	Priority StreamPriority
+}
+
+// WithStreamPriority returns a call option that tags the call's stream with the given
+// priority. The priority applies to both requests and responses of the call.
+func WithStreamPriority(priority StreamPriority) grpc.CallOption {
+	return StreamPriorityCallOption{Priority: priority}
+}
+
+// contextWithStreamPriority returns a context whose outgoing metadata carries the
+// priority from the given call options, if any, so that it reaches the server.
+func contextWithStreamPriority(ctx context.Context, opts []grpc.CallOption) context.Context {
+	for _, opt := range opts {
+		priorityOpt, ok := opt.(StreamPriorityCallOption)
+		if !ok {
+			continue
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		md.Set(StreamPriorityMetadataKey, priorityOpt.Priority.String())
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+	return ctx
+}
+
+// writeSchedulerWeights are how many writes each priority, from highest to lowest, gets
+// in every round of contention.
+var writeSchedulerWeights = [...]int{4, 2, 1}
+
+func writeSchedulerIndex(priority StreamPriority) int {
+	switch {
+	case priority > StreamPriorityNormal:
+		return 0
+	case priority < StreamPriorityNormal:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// A webrtcWriteScheduler decides which frame is written to a channel next. Writes go
+// through one at a time; while one is in progress, such as when waiting for the channel's
+// buffer to drain, others queue up by priority and are serviced with weighted round
+// robin so that higher priorities go first without starving lower ones.
+type webrtcWriteScheduler struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting [len(writeSchedulerWeights)][]chan struct{}
+	credits [len(writeSchedulerWeights)]int
+}
+
+func newWebRTCWriteScheduler() *webrtcWriteScheduler {
+	return &webrtcWriteScheduler{credits: writeSchedulerWeights}
+}
+
+// acquire waits until it is the turn of a write of the given priority. Every successful
+// acquire must be followed by a release.
+func (s *webrtcWriteScheduler) acquire(ctx context.Context, priority StreamPriority) error {
+	s.mu.Lock()
+	if !s.busy {
+		s.busy = true
+		s.mu.Unlock()
+		return nil
+	}
+	idx := writeSchedulerIndex(priority)
+	turn := make(chan struct{})
+	s.waiting[idx] = append(s.waiting[idx], turn)
+	s.mu.Unlock()
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-turn:
+		// our turn came anyway; pass it on
+		s.handOff()
+		return ctx.Err()
+	default:
+	}
+	for i, waiter := range s.waiting[idx] {
+		if waiter == turn {
+			s.waiting[idx] = append(s.waiting[idx][:i], s.waiting[idx][i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+// release ends the current write and gives the next one its turn.
+func (s *webrtcWriteScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handOff()
+}
+
+// handOff must be called with mu held.
+func (s *webrtcWriteScheduler) handOff() {
+	for round := 0; round < 2; round++ {
+		for idx := range s.waiting {
+			if len(s.waiting[idx]) == 0 || s.credits[idx] == 0 {
+				continue
+			}
+			s.credits[idx]--
+			next := s.waiting[idx][0]
+			s.waiting[idx] = s.waiting[idx][1:]
+			close(next)
+			return
+		}
+		// everyone waiting has used up their share of this round
+		s.credits = writeSchedulerWeights
+	}
+	s.busy = false
+}
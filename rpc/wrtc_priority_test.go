@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	echopb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+)
+
+func TestStreamPriorityFromMetadata(t *testing.T) {
+	test.That(t, streamPriorityFromMetadata(nil), test.ShouldEqual, StreamPriorityNormal)
+	for _, priority := range []StreamPriority{StreamPriorityLow, StreamPriorityNormal, StreamPriorityHigh} {
+		md := metadata.Pairs(StreamPriorityMetadataKey, priority.String())
+		test.That(t, streamPriorityFromMetadata(md), test.ShouldEqual, priority)
+	}
+	md := metadata.Pairs(StreamPriorityMetadataKey, "urgent")
+	test.That(t, streamPriorityFromMetadata(md), test.ShouldEqual, StreamPriorityNormal)
+
+	ctx := contextWithStreamPriority(context.Background(), nil)
+	_, ok := metadata.FromOutgoingContext(ctx)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), StreamPriorityMetadataKey, "low", "other", "value")
+	ctx = contextWithStreamPriority(ctx, []grpc.CallOption{WithStreamPriority(StreamPriorityHigh)})
+	md, ok = metadata.FromOutgoingContext(ctx)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, streamPriorityFromMetadata(md), test.ShouldEqual, StreamPriorityHigh)
+	test.That(t, md.Get("other"), test.ShouldResemble, []string{"value"})
+}
+
+// waitForWriters waits until the scheduler has the given number of writes queued up.
+func waitForWriters(t *testing.T, s *webrtcWriteScheduler, n int) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		s.mu.Lock()
+		var queued int
+		for _, waiting := range s.waiting {
+			queued += len(waiting)
+		}
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d queued writers", n)
+}
+
+func TestWebRTCWriteScheduler(t *testing.T) {
+	t.Run("higher priority first", func(t *testing.T) {
+		s := newWebRTCWriteScheduler()
+		test.That(t, s.acquire(context.Background(), StreamPriorityNormal), test.ShouldBeNil)
+
+		var mu sync.Mutex
+		var order []StreamPriority
+		var wg sync.WaitGroup
+		for i, priority := range []StreamPriority{StreamPriorityLow, StreamPriorityNormal, StreamPriorityHigh} {
+			wg.Add(1)
+			go func(priority StreamPriority) {
+				defer wg.Done()
+				if err := s.acquire(context.Background(), priority); err != nil {
+					return
+				}
+				mu.Lock()
+				order = append(order, priority)
+				mu.Unlock()
+				s.release()
+			}(priority)
+			waitForWriters(t, s, i+1)
+		}
+		s.release()
+		wg.Wait()
+		test.That(t, order, test.ShouldResemble, []StreamPriority{StreamPriorityHigh, StreamPriorityNormal, StreamPriorityLow})
+		test.That(t, s.busy, test.ShouldBeFalse)
+	})
+
+	t.Run("lower priority is not starved", func(t *testing.T) {
+		s := newWebRTCWriteScheduler()
+		test.That(t, s.acquire(context.Background(), StreamPriorityNormal), test.ShouldBeNil)
+
+		var mu sync.Mutex
+		var order []StreamPriority
+		var wg sync.WaitGroup
+		priorities := []StreamPriority{StreamPriorityLow}
+		for i := 0; i < 6; i++ {
+			priorities = append(priorities, StreamPriorityHigh)
+		}
+		for i, priority := range priorities {
+			wg.Add(1)
+			go func(priority StreamPriority) {
+				defer wg.Done()
+				if err := s.acquire(context.Background(), priority); err != nil {
+					return
+				}
+				mu.Lock()
+				order = append(order, priority)
+				mu.Unlock()
+				s.release()
+			}(priority)
+			waitForWriters(t, s, i+1)
+		}
+		s.release()
+		wg.Wait()
+		test.That(t, order, test.ShouldHaveLength, 7)
+		test.That(t, order[4], test.ShouldEqual, StreamPriorityLow)
+	})
+
+	t.Run("canceled waiter", func(t *testing.T) {
+		s := newWebRTCWriteScheduler()
+		test.That(t, s.acquire(context.Background(), StreamPriorityNormal), test.ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		acquired := make(chan error, 1)
+		go func() {
+			acquired <- s.acquire(ctx, StreamPriorityHigh)
+		}()
+		waitForWriters(t, s, 1)
+		cancel()
+		test.That(t, <-acquired, test.ShouldEqual, context.Canceled)
+		waitForWriters(t, s, 0)
+
+		s.release()
+		test.That(t, s.busy, test.ShouldBeFalse)
+	})
+}
+
+func TestWebRTCChannelStreamPriority(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair, err := NewInMemoryWebRTCChannelPair(ctx, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, pair.Close(), test.ShouldBeNil)
+	}()
+	pair.RegisterService(&echopb.EchoService_ServiceDesc, &echoserver.Server{})
+	client := echopb.NewEchoServiceClient(pair.Client())
+
+	resp, err := client.Echo(ctx, &echopb.EchoRequest{Message: "hello"}, WithStreamPriority(StreamPriorityHigh))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp.GetMessage(), test.ShouldEqual, "hello")
+
+	multiClient, err := client.EchoMultiple(ctx, &echopb.EchoMultipleRequest{Message: "howdy"}, WithStreamPriority(StreamPriorityLow))
+	test.That(t, err, test.ShouldBeNil)
+	var received string
+	for {
+		resp, err := multiClient.Recv()
+		if err != nil {
+			break
+		}
+		received += resp.GetMessage()
+	}
+	test.That(t, received, test.ShouldEqual, "howdy")
+}
@@ -6,16 +6,45 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/webrtc/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
 )
 
 // DefaultWebRTCMaxGRPCCalls is the maximum number of concurrent gRPC calls to allow
 // for a server.
 var DefaultWebRTCMaxGRPCCalls = 256
 
+var (
+	activePeerConnections = statz.NewGauge0("rpc.webrtc/active_peer_connections", statz.MetricConfig{
+		Description: "The number of peer connections currently held by the server.",
+		Unit:        units.Dimensionless,
+	})
+
+	peerConnectionsRejected = statz.NewCounter0("rpc.webrtc/peer_connections_rejected", statz.MetricConfig{
+		Description: "The number of offers rejected because the server was at its peer connection limit.",
+		Unit:        units.Dimensionless,
+	})
+
+	peerConnectionsEvicted = statz.NewCounter0("rpc.webrtc/peer_connections_evicted", statz.MetricConfig{
+		Description: "The number of idle peers evicted because the server was at its peer connection limit.",
+		Unit:        units.Dimensionless,
+	})
+)
+
+var (
+	errTooManyPeerConnections = status.Error(codes.ResourceExhausted, "too many peer connections")
+	errPeerEvicted            = &PeerCloseReason{Code: codes.ResourceExhausted, Message: "evicted to make room for another peer"}
+	errServerStopping         = &PeerCloseReason{Code: codes.Unavailable, Message: "server is shutting down"}
+)
+
 // A webrtcServer translates gRPC frames over WebRTC data channels into gRPC calls.
 type webrtcServer struct {
 	mu       sync.Mutex
@@ -25,7 +54,8 @@ type webrtcServer struct {
 	services map[string]*serviceInfo
 	logger   golog.Logger
 
-	peerConns               map[*webrtc.PeerConnection]struct{}
+	peerConns               map[*webrtc.PeerConnection]*webrtcServerChannel
+	tunnels                 map[*webrtcServerChannel]struct{}
 	activeBackgroundWorkers sync.WaitGroup
 	callTickets             chan struct{}
 
@@ -35,6 +65,35 @@ type webrtcServer struct {
 
 	onPeerAdded   func(pc *webrtc.PeerConnection)
 	onPeerRemoved func(pc *webrtc.PeerConnection)
+	onPeerEvent   func(event PeerEvent)
+
+	// peerOpts are used for every peer connection answered on behalf of this server.
+	peerOpts webrtcPeerOptions
+
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+
+	// admission limits the peers of this server together with those of its host servers.
+	admission *webrtcPeerAdmission
+
+	// compressors are the compressors responses may be compressed with, in order of preference.
+	compressors []string
+
+	// frameTracer, if set, traces the frames of every channel.
+	frameTracer *webrtcFrameTracer
+
+	// maxChannelSendRate, if positive, limits the bytes per second sent on every channel.
+	maxChannelSendRate float64
+
+	// metrics, if set, records metrics about peer connections.
+	metrics *serverMetrics
+
+	// maxRecvMsgSize and maxSendMsgSize, if set, limit the size of messages on every stream.
+	maxRecvMsgSize int
+	maxSendMsgSize int
+
+	// connectionAge limits how long peer connections live.
+	connectionAge ConnectionAgeOptions
 }
 
 // from grpc.
@@ -71,8 +130,10 @@ func newWebRTCServerWithInterceptorsAndUnknownStreamHandler(
 		handlers:          map[string]handlerFunc{},
 		services:          map[string]*serviceInfo{},
 		logger:            logger,
-		peerConns:         map[*webrtc.PeerConnection]struct{}{},
+		peerConns:         map[*webrtc.PeerConnection]*webrtcServerChannel{},
+		tunnels:           map[*webrtcServerChannel]struct{}{},
 		callTickets:       make(chan struct{}, DefaultWebRTCMaxGRPCCalls),
+		admission:         &webrtcPeerAdmission{admitted: map[*webrtcServerChannel]struct{}{}},
 		unaryInt:          unaryInt,
 		streamInt:         streamInt,
 		unknownStreamDesc: unknownStreamDesc,
@@ -81,6 +142,33 @@ func newWebRTCServerWithInterceptorsAndUnknownStreamHandler(
 	return srv
 }
 
+// newHostServer makes a new server with no registered services that is otherwise set up
+// the same as this one. It is used to serve a distinct set of services to the peers of
+// a single host.
+func (srv *webrtcServer) newHostServer(host string) *webrtcServer {
+	hostSrv := newWebRTCServerWithInterceptorsAndUnknownStreamHandler(
+		srv.logger.With("host", host),
+		srv.unaryInt,
+		srv.streamInt,
+		srv.unknownStreamDesc,
+	)
+	hostSrv.onPeerAdded = srv.onPeerAdded
+	hostSrv.onPeerRemoved = srv.onPeerRemoved
+	hostSrv.onPeerEvent = srv.onPeerEvent
+	hostSrv.peerOpts = srv.peerOpts
+	hostSrv.heartbeatInterval = srv.heartbeatInterval
+	hostSrv.heartbeatTimeout = srv.heartbeatTimeout
+	hostSrv.admission = srv.admission
+	hostSrv.compressors = srv.compressors
+	hostSrv.frameTracer = srv.frameTracer
+	hostSrv.maxChannelSendRate = srv.maxChannelSendRate
+	hostSrv.metrics = srv.metrics
+	hostSrv.maxRecvMsgSize = srv.maxRecvMsgSize
+	hostSrv.maxSendMsgSize = srv.maxSendMsgSize
+	hostSrv.connectionAge = srv.connectionAge
+	return hostSrv
+}
+
 // Stop instructs the server and all handlers to stop. It returns when all handlers
 // are done executing.
 func (srv *webrtcServer) Stop() {
@@ -90,13 +178,24 @@ func (srv *webrtcServer) Stop() {
 	srv.logger.Info("handlers complete")
 	srv.mu.Lock()
 	srv.logger.Info("closing lingering peer connections")
-	for pc := range srv.peerConns {
+	for pc, ch := range srv.peerConns {
+		ch.sendCloseReason(errServerStopping)
 		if err := pc.Close(); err != nil {
 			srv.logger.Errorw("error closing peer connection", "error", err)
 		}
 	}
 	srv.logger.Info("lingering peer connections closed")
+	tunnels := make([]*webrtcServerChannel, 0, len(srv.tunnels))
+	for ch := range srv.tunnels {
+		tunnels = append(tunnels, ch)
+	}
 	srv.mu.Unlock()
+	// tunnels wait on their remote ends to close so they are not closed while locked.
+	for _, ch := range tunnels {
+		if err := ch.Close(); err != nil {
+			srv.logger.Errorw("error closing WebSocket tunnel", "error", err)
+		}
+	}
 }
 
 // RegisterService registers the given implementation of a service to be handled via
@@ -173,18 +272,121 @@ func (srv *webrtcServer) NewChannel(
 	authAudience []string,
 ) *webrtcServerChannel {
 	serverCh := newWebRTCServerChannel(srv, peerConn, dataChannel, authAudience, srv.logger)
+	serverCh.acceptHeartbeat(srv.heartbeatInterval, srv.heartbeatTimeout)
+	serverCh.enforceConnectionAge(srv.connectionAge)
 	srv.mu.Lock()
-	srv.peerConns[peerConn] = struct{}{}
+	srv.peerConns[peerConn] = serverCh
 	srv.mu.Unlock()
+	srv.admission.add(serverCh)
+	srv.metrics.peerConnectionAdded()
 	if srv.onPeerAdded != nil {
 		srv.onPeerAdded(peerConn)
 	}
 	return serverCh
 }
 
+// A webrtcPeerAdmission limits the peers held by a server and its host servers, which
+// all share one, to the server's peer connection limit.
+type webrtcPeerAdmission struct {
+	mu     sync.Mutex
+	max    int
+	policy PeerConnectionLimitPolicy
+	// pending are peers admitted that are still being answered.
+	pending int
+	// admitted are the channels of every server sharing the admission that count against
+	// the limit and may be evicted to make room for others.
+	admitted map[*webrtcServerChannel]struct{}
+}
+
+// add counts the channel of an admitted peer against the limit.
+func (pa *webrtcPeerAdmission) add(ch *webrtcServerChannel) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.admitted[ch] = struct{}{}
+	activePeerConnections.Set(int64(len(pa.admitted)))
+}
+
+// remove stops counting the channel against the limit. It returns false if the channel
+// was not counted, such as when it was already evicted.
+func (pa *webrtcPeerAdmission) remove(ch *webrtcServerChannel) bool {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if _, ok := pa.admitted[ch]; !ok {
+		return false
+	}
+	delete(pa.admitted, ch)
+	activePeerConnections.Set(int64(len(pa.admitted)))
+	return true
+}
+
+// admitPeer determines whether or not a new peer may be answered given the server's
+// peer connection limit, which counts the peers of all of its host servers. If the limit
+// has been reached, the new peer is either rejected or the least recently active peer of
+// any host is evicted to make room for it. An admitted peer holds its slot until release
+// is called, which must be once it has been added with NewChannel or has failed to
+// connect, so that concurrent offers cannot exceed the limit.
+func (srv *webrtcServer) admitPeer() (release func(), err error) {
+	pa := srv.admission
+	pa.mu.Lock()
+	if pa.max <= 0 || len(pa.admitted)+pa.pending < pa.max {
+		release = pa.reserveLocked()
+		pa.mu.Unlock()
+		return release, nil
+	}
+	if pa.policy != PeerConnectionLimitEvictIdle {
+		pa.mu.Unlock()
+		peerConnectionsRejected.Inc()
+		return nil, errTooManyPeerConnections
+	}
+	var idlest *webrtcServerChannel
+	for ch := range pa.admitted {
+		if idlest == nil || ch.LastActivity().Before(idlest.LastActivity()) {
+			idlest = ch
+		}
+	}
+	if idlest == nil {
+		// every slot is held by a peer that is still being answered.
+		pa.mu.Unlock()
+		peerConnectionsRejected.Inc()
+		return nil, errTooManyPeerConnections
+	}
+	// removed now rather than once it finishes closing so that concurrent offers cannot
+	// evict it again and take its slot twice.
+	delete(pa.admitted, idlest)
+	activePeerConnections.Set(int64(len(pa.admitted)))
+	release = pa.reserveLocked()
+	pa.mu.Unlock()
+
+	idlest.server.metrics.peerConnectionRemoved()
+	peerConnectionsEvicted.Inc()
+	srv.logger.Infow("evicting least recently active peer", "last_activity", idlest.LastActivity())
+	if err := idlest.closeWithPeerReason(errPeerEvicted); err != nil {
+		srv.logger.Errorw("error evicting peer", "error", err)
+	}
+	return release, nil
+}
+
+// reserveLocked counts a peer being answered against the limit and returns a function
+// that stops counting it. It is safe to call the function more than once. pa.mu must be
+// held.
+func (pa *webrtcPeerAdmission) reserveLocked() func() {
+	pa.pending++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pa.mu.Lock()
+			pa.pending--
+			pa.mu.Unlock()
+		})
+	}
+}
+
 func (srv *webrtcServer) removePeer(peerConn *webrtc.PeerConnection) {
 	srv.mu.Lock()
 	defer srv.mu.Unlock()
+	if ch, ok := srv.peerConns[peerConn]; ok && srv.admission.remove(ch) {
+		srv.metrics.peerConnectionRemoved()
+	}
 	delete(srv.peerConns, peerConn)
 	if srv.onPeerRemoved != nil {
 		srv.onPeerRemoved(peerConn)
@@ -4,11 +4,14 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/webrtc/v3"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
 )
@@ -23,8 +26,17 @@ type webrtcServerChannel struct {
 	// the entity. There is no reason to extend the protocol right now since we intend
 	// to support some for of authentication in the presence of untrusted signalers.
 	authAudience string
-	server       *webrtcServer
-	streams      map[uint64]*webrtcServerStream
+	// authEntity, if set, is the entity that authenticated the channel itself, as is done
+	// for WebSocket tunnels, and takes the place of authAudience.
+	authEntity *EntityInfo
+	// authClaims are the claims of the access token the channel itself was authenticated
+	// with, if any, so that its calls are limited to the same scopes.
+	authClaims Claims
+	server     *webrtcServer
+	streams    map[uint64]*webrtcServerStream
+	// draining is set once the channel reached its max age and refuses new streams.
+	draining bool
+	created  time.Time
 }
 
 // newWebRTCServerChannel wraps the given WebRTC data channel to be used as the server end
@@ -32,7 +44,7 @@ type webrtcServerChannel struct {
 func newWebRTCServerChannel(
 	server *webrtcServer,
 	peerConn *webrtc.PeerConnection,
-	dataChannel *webrtc.DataChannel,
+	dataChannel webrtcDataChannel,
 	authAudience []string,
 	logger golog.Logger,
 ) *webrtcServerChannel {
@@ -43,41 +55,110 @@ func newWebRTCServerChannel(
 		func() { server.removePeer(peerConn) },
 		logger,
 	)
+	base.tracer = server.frameTracer.forChannel()
+	base.metrics = server.metrics
+	if server.maxChannelSendRate > 0 {
+		base.sendLimiter = newChannelSendLimiter(server.maxChannelSendRate)
+	}
 	ch := &webrtcServerChannel{
 		authAudience:      strings.Join(authAudience, ":"),
 		webrtcBaseChannel: base,
 		server:            server,
 		streams:           make(map[uint64]*webrtcServerStream),
+		created:           time.Now(),
 	}
 	dataChannel.OnMessage(ch.onChannelMessage)
 	return ch
 }
 
-func (ch *webrtcServerChannel) writeHeaders(stream *webrtcpb.Stream, headers *webrtcpb.ResponseHeaders) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Response{
+// useNegotiator associates the negotiator of this channel's peer connection with this
+// channel. In addition to exchanging close reasons, window updates received from the
+// remote peer are applied to the flow controlled streams they are for.
+func (ch *webrtcServerChannel) useNegotiator(negotiator *webrtcNegotiator) {
+	ch.webrtcBaseChannel.useNegotiator(negotiator)
+	negotiator.OnWindowUpdate(ch.onWindowUpdate)
+}
+
+func (ch *webrtcServerChannel) onWindowUpdate(streamID uint64, increment int) {
+	ch.mu.Lock()
+	serverStream, ok := ch.streams[streamID]
+	ch.mu.Unlock()
+	if !ok || serverStream.sendWindow == nil {
+		return
+	}
+	serverStream.sendWindow.release(increment)
+}
+
+// webrtcDeadlineMetadataField carries the absolute deadline of a call, if any, in
+// the request headers of a stream.
+const webrtcDeadlineMetadataField = "rpc-webrtc-deadline"
+
+// maxDeadlineClockSkew is how far apart the absolute deadline sent by a client and the
+// deadline derived from its relative timeout may be before the absolute deadline is
+// considered unreliable because of clock skew between the peers.
+const maxDeadlineClockSkew = time.Second
+
+// requestDeadline determines the deadline of a call received at the given time. The
+// relative timeout is always honored since it is immune to clock skew. When the client
+// also sent an absolute deadline that agrees with the timeout within maxDeadlineClockSkew,
+// the earlier of the two is used so that time spent in transit is accounted for.
+func requestDeadline(receivedAt time.Time, timeout *durationpb.Duration, md metadata.MD) (time.Time, bool) {
+	if timeout == nil || timeout.AsDuration() == 0 {
+		return time.Time{}, false
+	}
+	deadline := receivedAt.Add(timeout.AsDuration())
+
+	values := md.Get(webrtcDeadlineMetadataField)
+	if len(values) == 0 {
+		return deadline, true
+	}
+	absDeadline, err := time.Parse(time.RFC3339Nano, values[0])
+	if err != nil {
+		return deadline, true
+	}
+	skew := absDeadline.Sub(deadline)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= maxDeadlineClockSkew && absDeadline.Before(deadline) {
+		return absDeadline, true
+	}
+	return deadline, true
+}
+
+func (ch *webrtcServerChannel) writeHeaders(stream *webrtcpb.Stream, headers *webrtcpb.ResponseHeaders, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Response{
 		Stream: stream,
 		Type: &webrtcpb.Response_Headers{
 			Headers: headers,
 		},
-	})
+	}, priority)
 }
 
-func (ch *webrtcServerChannel) writeMessage(stream *webrtcpb.Stream, msg *webrtcpb.ResponseMessage) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Response{
+func (ch *webrtcServerChannel) writeMessage(stream *webrtcpb.Stream, msg *webrtcpb.ResponseMessage, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Response{
 		Stream: stream,
 		Type: &webrtcpb.Response_Message{
 			Message: msg,
 		},
-	})
+	}, priority)
 }
 
-func (ch *webrtcServerChannel) writeTrailers(stream *webrtcpb.Stream, trailers *webrtcpb.ResponseTrailers) error {
-	return ch.webrtcBaseChannel.write(&webrtcpb.Response{
+func (ch *webrtcServerChannel) writeTrailers(stream *webrtcpb.Stream, trailers *webrtcpb.ResponseTrailers, priority StreamPriority) error {
+	return ch.webrtcBaseChannel.writeWithPriority(&webrtcpb.Response{
 		Stream: stream,
 		Type: &webrtcpb.Response_Trailers{
 			Trailers: trailers,
 		},
-	})
+	}, priority)
+}
+
+// entity returns who calls over the channel are made on behalf of.
+func (ch *webrtcServerChannel) entity() string {
+	if ch.authEntity != nil {
+		return ch.authEntity.Entity
+	}
+	return ch.authAudience
 }
 
 func (ch *webrtcServerChannel) removeStreamByID(id uint64) {
@@ -87,12 +168,15 @@ func (ch *webrtcServerChannel) removeStreamByID(id uint64) {
 }
 
 func (ch *webrtcServerChannel) onChannelMessage(msg webrtc.DataChannelMessage) {
+	ch.markActivity()
+	ch.metrics.dataChannelBytesReceived(len(msg.Data))
 	req := &webrtcpb.Request{}
 	err := proto.Unmarshal(msg.Data, req)
 	if err != nil {
 		ch.webrtcBaseChannel.logger.Errorw("error unmarshaling message; discarding", "error", err)
 		return
 	}
+	ch.tracer.trace(FrameDirectionInbound, req, len(msg.Data))
 	stream := req.GetStream()
 	if stream == nil {
 		ch.webrtcBaseChannel.logger.Error("no stream, discard request")
@@ -105,6 +189,17 @@ func (ch *webrtcServerChannel) onChannelMessage(msg webrtc.DataChannelMessage) {
 	ch.mu.Lock()
 	serverStream, ok := ch.streams[id]
 	if !ok {
+		if ch.draining {
+			ch.mu.Unlock()
+			if _, ok := req.Type.(*webrtcpb.Request_Headers); ok {
+				if err := ch.writeTrailers(stream, &webrtcpb.ResponseTrailers{
+					Status: status.Convert(errPeerConnectionDraining).Proto(),
+				}, StreamPriorityNormal); err != nil {
+					logger.Debugw("error refusing stream on draining connection", "error", err)
+				}
+			}
+			return
+		}
 		if len(ch.streams) == WebRTCMaxStreamCount {
 			logger.Error(errWebRTCMaxStreams)
 			ch.mu.Unlock()
@@ -118,22 +213,46 @@ func (ch *webrtcServerChannel) onChannelMessage(msg webrtc.DataChannelMessage) {
 			return
 		}
 
-		handlerCtx := metadata.NewIncomingContext(ch.ctx, metadataFromProto(headers.Headers.Metadata))
-		timeout := headers.Headers.Timeout.AsDuration()
+		md := metadataFromProto(headers.Headers.Metadata)
+		deadline, hasDeadline := requestDeadline(time.Now(), headers.Headers.Timeout, md)
+		compressor := negotiateCompressor(ch.server.compressors, md)
+		var sendWindow *webrtcSendWindow
+		if windowSize, ok := streamWindowSizeFromMetadata(md); ok && ch.hasNegotiator() {
+			sendWindow = newWebRTCSendWindow(windowSize)
+		}
+		delete(md, webrtcDeadlineMetadataField)
+		delete(md, webrtcAcceptEncodingMetadataField)
+		delete(md, webrtcWindowSizeMetadataField)
+
+		handlerCtx := metadata.NewIncomingContext(ch.ctx, md)
 		var cancelCtx func()
-		if timeout == 0 {
-			handlerCtx, cancelCtx = context.WithCancel(handlerCtx)
+		if hasDeadline {
+			handlerCtx, cancelCtx = context.WithDeadline(handlerCtx, deadline)
 		} else {
-			handlerCtx, cancelCtx = context.WithTimeout(handlerCtx, timeout)
+			handlerCtx, cancelCtx = context.WithCancel(handlerCtx)
+		}
+		if ch.peerConn != nil {
+			handlerCtx = contextWithPeerConnection(handlerCtx, ch.peerConn)
 		}
-		handlerCtx = contextWithPeerConnection(handlerCtx, ch.peerConn)
 
-		// TODO(GOUT-11): Handle auth; right now we assume successful auth to the signaler
-		// implies that auth should be allowed here, which is not 100% true.
-		// TODO(RSDK-890): use the correct entity (sub), not the audience (hosts)
-		handlerCtx = ContextWithAuthEntity(handlerCtx, EntityInfo{Entity: ch.authAudience})
+		if ch.authEntity != nil {
+			handlerCtx = ContextWithAuthEntity(handlerCtx, *ch.authEntity)
+			if ch.authClaims != nil {
+				handlerCtx = contextWithAuthClaims(handlerCtx, ch.authClaims)
+			}
+		} else {
+			// TODO(GOUT-11): Handle auth; right now we assume successful auth to the signaler
+			// implies that auth should be allowed here, which is not 100% true.
+			// TODO(RSDK-890): use the correct entity (sub), not the audience (hosts)
+			handlerCtx = contextWithSignaledAuthEntity(handlerCtx, EntityInfo{Entity: ch.authAudience})
+		}
 
 		serverStream = newWebRTCServerStream(handlerCtx, cancelCtx, headers.Headers.Method, ch, stream, ch.removeStreamByID, logger)
+		serverStream.compressor = compressor
+		serverStream.sendWindow = sendWindow
+		serverStream.priority = streamPriorityFromMetadata(md)
+		serverStream.maxRecvMsgSize = ch.server.maxRecvMsgSize
+		serverStream.maxSendMsgSize = ch.server.maxSendMsgSize
 		ch.streams[id] = serverStream
 	}
 	ch.mu.Unlock()
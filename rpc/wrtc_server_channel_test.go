@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/edaniels/golog"
 	"github.com/pion/webrtc/v3"
@@ -13,6 +14,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
 	"go.viam.com/utils/testutils"
@@ -55,7 +57,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 	test.That(t, clientCh.write(&webrtcpb.Request{}), test.ShouldBeNil) // bad request
 	test.That(t, clientCh.writeMessage(&webrtcpb.Stream{                // message before headers
 		Id: 1,
-	}, &webrtcpb.RequestMessage{}), test.ShouldBeNil)
+	}, &webrtcpb.RequestMessage{}, StreamPriorityNormal), test.ShouldBeNil)
 
 	var expectedMessagesMu sync.Mutex
 	expectedMessages := []*webrtcpb.Response{
@@ -93,7 +95,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 
 	test.That(t, clientCh.writeHeaders(&webrtcpb.Stream{ // no method
 		Id: 1,
-	}, &webrtcpb.RequestHeaders{}), test.ShouldBeNil)
+	}, &webrtcpb.RequestHeaders{}, StreamPriorityNormal), test.ShouldBeNil)
 
 	<-messagesRead
 
@@ -121,13 +123,13 @@ func TestWebRTCServerChannel(t *testing.T) {
 		Id: 2,
 	}, &webrtcpb.RequestHeaders{
 		Method: "/proto.rpc.webrtc.v1.SignalingService/Call",
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	test.That(t, clientCh.writeHeaders(&webrtcpb.Stream{
 		Id: 2,
 	}, &webrtcpb.RequestHeaders{
 		Method: "/proto.rpc.webrtc.v1.SignalingService/Call",
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	<-messagesRead
 
@@ -179,7 +181,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 		Metadata: metadataToProto(metadata.MD{
 			"rpc-host": []string{"yeehaw"},
 		}),
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	reqMd, err := proto.Marshal(&webrtcpb.CallRequest{Sdp: "hello"})
 	test.That(t, err, test.ShouldBeNil)
@@ -193,7 +195,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 			Eom:  true,
 		},
 		Eos: true,
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	offer, err := signalServer.callQueue.RecvOffer(context.Background(), []string{"yeehaw"})
 	test.That(t, err, test.ShouldBeNil)
@@ -230,7 +232,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 		Metadata: metadataToProto(metadata.MD{
 			"rpc-host": []string{"yeehaw"},
 		}),
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	test.That(t, clientCh.writeMessage(&webrtcpb.Stream{
 		Id: 4,
@@ -241,7 +243,7 @@ func TestWebRTCServerChannel(t *testing.T) {
 			Eom:  true,
 		},
 		Eos: true,
-	}), test.ShouldBeNil)
+	}, StreamPriorityNormal), test.ShouldBeNil)
 
 	offer, err = signalServer.callQueue.RecvOffer(context.Background(), []string{"yeehaw"})
 	test.That(t, err, test.ShouldBeNil)
@@ -325,8 +327,8 @@ func TestWebRTCServerChannelResetStream(t *testing.T) {
 			Metadata: metadataToProto(metadata.MD{
 				"rpc-host": []string{"yeehaw"},
 			}),
-		}), test.ShouldBeNil)
-		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}), test.ShouldBeNil)
+		}, StreamPriorityNormal), test.ShouldBeNil)
+		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}, StreamPriorityNormal), test.ShouldBeNil)
 		<-messagesRead
 	})
 	t.Run("reset stream in middle of message", func(t *testing.T) {
@@ -357,7 +359,7 @@ func TestWebRTCServerChannelResetStream(t *testing.T) {
 			Metadata: metadataToProto(metadata.MD{
 				"rpc-host": []string{"yeehaw"},
 			}),
-		}), test.ShouldBeNil)
+		}, StreamPriorityNormal), test.ShouldBeNil)
 
 		reqMd, err := proto.Marshal(&webrtcpb.CallRequest{Sdp: "hello"})
 		test.That(t, err, test.ShouldBeNil)
@@ -371,8 +373,8 @@ func TestWebRTCServerChannelResetStream(t *testing.T) {
 				Eom:  false,
 			},
 			Eos: false,
-		}), test.ShouldBeNil)
-		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}), test.ShouldBeNil)
+		}, StreamPriorityNormal), test.ShouldBeNil)
+		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}, StreamPriorityNormal), test.ShouldBeNil)
 		<-messagesRead
 	})
 	t.Run("reset stream after message", func(t *testing.T) {
@@ -423,7 +425,7 @@ func TestWebRTCServerChannelResetStream(t *testing.T) {
 			Metadata: metadataToProto(metadata.MD{
 				"rpc-host": []string{"yeehaw"},
 			}),
-		}), test.ShouldBeNil)
+		}, StreamPriorityNormal), test.ShouldBeNil)
 
 		reqMd, err := proto.Marshal(&webrtcpb.CallRequest{Sdp: "hello"})
 		test.That(t, err, test.ShouldBeNil)
@@ -437,14 +439,225 @@ func TestWebRTCServerChannelResetStream(t *testing.T) {
 				Eom:  true,
 			},
 			Eos: true,
-		}), test.ShouldBeNil)
+		}, StreamPriorityNormal), test.ShouldBeNil)
 
 		offer, err := signalServer.callQueue.RecvOffer(context.Background(), []string{"yeehaw"})
 		test.That(t, err, test.ShouldBeNil)
 		answererSDP := "world"
 		test.That(t, offer.AnswererRespond(context.Background(), WebRTCCallAnswer{InitialSDP: &answererSDP}), test.ShouldBeNil)
-		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}), test.ShouldBeNil)
+		test.That(t, clientCh.writeReset(&webrtcpb.Stream{Id: 1}, StreamPriorityNormal), test.ShouldBeNil)
 
 		<-messagesRead
 	})
 }
+
+func TestWebRTCServerPeerConnectionLimit(t *testing.T) {
+	testutils.SkipUnlessInternet(t)
+	logger := golog.NewTestLogger(t)
+	pc1, pc2, dc1, dc2 := setupWebRTCPeers(t)
+
+	clientCh := newWebRTCClientChannel(pc1, dc1, logger, nil, nil)
+	defer func() {
+		test.That(t, clientCh.Close(), test.ShouldBeNil)
+	}()
+
+	server := newWebRTCServer(logger)
+	defer server.Stop()
+	server.admission.max = 1
+	release, err := server.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+
+	serverCh := server.NewChannel(pc2, dc2, nil)
+	release()
+	<-clientCh.Ready()
+	<-serverCh.Ready()
+
+	_, err = server.admitPeer()
+	test.That(t, err, test.ShouldEqual, errTooManyPeerConnections)
+	test.That(t, status.Code(err), test.ShouldEqual, codes.ResourceExhausted)
+	isClosed, _ := serverCh.Closed()
+	test.That(t, isClosed, test.ShouldBeFalse)
+
+	server.admission.policy = PeerConnectionLimitEvictIdle
+	var wg sync.WaitGroup
+	admitted := make(chan func(), 10)
+	for i := 0; i < cap(admitted); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, err := server.admitPeer(); err == nil {
+				admitted <- release
+			}
+		}()
+	}
+	wg.Wait()
+	close(admitted)
+	// only one offer may take the evicted peer's slot.
+	test.That(t, admitted, test.ShouldHaveLength, 1)
+	isClosed, reason := serverCh.Closed()
+	test.That(t, isClosed, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, errPeerEvicted)
+	server.admission.mu.Lock()
+	test.That(t, server.admission.admitted, test.ShouldBeEmpty)
+	server.admission.mu.Unlock()
+
+	_, err = server.admitPeer()
+	test.That(t, err, test.ShouldEqual, errTooManyPeerConnections)
+	(<-admitted)()
+	release, err = server.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	release()
+}
+
+func TestWebRTCServerPeerConnectionLimitAcrossHosts(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	server := newWebRTCServer(logger)
+	defer server.Stop()
+	server.admission.max = 2
+	hostA := server.newHostServer("a")
+	defer hostA.Stop()
+	hostB := server.newHostServer("b")
+	defer hostB.Stop()
+
+	releaseA, err := hostA.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	releaseB, err := hostB.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	for _, srv := range []*webrtcServer{server, hostA, hostB} {
+		_, err = srv.admitPeer()
+		test.That(t, err, test.ShouldEqual, errTooManyPeerConnections)
+	}
+	releaseA()
+	releaseB()
+
+	testutils.SkipUnlessInternet(t)
+	pcA1, pcA2, dcA1, dcA2 := setupWebRTCPeers(t)
+	pcB1, pcB2, dcB1, dcB2 := setupWebRTCPeers(t)
+	clientChA := newWebRTCClientChannel(pcA1, dcA1, logger, nil, nil)
+	defer func() {
+		test.That(t, clientChA.Close(), test.ShouldBeNil)
+	}()
+	clientChB := newWebRTCClientChannel(pcB1, dcB1, logger, nil, nil)
+	defer func() {
+		test.That(t, clientChB.Close(), test.ShouldBeNil)
+	}()
+
+	releaseA, err = hostA.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	serverChA := hostA.NewChannel(pcA2, dcA2, nil)
+	releaseA()
+	releaseB, err = hostB.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	serverChB := hostB.NewChannel(pcB2, dcB2, nil)
+	releaseB()
+	<-serverChA.Ready()
+	<-serverChB.Ready()
+
+	_, err = hostA.admitPeer()
+	test.That(t, err, test.ShouldEqual, errTooManyPeerConnections)
+
+	// the idlest peer is evicted even when it belongs to another host.
+	serverChB.markActivity()
+	server.admission.policy = PeerConnectionLimitEvictIdle
+	release, err := hostB.admitPeer()
+	test.That(t, err, test.ShouldBeNil)
+	defer release()
+	isClosed, reason := serverChA.Closed()
+	test.That(t, isClosed, test.ShouldBeTrue)
+	test.That(t, reason, test.ShouldEqual, errPeerEvicted)
+	isClosed, _ = serverChB.Closed()
+	test.That(t, isClosed, test.ShouldBeFalse)
+}
+
+func TestWebRTCServerAdmitPeerConcurrently(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	server := newWebRTCServer(logger)
+	defer server.Stop()
+	server.admission.max = 3
+
+	var wg sync.WaitGroup
+	admitted := make(chan func(), 20)
+	for i := 0; i < cap(admitted); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, err := server.admitPeer(); err == nil {
+				admitted <- release
+			}
+		}()
+	}
+	wg.Wait()
+	close(admitted)
+	test.That(t, admitted, test.ShouldHaveLength, server.admission.max)
+
+	for release := range admitted {
+		release()
+		// releasing more than once frees only one slot.
+		release()
+	}
+	server.admission.mu.Lock()
+	test.That(t, server.admission.pending, test.ShouldEqual, 0)
+	server.admission.mu.Unlock()
+}
+
+func TestRequestDeadline(t *testing.T) {
+	receivedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	withDeadline := func(deadline time.Time) metadata.MD {
+		return metadata.MD{webrtcDeadlineMetadataField: []string{deadline.Format(time.RFC3339Nano)}}
+	}
+
+	t.Run("no timeout", func(t *testing.T) {
+		_, ok := requestDeadline(receivedAt, nil, nil)
+		test.That(t, ok, test.ShouldBeFalse)
+		_, ok = requestDeadline(receivedAt, durationpb.New(0), withDeadline(receivedAt))
+		test.That(t, ok, test.ShouldBeFalse)
+	})
+
+	t.Run("relative only", func(t *testing.T) {
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(5*time.Second), nil)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(receivedAt.Add(5*time.Second)), test.ShouldBeTrue)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(-1), nil)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Before(receivedAt), test.ShouldBeTrue)
+	})
+
+	t.Run("absolute accounts for transit", func(t *testing.T) {
+		// the call spent 200ms getting here
+		abs := receivedAt.Add(5*time.Second - 200*time.Millisecond)
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(5*time.Second), withDeadline(abs))
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(abs), test.ShouldBeTrue)
+	})
+
+	t.Run("absolute later than relative", func(t *testing.T) {
+		abs := receivedAt.Add(5*time.Second + 500*time.Millisecond)
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(5*time.Second), withDeadline(abs))
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(receivedAt.Add(5*time.Second)), test.ShouldBeTrue)
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		// the client's clock is an hour behind ours
+		abs := receivedAt.Add(5*time.Second - time.Hour)
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(5*time.Second), withDeadline(abs))
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(receivedAt.Add(5*time.Second)), test.ShouldBeTrue)
+
+		// or an hour ahead
+		abs = receivedAt.Add(5*time.Second + time.Hour)
+		deadline, ok = requestDeadline(receivedAt, durationpb.New(5*time.Second), withDeadline(abs))
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(receivedAt.Add(5*time.Second)), test.ShouldBeTrue)
+	})
+
+	t.Run("malformed absolute", func(t *testing.T) {
+		md := metadata.MD{webrtcDeadlineMetadataField: []string{"soon"}}
+		deadline, ok := requestDeadline(receivedAt, durationpb.New(5*time.Second), md)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, deadline.Equal(receivedAt.Add(5*time.Second)), test.ShouldBeTrue)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strconv"
 	"sync/atomic"
 
 	"github.com/edaniels/golog"
@@ -19,6 +20,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"go.viam.com/utils"
+	"go.viam.com/utils/errs"
 	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
 )
 
@@ -39,6 +41,16 @@ type webrtcServerStream struct {
 	header          metadata.MD
 	trailer         metadata.MD
 	sendClosed      atomic.Bool
+	// compressor, if set, compresses every message sent on this stream.
+	compressor Compressor
+	// sendWindow, if set, flow controls the messages sent on this stream.
+	sendWindow *webrtcSendWindow
+	// priority is the priority the client tagged this stream with.
+	priority StreamPriority
+	// maxRecvMsgSize and maxSendMsgSize, if set, limit the size of the messages received
+	// and sent on this stream.
+	maxRecvMsgSize int
+	maxSendMsgSize int
 }
 
 // newWebRTCServerStream creates a gRPC stream from the given server channel with a
@@ -191,13 +203,21 @@ func (s *webrtcServerStream) SendMsg(m interface{}) (err error) {
 	if err != nil {
 		return err
 	}
+	if s.maxSendMsgSize != 0 && len(data) > s.maxSendMsgSize {
+		return status.Errorf(codes.ResourceExhausted, "trying to send message larger than max (%d vs. %d)", len(data), s.maxSendMsgSize)
+	}
+	if s.compressor != nil {
+		if data, err = s.compressor.Compress(data); err != nil {
+			return err
+		}
+	}
 
 	if len(data) == 0 {
 		return s.ch.writeMessage(s.stream, &webrtcpb.ResponseMessage{
 			PacketMessage: &webrtcpb.PacketMessage{
 				Eom: true,
 			},
-		})
+		}, s.priority)
 	}
 
 	for len(data) != 0 {
@@ -212,9 +232,14 @@ func (s *webrtcServerStream) SendMsg(m interface{}) (err error) {
 		if len(data) == 0 {
 			packet.Eom = true
 		}
+		if s.sendWindow != nil {
+			if err := s.sendWindow.take(s.ctx, len(packet.Data)); err != nil {
+				return err
+			}
+		}
 		if err := s.ch.writeMessage(s.stream, &webrtcpb.ResponseMessage{
 			PacketMessage: packet,
-		}); err != nil {
+		}, s.priority); err != nil {
 			return err
 		}
 	}
@@ -318,6 +343,14 @@ func (s *webrtcServerStream) processMessage(msg *webrtcpb.RequestMessage) {
 			s.closeWithError(errors.New("expected RequestMessage.PacketMessgae to not be nil but it was"), false)
 			return
 		}
+		if size := len(msg.PacketMessage.Data) + s.packetBuf.Len(); s.maxRecvMsgSize != 0 && size > s.maxRecvMsgSize {
+			s.packetBuf.Reset()
+			err := status.Errorf(codes.ResourceExhausted, "received message larger than max (%d vs. %d)", size, s.maxRecvMsgSize)
+			if closeErr := s.closeWithSendError(err); closeErr != nil {
+				s.logger.Errorw("error closing stream", "error", closeErr)
+			}
+			return
+		}
 		data, eop := s.webrtcBaseStream.processMessage(msg.PacketMessage)
 		if !eop {
 			return
@@ -383,22 +416,35 @@ func (s *webrtcServerStream) closeWithSendError(err error) (writeErr error) {
 	return s.ch.writeTrailers(s.stream, &webrtcpb.ResponseTrailers{
 		Status:   respStatus.Proto(),
 		Metadata: metadataToProto(s.trailer),
-	})
+	}, s.priority)
 }
 
 func (s *webrtcServerStream) writeHeaders() error {
 	if !s.headersWritten.CompareAndSwap(false, true) {
 		return nil
 	}
-	protoHeaders := metadataToProto(s.header)
+	header := s.header
+	if s.compressor != nil || s.sendWindow != nil {
+		header = header.Copy()
+	}
+	if s.compressor != nil {
+		header.Set(webrtcEncodingMetadataField, s.compressor.Name())
+	}
+	if s.sendWindow != nil {
+		header.Set(webrtcWindowSizeMetadataField, strconv.Itoa(s.sendWindow.size))
+	}
+	protoHeaders := metadataToProto(header)
 	return s.ch.writeHeaders(s.stream, &webrtcpb.ResponseHeaders{
 		Metadata: protoHeaders,
-	})
+	}, s.priority)
 }
 
 // ErrorToStatus converts an error to a gRPC status. A nil
 // error becomes a successful status.
 func ErrorToStatus(err error) *status.Status {
+	if s, ok := errs.ToStatus(err); ok {
+		return s
+	}
 	respStatus := status.FromContextError(err)
 	if respStatus.Code() == codes.Unknown {
 		respStatus = status.Convert(err)
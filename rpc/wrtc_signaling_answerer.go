@@ -12,6 +12,7 @@ import (
 	"github.com/edaniels/golog"
 	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
 	"go.uber.org/multierr"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -29,15 +30,15 @@ const testDelayAnswererNegotiationVar = "TEST_DELAY_ANSWERER_NEGOTIATION"
 type webrtcSignalingAnswerer struct {
 	startStopMu sync.Mutex // startStopMu guards the Start and Stop methods so they do not happen concurrently.
 
-	address                 string
-	hosts                   []string
-	server                  *webrtcServer
-	dialOpts                []DialOption
-	webrtcConfig            webrtc.Configuration
-	activeBackgroundWorkers sync.WaitGroup
-	cancelBackgroundWorkers func()
-	closeCtx                context.Context
-	logger                  golog.Logger
+	address      string
+	hosts        []string
+	server       *webrtcServer
+	hostServers  map[string]*webrtcServer
+	dialOpts     []DialOption
+	webrtcConfig webrtc.Configuration
+	workers      *utils.StoppableWorkers
+	closeCtx     context.Context
+	logger       golog.Logger
 }
 
 // newWebRTCSignalingAnswerer makes an answerer that will connect to and listen for calls at the given
@@ -55,32 +56,97 @@ func newWebRTCSignalingAnswerer(
 	dialOptsCopy := make([]DialOption, len(dialOpts))
 	copy(dialOptsCopy, dialOpts)
 	dialOptsCopy = append(dialOptsCopy, WithWebRTCOptions(DialWebRTCOptions{Disable: true}))
-	closeCtx, cancel := context.WithCancel(context.Background())
+	workers := utils.NewStoppableWorkersWithContext(context.Background(), logger)
 	return &webrtcSignalingAnswerer{
-		address:                 address,
-		hosts:                   hosts,
-		server:                  server,
-		dialOpts:                dialOptsCopy,
-		webrtcConfig:            webrtcConfig,
-		cancelBackgroundWorkers: cancel,
-		closeCtx:                closeCtx,
-		logger:                  logger,
+		address:      address,
+		hosts:        hosts,
+		server:       server,
+		dialOpts:     dialOptsCopy,
+		webrtcConfig: webrtcConfig,
+		workers:      workers,
+		closeCtx:     workers.Context(),
+		logger:       logger,
 	}
 }
 
 const (
-	defaultMaxAnswerers   = 2
-	answererReconnectWait = time.Second
+	defaultMaxAnswerers      = 2
+	answererReconnectWait    = time.Second
+	answererMaxReconnectWait = 10 * time.Second
 )
 
+// answererReconnectPolicy returns how to keep reconnecting an answer client that broke
+// with the given error: until it works or the answerer stops, backing off from
+// answererReconnectWait up to answererMaxReconnectWait.
+func answererReconnectPolicy(logger golog.Logger, answerErr error) utils.RetryPolicy {
+	return utils.RetryPolicy{
+		InitialBackoff: answererReconnectWait,
+		MaxBackoff:     answererMaxReconnectWait,
+		OnAttempt: func(attempt utils.RetryAttempt) {
+			if attempt.Err == nil {
+				return
+			}
+			logger.Errorw("error reconnecting answer client", "error", answerErr, "reconnect_err", attempt.Err)
+			if attempt.Retrying {
+				logger.Debugw("reconnecting answer client", "in", attempt.Wait.String())
+			}
+		},
+	}
+}
+
+// serveHost routes peers answered for the given host to the given server instead of
+// the answerer's own server. It returns false if the answerer does not answer for the
+// host. It must be called before Start.
+func (ans *webrtcSignalingAnswerer) serveHost(host string, server *webrtcServer) bool {
+	ans.startStopMu.Lock()
+	defer ans.startStopMu.Unlock()
+	for _, existing := range ans.hosts {
+		if existing != host {
+			continue
+		}
+		if ans.hostServers == nil {
+			ans.hostServers = map[string]*webrtcServer{}
+		}
+		ans.hostServers[host] = server
+		return true
+	}
+	return false
+}
+
+// An answererRoute is a set of hosts answered for over a single signaling stream along
+// with the server that peers answered for those hosts are handed to.
+type answererRoute struct {
+	hosts  []string
+	server *webrtcServer
+}
+
+// routes returns how the hosts of the answerer are split up. Hosts without a server of
+// their own share a route to the answerer's server.
+func (ans *webrtcSignalingAnswerer) routes() []answererRoute {
+	var shared []string
+	var routes []answererRoute
+	for _, host := range ans.hosts {
+		if server, ok := ans.hostServers[host]; ok {
+			routes = append(routes, answererRoute{hosts: []string{host}, server: server})
+			continue
+		}
+		shared = append(shared, host)
+	}
+	if len(shared) != 0 {
+		routes = append([]answererRoute{{hosts: shared, server: ans.server}}, routes...)
+	}
+	return routes
+}
+
 // Start connects to the signaling service and listens forever until instructed to stop
 // via Stop.
 func (ans *webrtcSignalingAnswerer) Start() {
 	ans.startStopMu.Lock()
 	defer ans.startStopMu.Unlock()
 
+	routes := ans.routes()
 	for i := 0; i < defaultMaxAnswerers; i++ {
-		ans.startAnswerer()
+		ans.startAnswerer(routes)
 	}
 }
 
@@ -100,17 +166,24 @@ func checkExceptionalError(err error) error {
 	return err
 }
 
-func (ans *webrtcSignalingAnswerer) startAnswerer() {
+// startAnswerer connects to the signaling service and answers for every route over
+// that one connection, each with its own answering stream.
+func (ans *webrtcSignalingAnswerer) startAnswerer(routes []answererRoute) {
 	var connInUse ClientConn
 	var connMu sync.Mutex
-	reconnect := func() error {
+	// reconnect replaces the given broken connection. If another route already replaced
+	// it, the replacement is used as is.
+	reconnect := func(broken ClientConn) error {
 		connMu.Lock()
-		conn := connInUse
-		connMu.Unlock()
-		if conn != nil {
-			if err := checkExceptionalError(conn.Close()); err != nil {
+		defer connMu.Unlock()
+		if connInUse != broken {
+			return nil
+		}
+		if connInUse != nil {
+			if err := checkExceptionalError(connInUse.Close()); err != nil {
 				ans.logger.Errorw("error closing existing signaling connection", "error", err)
 			}
+			connInUse = nil
 		}
 		setupCtx, timeoutCancel := context.WithTimeout(ans.closeCtx, 10*time.Second)
 		defer timeoutCancel()
@@ -118,90 +191,99 @@ func (ans *webrtcSignalingAnswerer) startAnswerer() {
 		if err != nil {
 			return err
 		}
-		connMu.Lock()
 		connInUse = conn
-		connMu.Unlock()
 		return nil
 	}
-	newAnswer := func() (webrtcpb.SignalingService_AnswerClient, error) {
+	currentConn := func() ClientConn {
 		connMu.Lock()
-		conn := connInUse
-		connMu.Unlock()
+		defer connMu.Unlock()
+		return connInUse
+	}
+	// newAnswer returns a new answering stream for the route along with the connection
+	// it is on, if any.
+	newAnswer := func(route answererRoute) (webrtcpb.SignalingService_AnswerClient, ClientConn, error) {
+		conn := currentConn()
 		if conn == nil {
-			if err := reconnect(); err != nil {
-				return nil, err
+			if err := reconnect(nil); err != nil {
+				return nil, nil, err
 			}
+			conn = currentConn()
 		}
-		connMu.Lock()
-		conn = connInUse
-		connMu.Unlock()
 		client := webrtcpb.NewSignalingServiceClient(conn)
 		md := metadata.New(nil)
-		md.Append(RPCHostMetadataField, ans.hosts...)
+		md.Append(RPCHostMetadataField, route.hosts...)
 		answerCtx := metadata.NewOutgoingContext(ans.closeCtx, md)
 		answerClient, err := client.Answer(answerCtx)
 		if err != nil {
-			return nil, multierr.Combine(err, conn.Close())
+			return nil, conn, err
 		}
-		return answerClient, nil
+		return answerClient, conn, nil
 	}
-	ans.activeBackgroundWorkers.Add(1)
-	utils.ManagedGo(func() {
-		var client webrtcpb.SignalingService_AnswerClient
-		defer func() {
-			if client == nil {
-				return
-			}
-			if err := client.CloseSend(); err != nil {
-				ans.logger.Errorw("error closing send side of answering client", "error", err)
-			}
-		}()
-		for {
-			select {
-			case <-ans.closeCtx.Done():
-				return
-			default:
-			}
-			var err error
-			client, err = newAnswer()
-			if err == nil {
-				err = ans.answer(client)
-			}
-			// Exceptional errors represent a broken connection and require reconnecting. Common
-			// errors represent that an operation has failed, but can be safely retried over the
-			// existing connection.
-			if checkExceptionalError(err) == nil {
-				continue
-			}
 
-			ans.logger.Errorw("error answering", "error", err)
+	// routes are answered on their own workers, restarted if they panic, so that the
+	// connection is only closed once they have all returned.
+	routeWorkers := utils.NewStoppableWorkersWithContext(ans.workers.Context(), ans.logger)
+	for _, route := range routes {
+		route := route
+		routeWorkers.AddRestartingWorkers(func(ctx context.Context) {
+			var client webrtcpb.SignalingService_AnswerClient
+			defer func() {
+				if client == nil {
+					return
+				}
+				if err := client.CloseSend(); err != nil {
+					ans.logger.Errorw("error closing send side of answering client", "error", err)
+				}
+			}()
 			for {
-				ans.logger.Debugw("reconnecting answer client", "in", answererReconnectWait.String())
-				if !utils.SelectContextOrWait(ans.closeCtx, answererReconnectWait) {
+				select {
+				case <-ctx.Done():
 					return
+				default:
+				}
+				var conn ClientConn
+				var err error
+				client, conn, err = newAnswer(route)
+				if err == nil {
+					err = ans.answer(client, route)
 				}
-				if connectErr := reconnect(); connectErr != nil {
-					ans.logger.Errorw("error reconnecting answer client", "error", err, "reconnect_err", connectErr)
+				// Exceptional errors represent a broken connection and require reconnecting. Common
+				// errors represent that an operation has failed, but can be safely retried over the
+				// existing connection.
+				if checkExceptionalError(err) == nil {
 					continue
 				}
+
+				ans.logger.Errorw("error answering", "error", err, "hosts", route.hosts)
+				ans.logger.Debugw("reconnecting answer client", "in", answererReconnectWait.String())
+				if !utils.SelectContextOrWaitUntil(ctx, time.Now().Add(answererReconnectWait)) {
+					return
+				}
+				if utils.Retry(ctx, answererReconnectPolicy(ans.logger, err), func() error {
+					if connectErr := reconnect(conn); connectErr != nil {
+						conn = currentConn()
+						return connectErr
+					}
+					return nil
+				}) != nil {
+					// only a done context stops reconnecting.
+					return
+				}
 				ans.logger.Debug("reconnected answer client")
-				break
-			}
-		}
-	}, func() {
-		defer ans.activeBackgroundWorkers.Done()
-		defer func() {
-			connMu.Lock()
-			conn := connInUse
-			connMu.Unlock()
-			if conn == nil {
-				return
 			}
+		})
+	}
 
-			if err := checkExceptionalError(conn.Close()); err != nil {
-				ans.logger.Errorw("error closing signaling connection", "error", err)
-			}
-		}()
+	ans.workers.AddWorkers(func(ctx context.Context) {
+		<-ctx.Done()
+		routeWorkers.Stop()
+		conn := currentConn()
+		if conn == nil {
+			return
+		}
+		if err := checkExceptionalError(conn.Close()); err != nil {
+			ans.logger.Errorw("error closing signaling connection", "error", err)
+		}
 	})
 }
 
@@ -210,15 +292,14 @@ func (ans *webrtcSignalingAnswerer) Stop() {
 	ans.startStopMu.Lock()
 	defer ans.startStopMu.Unlock()
 
-	ans.cancelBackgroundWorkers()
-	ans.activeBackgroundWorkers.Wait()
+	ans.workers.Stop()
 }
 
 // answer accepts a single call offer, responds with a corresponding SDP, and
 // attempts to establish a WebRTC connection with the caller via ICE. Once established,
 // the designated WebRTC data channel is passed off to the underlying Server which
 // is then used as the server end of a gRPC connection.
-func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_AnswerClient) (err error) {
+func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_AnswerClient, route answererRoute) (err error) {
 	resp, err := client.Recv()
 	if err != nil {
 		return err
@@ -239,15 +320,50 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 	}
 	init := initStage.Init
 
+	started := time.Now()
+	var successful bool
+	// the caller's trace is not carried through the signaling server, so each answer is its own trace.
+	spanCtx, span := trace.StartSpan(ans.closeCtx, "rpc.webrtc.answer")
+	span.AddAttributes(trace.StringAttribute("uuid", uuid))
+	defer func() {
+		route.server.metrics.negotiationDone(started, successful && err == nil)
+		spanErr := err
+		if spanErr == nil && !successful {
+			spanErr = errors.New("negotiation failed")
+		}
+		endSpan(span, spanErr)
+	}()
+	_, signalingSpan := trace.StartSpan(spanCtx, "rpc.webrtc.signaling")
+	// ending more than once is a no-op.
+	defer signalingSpan.End()
+
+	releasePeerSlot, err := route.server.admitPeer()
+	if err != nil {
+		return client.Send(&webrtcpb.AnswerResponse{
+			Uuid: uuid,
+			Stage: &webrtcpb.AnswerResponse_Error{
+				Error: &webrtcpb.AnswerResponseErrorStage{
+					Status: ErrorToStatus(err).Proto(),
+				},
+			},
+		})
+	}
+	// by the time the answer is done, the peer has either been added to the server or
+	// has failed to connect.
+	defer releasePeerSlot()
+
 	disableTrickle := false
 	if init.OptionalConfig != nil {
 		disableTrickle = init.OptionalConfig.DisableTrickle
 	}
-	pc, dc, err := newPeerConnectionForServer(
+	peerOpts := route.server.peerOpts
+	peerOpts.events = newWebRTCPeerEvents(route.server.onPeerEvent)
+	pc, dc, negotiator, err := newPeerConnectionForServer(
 		ans.closeCtx,
 		init.Sdp,
 		ans.webrtcConfig,
 		disableTrickle,
+		peerOpts,
 		ans.logger,
 	)
 	if err != nil {
@@ -260,7 +376,6 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 			},
 		})
 	}
-	var successful bool
 	defer func() {
 		if !(successful && err == nil) {
 			err = multierr.Combine(err, pc.Close())
@@ -314,6 +429,7 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 				return
 			}
 			if icecandidate != nil {
+				peerOpts.events.candidateAdded(icecandidate.ToJSON(), false)
 				pendingCandidates.Add(1)
 				if icecandidate.Typ == webrtc.ICECandidateTypeHost {
 					waitOneHostOnce.Do(func() {
@@ -322,10 +438,7 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 				}
 			}
 			// must spin off to unblock the ICE gatherer
-			ans.activeBackgroundWorkers.Add(1)
-			utils.PanicCapturingGo(func() {
-				defer ans.activeBackgroundWorkers.Done()
-
+			ans.workers.AddWorkers(func(context.Context) {
 				if icecandidate != nil {
 					defer pendingCandidates.Done()
 				}
@@ -363,7 +476,7 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 			})
 		})
 
-		err = pc.SetLocalDescription(answer)
+		err = peerOpts.setLocalDescription(pc, answer)
 		if err != nil {
 			return err
 		}
@@ -398,8 +511,13 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 		return err
 	}
 	close(initSent)
+	signalingSpan.End()
+
+	_, iceSpan := trace.StartSpan(spanCtx, "rpc.webrtc.ice")
+	defer iceSpan.End()
 
-	serverChannel := ans.server.NewChannel(pc, dc, ans.hosts)
+	serverChannel := route.server.NewChannel(pc, dc, route.hosts)
+	serverChannel.useNegotiator(negotiator)
 
 	if !init.OptionalConfig.DisableTrickle {
 		exchangeCandidates := func() error {
@@ -429,6 +547,7 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 					if err := pc.AddICECandidate(cand); err != nil {
 						return err
 					}
+					peerOpts.events.candidateAdded(cand, true)
 				case *webrtcpb.AnswerRequest_Done:
 					return nil
 				case *webrtcpb.AnswerRequest_Error:
@@ -463,7 +582,9 @@ func (ans *webrtcSignalingAnswerer) answer(client webrtcpb.SignalingService_Answ
 		}
 	}
 
-	if answerErr := doAnswer(); answerErr != nil {
+	answerErr := doAnswer()
+	endSpan(iceSpan, answerErr)
+	if answerErr != nil {
 		var err error
 		sendDoneErrorOnce.Do(func() {
 			err = client.Send(&webrtcpb.AnswerResponse{
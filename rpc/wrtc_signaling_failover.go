@@ -0,0 +1,180 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+)
+
+// signalingFailoverAttemptTimeout bounds how long a single signaling server may take
+// to pass its health check when there are others to fail over to.
+const signalingFailoverAttemptTimeout = 3 * time.Second
+
+// A signalingServerSelector decides the order in which a set of equivalent signaling
+// servers is tried. The last server that worked for a host is tried first; otherwise
+// servers are tried in round-robin order.
+type signalingServerSelector struct {
+	mu          sync.Mutex
+	nextIdx     map[string]int
+	lastWorking map[string]string
+}
+
+// defaultSignalingServerSelector is shared by all dials so that the last working
+// signaling server is remembered across reconnects.
+var defaultSignalingServerSelector = newSignalingServerSelector()
+
+func newSignalingServerSelector() *signalingServerSelector {
+	return &signalingServerSelector{
+		nextIdx:     map[string]int{},
+		lastWorking: map[string]string{},
+	}
+}
+
+func signalingSelectionKey(host string, servers []string) string {
+	return host + "|" + strings.Join(servers, ",")
+}
+
+// order returns the servers in the order they should be tried for the given host.
+func (sel *signalingServerSelector) order(host string, servers []string) []string {
+	if len(servers) <= 1 {
+		return servers
+	}
+	key := signalingSelectionKey(host, servers)
+
+	sel.mu.Lock()
+	defer sel.mu.Unlock()
+	start := -1
+	if lastWorking, ok := sel.lastWorking[key]; ok {
+		for idx, server := range servers {
+			if server == lastWorking {
+				start = idx
+				break
+			}
+		}
+	}
+	if start == -1 {
+		start = sel.nextIdx[key] % len(servers)
+		sel.nextIdx[key] = start + 1
+	}
+
+	ordered := make([]string, 0, len(servers))
+	ordered = append(ordered, servers[start:]...)
+	return append(ordered, servers[:start]...)
+}
+
+// markWorking remembers that the given server worked for the host.
+func (sel *signalingServerSelector) markWorking(host string, servers []string, server string) {
+	if len(servers) <= 1 {
+		return
+	}
+	sel.mu.Lock()
+	defer sel.mu.Unlock()
+	sel.lastWorking[signalingSelectionKey(host, servers)] = server
+}
+
+// markFailed forgets the given server as working for the host if it was.
+func (sel *signalingServerSelector) markFailed(host string, servers []string, server string) {
+	if len(servers) <= 1 {
+		return
+	}
+	key := signalingSelectionKey(host, servers)
+	sel.mu.Lock()
+	defer sel.mu.Unlock()
+	if sel.lastWorking[key] == server {
+		delete(sel.lastWorking, key)
+	}
+}
+
+// dialHealthySignalingServer connects to the first signaling server, out of the primary
+// one and any additional ones, that passes a health check for the given host. The health
+// check is asking the signaling server for its optional WebRTC config.
+func dialHealthySignalingServer(
+	ctx context.Context,
+	primary string,
+	host string,
+	logger golog.Logger,
+	dOpts dialOptions,
+) (ClientConn, *webrtcpb.OptionalWebRTCConfigResponse, error) {
+	servers := append([]string{primary}, dOpts.webrtcOpts.AdditionalSignalingServerAddresses...)
+	ordered := defaultSignalingServerSelector.order(host, servers)
+
+	var errs error
+	allNoSignaler := true
+	for _, server := range ordered {
+		attemptCtx := ctx
+		cancelAttempt := func() {}
+		if len(ordered) > 1 {
+			attemptCtx, cancelAttempt = context.WithTimeout(ctx, signalingFailoverAttemptTimeout)
+		}
+		conn, configResp, err := dialSignalingServerWithHealthCheck(attemptCtx, server, host, logger, dOpts)
+		cancelAttempt()
+		if err == nil {
+			defaultSignalingServerSelector.markWorking(host, servers, server)
+			return conn, configResp, nil
+		}
+		if len(ordered) == 1 {
+			return nil, nil, err
+		}
+
+		defaultSignalingServerSelector.markFailed(host, servers, server)
+		logger.Debugw("signaling server failed health check", "signaling_server", server, "error", err)
+		if !errors.Is(err, ErrNoWebRTCSignaler) {
+			allNoSignaler = false
+		}
+		errs = multierr.Combine(errs, errors.Wrapf(err, "signaling server %q", server))
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+	}
+	if allNoSignaler {
+		return nil, nil, ErrNoWebRTCSignaler
+	}
+	return nil, nil, errs
+}
+
+func dialSignalingServerWithHealthCheck(
+	ctx context.Context,
+	signalingServer string,
+	host string,
+	logger golog.Logger,
+	dOpts dialOptions,
+) (ClientConn, *webrtcpb.OptionalWebRTCConfigResponse, error) {
+	logger.Debugw(
+		"connecting to signaling server",
+		"signaling_server", signalingServer,
+		"host", host,
+	)
+
+	conn, err := dialSignalingServer(ctx, signalingServer, host, logger, dOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Debugw("connected to signaling server", "signaling_server", signalingServer)
+
+	md := metadata.New(map[string]string{RPCHostMetadataField: host})
+	signalCtx := metadata.NewOutgoingContext(ctx, md)
+
+	signalingClient := webrtcpb.NewSignalingServiceClient(conn)
+	configResp, err := signalingClient.OptionalWebRTCConfig(signalCtx, &webrtcpb.OptionalWebRTCConfigRequest{})
+	if err != nil {
+		closeErr := conn.Close()
+		// this would be where we would hit an unimplemented signaler error first.
+		if s, ok := status.FromError(err); ok && (s.Code() == codes.Unimplemented ||
+			(s.Code() == codes.InvalidArgument && s.Message() == hostNotAllowedMsg)) {
+			return nil, nil, multierr.Combine(ErrNoWebRTCSignaler, closeErr)
+		}
+		return nil, nil, multierr.Combine(err, closeErr)
+	}
+	return conn, configResp, nil
+}
@@ -0,0 +1,33 @@
+package rpc
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestSignalingServerSelector(t *testing.T) {
+	sel := newSignalingServerSelector()
+	servers := []string{"one:443", "two:443", "three:443"}
+
+	// single servers are always tried alone
+	test.That(t, sel.order("host", servers[:1]), test.ShouldResemble, servers[:1])
+
+	// round-robin when nothing is known to work
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"one:443", "two:443", "three:443"})
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"two:443", "three:443", "one:443"})
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"three:443", "one:443", "two:443"})
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"one:443", "two:443", "three:443"})
+
+	// the last working server is remembered per host
+	sel.markWorking("host", servers, "three:443")
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"three:443", "one:443", "two:443"})
+	test.That(t, sel.order("host", servers), test.ShouldResemble, []string{"three:443", "one:443", "two:443"})
+	test.That(t, sel.order("other", servers)[0], test.ShouldEqual, "one:443")
+
+	// failing servers are forgotten
+	sel.markFailed("host", servers, "one:443")
+	test.That(t, sel.order("host", servers)[0], test.ShouldEqual, "three:443")
+	sel.markFailed("host", servers, "three:443")
+	test.That(t, sel.order("host", servers)[0], test.ShouldEqual, "two:443")
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 	"google.golang.org/grpc/codes"
@@ -327,7 +328,7 @@ func (srv *WebRTCSignalingServer) Answer(server webrtcpb.SignalingService_Answer
 
 	iceServers, err := srv.additionalICEServers(ctx, hosts, true)
 	if err != nil {
-		return err
+		return multierr.Combine(err, offer.AnswererDone(server.Context()))
 	}
 
 	// initialize
@@ -345,7 +346,8 @@ func (srv *WebRTCSignalingServer) Answer(server webrtcpb.SignalingService_Answer
 			},
 		},
 	}); err != nil {
-		return err
+		// the answerer went away before it saw the offer; let the queue hand it to the next one.
+		return multierr.Combine(err, offer.AnswererDone(server.Context()))
 	}
 
 	offerCtx, offerCtxCancel := context.WithDeadline(ctx, offer.Deadline())
@@ -367,11 +369,32 @@ func (srv *WebRTCSignalingServer) Answer(server webrtcpb.SignalingService_Answer
 				}
 			}
 		}()
+		sendCandidate := func(cand webrtc.ICECandidateInit) error {
+			return server.Send(&webrtcpb.AnswerRequest{
+				Uuid: uuid,
+				Stage: &webrtcpb.AnswerRequest_Update{
+					Update: &webrtcpb.AnswerRequestUpdateStage{
+						Candidate: iceCandidateInitToProto(cand),
+					},
+				},
+			})
+		}
 		for {
 			select {
 			case <-offerCtx.Done():
 				return offerCtx.Err()
 			case <-offer.CallerDone():
+				// candidates replayed from an offer a previous answerer abandoned may still be buffered.
+				for drained := false; !drained; {
+					select {
+					case cand := <-offer.CallerCandidates():
+						if err := sendCandidate(cand); err != nil {
+							return err
+						}
+					default:
+						drained = true
+					}
+				}
 				callerErr := offer.CallerErr()
 				if callerErr != nil {
 					if err := server.Send(&webrtcpb.AnswerRequest{
@@ -387,15 +410,7 @@ func (srv *WebRTCSignalingServer) Answer(server webrtcpb.SignalingService_Answer
 				}
 				return callerErr
 			case cand := <-offer.CallerCandidates():
-				ip := iceCandidateInitToProto(cand)
-				if err := server.Send(&webrtcpb.AnswerRequest{
-					Uuid: uuid,
-					Stage: &webrtcpb.AnswerRequest_Update{
-						Update: &webrtcpb.AnswerRequestUpdateStage{
-							Candidate: ip,
-						},
-					},
-				}); err != nil {
+				if err := sendCandidate(cand); err != nil {
 					return err
 				}
 			}
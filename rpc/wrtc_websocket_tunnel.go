@@ -0,0 +1,480 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"nhooyr.io/websocket"
+
+	"go.viam.com/utils"
+)
+
+// WebSocketTunnelPath is the HTTP path at which a server with
+// WebRTCServerOptions.EnableWebSocketTunnel accepts channels tunneled over WebSockets.
+const WebSocketTunnelPath = "/webrtc/v1/tunnel"
+
+const (
+	// webSocketTunnelSubprotocol identifies the framing used over a WebSocket tunnel, which
+	// is exactly that of a WebRTC data channel: one protobuf request or response per message.
+	webSocketTunnelSubprotocol = "webrtc-tunnel.v1"
+
+	// webSocketTunnelReadLimit is well above the largest message a channel writes.
+	webSocketTunnelReadLimit = 1 << 20
+
+	// webSocketTunnelHostQueryParam is the query parameter naming the host being dialed.
+	webSocketTunnelHostQueryParam = "host"
+)
+
+var errWebSocketTunnelUnavailable = errors.New("WebSocket tunnel is not served")
+
+// A webrtcWebSocketDataChannel carries the messages of a channel over a WebSocket for
+// networks where WebRTC cannot connect, such as those blocking all UDP. Writes are
+// synchronous so nothing is ever buffered.
+type webrtcWebSocketDataChannel struct {
+	conn *websocket.Conn
+	// done is closed once messages are no longer being read.
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	mu        sync.Mutex
+	onOpen    func()
+	onClose   func()
+	onError   func(err error)
+	onMessage func(msg webrtc.DataChannelMessage)
+}
+
+func newWebRTCWebSocketDataChannel(conn *websocket.Conn) *webrtcWebSocketDataChannel {
+	conn.SetReadLimit(webSocketTunnelReadLimit)
+	return &webrtcWebSocketDataChannel{
+		conn: conn,
+		done: make(chan struct{}),
+	}
+}
+
+// start opens the channel and reads messages until the WebSocket is closed. It must be
+// called once the channel's handlers are set.
+func (dc *webrtcWebSocketDataChannel) start() {
+	dc.mu.Lock()
+	onOpen := dc.onOpen
+	dc.mu.Unlock()
+	if onOpen != nil {
+		onOpen()
+	}
+	utils.PanicCapturingGo(dc.readMessages)
+}
+
+func (dc *webrtcWebSocketDataChannel) readMessages() {
+	defer func() {
+		close(dc.done)
+		dc.mu.Lock()
+		onClose := dc.onClose
+		dc.mu.Unlock()
+		if onClose != nil {
+			onClose()
+		}
+	}()
+	for {
+		// reads are never canceled since that closes the WebSocket; closing it instead
+		// ends the read.
+		msgType, data, err := dc.conn.Read(context.Background())
+		if err != nil {
+			if !isWebSocketClosedErr(err) {
+				dc.mu.Lock()
+				onError := dc.onError
+				dc.mu.Unlock()
+				if onError != nil {
+					onError(err)
+				}
+			}
+			return
+		}
+		if msgType != websocket.MessageBinary {
+			continue
+		}
+		dc.mu.Lock()
+		onMessage := dc.onMessage
+		dc.mu.Unlock()
+		if onMessage != nil {
+			onMessage(webrtc.DataChannelMessage{Data: data})
+		}
+	}
+}
+
+// isWebSocketClosedErr returns true if the error is only due to either end closing the
+// WebSocket.
+func isWebSocketClosedErr(err error) bool {
+	return websocket.CloseStatus(err) != -1 || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
+
+// ID returns nil since there is only one channel per WebSocket.
+func (dc *webrtcWebSocketDataChannel) ID() *uint16 {
+	return nil
+}
+
+func (dc *webrtcWebSocketDataChannel) OnOpen(f func()) {
+	dc.mu.Lock()
+	dc.onOpen = f
+	dc.mu.Unlock()
+}
+
+func (dc *webrtcWebSocketDataChannel) OnClose(f func()) {
+	dc.mu.Lock()
+	dc.onClose = f
+	dc.mu.Unlock()
+}
+
+func (dc *webrtcWebSocketDataChannel) OnError(f func(err error)) {
+	dc.mu.Lock()
+	dc.onError = f
+	dc.mu.Unlock()
+}
+
+func (dc *webrtcWebSocketDataChannel) OnMessage(f func(msg webrtc.DataChannelMessage)) {
+	dc.mu.Lock()
+	dc.onMessage = f
+	dc.mu.Unlock()
+}
+
+func (dc *webrtcWebSocketDataChannel) SetBufferedAmountLowThreshold(th uint64) {}
+
+func (dc *webrtcWebSocketDataChannel) OnBufferedAmountLow(f func()) {}
+
+func (dc *webrtcWebSocketDataChannel) BufferedAmount() uint64 {
+	return 0
+}
+
+func (dc *webrtcWebSocketDataChannel) Send(data []byte) error {
+	if err := dc.conn.Write(context.Background(), websocket.MessageBinary, data); err != nil {
+		if isWebSocketClosedErr(err) {
+			return io.ErrClosedPipe
+		}
+		return err
+	}
+	return nil
+}
+
+// Close closes the WebSocket, waiting briefly for the remote end to acknowledge it.
+func (dc *webrtcWebSocketDataChannel) Close() error {
+	dc.closeOnce.Do(func() {
+		select {
+		case <-dc.done:
+			// the remote end already closed it.
+			return
+		default:
+		}
+		if err := dc.conn.Close(websocket.StatusNormalClosure, ""); err != nil && !isWebSocketClosedErr(err) {
+			dc.closeErr = err
+		}
+	})
+	return dc.closeErr
+}
+
+// runWebSocketHeartbeat periodically pings the remote end of the WebSocket tunnel and
+// closes this channel if it does not answer within the timeout. A negative interval
+// disables heartbeats.
+func (ch *webrtcBaseChannel) runWebSocketHeartbeat(dc *webrtcWebSocketDataChannel, interval, timeout time.Duration) {
+	if interval < 0 {
+		return
+	}
+	if interval == 0 {
+		interval = DefaultWebRTCHeartbeatInterval
+	}
+	if timeout == 0 {
+		timeout = DefaultWebRTCHeartbeatTimeout
+	}
+
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return
+	}
+	ch.activeBackgroundWorkers.Add(1)
+	ch.mu.Unlock()
+
+	utils.PanicCapturingGo(func() {
+		defer ch.activeBackgroundWorkers.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ch.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			pingCtx, pingCancel := context.WithTimeout(ch.ctx, timeout)
+			err := dc.conn.Ping(pingCtx)
+			pingCancel()
+			if err == nil {
+				ch.lastHeartbeat.Store(time.Now().UnixNano())
+				continue
+			}
+			if ch.ctx.Err() != nil {
+				return
+			}
+			ch.logger.Warnw(
+				"no heartbeat from peer; closing",
+				"last_heartbeat", ch.LastHeartbeat(),
+				"timeout", timeout.String(),
+				"error", err,
+			)
+			if err := ch.closeWithReason(errPeerHeartbeatTimeout); err != nil {
+				ch.logger.Errorw("error closing channel", "error", err)
+			}
+			return
+		}
+	})
+}
+
+// serveWebSocketTunnel accepts a channel tunneled over a WebSocket and serves it just
+// like a WebRTC data channel. Since there is no signaler vouching for the client, the
+// upgrade request itself must be authenticated when the server is.
+func (ss *simpleServer) serveWebSocketTunnel(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get(webSocketTunnelHostQueryParam)
+
+	var authEntity *EntityInfo
+	var authClaims Claims
+	if !ss.unauthenticated {
+		authedCtx, err := ss.authenticateHTTPRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		entity, ok := ContextAuthEntity(authedCtx)
+		if !ok {
+			http.Error(w, "no authenticated entity", http.StatusUnauthorized)
+			return
+		}
+		authEntity = &entity
+		authClaims, _ = ContextAuthClaims(authedCtx)
+	}
+
+	server := ss.webrtcServer
+	if hostServer, ok := ss.webrtcHostServers[host]; ok {
+		server = hostServer
+	}
+	// tunnels count against the peer connection limit just like WebRTC peers.
+	release, err := server.admitPeer()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:    []string{webSocketTunnelSubprotocol},
+		CompressionMode: websocket.CompressionDisabled,
+	})
+	if err != nil {
+		// Accept has already written the failure.
+		ss.logger.Debugw("error accepting WebSocket tunnel", "error", err)
+		return
+	}
+	if conn.Subprotocol() != webSocketTunnelSubprotocol {
+		utils.UncheckedError(conn.Close(websocket.StatusPolicyViolation, "unsupported subprotocol"))
+		return
+	}
+	// the handler returns right away so that the tunnel is not considered an in-flight
+	// call while draining; its calls are drained like those of any other channel.
+	server.newWebSocketTunnelChannel(newWebRTCWebSocketDataChannel(conn), []string{host}, authEntity, authClaims)
+}
+
+// newWebSocketTunnelChannel binds the given WebSocket tunnel to be serviced as the server
+// end of a gRPC connection. The tunnel must have been admitted with admitPeer and counts
+// against the peer connection limit until it closes.
+func (srv *webrtcServer) newWebSocketTunnelChannel(
+	dataChannel *webrtcWebSocketDataChannel,
+	authAudience []string,
+	authEntity *EntityInfo,
+	authClaims Claims,
+) {
+	serverCh := newWebRTCServerChannel(srv, nil, dataChannel, authAudience, srv.logger.With("transport", "websocket"))
+	serverCh.authEntity = authEntity
+	serverCh.authClaims = authClaims
+	srv.mu.Lock()
+	if srv.ctx.Err() != nil {
+		srv.mu.Unlock()
+		utils.UncheckedError(serverCh.closeWithPeerReason(errServerStopping))
+		return
+	}
+	srv.tunnels[serverCh] = struct{}{}
+	srv.mu.Unlock()
+	srv.admission.add(serverCh)
+	srv.metrics.peerConnectionAdded()
+
+	dataChannel.start()
+	serverCh.runWebSocketHeartbeat(dataChannel, srv.heartbeatInterval, srv.heartbeatTimeout)
+	serverCh.enforceConnectionAge(srv.connectionAge)
+	utils.PanicCapturingGo(func() {
+		<-dataChannel.done
+		utils.UncheckedError(serverCh.Close())
+		srv.mu.Lock()
+		delete(srv.tunnels, serverCh)
+		srv.mu.Unlock()
+		if srv.admission.remove(serverCh) {
+			srv.metrics.peerConnectionRemoved()
+		}
+	})
+}
+
+// dialWebRTCOrWebSocketTunnel dials the host over WebRTC and, unless disabled, falls back
+// to tunneling the same channel over a WebSocket to the signaling server if WebRTC fails
+// to connect.
+func dialWebRTCOrWebSocketTunnel(
+	ctx context.Context,
+	signalingServer string,
+	host string,
+	dOpts dialOptions,
+	logger golog.Logger,
+) (ClientConn, error) {
+	ch, err := dialWebRTC(ctx, signalingServer, host, dOpts, logger)
+	if err == nil {
+		return ch, nil
+	}
+	if dOpts.webrtcOpts.DisableWebSocketFallback || !webrtcFailureMayFallBack(err) || ctx.Err() != nil {
+		return nil, err
+	}
+	logger.Debugw("failed to connect via WebRTC; trying WebSocket tunnel", "error", err)
+	tunnelCh, tunnelErr := dialWebSocketTunnel(ctx, signalingServer, host, dOpts, logger)
+	if tunnelErr != nil {
+		if errors.Is(tunnelErr, errWebSocketTunnelUnavailable) {
+			return nil, err
+		}
+		return nil, multierr.Combine(err, errors.Wrap(tunnelErr, "failed to connect via WebSocket tunnel"))
+	}
+	return tunnelCh, nil
+}
+
+// webrtcFailureMayFallBack returns whether a failure to dial over WebRTC may be worked
+// around with a WebSocket tunnel. Failures to use the signaling server at all would only
+// happen again.
+func webrtcFailureMayFallBack(err error) bool {
+	if errors.Is(err, ErrNoWebRTCSignaler) || errors.Is(err, ErrInsecureWithCredentials) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return false
+	default:
+		return true
+	}
+}
+
+// dialWebSocketTunnel connects to the host through a WebSocket tunnel served by the
+// signaling server. It authenticates with the signaling credentials.
+func dialWebSocketTunnel(
+	ctx context.Context,
+	signalingServer string,
+	host string,
+	dOpts dialOptions,
+	logger golog.Logger,
+) (*webrtcClientChannel, error) {
+	logger = logger.Named("websocket")
+
+	header := http.Header{}
+	accessToken, err := webSocketTunnelAccessToken(ctx, signalingServer, host, dOpts, logger)
+	if err != nil {
+		return nil, err
+	}
+	if accessToken != "" {
+		header.Set(MetadataFieldAuthorization, AuthorizationValuePrefixBearer+accessToken)
+	}
+
+	tunnelURL := url.URL{
+		Scheme:   "wss",
+		Host:     signalingServer,
+		Path:     WebSocketTunnelPath,
+		RawQuery: url.Values{webSocketTunnelHostQueryParam: []string{host}}.Encode(),
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if dOpts.proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return dOpts.proxy(req.URL.Host)
+		}
+	}
+	if dOpts.webrtcOpts.SignalingInsecure {
+		tunnelURL.Scheme = "ws"
+	} else {
+		tlsConfig := dOpts.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = newDefaultTLSConfig()
+		}
+		tlsConfig = tlsConfig.Clone()
+		if dOpts.clientCert != nil {
+			tlsConfig.Certificates = append(tlsConfig.Certificates, *dOpts.clientCert)
+		}
+		// WebSockets are upgraded from HTTP/1.1.
+		tlsConfig.NextProtos = nil
+		transport.TLSClientConfig = tlsConfig
+	}
+	defer transport.CloseIdleConnections()
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, getDefaultOfferDeadline())
+	defer dialCancel()
+	conn, resp, err := websocket.Dial(dialCtx, tunnelURL.String(), &websocket.DialOptions{
+		HTTPClient:      &http.Client{Transport: transport},
+		HTTPHeader:      header,
+		Subprotocols:    []string{webSocketTunnelSubprotocol},
+		CompressionMode: websocket.CompressionDisabled,
+	})
+	if resp != nil && resp.Body != nil {
+		utils.UncheckedError(resp.Body.Close())
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, errWebSocketTunnelUnavailable
+		}
+		return nil, err
+	}
+
+	dataChannel := newWebRTCWebSocketDataChannel(conn)
+	unaryInterceptor, streamInterceptor := webrtcClientInterceptors(dOpts, nil)
+	//nolint:contextcheck
+	clientCh := newWebRTCClientChannel(nil, dataChannel, logger, unaryInterceptor, streamInterceptor)
+	clientCh.tracer = newWebRTCFrameTracer(dOpts.webrtcOpts.FrameTraceWriter).forChannel()
+	dataChannel.start()
+	clientCh.runWebSocketHeartbeat(dataChannel, dOpts.webrtcOpts.HeartbeatInterval, dOpts.webrtcOpts.HeartbeatTimeout)
+	return clientCh, nil
+}
+
+// webSocketTunnelAccessToken returns the access token to present to the signaling server
+// when opening a WebSocket tunnel, if any.
+func webSocketTunnelAccessToken(
+	ctx context.Context,
+	signalingServer string,
+	host string,
+	dOpts dialOptions,
+	logger golog.Logger,
+) (string, error) {
+	if dOpts.authMaterial != "" {
+		return dOpts.authMaterial, nil
+	}
+	if dOpts.webrtcOpts.SignalingCreds.Type == "" && dOpts.webrtcOpts.SignalingExternalAuthAuthMaterial == "" {
+		return "", nil
+	}
+	conn, err := dialSignalingServer(ctx, signalingServer, host, logger, dOpts)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		utils.UncheckedError(conn.Close())
+	}()
+	authConn, ok := conn.(ClientConnAuthenticator)
+	if !ok {
+		return "", nil
+	}
+	return authConn.Authenticate(ctx)
+}
@@ -0,0 +1,310 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/pion/webrtc/v3"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"nhooyr.io/websocket"
+
+	pb "go.viam.com/utils/proto/rpc/examples/echo/v1"
+	echoserver "go.viam.com/utils/rpc/examples/echo/server"
+	"go.viam.com/utils/testutils"
+)
+
+// blockedWebRTCOptions returns options for dialing the given signaling server over WebRTC
+// as if UDP were blocked.
+func blockedWebRTCOptions(signalingAddress string) DialWebRTCOptions {
+	return DialWebRTCOptions{
+		SignalingServerAddress: signalingAddress,
+		SignalingInsecure:      true,
+		SignalingCreds:         Credentials{Type: "fake", Payload: "sosecret"},
+		OnLocalDescription: func(desc webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+			return desc, errors.New("UDP is blocked")
+		},
+	}
+}
+
+func TestWebSocketTunnel(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"yeehaw"}, "sosecret")),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+			EnableWebSocketTunnel:  true,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	es := echoserver.Server{
+		MustContextAuthEntity: func(ctx context.Context) echoserver.RPCEntityInfo {
+			ent := MustContextAuthEntity(ctx)
+			return echoserver.RPCEntityInfo{
+				Entity: ent.Entity,
+				Data:   ent.Data,
+			}
+		},
+	}
+	es.SetAuthorized(true)
+	es.SetExpectedAuthEntity("yeehaw")
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &es), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	stopped := false
+	defer func() {
+		if !stopped {
+			test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+			test.That(t, <-errChan, test.ShouldBeNil)
+		}
+	}()
+
+	dial := func(t *testing.T, webrtcOpts DialWebRTCOptions) (ClientConn, error) {
+		t.Helper()
+		return Dial(context.Background(), "yeehaw", logger,
+			WithDisableDirectGRPC(),
+			WithDialMulticastDNSOptions(DialMulticastDNSOptions{Disable: true}),
+			WithWebRTCOptions(webrtcOpts),
+		)
+	}
+
+	t.Run("falls back when WebRTC fails", func(t *testing.T) {
+		conn, err := dial(t, blockedWebRTCOptions(listener.Addr().String()))
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		tunnelCh, ok := conn.(*webrtcClientChannel)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, tunnelCh.peerConn, test.ShouldBeNil)
+
+		client := pb.NewEchoServiceClient(conn)
+		echoResp, err := client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, echoResp.GetMessage(), test.ShouldEqual, "hello")
+
+		echoMultipleClient, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hello"})
+		test.That(t, err, test.ShouldBeNil)
+		var received string
+		for {
+			resp, err := echoMultipleClient.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			test.That(t, err, test.ShouldBeNil)
+			received += resp.GetMessage()
+		}
+		test.That(t, received, test.ShouldEqual, "hello")
+	})
+
+	t.Run("fallback disabled", func(t *testing.T) {
+		webrtcOpts := blockedWebRTCOptions(listener.Addr().String())
+		webrtcOpts.DisableWebSocketFallback = true
+		_, err := dial(t, webrtcOpts)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "UDP is blocked")
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		tunnelURL := fmt.Sprintf("ws://%s%s?host=yeehaw", listener.Addr().String(), WebSocketTunnelPath)
+		_, resp, err := websocket.Dial(context.Background(), tunnelURL, &websocket.DialOptions{
+			Subprotocols: []string{webSocketTunnelSubprotocol},
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, resp, test.ShouldNotBeNil)
+		test.That(t, resp.Body.Close(), test.ShouldBeNil)
+		test.That(t, resp.StatusCode, test.ShouldEqual, http.StatusUnauthorized)
+
+		webrtcOpts := blockedWebRTCOptions(listener.Addr().String())
+		webrtcOpts.SignalingCreds.Payload = "wrong"
+		_, err = dial(t, webrtcOpts)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("closed when the server stops", func(t *testing.T) {
+		conn, err := dial(t, blockedWebRTCOptions(listener.Addr().String()))
+		test.That(t, err, test.ShouldBeNil)
+		defer func() {
+			test.That(t, conn.Close(), test.ShouldBeNil)
+		}()
+		tunnelCh := conn.(*webrtcClientChannel)
+
+		stopped = true
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			closed, _ := tunnelCh.Closed()
+			test.That(tb, closed, test.ShouldBeTrue)
+		})
+		_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
+
+func TestWebSocketTunnelNotServed(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAuthHandler("fake", MakeSimpleAuthHandler([]string{"yeehaw"}, "sosecret")),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	// the original error is kept since there is nothing to fall back to.
+	_, err = Dial(context.Background(), "yeehaw", logger,
+		WithDisableDirectGRPC(),
+		WithDialMulticastDNSOptions(DialMulticastDNSOptions{Disable: true}),
+		WithWebRTCOptions(blockedWebRTCOptions(listener.Addr().String())),
+	)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "UDP is blocked")
+	test.That(t, err.Error(), test.ShouldNotContainSubstring, "WebSocket")
+}
+
+func TestWebSocketTunnelAuthScopes(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	key, secret, err := NewAPIKey([]string{"/" + pb.EchoService_ServiceDesc.ServiceName + "/Echo"}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAPIKeyAuthHandler(NewMemoryAPIKeyStore(key)),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+			EnableWebSocketTunnel:  true,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	// calls over the tunnel are limited to the scopes of the key that opened it.
+	conn, err := dialWebSocketTunnel(context.Background(), listener.Addr().String(), "yeehaw", dialOptions{
+		webrtcOpts: DialWebRTCOptions{
+			SignalingInsecure:   true,
+			SignalingAuthEntity: key.ID,
+			SignalingCreds:      Credentials{Type: CredentialsTypeAPIKey, Payload: secret},
+		},
+		webrtcOptsSet: true,
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}()
+
+	client := pb.NewEchoServiceClient(conn)
+	_, err = client.Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	echoMultipleClient, err := client.EchoMultiple(context.Background(), &pb.EchoMultipleRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echoMultipleClient.Recv()
+	test.That(t, status.Code(err), test.ShouldEqual, codes.PermissionDenied)
+}
+
+func TestWebSocketTunnelPeerConnectionLimit(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+
+	key, secret, err := NewAPIKey(nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	rpcServer, err := NewServer(
+		logger,
+		WithDisableMulticastDNS(),
+		WithAPIKeyAuthHandler(NewMemoryAPIKeyStore(key)),
+		WithWebRTCServerOptions(WebRTCServerOptions{
+			Enable:                 true,
+			InternalSignalingHosts: []string{"yeehaw"},
+			EnableWebSocketTunnel:  true,
+			MaxPeerConnections:     1,
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rpcServer.RegisterServiceServer(context.Background(), &pb.EchoService_ServiceDesc, &echoserver.Server{}), test.ShouldBeNil)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+		test.That(t, <-errChan, test.ShouldBeNil)
+	}()
+
+	dial := func() (*webrtcClientChannel, error) {
+		return dialWebSocketTunnel(context.Background(), listener.Addr().String(), "yeehaw", dialOptions{
+			webrtcOpts: DialWebRTCOptions{
+				SignalingInsecure:   true,
+				SignalingAuthEntity: key.ID,
+				SignalingCreds:      Credentials{Type: CredentialsTypeAPIKey, Payload: secret},
+			},
+			webrtcOptsSet: true,
+		}, logger)
+	}
+
+	conn, err := dial()
+	test.That(t, err, test.ShouldBeNil)
+	_, err = pb.NewEchoServiceClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hello"})
+	test.That(t, err, test.ShouldBeNil)
+
+	// tunnels count against the limit like any other peer.
+	_, err = dial()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, fmt.Sprint(http.StatusServiceUnavailable))
+
+	// closing the tunnel frees its slot.
+	test.That(t, conn.Close(), test.ShouldBeNil)
+	testutils.WaitForAssertion(t, func(tb testing.TB) {
+		tb.Helper()
+		conn, err := dial()
+		test.That(tb, err, test.ShouldBeNil)
+		test.That(tb, conn.Close(), test.ShouldBeNil)
+	})
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
 	"runtime"
 	"runtime/debug"
+	"runtime/pprof"
 	"sync"
 	"syscall"
 	"time"
@@ -36,7 +38,7 @@ func ContextualMainQuit[L ILogger](main func(ctx context.Context, args []string,
 }
 
 func contextualMain[L ILogger](main func(ctx context.Context, args []string, logger L) error, quitSignal bool, logger L) {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, stop := ContextWithSignals(context.Background(), SignalOptions{Logger: logger})
 	if quitSignal {
 		quitC := make(chan os.Signal, 1)
 		signal.Notify(quitC, syscall.SIGQUIT)
@@ -54,21 +56,22 @@ func contextualMain[L ILogger](main func(ctx context.Context, args []string, log
 			if !SelectContextOrWaitChan(ctx, usr1C) {
 				return
 			}
-			buf := make([]byte, 1024)
-			for {
-				n := runtime.Stack(buf, true)
-				if n < len(buf) {
-					buf = buf[:n]
-					break
-				}
-				buf = make([]byte, 2*len(buf))
-			}
-			logger.Warn(string(buf))
+			logger.Warn(string(goroutineStacks()))
 		}
 	}, signalWatcher.Done)
 
 	readyC := make(chan struct{})
 	readyCtx := ContextWithReadyFunc(ctx, readyC)
+	signalWatcher.Add(1)
+	PanicCapturingGo(func() {
+		defer signalWatcher.Done()
+		if !SelectContextOrWaitChan(ctx, readyC) {
+			return
+		}
+		if err := NotifyReady(); err != nil {
+			logger.Warn("failed to notify readiness: ", err)
+		}
+	})
 	if err := FilterOutError(main(readyCtx, os.Args, logger), context.Canceled); err != nil {
 		fatal(logger, err)
 	}
@@ -144,10 +147,20 @@ func PanicCapturingGo(f func()) {
 
 const waitDur = 3 * time.Second
 
+// GoroutineLabel is the pprof label that goroutines spawned by PanicCapturingGo and
+// ManagedGo carry, set to the name of the function they run, so that goroutine profiles
+// and leak reports can tell where they came from.
+const GoroutineLabel = "goutils.func"
+
 // PanicCapturingGoWithCallback spawns a goroutine to run the given function and captures
 // any panic that occurs, logs it, and calls the given callback. The callback can be
 // used for restart functionality.
 func PanicCapturingGoWithCallback(f func(), callback func(err interface{})) {
+	panicCapturingGo(funcName(f), f, callback)
+}
+
+func panicCapturingGo(name string, f func(), callback func(err interface{})) {
+	labels := pprof.Labels(GoroutineLabel, name)
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
@@ -161,14 +174,23 @@ func PanicCapturingGoWithCallback(f func(), callback func(err interface{})) {
 				callback(err)
 			}
 		}()
-		f()
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			f()
+		})
 	}()
 }
 
+func funcName(f func()) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
+
 // ManagedGo keeps the given function alive in the background until
 // it terminates normally.
 func ManagedGo(f, onComplete func()) {
-	PanicCapturingGoWithCallback(func() {
+	panicCapturingGo(funcName(f), func() {
 		defer func() {
 			if err := recover(); err == nil && onComplete != nil {
 				onComplete()
@@ -191,6 +213,17 @@ func SelectContextOrWait(ctx context.Context, dur time.Duration) bool {
 	return SelectContextOrWaitChan(ctx, timer.C)
 }
 
+// SelectContextOrWaitUntil either terminates because the given context is done
+// or the given time is reached. It returns true if the time was reached. If the
+// context has a deadline before the given time, it returns false right away
+// rather than waiting for a time that the context will not last until.
+func SelectContextOrWaitUntil(ctx context.Context, until time.Time) bool {
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return false
+	}
+	return SelectContextOrWait(ctx, time.Until(until))
+}
+
 // SelectContextOrWaitChan either terminates because the given context is done
 // or the given time channel is received on. It returns true if the channel
 // was received on.
@@ -11,3 +11,7 @@ import (
 func notifySignals(channel chan os.Signal) {
 	signal.Notify(channel, syscall.SIGUSR1)
 }
+
+func notifyReloadSignals(channel chan os.Signal) {
+	signal.Notify(channel, syscall.SIGHUP)
+}
@@ -169,6 +169,28 @@ func TestSelectContextOrWait(t *testing.T) {
 	test.That(t, ok, test.ShouldBeTrue)
 }
 
+func TestSelectContextOrWaitUntil(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ok := SelectContextOrWaitUntil(ctx, time.Now().Add(time.Hour))
+	test.That(t, ok, test.ShouldBeFalse)
+
+	// a deadline before the time is not waited for
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	ok = SelectContextOrWaitUntil(ctx, time.Now().Add(time.Hour))
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, time.Since(start), test.ShouldBeLessThan, time.Minute)
+
+	ok = SelectContextOrWaitUntil(ctx, time.Now().Add(time.Millisecond))
+	test.That(t, ok, test.ShouldBeTrue)
+
+	// times already passed are reached right away
+	ok = SelectContextOrWaitUntil(context.Background(), time.Now().Add(-time.Hour))
+	test.That(t, ok, test.ShouldBeTrue)
+}
+
 func TestSelectContextOrWaitChan(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
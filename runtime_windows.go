@@ -5,3 +5,5 @@ import "os"
 func notifySignals(channel chan os.Signal) {
 	println("skipping notifySignals on windows platform")
 }
+
+func notifyReloadSignals(channel chan os.Signal) {}
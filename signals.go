@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"github.com/edaniels/golog"
+)
+
+// exit is how the process is forced to exit. It is a variable so that tests can catch it.
+var exit = os.Exit
+
+// SignalOptions configure how ContextWithSignals handles signals.
+type SignalOptions struct {
+	// Handlers are called with each signal they are registered for, such as SIGHUP to
+	// reload configuration or certificates. They are called one at a time from the
+	// goroutine watching for signals, so they should return quickly. Interrupt and
+	// SIGTERM are always handled by shutting down and cannot be registered.
+	Handlers map[os.Signal]func()
+
+	// Logger is where shutting down and forced exits are reported. Defaults to the global
+	// logger.
+	Logger ILogger
+}
+
+// ContextWithSignals returns a context that is canceled on the first interrupt (SIGINT) or
+// SIGTERM the process receives so that it can shut down gracefully. Should another one
+// come while shutting down, the stacks of all goroutines are logged, to show what is
+// holding the shutdown up, and the process exits right away. Other signals are passed to
+// their registered handlers. The returned function stops handling signals and must be
+// called once done with them.
+func ContextWithSignals(ctx context.Context, opts SignalOptions) (context.Context, func()) {
+	var logger ILogger = golog.Global()
+	if opts.Logger != nil {
+		logger = opts.Logger
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	shutdownC := make(chan os.Signal, 2)
+	signal.Notify(shutdownC, os.Interrupt, syscall.SIGTERM)
+	handlerC := make(chan os.Signal, 1)
+	handled := make([]os.Signal, 0, len(opts.Handlers))
+	for sig := range opts.Handlers {
+		if sig == os.Interrupt || sig == syscall.SIGTERM {
+			continue
+		}
+		handled = append(handled, sig)
+	}
+	if len(handled) != 0 {
+		signal.Notify(handlerC, handled...)
+	}
+
+	stopC := make(chan struct{})
+	var watcher sync.WaitGroup
+	watcher.Add(1)
+	PanicCapturingGo(func() {
+		defer watcher.Done()
+		shuttingDown := false
+		for {
+			select {
+			case <-stopC:
+				return
+			case sig := <-shutdownC:
+				if !shuttingDown {
+					shuttingDown = true
+					logger.Info("received ", sig, "; shutting down (send again to force exit)")
+					cancel()
+					continue
+				}
+				logger.Warn("received ", sig, " while shutting down; forcing exit with goroutines:\n", string(goroutineStacks()))
+				exit(1)
+			case sig := <-handlerC:
+				opts.Handlers[sig]()
+			}
+		}
+	})
+
+	var stopOnce sync.Once
+	return ctx, func() {
+		stopOnce.Do(func() {
+			signal.Stop(shutdownC)
+			signal.Stop(handlerC)
+			close(stopC)
+			watcher.Wait()
+			cancel()
+		})
+	}
+}
+
+// goroutineStacks returns the stacks of all goroutines.
+func goroutineStacks() []byte {
+	buf := make([]byte, 1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
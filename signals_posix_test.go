@@ -0,0 +1,48 @@
+//go:build !windows
+
+package utils
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestContextWithSignals(t *testing.T) {
+	exited := make(chan int, 1)
+	prevExit := exit
+	exit = func(code int) {
+		exited <- code
+	}
+	defer func() {
+		exit = prevExit
+	}()
+
+	reloaded := make(chan struct{}, 1)
+	logger, logs := golog.NewObservedTestLogger(t)
+	ctx, stop := ContextWithSignals(context.Background(), SignalOptions{
+		Handlers: map[os.Signal]func(){
+			syscall.SIGHUP: func() {
+				reloaded <- struct{}{}
+			},
+		},
+		Logger: logger,
+	})
+	defer stop()
+
+	test.That(t, syscall.Kill(os.Getpid(), syscall.SIGHUP), test.ShouldBeNil)
+	<-reloaded
+	test.That(t, ctx.Err(), test.ShouldBeNil)
+
+	// the first interrupt shuts down and the second forces an exit
+	test.That(t, syscall.Kill(os.Getpid(), syscall.SIGINT), test.ShouldBeNil)
+	<-ctx.Done()
+	test.That(t, syscall.Kill(os.Getpid(), syscall.SIGINT), test.ShouldBeNil)
+	test.That(t, <-exited, test.ShouldEqual, 1)
+	test.That(t, logs.FilterMessageSnippet("forcing exit").Len(), test.ShouldEqual, 1)
+	test.That(t, logs.FilterMessageSnippet("goroutine ").Len(), test.ShouldEqual, 1)
+}
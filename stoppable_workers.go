@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/edaniels/golog"
+)
+
+// StoppableWorkers runs goroutines, or workers, that all stop together. Each worker is
+// given a context that is done once Stop is called or the parent context the workers
+// were made with is done, and is expected to return soon after. A panic in a worker is
+// captured and logged rather than crashing the process; the worker is only restarted if
+// it was added with AddRestartingWorkers.
+type StoppableWorkers struct {
+	logger golog.Logger
+	ctx    context.Context
+	cancel func()
+
+	// mu guards against workers being added while Stop waits on them.
+	mu      sync.Mutex
+	stopped bool
+	workers sync.WaitGroup
+}
+
+// NewStoppableWorkers starts the given workers, which run until Stop is called. Panics
+// are logged to the global logger.
+func NewStoppableWorkers(workers ...func(ctx context.Context)) *StoppableWorkers {
+	return NewStoppableWorkersWithContext(context.Background(), golog.Global(), workers...)
+}
+
+// NewStoppableWorkersWithContext starts the given workers, which run until Stop is called
+// or the parent context is done. Panics are logged to the given logger.
+func NewStoppableWorkersWithContext(
+	parent context.Context,
+	logger golog.Logger,
+	workers ...func(ctx context.Context),
+) *StoppableWorkers {
+	ctx, cancel := context.WithCancel(parent)
+	sw := &StoppableWorkers{logger: logger, ctx: ctx, cancel: cancel}
+	sw.AddWorkers(workers...)
+	return sw
+}
+
+// AddWorkers starts more workers alongside those already running. Workers added once Stop
+// was called are not started.
+func (sw *StoppableWorkers) AddWorkers(workers ...func(ctx context.Context)) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.stopped {
+		return
+	}
+	for _, worker := range workers {
+		worker := worker
+		sw.workers.Add(1)
+		go func() {
+			defer sw.workers.Done()
+			sw.run(worker)
+		}()
+	}
+}
+
+// AddRestartingWorkers starts more workers like AddWorkers, except that a worker that
+// panics is started again, like a function run with ManagedGo, until the workers are told
+// to stop.
+func (sw *StoppableWorkers) AddRestartingWorkers(workers ...func(ctx context.Context)) {
+	restarting := make([]func(ctx context.Context), 0, len(workers))
+	for _, worker := range workers {
+		worker := worker
+		restarting = append(restarting, func(ctx context.Context) {
+			for sw.run(worker) && ctx.Err() == nil {
+				sw.logger.Debug("restarting worker after panic")
+			}
+		})
+	}
+	sw.AddWorkers(restarting...)
+}
+
+// run runs the worker, returning whether it panicked.
+func (sw *StoppableWorkers) run(worker func(ctx context.Context)) (panicked bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			sw.logger.Errorw("panic while running worker", "error", err, "stack", string(debug.Stack()))
+			panicked = true
+		}
+	}()
+	worker(sw.ctx)
+	return false
+}
+
+// Context returns the context given to the workers. It is done once the workers are
+// told to stop.
+func (sw *StoppableWorkers) Context() context.Context {
+	return sw.ctx
+}
+
+// Stop tells the workers to stop and waits for all of them to return. It is safe to call
+// more than once.
+func (sw *StoppableWorkers) Stop() {
+	sw.mu.Lock()
+	sw.stopped = true
+	sw.mu.Unlock()
+	sw.cancel()
+	sw.workers.Wait()
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestStoppableWorkers(t *testing.T) {
+	t.Run("stop waits for workers", func(t *testing.T) {
+		var running, stopped atomic.Int32
+		started := make(chan struct{}, 3)
+		worker := func(ctx context.Context) {
+			running.Add(1)
+			started <- struct{}{}
+			<-ctx.Done()
+			stopped.Add(1)
+		}
+		sw := NewStoppableWorkers(worker, worker)
+		sw.AddWorkers(worker)
+		for i := 0; i < 3; i++ {
+			<-started
+		}
+		test.That(t, running.Load(), test.ShouldEqual, 3)
+		sw.Stop()
+		test.That(t, stopped.Load(), test.ShouldEqual, 3)
+		test.That(t, sw.Context().Err(), test.ShouldBeError, context.Canceled)
+
+		// workers added once stopped are not started and stopping again is fine.
+		sw.AddWorkers(worker)
+		sw.Stop()
+		test.That(t, running.Load(), test.ShouldEqual, 3)
+	})
+
+	t.Run("parent context", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		sw := NewStoppableWorkersWithContext(parent, golog.NewTestLogger(t), func(ctx context.Context) {
+			<-ctx.Done()
+			close(done)
+		})
+		cancel()
+		<-done
+		sw.Stop()
+	})
+
+	t.Run("panics are captured", func(t *testing.T) {
+		logger, logs := golog.NewObservedTestLogger(t)
+		sw := NewStoppableWorkersWithContext(context.Background(), logger, func(ctx context.Context) {
+			panic("whoops")
+		})
+		sw.Stop()
+		test.That(t, logs.FilterMessage("panic while running worker").Len(), test.ShouldEqual, 1)
+	})
+	t.Run("restarting workers", func(t *testing.T) {
+		logger, logs := golog.NewObservedTestLogger(t)
+		var runs atomic.Int32
+		done := make(chan struct{})
+		sw := NewStoppableWorkersWithContext(context.Background(), logger)
+		sw.AddRestartingWorkers(func(ctx context.Context) {
+			if runs.Add(1) < 3 {
+				panic("whoops")
+			}
+			close(done)
+			<-ctx.Done()
+		})
+		<-done
+		sw.Stop()
+		test.That(t, runs.Load(), test.ShouldEqual, 3)
+		test.That(t, logs.FilterMessage("panic while running worker").Len(), test.ShouldEqual, 2)
+	})
+}
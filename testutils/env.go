@@ -73,10 +73,15 @@ func ArtifactGoogleCreds(tb testing.TB) string {
 	return creds
 }
 
+// backingMongoDBURI returns the URI in TEST_MONGODB_URI or, if there is none, that of a
+// local MongoDB started for tests.
 func backingMongoDBURI() (string, error) {
 	mongoURI, ok := os.LookupEnv("TEST_MONGODB_URI")
 	if !ok || mongoURI == "" {
-		return "", errors.New("no MongoDB URI found")
+		var err error
+		if mongoURI, err = startLocalMongoDB(); err != nil {
+			return "", err
+		}
 	}
 	setupMongoDBForTests()
 	return mongoURI, nil
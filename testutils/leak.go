@@ -0,0 +1,290 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// A LeakOption changes what VerifyNoLeaks considers a leak.
+type LeakOption func(opts *leakOptions)
+
+type leakOptions struct {
+	goroutines []string
+	fds        []string
+	timeout    time.Duration
+}
+
+// AllowGoroutines allows goroutines left running with any of the given top functions, such
+// as pollers known to live as long as the process.
+func AllowGoroutines(topFunctions ...string) LeakOption {
+	return func(opts *leakOptions) {
+		opts.goroutines = append(opts.goroutines, topFunctions...)
+	}
+}
+
+// AllowFDs allows file descriptors left open whose targets, such as a path or
+// "socket:[1234]" on Linux, start with any of the given prefixes.
+func AllowFDs(targetPrefixes ...string) LeakOption {
+	return func(opts *leakOptions) {
+		opts.fds = append(opts.fds, targetPrefixes...)
+	}
+}
+
+// WithLeakTimeout sets how long to wait for goroutines to exit and file descriptors to
+// close before reporting them as leaked. It defaults to 5 seconds.
+func WithLeakTimeout(timeout time.Duration) LeakOption {
+	return func(opts *leakOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// VerifyNoLeaks fails the test if goroutines or file descriptors that were not there when it
+// was called are left once the test and its cleanups are done. It should be called first in
+// a test so that it checks after every other cleanup. Goroutines spawned by
+// utils.PanicCapturingGo or utils.ManagedGo are reported with the name of the function they
+// run. File descriptors are not checked on Windows. Tests running in parallel may be
+// reported as leaking.
+func VerifyNoLeaks(tb testing.TB, opts ...LeakOption) {
+	tb.Helper()
+	options := leakOptions{timeout: 5 * time.Second}
+	AllowGoroutines(utils.LongLivedGoroutines...)(&options)
+	// started by signal.Notify and never stopped.
+	AllowGoroutines("os/signal.signal_recv", "os/signal.loop")(&options)
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	before := takeLeakSnapshot()
+	tb.Cleanup(func() {
+		if err := waitForLeaks(before, options); err != nil {
+			tb.Error(err)
+		}
+	})
+}
+
+// waitForLeaks waits up to the timeout for what was left since the snapshot to go away and
+// returns what did not.
+func waitForLeaks(before leakSnapshot, opts leakOptions) error {
+	deadline := time.Now().Add(opts.timeout)
+	for wait := time.Millisecond; ; wait *= 2 {
+		err := takeLeakSnapshot().leaksSince(before, opts)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond
+		}
+		time.Sleep(wait)
+	}
+}
+
+type leakSnapshot struct {
+	// self is the goroutine that took the snapshot.
+	self       int
+	goroutines map[int]goroutineInfo
+	fds        map[int]string
+}
+
+type goroutineInfo struct {
+	id     int
+	state  string
+	labels string
+	funcs  []string
+	stack  string
+}
+
+func takeLeakSnapshot() leakSnapshot {
+	self, goroutines := runningGoroutines()
+	return leakSnapshot{
+		self:       self,
+		goroutines: goroutines,
+		fds:        openFDs(),
+	}
+}
+
+// leaksSince returns an error describing the goroutines and file descriptors in this
+// snapshot but not in the one before it.
+func (s leakSnapshot) leaksSince(before leakSnapshot, opts leakOptions) error {
+	var leakedGoroutines []goroutineInfo
+	for id, g := range s.goroutines {
+		if _, ok := before.goroutines[id]; ok || id == s.self || len(g.funcs) == 0 {
+			continue
+		}
+		if containsString(opts.goroutines, g.funcs[0]) {
+			continue
+		}
+		leakedGoroutines = append(leakedGoroutines, g)
+	}
+	var leakedFDs []int
+	for fd, target := range s.fds {
+		if beforeTarget, ok := before.fds[fd]; ok && beforeTarget == target {
+			continue
+		}
+		if hasAnyPrefix(target, opts.fds) {
+			continue
+		}
+		leakedFDs = append(leakedFDs, fd)
+	}
+
+	var errs error
+	if len(leakedGoroutines) != 0 {
+		sort.Slice(leakedGoroutines, func(i, j int) bool {
+			return leakedGoroutines[i].id < leakedGoroutines[j].id
+		})
+		var report strings.Builder
+		for _, g := range leakedGoroutines {
+			fmt.Fprintf(&report, "\n\ngoroutine %d [%s]", g.id, g.state)
+			if g.labels != "" {
+				fmt.Fprintf(&report, " labels: %s", g.labels)
+			}
+			report.WriteString(":\n" + g.stack)
+		}
+		errs = multierr.Combine(errs, errors.Errorf("%d goroutine(s) leaked:%s", len(leakedGoroutines), report.String()))
+	}
+	if len(leakedFDs) != 0 {
+		sort.Ints(leakedFDs)
+		var report strings.Builder
+		for _, fd := range leakedFDs {
+			fmt.Fprintf(&report, "\nfd %d: %s", fd, s.fds[fd])
+		}
+		errs = multierr.Combine(errs, errors.Errorf("%d file descriptor(s) leaked:%s", len(leakedFDs), report.String()))
+	}
+	return errs
+}
+
+// runningGoroutines returns the ID of the calling goroutine and every goroutine by ID, with
+// the pprof labels of those that have any.
+func runningGoroutines() (int, map[int]goroutineInfo) {
+	labels := goroutineLabels()
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	self := -1
+	goroutines := map[int]goroutineInfo{}
+	for _, block := range strings.Split(string(buf), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		// goroutine 12 [chan receive]:
+		header := strings.TrimSuffix(strings.TrimPrefix(lines[0], "goroutine "), ":")
+		idStr, state, ok := strings.Cut(header, " ")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		g := goroutineInfo{
+			id:    id,
+			state: strings.TrimSuffix(strings.TrimPrefix(state, "["), "]"),
+			stack: strings.Join(lines[1:], "\n"),
+		}
+		// each frame is a function call followed by a tab indented file and line.
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "created by ") {
+				continue
+			}
+			if idx := strings.LastIndex(line, "("); idx > 0 {
+				line = line[:idx]
+			}
+			g.funcs = append(g.funcs, line)
+		}
+		g.labels = labels[stackKey(g.funcs)]
+		goroutines[id] = g
+		// the first is always the calling goroutine.
+		if self == -1 {
+			self = id
+		}
+	}
+	return self, goroutines
+}
+
+// goroutineLabels returns the pprof labels of goroutines by the key of their stacks.
+// Stack traces do not include labels, so the goroutine profile, which does but lacks IDs,
+// is how they are found.
+func goroutineLabels() map[string]string {
+	var buf bytes.Buffer
+	//nolint:errcheck
+	_ = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+
+	labels := map[string]string{}
+	for _, block := range strings.Split(buf.String(), "\n\n") {
+		var funcs []string
+		var blockLabels string
+		for _, line := range strings.Split(strings.TrimSpace(block), "\n") {
+			if value, ok := strings.CutPrefix(line, "# labels: "); ok {
+				blockLabels = value
+				continue
+			}
+			// #	0x4712e0	main.main+0xc0	/path/to/main.go:10
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[0] != "#" {
+				continue
+			}
+			fn := fields[2]
+			if idx := strings.LastIndex(fn, "+"); idx > 0 {
+				fn = fn[:idx]
+			}
+			funcs = append(funcs, fn)
+		}
+		if blockLabels != "" {
+			labels[stackKey(funcs)] = blockLabels
+		}
+	}
+	return labels
+}
+
+// stackKey returns a key for a stack of functions that is the same in stack traces and
+// goroutine profiles, which differ in which runtime functions and how many frames they
+// include.
+func stackKey(funcs []string) string {
+	const maxFrames = 16
+	key := make([]string, 0, maxFrames)
+	for _, fn := range funcs {
+		if name, ok := strings.CutPrefix(fn, "runtime."); ok && (name == "" || !unicode.IsUpper(rune(name[0]))) {
+			continue
+		}
+		key = append(key, fn)
+		if len(key) == maxFrames {
+			break
+		}
+	}
+	return strings.Join(key, "\n")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(value string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
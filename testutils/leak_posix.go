@@ -0,0 +1,48 @@
+//go:build !windows
+
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// openFDs returns the open file descriptors of the process and what they refer to, where
+// that can be found.
+func openFDs() map[int]string {
+	// the netpoller opens descriptors of its own the first time a file or socket uses it,
+	// so it is made to do so now rather than during a test.
+	if r, w, err := os.Pipe(); err == nil {
+		//nolint:errcheck,gosec
+		r.Close()
+		//nolint:errcheck,gosec
+		w.Close()
+	}
+
+	dir := "/proc/self/fd"
+	if _, err := os.Stat(dir); err != nil {
+		dir = "/dev/fd"
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	fds := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// the descriptor of the directory being read is closed by now.
+			if _, statErr := os.Stat(filepath.Join(dir, entry.Name())); statErr != nil {
+				continue
+			}
+			target = ""
+		}
+		fds[fd] = target
+	}
+	return fds
+}
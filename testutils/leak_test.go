@@ -0,0 +1,81 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/utils"
+)
+
+func TestLeakSnapshot(t *testing.T) {
+	opts := leakOptions{goroutines: utils.LongLivedGoroutines, timeout: 5 * time.Second}
+	before := takeLeakSnapshot()
+	test.That(t, takeLeakSnapshot().leaksSince(before, opts), test.ShouldBeNil)
+
+	t.Run("goroutines", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		utils.PanicCapturingGo(func() {
+			defer close(done)
+			close(started)
+			<-release
+		})
+		<-started
+		// wait for the goroutine to block
+		for i := 0; i < 100; i++ {
+			runtime.Gosched()
+		}
+
+		err := takeLeakSnapshot().leaksSince(before, opts)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "1 goroutine(s) leaked")
+		test.That(t, err.Error(), test.ShouldContainSubstring, utils.GoroutineLabel)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "TestLeakSnapshot.func1.1")
+
+		allowed := opts
+		AllowGoroutines("go.viam.com/utils/testutils.TestLeakSnapshot.func1.1")(&allowed)
+		test.That(t, takeLeakSnapshot().leaksSince(before, allowed), test.ShouldBeNil)
+
+		close(release)
+		<-done
+		test.That(t, waitForLeaks(before, opts), test.ShouldBeNil)
+	})
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	t.Run("file descriptors", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "leaked")
+		//nolint:gosec
+		f, err := os.Create(path)
+		test.That(t, err, test.ShouldBeNil)
+
+		err = takeLeakSnapshot().leaksSince(before, opts)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "1 file descriptor(s) leaked")
+		if runtime.GOOS == "linux" {
+			test.That(t, err.Error(), test.ShouldContainSubstring, path)
+			allowed := opts
+			AllowFDs(filepath.Dir(path))(&allowed)
+			test.That(t, takeLeakSnapshot().leaksSince(before, allowed), test.ShouldBeNil)
+		}
+
+		test.That(t, f.Close(), test.ShouldBeNil)
+		test.That(t, takeLeakSnapshot().leaksSince(before, opts), test.ShouldBeNil)
+	})
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	VerifyNoLeaks(t)
+	done := make(chan struct{})
+	utils.PanicCapturingGo(func() {
+		close(done)
+	})
+	<-done
+}
@@ -0,0 +1,6 @@
+package testutils
+
+// openFDs returns nothing as file descriptors are not checked on Windows.
+func openFDs() map[int]string {
+	return nil
+}
@@ -143,3 +143,19 @@ func BackingMongoDBClient(tb testing.TB) *mongo.Client {
 	}
 	return client
 }
+
+// BackingMongoDBDatabase returns a database of the backing MongoDB named uniquely for the
+// test so that tests using it may run in parallel. The database is dropped once the test
+// is done.
+func BackingMongoDBDatabase(tb testing.TB) *mongo.Database {
+	tb.Helper()
+	client := BackingMongoDBClient(tb)
+	dbName := "test_" + utils.RandomAlphaString(10)
+	db := mongoutils.DatabaseFromClient(client, dbName)
+	tb.Cleanup(func() {
+		if err := db.Drop(context.Background()); err != nil {
+			tb.Errorf("error dropping test database %q: %v", dbName, err)
+		}
+	})
+	return db
+}
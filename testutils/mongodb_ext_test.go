@@ -0,0 +1,34 @@
+package testutils_test
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/testutils"
+)
+
+func TestBackingMongoDBDatabase(t *testing.T) {
+	testutils.SkipUnlessBackingMongoDBURI(t)
+	client := testutils.BackingMongoDBClient(t)
+
+	var dbNames []string
+	for i := 0; i < 2; i++ {
+		t.Run("database", func(t *testing.T) {
+			db := testutils.BackingMongoDBDatabase(t)
+			_, err := db.Collection("foo").InsertOne(context.Background(), bson.D{})
+			test.That(t, err, test.ShouldBeNil)
+			dbNames = append(dbNames, db.Name())
+		})
+	}
+	test.That(t, dbNames, test.ShouldHaveLength, 2)
+	test.That(t, dbNames[0], test.ShouldNotEqual, dbNames[1])
+
+	// databases are dropped once their test is done
+	existing, err := client.ListDatabaseNames(context.Background(), bson.D{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, existing, test.ShouldNotContain, dbNames[0])
+	test.That(t, existing, test.ShouldNotContain, dbNames[1])
+}
@@ -0,0 +1,141 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// localMongoDBStartTimeout is how long a local MongoDB has to start and become primary.
+const localMongoDBStartTimeout = time.Minute
+
+// A localMongoDB is a mongod started for tests when no TEST_MONGODB_URI is given. It is
+// a single member replica set so that change streams and transactions work.
+var (
+	localMongoDBMu  sync.Mutex
+	localMongoDBCmd *exec.Cmd
+	localMongoDBDir string
+	localMongoDBURI string
+)
+
+// startLocalMongoDB starts a mongod found at TEST_MONGODB_BINARY or on the PATH, unless
+// one is already running, and returns its URI.
+func startLocalMongoDB() (string, error) {
+	localMongoDBMu.Lock()
+	defer localMongoDBMu.Unlock()
+	if localMongoDBURI != "" {
+		return localMongoDBURI, nil
+	}
+
+	binary, ok := os.LookupEnv("TEST_MONGODB_BINARY")
+	if !ok || binary == "" {
+		binary = "mongod"
+	}
+	binaryPath, err := exec.LookPath(binary)
+	if err != nil {
+		return "", errors.New("no MongoDB URI found and no mongod to start")
+	}
+	port, err := utils.TryReserveRandomPort()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "test-mongodb-")
+	if err != nil {
+		return "", err
+	}
+	host := fmt.Sprintf("127.0.0.1:%d", port)
+	//nolint:gosec
+	cmd := exec.Command(
+		binaryPath,
+		"--dbpath", dir,
+		"--bind_ip", "127.0.0.1",
+		"--port", fmt.Sprint(port),
+		"--replSet", "rs0",
+		"--quiet",
+	)
+	if err := cmd.Start(); err != nil {
+		return "", multierr.Combine(err, os.RemoveAll(dir))
+	}
+	localMongoDBCmd, localMongoDBDir = cmd, dir
+
+	uri := fmt.Sprintf("mongodb://%s/?directConnection=true", host)
+	if err := initiateLocalMongoDB(uri, host); err != nil {
+		return "", multierr.Combine(errors.Wrap(err, "failed to start local MongoDB"), stopLocalMongoDBLocked())
+	}
+	localMongoDBURI = uri
+	logger.Debugw("started local MongoDB", "uri", uri, "dir", dir)
+	return uri, nil
+}
+
+// initiateLocalMongoDB initiates the replica set of a freshly started mongod and waits
+// for it to become primary.
+func initiateLocalMongoDB(uri, host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), localMongoDBStartTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(time.Second))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		utils.UncheckedError(client.Disconnect(context.Background()))
+	}()
+
+	admin := client.Database("admin")
+	initiated := false
+	return utils.Retry(ctx, utils.RetryPolicy{}, func() error {
+		if !initiated {
+			if err := admin.RunCommand(ctx, bson.D{{"replSetInitiate", bson.D{
+				{"_id", "rs0"},
+				{"members", bson.A{bson.D{{"_id", 0}, {"host", host}}}},
+			}}}).Err(); err != nil {
+				return err
+			}
+			initiated = true
+		}
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		if err := admin.RunCommand(ctx, bson.D{{"hello", 1}}).Decode(&hello); err != nil {
+			return err
+		}
+		if !hello.IsWritablePrimary {
+			return errors.New("not primary yet")
+		}
+		return nil
+	})
+}
+
+// stopLocalMongoDB stops the local MongoDB, if one was started, and removes its data.
+func stopLocalMongoDB() error {
+	localMongoDBMu.Lock()
+	defer localMongoDBMu.Unlock()
+	return stopLocalMongoDBLocked()
+}
+
+func stopLocalMongoDBLocked() error {
+	if localMongoDBCmd == nil {
+		return nil
+	}
+	var errs error
+	if err := localMongoDBCmd.Process.Kill(); err != nil {
+		errs = multierr.Combine(errs, err)
+	} else {
+		// killed processes always exit with an error.
+		//nolint:errcheck
+		localMongoDBCmd.Wait()
+	}
+	errs = multierr.Combine(errs, os.RemoveAll(localMongoDBDir))
+	localMongoDBCmd, localMongoDBDir, localMongoDBURI = nil, "", ""
+	return errs
+}
@@ -0,0 +1,503 @@
+package testutils
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/transport/v2"
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+// A NetworkProfile describes the conditions of a simulated network link. The zero value is
+// a perfect link.
+type NetworkProfile struct {
+	// Latency is how long everything written takes to arrive.
+	Latency time.Duration
+	// Jitter is the most that is randomly added to Latency for each write.
+	Jitter time.Duration
+	// Bandwidth is how many bytes per second can be sent. Zero is unlimited.
+	Bandwidth int
+	// Loss is the probability, from 0 to 1, that a packet is dropped. Streams never lose data.
+	Loss float64
+	// Reorder is the probability, from 0 to 1, that a packet is held back by another Latency,
+	// or a millisecond without any, letting packets sent after it arrive first. Streams are
+	// never reordered.
+	Reorder float64
+	// Seed seeds the randomness of jitter, loss, and reordering so that runs can be repeated.
+	Seed int64
+}
+
+// maxPacketQueueDelay is how long a packet may wait for bandwidth before it is dropped, as
+// a router with a full queue would.
+const maxPacketQueueDelay = time.Second
+
+// simulatedLink schedules what is written over a link with the conditions of a profile.
+type simulatedLink struct {
+	profile NetworkProfile
+
+	mu   sync.Mutex
+	rand *rand.Rand
+	// busyUntil is when everything written so far will have been put on the link.
+	busyUntil time.Time
+	// lastArrival is when the last write that was not reordered arrives.
+	lastArrival time.Time
+}
+
+func newSimulatedLink(profile NetworkProfile) *simulatedLink {
+	return &simulatedLink{
+		profile: profile,
+		//nolint:gosec
+		rand: rand.New(rand.NewSource(profile.Seed)),
+	}
+}
+
+// schedule returns when a write of n bytes made now is done being sent and when it arrives,
+// or that it is dropped. Unless ordered is set, it may be lost or reordered.
+func (l *simulatedLink) schedule(n int, ordered bool) (sent, arrival time.Time, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if !ordered && l.profile.Loss > 0 && l.rand.Float64() < l.profile.Loss {
+		return now, now, true
+	}
+
+	sent = now
+	if l.busyUntil.After(sent) {
+		sent = l.busyUntil
+	}
+	if l.profile.Bandwidth > 0 {
+		if !ordered && sent.Sub(now) > maxPacketQueueDelay {
+			return now, now, true
+		}
+		sent = sent.Add(time.Duration(n) * time.Second / time.Duration(l.profile.Bandwidth))
+	}
+	l.busyUntil = sent
+
+	arrival = sent.Add(l.profile.Latency)
+	if l.profile.Jitter > 0 {
+		arrival = arrival.Add(time.Duration(l.rand.Int63n(int64(l.profile.Jitter) + 1)))
+	}
+	if !ordered && l.profile.Reorder > 0 && l.rand.Float64() < l.profile.Reorder {
+		holdBack := l.profile.Latency
+		if holdBack == 0 {
+			holdBack = time.Millisecond
+		}
+		// held back packets do not hold back those after them.
+		return sent, arrival.Add(holdBack), false
+	}
+	if arrival.Before(l.lastArrival) {
+		arrival = l.lastArrival
+	}
+	l.lastArrival = arrival
+	return sent, arrival, false
+}
+
+// SimulateConn returns a stream connection whose writes arrive at the other end with the
+// latency, jitter, and bandwidth of the profile. Writes block while the bandwidth is used up,
+// which pushes back on writers as a slow link would. Only writes are affected, so both ends
+// must be wrapped to affect both directions. Writes still in flight when the connection is
+// closed are discarded.
+func SimulateConn(conn net.Conn, profile NetworkProfile) net.Conn {
+	return newSimulatedConn(conn, newSimulatedLink(profile))
+}
+
+// simulatedConnQueueSize is how many writes can be in flight on a simulated connection.
+const simulatedConnQueueSize = 1024
+
+type simulatedConn struct {
+	net.Conn
+	link *simulatedLink
+
+	segments  chan simulatedSegment
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+
+	mu            sync.Mutex
+	writeDeadline time.Time
+	writeErr      error
+}
+
+type simulatedSegment struct {
+	data    []byte
+	arrival time.Time
+}
+
+func newSimulatedConn(conn net.Conn, link *simulatedLink) *simulatedConn {
+	sc := &simulatedConn{
+		Conn:     conn,
+		link:     link,
+		segments: make(chan simulatedSegment, simulatedConnQueueSize),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	utils.PanicCapturingGo(sc.deliver)
+	return sc
+}
+
+// deliver writes each segment to the underlying connection once it has arrived.
+func (sc *simulatedConn) deliver() {
+	defer close(sc.done)
+	for {
+		var segment simulatedSegment
+		select {
+		case <-sc.closed:
+			return
+		case segment = <-sc.segments:
+		}
+		if !sleepUntil(segment.arrival, sc.closed) {
+			return
+		}
+		if _, err := sc.Conn.Write(segment.data); err != nil {
+			sc.mu.Lock()
+			sc.writeErr = err
+			sc.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Write queues the data to arrive later and returns once it has been sent.
+func (sc *simulatedConn) Write(b []byte) (int, error) {
+	sc.mu.Lock()
+	err := sc.writeErr
+	deadline := sc.writeDeadline
+	sc.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case <-sc.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var deadlineC <-chan time.Time
+	if !deadline.IsZero() {
+		if !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		deadlineC = timer.C
+	}
+
+	sent, arrival, _ := sc.link.schedule(len(b), true)
+	select {
+	case <-sc.closed:
+		return 0, net.ErrClosed
+	case <-sc.done:
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		return 0, sc.writeErr
+	case <-deadlineC:
+		return 0, os.ErrDeadlineExceeded
+	case sc.segments <- simulatedSegment{data: append([]byte(nil), b...), arrival: arrival}:
+	}
+
+	wait := time.NewTimer(time.Until(sent))
+	defer wait.Stop()
+	select {
+	case <-sc.closed:
+		return 0, net.ErrClosed
+	case <-deadlineC:
+		// the data is already on its way.
+		return len(b), nil
+	case <-wait.C:
+		return len(b), nil
+	}
+}
+
+// SetDeadline sets the deadlines of the underlying connection and of waiting to send.
+func (sc *simulatedConn) SetDeadline(t time.Time) error {
+	sc.mu.Lock()
+	sc.writeDeadline = t
+	sc.mu.Unlock()
+	return sc.Conn.SetDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection and of waiting to
+// send.
+func (sc *simulatedConn) SetWriteDeadline(t time.Time) error {
+	sc.mu.Lock()
+	sc.writeDeadline = t
+	sc.mu.Unlock()
+	return sc.Conn.SetWriteDeadline(t)
+}
+
+// Close discards what is in flight and closes the underlying connection.
+func (sc *simulatedConn) Close() error {
+	sc.closeOnce.Do(func() {
+		close(sc.closed)
+	})
+	// closing first unblocks a delivery stuck writing to a peer that is not reading.
+	err := sc.Conn.Close()
+	<-sc.done
+	return err
+}
+
+// SimulateListener returns a listener whose accepted connections are wrapped by
+// SimulateConn with the profile, such as for a gRPC server to be soak tested behind. Each
+// connection gets a link of its own.
+func SimulateListener(listener net.Listener, profile NetworkProfile) net.Listener {
+	return &simulatedListener{Listener: listener, profile: profile}
+}
+
+type simulatedListener struct {
+	net.Listener
+	profile NetworkProfile
+}
+
+func (sl *simulatedListener) Accept() (net.Conn, error) {
+	conn, err := sl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return SimulateConn(conn, sl.profile), nil
+}
+
+// SimulatedDialer returns a TCP dialer whose connections are wrapped by SimulateConn with the
+// profile, such as for grpc.WithContextDialer. Each connection gets a link of its own.
+func SimulatedDialer(profile NetworkProfile) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return SimulateConn(conn, profile), nil
+	}
+}
+
+// SimulatePacketConn returns a packet connection whose writes arrive at their destinations
+// with the latency, jitter, bandwidth, loss, and reordering of the profile. Packets that
+// would wait for bandwidth for more than a second are dropped. Only writes are affected, so
+// both ends must be wrapped to affect both directions.
+func SimulatePacketConn(conn net.PacketConn, profile NetworkProfile) net.PacketConn {
+	return &simulatedPacketConn{
+		PacketConn:  conn,
+		packets:     newSimulatedPackets(newSimulatedLink(profile)),
+		ownsPackets: true,
+	}
+}
+
+type simulatedPacketConn struct {
+	net.PacketConn
+	packets *simulatedPackets
+	// ownsPackets is unset when the link is shared by a simulated network.
+	ownsPackets bool
+}
+
+func (spc *simulatedPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	data := append([]byte(nil), p...)
+	return spc.packets.send(len(p), func() {
+		//nolint:errcheck
+		_, _ = spc.PacketConn.WriteTo(data, addr)
+	})
+}
+
+func (spc *simulatedPacketConn) Close() error {
+	if spc.ownsPackets {
+		spc.packets.close()
+	}
+	return spc.PacketConn.Close()
+}
+
+// simulatedPackets sends packets over a link once they arrive, as if the network had carried
+// them. Errors writing a packet that has arrived are ignored as any lost packet would be.
+// Packets are delivered by a goroutine that only runs while some are in flight, since a
+// simulated network is never closed.
+type simulatedPackets struct {
+	link *simulatedLink
+
+	mu         sync.Mutex
+	closed     bool
+	delivering bool
+	// inFlight is ordered by arrival, then by when packets were sent.
+	inFlight []simulatedPacket
+	// arrived is signaled when a packet that arrives before all others is sent or when
+	// closing.
+	arrived chan struct{}
+}
+
+type simulatedPacket struct {
+	write   func()
+	arrival time.Time
+}
+
+func newSimulatedPackets(link *simulatedLink) *simulatedPackets {
+	return &simulatedPackets{link: link, arrived: make(chan struct{}, 1)}
+}
+
+// send schedules a write of a packet of n bytes and returns once it has been sent.
+func (sp *simulatedPackets) send(n int, write func()) (int, error) {
+	sent, arrival, dropped := sp.link.schedule(n, false)
+	sp.mu.Lock()
+	if sp.closed {
+		sp.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	if !dropped {
+		idx := sort.Search(len(sp.inFlight), func(i int) bool {
+			return sp.inFlight[i].arrival.After(arrival)
+		})
+		sp.inFlight = append(sp.inFlight, simulatedPacket{})
+		copy(sp.inFlight[idx+1:], sp.inFlight[idx:])
+		sp.inFlight[idx] = simulatedPacket{write: write, arrival: arrival}
+		if idx == 0 {
+			sp.signalArrived()
+		}
+		if !sp.delivering {
+			sp.delivering = true
+			utils.PanicCapturingGo(sp.deliver)
+		}
+	}
+	sp.mu.Unlock()
+	time.Sleep(time.Until(sent))
+	return n, nil
+}
+
+// deliver writes packets as they arrive until none are in flight.
+func (sp *simulatedPackets) deliver() {
+	for {
+		sp.mu.Lock()
+		if sp.closed || len(sp.inFlight) == 0 {
+			sp.delivering = false
+			sp.mu.Unlock()
+			return
+		}
+		next := sp.inFlight[0]
+		if wait := time.Until(next.arrival); wait > 0 {
+			sp.mu.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-sp.arrived:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+		sp.inFlight = sp.inFlight[1:]
+		sp.mu.Unlock()
+		next.write()
+	}
+}
+
+func (sp *simulatedPackets) signalArrived() {
+	select {
+	case sp.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// close discards the packets in flight.
+func (sp *simulatedPackets) close() {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.closed = true
+	sp.inFlight = nil
+	sp.signalArrived()
+}
+
+// sleepUntil waits until the given time and returns true unless stop is closed first.
+func sleepUntil(t time.Time, stop <-chan struct{}) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// SimulateNet returns a network whose UDP sockets have their writes affected by the profile,
+// such as the virtual network of an rpc.InMemoryWebRTCChannelPair. All of its sockets share
+// one link, as they would the link of a host. Only writes are affected, so the networks of
+// both peers must be wrapped to affect both directions.
+func SimulateNet(n transport.Net, profile NetworkProfile) transport.Net {
+	return &simulatedNet{Net: n, packets: newSimulatedPackets(newSimulatedLink(profile))}
+}
+
+type simulatedNet struct {
+	transport.Net
+	packets *simulatedPackets
+}
+
+func (sn *simulatedNet) ListenPacket(network, address string) (net.PacketConn, error) {
+	conn, err := sn.Net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &simulatedPacketConn{PacketConn: conn, packets: sn.packets}, nil
+}
+
+func (sn *simulatedNet) ListenUDP(network string, locAddr *net.UDPAddr) (transport.UDPConn, error) {
+	conn, err := sn.Net.ListenUDP(network, locAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &simulatedUDPConn{UDPConn: conn, packets: sn.packets}, nil
+}
+
+func (sn *simulatedNet) DialUDP(network string, laddr, raddr *net.UDPAddr) (transport.UDPConn, error) {
+	conn, err := sn.Net.DialUDP(network, laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return &simulatedUDPConn{UDPConn: conn, packets: sn.packets}, nil
+}
+
+// simulatedUDPConn is a UDP socket of a simulated network. Packets it wrote are still
+// delivered after it is closed since they share the link of the network, though writing them
+// then fails.
+type simulatedUDPConn struct {
+	transport.UDPConn
+	packets *simulatedPackets
+}
+
+func (suc *simulatedUDPConn) Write(b []byte) (int, error) {
+	data := append([]byte(nil), b...)
+	return suc.packets.send(len(b), func() {
+		//nolint:errcheck
+		_, _ = suc.UDPConn.Write(data)
+	})
+}
+
+func (suc *simulatedUDPConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	data := append([]byte(nil), p...)
+	return suc.packets.send(len(p), func() {
+		//nolint:errcheck
+		_, _ = suc.UDPConn.WriteTo(data, addr)
+	})
+}
+
+func (suc *simulatedUDPConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	data := append([]byte(nil), b...)
+	return suc.packets.send(len(b), func() {
+		//nolint:errcheck
+		_, _ = suc.UDPConn.WriteToUDP(data, addr)
+	})
+}
+
+func (suc *simulatedUDPConn) WriteMsgUDP(b, oob []byte, addr *net.UDPAddr) (int, int, error) {
+	if len(oob) != 0 {
+		return 0, 0, errors.New("out-of-band data is not supported by simulated networks")
+	}
+	n, err := suc.WriteToUDP(b, addr)
+	return n, 0, err
+}
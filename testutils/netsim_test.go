@@ -0,0 +1,170 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestSimulateConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer listener.Close()
+	profile := NetworkProfile{
+		Latency:   50 * time.Millisecond,
+		Jitter:    20 * time.Millisecond,
+		Bandwidth: 100 << 10,
+		Seed:      1,
+	}
+	listener = SimulateListener(listener, profile)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	client, err := SimulatedDialer(profile)(context.Background(), listener.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+
+	t.Run("latency", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.Write([]byte("hello"))
+		test.That(t, err, test.ShouldBeNil)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(server, buf)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, string(buf), test.ShouldEqual, "hello")
+		test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, profile.Latency)
+	})
+
+	t.Run("bandwidth and ordering", func(t *testing.T) {
+		// 20KiB at 100KiB/s takes at least 200ms to send.
+		var expected bytes.Buffer
+		for i := 0; i < 20; i++ {
+			expected.Write(bytes.Repeat([]byte{byte(i)}, 1<<10))
+		}
+		received := make(chan []byte)
+		go func() {
+			buf := make([]byte, expected.Len())
+			//nolint:errcheck
+			_, _ = io.ReadFull(client, buf)
+			received <- buf
+		}()
+
+		start := time.Now()
+		data := expected.Bytes()
+		for i := 0; i < len(data); i += 1 << 10 {
+			_, err := server.Write(data[i : i+1<<10])
+			test.That(t, err, test.ShouldBeNil)
+		}
+		test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, 190*time.Millisecond)
+		test.That(t, <-received, test.ShouldResemble, data)
+	})
+
+	t.Run("write deadline", func(t *testing.T) {
+		test.That(t, client.SetWriteDeadline(time.Now().Add(-time.Second)), test.ShouldBeNil)
+		_, err := client.Write(make([]byte, 1<<10))
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, client.SetWriteDeadline(time.Time{}), test.ShouldBeNil)
+	})
+
+	test.That(t, client.Close(), test.ShouldBeNil)
+	_, err = client.Write([]byte("hello"))
+	test.That(t, err, test.ShouldBeError, net.ErrClosed)
+}
+
+func TestSimulatePacketConn(t *testing.T) {
+	listenPair := func(t *testing.T, profile NetworkProfile) (net.PacketConn, net.PacketConn) {
+		t.Helper()
+		sender, err := net.ListenPacket("udp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		receiver, err := net.ListenPacket("udp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		t.Cleanup(func() {
+			test.That(t, receiver.Close(), test.ShouldBeNil)
+		})
+		simulated := SimulatePacketConn(sender, profile)
+		t.Cleanup(func() {
+			test.That(t, simulated.Close(), test.ShouldBeNil)
+		})
+		return simulated, receiver
+	}
+	// receive returns the first byte of each packet received until none come for a while.
+	receive := func(t *testing.T, conn net.PacketConn) []int {
+		t.Helper()
+		var received []int
+		buf := make([]byte, 1500)
+		for {
+			test.That(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)), test.ShouldBeNil)
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return received
+			}
+			test.That(t, n, test.ShouldBeGreaterThan, 0)
+			received = append(received, int(buf[0]))
+		}
+	}
+
+	t.Run("latency", func(t *testing.T) {
+		sender, receiver := listenPair(t, NetworkProfile{Latency: 50 * time.Millisecond})
+		start := time.Now()
+		_, err := sender.WriteTo([]byte{1}, receiver.LocalAddr())
+		test.That(t, err, test.ShouldBeNil)
+		buf := make([]byte, 1)
+		_, _, err = receiver.ReadFrom(buf)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		sender, receiver := listenPair(t, NetworkProfile{Loss: 0.5, Seed: 1})
+		for i := 0; i < 100; i++ {
+			_, err := sender.WriteTo([]byte{byte(i)}, receiver.LocalAddr())
+			test.That(t, err, test.ShouldBeNil)
+		}
+		received := receive(t, receiver)
+		test.That(t, len(received), test.ShouldBeBetween, 25, 75)
+		test.That(t, sort.IntsAreSorted(received), test.ShouldBeTrue)
+	})
+
+	t.Run("reorder", func(t *testing.T) {
+		sender, receiver := listenPair(t, NetworkProfile{Latency: 20 * time.Millisecond, Reorder: 0.2, Seed: 1})
+		for i := 0; i < 50; i++ {
+			_, err := sender.WriteTo([]byte{byte(i)}, receiver.LocalAddr())
+			test.That(t, err, test.ShouldBeNil)
+		}
+		received := receive(t, receiver)
+		test.That(t, received, test.ShouldHaveLength, 50)
+		test.That(t, sort.IntsAreSorted(received), test.ShouldBeFalse)
+		sort.Ints(received)
+		for i, b := range received {
+			test.That(t, b, test.ShouldEqual, i)
+		}
+	})
+
+	t.Run("close discards in flight", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		receiver, err := net.ListenPacket("udp", "localhost:0")
+		test.That(t, err, test.ShouldBeNil)
+		defer receiver.Close()
+		sender := SimulatePacketConn(conn, NetworkProfile{Latency: 50 * time.Millisecond})
+		_, err = sender.WriteTo([]byte{1}, receiver.LocalAddr())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, sender.Close(), test.ShouldBeNil)
+		test.That(t, receive(t, receiver), test.ShouldBeEmpty)
+		_, err = sender.WriteTo([]byte{1}, receiver.LocalAddr())
+		test.That(t, err, test.ShouldBeError, net.ErrClosed)
+	})
+}
@@ -269,5 +269,8 @@ var fatal = func(t *testing.T, args ...interface{}) {
 // Teardown cleans up any temporary resources used by tests.
 func Teardown() {
 	teardownMongoDB()
+	if err := stopLocalMongoDB(); err != nil {
+		logger.Debugw("error stopping local MongoDB", "error", err)
+	}
 	http.DefaultClient.CloseIdleConnections()
 }
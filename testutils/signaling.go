@@ -0,0 +1,194 @@
+package testutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.viam.com/utils"
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+)
+
+// CorruptedICECandidate is what the candidates corrupted by a FakeSignalingServer are
+// replaced with.
+const CorruptedICECandidate = "candidate:corrupted"
+
+// A FakeSignalingServer is a signaling service that passes calls through to another, such
+// as an in-process rpc.WebRTCSignalingServer backed by a memory call queue, while injecting
+// faults on demand. Faults are counted down as they are injected so that tests of reconnect
+// logic can fail exactly the attempts they mean to. Only trickled candidates can be
+// corrupted; those in the SDP of calls with trickle ICE disabled are left alone.
+type FakeSignalingServer struct {
+	webrtcpb.UnimplementedSignalingServiceServer
+	signaling webrtcpb.SignalingServiceServer
+
+	mu                  sync.Mutex
+	offersToDrop        int
+	answerDelay         time.Duration
+	candidatesToCorrupt int
+	// released is closed by Reset to end the calls held as dropped.
+	released chan struct{}
+
+	calls               int
+	droppedOffers       int
+	delayedAnswers      int
+	corruptedCandidates int
+}
+
+// NewFakeSignalingServer returns a fake signaling server passing calls through to the given
+// one without any faults.
+func NewFakeSignalingServer(signaling webrtcpb.SignalingServiceServer) *FakeSignalingServer {
+	return &FakeSignalingServer{
+		signaling: signaling,
+		released:  make(chan struct{}),
+	}
+}
+
+// DropOffers makes the next n calls go unanswered, as if their offers were lost, until
+// the caller gives up.
+func (srv *FakeSignalingServer) DropOffers(n int) {
+	srv.mu.Lock()
+	srv.offersToDrop = n
+	srv.mu.Unlock()
+}
+
+// DelayAnswers holds back each answer for the given duration before passing it on to the
+// caller. A zero duration stops delaying answers.
+func (srv *FakeSignalingServer) DelayAnswers(delay time.Duration) {
+	srv.mu.Lock()
+	srv.answerDelay = delay
+	srv.mu.Unlock()
+}
+
+// CorruptCandidates replaces the next n ICE candidates trickled in either direction with
+// CorruptedICECandidate.
+func (srv *FakeSignalingServer) CorruptCandidates(n int) {
+	srv.mu.Lock()
+	srv.candidatesToCorrupt = n
+	srv.mu.Unlock()
+}
+
+// Reset stops injecting faults and ends any calls being held as dropped.
+func (srv *FakeSignalingServer) Reset() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.offersToDrop = 0
+	srv.answerDelay = 0
+	srv.candidatesToCorrupt = 0
+	close(srv.released)
+	srv.released = make(chan struct{})
+}
+
+// FakeSignalingStats counts what a FakeSignalingServer has seen and done.
+type FakeSignalingStats struct {
+	Calls               int
+	DroppedOffers       int
+	DelayedAnswers      int
+	CorruptedCandidates int
+}
+
+// Stats returns what the server has seen and done so far.
+func (srv *FakeSignalingServer) Stats() FakeSignalingStats {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return FakeSignalingStats{
+		Calls:               srv.calls,
+		DroppedOffers:       srv.droppedOffers,
+		DelayedAnswers:      srv.delayedAnswers,
+		CorruptedCandidates: srv.corruptedCandidates,
+	}
+}
+
+// Call passes the call through unless its offer is to be dropped, in which case it is held
+// until the caller gives up or the server is reset.
+func (srv *FakeSignalingServer) Call(req *webrtcpb.CallRequest, server webrtcpb.SignalingService_CallServer) error {
+	srv.mu.Lock()
+	srv.calls++
+	if srv.offersToDrop > 0 {
+		srv.offersToDrop--
+		srv.droppedOffers++
+		released := srv.released
+		srv.mu.Unlock()
+
+		select {
+		case <-server.Context().Done():
+			return server.Context().Err()
+		case <-released:
+			return status.Error(codes.Unavailable, "offer dropped")
+		}
+	}
+	srv.mu.Unlock()
+	return srv.signaling.Call(req, &fakeSignalingCallServer{server, srv})
+}
+
+// CallUpdate passes the update through, corrupting its candidate if asked to.
+func (srv *FakeSignalingServer) CallUpdate(
+	ctx context.Context,
+	req *webrtcpb.CallUpdateRequest,
+) (*webrtcpb.CallUpdateResponse, error) {
+	if req.GetCandidate() != nil && srv.takeCandidateCorruption() {
+		req = proto.Clone(req).(*webrtcpb.CallUpdateRequest)
+		req.GetCandidate().Candidate = CorruptedICECandidate
+	}
+	return srv.signaling.CallUpdate(ctx, req)
+}
+
+// Answer passes through to the wrapped server.
+func (srv *FakeSignalingServer) Answer(server webrtcpb.SignalingService_AnswerServer) error {
+	return srv.signaling.Answer(server)
+}
+
+// OptionalWebRTCConfig passes through to the wrapped server.
+func (srv *FakeSignalingServer) OptionalWebRTCConfig(
+	ctx context.Context,
+	req *webrtcpb.OptionalWebRTCConfigRequest,
+) (*webrtcpb.OptionalWebRTCConfigResponse, error) {
+	return srv.signaling.OptionalWebRTCConfig(ctx, req)
+}
+
+func (srv *FakeSignalingServer) takeCandidateCorruption() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.candidatesToCorrupt <= 0 {
+		return false
+	}
+	srv.candidatesToCorrupt--
+	srv.corruptedCandidates++
+	return true
+}
+
+func (srv *FakeSignalingServer) takeAnswerDelay() time.Duration {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.answerDelay > 0 {
+		srv.delayedAnswers++
+	}
+	return srv.answerDelay
+}
+
+// fakeSignalingCallServer injects faults into what is sent back to a caller.
+type fakeSignalingCallServer struct {
+	webrtcpb.SignalingService_CallServer
+	srv *FakeSignalingServer
+}
+
+func (s *fakeSignalingCallServer) Send(resp *webrtcpb.CallResponse) error {
+	switch {
+	case resp.GetInit() != nil:
+		if delay := s.srv.takeAnswerDelay(); delay > 0 {
+			if !utils.SelectContextOrWait(s.Context(), delay) {
+				return s.Context().Err()
+			}
+		}
+	case resp.GetUpdate().GetCandidate() != nil:
+		if s.srv.takeCandidateCorruption() {
+			resp = proto.Clone(resp).(*webrtcpb.CallResponse)
+			resp.GetUpdate().GetCandidate().Candidate = CorruptedICECandidate
+		}
+	}
+	return s.SignalingService_CallServer.Send(resp)
+}
@@ -0,0 +1,120 @@
+package testutils_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+	"google.golang.org/grpc"
+
+	webrtcpb "go.viam.com/utils/proto/rpc/webrtc/v1"
+	"go.viam.com/utils/rpc"
+	"go.viam.com/utils/rpc/examples/echo/echotest"
+	"go.viam.com/utils/testutils"
+)
+
+func TestFakeSignalingServer(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	const host = "yeehaw"
+
+	signalingServer := rpc.NewWebRTCSignalingServer(rpc.NewMemoryWebRTCCallQueue(logger), nil, logger)
+	defer signalingServer.Close()
+	fake := testutils.NewFakeSignalingServer(signalingServer)
+
+	signalingListener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&webrtcpb.SignalingService_ServiceDesc, fake)
+	serveDone := make(chan error)
+	go func() {
+		serveDone <- grpcServer.Serve(signalingListener)
+	}()
+	signalingAddr := signalingListener.Addr().String()
+
+	rpcServer, err := rpc.NewServer(
+		logger,
+		rpc.WithDisableMulticastDNS(),
+		rpc.WithUnauthenticated(),
+		rpc.WithWebRTCServerOptions(rpc.WebRTCServerOptions{
+			Enable:                    true,
+			ExternalSignalingAddress:  signalingAddr,
+			ExternalSignalingHosts:    []string{host},
+			ExternalSignalingDialOpts: []rpc.DialOption{rpc.WithInsecure()},
+		}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = echotest.Register(context.Background(), rpcServer)
+	test.That(t, err, test.ShouldBeNil)
+	listener, err := net.Listen("tcp", "localhost:0")
+	test.That(t, err, test.ShouldBeNil)
+	errChan := make(chan error)
+	go func() {
+		errChan <- rpcServer.Serve(listener)
+	}()
+
+	dial := func(t *testing.T, timeout time.Duration) (rpc.ClientConn, error) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return rpc.DialWebRTC(ctx, signalingAddr, host, logger,
+			rpc.WithWebRTCOptions(rpc.DialWebRTCOptions{SignalingInsecure: true}),
+		)
+	}
+	dialAndEcho := func(t *testing.T) {
+		t.Helper()
+		conn, err := dial(t, 10*time.Second)
+		test.That(t, err, test.ShouldBeNil)
+		echotest.AssertEcho(t, conn)
+		test.That(t, conn.Close(), test.ShouldBeNil)
+	}
+
+	t.Run("no faults", func(t *testing.T) {
+		dialAndEcho(t)
+		test.That(t, fake.Stats().Calls, test.ShouldEqual, 1)
+	})
+
+	t.Run("drop offers", func(t *testing.T) {
+		defer fake.Reset()
+		fake.DropOffers(1)
+		_, err := dial(t, time.Second)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, fake.Stats().DroppedOffers, test.ShouldEqual, 1)
+
+		// only as many offers as asked for are dropped
+		dialAndEcho(t)
+		test.That(t, fake.Stats().DroppedOffers, test.ShouldEqual, 1)
+	})
+
+	t.Run("delay answers", func(t *testing.T) {
+		defer fake.Reset()
+		fake.DelayAnswers(time.Second)
+		start := time.Now()
+		dialAndEcho(t)
+		test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, time.Second)
+		test.That(t, fake.Stats().DelayedAnswers, test.ShouldEqual, 1)
+
+		// an answer delayed past when the caller gives up never arrives
+		fake.DelayAnswers(10 * time.Second)
+		_, err := dial(t, time.Second)
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+
+	t.Run("corrupt candidates", func(t *testing.T) {
+		defer fake.Reset()
+		fake.CorruptCandidates(1000)
+		_, err := dial(t, 5*time.Second)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, fake.Stats().CorruptedCandidates, test.ShouldBeGreaterThan, 0)
+
+		fake.Reset()
+		dialAndEcho(t)
+	})
+
+	test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	test.That(t, <-errChan, test.ShouldBeNil)
+	grpcServer.Stop()
+	test.That(t, <-serveDone, test.ShouldBeNil)
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils"
+)
+
+// Defaults for where CSRFMiddleware looks for tokens.
+const (
+	DefaultCSRFHeader    = "X-CSRF-Token"
+	DefaultCSRFFormField = "csrf_token"
+)
+
+// csrfSessionKey is the key of the session data the CSRF token is stored under.
+const csrfSessionKey = "csrf_token"
+
+type csrfTokenKey struct{}
+
+// CSRFToken returns the CSRF token of the request's session, as set by CSRFMiddleware, so
+// that it can be passed on to templates and scripts. It returns an empty string if the
+// request did not pass through CSRFMiddleware.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// CSRFTemplateField returns a hidden form input holding the CSRF token under the default
+// form field. It is available to templates as csrfField, e.g. {{ csrfField .CSRFToken }}.
+func CSRFTemplateField(token string) template.HTML {
+	//nolint:gosec
+	return template.HTML(fmt.Sprintf(
+		`<input type="hidden" name="%s" value="%s">`,
+		DefaultCSRFFormField,
+		template.HTMLEscapeString(token),
+	))
+}
+
+// CSRFMiddleware protects a handler from cross-site request forgery by requiring
+// state-changing requests to carry a token tied to the session, either in a header or a
+// form field. Requests with safe methods (GET, HEAD, OPTIONS, and TRACE) are let through
+// and get a token issued if their session does not have one yet.
+type CSRFMiddleware struct {
+	Sessions *SessionManager
+	Handler  http.Handler
+	Logger   golog.Logger
+
+	// Header and FormField are where tokens are looked for.
+	Header    string
+	FormField string
+}
+
+// NewCSRFMiddleware returns a CSRFMiddleware looking for tokens in the default header and
+// form field.
+func NewCSRFMiddleware(sessions *SessionManager, h http.Handler, logger golog.Logger) *CSRFMiddleware {
+	return &CSRFMiddleware{
+		Sessions:  sessions,
+		Handler:   h,
+		Logger:    logger,
+		Header:    DefaultCSRFHeader,
+		FormField: DefaultCSRFFormField,
+	}
+}
+
+func (cm *CSRFMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		token, err := cm.issueToken(w, r)
+		if HandleError(w, err, cm.Logger, "error issuing CSRF token") {
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+	default:
+		token, err := cm.sessionToken(r)
+		if HandleError(w, err, cm.Logger, "error checking CSRF token") {
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cm.requestToken(r))) != 1 {
+			cm.Logger.Infow("rejecting request with invalid CSRF token", "method", r.Method, "path", r.URL.Path)
+			writeCSRFError(w)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+	}
+	cm.Handler.ServeHTTP(w, r)
+}
+
+// issueToken returns the token of the request's session, creating both if needed.
+func (cm *CSRFMiddleware) issueToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	session, err := cm.Sessions.Get(r, true)
+	if err != nil {
+		return "", err
+	}
+	token, _, err := GetValue[string](session, csrfSessionKey)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		return token, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	session.SetValue(csrfSessionKey, token)
+	if err := session.Save(r.Context(), r, w); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// sessionToken returns the token of the request's session, if any.
+func (cm *CSRFMiddleware) sessionToken(r *http.Request) (string, error) {
+	session, err := cm.Sessions.Get(r, false)
+	if err != nil {
+		if errors.Is(err, ErrNoSession) {
+			return "", nil
+		}
+		return "", err
+	}
+	token, _, err := GetValue[string](session, csrfSessionKey)
+	return token, err
+}
+
+// requestToken returns the token the request carries, if any.
+func (cm *CSRFMiddleware) requestToken(r *http.Request) string {
+	if token := r.Header.Get(cm.Header); token != "" {
+		return token
+	}
+	return r.PostFormValue(cm.FormField)
+}
+
+func writeCSRFError(w http.ResponseWriter) {
+	js, err := json.Marshal(map[string]interface{}{
+		"err":  "invalid CSRF token",
+		"code": "csrf_token_invalid",
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_, err = w.Write(js)
+	utils.UncheckedError(err)
+}
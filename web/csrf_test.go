@@ -0,0 +1,90 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	sm := NewSessionManager(&memorySessionStore{}, logger)
+
+	var handled int
+	var handledToken string
+	cm := NewCSRFMiddleware(sm, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled++
+		handledToken = CSRFToken(r)
+	}), logger)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		cm.ServeHTTP(w, r)
+		return w
+	}
+
+	// without a session, state-changing requests are rejected.
+	w := serve(httptest.NewRequest(http.MethodPost, "/", nil))
+	test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+	test.That(t, w.Body.String(), test.ShouldContainSubstring, "csrf_token_invalid")
+	test.That(t, handled, test.ShouldEqual, 0)
+
+	w = serve(httptest.NewRequest(http.MethodGet, "/", nil))
+	test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+	test.That(t, handled, test.ShouldEqual, 1)
+	token := handledToken
+	test.That(t, token, test.ShouldNotBeEmpty)
+	cookies := w.Result().Cookies()
+	test.That(t, cookies, test.ShouldHaveLength, 1)
+	sessionCookie := cookies[0]
+
+	// the token stays the same for the session.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(sessionCookie)
+	w = serve(r)
+	test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+	test.That(t, handledToken, test.ShouldEqual, token)
+	test.That(t, w.Result().Cookies(), test.ShouldBeEmpty)
+
+	t.Run("header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.AddCookie(sessionCookie)
+		r.Header.Set(DefaultCSRFHeader, token)
+		w := serve(r)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+		test.That(t, handledToken, test.ShouldEqual, token)
+	})
+
+	t.Run("form field", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{DefaultCSRFFormField: []string{token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(sessionCookie)
+		w := serve(r)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusOK)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		before := handled
+		r := httptest.NewRequest(http.MethodDelete, "/", nil)
+		r.AddCookie(sessionCookie)
+		r.Header.Set(DefaultCSRFHeader, token+"x")
+		w := serve(r)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+		test.That(t, w.Header().Get("Content-Type"), test.ShouldEqual, "application/json")
+
+		r = httptest.NewRequest(http.MethodPut, "/", nil)
+		r.AddCookie(sessionCookie)
+		w = serve(r)
+		test.That(t, w.Code, test.ShouldEqual, http.StatusForbidden)
+		test.That(t, handled, test.ShouldEqual, before)
+	})
+}
+
+func TestCSRFTemplateField(t *testing.T) {
+	test.That(t, string(CSRFTemplateField(`a"b`)), test.ShouldEqual, `<input type="hidden" name="csrf_token" value="a&#34;b">`)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/edaniels/golog"
 
 	"go.viam.com/utils"
+	"go.viam.com/utils/errs"
 )
 
 // ErrorResponse lets you specify a status code.
@@ -42,6 +43,9 @@ func HandleError(w http.ResponseWriter, err error, logger golog.Logger, context
 	var er ErrorResponse
 	if errors.As(err, &er) {
 		statusCode = er.Status()
+	} else if category := errs.CategoryOf(err); category != errs.Unknown {
+		// such as errors of remote calls carrying a gRPC status.
+		statusCode = category.HTTPStatus()
 	}
 
 	// Log internal errors.
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.viam.com/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.viam.com/utils/errs"
+)
+
+func TestHandleError(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{errors.New("whoops"), http.StatusInternalServerError},
+		{ErrorResponseStatus(http.StatusTeapot), http.StatusTeapot},
+		{errors.Wrap(errs.NotFound.Errorf("no such robot"), "getting robot"), http.StatusNotFound},
+		{status.Error(codes.PermissionDenied, "no"), http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		test.That(t, HandleError(w, tc.err, logger, "doing it"), test.ShouldBeTrue)
+		test.That(t, w.Code, test.ShouldEqual, tc.status)
+		test.That(t, w.Body.String(), test.ShouldEqual, "doing it\n"+tc.err.Error()+"\n")
+	}
+	test.That(t, HandleError(httptest.NewRecorder(), nil, logger), test.ShouldBeFalse)
+}
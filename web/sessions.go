@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/edaniels/golog"
@@ -15,6 +17,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.opencensus.io/trace"
 
+	"go.viam.com/utils"
 	mongoutils "go.viam.com/utils/mongo"
 )
 
@@ -29,9 +32,10 @@ var webSessionsIndex = []mongo.IndexModel{
 
 // SessionManager handles working with sessions from http.
 type SessionManager struct {
-	store      Store
-	cookieName string
-	logger     golog.Logger
+	store   Store
+	logger  golog.Logger
+	opts    sessionManagerOptions
+	workers *utils.StoppableWorkers
 }
 
 // Session representation of a session.
@@ -41,11 +45,30 @@ type Session struct {
 
 	isNew bool
 
-	id   string
-	Data bson.M
+	// secure is whether the session's cookie should only be sent over HTTPS, based on the
+	// request the session was last retrieved or saved with.
+	secure bool
+
+	// version is the schema version of Data.
+	version int
+
+	// created is when the session was first created and lastUpdate is when it was last
+	// saved; both are used to expire it.
+	created    time.Time
+	lastUpdate time.Time
+
+	// revision is the revision of Data last loaded from or saved to stores that detect
+	// concurrent modification.
+	revision int64
+
+	id string
+
+	// Data is the session's data. GetValue and SetValue provide typed access to it.
+	Data map[string]interface{}
 }
 
-// Store actually stores raw data somewhere.
+// Store actually stores raw data somewhere. Get returns ErrNoSession if there is no
+// session with the ID.
 type Store interface {
 	Delete(ctx context.Context, id string) error
 	Get(ctx context.Context, id string) (*Session, error)
@@ -53,81 +76,296 @@ type Store interface {
 	SetSessionManager(*SessionManager)
 }
 
+// An expiredSessionSweeper is a Store that cannot expire sessions on its own and instead
+// has expired sessions deleted from it by SweepExpiredSessions.
+type expiredSessionSweeper interface {
+	deleteExpired(ctx context.Context, expired func(s *Session) bool) error
+}
+
+// A userSessionDeleter is a Store that can delete all sessions belonging to a user, as
+// identified by the value of a field of their data.
+type userSessionDeleter interface {
+	deleteAllForUser(ctx context.Context, field, userKey string) error
+}
+
+// A sessionError is an error of the session machinery that tells whether the client or
+// the server is at fault through its HTTP status, which HandleError responds with.
+type sessionError struct {
+	msg    string
+	status int
+	parent error
+}
+
+func (e *sessionError) Error() string {
+	return e.msg
+}
+
+func (e *sessionError) Status() int {
+	return e.status
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.parent
+}
+
+// Errors returned by SessionManager.Get and stores. Whether there is a usable session
+// should be checked with errors.Is(err, ErrNoSession), which also holds for ErrExpired.
+// Any other error is wrapped in ErrStoreUnavailable.
+var (
+	// ErrNoSession means the request has no session, which is a client error.
+	ErrNoSession error = &sessionError{msg: "no session found", status: http.StatusUnauthorized}
+
+	// ErrExpired means the request's session has expired and was deleted.
+	ErrExpired error = &sessionError{msg: "session expired", status: http.StatusUnauthorized, parent: ErrNoSession}
+
+	// ErrStoreUnavailable means the session store failed, which is a server error that may
+	// be temporary.
+	ErrStoreUnavailable error = &sessionError{msg: "session store unavailable", status: http.StatusServiceUnavailable}
+)
+
 // ----
 
 // NewSessionManager creates a new SessionManager.
-func NewSessionManager(theStore Store, logger golog.Logger) *SessionManager {
-	sm := &SessionManager{store: theStore, cookieName: "session-id", logger: logger}
+func NewSessionManager(theStore Store, logger golog.Logger, opts ...SessionManagerOption) *SessionManager {
+	var sOpts sessionManagerOptions
+	for _, opt := range opts {
+		opt.apply(&sOpts)
+	}
+	if sOpts.cookie.Name == "" {
+		sOpts.cookie.Name = "session-id"
+	}
+	if sOpts.cookie.Path == "" {
+		sOpts.cookie.Path = "/"
+	}
+	if sOpts.cookie.SameSite == 0 {
+		sOpts.cookie.SameSite = http.SameSiteLaxMode
+	}
+	if sOpts.cookie.MaxAge == 0 {
+		sOpts.cookie.MaxAge = 7 * 24 * time.Hour
+	}
+	sm := &SessionManager{
+		store:   theStore,
+		logger:  logger,
+		opts:    sOpts,
+		workers: utils.NewStoppableWorkersWithContext(context.Background(), logger),
+	}
 	theStore.SetSessionManager(sm)
+	if sOpts.sweepInterval > 0 {
+		sm.workers.AddWorkers(func(ctx context.Context) {
+			sm.SweepExpiredSessions(ctx, sOpts.sweepInterval)
+		})
+	}
 	return sm
 }
 
-// Get get a session from the request via cookies.
-func (sm *SessionManager) Get(r *http.Request, createIfNotExist bool) (*Session, error) {
-	var s *Session
-	id := ""
-
-	c, err := r.Cookie(sm.cookieName)
-	if !errors.Is(err, http.ErrNoCookie) {
-		if c == nil {
-			panic("wtf")
-		}
-		if err != nil {
-			panic("wtf")
-		}
-		id = c.Value
+// Close stops the manager's background work, such as sweeping expired sessions.
+func (sm *SessionManager) Close() {
+	sm.workers.Stop()
+}
 
-		s, err = sm.store.Get(r.Context(), id)
-		if err != nil && !errors.Is(err, errNoSession) {
-			return nil, fmt.Errorf("couldn't get cookie from store: %w", err)
-		}
+// Get get a session from the request via cookies. If the request has no usable session
+// and createIfNotExist is false, the error is an ErrNoSession, or more specifically an
+// ErrExpired if the session expired. Failures of the store are an ErrStoreUnavailable.
+func (sm *SessionManager) Get(r *http.Request, createIfNotExist bool) (*Session, error) {
+	noSession := ErrNoSession
 
-		if s != nil {
+	if c, err := r.Cookie(sm.opts.cookie.Name); err == nil && c.Value != "" {
+		s, err := sm.load(r, c.Value)
+		switch {
+		case err == nil:
 			return s, nil
+		case errors.Is(err, ErrNoSession):
+			noSession = err
+		default:
+			return nil, err
 		}
 	}
 
 	if !createIfNotExist {
-		return nil, errNoSession
+		return nil, noSession
 	}
 
-	if id == "" {
-		id, err = sm.newID()
-		if err != nil {
-			return nil, fmt.Errorf("couldn't create new id: %w", err)
-		}
+	// an ID from a cookie that did not match a session is never reused so that clients
+	// cannot choose the ID of a session someone else will log in to.
+	id, err := sm.newID()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create new id: %w", err)
 	}
 
-	s = &Session{
+	return &Session{
 		store:   sm.store,
 		manager: sm,
 		isNew:   true,
+		secure:  r.TLS != nil,
+		version: sm.opts.dataVersion,
+		created: time.Now(),
 		id:      id,
-		Data:    bson.M{},
+		Data:    map[string]interface{}{},
+	}, nil
+}
+
+// load loads the session with the ID from the store for the request, deleting it if it
+// has expired or cannot be migrated.
+func (sm *SessionManager) load(r *http.Request, id string) (*Session, error) {
+	s, err := sm.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrNoSession) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: couldn't get session from store: %w", ErrStoreUnavailable, err)
+	}
+	if s == nil {
+		return nil, ErrNoSession
+	}
+
+	if sm.expired(s, time.Now()) {
+		if err := sm.store.Delete(r.Context(), id); err != nil {
+			sm.logger.Errorw("cannot delete expired session", "error", err)
+		} else {
+			sm.notifyDestroyed(r.Context(), id, s.Data)
+		}
+		return nil, ErrExpired
+	}
+
+	data := s.Data
+	if err := sm.migrate(r.Context(), s); err != nil {
+		sm.logger.Errorw("cannot migrate session data; discarding session", "error", err)
+		if err := sm.store.Delete(r.Context(), id); err != nil {
+			sm.logger.Errorw("cannot delete session", "error", err)
+		} else {
+			sm.notifyDestroyed(r.Context(), id, data)
+		}
+		return nil, ErrNoSession
 	}
 
+	s.secure = r.TLS != nil
+	sm.notify(s.Data, func(o SessionObserver, data map[string]interface{}) {
+		o.SessionLoaded(r.Context(), id, data)
+	})
 	return s, nil
 }
 
 // DeleteSession deletes a session.
 func (sm *SessionManager) DeleteSession(ctx context.Context, r *http.Request, w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     sm.cookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-		HttpOnly: true,
-	})
+	cookie := sm.cookie("", r.TLS != nil)
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
 
-	c, err := r.Cookie(sm.cookieName)
+	c, err := r.Cookie(sm.opts.cookie.Name)
 	if err == nil {
 		err = sm.store.Delete(ctx, c.Value)
 		if err != nil {
 			sm.logger.Errorw("cannot delete cookie", "error", err)
+		} else {
+			sm.notifyDestroyed(ctx, c.Value, nil)
+		}
+	}
+}
+
+// cookie returns a session cookie with the given value and the manager's attributes.
+func (sm *SessionManager) cookie(value string, secure bool) *http.Cookie {
+	opts := sm.opts.cookie
+	cookie := &http.Cookie{
+		Name:     opts.Name,
+		Value:    value,
+		Domain:   opts.Domain,
+		Path:     opts.Path,
+		Secure:   secure,
+		SameSite: opts.SameSite,
+		HttpOnly: true,
+	}
+	if opts.MaxAge > 0 {
+		cookie.MaxAge = int(opts.MaxAge / time.Second)
+	}
+	if opts.HttpOnly != nil {
+		cookie.HttpOnly = *opts.HttpOnly
+	}
+	if opts.Secure != nil {
+		cookie.Secure = *opts.Secure
+	}
+	return cookie
+}
+
+// notifyDestroyed notifies the manager's observers that the session was deleted.
+func (sm *SessionManager) notifyDestroyed(ctx context.Context, id string, data map[string]interface{}) {
+	sm.notify(data, func(o SessionObserver, data map[string]interface{}) {
+		o.SessionDestroyed(ctx, id, data)
+	})
+}
+
+// migrate upgrades the session's data to the manager's current schema version.
+func (sm *SessionManager) migrate(ctx context.Context, s *Session) error {
+	if s.version >= sm.opts.dataVersion {
+		return nil
+	}
+	if sm.opts.migrate == nil {
+		return errors.Errorf("no migration from session data version %d to %d", s.version, sm.opts.dataVersion)
+	}
+	data, err := sm.opts.migrate(ctx, s.version, s.Data)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	s.Data = data
+	s.version = sm.opts.dataVersion
+	return nil
+}
+
+// expired returns whether the session has outlived either of the manager's timeouts.
+// Sessions whose timestamps are unknown, like those saved before they were recorded,
+// do not expire by that timeout.
+func (sm *SessionManager) expired(s *Session, now time.Time) bool {
+	if sm.opts.absoluteTimeout > 0 && !s.created.IsZero() && now.Sub(s.created) > sm.opts.absoluteTimeout {
+		return true
+	}
+	if sm.opts.idleTimeout > 0 && !s.lastUpdate.IsZero() && now.Sub(s.lastUpdate) > sm.opts.idleTimeout {
+		return true
+	}
+	return false
+}
+
+// SweepExpiredSessions deletes expired sessions from the manager's store every interval
+// until the context is done, for stores that cannot expire sessions on their own, like
+// the memory and SQL stores. For any other store it returns immediately. It should be run in its
+// own goroutine, or instead be run in the background with WithSessionSweepInterval.
+func (sm *SessionManager) SweepExpiredSessions(ctx context.Context, interval time.Duration) {
+	sweeper, ok := sm.store.(expiredSessionSweeper)
+	if !ok {
+		return
+	}
+	task := utils.StartPeriodicTask(ctx, utils.PeriodicTaskConfig{Interval: interval}, func(ctx context.Context) error {
+		if err := sweeper.deleteExpired(ctx, func(s *Session) bool {
+			return sm.expired(s, time.Now())
+		}); err != nil {
+			sm.logger.Errorw("cannot delete expired sessions", "error", err)
+			return err
 		}
+		return nil
+	})
+	<-ctx.Done()
+	task.Stop()
+}
+
+// DeleteAllForUser deletes every session whose data identifies it as belonging to the
+// given user under the field set with WithSessionUserKeyField, logging the user out
+// everywhere. It should be called when a user's credentials change or their access is
+// revoked. Only the MongoDB and memory stores support it; stores that keep sessions in
+// cookies cannot reach them.
+func (sm *SessionManager) DeleteAllForUser(ctx context.Context, userKey string) error {
+	field, err := sessionUserKeyField(sm)
+	if err != nil {
+		return err
+	}
+	if userKey == "" {
+		return errors.New("user key must not be empty")
+	}
+	deleter, ok := sm.store.(userSessionDeleter)
+	if !ok {
+		return errors.Errorf("session store %T does not support deleting sessions by user", sm.store)
 	}
+	return deleter.deleteAllForUser(ctx, field, userKey)
 }
 
 func (sm *SessionManager) newID() (string, error) {
@@ -142,33 +380,151 @@ func (sm *SessionManager) newID() (string, error) {
 // ----
 
 // Save saves a session back to the store it came freom.
+// The cookie is (re)set if the session is new or the store changed its ID.
 func (s *Session) Save(ctx context.Context, r *http.Request, w http.ResponseWriter) error {
+	s.secure = r.TLS != nil
+	return s.save(ctx, w)
+}
+
+func (s *Session) save(ctx context.Context, w http.ResponseWriter) error {
+	oldID := s.id
+	s.lastUpdate = time.Now()
+	if s.created.IsZero() {
+		s.created = s.lastUpdate
+	}
+	if err := s.store.Save(ctx, s); err != nil {
+		return err
+	}
 	if s.isNew {
-		http.SetCookie(w, &http.Cookie{
-			Name:     s.manager.cookieName,
-			Value:    s.id,
-			Path:     "/",
-			MaxAge:   86400 * 7,
-			Secure:   r.TLS != nil,
-			SameSite: http.SameSiteLaxMode,
-			HttpOnly: true,
+		s.manager.notify(s.Data, func(o SessionObserver, data map[string]interface{}) {
+			o.SessionCreated(ctx, s.id, data)
 		})
+	}
+	s.manager.notify(s.Data, func(o SessionObserver, data map[string]interface{}) {
+		o.SessionSaved(ctx, s.id, data)
+	})
+	if s.isNew || s.id != oldID {
+		http.SetCookie(w, s.manager.cookie(s.id, s.secure))
 		s.isNew = false
 	}
-	return s.store.Save(ctx, s)
+	return nil
+}
+
+// Regenerate moves the session's data to a new ID, deleting the session stored under the
+// old one, and sets the new ID as the session's cookie. It should be called whenever the
+// privileges of the session change, such as after logging in, so that an ID someone else
+// may have learned or planted beforehand is useless.
+func (s *Session) Regenerate(ctx context.Context, w http.ResponseWriter) error {
+	newID, err := s.manager.newID()
+	if err != nil {
+		return fmt.Errorf("couldn't create new id: %w", err)
+	}
+
+	oldID, oldRevision, wasNew := s.id, s.revision, s.isNew
+	s.id, s.revision, s.isNew = newID, 0, true
+	if err := s.save(ctx, w); err != nil {
+		s.id, s.revision, s.isNew = oldID, oldRevision, wasNew
+		return err
+	}
+
+	if !wasNew {
+		if err := s.store.Delete(ctx, oldID); err != nil {
+			return fmt.Errorf("couldn't delete session under old id: %w", err)
+		}
+		s.manager.notifyDestroyed(ctx, oldID, s.Data)
+	}
+	return nil
 }
 
 // -----
 
 // NewMongoDBSessionStore new MongoDB backed store.
 func NewMongoDBSessionStore(ctx context.Context, coll *mongo.Collection) (Store, error) {
-	if err := mongoutils.EnsureIndexes(ctx, coll, webSessionsIndex...); err != nil {
+	// the expiry of sessions may be tuned with EnsureMongoDBSessionTTLIndexes.
+	if _, err := mongoutils.EnsureIndexesWithOptions(
+		ctx, coll, mongoutils.EnsureIndexesOptions{IgnoreTTL: true}, webSessionsIndex...,
+	); err != nil {
 		return nil, errors.Wrap(err, "Failed to create indexes for webSessionsCollection")
 	}
 
 	return &mongoDBSessionStore{collection: coll}, nil
 }
 
+// EnsureMongoDBSessionTTLIndexes makes MongoDB delete sessions from the collection once
+// they expire under the given timeouts, which should match those of the SessionManager
+// (see WithSessionAbsoluteTimeout and WithSessionIdleTimeout). Existing expiry indexes are
+// updated in place. A zero timeout leaves the corresponding index untouched.
+func EnsureMongoDBSessionTTLIndexes(ctx context.Context, coll *mongo.Collection, absoluteTimeout, idleTimeout time.Duration) error {
+	ctx, span := trace.StartSpan(ctx, "EnsureMongoDBSessionTTLIndexes")
+	defer span.End()
+
+	for _, ttl := range []struct {
+		key     string
+		timeout time.Duration
+	}{
+		{"created", absoluteTimeout},
+		{"lastUpdate", idleTimeout},
+	} {
+		if ttl.timeout <= 0 {
+			continue
+		}
+		if err := ensureMongoDBTTLIndex(ctx, coll, ttl.key, int32(ttl.timeout/time.Second)); err != nil {
+			return errors.Wrapf(err, "failed to ensure expiry index on %q", ttl.key)
+		}
+	}
+	return nil
+}
+
+// ensureMongoDBTTLIndex creates or updates the single field index on key so that documents
+// expire the given number of seconds after the time stored at key.
+func ensureMongoDBTTLIndex(ctx context.Context, coll *mongo.Collection, key string, seconds int32) error {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		keys, ok := index["key"].(bson.M)
+		if !ok || len(keys) != 1 || keys[key] == nil {
+			continue
+		}
+		if current, ok := index["expireAfterSeconds"]; ok && fmt.Sprint(current) == fmt.Sprint(seconds) {
+			return nil
+		}
+		return coll.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: index["name"]},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+	}
+
+	return mongoutils.EnsureIndexes(ctx, coll, mongo.IndexModel{
+		Keys:    bson.D{{Key: key, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+}
+
+// EnsureMongoDBSessionUserIndex indexes the session data field identifying the user a
+// session belongs to, as set with WithSessionUserKeyField, so that
+// SessionManager.DeleteAllForUser does not scan the whole collection.
+func EnsureMongoDBSessionUserIndex(ctx context.Context, coll *mongo.Collection, field string) error {
+	ctx, span := trace.StartSpan(ctx, "EnsureMongoDBSessionUserIndex")
+	defer span.End()
+
+	if err := mongoutils.EnsureIndexes(ctx, coll, mongo.IndexModel{
+		Keys: bson.D{{Key: "data." + field, Value: 1}},
+	}); err != nil {
+		return errors.Wrapf(err, "failed to ensure index on session user key %q", field)
+	}
+	return nil
+}
+
 type mongoDBSessionStore struct {
 	collection *mongo.Collection
 	manager    *SessionManager
@@ -186,7 +542,21 @@ func (mss *mongoDBSessionStore) Delete(ctx context.Context, id string) error {
 	return err
 }
 
-var errNoSession = errors.New("no session found")
+func (mss *mongoDBSessionStore) deleteAllForUser(ctx context.Context, field, userKey string) error {
+	ctx, span := trace.StartSpan(ctx, "MongoDBSessionStore::DeleteAllForUser")
+	defer span.End()
+
+	_, err := mss.collection.DeleteMany(ctx, bson.M{"data." + field: userKey})
+	return err
+}
+
+// mongoDBSession is how a session is stored in its document.
+type mongoDBSession struct {
+	Version    int                    `bson:"version"`
+	Created    time.Time              `bson:"created"`
+	LastUpdate time.Time              `bson:"lastUpdate"`
+	Data       map[string]interface{} `bson:"data"`
+}
 
 func (mss *mongoDBSessionStore) Get(ctx context.Context, id string) (*Session, error) {
 	ctx, span := trace.StartSpan(ctx, "MongoDBSessionStore::Get")
@@ -195,22 +565,24 @@ func (mss *mongoDBSessionStore) Get(ctx context.Context, id string) (*Session, e
 	res := mss.collection.FindOne(ctx, bson.M{"_id": id})
 	if res.Err() != nil {
 		if errors.Is(res.Err(), mongo.ErrNoDocuments) {
-			return nil, errNoSession
+			return nil, ErrNoSession
 		}
 		return nil, fmt.Errorf("couldn't load session from db: %w", res.Err())
 	}
 
-	m := bson.M{}
-	if err := res.Decode(&m); err != nil {
+	var doc mongoDBSession
+	if err := res.Decode(&doc); err != nil {
 		return nil, err
 	}
-
 	s := &Session{
-		store:   mss,
-		manager: mss.manager,
-		isNew:   false,
-		id:      id,
-		Data:    m["data"].(bson.M),
+		store:      mss,
+		manager:    mss.manager,
+		isNew:      false,
+		version:    doc.Version,
+		created:    doc.Created,
+		lastUpdate: doc.LastUpdate,
+		id:         id,
+		Data:       sessionDataFromBSON(doc.Data),
 	}
 
 	return s, nil
@@ -222,7 +594,9 @@ func (mss *mongoDBSessionStore) Save(ctx context.Context, s *Session) error {
 
 	doc := bson.M{
 		"_id":        s.id,
-		"lastUpdate": time.Now(),
+		"version":    s.version,
+		"created":    s.created,
+		"lastUpdate": s.lastUpdate,
 		"data":       s.Data,
 	}
 
@@ -237,38 +611,129 @@ func (mss *mongoDBSessionStore) Save(ctx context.Context, s *Session) error {
 
 // ------
 
-// NewMemorySessionStore creates a new memory session store.
+// MemorySessionStoreOptions configure a memory store.
+type MemorySessionStoreOptions struct {
+	// MaxEntries is how many sessions are kept before the least recently used ones are
+	// evicted. Zero means no limit.
+	MaxEntries int
+
+	// TTL is how long a session is kept after it was last saved. Zero means forever.
+	TTL time.Duration
+}
+
+// NewMemorySessionStore creates a new memory session store without limits. It is the store
+// to use in tests.
 func NewMemorySessionStore() Store {
 	return &memorySessionStore{}
 }
 
+// NewMemorySessionStoreWithOptions creates a new memory session store. Allows limiting how
+// many sessions are kept and for how long.
+func NewMemorySessionStoreWithOptions(opts MemorySessionStoreOptions) Store {
+	return &memorySessionStore{maxEntries: opts.MaxEntries, ttl: opts.TTL}
+}
+
+// A memorySessionStore keeps copies of sessions so that handlers working on the same
+// session concurrently do not share its data.
 type memorySessionStore struct {
-	data    map[string]*Session
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*memorySessionEntry
 	manager *SessionManager
 }
 
+type memorySessionEntry struct {
+	session *Session
+	// expires is zero if the session never expires.
+	expires time.Time
+	// lastUsed is the time in Unix nanoseconds the session was last retrieved or saved. It
+	// is updated while only holding the read lock.
+	lastUsed atomic.Int64
+}
+
 func (mss *memorySessionStore) SetSessionManager(sm *SessionManager) {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
 	mss.manager = sm
 }
 
 func (mss *memorySessionStore) Delete(ctx context.Context, id string) error {
-	if mss.data != nil {
-		mss.data[id] = nil
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	delete(mss.entries, id)
+	return nil
+}
+
+func (mss *memorySessionStore) deleteAllForUser(ctx context.Context, field, userKey string) error {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	for id, entry := range mss.entries {
+		if key, ok := entry.session.Data[field].(string); ok && key == userKey {
+			delete(mss.entries, id)
+		}
 	}
 	return nil
 }
 
 func (mss *memorySessionStore) Get(ctx context.Context, id string) (*Session, error) {
-	if mss.data == nil {
-		return nil, errNoSession
+	now := time.Now()
+	mss.mu.RLock()
+	defer mss.mu.RUnlock()
+	entry, ok := mss.entries[id]
+	if !ok || (!entry.expires.IsZero() && now.After(entry.expires)) {
+		return nil, ErrNoSession
 	}
-	return mss.data[id], nil
+	entry.lastUsed.Store(now.UnixNano())
+
+	s := copySession(entry.session)
+	s.store = mss
+	s.manager = mss.manager
+	return s, nil
 }
 
 func (mss *memorySessionStore) Save(ctx context.Context, s *Session) error {
-	if mss.data == nil {
-		mss.data = map[string]*Session{}
+	now := time.Now()
+	entry := &memorySessionEntry{session: copySession(s)}
+	if mss.ttl > 0 {
+		entry.expires = now.Add(mss.ttl)
+	}
+	entry.lastUsed.Store(now.UnixNano())
+
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	if mss.entries == nil {
+		mss.entries = map[string]*memorySessionEntry{}
+	}
+	mss.entries[s.id] = entry
+	if mss.maxEntries > 0 && len(mss.entries) > mss.maxEntries {
+		mss.evictLeastRecentlyUsed()
+	}
+	return nil
+}
+
+// evictLeastRecentlyUsed removes the session that was used longest ago. It must be called
+// while holding the write lock.
+func (mss *memorySessionStore) evictLeastRecentlyUsed() {
+	var oldestID string
+	var oldest int64
+	for id, entry := range mss.entries {
+		if lastUsed := entry.lastUsed.Load(); oldestID == "" || lastUsed < oldest {
+			oldestID, oldest = id, lastUsed
+		}
+	}
+	delete(mss.entries, oldestID)
+}
+
+func (mss *memorySessionStore) deleteExpired(ctx context.Context, expired func(s *Session) bool) error {
+	now := time.Now()
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	for id, entry := range mss.entries {
+		if (!entry.expires.IsZero() && now.After(entry.expires)) || expired(entry.session) {
+			delete(mss.entries, id)
+		}
 	}
-	mss.data[s.id] = s
 	return nil
 }
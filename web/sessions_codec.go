@@ -0,0 +1,191 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A SessionCodec encodes sessions for stores that keep them as bytes, like the cookie and
+// Redis stores. The MongoDB store always keeps sessions as BSON documents so that they
+// can be queried.
+type SessionCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Available SessionCodecs. Values decoded by JSONSessionCodec and BSONSessionCodec have
+// generic types (e.g. float64 or map[string]interface{}), which GetValue converts back
+// into the types they were stored as. GobSessionCodec preserves types, but any types
+// stored other than Go's basic types must be registered with gob.Register.
+var (
+	JSONSessionCodec SessionCodec = jsonSessionCodec{}
+	BSONSessionCodec SessionCodec = bsonSessionCodec{}
+	GobSessionCodec  SessionCodec = gobSessionCodec{}
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	// sessions encoded before Session.Data was a plain map may hold these.
+	gob.Register(bson.M{})
+	gob.Register(bson.A{})
+}
+
+type jsonSessionCodec struct{}
+
+func (jsonSessionCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonSessionCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type bsonSessionCodec struct{}
+
+func (bsonSessionCodec) Marshal(v interface{}) ([]byte, error) {
+	return bson.Marshal(v)
+}
+
+func (bsonSessionCodec) Unmarshal(data []byte, v interface{}) error {
+	return bson.Unmarshal(data, v)
+}
+
+type gobSessionCodec struct{}
+
+func (gobSessionCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobSessionCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// A SessionDataMigration upgrades session data written under an older schema version to
+// the current one.
+type SessionDataMigration func(ctx context.Context, fromVersion int, data map[string]interface{}) (map[string]interface{}, error)
+
+// sessionRecord is how a session is encoded by a SessionCodec.
+type sessionRecord struct {
+	Version    int                    `json:"version" bson:"version"`
+	Revision   int64                  `json:"revision" bson:"revision"`
+	Created    time.Time              `json:"created" bson:"created"`
+	LastUpdate time.Time              `json:"lastUpdate" bson:"lastUpdate"`
+	Data       map[string]interface{} `json:"data" bson:"data"`
+}
+
+// sessionCodec returns the codec that sessions of the manager, which may be nil, are
+// encoded with.
+func sessionCodec(sm *SessionManager) SessionCodec {
+	if sm == nil || sm.opts.codec == nil {
+		return BSONSessionCodec
+	}
+	return sm.opts.codec
+}
+
+// encodeSession encodes the session with the given manager's codec.
+func encodeSession(sm *SessionManager, s *Session, revision int64) ([]byte, error) {
+	return sessionCodec(sm).Marshal(&sessionRecord{
+		Version:    s.version,
+		Revision:   revision,
+		Created:    s.created,
+		LastUpdate: s.lastUpdate,
+		Data:       s.Data,
+	})
+}
+
+// decodeSession decodes a session encoded with the given manager's codec.
+func decodeSession(sm *SessionManager, data []byte) (*sessionRecord, error) {
+	var record sessionRecord
+	if err := sessionCodec(sm).Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	record.Data = sessionDataFromBSON(record.Data)
+	return &record, nil
+}
+
+// GetValue returns the value stored in the session's data under the key as a T. Values
+// that came back from a store as a different type, like a struct decoded as a map, are
+// converted with the session manager's codec. The boolean is false if there is no value.
+func GetValue[T any](s *Session, key string) (T, bool, error) {
+	var zero T
+	v, ok := s.Data[key]
+	if !ok || v == nil {
+		return zero, false, nil
+	}
+	if typed, ok := v.(T); ok {
+		return typed, true, nil
+	}
+
+	// wrap the value since not every codec can encode a bare non-document value.
+	codec := sessionCodec(s.manager)
+	encoded, err := codec.Marshal(&struct {
+		V interface{} `json:"v" bson:"v"`
+	}{v})
+	if err != nil {
+		return zero, false, err
+	}
+	var decoded struct {
+		V T `json:"v" bson:"v"`
+	}
+	if err := codec.Unmarshal(encoded, &decoded); err != nil {
+		return zero, false, err
+	}
+	return decoded.V, true, nil
+}
+
+// SetValue stores the value in the session's data under the key. It must be encodable
+// by the session manager's codec.
+func (s *Session) SetValue(key string, value interface{}) {
+	if s.Data == nil {
+		s.Data = map[string]interface{}{}
+	}
+	s.Data[key] = value
+}
+
+// sessionDataFromBSON returns the session data with the BSON documents and arrays that
+// stores and codecs decode it into, at any depth, replaced by plain maps and slices so
+// that Session.Data only ever holds Go's own types.
+func sessionDataFromBSON(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return map[string]interface{}{}
+	}
+	return sessionValueFromBSON(data).(map[string]interface{})
+}
+
+// sessionValueFromBSON converts a decoded value in place where it can.
+func sessionValueFromBSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bson.M:
+		return sessionValueFromBSON(map[string]interface{}(v))
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = sessionValueFromBSON(elem)
+		}
+		return v
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			m[elem.Key] = sessionValueFromBSON(elem.Value)
+		}
+		return m
+	case bson.A:
+		return sessionValueFromBSON([]interface{}(v))
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = sessionValueFromBSON(elem)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.viam.com/test"
+)
+
+type testSessionProfile struct {
+	Name  string
+	Age   int
+	Roles []string
+}
+
+func TestSessionCodecs(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		codec SessionCodec
+	}{
+		{"json", JSONSessionCodec},
+		{"bson", BSONSessionCodec},
+		{"gob", GobSessionCodec},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewCookieSessionStore(bytes.Repeat([]byte{1}, 32))
+			test.That(t, err, test.ShouldBeNil)
+			sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionCodec(tc.codec))
+
+			r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+			test.That(t, err, test.ShouldBeNil)
+			s, err := sm.Get(r, true)
+			test.That(t, err, test.ShouldBeNil)
+
+			profile := testSessionProfile{Name: "alice", Age: 30, Roles: []string{"admin"}}
+			if tc.codec == GobSessionCodec {
+				// gob can only decode the interface values of data into registered types.
+				s.SetValue("profile", map[string]interface{}{"name": profile.Name, "age": profile.Age, "roles": profile.Roles})
+			} else {
+				s.SetValue("profile", profile)
+			}
+			s.SetValue("count", 3)
+			s.SetValue("nested", map[string]interface{}{"list": []interface{}{map[string]interface{}{"a": "b"}}})
+
+			w := &DummyWriter{}
+			test.That(t, s.Save(context.Background(), r, w), test.ShouldBeNil)
+			cookies := (&http.Response{Header: w.Header()}).Cookies()
+			test.That(t, cookies, test.ShouldHaveLength, 1)
+
+			r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+			test.That(t, err, test.ShouldBeNil)
+			r.AddCookie(cookies[0])
+			s, err = sm.Get(r, false)
+			test.That(t, err, test.ShouldBeNil)
+
+			count, ok, err := GetValue[int](s, "count")
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, ok, test.ShouldBeTrue)
+			test.That(t, count, test.ShouldEqual, 3)
+
+			// documents and arrays come back as plain maps and slices whatever the codec.
+			nested, ok := s.Data["nested"].(map[string]interface{})
+			test.That(t, ok, test.ShouldBeTrue)
+			list, ok := nested["list"].([]interface{})
+			test.That(t, ok, test.ShouldBeTrue)
+			test.That(t, list, test.ShouldHaveLength, 1)
+			test.That(t, list[0], test.ShouldResemble, map[string]interface{}{"a": "b"})
+
+			if tc.codec == GobSessionCodec {
+				decoded, ok, err := GetValue[map[string]interface{}](s, "profile")
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, ok, test.ShouldBeTrue)
+				test.That(t, decoded["name"], test.ShouldEqual, "alice")
+			} else {
+				decoded, ok, err := GetValue[testSessionProfile](s, "profile")
+				test.That(t, err, test.ShouldBeNil)
+				test.That(t, ok, test.ShouldBeTrue)
+				test.That(t, decoded, test.ShouldResemble, profile)
+			}
+
+			_, ok, err = GetValue[string](s, "missing")
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, ok, test.ShouldBeFalse)
+		})
+	}
+}
+
+func TestSessionDataMigration(t *testing.T) {
+	store := &memorySessionStore{}
+	sm := NewSessionManager(store, golog.NewTestLogger(t))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.version, test.ShouldEqual, 0)
+	s.Data["name"] = "alice"
+	test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeNil)
+	id := s.id
+
+	getWithVersion := func(version int, migrate SessionDataMigration) (*Session, error) {
+		sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionDataVersion(version, migrate))
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: id})
+		return sm.Get(r, false)
+	}
+
+	var migratedFrom []int
+	s, err = getWithVersion(1, func(ctx context.Context, fromVersion int, data map[string]interface{}) (map[string]interface{}, error) {
+		migratedFrom = append(migratedFrom, fromVersion)
+		return map[string]interface{}{"names": []string{data["name"].(string)}}, nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, migratedFrom, test.ShouldResemble, []int{0})
+	test.That(t, s.version, test.ShouldEqual, 1)
+	test.That(t, s.Data, test.ShouldResemble, map[string]interface{}{"names": []string{"alice"}})
+	test.That(t, store.Save(context.Background(), s), test.ShouldBeNil)
+
+	// up to date sessions are not migrated again.
+	s, err = getWithVersion(1, func(ctx context.Context, fromVersion int, data map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("should not be called")
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.version, test.ShouldEqual, 1)
+
+	// sessions that fail to migrate are discarded.
+	_, err = getWithVersion(2, func(ctx context.Context, fromVersion int, data map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("cannot migrate")
+	})
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+	_, err = getWithVersion(1, nil)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+}
+
+func TestDecodeSessionBSONTypes(t *testing.T) {
+	// gob sessions encoded while data was a bson.M keep their BSON types.
+	encoded, err := GobSessionCodec.Marshal(&struct {
+		Version int
+		Data    bson.M
+	}{
+		Version: 1,
+		Data: bson.M{
+			"profile": bson.M{"roles": bson.A{"admin", bson.M{"a": "b"}}},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	sm := NewSessionManager(&memorySessionStore{}, golog.NewTestLogger(t), WithSessionCodec(GobSessionCodec))
+	record, err := decodeSession(sm, encoded)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, record.Version, test.ShouldEqual, 1)
+	test.That(t, record.Data, test.ShouldResemble, map[string]interface{}{
+		"profile": map[string]interface{}{"roles": []interface{}{"admin", map[string]interface{}{"a": "b"}}},
+	})
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// maxCookieSize is the largest cookie value browsers can be relied upon to store.
+const maxCookieSize = 4096
+
+// NewCookieSessionStore creates a new store that keeps session data in the session cookie
+// itself, authenticated and encrypted with AES-GCM, so that no server-side storage is
+// needed. Each key must be 16, 24, or 32 bytes long. The first key is used to encrypt
+// sessions and all keys are tried when decrypting them, so keys can be rotated by
+// prepending a new key and dropping an old one once sessions using it have expired.
+//
+// Since the session ID of a cookie session is its encrypted data, it changes on every
+// save and sessions cannot be revoked before their cookies expire. Data must stay small
+// enough that its encrypted form fits in a cookie.
+func NewCookieSessionStore(secretKeys ...[]byte) (Store, error) {
+	if len(secretKeys) == 0 {
+		return nil, errors.New("at least one secret key is required")
+	}
+	aeads := make([]cipher.AEAD, 0, len(secretKeys))
+	for i, key := range secretKeys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid secret key at index %d", i)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads = append(aeads, aead)
+	}
+	return &cookieSessionStore{aeads: aeads}, nil
+}
+
+type cookieSessionStore struct {
+	aeads   []cipher.AEAD
+	manager *SessionManager
+}
+
+func (css *cookieSessionStore) SetSessionManager(sm *SessionManager) {
+	css.manager = sm
+}
+
+// Delete does nothing since there is no server-side state; the manager expires the cookie.
+func (css *cookieSessionStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (css *cookieSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+
+	var plaintext []byte
+	for _, aead := range css.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrNoSession
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err = aead.Open(nil, nonce, ciphertext, nil)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		// tampered with or encrypted with a key no longer in use.
+		return nil, ErrNoSession
+	}
+
+	record, err := decodeSession(css.manager, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode session cookie: %w", err)
+	}
+
+	s := &Session{
+		store:      css,
+		manager:    css.manager,
+		isNew:      false,
+		version:    record.Version,
+		created:    record.Created,
+		lastUpdate: record.LastUpdate,
+		id:         id,
+		Data:       record.Data,
+	}
+
+	return s, nil
+}
+
+// Save encrypts the session's data and makes the result its new ID, which the session
+// then sets as its cookie.
+func (css *cookieSessionStore) Save(ctx context.Context, s *Session) error {
+	plaintext, err := encodeSession(css.manager, s, 0)
+	if err != nil {
+		return fmt.Errorf("couldn't encode session data: %w", err)
+	}
+
+	aead := css.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	id := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil))
+	if len(id) > maxCookieSize {
+		return errors.Errorf("session data too large to store in a cookie (%d > %d bytes)", len(id), maxCookieSize)
+	}
+
+	s.id = id
+	return nil
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+)
+
+func TestCookieSessionStore(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+
+	_, err := NewCookieSessionStore()
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewCookieSessionStore([]byte("short"))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "index 0")
+
+	store, err := NewCookieSessionStore(oldKey)
+	test.That(t, err, test.ShouldBeNil)
+	sm := NewSessionManager(store, golog.NewTestLogger(t))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	s.Data["user"] = "alice"
+
+	w := &DummyWriter{}
+	test.That(t, s.Save(context.Background(), r, w), test.ShouldBeNil)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	test.That(t, cookies, test.ShouldHaveLength, 1)
+	test.That(t, cookies[0].Value, test.ShouldNotContainSubstring, "alice")
+
+	getWithCookie := func(sm *SessionManager, value string) (*Session, error) {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		r.AddCookie(&http.Cookie{Name: "session-id", Value: value})
+		return sm.Get(r, false)
+	}
+
+	s2, err := getWithCookie(sm, cookies[0].Value)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.Data["user"], test.ShouldEqual, "alice")
+
+	t.Run("saving changes resets the cookie", func(t *testing.T) {
+		s2.Data["user"] = "bob"
+		w := &DummyWriter{}
+		test.That(t, s2.Save(context.Background(), r, w), test.ShouldBeNil)
+		cookies := (&http.Response{Header: w.Header()}).Cookies()
+		test.That(t, cookies, test.ShouldHaveLength, 1)
+
+		s3, err := getWithCookie(sm, cookies[0].Value)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s3.Data["user"], test.ShouldEqual, "bob")
+	})
+
+	t.Run("tampered cookies are rejected", func(t *testing.T) {
+		value := []byte(cookies[0].Value)
+		value[len(value)/2] ^= 1
+		_, err := getWithCookie(sm, string(value))
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+		_, err = getWithCookie(sm, "not base64!")
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		rotated, err := NewCookieSessionStore(newKey, oldKey)
+		test.That(t, err, test.ShouldBeNil)
+		rotatedSM := NewSessionManager(rotated, golog.NewTestLogger(t))
+
+		s, err := getWithCookie(rotatedSM, cookies[0].Value)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s.Data["user"], test.ShouldEqual, "alice")
+
+		w := &DummyWriter{}
+		test.That(t, s.Save(context.Background(), r, w), test.ShouldBeNil)
+		newCookies := (&http.Response{Header: w.Header()}).Cookies()
+		test.That(t, newCookies, test.ShouldHaveLength, 1)
+
+		// the old key can no longer read sessions encrypted with the new one.
+		_, err = getWithCookie(sm, newCookies[0].Value)
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+		retired, err := NewCookieSessionStore(newKey)
+		test.That(t, err, test.ShouldBeNil)
+		retiredSM := NewSessionManager(retired, golog.NewTestLogger(t))
+		_, err = getWithCookie(retiredSM, cookies[0].Value)
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+		s, err = getWithCookie(retiredSM, newCookies[0].Value)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s.Data["user"], test.ShouldEqual, "alice")
+	})
+
+	t.Run("oversized data", func(t *testing.T) {
+		s, err := sm.Get(r, true)
+		test.That(t, err, test.ShouldBeNil)
+		s.Data["big"] = strings.Repeat("x", maxCookieSize)
+		err = s.Save(context.Background(), r, &DummyWriter{})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "too large")
+	})
+}
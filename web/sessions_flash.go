@@ -0,0 +1,59 @@
+package web
+
+import (
+	"encoding/gob"
+	"html/template"
+	"strings"
+)
+
+// flashesSessionKey is the key of the session data flash messages are stored under.
+const flashesSessionKey = "_flashes"
+
+func init() {
+	gob.Register([]Flash{})
+}
+
+// A Flash is a one-shot message shown to the user on the next page they view, such as a
+// confirmation that a form was submitted.
+type Flash struct {
+	Category string `json:"category" bson:"category"`
+	Message  string `json:"message" bson:"message"`
+}
+
+// AddFlash queues a message under the given category (e.g. "info" or "error") to be shown
+// once. The session must be saved for the message to persist.
+func (s *Session) AddFlash(category, msg string) {
+	flashes := s.flashes()
+	s.SetValue(flashesSessionKey, append(flashes, Flash{Category: category, Message: msg}))
+}
+
+// Flashes returns the queued messages and clears them. The session must be saved for
+// the messages to stay cleared.
+func (s *Session) Flashes() []Flash {
+	flashes := s.flashes()
+	delete(s.Data, flashesSessionKey)
+	return flashes
+}
+
+func (s *Session) flashes() []Flash {
+	flashes, _, err := GetValue[[]Flash](s, flashesSessionKey)
+	if err != nil && s.manager != nil {
+		s.manager.logger.Errorw("discarding undecodable flash messages", "error", err)
+	}
+	return flashes
+}
+
+// RenderFlashes renders each message as a div with the classes "flash" and
+// "flash-<category>". It is available to templates as flashes, e.g. {{ flashes .Flashes }}.
+func RenderFlashes(flashes []Flash) template.HTML {
+	var b strings.Builder
+	for _, flash := range flashes {
+		b.WriteString(`<div class="flash flash-`)
+		b.WriteString(template.HTMLEscapeString(flash.Category))
+		b.WriteString(`">`)
+		b.WriteString(template.HTMLEscapeString(flash.Message))
+		b.WriteString("</div>\n")
+	}
+	//nolint:gosec
+	return template.HTML(b.String())
+}
@@ -0,0 +1,323 @@
+package web
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/edaniels/golog"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"go.viam.com/utils/perf/statz"
+	"go.viam.com/utils/perf/statz/units"
+)
+
+var (
+	sessionStoreOperations = statz.NewCounter2[string, string]("web/session_store_operations", statz.MetricConfig{
+		Description: "The number of session store operations.",
+		Unit:        units.Dimensionless,
+		Labels: []statz.Label{
+			{Name: "operation", Description: "The operation (get|save|delete|delete_user)."},
+			{Name: "result", Description: "The result (hit|miss|fallback|ok|error)."},
+		},
+	})
+
+	sessionStoreLatency = statz.NewDistribution1[string]("web/session_store_latency", statz.MetricConfig{
+		Description: "The latency of session store operations.",
+		Unit:        units.Milliseconds,
+		Labels: []statz.Label{
+			{Name: "operation", Description: "The operation (get|save|delete|delete_user)."},
+		},
+	}, statz.LatencyDistribution)
+)
+
+// InstrumentedSessionStoreOptions configure an instrumented store.
+type InstrumentedSessionStoreOptions struct {
+	// FallbackCacheSize is how many of the most recently used sessions are cached in memory
+	// to be served while the store fails. Defaults to 10000.
+	FallbackCacheSize int
+
+	// FallbackCacheTTL is how long after it was last read from or written to the store a
+	// cached session may be served. Defaults to 1 hour.
+	FallbackCacheTTL time.Duration
+
+	// DisableFallback disables the fallback cache.
+	DisableFallback bool
+}
+
+const (
+	defaultFallbackCacheSize = 10000
+	defaultFallbackCacheTTL  = time.Hour
+)
+
+// NewInstrumentedSessionStore wraps the store so that its operations are recorded as
+// metrics and its errors are logged. Unless disabled, sessions are also cached in memory
+// as they are read and written so that if the store becomes temporarily unavailable,
+// sessions can still be retrieved and users stay logged in. Saves still fail while the
+// store is unavailable.
+func NewInstrumentedSessionStore(store Store, logger golog.Logger, opts InstrumentedSessionStoreOptions) Store {
+	iss := &instrumentedSessionStore{store: store, logger: logger}
+	if !opts.DisableFallback {
+		if opts.FallbackCacheSize == 0 {
+			opts.FallbackCacheSize = defaultFallbackCacheSize
+		}
+		if opts.FallbackCacheTTL == 0 {
+			opts.FallbackCacheTTL = defaultFallbackCacheTTL
+		}
+		iss.fallback = newSessionCache(opts.FallbackCacheSize, opts.FallbackCacheTTL)
+	}
+	return iss
+}
+
+type instrumentedSessionStore struct {
+	store    Store
+	logger   golog.Logger
+	fallback *sessionCache
+}
+
+func (iss *instrumentedSessionStore) SetSessionManager(sm *SessionManager) {
+	iss.store.SetSessionManager(sm)
+}
+
+// record records an operation that started at the given time.
+func (iss *instrumentedSessionStore) record(operation, result string, start time.Time) {
+	sessionStoreOperations.Inc(operation, result)
+	sessionStoreLatency.Observe(float64(time.Since(start))/float64(time.Millisecond), operation)
+}
+
+func (iss *instrumentedSessionStore) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := iss.store.Delete(ctx, id)
+	// never serve a session that may have been deleted.
+	iss.fallback.delete(id)
+	if err != nil {
+		iss.record("delete", "error", start)
+		iss.logger.Errorw("failed to delete session", "error", err)
+		return err
+	}
+	iss.record("delete", "ok", start)
+	return nil
+}
+
+func (iss *instrumentedSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	start := time.Now()
+	s, err := iss.store.Get(ctx, id)
+	switch {
+	case err == nil && s != nil:
+		iss.record("get", "hit", start)
+		s.store = iss
+		iss.fallback.put(s)
+		return s, nil
+	case err == nil || errors.Is(err, ErrNoSession):
+		iss.record("get", "miss", start)
+		iss.fallback.delete(id)
+		return s, err
+	}
+
+	if cached := iss.fallback.get(id); cached != nil {
+		iss.record("get", "fallback", start)
+		iss.logger.Warnw("failed to get session; serving cached copy", "error", err)
+		return cached, nil
+	}
+	iss.record("get", "error", start)
+	iss.logger.Errorw("failed to get session", "error", err)
+	return nil, err
+}
+
+func (iss *instrumentedSessionStore) Save(ctx context.Context, s *Session) error {
+	start := time.Now()
+	if err := iss.store.Save(ctx, s); err != nil {
+		iss.record("save", "error", start)
+		iss.logger.Errorw("failed to save session", "error", err)
+		return err
+	}
+	iss.record("save", "ok", start)
+	s.store = iss
+	iss.fallback.put(s)
+	return nil
+}
+
+// deleteExpired sweeps the wrapped store, if it needs sweeping, and the fallback cache.
+func (iss *instrumentedSessionStore) deleteExpired(ctx context.Context, expired func(s *Session) bool) error {
+	iss.fallback.deleteExpired(expired)
+	if sweeper, ok := iss.store.(expiredSessionSweeper); ok {
+		return sweeper.deleteExpired(ctx, expired)
+	}
+	return nil
+}
+
+// deleteAllForUser deletes the user's sessions from the wrapped store, if it supports it,
+// and the fallback cache, so that revoked sessions are not served while the store fails.
+func (iss *instrumentedSessionStore) deleteAllForUser(ctx context.Context, field, userKey string) error {
+	deleter, ok := iss.store.(userSessionDeleter)
+	if !ok {
+		return errors.Errorf("session store %T does not support deleting sessions by user", iss.store)
+	}
+	start := time.Now()
+	err := deleter.deleteAllForUser(ctx, field, userKey)
+	iss.fallback.deleteExpired(func(s *Session) bool {
+		key, ok := s.Data[field].(string)
+		return ok && key == userKey
+	})
+	if err != nil {
+		iss.record("delete_user", "error", start)
+		iss.logger.Errorw("failed to delete sessions of user", "error", err)
+		return err
+	}
+	iss.record("delete_user", "ok", start)
+	return nil
+}
+
+// A sessionCache holds copies of a bounded number of sessions for a limited time, evicting
+// the least recently used sessions first. All methods may be called on a nil
+// *sessionCache, in which case nothing is cached.
+type sessionCache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order has the most recently used entries at the front.
+	order *list.List
+}
+
+type sessionCacheEntry struct {
+	session *Session
+	expires time.Time
+}
+
+func newSessionCache(maxEntries int, ttl time.Duration) *sessionCache {
+	return &sessionCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// get returns a copy of the session cached under the ID, if any.
+func (sc *sessionCache) get(id string) *Session {
+	if sc == nil {
+		return nil
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	elem, ok := sc.entries[id]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*sessionCacheEntry)
+	if sc.ttl > 0 && time.Now().After(entry.expires) {
+		sc.removeElement(elem)
+		return nil
+	}
+	sc.order.MoveToFront(elem)
+	return copySession(entry.session)
+}
+
+// put caches a copy of the session under its ID.
+func (sc *sessionCache) put(s *Session) {
+	if sc == nil {
+		return
+	}
+	entry := &sessionCacheEntry{session: copySession(s), expires: time.Now().Add(sc.ttl)}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if elem, ok := sc.entries[s.id]; ok {
+		elem.Value = entry
+		sc.order.MoveToFront(elem)
+		return
+	}
+	sc.entries[s.id] = sc.order.PushFront(entry)
+	for sc.maxEntries > 0 && sc.order.Len() > sc.maxEntries {
+		sc.removeElement(sc.order.Back())
+	}
+}
+
+func (sc *sessionCache) delete(id string) {
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if elem, ok := sc.entries[id]; ok {
+		sc.removeElement(elem)
+	}
+}
+
+// deleteExpired removes the sessions that have expired either by the cache's TTL or
+// according to the given function.
+func (sc *sessionCache) deleteExpired(expired func(s *Session) bool) {
+	if sc == nil {
+		return
+	}
+	now := time.Now()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, elem := range sc.entries {
+		entry := elem.Value.(*sessionCacheEntry)
+		if (sc.ttl > 0 && now.After(entry.expires)) || expired(entry.session) {
+			sc.removeElement(elem)
+		}
+	}
+}
+
+func (sc *sessionCache) removeElement(elem *list.Element) {
+	entry := sc.order.Remove(elem).(*sessionCacheEntry)
+	delete(sc.entries, entry.session.id)
+}
+
+// copySession returns a copy of the session that shares no maps or slices of its data
+// with the original, so that either can be modified without affecting the other.
+func copySession(s *Session) *Session {
+	cp := *s
+	if s.Data != nil {
+		cp.Data = copySessionValue(s.Data).(map[string]interface{})
+	}
+	return &cp
+}
+
+func copySessionValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bson.M:
+		cp := make(bson.M, len(v))
+		for key, elem := range v {
+			cp[key] = copySessionValue(elem)
+		}
+		return cp
+	case map[string]interface{}:
+		cp := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			cp[key] = copySessionValue(elem)
+		}
+		return cp
+	case bson.A:
+		cp := make(bson.A, len(v))
+		for i, elem := range v {
+			cp[i] = copySessionValue(elem)
+		}
+		return cp
+	case []interface{}:
+		cp := make([]interface{}, len(v))
+		for i, elem := range v {
+			cp[i] = copySessionValue(elem)
+		}
+		return cp
+	case bson.D:
+		cp := make(bson.D, len(v))
+		for i, elem := range v {
+			cp[i] = bson.E{Key: elem.Key, Value: copySessionValue(elem.Value)}
+		}
+		return cp
+	case []string:
+		return append([]string(nil), v...)
+	case []byte:
+		return append([]byte(nil), v...)
+	case []Flash:
+		return append([]Flash(nil), v...)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/perf/statz/statztest"
+)
+
+// flakyStore is a store that fails every operation while down.
+type flakyStore struct {
+	Store
+	down bool
+}
+
+var errStoreDown = errors.New("store is down")
+
+func (fs *flakyStore) Delete(ctx context.Context, id string) error {
+	if fs.down {
+		return errStoreDown
+	}
+	return fs.Store.Delete(ctx, id)
+}
+
+func (fs *flakyStore) Get(ctx context.Context, id string) (*Session, error) {
+	if fs.down {
+		return nil, errStoreDown
+	}
+	return fs.Store.Get(ctx, id)
+}
+
+func (fs *flakyStore) Save(ctx context.Context, s *Session) error {
+	if fs.down {
+		return errStoreDown
+	}
+	return fs.Store.Save(ctx, s)
+}
+
+func TestInstrumentedSessionStore(t *testing.T) {
+	operations := statztest.NewCounterRecorder("web/session_store_operations")
+	operationCount := func(operation, result string) int64 {
+		return operations.Value("operation", operation, "result", result)
+	}
+	initialHits := operationCount("get", "hit")
+	initialFallbacks := operationCount("get", "fallback")
+	initialSaveErrors := operationCount("save", "error")
+
+	logger := golog.NewTestLogger(t)
+	inner := &flakyStore{Store: NewMemorySessionStore()}
+	sm := NewSessionManager(NewInstrumentedSessionStore(inner, logger, InstrumentedSessionStoreOptions{}), logger)
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	s.Data["user"] = "alice"
+	test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeNil)
+
+	r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s.id})
+	s, err = sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, operationCount("get", "hit"), test.ShouldEqual, initialHits+1)
+
+	// saves go through the instrumented store.
+	inner.down = true
+	test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeError, errStoreDown)
+	test.That(t, operationCount("save", "error"), test.ShouldEqual, initialSaveErrors+1)
+
+	s2, err := sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.Data["user"], test.ShouldEqual, "alice")
+	test.That(t, operationCount("get", "fallback"), test.ShouldEqual, initialFallbacks+1)
+
+	// cached copies are independent of each other.
+	s2.Data["user"] = "mallory"
+	s3, err := sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s3.Data["user"], test.ShouldEqual, "alice")
+
+	// deleted sessions are never served from the cache.
+	test.That(t, sm.store.Delete(context.Background(), s.id), test.ShouldBeError, errStoreDown)
+	_, err = sm.Get(r, false)
+	test.That(t, errors.Is(err, errStoreDown), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, ErrStoreUnavailable), test.ShouldBeTrue)
+
+	t.Run("disabled", func(t *testing.T) {
+		inner := &flakyStore{Store: NewMemorySessionStore()}
+		store := NewInstrumentedSessionStore(inner, logger, InstrumentedSessionStoreOptions{DisableFallback: true})
+		NewSessionManager(store, logger)
+		s := &Session{id: "foo"}
+		test.That(t, store.Save(context.Background(), s), test.ShouldBeNil)
+		inner.down = true
+		_, err := store.Get(context.Background(), "foo")
+		test.That(t, err, test.ShouldBeError, errStoreDown)
+	})
+}
+
+func TestSessionCache(t *testing.T) {
+	sc := newSessionCache(2, time.Hour)
+	for _, id := range []string{"a", "b"} {
+		sc.put(&Session{id: id})
+	}
+	test.That(t, sc.get("a"), test.ShouldNotBeNil)
+	sc.put(&Session{id: "c"})
+	// b was least recently used.
+	test.That(t, sc.get("b"), test.ShouldBeNil)
+	test.That(t, sc.get("a"), test.ShouldNotBeNil)
+	test.That(t, sc.get("c"), test.ShouldNotBeNil)
+
+	sc = newSessionCache(10, time.Nanosecond)
+	sc.put(&Session{id: "a"})
+	time.Sleep(time.Millisecond)
+	test.That(t, sc.get("a"), test.ShouldBeNil)
+
+	var nilCache *sessionCache
+	nilCache.put(&Session{id: "a"})
+	test.That(t, nilCache.get("a"), test.ShouldBeNil)
+}
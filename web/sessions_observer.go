@@ -0,0 +1,76 @@
+package web
+
+import "context"
+
+// A SessionObserver is notified of the lifecycle of the sessions of a SessionManager, such
+// as to audit logins. The data passed to it is a copy of the session's data at the time
+// of the event and may be nil if it is unknown. Observers are called synchronously from
+// the request handling the session, so they should return quickly.
+type SessionObserver interface {
+	// SessionCreated is called when a new session is first saved, including when a session
+	// is moved to a new ID by Regenerate.
+	SessionCreated(ctx context.Context, id string, data map[string]interface{})
+
+	// SessionLoaded is called when an existing session is retrieved for a request.
+	SessionLoaded(ctx context.Context, id string, data map[string]interface{})
+
+	// SessionSaved is called whenever a session is saved, including when it is created.
+	SessionSaved(ctx context.Context, id string, data map[string]interface{})
+
+	// SessionDestroyed is called when a session is deleted because it was logged out of,
+	// expired, failed to migrate, or was moved to a new ID. Sessions deleted in bulk by
+	// SweepExpiredSessions and DeleteAllForUser, or expired by the store itself, are not
+	// reported.
+	SessionDestroyed(ctx context.Context, id string, data map[string]interface{})
+}
+
+// SessionObserverFuncs is a SessionObserver calling whichever of its functions are set.
+type SessionObserverFuncs struct {
+	Created   func(ctx context.Context, id string, data map[string]interface{})
+	Loaded    func(ctx context.Context, id string, data map[string]interface{})
+	Saved     func(ctx context.Context, id string, data map[string]interface{})
+	Destroyed func(ctx context.Context, id string, data map[string]interface{})
+}
+
+// SessionCreated calls Created, if set.
+func (funcs SessionObserverFuncs) SessionCreated(ctx context.Context, id string, data map[string]interface{}) {
+	if funcs.Created != nil {
+		funcs.Created(ctx, id, data)
+	}
+}
+
+// SessionLoaded calls Loaded, if set.
+func (funcs SessionObserverFuncs) SessionLoaded(ctx context.Context, id string, data map[string]interface{}) {
+	if funcs.Loaded != nil {
+		funcs.Loaded(ctx, id, data)
+	}
+}
+
+// SessionSaved calls Saved, if set.
+func (funcs SessionObserverFuncs) SessionSaved(ctx context.Context, id string, data map[string]interface{}) {
+	if funcs.Saved != nil {
+		funcs.Saved(ctx, id, data)
+	}
+}
+
+// SessionDestroyed calls Destroyed, if set.
+func (funcs SessionObserverFuncs) SessionDestroyed(ctx context.Context, id string, data map[string]interface{}) {
+	if funcs.Destroyed != nil {
+		funcs.Destroyed(ctx, id, data)
+	}
+}
+
+// notify calls the function with each of the manager's observers, passing it a snapshot
+// of the data for each so that no observer can modify the session or affect another.
+func (sm *SessionManager) notify(data map[string]interface{}, f func(o SessionObserver, data map[string]interface{})) {
+	if sm == nil {
+		return
+	}
+	for _, o := range sm.opts.observers {
+		var snapshot map[string]interface{}
+		if data != nil {
+			snapshot = copySessionValue(data).(map[string]interface{})
+		}
+		f(o, snapshot)
+	}
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookieOptions control the attributes of session cookies. Unset fields keep their
+// defaults.
+type CookieOptions struct {
+	// Name is the name of the cookie. Defaults to "session-id".
+	Name string
+
+	// Domain is the domain the cookie is sent to. Defaults to the host that set it.
+	Domain string
+
+	// Path is the path the cookie is sent for. Defaults to "/".
+	Path string
+
+	// SameSite controls whether the cookie is sent with cross-site requests. Defaults to
+	// http.SameSiteLaxMode.
+	SameSite http.SameSite
+
+	// HttpOnly is whether the cookie is hidden from scripts. Defaults to true.
+	HttpOnly *bool
+
+	// Secure is whether the cookie is only sent over HTTPS. Defaults to whether the request
+	// the cookie is set in response to was made over TLS.
+	Secure *bool
+
+	// MaxAge is how long the cookie is kept for. Defaults to 7 days. If negative, the cookie
+	// is kept until the browser is closed.
+	MaxAge time.Duration
+}
+
+// sessionManagerOptions configure a SessionManager.
+type sessionManagerOptions struct {
+	// cookie controls the attributes of session cookies.
+	cookie CookieOptions
+
+	// absoluteTimeout is how long after its creation a session expires.
+	absoluteTimeout time.Duration
+
+	// idleTimeout is how long after it was last saved a session expires.
+	idleTimeout time.Duration
+
+	// codec encodes sessions for stores that keep them as bytes.
+	codec SessionCodec
+
+	// dataVersion is the current schema version of session data and migrate upgrades data
+	// from older versions to it.
+	dataVersion int
+	migrate     SessionDataMigration
+
+	// userKeyField is the key of session data identifying the user a session belongs to.
+	userKeyField string
+
+	// observers are notified of the lifecycle of sessions.
+	observers []SessionObserver
+
+	// sweepInterval is how often expired sessions are swept from stores that need it.
+	sweepInterval time.Duration
+}
+
+// SessionManagerOption configures how a SessionManager handles sessions.
+type SessionManagerOption interface {
+	apply(*sessionManagerOptions)
+}
+
+// funcSessionManagerOption wraps a function that modifies sessionManagerOptions into an
+// implementation of the SessionManagerOption interface.
+type funcSessionManagerOption struct {
+	f func(*sessionManagerOptions)
+}
+
+func (fsmo *funcSessionManagerOption) apply(do *sessionManagerOptions) {
+	fsmo.f(do)
+}
+
+func newFuncSessionManagerOption(f func(*sessionManagerOptions)) *funcSessionManagerOption {
+	return &funcSessionManagerOption{
+		f: f,
+	}
+}
+
+// WithSessionAbsoluteTimeout returns a SessionManagerOption which expires sessions the
+// given duration after they were created, regardless of activity. Sessions do not expire
+// this way by default.
+func WithSessionAbsoluteTimeout(timeout time.Duration) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.absoluteTimeout = timeout
+	})
+}
+
+// WithSessionIdleTimeout returns a SessionManagerOption which expires sessions that have
+// not been saved for the given duration. Applications wanting sessions to stay alive while
+// in use should save them on each request. Sessions do not expire this way by default.
+func WithSessionIdleTimeout(timeout time.Duration) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.idleTimeout = timeout
+	})
+}
+
+// WithSessionCookieOptions returns a SessionManagerOption which sets the attributes of
+// session cookies.
+func WithSessionCookieOptions(opts CookieOptions) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.cookie = opts
+	})
+}
+
+// WithSessionCodec returns a SessionManagerOption which sets the codec sessions are encoded
+// with by stores that keep them as bytes. Defaults to BSONSessionCodec.
+func WithSessionCodec(codec SessionCodec) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.codec = codec
+	})
+}
+
+// WithSessionDataVersion returns a SessionManagerOption which sets the current schema
+// version of session data. Sessions saved under an older version are upgraded by the
+// given migration when they are retrieved; sessions it fails to upgrade are discarded.
+// Defaults to version 0.
+func WithSessionDataVersion(version int, migrate SessionDataMigration) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.dataVersion = version
+		o.migrate = migrate
+	})
+}
+
+// WithSessionUserKeyField returns a SessionManagerOption which sets the key of session data
+// holding the string that identifies the user a session belongs to, such as an email
+// address or user ID. It is required by SessionManager.DeleteAllForUser. With the MongoDB
+// store, the field should be indexed with EnsureMongoDBSessionUserIndex.
+func WithSessionUserKeyField(field string) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.userKeyField = field
+	})
+}
+
+// WithSessionObserver returns a SessionManagerOption which adds an observer to be notified
+// of the lifecycle of sessions. Observers are called in the order they were added.
+func WithSessionObserver(observer SessionObserver) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.observers = append(o.observers, observer)
+	})
+}
+
+// WithSessionSweepInterval returns a SessionManagerOption which sweeps expired sessions
+// from stores that cannot expire them on their own every interval, in the background,
+// until SessionManager.Close is called. See SessionManager.SweepExpiredSessions.
+func WithSessionSweepInterval(interval time.Duration) SessionManagerOption {
+	return newFuncSessionManagerOption(func(o *sessionManagerOptions) {
+		o.sweepInterval = interval
+	})
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"go.opencensus.io/trace"
+)
+
+// RedisSessionStoreOptions configure a Redis backed store.
+type RedisSessionStoreOptions struct {
+	// KeyPrefix is prepended to session IDs to form the keys sessions are stored at.
+	// Defaults to "session:".
+	KeyPrefix string
+
+	// TTL is how long a session is kept after it was last saved. Defaults to 30 days.
+	TTL time.Duration
+
+	// MaxSaveRetries is how many times a save is retried when another client modified the
+	// session's key while it was being saved. Defaults to 3.
+	MaxSaveRetries int
+}
+
+const (
+	defaultRedisSessionKeyPrefix = "session:"
+	defaultRedisSessionTTL       = 30 * 24 * time.Hour
+	defaultRedisMaxSaveRetries   = 3
+)
+
+// errSessionConflict is returned when saving a session that was modified by someone else
+// since it was loaded.
+var errSessionConflict = errors.New("session was modified concurrently")
+
+// NewRedisSessionStore new Redis backed store. Sessions expire once they have not been
+// saved for the configured TTL. Saves use optimistic locking so that a session modified
+// by another request since it was loaded is not overwritten; such saves fail instead.
+func NewRedisSessionStore(ctx context.Context, client redis.UniversalClient, opts RedisSessionStoreOptions) (Store, error) {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaultRedisSessionKeyPrefix
+	}
+	if opts.TTL == 0 {
+		opts.TTL = defaultRedisSessionTTL
+	}
+	if opts.MaxSaveRetries == 0 {
+		opts.MaxSaveRetries = defaultRedisMaxSaveRetries
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to connect to redis")
+	}
+
+	return &redisSessionStore{client: client, opts: opts}, nil
+}
+
+type redisSessionStore struct {
+	client  redis.UniversalClient
+	opts    RedisSessionStoreOptions
+	manager *SessionManager
+}
+
+func (rss *redisSessionStore) SetSessionManager(sm *SessionManager) {
+	rss.manager = sm
+}
+
+func (rss *redisSessionStore) key(id string) string {
+	return rss.opts.KeyPrefix + id
+}
+
+func (rss *redisSessionStore) Delete(ctx context.Context, id string) error {
+	ctx, span := trace.StartSpan(ctx, "RedisSessionStore::Delete")
+	defer span.End()
+
+	return rss.client.Del(ctx, rss.key(id)).Err()
+}
+
+// getRecord gets the session at the key, if any.
+func (rss *redisSessionStore) getRecord(ctx context.Context, cmd redis.Cmdable, key string) (*sessionRecord, error) {
+	raw, err := cmd.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNoSession
+		}
+		return nil, fmt.Errorf("couldn't load session from redis: %w", err)
+	}
+
+	return decodeSession(rss.manager, raw)
+}
+
+func (rss *redisSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	ctx, span := trace.StartSpan(ctx, "RedisSessionStore::Get")
+	defer span.End()
+
+	record, err := rss.getRecord(ctx, rss.client, rss.key(id))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		store:      rss,
+		manager:    rss.manager,
+		isNew:      false,
+		version:    record.Version,
+		created:    record.Created,
+		lastUpdate: record.LastUpdate,
+		revision:   record.Revision,
+		id:         id,
+		Data:       record.Data,
+	}
+
+	return s, nil
+}
+
+func (rss *redisSessionStore) Save(ctx context.Context, s *Session) error {
+	ctx, span := trace.StartSpan(ctx, "RedisSessionStore::Save")
+	defer span.End()
+
+	key := rss.key(s.id)
+	newRevision := s.revision + 1
+	raw, err := encodeSession(rss.manager, s, newRevision)
+	if err != nil {
+		return fmt.Errorf("couldn't encode session data: %w", err)
+	}
+
+	save := func(tx *redis.Tx) error {
+		current, err := rss.getRecord(ctx, tx, key)
+		switch {
+		case errors.Is(err, ErrNoSession):
+			// a session deleted or expired since it was loaded is recreated.
+		case err != nil:
+			return err
+		case current.Revision != s.revision:
+			return errSessionConflict
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, raw, rss.opts.TTL)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < rss.opts.MaxSaveRetries; i++ {
+		err = rss.client.Watch(ctx, save, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, redis.TxFailedErr) {
+			return errSessionConflict
+		}
+		return err
+	}
+
+	s.revision = newRevision
+	return nil
+}
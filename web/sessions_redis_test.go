@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.viam.com/test"
+)
+
+func TestRedisStore(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer func() {
+		test.That(t, client.Close(), test.ShouldBeNil)
+	}()
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ctx := context.Background()
+	opts := RedisSessionStoreOptions{KeyPrefix: "sessiontest1:", TTL: time.Minute}
+	store, err := NewRedisSessionStore(connectCtx, client, opts)
+	if err != nil {
+		t.Skip()
+		return
+	}
+	defer func() {
+		keys, err := client.Keys(ctx, opts.KeyPrefix+"*").Result()
+		test.That(t, err, test.ShouldBeNil)
+		if len(keys) != 0 {
+			test.That(t, client.Del(ctx, keys...).Err(), test.ShouldBeNil)
+		}
+	}()
+
+	s1 := &Session{}
+	s1.id = "foo"
+	s1.Data = map[string]interface{}{"a": 1, "b": 2}
+	test.That(t, store.Save(ctx, s1), test.ShouldBeNil)
+
+	ttl, err := client.TTL(ctx, "sessiontest1:foo").Result()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ttl, test.ShouldBeGreaterThan, 0)
+	test.That(t, ttl, test.ShouldBeLessThanOrEqualTo, time.Minute)
+
+	s2, err := store.Get(ctx, s1.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.Data["a"], test.ShouldEqual, int32(1))
+	test.That(t, s2.Data["b"], test.ShouldEqual, int32(2))
+
+	_, err = store.Get(ctx, "something")
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+	t.Run("concurrent modification", func(t *testing.T) {
+		s3, err := store.Get(ctx, s1.id)
+		test.That(t, err, test.ShouldBeNil)
+
+		s2.Data["a"] = 3
+		test.That(t, store.Save(ctx, s2), test.ShouldBeNil)
+
+		// s3 was loaded before s2 was saved so saving it would lose s2's changes.
+		s3.Data["b"] = 4
+		test.That(t, store.Save(ctx, s3), test.ShouldBeError, errSessionConflict)
+
+		s3, err = store.Get(ctx, s1.id)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s3.Data["a"], test.ShouldEqual, int32(3))
+		s3.Data["b"] = 4
+		test.That(t, store.Save(ctx, s3), test.ShouldBeNil)
+	})
+
+	test.That(t, store.Delete(ctx, s1.id), test.ShouldBeNil)
+	_, err = store.Get(ctx, s1.id)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+}
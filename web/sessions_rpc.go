@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/utils/rpc"
+)
+
+// An RPCAccessTokenAuthority signs and verifies the access tokens of an RPC server, such as
+// an rpc.Server.
+type RPCAccessTokenAuthority interface {
+	SignAccessToken(forType rpc.CredentialsType, entity string, lifetime time.Duration, authMD map[string]string) (string, error)
+	VerifyAccessToken(ctx context.Context, token string) (rpc.JWTClaims, error)
+}
+
+// DefaultRPCAccessTokenLifetime is how long access tokens minted for sessions are valid for
+// by default. They are meant to be short-lived and minted again as needed so that they
+// do not outlive the session by much.
+const DefaultRPCAccessTokenLifetime = 5 * time.Minute
+
+// MintRPCAccessToken returns an access token for the RPC server of the authority on behalf
+// of the user logged in to the session, so that browser UIs can call the server as that
+// user. The user is identified by the session data field set with WithSessionUserKeyField,
+// which becomes the token's entity, and the session must have a user. The token is valid
+// for the given lifetime, or DefaultRPCAccessTokenLifetime if zero.
+func MintRPCAccessToken(s *Session, authority RPCAccessTokenAuthority, lifetime time.Duration) (string, error) {
+	field, err := sessionUserKeyField(s.manager)
+	if err != nil {
+		return "", err
+	}
+	userKey, _, err := GetValue[string](s, field)
+	if err != nil {
+		return "", err
+	}
+	if userKey == "" {
+		return "", errors.Wrap(ErrNoSession, "session has no user")
+	}
+	if lifetime == 0 {
+		lifetime = DefaultRPCAccessTokenLifetime
+	}
+	return authority.SignAccessToken(rpc.CredentialsTypeWebSession, userKey, lifetime, nil)
+}
+
+// SessionFromRPCAccessToken logs the entity of a verified access token of the RPC server of
+// the authority in to the request's session, creating the session if needed, so that a
+// client holding a token can continue in a browser as the same user. The entity is
+// stored under the session data field set with WithSessionUserKeyField. If the session
+// belongs to a different user, it is started over with no data so that nothing of theirs
+// carries over. Since the session's privileges change, it is regenerated and its new
+// cookie is set.
+func SessionFromRPCAccessToken(
+	ctx context.Context,
+	sessions *SessionManager,
+	authority RPCAccessTokenAuthority,
+	token string,
+	r *http.Request,
+	w http.ResponseWriter,
+) (*Session, error) {
+	field, err := sessionUserKeyField(sessions)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := authority.VerifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	entity := claims.Entity()
+	if entity == "" {
+		return nil, errors.New("access token has no entity")
+	}
+
+	s, err := sessions.Get(r, true)
+	if err != nil {
+		return nil, err
+	}
+	s.secure = r.TLS != nil
+	userKey, _, err := GetValue[string](s, field)
+	if err != nil || (userKey != "" && userKey != entity) {
+		s.Data = map[string]interface{}{}
+		s.created = time.Now()
+	}
+	s.SetValue(field, entity)
+	if err := s.Regenerate(ctx, w); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sessionUserKeyField returns the session data field identifying users of the manager.
+func sessionUserKeyField(sm *SessionManager) (string, error) {
+	if sm == nil || sm.opts.userKeyField == "" {
+		return "", errors.New("no session user key field set")
+	}
+	return sm.opts.userKeyField, nil
+}
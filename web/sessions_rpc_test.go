@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/rpc"
+)
+
+func TestSessionRPCBridge(t *testing.T) {
+	ctx := context.Background()
+	logger := golog.NewTestLogger(t)
+	rpcServer, err := rpc.NewServer(logger, rpc.WithAuthAudience("test"))
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, rpcServer.Stop(), test.ShouldBeNil)
+	}()
+
+	newRequest := func() *http.Request {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		return r
+	}
+
+	sm := NewSessionManager(NewMemorySessionStore(), logger)
+	s, err := sm.Get(newRequest(), true)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = MintRPCAccessToken(s, rpcServer, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	sm = NewSessionManager(NewMemorySessionStore(), logger, WithSessionUserKeyField("email"))
+	s, err = sm.Get(newRequest(), true)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = MintRPCAccessToken(s, rpcServer, 0)
+	test.That(t, errors.Is(err, ErrNoSession), test.ShouldBeTrue)
+
+	s.SetValue("email", "alice@example.com")
+	token, err := MintRPCAccessToken(s, rpcServer, time.Minute)
+	test.That(t, err, test.ShouldBeNil)
+	claims, err := rpcServer.VerifyAccessToken(ctx, token)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, claims.Entity(), test.ShouldEqual, "alice@example.com")
+	test.That(t, claims.CredentialsType(), test.ShouldEqual, rpc.CredentialsTypeWebSession)
+	test.That(t, claims.ExpiresAt, test.ShouldNotBeNil)
+
+	// the token logs its user in to a new browser session.
+	w := &DummyWriter{}
+	s2, err := SessionFromRPCAccessToken(ctx, sm, rpcServer, token, newRequest(), w)
+	test.That(t, err, test.ShouldBeNil)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	test.That(t, cookies, test.ShouldHaveLength, 1)
+	test.That(t, cookies[0].Value, test.ShouldEqual, s2.id)
+
+	r := newRequest()
+	r.AddCookie(cookies[0])
+	s3, err := sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	email, _, err := GetValue[string](s3, "email")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, email, test.ShouldEqual, "alice@example.com")
+
+	// a session belonging to another user is started over rather than taken over.
+	bobs, err := sm.Get(newRequest(), true)
+	test.That(t, err, test.ShouldBeNil)
+	bobs.SetValue("email", "bob@example.com")
+	bobs.SetValue("cart", "bob's cart")
+	test.That(t, bobs.Save(ctx, newRequest(), &DummyWriter{}), test.ShouldBeNil)
+	bobsID := bobs.id
+
+	r = newRequest()
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: bobsID})
+	s4, err := SessionFromRPCAccessToken(ctx, sm, rpcServer, token, r, &DummyWriter{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s4.id, test.ShouldNotEqual, bobsID)
+	test.That(t, s4.Data, test.ShouldResemble, map[string]interface{}{"email": "alice@example.com"})
+	_, err = sm.store.Get(ctx, bobsID)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+	// the user's own session keeps its data.
+	s3.SetValue("cart", "alice's cart")
+	test.That(t, s3.Save(ctx, newRequest(), &DummyWriter{}), test.ShouldBeNil)
+	r = newRequest()
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s3.id})
+	s5, err := SessionFromRPCAccessToken(ctx, sm, rpcServer, token, r, &DummyWriter{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s5.Data["cart"], test.ShouldEqual, "alice's cart")
+
+	_, err = SessionFromRPCAccessToken(ctx, sm, rpcServer, token+"x", newRequest(), &DummyWriter{})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,360 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"go.uber.org/multierr"
+
+	"go.viam.com/utils"
+)
+
+// A SQLDialect is a flavor of SQL a SQL backed store can speak.
+type SQLDialect int
+
+// The supported SQLDialects.
+const (
+	SQLDialectPostgres SQLDialect = iota + 1
+	SQLDialectSQLite
+)
+
+// placeholder returns the placeholder of the nth (starting at 1) parameter of a statement.
+func (d SQLDialect) placeholder(n int) string {
+	if d == SQLDialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// blobType returns the column type of binary data.
+func (d SQLDialect) blobType() string {
+	if d == SQLDialectPostgres {
+		return "BYTEA"
+	}
+	return "BLOB"
+}
+
+// SQLSessionStoreOptions configure a SQL backed store.
+type SQLSessionStoreOptions struct {
+	// Dialect is the flavor of SQL the database speaks. Required.
+	Dialect SQLDialect
+
+	// TableName is the table sessions are stored in. Defaults to "web_sessions".
+	TableName string
+
+	// TTL is how long a session is kept after it was last saved. Defaults to 30 days.
+	TTL time.Duration
+}
+
+const (
+	defaultSQLSessionTableName = "web_sessions"
+	defaultSQLSessionTTL       = 30 * 24 * time.Hour
+)
+
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (opts *SQLSessionStoreOptions) setDefaults() error {
+	if opts.Dialect != SQLDialectPostgres && opts.Dialect != SQLDialectSQLite {
+		return errors.Errorf("unsupported SQL dialect %d", opts.Dialect)
+	}
+	if opts.TableName == "" {
+		opts.TableName = defaultSQLSessionTableName
+	}
+	if !sqlIdentifierPattern.MatchString(opts.TableName) {
+		return errors.Errorf("invalid session table name %q", opts.TableName)
+	}
+	if opts.TTL == 0 {
+		opts.TTL = defaultSQLSessionTTL
+	}
+	return nil
+}
+
+// sqlSessionSchemaMigrations are the statements bringing the session table from each
+// schema version to the next, given the options of the store. New versions must only be
+// appended.
+var sqlSessionSchemaMigrations = []func(opts SQLSessionStoreOptions) []string{
+	// version 1 creates the table. Timestamps are Unix nanoseconds, or 0 if unknown, and
+	// data is the session encoded with the session manager's codec.
+	func(opts SQLSessionStoreOptions) []string {
+		table := opts.TableName
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id TEXT PRIMARY KEY,
+	revision BIGINT NOT NULL,
+	created BIGINT NOT NULL,
+	last_update BIGINT NOT NULL,
+	user_key TEXT,
+	data %s NOT NULL
+)`, table, opts.Dialect.blobType()),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_created ON %[1]s (created)", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_last_update ON %[1]s (last_update)", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_user_key ON %[1]s (user_key)", table),
+		}
+	},
+}
+
+// SQLSessionSchemaVersion is the version of the session table's schema that
+// MigrateSQLSessionSchema brings it to.
+var SQLSessionSchemaVersion = len(sqlSessionSchemaMigrations)
+
+// MigrateSQLSessionSchema creates the session table described by the options, or upgrades
+// it to the current schema version. The version of the table is kept in a table of the
+// same name suffixed with "_schema". It is called by NewSQLSessionStore, but may also be
+// run ahead of deploying a new version of an application. It returns the version the
+// table was at.
+func MigrateSQLSessionSchema(ctx context.Context, db *sql.DB, opts SQLSessionStoreOptions) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "MigrateSQLSessionSchema")
+	defer span.End()
+
+	if err := opts.setDefaults(); err != nil {
+		return 0, err
+	}
+	schemaTable := opts.TableName + "_schema"
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// rolling back after committing is a no-op.
+		if err := tx.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+			utils.UncheckedError(err)
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)", schemaTable,
+	)); err != nil {
+		return 0, errors.Wrap(err, "failed to create session schema table")
+	}
+	var version int
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT version FROM %s", schemaTable)).Scan(&version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.Wrap(err, "failed to get session schema version")
+	}
+	if version > len(sqlSessionSchemaMigrations) {
+		return version, errors.Errorf(
+			"session schema version %d is newer than the supported version %d", version, len(sqlSessionSchemaMigrations))
+	}
+	if version == len(sqlSessionSchemaMigrations) {
+		return version, nil
+	}
+
+	for i := version; i < len(sqlSessionSchemaMigrations); i++ {
+		for _, stmt := range sqlSessionSchemaMigrations[i](opts) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return version, errors.Wrapf(err, "failed to migrate session schema to version %d", i+1)
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", schemaTable)); err != nil {
+		return version, err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (version) VALUES (%s)", schemaTable, opts.Dialect.placeholder(1),
+	), len(sqlSessionSchemaMigrations)); err != nil {
+		return version, err
+	}
+	return version, tx.Commit()
+}
+
+// SQLSessionStore is a store backed by a SQL database, such as Postgres or SQLite, through
+// database/sql. The database's driver must be imported by the application.
+//
+// Saves use optimistic locking so that a session modified by another request since it was
+// loaded is not overwritten; such saves fail instead. Expired sessions are deleted by
+// SessionManager.SweepExpiredSessions, which must be run, such as with
+// WithSessionSweepInterval, for the TTL to be enforced.
+type SQLSessionStore struct {
+	db      *sql.DB
+	opts    SQLSessionStoreOptions
+	manager *SessionManager
+
+	getStmt           *sql.Stmt
+	saveStmt          *sql.Stmt
+	deleteStmt        *sql.Stmt
+	deleteUserStmt    *sql.Stmt
+	deleteExpiredStmt *sql.Stmt
+}
+
+// NewSQLSessionStore new SQL backed store. The session table is created or upgraded to the
+// current schema version as needed. The store must be closed to release its prepared
+// statements.
+func NewSQLSessionStore(ctx context.Context, db *sql.DB, opts SQLSessionStoreOptions) (*SQLSessionStore, error) {
+	if err := opts.setDefaults(); err != nil {
+		return nil, err
+	}
+	if _, err := MigrateSQLSessionSchema(ctx, db, opts); err != nil {
+		return nil, err
+	}
+
+	ss := &SQLSessionStore{db: db, opts: opts}
+	table, p := opts.TableName, opts.Dialect.placeholder
+	for _, stmt := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&ss.getStmt, fmt.Sprintf("SELECT data FROM %s WHERE id = %s", table, p(1))},
+		// the update only happens if the session was not modified since it was loaded.
+		{&ss.saveStmt, fmt.Sprintf(`INSERT INTO %[1]s (id, revision, created, last_update, user_key, data)
+VALUES (%[2]s, %[3]s, %[4]s, %[5]s, %[6]s, %[7]s)
+ON CONFLICT (id) DO UPDATE SET
+	revision = excluded.revision,
+	created = excluded.created,
+	last_update = excluded.last_update,
+	user_key = excluded.user_key,
+	data = excluded.data
+WHERE %[1]s.revision = %[8]s`, table, p(1), p(2), p(3), p(4), p(5), p(6), p(7))},
+		{&ss.deleteStmt, fmt.Sprintf("DELETE FROM %s WHERE id = %s", table, p(1))},
+		{&ss.deleteUserStmt, fmt.Sprintf("DELETE FROM %s WHERE user_key = %s", table, p(1))},
+		{&ss.deleteExpiredStmt, fmt.Sprintf(
+			"DELETE FROM %s WHERE (last_update > 0 AND last_update < %s) OR (created > 0 AND created < %s)",
+			table, p(1), p(2),
+		)},
+	} {
+		prepared, err := db.PrepareContext(ctx, stmt.query)
+		if err != nil {
+			return nil, multierr.Combine(errors.Wrap(err, "failed to prepare session statement"), ss.Close())
+		}
+		*stmt.stmt = prepared
+	}
+	return ss, nil
+}
+
+// Close releases the store's prepared statements. The database is left open.
+func (ss *SQLSessionStore) Close() error {
+	var err error
+	for _, stmt := range []*sql.Stmt{ss.getStmt, ss.saveStmt, ss.deleteStmt, ss.deleteUserStmt, ss.deleteExpiredStmt} {
+		if stmt != nil {
+			err = multierr.Combine(err, stmt.Close())
+		}
+	}
+	return err
+}
+
+// SetSessionManager sets the manager whose codec sessions are encoded with.
+func (ss *SQLSessionStore) SetSessionManager(sm *SessionManager) {
+	ss.manager = sm
+}
+
+// Delete deletes the session with the ID, if any.
+func (ss *SQLSessionStore) Delete(ctx context.Context, id string) error {
+	ctx, span := trace.StartSpan(ctx, "SQLSessionStore::Delete")
+	defer span.End()
+
+	_, err := ss.deleteStmt.ExecContext(ctx, id)
+	return err
+}
+
+// Get gets the session with the ID.
+func (ss *SQLSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	ctx, span := trace.StartSpan(ctx, "SQLSessionStore::Get")
+	defer span.End()
+
+	var raw []byte
+	if err := ss.getStmt.QueryRowContext(ctx, id).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoSession
+		}
+		return nil, fmt.Errorf("couldn't load session from db: %w", err)
+	}
+	record, err := decodeSession(ss.manager, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		store:      ss,
+		manager:    ss.manager,
+		isNew:      false,
+		version:    record.Version,
+		created:    record.Created,
+		lastUpdate: record.LastUpdate,
+		revision:   record.Revision,
+		id:         id,
+		Data:       record.Data,
+	}
+
+	return s, nil
+}
+
+// Save saves the session, failing if it was modified since it was loaded.
+func (ss *SQLSessionStore) Save(ctx context.Context, s *Session) error {
+	ctx, span := trace.StartSpan(ctx, "SQLSessionStore::Save")
+	defer span.End()
+
+	newRevision := s.revision + 1
+	raw, err := encodeSession(ss.manager, s, newRevision)
+	if err != nil {
+		return fmt.Errorf("couldn't encode session data: %w", err)
+	}
+	var userKey sql.NullString
+	if ss.manager != nil && ss.manager.opts.userKeyField != "" {
+		userKey.String, userKey.Valid = s.Data[ss.manager.opts.userKeyField].(string)
+	}
+
+	// a session deleted or expired since it was loaded is recreated.
+	res, err := ss.saveStmt.ExecContext(ctx,
+		s.id, newRevision, toSQLTime(s.created), toSQLTime(s.lastUpdate), userKey, raw, s.revision)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errSessionConflict
+	}
+
+	s.revision = newRevision
+	return nil
+}
+
+// deleteAllForUser deletes the sessions whose user key, as taken from the field of their
+// data when they were saved, matches.
+func (ss *SQLSessionStore) deleteAllForUser(ctx context.Context, field, userKey string) error {
+	ctx, span := trace.StartSpan(ctx, "SQLSessionStore::DeleteAllForUser")
+	defer span.End()
+
+	_, err := ss.deleteUserStmt.ExecContext(ctx, userKey)
+	return err
+}
+
+// deleteExpired deletes the sessions past the store's TTL or either of the manager's
+// timeouts, leaving those whose timestamps are unknown. The timeouts are evaluated by the database instead of calling the given
+// function so that sessions need not be loaded.
+func (ss *SQLSessionStore) deleteExpired(ctx context.Context, expired func(s *Session) bool) error {
+	ctx, span := trace.StartSpan(ctx, "SQLSessionStore::DeleteExpired")
+	defer span.End()
+
+	now := time.Now()
+	cutoff := func(timeout time.Duration) int64 {
+		if timeout <= 0 {
+			return 0
+		}
+		return now.Add(-timeout).UnixNano()
+	}
+	lastUpdateCutoff, createdCutoff := cutoff(ss.opts.TTL), int64(0)
+	if ss.manager != nil {
+		if idleCutoff := cutoff(ss.manager.opts.idleTimeout); idleCutoff > lastUpdateCutoff {
+			lastUpdateCutoff = idleCutoff
+		}
+		createdCutoff = cutoff(ss.manager.opts.absoluteTimeout)
+	}
+	_, err := ss.deleteExpiredStmt.ExecContext(ctx, lastUpdateCutoff, createdCutoff)
+	return err
+}
+
+// toSQLTime returns the time as stored in the session table.
+func toSQLTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
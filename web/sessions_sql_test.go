@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edaniels/golog"
+	// registers the sqlite3 driver.
+	_ "github.com/mattn/go-sqlite3"
+	"go.viam.com/test"
+)
+
+func newTestSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, db.Close(), test.ShouldBeNil)
+	})
+	return db
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteDB(t)
+
+	_, err := NewSQLSessionStore(ctx, db, SQLSessionStoreOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewSQLSessionStore(ctx, db, SQLSessionStoreOptions{Dialect: SQLDialectSQLite, TableName: "x; DROP TABLE y"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	opts := SQLSessionStoreOptions{Dialect: SQLDialectSQLite, TTL: time.Hour}
+	store, err := NewSQLSessionStore(ctx, db, opts)
+	test.That(t, err, test.ShouldBeNil)
+	defer func() {
+		test.That(t, store.Close(), test.ShouldBeNil)
+	}()
+
+	s1 := &Session{}
+	s1.id = "foo"
+	s1.Data = map[string]interface{}{"a": 1, "b": 2}
+	test.That(t, store.Save(ctx, s1), test.ShouldBeNil)
+
+	s2, err := store.Get(ctx, s1.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.Data["a"], test.ShouldEqual, int32(1))
+	test.That(t, s2.Data["b"], test.ShouldEqual, int32(2))
+
+	_, err = store.Get(ctx, "something")
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+	t.Run("concurrent modification", func(t *testing.T) {
+		s3, err := store.Get(ctx, s1.id)
+		test.That(t, err, test.ShouldBeNil)
+
+		s2.Data["a"] = 3
+		test.That(t, store.Save(ctx, s2), test.ShouldBeNil)
+
+		// s3 was loaded before s2 was saved so saving it would lose s2's changes.
+		s3.Data["b"] = 4
+		test.That(t, store.Save(ctx, s3), test.ShouldBeError, errSessionConflict)
+
+		s3, err = store.Get(ctx, s1.id)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s3.Data["a"], test.ShouldEqual, int32(3))
+		s3.Data["b"] = 4
+		test.That(t, store.Save(ctx, s3), test.ShouldBeNil)
+	})
+
+	test.That(t, store.Delete(ctx, s1.id), test.ShouldBeNil)
+	_, err = store.Get(ctx, s1.id)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+	t.Run("compatibility", func(t *testing.T) {
+		testSessionStoreCompatibility(t, store)
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionAbsoluteTimeout(time.Minute))
+		now := time.Now()
+		for _, s := range []*Session{
+			{id: "live", created: now, lastUpdate: now},
+			{id: "unknown"},
+			{id: "idle", created: now, lastUpdate: now.Add(-2 * time.Hour)},
+			{id: "old", created: now.Add(-2 * time.Minute), lastUpdate: now},
+		} {
+			s.manager = sm
+			test.That(t, store.Save(ctx, s), test.ShouldBeNil)
+		}
+
+		sweepCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		sm.SweepExpiredSessions(sweepCtx, time.Millisecond)
+
+		for id, exists := range map[string]bool{"live": true, "unknown": true, "idle": false, "old": false} {
+			_, err := store.Get(ctx, id)
+			if exists {
+				test.That(t, err, test.ShouldBeNil)
+			} else {
+				test.That(t, err, test.ShouldBeError, ErrNoSession)
+			}
+		}
+	})
+}
+
+func TestMigrateSQLSessionSchema(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteDB(t)
+	opts := SQLSessionStoreOptions{Dialect: SQLDialectSQLite, TableName: "my_sessions"}
+
+	version, err := MigrateSQLSessionSchema(ctx, db, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, version, test.ShouldEqual, 0)
+
+	version, err = MigrateSQLSessionSchema(ctx, db, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, version, test.ShouldEqual, SQLSessionSchemaVersion)
+
+	_, err = db.ExecContext(ctx, "UPDATE my_sessions_schema SET version = ?", SQLSessionSchemaVersion+1)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = MigrateSQLSessionSchema(ctx, db, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = NewSQLSessionStore(ctx, db, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+}
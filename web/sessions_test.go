@@ -1,15 +1,20 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/edaniels/golog"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.viam.com/test"
+
+	"go.viam.com/utils/testutils"
 )
 
 func TestSession1(t *testing.T) {
@@ -20,7 +25,7 @@ func TestSession1(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if _, err := sm.Get(r, false); !errors.Is(err, errNoSession) {
+	if _, err := sm.Get(r, false); !errors.Is(err, ErrNoSession) {
 		t.Fatal(err)
 	}
 
@@ -42,30 +47,14 @@ func TestSession1(t *testing.T) {
 // ----
 
 func TestMongoStore(t *testing.T) {
-	connectCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
-	defer cancel()
-
-	client, err := mongo.Connect(connectCtx)
-	if err != nil {
-		t.Skip()
-		return
-	}
-
-	err = client.Ping(connectCtx, nil)
-	if err != nil {
-		t.Skip()
-		return
-	}
-
 	ctx := context.Background()
-	coll := client.Database("test").Collection("sessiontest1")
+	coll := testutils.BackingMongoDBDatabase(t).Collection("sessiontest1")
 	store := &mongoDBSessionStore{coll, nil}
-	defer coll.Drop(ctx)
 
 	s1 := &Session{}
 	s1.id = "foo"
-	s1.Data = bson.M{"a": 1, "b": 2}
-	err = store.Save(ctx, s1)
+	s1.Data = map[string]interface{}{"a": 1, "b": 2}
+	err := store.Save(ctx, s1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,9 +70,77 @@ func TestMongoStore(t *testing.T) {
 		t.Fatal("b wrong")
 	}
 
-	if _, err := store.Get(ctx, "something"); !errors.Is(err, errNoSession) {
+	if _, err := store.Get(ctx, "something"); !errors.Is(err, ErrNoSession) {
 		t.Fatal(err)
 	}
+
+	test.That(t, EnsureMongoDBSessionUserIndex(ctx, coll, "email"), test.ShouldBeNil)
+	testSessionStoreCompatibility(t, store)
+}
+
+// testSessionStoreCompatibility checks the behavior all server side stores must share
+// through a SessionManager. The store must not already hold sessions of the tested users.
+func testSessionStoreCompatibility(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionUserKeyField("email"))
+
+	save := func(user string) *Session {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		s, err := sm.Get(r, true)
+		test.That(t, err, test.ShouldBeNil)
+		s.SetValue("email", user)
+		s.SetValue("count", 1)
+		s.SetValue("tags", []string{"a", "b"})
+		test.That(t, s.Save(ctx, r, &DummyWriter{}), test.ShouldBeNil)
+		return s
+	}
+	get := func(id string) (*Session, error) {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: id})
+		return sm.Get(r, false)
+	}
+
+	s1 := save("compat-alice@example.com")
+	s2, err := get(s1.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.isNew, test.ShouldBeFalse)
+	test.That(t, s2.created.Unix(), test.ShouldEqual, s1.created.Unix())
+	test.That(t, s2.lastUpdate.Unix(), test.ShouldEqual, s1.lastUpdate.Unix())
+	email, _, err := GetValue[string](s2, "email")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, email, test.ShouldEqual, "compat-alice@example.com")
+	count, _, err := GetValue[int](s2, "count")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 1)
+	tags, _, err := GetValue[[]string](s2, "tags")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, tags, test.ShouldResemble, []string{"a", "b"})
+
+	s2.SetValue("count", 2)
+	test.That(t, s2.Save(ctx, &http.Request{}, &DummyWriter{}), test.ShouldBeNil)
+	s2, err = get(s1.id)
+	test.That(t, err, test.ShouldBeNil)
+	count, _, err = GetValue[int](s2, "count")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 2)
+
+	test.That(t, store.Delete(ctx, s1.id), test.ShouldBeNil)
+	_, err = get(s1.id)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+	test.That(t, store.Delete(ctx, s1.id), test.ShouldBeNil)
+
+	alice1, alice2, bob := save("compat-alice@example.com"), save("compat-alice@example.com"), save("compat-bob@example.com")
+	test.That(t, sm.DeleteAllForUser(ctx, "compat-alice@example.com"), test.ShouldBeNil)
+	for _, s := range []*Session{alice1, alice2} {
+		_, err = get(s.id)
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+	}
+	_, err = get(bob.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, store.Delete(ctx, bob.id), test.ShouldBeNil)
 }
 
 // ----
@@ -105,3 +162,457 @@ func (dw *DummyWriter) Write(b []byte) (int, error) {
 
 func (dw *DummyWriter) WriteHeader(code int) {
 }
+
+func TestSessionExpiration(t *testing.T) {
+	store := &memorySessionStore{}
+	sm := NewSessionManager(
+		store,
+		golog.NewTestLogger(t),
+		WithSessionAbsoluteTimeout(time.Hour),
+		WithSessionIdleTimeout(time.Minute),
+	)
+
+	newSession := func() (*Session, *http.Request) {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		s, err := sm.Get(r, true)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeNil)
+
+		r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s.id})
+		return s, r
+	}
+
+	s, r := newSession()
+	s2, err := sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.id, test.ShouldEqual, s.id)
+
+	t.Run("idle", func(t *testing.T) {
+		s, r := newSession()
+		s.lastUpdate = time.Now().Add(-2 * time.Minute)
+		test.That(t, store.Save(context.Background(), s), test.ShouldBeNil)
+		_, err := sm.Get(r, false)
+		test.That(t, err, test.ShouldBeError, ErrExpired)
+		_, ok := store.entries[s.id]
+		test.That(t, ok, test.ShouldBeFalse)
+	})
+
+	t.Run("absolute", func(t *testing.T) {
+		s, r := newSession()
+		s.created = time.Now().Add(-2 * time.Hour)
+		test.That(t, store.Save(context.Background(), s), test.ShouldBeNil)
+		_, err := sm.Get(r, false)
+		test.That(t, err, test.ShouldBeError, ErrExpired)
+
+		// saving a session again does not extend its absolute lifetime.
+		s, r = newSession()
+		s.created = time.Now().Add(-2 * time.Hour)
+		test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeNil)
+		_, err = sm.Get(r, false)
+		test.That(t, err, test.ShouldBeError, ErrExpired)
+	})
+
+	t.Run("sweeper", func(t *testing.T) {
+		expiredSession, _ := newSession()
+		expiredSession.lastUpdate = time.Now().Add(-2 * time.Minute)
+		test.That(t, store.Save(context.Background(), expiredSession), test.ShouldBeNil)
+		liveSession, _ := newSession()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sm.SweepExpiredSessions(ctx, time.Millisecond)
+		}()
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			store.mu.RLock()
+			defer store.mu.RUnlock()
+			_, ok := store.entries[expiredSession.id]
+			test.That(tb, ok, test.ShouldBeFalse)
+		})
+		cancel()
+		<-done
+
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		_, ok := store.entries[liveSession.id]
+		test.That(t, ok, test.ShouldBeTrue)
+	})
+
+	t.Run("sweep interval", func(t *testing.T) {
+		sweepingSM := NewSessionManager(
+			store,
+			golog.NewTestLogger(t),
+			WithSessionIdleTimeout(time.Minute),
+			WithSessionSweepInterval(time.Millisecond),
+		)
+		defer store.SetSessionManager(sm)
+		defer sweepingSM.Close()
+
+		expiredSession, _ := newSession()
+		expiredSession.lastUpdate = time.Now().Add(-2 * time.Minute)
+		test.That(t, store.Save(context.Background(), expiredSession), test.ShouldBeNil)
+		testutils.WaitForAssertion(t, func(tb testing.TB) {
+			tb.Helper()
+			store.mu.RLock()
+			defer store.mu.RUnlock()
+			_, ok := store.entries[expiredSession.id]
+			test.That(tb, ok, test.ShouldBeFalse)
+		})
+	})
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	store := &memorySessionStore{}
+	sm := NewSessionManager(store, golog.NewTestLogger(t))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: "planted"})
+
+	// an unknown ID is never reused.
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.id, test.ShouldNotEqual, "planted")
+	s.Data["a"] = 1
+	test.That(t, s.Save(context.Background(), r, &DummyWriter{}), test.ShouldBeNil)
+
+	r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s.id})
+	s, err = sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	oldID := s.id
+
+	w := &DummyWriter{}
+	test.That(t, s.Regenerate(context.Background(), w), test.ShouldBeNil)
+	test.That(t, s.id, test.ShouldNotEqual, oldID)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	test.That(t, cookies, test.ShouldHaveLength, 1)
+	test.That(t, cookies[0].Value, test.ShouldEqual, s.id)
+
+	_, err = sm.Get(r, false)
+	test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+	r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.AddCookie(cookies[0])
+	s2, err := sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s2.Data["a"], test.ShouldEqual, 1)
+}
+
+func TestSessionCookieOptions(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	saveAndDelete := func(sm *SessionManager) (*http.Cookie, *http.Cookie) {
+		s, err := sm.Get(r, true)
+		test.That(t, err, test.ShouldBeNil)
+		w := &DummyWriter{}
+		test.That(t, s.Save(context.Background(), r, w), test.ShouldBeNil)
+		sm.DeleteSession(context.Background(), r, w)
+		cookies := (&http.Response{Header: w.Header()}).Cookies()
+		test.That(t, cookies, test.ShouldHaveLength, 2)
+		return cookies[0], cookies[1]
+	}
+
+	saved, deleted := saveAndDelete(NewSessionManager(&memorySessionStore{}, golog.NewTestLogger(t)))
+	test.That(t, saved.Name, test.ShouldEqual, "session-id")
+	test.That(t, saved.Path, test.ShouldEqual, "/")
+	test.That(t, saved.MaxAge, test.ShouldEqual, 7*24*3600)
+	test.That(t, saved.HttpOnly, test.ShouldBeTrue)
+	test.That(t, saved.Secure, test.ShouldBeFalse)
+	test.That(t, saved.SameSite, test.ShouldEqual, http.SameSiteLaxMode)
+	test.That(t, deleted.Name, test.ShouldEqual, "session-id")
+	test.That(t, deleted.MaxAge, test.ShouldEqual, -1)
+
+	httpOnly, secure := false, true
+	saved, deleted = saveAndDelete(NewSessionManager(
+		&memorySessionStore{},
+		golog.NewTestLogger(t),
+		WithSessionCookieOptions(CookieOptions{
+			Name:     "sid",
+			Domain:   "example.com",
+			Path:     "/app",
+			SameSite: http.SameSiteStrictMode,
+			HttpOnly: &httpOnly,
+			Secure:   &secure,
+			MaxAge:   -1,
+		}),
+	))
+	for _, cookie := range []*http.Cookie{saved, deleted} {
+		test.That(t, cookie.Name, test.ShouldEqual, "sid")
+		test.That(t, cookie.Domain, test.ShouldEqual, "example.com")
+		test.That(t, cookie.Path, test.ShouldEqual, "/app")
+		test.That(t, cookie.HttpOnly, test.ShouldBeFalse)
+		test.That(t, cookie.Secure, test.ShouldBeTrue)
+		test.That(t, cookie.SameSite, test.ShouldEqual, http.SameSiteStrictMode)
+	}
+	test.That(t, saved.MaxAge, test.ShouldEqual, 0)
+	test.That(t, deleted.MaxAge, test.ShouldEqual, -1)
+}
+
+func TestSessionFlashes(t *testing.T) {
+	store, err := NewCookieSessionStore(bytes.Repeat([]byte{1}, 32))
+	test.That(t, err, test.ShouldBeNil)
+	sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionCodec(JSONSessionCodec))
+
+	// saves the session and returns it as loaded by the next request.
+	roundTrip := func(s *Session) *Session {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		w := &DummyWriter{}
+		test.That(t, s.Save(context.Background(), r, w), test.ShouldBeNil)
+		r.AddCookie((&http.Response{Header: w.Header()}).Cookies()[0])
+		s, err = sm.Get(r, false)
+		test.That(t, err, test.ShouldBeNil)
+		return s
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Flashes(), test.ShouldBeEmpty)
+
+	s.AddFlash("info", "saved")
+	s = roundTrip(s)
+	s.AddFlash("error", "failed")
+	s = roundTrip(s)
+
+	test.That(t, s.Flashes(), test.ShouldResemble, []Flash{
+		{Category: "info", Message: "saved"},
+		{Category: "error", Message: "failed"},
+	})
+	test.That(t, s.Flashes(), test.ShouldBeEmpty)
+	s = roundTrip(s)
+	test.That(t, s.Flashes(), test.ShouldBeEmpty)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("copies", func(t *testing.T) {
+		store := NewMemorySessionStore()
+		s1 := &Session{id: "foo", Data: map[string]interface{}{"a": map[string]interface{}{"b": 1}}}
+		test.That(t, store.Save(ctx, s1), test.ShouldBeNil)
+		s1.Data["a"].(map[string]interface{})["b"] = 2
+
+		s2, err := store.Get(ctx, "foo")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s2.Data["a"], test.ShouldResemble, map[string]interface{}{"b": 1})
+		s2.Data["a"].(map[string]interface{})["b"] = 3
+
+		s3, err := store.Get(ctx, "foo")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, s3.Data["a"], test.ShouldResemble, map[string]interface{}{"b": 1})
+
+		test.That(t, store.Delete(ctx, "foo"), test.ShouldBeNil)
+		_, err = store.Get(ctx, "foo")
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		store := NewMemorySessionStore()
+		test.That(t, store.Save(ctx, &Session{id: "foo", Data: map[string]interface{}{}}), test.ShouldBeNil)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					s, err := store.Get(ctx, "foo")
+					test.That(t, err, test.ShouldBeNil)
+					s.Data["count"] = i*100 + j
+					test.That(t, store.Save(ctx, s), test.ShouldBeNil)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	t.Run("compatibility", func(t *testing.T) {
+		testSessionStoreCompatibility(t, NewMemorySessionStore())
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		store := NewMemorySessionStoreWithOptions(MemorySessionStoreOptions{MaxEntries: 2})
+		test.That(t, store.Save(ctx, &Session{id: "a"}), test.ShouldBeNil)
+		time.Sleep(time.Millisecond)
+		test.That(t, store.Save(ctx, &Session{id: "b"}), test.ShouldBeNil)
+		time.Sleep(time.Millisecond)
+		_, err := store.Get(ctx, "a")
+		test.That(t, err, test.ShouldBeNil)
+		time.Sleep(time.Millisecond)
+		test.That(t, store.Save(ctx, &Session{id: "c"}), test.ShouldBeNil)
+
+		// b was used least recently.
+		_, err = store.Get(ctx, "b")
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+		for _, id := range []string{"a", "c"} {
+			_, err = store.Get(ctx, id)
+			test.That(t, err, test.ShouldBeNil)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		store := NewMemorySessionStoreWithOptions(MemorySessionStoreOptions{TTL: time.Millisecond})
+		test.That(t, store.Save(ctx, &Session{id: "a"}), test.ShouldBeNil)
+		time.Sleep(2 * time.Millisecond)
+		_, err := store.Get(ctx, "a")
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+
+		mss := store.(*memorySessionStore)
+		test.That(t, mss.deleteExpired(ctx, func(s *Session) bool { return false }), test.ShouldBeNil)
+		test.That(t, mss.entries, test.ShouldBeEmpty)
+	})
+}
+
+func TestSessionDeleteAllForUser(t *testing.T) {
+	ctx := context.Background()
+	logger := golog.NewTestLogger(t)
+
+	for _, tc := range []struct {
+		name  string
+		store func() Store
+	}{
+		{"memory", NewMemorySessionStore},
+		{"instrumented", func() Store {
+			return NewInstrumentedSessionStore(NewMemorySessionStore(), logger, InstrumentedSessionStoreOptions{})
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := tc.store()
+			sm := NewSessionManager(store, logger)
+			test.That(t, sm.DeleteAllForUser(ctx, "alice"), test.ShouldNotBeNil)
+
+			sm = NewSessionManager(store, logger, WithSessionUserKeyField("email"))
+			ids := map[string]string{}
+			for _, user := range []string{"alice", "alice", "bob", ""} {
+				r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+				test.That(t, err, test.ShouldBeNil)
+				s, err := sm.Get(r, true)
+				test.That(t, err, test.ShouldBeNil)
+				if user != "" {
+					s.SetValue("email", user)
+				}
+				test.That(t, s.Save(ctx, r, &DummyWriter{}), test.ShouldBeNil)
+				ids[s.id] = user
+			}
+
+			test.That(t, sm.DeleteAllForUser(ctx, ""), test.ShouldNotBeNil)
+			test.That(t, sm.DeleteAllForUser(ctx, "alice"), test.ShouldBeNil)
+			for id, user := range ids {
+				_, err := store.Get(ctx, id)
+				if user == "alice" {
+					test.That(t, err, test.ShouldBeError, ErrNoSession)
+				} else {
+					test.That(t, err, test.ShouldBeNil)
+				}
+			}
+		})
+	}
+
+	store, err := NewCookieSessionStore(bytes.Repeat([]byte{1}, 32))
+	test.That(t, err, test.ShouldBeNil)
+	sm := NewSessionManager(store, logger, WithSessionUserKeyField("email"))
+	test.That(t, sm.DeleteAllForUser(ctx, "alice"), test.ShouldNotBeNil)
+}
+
+func TestSessionObserver(t *testing.T) {
+	ctx := context.Background()
+	var events []string
+	record := func(event string) func(ctx context.Context, id string, data map[string]interface{}) {
+		return func(ctx context.Context, id string, data map[string]interface{}) {
+			events = append(events, fmt.Sprintf("%s %v", event, data["name"]))
+			if data != nil {
+				// observers get a snapshot they cannot change the session through.
+				data["name"] = "mallory"
+			}
+		}
+	}
+	store := NewMemorySessionStore()
+	sm := NewSessionManager(store, golog.NewTestLogger(t), WithSessionObserver(SessionObserverFuncs{
+		Created:   record("created"),
+		Loaded:    record("loaded"),
+		Saved:     record("saved"),
+		Destroyed: record("destroyed"),
+	}))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, events, test.ShouldBeEmpty)
+
+	s.Data["name"] = "alice"
+	w := &DummyWriter{}
+	test.That(t, s.Save(ctx, r, w), test.ShouldBeNil)
+	test.That(t, s.Data["name"], test.ShouldEqual, "alice")
+	test.That(t, events, test.ShouldResemble, []string{"created alice", "saved alice"})
+
+	events = nil
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	r.AddCookie(cookies[0])
+	s, err = sm.Get(r, false)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.Data["name"], test.ShouldEqual, "alice")
+	test.That(t, s.Save(ctx, r, w), test.ShouldBeNil)
+	test.That(t, events, test.ShouldResemble, []string{"loaded alice", "saved alice"})
+
+	events = nil
+	test.That(t, s.Regenerate(ctx, w), test.ShouldBeNil)
+	test.That(t, events, test.ShouldResemble, []string{"created alice", "saved alice", "destroyed alice"})
+
+	events = nil
+	r, err = http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s.id})
+	sm.DeleteSession(ctx, r, &DummyWriter{})
+	test.That(t, events, test.ShouldResemble, []string{"destroyed <nil>"})
+}
+
+func TestSessionErrors(t *testing.T) {
+	logger := golog.NewTestLogger(t)
+	store := &flakyStore{Store: NewMemorySessionStore()}
+	sm := NewSessionManager(store, logger, WithSessionIdleTimeout(time.Minute))
+
+	status := func(err error) int {
+		w := httptest.NewRecorder()
+		test.That(t, HandleError(w, err, logger), test.ShouldBeTrue)
+		return w.Code
+	}
+
+	for _, value := range []string{"", "unknown"} {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+		test.That(t, err, test.ShouldBeNil)
+		r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: value})
+		_, err = sm.Get(r, false)
+		test.That(t, err, test.ShouldBeError, ErrNoSession)
+		test.That(t, status(err), test.ShouldEqual, http.StatusUnauthorized)
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	test.That(t, err, test.ShouldBeNil)
+	s, err := sm.Get(r, true)
+	test.That(t, err, test.ShouldBeNil)
+	s.lastUpdate = time.Now().Add(-time.Hour)
+	test.That(t, store.Save(context.Background(), s), test.ShouldBeNil)
+	r.AddCookie(&http.Cookie{Name: sm.opts.cookie.Name, Value: s.id})
+	_, err = sm.Get(r, false)
+	test.That(t, err, test.ShouldBeError, ErrExpired)
+	test.That(t, errors.Is(err, ErrNoSession), test.ShouldBeTrue)
+	test.That(t, status(err), test.ShouldEqual, http.StatusUnauthorized)
+
+	store.down = true
+	_, err = sm.Get(r, true)
+	test.That(t, errors.Is(err, ErrStoreUnavailable), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, errStoreDown), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, ErrNoSession), test.ShouldBeFalse)
+	test.That(t, status(err), test.ShouldEqual, http.StatusServiceUnavailable)
+}
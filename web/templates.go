@@ -204,6 +204,8 @@ func baseTemplate(opts protojson.MarshalingOptions) *template.Template {
 
 	// Support optional protoJson
 	funcs["protoJson"] = createToProtoJSON(opts)
+	funcs["csrfField"] = CSRFTemplateField
+	funcs["flashes"] = RenderFlashes
 
 	return template.New("app").Funcs(funcs)
 }
@@ -94,3 +94,9 @@ func staticHandler(template string, data interface{}, err error) TemplateHandler
 		return NamedTemplate(template), data, err
 	}
 }
+
+func TestTemplateFlashes(t *testing.T) {
+	flashes := []Flash{{Category: "info", Message: "saved"}, {Category: "error", Message: "<oops>"}}
+	validateTemplateRendering(t, "flashes.html", flashes, `<div class="flash flash-info">saved</div>
+<div class="flash flash-error">&lt;oops&gt;</div>`)
+}
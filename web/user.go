@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/pkg/errors"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 // UserInfo basic info about a user from a session.
@@ -75,23 +74,22 @@ func GetLoggedInUserInfo(sessions *SessionManager, r *http.Request) (UserInfo, e
 
 	session, err := sessions.Get(r, false)
 	if err != nil {
-		if errors.Is(err, errNoSession) {
+		if errors.Is(err, ErrNoSession) {
 			return ui, nil
 		}
 		return ui, err
 	}
 
-	v := session.Data["profile"]
-	if v == nil {
+	profile, ok, err := GetValue[map[string]interface{}](session, "profile")
+	if err != nil {
+		return ui, errors.Wrap(err, "invalid profile in session")
+	}
+	if !ok {
 		return ui, nil
 	}
 
 	ui.LoggedIn = true
-	var ok bool
-	ui.Properties, ok = v.(bson.M)
-	if !ok {
-		return ui, errors.Errorf("expected bson.M but got %T", v)
-	}
+	ui.Properties = profile
 
 	return ui, nil
 }